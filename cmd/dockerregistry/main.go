@@ -1,33 +1,31 @@
 package main
 
 import (
-	"flag"
-	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/openshift/origin/pkg/cmd/dockerregistry"
 )
 
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
-	flag.Parse()
 
-	// TODO convert to flags instead of a config file?
-	configurationPath := ""
-	if flag.NArg() > 0 {
-		configurationPath = flag.Arg(0)
+	basename := filepath.Base(os.Args[0])
+	command := dockerregistry.NewCommandDockerRegistry(basename)
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		// dockerregistry gc [--dry-run] [--min-age=<duration>] <config>
+		case "gc":
+			command, args = dockerregistry.NewCommandGC(basename+" gc"), args[1:]
+		// dockerregistry migrate-storage [--dry-run] [--remove-legacy] <config>
+		case "migrate-storage":
+			command, args = dockerregistry.NewCommandMigrateStorage(basename+" migrate-storage"), args[1:]
+		}
 	}
-	if configurationPath == "" {
-		fmt.Println("configuration path unspecified")
+	command.SetArgs(args)
+	if err := command.Execute(); err != nil {
 		os.Exit(1)
 	}
-
-	configFile, err := os.Open(configurationPath)
-	if err != nil {
-		log.Fatalf("Unable to open configuration file: %s", err)
-	}
-
-	dockerregistry.Execute(configFile)
 }
@@ -1863,6 +1863,119 @@ func deepCopy_api_Image(in imageapi.Image, out *imageapi.Image, c *conversion.Cl
 	}
 	out.DockerImageMetadataVersion = in.DockerImageMetadataVersion
 	out.DockerImageManifest = in.DockerImageManifest
+	if in.Signatures != nil {
+		out.Signatures = make([]imageapi.ImageSignature, len(in.Signatures))
+		for i := range in.Signatures {
+			if err := deepCopy_api_ImageSignature(in.Signatures[i], &out.Signatures[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Signatures = nil
+	}
+	if in.Finalizers != nil {
+		out.Finalizers = make([]pkgapi.FinalizerName, len(in.Finalizers))
+		for i := range in.Finalizers {
+			out.Finalizers[i] = in.Finalizers[i]
+		}
+	} else {
+		out.Finalizers = nil
+	}
+	return nil
+}
+
+func deepCopy_api_ImageImportSpec(in imageapi.ImageImportSpec, out *imageapi.ImageImportSpec, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.From); err != nil {
+		return err
+	} else {
+		out.From = newVal.(pkgapi.ObjectReference)
+	}
+	if in.To != nil {
+		if newVal, err := c.DeepCopy(in.To); err != nil {
+			return err
+		} else {
+			out.To = newVal.(*pkgapi.LocalObjectReference)
+		}
+	} else {
+		out.To = nil
+	}
+	if err := deepCopy_api_TagImportPolicy(in.ImportPolicy, &out.ImportPolicy, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deepCopy_api_ImageImportStatus(in imageapi.ImageImportStatus, out *imageapi.ImageImportStatus, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.Status); err != nil {
+		return err
+	} else {
+		out.Status = newVal.(unversioned.Status)
+	}
+	if in.Image != nil {
+		out.Image = new(imageapi.Image)
+		if err := deepCopy_api_Image(*in.Image, out.Image, c); err != nil {
+			return err
+		}
+	} else {
+		out.Image = nil
+	}
+	out.Tag = in.Tag
+	return nil
+}
+
+func deepCopy_api_ImageLayer(in imageapi.ImageLayer, out *imageapi.ImageLayer, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ObjectMeta); err != nil {
+		return err
+	} else {
+		out.ObjectMeta = newVal.(pkgapi.ObjectMeta)
+	}
+	out.Size = in.Size
+	if in.Images != nil {
+		out.Images = make([]string, len(in.Images))
+		for i := range in.Images {
+			out.Images[i] = in.Images[i]
+		}
+	} else {
+		out.Images = nil
+	}
+	if in.ImageStreams != nil {
+		out.ImageStreams = make([]string, len(in.ImageStreams))
+		for i := range in.ImageStreams {
+			out.ImageStreams[i] = in.ImageStreams[i]
+		}
+	} else {
+		out.ImageStreams = nil
+	}
+	out.Shared = in.Shared
+	return nil
+}
+
+func deepCopy_api_ImageLayerList(in imageapi.ImageLayerList, out *imageapi.ImageLayerList, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ListMeta); err != nil {
+		return err
+	} else {
+		out.ListMeta = newVal.(unversioned.ListMeta)
+	}
+	if in.Items != nil {
+		out.Items = make([]imageapi.ImageLayer, len(in.Items))
+		for i := range in.Items {
+			if err := deepCopy_api_ImageLayer(in.Items[i], &out.Items[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1890,6 +2003,38 @@ func deepCopy_api_ImageList(in imageapi.ImageList, out *imageapi.ImageList, c *c
 	return nil
 }
 
+func deepCopy_api_ImageSignature(in imageapi.ImageSignature, out *imageapi.ImageSignature, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ObjectMeta); err != nil {
+		return err
+	} else {
+		out.ObjectMeta = newVal.(pkgapi.ObjectMeta)
+	}
+	out.Type = in.Type
+	if in.Content != nil {
+		out.Content = make([]uint8, len(in.Content))
+		for i := range in.Content {
+			out.Content[i] = in.Content[i]
+		}
+	} else {
+		out.Content = nil
+	}
+	out.ImageIdentity = in.ImageIdentity
+	if in.SignedClaims != nil {
+		out.SignedClaims = make(map[string]string)
+		for key, val := range in.SignedClaims {
+			out.SignedClaims[key] = val
+		}
+	} else {
+		out.SignedClaims = nil
+	}
+	return nil
+}
+
 func deepCopy_api_ImageStream(in imageapi.ImageStream, out *imageapi.ImageStream, c *conversion.Cloner) error {
 	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
 		return err
@@ -1927,6 +2072,79 @@ func deepCopy_api_ImageStreamImage(in imageapi.ImageStreamImage, out *imageapi.I
 	return nil
 }
 
+func deepCopy_api_ImageStreamImport(in imageapi.ImageStreamImport, out *imageapi.ImageStreamImport, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ObjectMeta); err != nil {
+		return err
+	} else {
+		out.ObjectMeta = newVal.(pkgapi.ObjectMeta)
+	}
+	if err := deepCopy_api_ImageStreamImportSpec(in.Spec, &out.Spec, c); err != nil {
+		return err
+	}
+	if err := deepCopy_api_ImageStreamImportStatus(in.Status, &out.Status, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deepCopy_api_ImageStreamImportSpec(in imageapi.ImageStreamImportSpec, out *imageapi.ImageStreamImportSpec, c *conversion.Cloner) error {
+	out.Import = in.Import
+	if in.Repository != nil {
+		out.Repository = new(imageapi.RepositoryImportSpec)
+		if err := deepCopy_api_RepositoryImportSpec(*in.Repository, out.Repository, c); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapi.ImageImportSpec, len(in.Images))
+		for i := range in.Images {
+			if err := deepCopy_api_ImageImportSpec(in.Images[i], &out.Images[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func deepCopy_api_ImageStreamImportStatus(in imageapi.ImageStreamImportStatus, out *imageapi.ImageStreamImportStatus, c *conversion.Cloner) error {
+	if in.Import != nil {
+		out.Import = new(imageapi.ImageStream)
+		if err := deepCopy_api_ImageStream(*in.Import, out.Import, c); err != nil {
+			return err
+		}
+	} else {
+		out.Import = nil
+	}
+	if in.Repository != nil {
+		out.Repository = new(imageapi.RepositoryImportStatus)
+		if err := deepCopy_api_RepositoryImportStatus(*in.Repository, out.Repository, c); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapi.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := deepCopy_api_ImageImportStatus(in.Images[i], &out.Images[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
 func deepCopy_api_ImageStreamList(in imageapi.ImageStreamList, out *imageapi.ImageStreamList, c *conversion.Cloner) error {
 	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
 		return err
@@ -1984,6 +2202,15 @@ func deepCopy_api_ImageStreamSpec(in imageapi.ImageStreamSpec, out *imageapi.Ima
 	} else {
 		out.Tags = nil
 	}
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if in.Mirror != nil {
+		out.Mirror = new(imageapi.RepositoryMirrorPolicy)
+		if err := deepCopy_api_RepositoryMirrorPolicy(*in.Mirror, out.Mirror, c); err != nil {
+			return err
+		}
+	} else {
+		out.Mirror = nil
+	}
 	return nil
 }
 
@@ -2045,6 +2272,54 @@ func deepCopy_api_ImageStreamTagList(in imageapi.ImageStreamTagList, out *imagea
 	return nil
 }
 
+func deepCopy_api_RepositoryImportSpec(in imageapi.RepositoryImportSpec, out *imageapi.RepositoryImportSpec, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.From); err != nil {
+		return err
+	} else {
+		out.From = newVal.(pkgapi.ObjectReference)
+	}
+	return nil
+}
+
+func deepCopy_api_RepositoryImportStatus(in imageapi.RepositoryImportStatus, out *imageapi.RepositoryImportStatus, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.Status); err != nil {
+		return err
+	} else {
+		out.Status = newVal.(unversioned.Status)
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapi.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := deepCopy_api_ImageImportStatus(in.Images[i], &out.Images[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func deepCopy_api_RepositoryMirrorPolicy(in imageapi.RepositoryMirrorPolicy, out *imageapi.RepositoryMirrorPolicy, c *conversion.Cloner) error {
+	if in.IncludeTags != nil {
+		out.IncludeTags = make([]string, len(in.IncludeTags))
+		for i := range in.IncludeTags {
+			out.IncludeTags[i] = in.IncludeTags[i]
+		}
+	} else {
+		out.IncludeTags = nil
+	}
+	if in.ExcludeTags != nil {
+		out.ExcludeTags = make([]string, len(in.ExcludeTags))
+		for i := range in.ExcludeTags {
+			out.ExcludeTags[i] = in.ExcludeTags[i]
+		}
+	} else {
+		out.ExcludeTags = nil
+	}
+	return nil
+}
+
 func deepCopy_api_TagEvent(in imageapi.TagEvent, out *imageapi.TagEvent, c *conversion.Cloner) error {
 	if newVal, err := c.DeepCopy(in.Created); err != nil {
 		return err
@@ -2056,6 +2331,26 @@ func deepCopy_api_TagEvent(in imageapi.TagEvent, out *imageapi.TagEvent, c *conv
 	return nil
 }
 
+func deepCopy_api_TagEventCondition(in imageapi.TagEventCondition, out *imageapi.TagEventCondition, c *conversion.Cloner) error {
+	out.Type = in.Type
+	out.Status = in.Status
+	if newVal, err := c.DeepCopy(in.LastTransitionTime); err != nil {
+		return err
+	} else {
+		out.LastTransitionTime = newVal.(unversioned.Time)
+	}
+	out.Reason = in.Reason
+	out.Message = in.Message
+	out.Failures = in.Failures
+	if newVal, err := c.DeepCopy(in.LastAttemptTime); err != nil {
+		return err
+	} else {
+		out.LastAttemptTime = newVal.(unversioned.Time)
+	}
+	out.Generation = in.Generation
+	return nil
+}
+
 func deepCopy_api_TagEventList(in imageapi.TagEventList, out *imageapi.TagEventList, c *conversion.Cloner) error {
 	if in.Items != nil {
 		out.Items = make([]imageapi.TagEvent, len(in.Items))
@@ -2067,6 +2362,23 @@ func deepCopy_api_TagEventList(in imageapi.TagEventList, out *imageapi.TagEventL
 	} else {
 		out.Items = nil
 	}
+	if in.Conditions != nil {
+		out.Conditions = make([]imageapi.TagEventCondition, len(in.Conditions))
+		for i := range in.Conditions {
+			if err := deepCopy_api_TagEventCondition(in.Conditions[i], &out.Conditions[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Conditions = nil
+	}
+	return nil
+}
+
+func deepCopy_api_TagImportPolicy(in imageapi.TagImportPolicy, out *imageapi.TagImportPolicy, c *conversion.Cloner) error {
+	out.Scheduled = in.Scheduled
+	out.Insecure = in.Insecure
+	out.ImportMode = in.ImportMode
 	return nil
 }
 
@@ -2089,6 +2401,19 @@ func deepCopy_api_TagReference(in imageapi.TagReference, out *imageapi.TagRefere
 		out.From = nil
 	}
 	out.Reference = in.Reference
+	if err := deepCopy_api_TagImportPolicy(in.ImportPolicy, &out.ImportPolicy, c); err != nil {
+		return err
+	}
+	if err := deepCopy_api_TagReferencePolicy(in.ReferencePolicy, &out.ReferencePolicy, c); err != nil {
+		return err
+	}
+	out.Immutable = in.Immutable
+	out.Track = in.Track
+	return nil
+}
+
+func deepCopy_api_TagReferencePolicy(in imageapi.TagReferencePolicy, out *imageapi.TagReferencePolicy, c *conversion.Cloner) error {
+	out.Type = in.Type
 	return nil
 }
 
@@ -2921,18 +3246,32 @@ func init() {
 		deepCopy_api_DockerConfig,
 		deepCopy_api_DockerImage,
 		deepCopy_api_Image,
+		deepCopy_api_ImageImportSpec,
+		deepCopy_api_ImageImportStatus,
+		deepCopy_api_ImageLayer,
+		deepCopy_api_ImageLayerList,
 		deepCopy_api_ImageList,
+		deepCopy_api_ImageSignature,
 		deepCopy_api_ImageStream,
 		deepCopy_api_ImageStreamImage,
+		deepCopy_api_ImageStreamImport,
+		deepCopy_api_ImageStreamImportSpec,
+		deepCopy_api_ImageStreamImportStatus,
 		deepCopy_api_ImageStreamList,
 		deepCopy_api_ImageStreamMapping,
 		deepCopy_api_ImageStreamSpec,
 		deepCopy_api_ImageStreamStatus,
 		deepCopy_api_ImageStreamTag,
 		deepCopy_api_ImageStreamTagList,
+		deepCopy_api_RepositoryImportSpec,
+		deepCopy_api_RepositoryImportStatus,
+		deepCopy_api_RepositoryMirrorPolicy,
 		deepCopy_api_TagEvent,
+		deepCopy_api_TagEventCondition,
 		deepCopy_api_TagEventList,
+		deepCopy_api_TagImportPolicy,
 		deepCopy_api_TagReference,
+		deepCopy_api_TagReferencePolicy,
 		deepCopy_api_OAuthAccessToken,
 		deepCopy_api_OAuthAccessTokenList,
 		deepCopy_api_OAuthAuthorizeToken,
@@ -28,6 +28,8 @@ type ImagePipeline struct {
 	BaseImage ImageTagLocation
 	// If set, the source repository that inputs to the build
 	Source SourceLocation
+	// If set, the Docker repository tag the image is imported from or references
+	ImportedFrom ImageTagLocation
 }
 
 // ImageTagLocation identifies the source or destination of an image. Represents
@@ -106,6 +108,11 @@ func NewImagePipelineFromImageTagLocation(g osgraph.Graph, node graph.Node, imag
 		flow.LastSuccessfulBuild, flow.LastUnsuccessfulBuild, flow.ActiveBuilds = buildedges.RelevantBuilds(g, flow.Build)
 	}
 
+	for _, input := range g.PredecessorNodesByEdgeKind(node, imageedges.ImportedImageStreamTagGraphEdgeKind) {
+		covered.Insert(input.ID())
+		flow.ImportedFrom = input.(ImageTagLocation)
+	}
+
 	for _, input := range g.SuccessorNodesByEdgeKind(node, imageedges.ReferencedImageStreamGraphEdgeKind) {
 		covered.Insert(input.ID())
 		imageStreamNode := input.(*imagegraph.ImageStreamNode)
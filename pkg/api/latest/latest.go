@@ -120,7 +120,8 @@ func init() {
 		"Project":        true,
 		"ProjectRequest": true,
 
-		"Image": true,
+		"Image":          true,
+		"ImageSignature": true,
 
 		"User":                true,
 		"Identity":            true,
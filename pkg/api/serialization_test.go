@@ -177,6 +177,10 @@ func fuzzInternalObject(t *testing.T, forVersion string, item runtime.Object, se
 				specs := []string{"", "ImageStreamTag", "ImageStreamImage"}
 				j.From.Kind = specs[c.Intn(len(specs))]
 			}
+			// an empty reference policy is defaulted to Source
+			if len(j.ReferencePolicy.Type) == 0 {
+				j.ReferencePolicy.Type = image.SourceTagReferencePolicy
+			}
 		},
 		func(j *build.SourceBuildStrategy, c fuzz.Continue) {
 			c.FuzzNoCustom(j)
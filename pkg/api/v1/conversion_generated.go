@@ -2796,9 +2796,136 @@ func autoconvert_api_Image_To_v1_Image(in *imageapi.Image, out *imageapiv1.Image
 	}
 	out.DockerImageMetadataVersion = in.DockerImageMetadataVersion
 	out.DockerImageManifest = in.DockerImageManifest
+	if in.Signatures != nil {
+		out.Signatures = make([]imageapiv1.ImageSignature, len(in.Signatures))
+		for i := range in.Signatures {
+			if err := convert_api_ImageSignature_To_v1_ImageSignature(&in.Signatures[i], &out.Signatures[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Signatures = nil
+	}
+	if in.Finalizers != nil {
+		out.Finalizers = make([]pkgapiv1.FinalizerName, len(in.Finalizers))
+		for i := range in.Finalizers {
+			out.Finalizers[i] = pkgapiv1.FinalizerName(in.Finalizers[i])
+		}
+	} else {
+		out.Finalizers = nil
+	}
+	return nil
+}
+
+func autoconvert_api_ImageImportSpec_To_v1_ImageImportSpec(in *imageapi.ImageImportSpec, out *imageapiv1.ImageImportSpec, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageImportSpec))(in)
+	}
+	if err := convert_api_ObjectReference_To_v1_ObjectReference(&in.From, &out.From, s); err != nil {
+		return err
+	}
+	if in.To != nil {
+		out.To = new(pkgapiv1.LocalObjectReference)
+		if err := convert_api_LocalObjectReference_To_v1_LocalObjectReference(in.To, out.To, s); err != nil {
+			return err
+		}
+	} else {
+		out.To = nil
+	}
+	if err := convert_api_TagImportPolicy_To_v1_TagImportPolicy(&in.ImportPolicy, &out.ImportPolicy, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func convert_api_ImageImportSpec_To_v1_ImageImportSpec(in *imageapi.ImageImportSpec, out *imageapiv1.ImageImportSpec, s conversion.Scope) error {
+	return autoconvert_api_ImageImportSpec_To_v1_ImageImportSpec(in, out, s)
+}
+
+func autoconvert_api_ImageImportStatus_To_v1_ImageImportStatus(in *imageapi.ImageImportStatus, out *imageapiv1.ImageImportStatus, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageImportStatus))(in)
+	}
+	if err := s.Convert(&in.Status, &out.Status, 0); err != nil {
+		return err
+	}
+	if in.Image != nil {
+		if err := s.Convert(&in.Image, &out.Image, 0); err != nil {
+			return err
+		}
+	} else {
+		out.Image = nil
+	}
+	out.Tag = in.Tag
 	return nil
 }
 
+func convert_api_ImageImportStatus_To_v1_ImageImportStatus(in *imageapi.ImageImportStatus, out *imageapiv1.ImageImportStatus, s conversion.Scope) error {
+	return autoconvert_api_ImageImportStatus_To_v1_ImageImportStatus(in, out, s)
+}
+
+func autoconvert_api_ImageLayer_To_v1_ImageLayer(in *imageapi.ImageLayer, out *imageapiv1.ImageLayer, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageLayer))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := convert_api_ObjectMeta_To_v1_ObjectMeta(&in.ObjectMeta, &out.ObjectMeta, s); err != nil {
+		return err
+	}
+	out.Size = in.Size
+	if in.Images != nil {
+		out.Images = make([]string, len(in.Images))
+		for i := range in.Images {
+			out.Images[i] = in.Images[i]
+		}
+	} else {
+		out.Images = nil
+	}
+	if in.ImageStreams != nil {
+		out.ImageStreams = make([]string, len(in.ImageStreams))
+		for i := range in.ImageStreams {
+			out.ImageStreams[i] = in.ImageStreams[i]
+		}
+	} else {
+		out.ImageStreams = nil
+	}
+	out.Shared = in.Shared
+	return nil
+}
+
+func convert_api_ImageLayer_To_v1_ImageLayer(in *imageapi.ImageLayer, out *imageapiv1.ImageLayer, s conversion.Scope) error {
+	return autoconvert_api_ImageLayer_To_v1_ImageLayer(in, out, s)
+}
+
+func autoconvert_api_ImageLayerList_To_v1_ImageLayerList(in *imageapi.ImageLayerList, out *imageapiv1.ImageLayerList, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageLayerList))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := s.Convert(&in.ListMeta, &out.ListMeta, 0); err != nil {
+		return err
+	}
+	if in.Items != nil {
+		out.Items = make([]imageapiv1.ImageLayer, len(in.Items))
+		for i := range in.Items {
+			if err := convert_api_ImageLayer_To_v1_ImageLayer(&in.Items[i], &out.Items[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	return nil
+}
+
+func convert_api_ImageLayerList_To_v1_ImageLayerList(in *imageapi.ImageLayerList, out *imageapiv1.ImageLayerList, s conversion.Scope) error {
+	return autoconvert_api_ImageLayerList_To_v1_ImageLayerList(in, out, s)
+}
+
 func autoconvert_api_ImageList_To_v1_ImageList(in *imageapi.ImageList, out *imageapiv1.ImageList, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapi.ImageList))(in)
@@ -2826,6 +2953,36 @@ func convert_api_ImageList_To_v1_ImageList(in *imageapi.ImageList, out *imageapi
 	return autoconvert_api_ImageList_To_v1_ImageList(in, out, s)
 }
 
+func autoconvert_api_ImageSignature_To_v1_ImageSignature(in *imageapi.ImageSignature, out *imageapiv1.ImageSignature, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageSignature))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := convert_api_ObjectMeta_To_v1_ObjectMeta(&in.ObjectMeta, &out.ObjectMeta, s); err != nil {
+		return err
+	}
+	out.Type = in.Type
+	if err := s.Convert(&in.Content, &out.Content, 0); err != nil {
+		return err
+	}
+	out.ImageIdentity = in.ImageIdentity
+	if in.SignedClaims != nil {
+		out.SignedClaims = make(map[string]string)
+		for key, val := range in.SignedClaims {
+			out.SignedClaims[key] = val
+		}
+	} else {
+		out.SignedClaims = nil
+	}
+	return nil
+}
+
+func convert_api_ImageSignature_To_v1_ImageSignature(in *imageapi.ImageSignature, out *imageapiv1.ImageSignature, s conversion.Scope) error {
+	return autoconvert_api_ImageSignature_To_v1_ImageSignature(in, out, s)
+}
+
 func autoconvert_api_ImageStream_To_v1_ImageStream(in *imageapi.ImageStream, out *imageapiv1.ImageStream, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapi.ImageStream))(in)
@@ -2869,6 +3026,96 @@ func convert_api_ImageStreamImage_To_v1_ImageStreamImage(in *imageapi.ImageStrea
 	return autoconvert_api_ImageStreamImage_To_v1_ImageStreamImage(in, out, s)
 }
 
+func autoconvert_api_ImageStreamImport_To_v1_ImageStreamImport(in *imageapi.ImageStreamImport, out *imageapiv1.ImageStreamImport, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageStreamImport))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := convert_api_ObjectMeta_To_v1_ObjectMeta(&in.ObjectMeta, &out.ObjectMeta, s); err != nil {
+		return err
+	}
+	if err := convert_api_ImageStreamImportSpec_To_v1_ImageStreamImportSpec(&in.Spec, &out.Spec, s); err != nil {
+		return err
+	}
+	if err := convert_api_ImageStreamImportStatus_To_v1_ImageStreamImportStatus(&in.Status, &out.Status, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func convert_api_ImageStreamImport_To_v1_ImageStreamImport(in *imageapi.ImageStreamImport, out *imageapiv1.ImageStreamImport, s conversion.Scope) error {
+	return autoconvert_api_ImageStreamImport_To_v1_ImageStreamImport(in, out, s)
+}
+
+func autoconvert_api_ImageStreamImportSpec_To_v1_ImageStreamImportSpec(in *imageapi.ImageStreamImportSpec, out *imageapiv1.ImageStreamImportSpec, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageStreamImportSpec))(in)
+	}
+	out.Import = in.Import
+	if in.Repository != nil {
+		out.Repository = new(imageapiv1.RepositoryImportSpec)
+		if err := convert_api_RepositoryImportSpec_To_v1_RepositoryImportSpec(in.Repository, out.Repository, s); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapiv1.ImageImportSpec, len(in.Images))
+		for i := range in.Images {
+			if err := convert_api_ImageImportSpec_To_v1_ImageImportSpec(&in.Images[i], &out.Images[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func convert_api_ImageStreamImportSpec_To_v1_ImageStreamImportSpec(in *imageapi.ImageStreamImportSpec, out *imageapiv1.ImageStreamImportSpec, s conversion.Scope) error {
+	return autoconvert_api_ImageStreamImportSpec_To_v1_ImageStreamImportSpec(in, out, s)
+}
+
+func autoconvert_api_ImageStreamImportStatus_To_v1_ImageStreamImportStatus(in *imageapi.ImageStreamImportStatus, out *imageapiv1.ImageStreamImportStatus, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.ImageStreamImportStatus))(in)
+	}
+	if in.Import != nil {
+		out.Import = new(imageapiv1.ImageStream)
+		if err := convert_api_ImageStream_To_v1_ImageStream(in.Import, out.Import, s); err != nil {
+			return err
+		}
+	} else {
+		out.Import = nil
+	}
+	if in.Repository != nil {
+		out.Repository = new(imageapiv1.RepositoryImportStatus)
+		if err := convert_api_RepositoryImportStatus_To_v1_RepositoryImportStatus(in.Repository, out.Repository, s); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapiv1.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := convert_api_ImageImportStatus_To_v1_ImageImportStatus(&in.Images[i], &out.Images[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func convert_api_ImageStreamImportStatus_To_v1_ImageStreamImportStatus(in *imageapi.ImageStreamImportStatus, out *imageapiv1.ImageStreamImportStatus, s conversion.Scope) error {
+	return autoconvert_api_ImageStreamImportStatus_To_v1_ImageStreamImportStatus(in, out, s)
+}
+
 func autoconvert_api_ImageStreamList_To_v1_ImageStreamList(in *imageapi.ImageStreamList, out *imageapiv1.ImageStreamList, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapi.ImageStreamList))(in)
@@ -2922,6 +3169,15 @@ func autoconvert_api_ImageStreamSpec_To_v1_ImageStreamSpec(in *imageapi.ImageStr
 	if err := s.Convert(&in.Tags, &out.Tags, 0); err != nil {
 		return err
 	}
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if in.Mirror != nil {
+		out.Mirror = new(imageapiv1.RepositoryMirrorPolicy)
+		if err := convert_api_RepositoryMirrorPolicy_To_v1_RepositoryMirrorPolicy(in.Mirror, out.Mirror, s); err != nil {
+			return err
+		}
+	} else {
+		out.Mirror = nil
+	}
 	return nil
 }
 
@@ -2983,6 +3239,85 @@ func convert_api_ImageStreamTagList_To_v1_ImageStreamTagList(in *imageapi.ImageS
 	return autoconvert_api_ImageStreamTagList_To_v1_ImageStreamTagList(in, out, s)
 }
 
+func autoconvert_api_RepositoryImportSpec_To_v1_RepositoryImportSpec(in *imageapi.RepositoryImportSpec, out *imageapiv1.RepositoryImportSpec, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.RepositoryImportSpec))(in)
+	}
+	if err := convert_api_ObjectReference_To_v1_ObjectReference(&in.From, &out.From, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func convert_api_RepositoryImportSpec_To_v1_RepositoryImportSpec(in *imageapi.RepositoryImportSpec, out *imageapiv1.RepositoryImportSpec, s conversion.Scope) error {
+	return autoconvert_api_RepositoryImportSpec_To_v1_RepositoryImportSpec(in, out, s)
+}
+
+func autoconvert_api_RepositoryImportStatus_To_v1_RepositoryImportStatus(in *imageapi.RepositoryImportStatus, out *imageapiv1.RepositoryImportStatus, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.RepositoryImportStatus))(in)
+	}
+	if err := s.Convert(&in.Status, &out.Status, 0); err != nil {
+		return err
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapiv1.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := convert_api_ImageImportStatus_To_v1_ImageImportStatus(&in.Images[i], &out.Images[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func convert_api_RepositoryImportStatus_To_v1_RepositoryImportStatus(in *imageapi.RepositoryImportStatus, out *imageapiv1.RepositoryImportStatus, s conversion.Scope) error {
+	return autoconvert_api_RepositoryImportStatus_To_v1_RepositoryImportStatus(in, out, s)
+}
+
+func autoconvert_api_RepositoryMirrorPolicy_To_v1_RepositoryMirrorPolicy(in *imageapi.RepositoryMirrorPolicy, out *imageapiv1.RepositoryMirrorPolicy, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.RepositoryMirrorPolicy))(in)
+	}
+	if in.IncludeTags != nil {
+		out.IncludeTags = make([]string, len(in.IncludeTags))
+		for i := range in.IncludeTags {
+			out.IncludeTags[i] = in.IncludeTags[i]
+		}
+	} else {
+		out.IncludeTags = nil
+	}
+	if in.ExcludeTags != nil {
+		out.ExcludeTags = make([]string, len(in.ExcludeTags))
+		for i := range in.ExcludeTags {
+			out.ExcludeTags[i] = in.ExcludeTags[i]
+		}
+	} else {
+		out.ExcludeTags = nil
+	}
+	return nil
+}
+
+func convert_api_RepositoryMirrorPolicy_To_v1_RepositoryMirrorPolicy(in *imageapi.RepositoryMirrorPolicy, out *imageapiv1.RepositoryMirrorPolicy, s conversion.Scope) error {
+	return autoconvert_api_RepositoryMirrorPolicy_To_v1_RepositoryMirrorPolicy(in, out, s)
+}
+
+func autoconvert_api_TagImportPolicy_To_v1_TagImportPolicy(in *imageapi.TagImportPolicy, out *imageapiv1.TagImportPolicy, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapi.TagImportPolicy))(in)
+	}
+	out.Scheduled = in.Scheduled
+	out.Insecure = in.Insecure
+	out.ImportMode = imageapiv1.TagImportMode(in.ImportMode)
+	return nil
+}
+
+func convert_api_TagImportPolicy_To_v1_TagImportPolicy(in *imageapi.TagImportPolicy, out *imageapiv1.TagImportPolicy, s conversion.Scope) error {
+	return autoconvert_api_TagImportPolicy_To_v1_TagImportPolicy(in, out, s)
+}
+
 func autoconvert_v1_Image_To_api_Image(in *imageapiv1.Image, out *imageapi.Image, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapiv1.Image))(in)
@@ -2999,9 +3334,136 @@ func autoconvert_v1_Image_To_api_Image(in *imageapiv1.Image, out *imageapi.Image
 	}
 	out.DockerImageMetadataVersion = in.DockerImageMetadataVersion
 	out.DockerImageManifest = in.DockerImageManifest
+	if in.Signatures != nil {
+		out.Signatures = make([]imageapi.ImageSignature, len(in.Signatures))
+		for i := range in.Signatures {
+			if err := convert_v1_ImageSignature_To_api_ImageSignature(&in.Signatures[i], &out.Signatures[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Signatures = nil
+	}
+	if in.Finalizers != nil {
+		out.Finalizers = make([]pkgapi.FinalizerName, len(in.Finalizers))
+		for i := range in.Finalizers {
+			out.Finalizers[i] = pkgapi.FinalizerName(in.Finalizers[i])
+		}
+	} else {
+		out.Finalizers = nil
+	}
+	return nil
+}
+
+func autoconvert_v1_ImageImportSpec_To_api_ImageImportSpec(in *imageapiv1.ImageImportSpec, out *imageapi.ImageImportSpec, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageImportSpec))(in)
+	}
+	if err := convert_v1_ObjectReference_To_api_ObjectReference(&in.From, &out.From, s); err != nil {
+		return err
+	}
+	if in.To != nil {
+		out.To = new(pkgapi.LocalObjectReference)
+		if err := convert_v1_LocalObjectReference_To_api_LocalObjectReference(in.To, out.To, s); err != nil {
+			return err
+		}
+	} else {
+		out.To = nil
+	}
+	if err := convert_v1_TagImportPolicy_To_api_TagImportPolicy(&in.ImportPolicy, &out.ImportPolicy, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func convert_v1_ImageImportSpec_To_api_ImageImportSpec(in *imageapiv1.ImageImportSpec, out *imageapi.ImageImportSpec, s conversion.Scope) error {
+	return autoconvert_v1_ImageImportSpec_To_api_ImageImportSpec(in, out, s)
+}
+
+func autoconvert_v1_ImageImportStatus_To_api_ImageImportStatus(in *imageapiv1.ImageImportStatus, out *imageapi.ImageImportStatus, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageImportStatus))(in)
+	}
+	if err := s.Convert(&in.Status, &out.Status, 0); err != nil {
+		return err
+	}
+	if in.Image != nil {
+		if err := s.Convert(&in.Image, &out.Image, 0); err != nil {
+			return err
+		}
+	} else {
+		out.Image = nil
+	}
+	out.Tag = in.Tag
 	return nil
 }
 
+func convert_v1_ImageImportStatus_To_api_ImageImportStatus(in *imageapiv1.ImageImportStatus, out *imageapi.ImageImportStatus, s conversion.Scope) error {
+	return autoconvert_v1_ImageImportStatus_To_api_ImageImportStatus(in, out, s)
+}
+
+func autoconvert_v1_ImageLayer_To_api_ImageLayer(in *imageapiv1.ImageLayer, out *imageapi.ImageLayer, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageLayer))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := convert_v1_ObjectMeta_To_api_ObjectMeta(&in.ObjectMeta, &out.ObjectMeta, s); err != nil {
+		return err
+	}
+	out.Size = in.Size
+	if in.Images != nil {
+		out.Images = make([]string, len(in.Images))
+		for i := range in.Images {
+			out.Images[i] = in.Images[i]
+		}
+	} else {
+		out.Images = nil
+	}
+	if in.ImageStreams != nil {
+		out.ImageStreams = make([]string, len(in.ImageStreams))
+		for i := range in.ImageStreams {
+			out.ImageStreams[i] = in.ImageStreams[i]
+		}
+	} else {
+		out.ImageStreams = nil
+	}
+	out.Shared = in.Shared
+	return nil
+}
+
+func convert_v1_ImageLayer_To_api_ImageLayer(in *imageapiv1.ImageLayer, out *imageapi.ImageLayer, s conversion.Scope) error {
+	return autoconvert_v1_ImageLayer_To_api_ImageLayer(in, out, s)
+}
+
+func autoconvert_v1_ImageLayerList_To_api_ImageLayerList(in *imageapiv1.ImageLayerList, out *imageapi.ImageLayerList, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageLayerList))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := s.Convert(&in.ListMeta, &out.ListMeta, 0); err != nil {
+		return err
+	}
+	if in.Items != nil {
+		out.Items = make([]imageapi.ImageLayer, len(in.Items))
+		for i := range in.Items {
+			if err := convert_v1_ImageLayer_To_api_ImageLayer(&in.Items[i], &out.Items[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	return nil
+}
+
+func convert_v1_ImageLayerList_To_api_ImageLayerList(in *imageapiv1.ImageLayerList, out *imageapi.ImageLayerList, s conversion.Scope) error {
+	return autoconvert_v1_ImageLayerList_To_api_ImageLayerList(in, out, s)
+}
+
 func autoconvert_v1_ImageList_To_api_ImageList(in *imageapiv1.ImageList, out *imageapi.ImageList, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapiv1.ImageList))(in)
@@ -3029,6 +3491,36 @@ func convert_v1_ImageList_To_api_ImageList(in *imageapiv1.ImageList, out *imagea
 	return autoconvert_v1_ImageList_To_api_ImageList(in, out, s)
 }
 
+func autoconvert_v1_ImageSignature_To_api_ImageSignature(in *imageapiv1.ImageSignature, out *imageapi.ImageSignature, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageSignature))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := convert_v1_ObjectMeta_To_api_ObjectMeta(&in.ObjectMeta, &out.ObjectMeta, s); err != nil {
+		return err
+	}
+	out.Type = in.Type
+	if err := s.Convert(&in.Content, &out.Content, 0); err != nil {
+		return err
+	}
+	out.ImageIdentity = in.ImageIdentity
+	if in.SignedClaims != nil {
+		out.SignedClaims = make(map[string]string)
+		for key, val := range in.SignedClaims {
+			out.SignedClaims[key] = val
+		}
+	} else {
+		out.SignedClaims = nil
+	}
+	return nil
+}
+
+func convert_v1_ImageSignature_To_api_ImageSignature(in *imageapiv1.ImageSignature, out *imageapi.ImageSignature, s conversion.Scope) error {
+	return autoconvert_v1_ImageSignature_To_api_ImageSignature(in, out, s)
+}
+
 func autoconvert_v1_ImageStream_To_api_ImageStream(in *imageapiv1.ImageStream, out *imageapi.ImageStream, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapiv1.ImageStream))(in)
@@ -3072,6 +3564,96 @@ func convert_v1_ImageStreamImage_To_api_ImageStreamImage(in *imageapiv1.ImageStr
 	return autoconvert_v1_ImageStreamImage_To_api_ImageStreamImage(in, out, s)
 }
 
+func autoconvert_v1_ImageStreamImport_To_api_ImageStreamImport(in *imageapiv1.ImageStreamImport, out *imageapi.ImageStreamImport, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageStreamImport))(in)
+	}
+	if err := s.Convert(&in.TypeMeta, &out.TypeMeta, 0); err != nil {
+		return err
+	}
+	if err := convert_v1_ObjectMeta_To_api_ObjectMeta(&in.ObjectMeta, &out.ObjectMeta, s); err != nil {
+		return err
+	}
+	if err := convert_v1_ImageStreamImportSpec_To_api_ImageStreamImportSpec(&in.Spec, &out.Spec, s); err != nil {
+		return err
+	}
+	if err := convert_v1_ImageStreamImportStatus_To_api_ImageStreamImportStatus(&in.Status, &out.Status, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func convert_v1_ImageStreamImport_To_api_ImageStreamImport(in *imageapiv1.ImageStreamImport, out *imageapi.ImageStreamImport, s conversion.Scope) error {
+	return autoconvert_v1_ImageStreamImport_To_api_ImageStreamImport(in, out, s)
+}
+
+func autoconvert_v1_ImageStreamImportSpec_To_api_ImageStreamImportSpec(in *imageapiv1.ImageStreamImportSpec, out *imageapi.ImageStreamImportSpec, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageStreamImportSpec))(in)
+	}
+	out.Import = in.Import
+	if in.Repository != nil {
+		out.Repository = new(imageapi.RepositoryImportSpec)
+		if err := convert_v1_RepositoryImportSpec_To_api_RepositoryImportSpec(in.Repository, out.Repository, s); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapi.ImageImportSpec, len(in.Images))
+		for i := range in.Images {
+			if err := convert_v1_ImageImportSpec_To_api_ImageImportSpec(&in.Images[i], &out.Images[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func convert_v1_ImageStreamImportSpec_To_api_ImageStreamImportSpec(in *imageapiv1.ImageStreamImportSpec, out *imageapi.ImageStreamImportSpec, s conversion.Scope) error {
+	return autoconvert_v1_ImageStreamImportSpec_To_api_ImageStreamImportSpec(in, out, s)
+}
+
+func autoconvert_v1_ImageStreamImportStatus_To_api_ImageStreamImportStatus(in *imageapiv1.ImageStreamImportStatus, out *imageapi.ImageStreamImportStatus, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.ImageStreamImportStatus))(in)
+	}
+	if in.Import != nil {
+		out.Import = new(imageapi.ImageStream)
+		if err := convert_v1_ImageStream_To_api_ImageStream(in.Import, out.Import, s); err != nil {
+			return err
+		}
+	} else {
+		out.Import = nil
+	}
+	if in.Repository != nil {
+		out.Repository = new(imageapi.RepositoryImportStatus)
+		if err := convert_v1_RepositoryImportStatus_To_api_RepositoryImportStatus(in.Repository, out.Repository, s); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapi.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := convert_v1_ImageImportStatus_To_api_ImageImportStatus(&in.Images[i], &out.Images[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func convert_v1_ImageStreamImportStatus_To_api_ImageStreamImportStatus(in *imageapiv1.ImageStreamImportStatus, out *imageapi.ImageStreamImportStatus, s conversion.Scope) error {
+	return autoconvert_v1_ImageStreamImportStatus_To_api_ImageStreamImportStatus(in, out, s)
+}
+
 func autoconvert_v1_ImageStreamList_To_api_ImageStreamList(in *imageapiv1.ImageStreamList, out *imageapi.ImageStreamList, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*imageapiv1.ImageStreamList))(in)
@@ -3124,6 +3706,15 @@ func autoconvert_v1_ImageStreamSpec_To_api_ImageStreamSpec(in *imageapiv1.ImageS
 	if err := s.Convert(&in.Tags, &out.Tags, 0); err != nil {
 		return err
 	}
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if in.Mirror != nil {
+		out.Mirror = new(imageapi.RepositoryMirrorPolicy)
+		if err := convert_v1_RepositoryMirrorPolicy_To_api_RepositoryMirrorPolicy(in.Mirror, out.Mirror, s); err != nil {
+			return err
+		}
+	} else {
+		out.Mirror = nil
+	}
 	return nil
 }
 
@@ -3185,6 +3776,85 @@ func convert_v1_ImageStreamTagList_To_api_ImageStreamTagList(in *imageapiv1.Imag
 	return autoconvert_v1_ImageStreamTagList_To_api_ImageStreamTagList(in, out, s)
 }
 
+func autoconvert_v1_RepositoryImportSpec_To_api_RepositoryImportSpec(in *imageapiv1.RepositoryImportSpec, out *imageapi.RepositoryImportSpec, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.RepositoryImportSpec))(in)
+	}
+	if err := convert_v1_ObjectReference_To_api_ObjectReference(&in.From, &out.From, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func convert_v1_RepositoryImportSpec_To_api_RepositoryImportSpec(in *imageapiv1.RepositoryImportSpec, out *imageapi.RepositoryImportSpec, s conversion.Scope) error {
+	return autoconvert_v1_RepositoryImportSpec_To_api_RepositoryImportSpec(in, out, s)
+}
+
+func autoconvert_v1_RepositoryImportStatus_To_api_RepositoryImportStatus(in *imageapiv1.RepositoryImportStatus, out *imageapi.RepositoryImportStatus, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.RepositoryImportStatus))(in)
+	}
+	if err := s.Convert(&in.Status, &out.Status, 0); err != nil {
+		return err
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapi.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := convert_v1_ImageImportStatus_To_api_ImageImportStatus(&in.Images[i], &out.Images[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func convert_v1_RepositoryImportStatus_To_api_RepositoryImportStatus(in *imageapiv1.RepositoryImportStatus, out *imageapi.RepositoryImportStatus, s conversion.Scope) error {
+	return autoconvert_v1_RepositoryImportStatus_To_api_RepositoryImportStatus(in, out, s)
+}
+
+func autoconvert_v1_RepositoryMirrorPolicy_To_api_RepositoryMirrorPolicy(in *imageapiv1.RepositoryMirrorPolicy, out *imageapi.RepositoryMirrorPolicy, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.RepositoryMirrorPolicy))(in)
+	}
+	if in.IncludeTags != nil {
+		out.IncludeTags = make([]string, len(in.IncludeTags))
+		for i := range in.IncludeTags {
+			out.IncludeTags[i] = in.IncludeTags[i]
+		}
+	} else {
+		out.IncludeTags = nil
+	}
+	if in.ExcludeTags != nil {
+		out.ExcludeTags = make([]string, len(in.ExcludeTags))
+		for i := range in.ExcludeTags {
+			out.ExcludeTags[i] = in.ExcludeTags[i]
+		}
+	} else {
+		out.ExcludeTags = nil
+	}
+	return nil
+}
+
+func convert_v1_RepositoryMirrorPolicy_To_api_RepositoryMirrorPolicy(in *imageapiv1.RepositoryMirrorPolicy, out *imageapi.RepositoryMirrorPolicy, s conversion.Scope) error {
+	return autoconvert_v1_RepositoryMirrorPolicy_To_api_RepositoryMirrorPolicy(in, out, s)
+}
+
+func autoconvert_v1_TagImportPolicy_To_api_TagImportPolicy(in *imageapiv1.TagImportPolicy, out *imageapi.TagImportPolicy, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*imageapiv1.TagImportPolicy))(in)
+	}
+	out.Scheduled = in.Scheduled
+	out.Insecure = in.Insecure
+	out.ImportMode = imageapi.TagImportMode(in.ImportMode)
+	return nil
+}
+
+func convert_v1_TagImportPolicy_To_api_TagImportPolicy(in *imageapiv1.TagImportPolicy, out *imageapi.TagImportPolicy, s conversion.Scope) error {
+	return autoconvert_v1_TagImportPolicy_To_api_TagImportPolicy(in, out, s)
+}
+
 func autoconvert_api_OAuthAccessToken_To_v1_OAuthAccessToken(in *oauthapi.OAuthAccessToken, out *oauthapiv1.OAuthAccessToken, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*oauthapi.OAuthAccessToken))(in)
@@ -5286,8 +5956,16 @@ func init() {
 		autoconvert_api_IdentityList_To_v1_IdentityList,
 		autoconvert_api_Identity_To_v1_Identity,
 		autoconvert_api_ImageChangeTrigger_To_v1_ImageChangeTrigger,
+		autoconvert_api_ImageImportSpec_To_v1_ImageImportSpec,
+		autoconvert_api_ImageImportStatus_To_v1_ImageImportStatus,
+		autoconvert_api_ImageLayerList_To_v1_ImageLayerList,
+		autoconvert_api_ImageLayer_To_v1_ImageLayer,
 		autoconvert_api_ImageList_To_v1_ImageList,
+		autoconvert_api_ImageSignature_To_v1_ImageSignature,
 		autoconvert_api_ImageStreamImage_To_v1_ImageStreamImage,
+		autoconvert_api_ImageStreamImportSpec_To_v1_ImageStreamImportSpec,
+		autoconvert_api_ImageStreamImportStatus_To_v1_ImageStreamImportStatus,
+		autoconvert_api_ImageStreamImport_To_v1_ImageStreamImport,
 		autoconvert_api_ImageStreamList_To_v1_ImageStreamList,
 		autoconvert_api_ImageStreamMapping_To_v1_ImageStreamMapping,
 		autoconvert_api_ImageStreamSpec_To_v1_ImageStreamSpec,
@@ -5324,6 +6002,9 @@ func init() {
 		autoconvert_api_ProjectSpec_To_v1_ProjectSpec,
 		autoconvert_api_ProjectStatus_To_v1_ProjectStatus,
 		autoconvert_api_Project_To_v1_Project,
+		autoconvert_api_RepositoryImportSpec_To_v1_RepositoryImportSpec,
+		autoconvert_api_RepositoryImportStatus_To_v1_RepositoryImportStatus,
+		autoconvert_api_RepositoryMirrorPolicy_To_v1_RepositoryMirrorPolicy,
 		autoconvert_api_ResourceAccessReviewResponse_To_v1_ResourceAccessReviewResponse,
 		autoconvert_api_ResourceAccessReview_To_v1_ResourceAccessReview,
 		autoconvert_api_ResourceRequirements_To_v1_ResourceRequirements,
@@ -5343,6 +6024,7 @@ func init() {
 		autoconvert_api_SubjectAccessReviewResponse_To_v1_SubjectAccessReviewResponse,
 		autoconvert_api_SubjectAccessReview_To_v1_SubjectAccessReview,
 		autoconvert_api_TLSConfig_To_v1_TLSConfig,
+		autoconvert_api_TagImportPolicy_To_v1_TagImportPolicy,
 		autoconvert_api_TemplateList_To_v1_TemplateList,
 		autoconvert_api_Template_To_v1_Template,
 		autoconvert_api_UserIdentityMapping_To_v1_UserIdentityMapping,
@@ -5395,8 +6077,16 @@ func init() {
 		autoconvert_v1_IdentityList_To_api_IdentityList,
 		autoconvert_v1_Identity_To_api_Identity,
 		autoconvert_v1_ImageChangeTrigger_To_api_ImageChangeTrigger,
+		autoconvert_v1_ImageImportSpec_To_api_ImageImportSpec,
+		autoconvert_v1_ImageImportStatus_To_api_ImageImportStatus,
+		autoconvert_v1_ImageLayerList_To_api_ImageLayerList,
+		autoconvert_v1_ImageLayer_To_api_ImageLayer,
 		autoconvert_v1_ImageList_To_api_ImageList,
+		autoconvert_v1_ImageSignature_To_api_ImageSignature,
 		autoconvert_v1_ImageStreamImage_To_api_ImageStreamImage,
+		autoconvert_v1_ImageStreamImportSpec_To_api_ImageStreamImportSpec,
+		autoconvert_v1_ImageStreamImportStatus_To_api_ImageStreamImportStatus,
+		autoconvert_v1_ImageStreamImport_To_api_ImageStreamImport,
 		autoconvert_v1_ImageStreamList_To_api_ImageStreamList,
 		autoconvert_v1_ImageStreamMapping_To_api_ImageStreamMapping,
 		autoconvert_v1_ImageStreamSpec_To_api_ImageStreamSpec,
@@ -5433,6 +6123,9 @@ func init() {
 		autoconvert_v1_ProjectSpec_To_api_ProjectSpec,
 		autoconvert_v1_ProjectStatus_To_api_ProjectStatus,
 		autoconvert_v1_Project_To_api_Project,
+		autoconvert_v1_RepositoryImportSpec_To_api_RepositoryImportSpec,
+		autoconvert_v1_RepositoryImportStatus_To_api_RepositoryImportStatus,
+		autoconvert_v1_RepositoryMirrorPolicy_To_api_RepositoryMirrorPolicy,
 		autoconvert_v1_ResourceAccessReviewResponse_To_api_ResourceAccessReviewResponse,
 		autoconvert_v1_ResourceAccessReview_To_api_ResourceAccessReview,
 		autoconvert_v1_ResourceRequirements_To_api_ResourceRequirements,
@@ -5452,6 +6145,7 @@ func init() {
 		autoconvert_v1_SubjectAccessReviewResponse_To_api_SubjectAccessReviewResponse,
 		autoconvert_v1_SubjectAccessReview_To_api_SubjectAccessReview,
 		autoconvert_v1_TLSConfig_To_api_TLSConfig,
+		autoconvert_v1_TagImportPolicy_To_api_TagImportPolicy,
 		autoconvert_v1_TemplateList_To_api_TemplateList,
 		autoconvert_v1_Template_To_api_Template,
 		autoconvert_v1_UserIdentityMapping_To_api_UserIdentityMapping,
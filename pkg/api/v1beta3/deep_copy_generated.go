@@ -1787,6 +1787,119 @@ func deepCopy_v1beta3_Image(in imageapiv1beta3.Image, out *imageapiv1beta3.Image
 	}
 	out.DockerImageMetadataVersion = in.DockerImageMetadataVersion
 	out.DockerImageManifest = in.DockerImageManifest
+	if in.Signatures != nil {
+		out.Signatures = make([]imageapiv1beta3.ImageSignature, len(in.Signatures))
+		for i := range in.Signatures {
+			if err := deepCopy_v1beta3_ImageSignature(in.Signatures[i], &out.Signatures[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Signatures = nil
+	}
+	if in.Finalizers != nil {
+		out.Finalizers = make([]pkgapiv1beta3.FinalizerName, len(in.Finalizers))
+		for i := range in.Finalizers {
+			out.Finalizers[i] = in.Finalizers[i]
+		}
+	} else {
+		out.Finalizers = nil
+	}
+	return nil
+}
+
+func deepCopy_v1beta3_ImageImportSpec(in imageapiv1beta3.ImageImportSpec, out *imageapiv1beta3.ImageImportSpec, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.From); err != nil {
+		return err
+	} else {
+		out.From = newVal.(pkgapiv1beta3.ObjectReference)
+	}
+	if in.To != nil {
+		if newVal, err := c.DeepCopy(in.To); err != nil {
+			return err
+		} else {
+			out.To = newVal.(*pkgapiv1beta3.LocalObjectReference)
+		}
+	} else {
+		out.To = nil
+	}
+	if err := deepCopy_v1beta3_TagImportPolicy(in.ImportPolicy, &out.ImportPolicy, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deepCopy_v1beta3_ImageImportStatus(in imageapiv1beta3.ImageImportStatus, out *imageapiv1beta3.ImageImportStatus, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.Status); err != nil {
+		return err
+	} else {
+		out.Status = newVal.(unversioned.Status)
+	}
+	if in.Image != nil {
+		out.Image = new(imageapiv1beta3.Image)
+		if err := deepCopy_v1beta3_Image(*in.Image, out.Image, c); err != nil {
+			return err
+		}
+	} else {
+		out.Image = nil
+	}
+	out.Tag = in.Tag
+	return nil
+}
+
+func deepCopy_v1beta3_ImageLayer(in imageapiv1beta3.ImageLayer, out *imageapiv1beta3.ImageLayer, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ObjectMeta); err != nil {
+		return err
+	} else {
+		out.ObjectMeta = newVal.(pkgapiv1beta3.ObjectMeta)
+	}
+	out.Size = in.Size
+	if in.Images != nil {
+		out.Images = make([]string, len(in.Images))
+		for i := range in.Images {
+			out.Images[i] = in.Images[i]
+		}
+	} else {
+		out.Images = nil
+	}
+	if in.ImageStreams != nil {
+		out.ImageStreams = make([]string, len(in.ImageStreams))
+		for i := range in.ImageStreams {
+			out.ImageStreams[i] = in.ImageStreams[i]
+		}
+	} else {
+		out.ImageStreams = nil
+	}
+	out.Shared = in.Shared
+	return nil
+}
+
+func deepCopy_v1beta3_ImageLayerList(in imageapiv1beta3.ImageLayerList, out *imageapiv1beta3.ImageLayerList, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ListMeta); err != nil {
+		return err
+	} else {
+		out.ListMeta = newVal.(unversioned.ListMeta)
+	}
+	if in.Items != nil {
+		out.Items = make([]imageapiv1beta3.ImageLayer, len(in.Items))
+		for i := range in.Items {
+			if err := deepCopy_v1beta3_ImageLayer(in.Items[i], &out.Items[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1814,6 +1927,38 @@ func deepCopy_v1beta3_ImageList(in imageapiv1beta3.ImageList, out *imageapiv1bet
 	return nil
 }
 
+func deepCopy_v1beta3_ImageSignature(in imageapiv1beta3.ImageSignature, out *imageapiv1beta3.ImageSignature, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ObjectMeta); err != nil {
+		return err
+	} else {
+		out.ObjectMeta = newVal.(pkgapiv1beta3.ObjectMeta)
+	}
+	out.Type = in.Type
+	if in.Content != nil {
+		out.Content = make([]uint8, len(in.Content))
+		for i := range in.Content {
+			out.Content[i] = in.Content[i]
+		}
+	} else {
+		out.Content = nil
+	}
+	out.ImageIdentity = in.ImageIdentity
+	if in.SignedClaims != nil {
+		out.SignedClaims = make(map[string]string)
+		for key, val := range in.SignedClaims {
+			out.SignedClaims[key] = val
+		}
+	} else {
+		out.SignedClaims = nil
+	}
+	return nil
+}
+
 func deepCopy_v1beta3_ImageStream(in imageapiv1beta3.ImageStream, out *imageapiv1beta3.ImageStream, c *conversion.Cloner) error {
 	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
 		return err
@@ -1842,6 +1987,79 @@ func deepCopy_v1beta3_ImageStreamImage(in imageapiv1beta3.ImageStreamImage, out
 	return nil
 }
 
+func deepCopy_v1beta3_ImageStreamImport(in imageapiv1beta3.ImageStreamImport, out *imageapiv1beta3.ImageStreamImport, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
+		return err
+	} else {
+		out.TypeMeta = newVal.(unversioned.TypeMeta)
+	}
+	if newVal, err := c.DeepCopy(in.ObjectMeta); err != nil {
+		return err
+	} else {
+		out.ObjectMeta = newVal.(pkgapiv1beta3.ObjectMeta)
+	}
+	if err := deepCopy_v1beta3_ImageStreamImportSpec(in.Spec, &out.Spec, c); err != nil {
+		return err
+	}
+	if err := deepCopy_v1beta3_ImageStreamImportStatus(in.Status, &out.Status, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deepCopy_v1beta3_ImageStreamImportSpec(in imageapiv1beta3.ImageStreamImportSpec, out *imageapiv1beta3.ImageStreamImportSpec, c *conversion.Cloner) error {
+	out.Import = in.Import
+	if in.Repository != nil {
+		out.Repository = new(imageapiv1beta3.RepositoryImportSpec)
+		if err := deepCopy_v1beta3_RepositoryImportSpec(*in.Repository, out.Repository, c); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapiv1beta3.ImageImportSpec, len(in.Images))
+		for i := range in.Images {
+			if err := deepCopy_v1beta3_ImageImportSpec(in.Images[i], &out.Images[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func deepCopy_v1beta3_ImageStreamImportStatus(in imageapiv1beta3.ImageStreamImportStatus, out *imageapiv1beta3.ImageStreamImportStatus, c *conversion.Cloner) error {
+	if in.Import != nil {
+		out.Import = new(imageapiv1beta3.ImageStream)
+		if err := deepCopy_v1beta3_ImageStream(*in.Import, out.Import, c); err != nil {
+			return err
+		}
+	} else {
+		out.Import = nil
+	}
+	if in.Repository != nil {
+		out.Repository = new(imageapiv1beta3.RepositoryImportStatus)
+		if err := deepCopy_v1beta3_RepositoryImportStatus(*in.Repository, out.Repository, c); err != nil {
+			return err
+		}
+	} else {
+		out.Repository = nil
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapiv1beta3.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := deepCopy_v1beta3_ImageImportStatus(in.Images[i], &out.Images[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
 func deepCopy_v1beta3_ImageStreamList(in imageapiv1beta3.ImageStreamList, out *imageapiv1beta3.ImageStreamList, c *conversion.Cloner) error {
 	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
 		return err
@@ -1896,6 +2114,15 @@ func deepCopy_v1beta3_ImageStreamSpec(in imageapiv1beta3.ImageStreamSpec, out *i
 	} else {
 		out.Tags = nil
 	}
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if in.Mirror != nil {
+		out.Mirror = new(imageapiv1beta3.RepositoryMirrorPolicy)
+		if err := deepCopy_v1beta3_RepositoryMirrorPolicy(*in.Mirror, out.Mirror, c); err != nil {
+			return err
+		}
+	} else {
+		out.Mirror = nil
+	}
 	return nil
 }
 
@@ -1958,6 +2185,16 @@ func deepCopy_v1beta3_NamedTagEventList(in imageapiv1beta3.NamedTagEventList, ou
 	} else {
 		out.Items = nil
 	}
+	if in.Conditions != nil {
+		out.Conditions = make([]imageapiv1beta3.TagEventCondition, len(in.Conditions))
+		for i := range in.Conditions {
+			if err := deepCopy_v1beta3_TagEventCondition(in.Conditions[i], &out.Conditions[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Conditions = nil
+	}
 	return nil
 }
 
@@ -1981,6 +2218,62 @@ func deepCopy_v1beta3_NamedTagReference(in imageapiv1beta3.NamedTagReference, ou
 		out.From = nil
 	}
 	out.Reference = in.Reference
+	if err := deepCopy_v1beta3_TagImportPolicy(in.ImportPolicy, &out.ImportPolicy, c); err != nil {
+		return err
+	}
+	if err := deepCopy_v1beta3_TagReferencePolicy(in.ReferencePolicy, &out.ReferencePolicy, c); err != nil {
+		return err
+	}
+	out.Immutable = in.Immutable
+	out.Track = in.Track
+	return nil
+}
+
+func deepCopy_v1beta3_RepositoryImportSpec(in imageapiv1beta3.RepositoryImportSpec, out *imageapiv1beta3.RepositoryImportSpec, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.From); err != nil {
+		return err
+	} else {
+		out.From = newVal.(pkgapiv1beta3.ObjectReference)
+	}
+	return nil
+}
+
+func deepCopy_v1beta3_RepositoryImportStatus(in imageapiv1beta3.RepositoryImportStatus, out *imageapiv1beta3.RepositoryImportStatus, c *conversion.Cloner) error {
+	if newVal, err := c.DeepCopy(in.Status); err != nil {
+		return err
+	} else {
+		out.Status = newVal.(unversioned.Status)
+	}
+	if in.Images != nil {
+		out.Images = make([]imageapiv1beta3.ImageImportStatus, len(in.Images))
+		for i := range in.Images {
+			if err := deepCopy_v1beta3_ImageImportStatus(in.Images[i], &out.Images[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Images = nil
+	}
+	return nil
+}
+
+func deepCopy_v1beta3_RepositoryMirrorPolicy(in imageapiv1beta3.RepositoryMirrorPolicy, out *imageapiv1beta3.RepositoryMirrorPolicy, c *conversion.Cloner) error {
+	if in.IncludeTags != nil {
+		out.IncludeTags = make([]string, len(in.IncludeTags))
+		for i := range in.IncludeTags {
+			out.IncludeTags[i] = in.IncludeTags[i]
+		}
+	} else {
+		out.IncludeTags = nil
+	}
+	if in.ExcludeTags != nil {
+		out.ExcludeTags = make([]string, len(in.ExcludeTags))
+		for i := range in.ExcludeTags {
+			out.ExcludeTags[i] = in.ExcludeTags[i]
+		}
+	} else {
+		out.ExcludeTags = nil
+	}
 	return nil
 }
 
@@ -1995,6 +2288,38 @@ func deepCopy_v1beta3_TagEvent(in imageapiv1beta3.TagEvent, out *imageapiv1beta3
 	return nil
 }
 
+func deepCopy_v1beta3_TagEventCondition(in imageapiv1beta3.TagEventCondition, out *imageapiv1beta3.TagEventCondition, c *conversion.Cloner) error {
+	out.Type = in.Type
+	out.Status = in.Status
+	if newVal, err := c.DeepCopy(in.LastTransitionTime); err != nil {
+		return err
+	} else {
+		out.LastTransitionTime = newVal.(unversioned.Time)
+	}
+	out.Reason = in.Reason
+	out.Message = in.Message
+	out.Failures = in.Failures
+	if newVal, err := c.DeepCopy(in.LastAttemptTime); err != nil {
+		return err
+	} else {
+		out.LastAttemptTime = newVal.(unversioned.Time)
+	}
+	out.Generation = in.Generation
+	return nil
+}
+
+func deepCopy_v1beta3_TagImportPolicy(in imageapiv1beta3.TagImportPolicy, out *imageapiv1beta3.TagImportPolicy, c *conversion.Cloner) error {
+	out.Scheduled = in.Scheduled
+	out.Insecure = in.Insecure
+	out.ImportMode = in.ImportMode
+	return nil
+}
+
+func deepCopy_v1beta3_TagReferencePolicy(in imageapiv1beta3.TagReferencePolicy, out *imageapiv1beta3.TagReferencePolicy, c *conversion.Cloner) error {
+	out.Type = in.Type
+	return nil
+}
+
 func deepCopy_v1beta3_OAuthAccessToken(in oauthapiv1beta3.OAuthAccessToken, out *oauthapiv1beta3.OAuthAccessToken, c *conversion.Cloner) error {
 	if newVal, err := c.DeepCopy(in.TypeMeta); err != nil {
 		return err
@@ -2825,9 +3150,17 @@ func init() {
 		deepCopy_v1beta3_RecreateDeploymentStrategyParams,
 		deepCopy_v1beta3_RollingDeploymentStrategyParams,
 		deepCopy_v1beta3_Image,
+		deepCopy_v1beta3_ImageImportSpec,
+		deepCopy_v1beta3_ImageImportStatus,
+		deepCopy_v1beta3_ImageLayer,
+		deepCopy_v1beta3_ImageLayerList,
 		deepCopy_v1beta3_ImageList,
+		deepCopy_v1beta3_ImageSignature,
 		deepCopy_v1beta3_ImageStream,
 		deepCopy_v1beta3_ImageStreamImage,
+		deepCopy_v1beta3_ImageStreamImport,
+		deepCopy_v1beta3_ImageStreamImportSpec,
+		deepCopy_v1beta3_ImageStreamImportStatus,
 		deepCopy_v1beta3_ImageStreamList,
 		deepCopy_v1beta3_ImageStreamMapping,
 		deepCopy_v1beta3_ImageStreamSpec,
@@ -2836,7 +3169,13 @@ func init() {
 		deepCopy_v1beta3_ImageStreamTagList,
 		deepCopy_v1beta3_NamedTagEventList,
 		deepCopy_v1beta3_NamedTagReference,
+		deepCopy_v1beta3_RepositoryImportSpec,
+		deepCopy_v1beta3_RepositoryImportStatus,
+		deepCopy_v1beta3_RepositoryMirrorPolicy,
 		deepCopy_v1beta3_TagEvent,
+		deepCopy_v1beta3_TagEventCondition,
+		deepCopy_v1beta3_TagImportPolicy,
+		deepCopy_v1beta3_TagReferencePolicy,
 		deepCopy_v1beta3_OAuthAccessToken,
 		deepCopy_v1beta3_OAuthAccessTokenList,
 		deepCopy_v1beta3_OAuthAuthorizeToken,
@@ -13,6 +13,7 @@ import (
 
 	"github.com/openshift/origin/pkg/api"
 	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
 func TestNameFunc(t *testing.T) {
@@ -151,6 +152,8 @@ func getValidName(apiType reflect.Type) string {
 		return ":default"
 	case *authorizationapi.ClusterPolicy, *authorizationapi.Policy:
 		return "default"
+	case *imageapi.ImageSignature:
+		return "image@signature"
 	default:
 		return "any-string"
 	}
@@ -9,4 +9,6 @@ const (
 	NodeMetricsResource = "nodes/metrics"
 	NodeStatsResource   = "nodes/stats"
 	NodeLogResource     = "nodes/log"
+
+	RegistryMetricsResource = "registry/metrics"
 )
@@ -394,7 +394,8 @@ type ImageChangeTrigger struct {
 	// From is a reference to an ImageStreamTag that will trigger a build when updated
 	// It is optional. If no From is specified, the From image from the build strategy
 	// will be used. Only one ImageChangeTrigger with an empty From reference is allowed in
-	// a build configuration.
+	// a build configuration. An ImageStreamTag pinned to an image of its history with
+	// <name>:<tag>@<digest> triggers a single build of that image.
 	From *kapi.ObjectReference `json:"from,omitempty" description:"reference to an ImageStreamTag that will trigger the build"`
 }
 
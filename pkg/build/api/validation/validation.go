@@ -241,8 +241,10 @@ func validateToImageReference(reference *kapi.ObjectReference) fielderrors.Valid
 	case "ImageStreamTag":
 		if len(name) == 0 {
 			allErrs = append(allErrs, fielderrors.NewFieldRequired("name"))
-		} else if _, _, ok := imageapi.SplitImageStreamTag(name); !ok {
+		} else if _, tag, ok := imageapi.SplitImageStreamTag(name); !ok {
 			allErrs = append(allErrs, fielderrors.NewFieldInvalid("name", name, "ImageStreamTag object references must be in the form <name>:<tag>"))
+		} else if strings.Contains(tag, "@") {
+			allErrs = append(allErrs, fielderrors.NewFieldInvalid("name", name, "the output of a build can't be pinned to an image"))
 		}
 		if len(namespace) != 0 && !kvalidation.IsDNS1123Subdomain(namespace) {
 			allErrs = append(allErrs, fielderrors.NewFieldInvalid("namespace", namespace, "namespace must be a valid subdomain"))
@@ -271,8 +273,10 @@ func validateFromImageReference(reference *kapi.ObjectReference) fielderrors.Val
 	case "ImageStreamTag":
 		if len(name) == 0 {
 			allErrs = append(allErrs, fielderrors.NewFieldRequired("name"))
-		} else if _, _, ok := imageapi.SplitImageStreamTag(name); !ok {
+		} else if _, tag, ok := imageapi.SplitImageStreamTag(name); !ok {
 			allErrs = append(allErrs, fielderrors.NewFieldInvalid("name", name, "ImageStreamTag object references must be in the form <name>:<tag>"))
+		} else if _, _, ok := imageapi.SplitTagDigest(tag); !ok {
+			allErrs = append(allErrs, fielderrors.NewFieldInvalid("name", name, "ImageStreamTag object references pinned to an image must be in the form <name>:<tag>@<digest>"))
 		}
 
 		if len(namespace) != 0 && !kvalidation.IsDNS1123Subdomain(namespace) {
@@ -428,6 +428,37 @@ func TestBuildConfigImageChangeTriggers(t *testing.T) {
 			expectError: true,
 			errorType:   fielderrors.ValidationErrorTypeInvalid,
 		},
+		{
+			name: "imagestreamtag reference pinned to an image",
+			triggers: []buildapi.BuildTriggerPolicy{
+				{
+					Type: buildapi.ImageChangeBuildTriggerType,
+					ImageChange: &buildapi.ImageChangeTrigger{
+						From: &kapi.ObjectReference{
+							Kind: "ImageStreamTag",
+							Name: "myimage:tag@sha256:0123",
+						},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "imagestreamtag reference pinned to an invalid digest",
+			triggers: []buildapi.BuildTriggerPolicy{
+				{
+					Type: buildapi.ImageChangeBuildTriggerType,
+					ImageChange: &buildapi.ImageChangeTrigger{
+						From: &kapi.ObjectReference{
+							Kind: "ImageStreamTag",
+							Name: "myimage:tag@0123",
+						},
+					},
+				},
+			},
+			expectError: true,
+			errorType:   fielderrors.ValidationErrorTypeInvalid,
+		},
 		{
 			name: "duplicate imagestreamtag references",
 			triggers: []buildapi.BuildTriggerPolicy{
@@ -1463,3 +1494,21 @@ func TestValidateToImageReference(t *testing.T) {
 		t.Errorf("Error on wrong field, expected %s, got %s", "namespace", err.Field)
 	}
 }
+
+func TestValidateToImageReferencePinned(t *testing.T) {
+	o := &kapi.ObjectReference{
+		Name: "somename:tag@sha256:0123",
+		Kind: "ImageStreamTag",
+	}
+	errs := validateToImageReference(o)
+	if len(errs) != 1 {
+		t.Fatalf("Wrong number of errors: %v", errs)
+	}
+	err := errs[0].(*fielderrors.ValidationError)
+	if err.Type != fielderrors.ValidationErrorTypeInvalid {
+		t.Errorf("Wrong error type, expected %v, got %v", fielderrors.ValidationErrorTypeInvalid, err.Type)
+	}
+	if err.Field != "name" {
+		t.Errorf("Error on wrong field, expected %s, got %s", "name", err.Field)
+	}
+}
@@ -97,8 +97,9 @@ func (c *ImageChangeController) HandleImageRepo(repo *imageapi.ImageStream) erro
 			}
 
 			// This split is safe because ImageStreamTag names always have the form
-			// name:tag.
-			latest := imageapi.LatestTaggedImage(repo, tag)
+			// name:tag. A tag pinned to an image with name:tag@digest resolves to
+			// that image, which triggers a single build.
+			latest := imageapi.TaggedImage(repo, tag)
 			if latest == nil {
 				glog.V(4).Infof("unable to find tagged image: no image recorded for %s/%s:%s", repo.Namespace, repo.Name, tag)
 				continue
@@ -107,7 +108,7 @@ func (c *ImageChangeController) HandleImageRepo(repo *imageapi.ImageStream) erro
 
 			// (must be different) to trigger a build
 			last := trigger.ImageChange.LastTriggeredImageID
-			next := latest.DockerImageReference
+			next := imageapi.ResolveReferenceForTagEvent(repo, tag, latest)
 
 			if len(last) == 0 || (len(next) > 0 && next != last) {
 				triggeredImage = next
@@ -227,6 +227,44 @@ func TestNoImageIDChange(t *testing.T) {
 	}
 }
 
+func TestPinnedImageID(t *testing.T) {
+	// the trigger is pinned to an older image of the tag, a single build of that
+	// image should be triggered.
+	buildcfg := mockBuildConfig("registry.com/namespace/imagename", "registry.com/namespace/imagename", "testImageStream", "testTag@sha256:bbb")
+	imageStream := mockImageStream("testImageStream", "registry.com/namespace/imagename", map[string]string{"testTag": "sha256:bbb"})
+	history := imageStream.Status.Tags["testTag"]
+	history.Items = append([]imageapi.TagEvent{{Image: "sha256:aaa", DockerImageReference: "registry.com/namespace/imagename:sha256:aaa"}}, history.Items...)
+	imageStream.Status.Tags["testTag"] = history
+	image := mockImage("testImage@id", "registry.com/namespace/imagename:sha256:bbb")
+	controller := mockImageChangeController(buildcfg, imageStream, image)
+	bcInstantiator := controller.BuildConfigInstantiator.(*buildConfigInstantiator)
+	bcUpdater := bcInstantiator.buildConfigUpdater
+
+	if err := controller.HandleImageRepo(imageStream); err != nil {
+		t.Fatalf("Unexpected error %v from HandleImageRepo", err)
+	}
+	if len(bcInstantiator.name) == 0 {
+		t.Fatal("Expected build generation for the pinned image!")
+	}
+	if bcUpdater.buildcfg == nil {
+		t.Fatal("Expected buildConfig update for the pinned image!")
+	}
+	if actual, expected := bcUpdater.buildcfg.Spec.Triggers[0].ImageChange.LastTriggeredImageID, "registry.com/namespace/imagename:sha256:bbb"; actual != expected {
+		t.Errorf("Expected last triggered image %q, got %q", expected, actual)
+	}
+
+	// a newer image of the tag doesn't trigger another build
+	buildcfg.Spec.Triggers[0].ImageChange.LastTriggeredImageID = "registry.com/namespace/imagename:sha256:bbb"
+	controller = mockImageChangeController(buildcfg, imageStream, image)
+	bcInstantiator = controller.BuildConfigInstantiator.(*buildConfigInstantiator)
+	if err := controller.HandleImageRepo(imageStream); err != nil {
+		t.Fatalf("Unexpected error %v from HandleImageRepo", err)
+	}
+	if len(bcInstantiator.name) != 0 {
+		t.Error("New build generated when the tag is pinned to the built image!")
+	}
+}
+
 func TestBuildConfigInstantiatorError(t *testing.T) {
 	// valid configuration, but build creation fails, in that situation the buildconfig should not be updated
 	buildcfg := mockBuildConfig("registry.com/namespace/imagename", "registry.com/namespace/imagename", "testImageStream", "testTag")
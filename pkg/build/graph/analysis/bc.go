@@ -6,8 +6,11 @@ import (
 
 	"github.com/gonum/graph"
 	"github.com/gonum/graph/topo"
+	kapi "k8s.io/kubernetes/pkg/api"
 
 	osgraph "github.com/openshift/origin/pkg/api/graph"
+	kubegraph "github.com/openshift/origin/pkg/api/kubegraph/nodes"
+	"github.com/openshift/origin/pkg/build/builder/cmd/scmauth"
 	buildedges "github.com/openshift/origin/pkg/build/graph"
 	buildgraph "github.com/openshift/origin/pkg/build/graph/nodes"
 	imageedges "github.com/openshift/origin/pkg/image/graph"
@@ -18,8 +21,19 @@ const (
 	MissingRequiredRegistryErr = "MissingRequiredRegistry"
 	MissingImageStreamErr      = "MissingImageStream"
 	CyclicBuildConfigWarning   = "CyclicBuildConfig"
+	MissingSecretKeyErr        = "MissingSecretKey"
 )
 
+// sourceSecretKeys are the secret keys the builder knows how to use to access the source repository.
+var sourceSecretKeys = []string{
+	scmauth.SSHPrivateKeyMethodName,
+	scmauth.UsernameSecret,
+	scmauth.PasswordSecret,
+	scmauth.TokenSecret,
+	scmauth.GitConfigName,
+	scmauth.CACertName,
+}
+
 // FindUnpushableBuildConfigs checks all build configs that will output to an IST backed by an ImageStream and checks to make sure their builds can push.
 func FindUnpushableBuildConfigs(g osgraph.Graph) []osgraph.Marker {
 	markers := []osgraph.Marker{}
@@ -98,3 +112,54 @@ func FindCircularBuilds(g osgraph.Graph) []osgraph.Marker {
 
 	return markers
 }
+
+// FindBuildConfigSecretsMissingKeys checks all secrets referenced by build configs and flags the ones that exist but
+// lack the keys required for their use: source secrets need a key the builder handles (an SSH private key, basic
+// authentication credentials, a .gitconfig or a CA certificate), push and pull secrets need a Docker configuration.
+func FindBuildConfigSecretsMissingKeys(g osgraph.Graph) []osgraph.Marker {
+	markers := []osgraph.Marker{}
+
+	checks := []struct {
+		edgeKind string
+		usage    string
+		keys     []string
+	}{
+		{buildedges.BuildSourceSecretEdgeKind, "source", sourceSecretKeys},
+		{buildedges.BuildPullSecretEdgeKind, "pull", []string{kapi.DockerConfigKey, kapi.DockerConfigJsonKey}},
+		{buildedges.BuildPushSecretEdgeKind, "push", []string{kapi.DockerConfigKey, kapi.DockerConfigJsonKey}},
+	}
+
+	for _, bcNode := range g.NodesByKind(buildgraph.BuildConfigNodeKind) {
+		for _, check := range checks {
+			for _, uncastSecretNode := range g.SuccessorNodesByNodeAndEdgeKind(bcNode, kubegraph.SecretNodeKind, check.edgeKind) {
+				secretNode := uncastSecretNode.(*kubegraph.SecretNode)
+				// missing secrets are reported elsewhere
+				if !secretNode.Found() || hasAnyKey(secretNode.Secret, check.keys) {
+					continue
+				}
+
+				markers = append(markers, osgraph.Marker{
+					Node:         bcNode,
+					RelatedNodes: []graph.Node{secretNode},
+
+					Severity: osgraph.ErrorSeverity,
+					Key:      MissingSecretKeyErr,
+					Message: fmt.Sprintf("%s uses %s as a %s secret, but it does not contain any of the required keys: %s.",
+						bcNode.(*buildgraph.BuildConfigNode).ResourceString(), secretNode.ResourceString(), check.usage, strings.Join(check.keys, ", ")),
+				})
+			}
+		}
+	}
+
+	return markers
+}
+
+// hasAnyKey returns true if the secret contains data for at least one of the keys.
+func hasAnyKey(secret *kapi.Secret, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := secret.Data[key]; ok {
+			return true
+		}
+	}
+	return false
+}
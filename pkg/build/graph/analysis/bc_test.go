@@ -104,3 +104,27 @@ func TestCircularDeps(t *testing.T) {
 	}
 
 }
+
+func TestBuildConfigSecretsMissingKeys(t *testing.T) {
+	g, _, err := osgraphtest.BuildGraph("../../../api/graph/test/bc-secret-missing-keys.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buildedges.AddAllSecretEdges(g)
+
+	markers := FindBuildConfigSecretsMissingKeys(g)
+	if e, a := 1, len(markers); e != a {
+		t.Fatalf("expected %v, got %v: %#v", e, a, markers)
+	}
+
+	if got, expected := markers[0].Key, MissingSecretKeyErr; got != expected {
+		t.Fatalf("expected marker key %q, got %q", expected, got)
+	}
+
+	actualSecret := markers[0].RelatedNodes[0]
+	expectedSecret := g.Find(osgraph.UniqueName("Secret|/ssh-secret"))
+	if e, a := expectedSecret.ID(), actualSecret.ID(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
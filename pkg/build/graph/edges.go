@@ -5,6 +5,7 @@ import (
 	kapi "k8s.io/kubernetes/pkg/api"
 
 	osgraph "github.com/openshift/origin/pkg/api/graph"
+	kubegraph "github.com/openshift/origin/pkg/api/kubegraph/nodes"
 	buildapi "github.com/openshift/origin/pkg/build/api"
 	buildgraph "github.com/openshift/origin/pkg/build/graph/nodes"
 	buildutil "github.com/openshift/origin/pkg/build/util"
@@ -34,6 +35,16 @@ const (
 
 	// BuildEdgeKind goes from a BuildConfigNode to a BuildNode and indicates that the buildConfig owns the build
 	BuildEdgeKind = "Build"
+
+	// BuildSourceSecretEdgeKind goes from a BuildConfigNode to a SecretNode and indicates that the secret is used
+	// to authenticate against the source repository.
+	BuildSourceSecretEdgeKind = "BuildSourceSecret"
+	// BuildPullSecretEdgeKind goes from a BuildConfigNode to a SecretNode and indicates that the secret is used
+	// to pull the builder image.
+	BuildPullSecretEdgeKind = "BuildPullSecret"
+	// BuildPushSecretEdgeKind goes from a BuildConfigNode to a SecretNode and indicates that the secret is used
+	// to push the output image.
+	BuildPushSecretEdgeKind = "BuildPushSecret"
 )
 
 // AddBuildEdges adds edges that connect a BuildConfig to Builds to the given graph
@@ -128,3 +139,42 @@ func AddAllInputOutputEdges(g osgraph.MutableUniqueGraph) {
 		}
 	}
 }
+
+// AddSecretEdges links the build config to the secrets it uses for fetching source, pulling the builder image and
+// pushing the output image.
+func AddSecretEdges(g osgraph.MutableUniqueGraph, node *buildgraph.BuildConfigNode) {
+	bc := node.BuildConfig
+	addSecretEdge(g, node, bc.Spec.Source.SourceSecret, BuildSourceSecretEdgeKind)
+	addSecretEdge(g, node, bc.Spec.Output.PushSecret, BuildPushSecretEdgeKind)
+
+	strategy := bc.Spec.Strategy
+	switch {
+	case strategy.SourceStrategy != nil:
+		addSecretEdge(g, node, strategy.SourceStrategy.PullSecret, BuildPullSecretEdgeKind)
+	case strategy.DockerStrategy != nil:
+		addSecretEdge(g, node, strategy.DockerStrategy.PullSecret, BuildPullSecretEdgeKind)
+	case strategy.CustomStrategy != nil:
+		addSecretEdge(g, node, strategy.CustomStrategy.PullSecret, BuildPullSecretEdgeKind)
+	}
+}
+
+func addSecretEdge(g osgraph.MutableUniqueGraph, node *buildgraph.BuildConfigNode, ref *kapi.LocalObjectReference, edgeKind string) {
+	if ref == nil || len(ref.Name) == 0 {
+		return
+	}
+	syntheticSecret := &kapi.Secret{}
+	syntheticSecret.Namespace = node.BuildConfig.Namespace
+	syntheticSecret.Name = ref.Name
+
+	secretNode := kubegraph.FindOrCreateSyntheticSecretNode(g, syntheticSecret)
+	g.AddEdge(node, secretNode, edgeKind)
+}
+
+// AddAllSecretEdges adds secret edges for all BuildConfigs in the given graph
+func AddAllSecretEdges(g osgraph.MutableUniqueGraph) {
+	for _, node := range g.(graph.Graph).Nodes() {
+		if bcNode, ok := node.(*buildgraph.BuildConfigNode); ok {
+			AddSecretEdges(g, bcNode)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+// ImageLayersNamespacer has methods to work with ImageLayer resources in a namespace
+type ImageLayersNamespacer interface {
+	ImageLayers(namespace string) ImageLayerInterface
+}
+
+// ImageLayerInterface exposes methods on ImageLayer resources.
+type ImageLayerInterface interface {
+	List(label labels.Selector, field fields.Selector) (*api.ImageLayerList, error)
+	Get(name string) (*api.ImageLayer, error)
+}
+
+// imageLayers implements ImageLayersNamespacer interface
+type imageLayers struct {
+	r  *Client
+	ns string
+}
+
+// newImageLayers returns an imageLayers
+func newImageLayers(c *Client, namespace string) *imageLayers {
+	return &imageLayers{
+		r:  c,
+		ns: namespace,
+	}
+}
+
+// List returns the layers of the images referenced by the image streams of the namespace. Selecting
+// the field "imageStream" returns the layers of a single image stream.
+func (c *imageLayers) List(label labels.Selector, field fields.Selector) (result *api.ImageLayerList, err error) {
+	result = &api.ImageLayerList{}
+	err = c.r.Get().
+		Namespace(c.ns).
+		Resource("imageLayers").
+		LabelsSelectorParam(label).
+		FieldsSelectorParam(field).
+		Do().
+		Into(result)
+	return
+}
+
+// Get returns the layer with the given digest of the images referenced by the image streams of the namespace.
+func (c *imageLayers) Get(name string) (result *api.ImageLayer, err error) {
+	result = &api.ImageLayer{}
+	err = c.r.Get().Namespace(c.ns).Resource("imageLayers").Name(name).Do().Into(result)
+	return
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
 
 	imageapi "github.com/openshift/origin/pkg/image/api"
 )
@@ -17,7 +18,10 @@ type ImageInterface interface {
 	List(label labels.Selector, field fields.Selector) (*imageapi.ImageList, error)
 	Get(name string) (*imageapi.Image, error)
 	Create(image *imageapi.Image) (*imageapi.Image, error)
+	Update(image *imageapi.Image) (*imageapi.Image, error)
 	Delete(name string) error
+	Watch(label labels.Selector, field fields.Selector, resourceVersion string) (watch.Interface, error)
+	Finalize(image *imageapi.Image) (*imageapi.Image, error)
 }
 
 // images implements ImagesInterface.
@@ -58,8 +62,33 @@ func (c *images) Create(image *imageapi.Image) (result *imageapi.Image, err erro
 	return
 }
 
+// Update updates the image on the server. Returns the server's representation of the image and error if one occurs.
+func (c *images) Update(image *imageapi.Image) (result *imageapi.Image, err error) {
+	result = &imageapi.Image{}
+	err = c.r.Put().Resource("images").Name(image.Name).Body(image).Do().Into(result)
+	return
+}
+
 // Delete deletes an image, returns error if one occurs.
 func (c *images) Delete(name string) (err error) {
 	err = c.r.Delete().Resource("images").Name(name).Do().Error()
 	return
 }
+
+// Watch returns a watch.Interface that watches the requested images.
+func (c *images) Watch(label labels.Selector, field fields.Selector, resourceVersion string) (watch.Interface, error) {
+	return c.r.Get().
+		Prefix("watch").
+		Resource("images").
+		Param("resourceVersion", resourceVersion).
+		LabelsSelectorParam(label).
+		FieldsSelectorParam(field).
+		Watch()
+}
+
+// Finalize updates the finalizers of an image. Returns the server's representation of the image, and an error, if it occurs.
+func (c *images) Finalize(image *imageapi.Image) (result *imageapi.Image, err error) {
+	result = &imageapi.Image{}
+	err = c.r.Put().Resource("images").Name(image.Name).SubResource("finalize").Body(image).Do().Into(result)
+	return
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// ImageSignaturesInterfacer has methods to work with ImageSignature resources
+type ImageSignaturesInterfacer interface {
+	ImageSignatures() ImageSignatureInterface
+}
+
+// ImageSignatureInterface exposes methods on ImageSignature resources.
+type ImageSignatureInterface interface {
+	Create(signature *imageapi.ImageSignature) (*imageapi.ImageSignature, error)
+	Delete(name string) error
+}
+
+// imageSignatures implements ImageSignatureInterface.
+type imageSignatures struct {
+	r *Client
+}
+
+// newImageSignatures returns an imageSignatures
+func newImageSignatures(c *Client) ImageSignatureInterface {
+	return &imageSignatures{
+		r: c,
+	}
+}
+
+// Create adds a signature to the image it signs. Returns the server's representation of the signature and error if one occurs.
+func (c *imageSignatures) Create(signature *imageapi.ImageSignature) (result *imageapi.ImageSignature, err error) {
+	result = &imageapi.ImageSignature{}
+	err = c.r.Post().Resource("imageSignatures").Body(signature).Do().Into(result)
+	return
+}
+
+// Delete removes a signature from the image it signs, returns error if one occurs.
+func (c *imageSignatures) Delete(name string) (err error) {
+	err = c.r.Delete().Resource("imageSignatures").Name(name).Do().Error()
+	return
+}
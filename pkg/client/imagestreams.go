@@ -1,6 +1,7 @@
 package client
 
 import (
+	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/watch"
@@ -22,6 +23,9 @@ type ImageStreamInterface interface {
 	Delete(name string) error
 	Watch(label labels.Selector, field fields.Selector, resourceVersion string) (watch.Interface, error)
 	UpdateStatus(stream *imageapi.ImageStream) (*imageapi.ImageStream, error)
+	Import(isi *imageapi.ImageStreamImport) (*imageapi.ImageStreamImport, error)
+	Restore(stream *imageapi.ImageStream) (*imageapi.ImageStream, error)
+	Secrets(name string) (*kapi.SecretList, error)
 }
 
 // ImageStreamNamespaceGetter exposes methods to get ImageStreams by Namespace
@@ -108,3 +112,24 @@ func (c *imageStreams) UpdateStatus(stream *imageapi.ImageStream) (result *image
 	err = c.r.Put().Namespace(c.ns).Resource("imageStreams").Name(stream.Name).SubResource("status").Body(stream).Do().Into(result)
 	return
 }
+
+// Import imports the images described by isi. Returns the outcome of the import of every image, and an error if the import couldn't be attempted.
+func (c *imageStreams) Import(isi *imageapi.ImageStreamImport) (result *imageapi.ImageStreamImport, err error) {
+	result = &imageapi.ImageStreamImport{}
+	err = c.r.Post().Namespace(c.ns).Resource("imageStreamImports").Body(isi).Do().Into(result)
+	return
+}
+
+// Restore undoes the deletion of an image stream during its grace period. Returns the server's representation of the image stream, and an error, if it occurs.
+func (c *imageStreams) Restore(stream *imageapi.ImageStream) (result *imageapi.ImageStream, err error) {
+	result = &imageapi.ImageStream{}
+	err = c.r.Put().Namespace(c.ns).Resource("imageStreams").Name(stream.Name).SubResource("restore").Body(stream).Do().Into(result)
+	return
+}
+
+// Secrets returns the docker registry secrets used to pull the images of an image stream from their registries, and an error if one occurs.
+func (c *imageStreams) Secrets(name string) (result *kapi.SecretList, err error) {
+	result = &kapi.SecretList{}
+	err = c.r.Get().Namespace(c.ns).Resource("imageStreams").Name(name).SubResource("secrets").Do().Into(result)
+	return
+}
@@ -140,6 +140,11 @@ func (c *Fake) Images() client.ImageInterface {
 	return &FakeImages{Fake: c}
 }
 
+// ImageSignatures provides a fake REST client for ImageSignatures
+func (c *Fake) ImageSignatures() client.ImageSignatureInterface {
+	return &FakeImageSignatures{Fake: c}
+}
+
 // ImageStreams provides a fake REST client for ImageStreams
 func (c *Fake) ImageStreams(namespace string) client.ImageStreamInterface {
 	return &FakeImageStreams{Fake: c, Namespace: namespace}
@@ -160,6 +165,11 @@ func (c *Fake) ImageStreamImages(namespace string) client.ImageStreamImageInterf
 	return &FakeImageStreamImages{Fake: c, Namespace: namespace}
 }
 
+// ImageLayers provides a fake REST client for ImageLayers
+func (c *Fake) ImageLayers(namespace string) client.ImageLayerInterface {
+	return &FakeImageLayers{Fake: c, Namespace: namespace}
+}
+
 // DeploymentConfigs provides a fake REST client for DeploymentConfigs
 func (c *Fake) DeploymentConfigs(namespace string) client.DeploymentConfigInterface {
 	return &FakeDeploymentConfigs{Fake: c, Namespace: namespace}
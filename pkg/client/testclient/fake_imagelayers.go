@@ -0,0 +1,38 @@
+package testclient
+
+import (
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// FakeImageLayers implements ImageLayerInterface. Meant to be embedded into a
+// struct to get a default implementation. This makes faking out just the
+// methods you want to test easier.
+type FakeImageLayers struct {
+	Fake      *Fake
+	Namespace string
+}
+
+var _ client.ImageLayerInterface = &FakeImageLayers{}
+
+func (c *FakeImageLayers) List(label labels.Selector, field fields.Selector) (*imageapi.ImageLayerList, error) {
+	obj, err := c.Fake.Invokes(ktestclient.NewListAction("imagelayers", c.Namespace, label, field), &imageapi.ImageLayerList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*imageapi.ImageLayerList), err
+}
+
+func (c *FakeImageLayers) Get(name string) (*imageapi.ImageLayer, error) {
+	obj, err := c.Fake.Invokes(ktestclient.NewGetAction("imagelayers", c.Namespace, name), &imageapi.ImageLayer{})
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*imageapi.ImageLayer), err
+}
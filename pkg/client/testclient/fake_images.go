@@ -4,6 +4,7 @@ import (
 	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
 
 	"github.com/openshift/origin/pkg/client"
 	imageapi "github.com/openshift/origin/pkg/image/api"
@@ -45,7 +46,29 @@ func (c *FakeImages) Create(inObj *imageapi.Image) (*imageapi.Image, error) {
 	return obj.(*imageapi.Image), err
 }
 
+func (c *FakeImages) Update(inObj *imageapi.Image) (*imageapi.Image, error) {
+	obj, err := c.Fake.Invokes(ktestclient.NewRootUpdateAction("images", inObj), inObj)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*imageapi.Image), err
+}
+
 func (c *FakeImages) Delete(name string) error {
 	_, err := c.Fake.Invokes(ktestclient.NewRootDeleteAction("images", name), &imageapi.Image{})
 	return err
 }
+
+func (c *FakeImages) Watch(label labels.Selector, field fields.Selector, resourceVersion string) (watch.Interface, error) {
+	return c.Fake.InvokesWatch(ktestclient.NewRootWatchAction("images", label, field, resourceVersion))
+}
+
+func (c *FakeImages) Finalize(inObj *imageapi.Image) (*imageapi.Image, error) {
+	obj, err := c.Fake.Invokes(ktestclient.NewUpdateSubresourceAction("images", "finalize", "", inObj), inObj)
+	if obj == nil {
+		return nil, err
+	}
+
+	return obj.(*imageapi.Image), err
+}
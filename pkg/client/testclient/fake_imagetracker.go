@@ -0,0 +1,325 @@
+package testclient
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// ImageTracker keeps images and image streams by namespace and name and
+// answers the actions of a fake client for images, image streams, image
+// stream mappings, image stream tags and image stream images from them.
+// Unlike ObjectReaction it honors names and namespaces, and objects created or
+// updated through the fake client are stored, so a test can inspect them
+// afterwards.
+type ImageTracker struct {
+	lock    sync.Mutex
+	images  map[string]*imageapi.Image
+	streams map[string]*imageapi.ImageStream
+}
+
+// NewImageTracker returns an ImageTracker seeded with the provided images,
+// image streams and lists of them.
+func NewImageTracker(objects ...runtime.Object) *ImageTracker {
+	t := &ImageTracker{
+		images:  make(map[string]*imageapi.Image),
+		streams: make(map[string]*imageapi.ImageStream),
+	}
+	for _, obj := range objects {
+		if err := t.Add(obj); err != nil {
+			panic(err)
+		}
+	}
+	return t
+}
+
+// NewImageTrackerFake returns a fake client whose image related actions are
+// answered by an ImageTracker seeded with the provided objects.
+func NewImageTrackerFake(objects ...runtime.Object) (*Fake, *ImageTracker) {
+	tracker := NewImageTracker(objects...)
+	fakeClient := &Fake{}
+	tracker.AddReactors(fakeClient)
+	return fakeClient, tracker
+}
+
+// Add stores a copy of the provided Image, ImageStream, ImageList or
+// ImageStreamList in the tracker.
+func (t *ImageTracker) Add(obj runtime.Object) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	switch o := obj.(type) {
+	case *imageapi.Image:
+		t.images[o.Name] = copyImage(o)
+	case *imageapi.ImageStream:
+		t.streams[streamKey(o.Namespace, o.Name)] = copyImageStream(o)
+	case *imageapi.ImageList:
+		for i := range o.Items {
+			t.images[o.Items[i].Name] = copyImage(&o.Items[i])
+		}
+	case *imageapi.ImageStreamList:
+		for i := range o.Items {
+			t.streams[streamKey(o.Items[i].Namespace, o.Items[i].Name)] = copyImageStream(&o.Items[i])
+		}
+	default:
+		return fmt.Errorf("unsupported object %T", obj)
+	}
+	return nil
+}
+
+// Image returns a copy of the tracked image with the given name.
+func (t *ImageTracker) Image(name string) (*imageapi.Image, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	image, ok := t.images[name]
+	if !ok {
+		return nil, false
+	}
+	return copyImage(image), true
+}
+
+// ImageStream returns a copy of the tracked image stream with the given
+// namespace and name.
+func (t *ImageTracker) ImageStream(namespace, name string) (*imageapi.ImageStream, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stream, ok := t.streams[streamKey(namespace, name)]
+	if !ok {
+		return nil, false
+	}
+	return copyImageStream(stream), true
+}
+
+// AddReactors appends the tracker's reactors for all image resources to the
+// reaction chain of the fake client.
+func (t *ImageTracker) AddReactors(c *Fake) {
+	c.AddReactor("*", "images", t.reactImages)
+	c.AddReactor("*", "imagestreams", t.reactImageStreams)
+	c.AddReactor("*", "imagestreammappings", t.reactImageStreamMappings)
+	c.AddReactor("*", "imagestreamtags", t.reactImageStreamTags)
+	c.AddReactor("*", "imagestreamimages", t.reactImageStreamImages)
+}
+
+func (t *ImageTracker) reactImages(action ktestclient.Action) (bool, runtime.Object, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	switch action.GetVerb() {
+	case "get":
+		name := action.(ktestclient.GetAction).GetName()
+		image, ok := t.images[name]
+		if !ok {
+			return true, nil, kerrors.NewNotFound("image", name)
+		}
+		return true, copyImage(image), nil
+	case "list":
+		list := &imageapi.ImageList{}
+		for _, image := range t.images {
+			list.Items = append(list.Items, *copyImage(image))
+		}
+		return true, list, nil
+	case "create":
+		image := action.(ktestclient.CreateAction).GetObject().(*imageapi.Image)
+		if _, exists := t.images[image.Name]; exists {
+			return true, nil, kerrors.NewAlreadyExists("image", image.Name)
+		}
+		t.images[image.Name] = copyImage(image)
+		return true, copyImage(image), nil
+	case "delete":
+		name := action.(ktestclient.DeleteAction).GetName()
+		if _, exists := t.images[name]; !exists {
+			return true, nil, kerrors.NewNotFound("image", name)
+		}
+		delete(t.images, name)
+		return true, nil, nil
+	}
+	return false, nil, nil
+}
+
+func (t *ImageTracker) reactImageStreams(action ktestclient.Action) (bool, runtime.Object, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	namespace := action.GetNamespace()
+	switch action.GetVerb() {
+	case "get":
+		if action.GetSubresource() == "secrets" {
+			// the tracker holds no secrets
+			return true, &kapi.SecretList{}, nil
+		}
+		name := action.(ktestclient.GetAction).GetName()
+		stream, ok := t.streams[streamKey(namespace, name)]
+		if !ok {
+			return true, nil, kerrors.NewNotFound("imageStream", name)
+		}
+		return true, copyImageStream(stream), nil
+	case "list":
+		list := &imageapi.ImageStreamList{}
+		for _, stream := range t.streams {
+			if len(namespace) == 0 || stream.Namespace == namespace {
+				list.Items = append(list.Items, *copyImageStream(stream))
+			}
+		}
+		return true, list, nil
+	case "create", "update":
+		// UpdateStatus sends a CreateActionImpl with the update verb, so both
+		// action kinds are handled through the GetObject accessor
+		stream := copyImageStream(action.(interface {
+			GetObject() runtime.Object
+		}).GetObject().(*imageapi.ImageStream))
+		if len(stream.Namespace) == 0 {
+			stream.Namespace = namespace
+		}
+		key := streamKey(stream.Namespace, stream.Name)
+		_, exists := t.streams[key]
+		if action.GetVerb() == "create" && exists {
+			return true, nil, kerrors.NewAlreadyExists("imageStream", stream.Name)
+		}
+		if action.GetVerb() == "update" && !exists {
+			return true, nil, kerrors.NewNotFound("imageStream", stream.Name)
+		}
+		t.streams[key] = stream
+		return true, copyImageStream(stream), nil
+	case "delete":
+		name := action.(ktestclient.DeleteAction).GetName()
+		key := streamKey(namespace, name)
+		if _, exists := t.streams[key]; !exists {
+			return true, nil, kerrors.NewNotFound("imageStream", name)
+		}
+		delete(t.streams, key)
+		return true, nil, nil
+	}
+	return false, nil, nil
+}
+
+func (t *ImageTracker) reactImageStreamMappings(action ktestclient.Action) (bool, runtime.Object, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if action.GetVerb() != "create" {
+		return false, nil, nil
+	}
+	mapping := action.(ktestclient.CreateAction).GetObject().(*imageapi.ImageStreamMapping)
+	stream, ok := t.streams[streamKey(action.GetNamespace(), mapping.Name)]
+	if !ok {
+		return true, nil, kerrors.NewNotFound("imageStream", mapping.Name)
+	}
+
+	image := copyImage(&mapping.Image)
+	if _, exists := t.images[image.Name]; !exists {
+		t.images[image.Name] = image
+	}
+
+	tag := mapping.Tag
+	if len(tag) == 0 {
+		tag = imageapi.DefaultImageTag
+	}
+	next := imageapi.TagEvent{
+		Created:              unversioned.Now(),
+		DockerImageReference: image.DockerImageReference,
+		Image:                image.Name,
+	}
+	if imageapi.AddTagEventToImageStream(stream, tag, next) {
+		imageapi.UpdateTrackingTags(stream, tag, next)
+	}
+	return true, nil, nil
+}
+
+func (t *ImageTracker) reactImageStreamTags(action ktestclient.Action) (bool, runtime.Object, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if action.GetVerb() != "get" {
+		return false, nil, nil
+	}
+	a := action.(ktestclient.GetAction)
+	name, tag, ok := imageapi.SplitImageStreamTag(a.GetName())
+	if !ok {
+		return true, nil, kerrors.NewBadRequest(fmt.Sprintf("%q is not a valid image stream tag name", a.GetName()))
+	}
+	stream, ok := t.streams[streamKey(action.GetNamespace(), name)]
+	if !ok {
+		return true, nil, kerrors.NewNotFound("imageStream", name)
+	}
+	event := imageapi.TaggedImage(stream, tag)
+	if event == nil || len(event.Image) == 0 {
+		return true, nil, kerrors.NewNotFound("imageStreamTag", a.GetName())
+	}
+	image, ok := t.images[event.Image]
+	if !ok {
+		return true, nil, kerrors.NewNotFound("image", event.Image)
+	}
+
+	return true, &imageapi.ImageStreamTag{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace:         stream.Namespace,
+			Name:              a.GetName(),
+			CreationTimestamp: event.Created,
+		},
+		Image: *copyImage(image),
+	}, nil
+}
+
+func (t *ImageTracker) reactImageStreamImages(action ktestclient.Action) (bool, runtime.Object, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if action.GetVerb() != "get" {
+		return false, nil, nil
+	}
+	a := action.(ktestclient.GetAction)
+	parts := strings.SplitN(a.GetName(), "@", 2)
+	if len(parts) != 2 {
+		return true, nil, kerrors.NewBadRequest(fmt.Sprintf("%q is not a valid image stream image name", a.GetName()))
+	}
+	stream, ok := t.streams[streamKey(action.GetNamespace(), parts[0])]
+	if !ok {
+		return true, nil, kerrors.NewNotFound("imageStream", parts[0])
+	}
+	event, err := imageapi.ResolveImageID(stream, parts[1])
+	if err != nil {
+		return true, nil, err
+	}
+	image, ok := t.images[event.Image]
+	if !ok {
+		return true, nil, kerrors.NewNotFound("image", event.Image)
+	}
+
+	return true, &imageapi.ImageStreamImage{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace: stream.Namespace,
+			Name:      a.GetName(),
+		},
+		Image: *copyImage(image),
+	}, nil
+}
+
+func streamKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func copyImage(image *imageapi.Image) *imageapi.Image {
+	obj, err := kapi.Scheme.Copy(image)
+	if err != nil {
+		panic(err)
+	}
+	return obj.(*imageapi.Image)
+}
+
+func copyImageStream(stream *imageapi.ImageStream) *imageapi.ImageStream {
+	obj, err := kapi.Scheme.Copy(stream)
+	if err != nil {
+		panic(err)
+	}
+	return obj.(*imageapi.ImageStream)
+}
@@ -9,6 +9,8 @@ import (
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/runtime"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
 func TestNewClient(t *testing.T) {
@@ -55,3 +57,61 @@ func TestErrors(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestImageTracker(t *testing.T) {
+	stream := &imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "test", Name: "app"}}
+	other := &imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "other", Name: "app"}}
+	oc, tracker := NewImageTrackerFake(stream, other)
+
+	if _, err := oc.ImageStreams("test").Get("missing"); !errors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	list, err := oc.ImageStreams("test").List(labels.Everything(), fields.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Namespace != "test" {
+		t.Fatalf("unexpected list %#v", list)
+	}
+
+	mapping := &imageapi.ImageStreamMapping{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "test", Name: "app"},
+		Image: imageapi.Image{
+			ObjectMeta:           kapi.ObjectMeta{Name: "sha256:0000000000000000000000000000000000000000000000000000000000000001"},
+			DockerImageReference: "registry:5000/test/app@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		Tag: "v1",
+	}
+	if err := oc.ImageStreamMappings("test").Create(mapping); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := tracker.Image(mapping.Image.Name); !ok {
+		t.Errorf("expected image %s to be created", mapping.Image.Name)
+	}
+	updated, ok := tracker.ImageStream("test", "app")
+	if !ok {
+		t.Fatalf("expected image stream test/app")
+	}
+	if event := imageapi.LatestTaggedImage(updated, "v1"); event == nil || event.Image != mapping.Image.Name {
+		t.Errorf("unexpected tag event %#v", event)
+	}
+
+	istag, err := oc.ImageStreamTags("test").Get("app", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if istag.Image.Name != mapping.Image.Name {
+		t.Errorf("unexpected image stream tag %#v", istag)
+	}
+	isimage, err := oc.ImageStreamImages("test").Get("app", mapping.Image.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isimage.Image.Name != mapping.Image.Name {
+		t.Errorf("unexpected image stream image %#v", isimage)
+	}
+	if _, err := oc.ImageStreamTags("other").Get("app", "v1"); !errors.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
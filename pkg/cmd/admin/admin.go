@@ -7,8 +7,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/openshift/openshift-sdn/pkg/cmd/admin/network"
+	"github.com/openshift/origin/pkg/cmd/admin/backup"
 	"github.com/openshift/origin/pkg/cmd/admin/cert"
 	"github.com/openshift/origin/pkg/cmd/admin/groups"
+	"github.com/openshift/origin/pkg/cmd/admin/imagepruner"
 	"github.com/openshift/origin/pkg/cmd/admin/node"
 	"github.com/openshift/origin/pkg/cmd/admin/policy"
 	"github.com/openshift/origin/pkg/cmd/admin/project"
@@ -57,6 +59,7 @@ func NewCommandAdmin(name, fullName string, out io.Writer) *cobra.Command {
 				router.NewCmdRouter(f, fullName, "router", out),
 				exipfailover.NewCmdIPFailoverConfig(f, fullName, "ipfailover", out),
 				registry.NewCmdRegistry(f, fullName, "registry", out),
+				imagepruner.NewCmdImagePruner(f, fullName, "image-pruner", out),
 			},
 		},
 		{
@@ -65,6 +68,9 @@ func NewCommandAdmin(name, fullName string, out io.Writer) *cobra.Command {
 				buildchain.NewCmdBuildChain(name, fullName+" "+buildchain.BuildChainRecommendedCommandName, f, out),
 				node.NewCommandManageNode(f, node.ManageNodeCommandName, fullName+" "+node.ManageNodeCommandName, out),
 				prune.NewCommandPrune(prune.PruneRecommendedName, fullName+" "+prune.PruneRecommendedName, f, out),
+				backup.NewCommandExport(backup.ExportRecommendedName, fullName+" "+backup.ExportRecommendedName, f, out),
+				backup.NewCommandImport(backup.ImportRecommendedName, fullName+" "+backup.ImportRecommendedName, f, out),
+				backup.NewCommandRestore(backup.RestoreRecommendedName, fullName+" "+backup.RestoreRecommendedName, f, out),
 			},
 		},
 		{
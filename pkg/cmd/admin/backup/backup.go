@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/openshift/origin/pkg/cmd/util"
+	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
+)
+
+const (
+	ExportRecommendedName  = "export"
+	ImportRecommendedName  = "import"
+	RestoreRecommendedName = "restore"
+)
+
+const exportLong = `
+Export resources from the server into an archive
+
+The commands here allow administrators to back up resources that can't be
+recreated from their definitions alone, to restore them later with the import
+command into the same or another cluster.`
+
+const importLong = `
+Import resources from an archive into the server
+
+The commands here allow administrators to restore the resources backed up by
+the export command, in the same or another cluster.`
+
+const restoreLong = `
+Restore deleted resources
+
+The commands here allow administrators to undo the deletion of resources that
+are retained for a grace period after being deleted.`
+
+func NewCommandExport(name, fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Command {
+	// Parent command to which all subcommands are added.
+	cmds := &cobra.Command{
+		Use:   name,
+		Short: "Export resources from the server into an archive",
+		Long:  exportLong,
+		Run:   cmdutil.DefaultSubCommandRun(out),
+	}
+
+	cmds.AddCommand(NewCmdExportImageStreams(ImageStreamsRecommendedName, fullName+" "+ImageStreamsRecommendedName, f, out))
+	return cmds
+}
+
+func NewCommandImport(name, fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Command {
+	// Parent command to which all subcommands are added.
+	cmds := &cobra.Command{
+		Use:   name,
+		Short: "Import resources from an archive into the server",
+		Long:  importLong,
+		Run:   cmdutil.DefaultSubCommandRun(out),
+	}
+
+	cmds.AddCommand(NewCmdImportImageStreams(ImageStreamsRecommendedName, fullName+" "+ImageStreamsRecommendedName, f, out))
+	return cmds
+}
+
+func NewCommandRestore(name, fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Command {
+	// Parent command to which all subcommands are added.
+	cmds := &cobra.Command{
+		Use:   name,
+		Short: "Restore deleted resources",
+		Long:  restoreLong,
+		Run:   cmdutil.DefaultSubCommandRun(out),
+	}
+
+	cmds.AddCommand(NewCmdRestoreImageStream(RestoreImageStreamRecommendedName, fullName+" "+RestoreImageStreamRecommendedName, f, out))
+	return cmds
+}
@@ -0,0 +1,488 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	kcmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/api/latest"
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+const ImageStreamsRecommendedName = "imagestreams"
+
+const (
+	exportImageStreamsLong = `
+Export image streams and their images into an archive
+
+The image streams of the current project, or of all projects, are written to a
+gzipped tar archive along with the images of their tag history, so that they
+can be restored with the import command. The manifests of the images are
+exported unless --strip-manifests is given, a restored image without its
+manifest can't be pulled by digest. The signatures are left out unless
+requested. The content of the images is not exported: the storage of the
+registry must be backed up separately.`
+
+	exportImageStreamsExample = `  # Export the image streams of the current project
+  $ %[1]s --to=imagestreams.tar.gz
+
+  # Export the image streams of all projects with the signatures of their images
+  $ %[1]s --all-namespaces --include-signatures --to=imagestreams.tar.gz`
+
+	importImageStreamsLong = `
+Import image streams and their images from an archive
+
+The images and image streams of an archive written by the export command are
+created in the projects they were exported from, which must exist. Objects that
+already exist are left untouched. The tag history of the image streams is
+restored, and the references to the integrated registry of the exporting
+cluster are rewritten to the address given with --registry.`
+
+	importImageStreamsExample = `  # Import image streams pushed to the integrated registry available at 172.30.1.1:5000
+  $ %[1]s --from=imagestreams.tar.gz --registry=172.30.1.1:5000`
+)
+
+const (
+	archiveImageStreamsDir = "imagestreams"
+	archiveImagesDir       = "images"
+)
+
+type ExportImageStreamsOptions struct {
+	Client client.Interface
+	Out    io.Writer
+
+	Namespace         string
+	AllNamespaces     bool
+	Names             []string
+	Filename          string
+	StripManifests    bool
+	IncludeSignatures bool
+}
+
+func NewCmdExportImageStreams(name, fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Command {
+	options := &ExportImageStreamsOptions{}
+
+	cmd := &cobra.Command{
+		Use:     name + " [NAME ...] --to=FILE",
+		Short:   "Export image streams and their images into an archive",
+		Long:    exportImageStreamsLong,
+		Example: fmt.Sprintf(exportImageStreamsExample, fullName),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := options.Complete(f, args, out); err != nil {
+				kcmdutil.CheckErr(kcmdutil.UsageError(cmd, err.Error()))
+			}
+			if err := options.Validate(); err != nil {
+				kcmdutil.CheckErr(kcmdutil.UsageError(cmd, err.Error()))
+			}
+			kcmdutil.CheckErr(options.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&options.Filename, "to", options.Filename, "The archive to write, or - for the standard output.")
+	cmd.Flags().BoolVar(&options.AllNamespaces, "all-namespaces", options.AllNamespaces, "If true, export the image streams of all projects.")
+	cmd.Flags().BoolVar(&options.StripManifests, "strip-manifests", options.StripManifests, "If true, leave the manifests of the images out of the archive.")
+	cmd.Flags().BoolVar(&options.IncludeSignatures, "include-signatures", options.IncludeSignatures, "If true, export the signatures of the images.")
+
+	return cmd
+}
+
+func (o *ExportImageStreamsOptions) Complete(f *clientcmd.Factory, args []string, out io.Writer) error {
+	o.Names = args
+	o.Out = out
+
+	namespace, _, err := f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+	o.Namespace = namespace
+
+	o.Client, _, err = f.Clients()
+	return err
+}
+
+func (o *ExportImageStreamsOptions) Validate() error {
+	if len(o.Filename) == 0 {
+		return errors.New("an archive must be specified with --to")
+	}
+	if o.AllNamespaces && len(o.Names) > 0 {
+		return errors.New("image stream names can't be specified with --all-namespaces")
+	}
+	return nil
+}
+
+// Run writes the image streams and the images of their tag history to the
+// archive.
+func (o *ExportImageStreamsOptions) Run() error {
+	streams, err := o.imageStreams()
+	if err != nil {
+		return err
+	}
+
+	names := sets.NewString()
+	for i := range streams {
+		for _, history := range streams[i].Status.Tags {
+			for _, event := range history.Items {
+				if len(event.Image) > 0 {
+					names.Insert(event.Image)
+				}
+			}
+		}
+	}
+	images := []*imageapi.Image{}
+	for _, name := range names.List() {
+		image, err := o.Client.Images().Get(name)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				// the image was pruned but is still in the tag history
+				continue
+			}
+			return err
+		}
+		if o.StripManifests {
+			image.DockerImageManifest = ""
+		}
+		if !o.IncludeSignatures {
+			image.Signatures = nil
+		}
+		clearMeta(&image.ObjectMeta)
+		images = append(images, image)
+	}
+
+	var w io.Writer = o.Out
+	if o.Filename != "-" {
+		file, err := os.Create(o.Filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeArchive(w, streams, images); err != nil {
+		return err
+	}
+	if o.Filename != "-" {
+		fmt.Fprintf(o.Out, "Exported %d image streams and %d images to %s\n", len(streams), len(images), o.Filename)
+	}
+	return nil
+}
+
+// imageStreams returns the image streams to export, cleared of the metadata
+// set by the server.
+func (o *ExportImageStreamsOptions) imageStreams() ([]*imageapi.ImageStream, error) {
+	streams := []*imageapi.ImageStream{}
+	if len(o.Names) > 0 {
+		for _, name := range o.Names {
+			stream, err := o.Client.ImageStreams(o.Namespace).Get(name)
+			if err != nil {
+				return nil, err
+			}
+			streams = append(streams, stream)
+		}
+	} else {
+		namespace := o.Namespace
+		if o.AllNamespaces {
+			namespace = kapi.NamespaceAll
+		}
+		list, err := o.Client.ImageStreams(namespace).List(labels.Everything(), fields.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			streams = append(streams, &list.Items[i])
+		}
+	}
+	for _, stream := range streams {
+		clearMeta(&stream.ObjectMeta)
+	}
+	return streams, nil
+}
+
+// clearMeta clears the metadata set by the server on creation.
+func clearMeta(meta *kapi.ObjectMeta) {
+	meta.ResourceVersion = ""
+	meta.UID = ""
+	meta.SelfLink = ""
+	meta.Generation = 0
+	meta.CreationTimestamp = unversioned.Time{}
+	meta.DeletionTimestamp = nil
+}
+
+// writeArchive writes streams and images to w as a gzipped tar archive, with
+// a file per object.
+func writeArchive(w io.Writer, streams []*imageapi.ImageStream, images []*imageapi.Image) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, stream := range streams {
+		if err := writeArchiveEntry(tw, path.Join(archiveImageStreamsDir, stream.Namespace, stream.Name+".json"), stream); err != nil {
+			return err
+		}
+	}
+	for _, image := range images {
+		if err := writeArchiveEntry(tw, path.Join(archiveImagesDir, image.Name+".json"), image); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeArchiveEntry(tw *tar.Writer, name string, obj runtime.Object) error {
+	data, err := latest.Codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+type ImportImageStreamsOptions struct {
+	Client client.Interface
+	Out    io.Writer
+
+	Filename string
+	Registry string
+}
+
+func NewCmdImportImageStreams(name, fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Command {
+	options := &ImportImageStreamsOptions{}
+
+	cmd := &cobra.Command{
+		Use:     name + " --from=FILE [--registry=HOST]",
+		Short:   "Import image streams and their images from an archive",
+		Long:    importImageStreamsLong,
+		Example: fmt.Sprintf(importImageStreamsExample, fullName),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := options.Complete(f, args, out); err != nil {
+				kcmdutil.CheckErr(kcmdutil.UsageError(cmd, err.Error()))
+			}
+			kcmdutil.CheckErr(options.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&options.Filename, "from", options.Filename, "The archive to read, or - for the standard input.")
+	cmd.Flags().StringVar(&options.Registry, "registry", options.Registry, "The address of the integrated registry of this cluster, to rewrite the references to the integrated registry of the exporting cluster.")
+
+	return cmd
+}
+
+func (o *ImportImageStreamsOptions) Complete(f *clientcmd.Factory, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("no arguments are allowed to this command")
+	}
+	if len(o.Filename) == 0 {
+		return errors.New("an archive must be specified with --from")
+	}
+	o.Out = out
+
+	var err error
+	o.Client, _, err = f.Clients()
+	return err
+}
+
+// Run creates the images and the image streams of the archive, then restores
+// the tag history and the spec tags of the image streams. The spec tags are
+// set once the tag history of all the image streams is restored, so that tags
+// referencing other image streams resolve to the same images as when they
+// were exported.
+func (o *ImportImageStreamsOptions) Run() error {
+	var r io.Reader = os.Stdin
+	if o.Filename != "-" {
+		file, err := os.Open(o.Filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	streams, images, err := readArchive(r)
+	if err != nil {
+		return err
+	}
+	if len(o.Registry) > 0 {
+		rewriteRegistry(streams, images, o.Registry)
+	}
+
+	created := 0
+	for _, image := range images {
+		if _, err := o.Client.Images().Create(image); err != nil {
+			if kerrors.IsAlreadyExists(err) {
+				continue
+			}
+			return fmt.Errorf("unable to create image %s: %v", image.Name, err)
+		}
+		created++
+	}
+	fmt.Fprintf(o.Out, "Imported %d images, %d already existed\n", created, len(images)-created)
+
+	restored := []*imageapi.ImageStream{}
+	for _, stream := range streams {
+		newStream := &imageapi.ImageStream{
+			ObjectMeta: stream.ObjectMeta,
+			Spec:       imageapi.ImageStreamSpec{DockerImageRepository: stream.Spec.DockerImageRepository},
+		}
+		newStream, err := o.Client.ImageStreams(stream.Namespace).Create(newStream)
+		if err != nil {
+			if kerrors.IsAlreadyExists(err) {
+				fmt.Fprintf(o.Out, "Image stream %s/%s already exists, skipping\n", stream.Namespace, stream.Name)
+				continue
+			}
+			return fmt.Errorf("unable to create image stream %s/%s: %v", stream.Namespace, stream.Name, err)
+		}
+		newStream.Status.Tags = stream.Status.Tags
+		if _, err := o.Client.ImageStreams(stream.Namespace).UpdateStatus(newStream); err != nil {
+			return fmt.Errorf("unable to restore the tag history of image stream %s/%s: %v", stream.Namespace, stream.Name, err)
+		}
+		restored = append(restored, stream)
+	}
+
+	for _, stream := range restored {
+		if len(stream.Spec.Tags) == 0 {
+			continue
+		}
+		newStream, err := o.Client.ImageStreams(stream.Namespace).Get(stream.Name)
+		if err != nil {
+			return err
+		}
+		newStream.Spec.Tags = stream.Spec.Tags
+		if _, err := o.Client.ImageStreams(stream.Namespace).Update(newStream); err != nil {
+			return fmt.Errorf("unable to restore the tags of image stream %s/%s: %v", stream.Namespace, stream.Name, err)
+		}
+	}
+	fmt.Fprintf(o.Out, "Imported %d image streams, %d already existed\n", len(restored), len(streams)-len(restored))
+	return nil
+}
+
+// readArchive reads the image streams and the images of an archive written by
+// writeArchive, sorted by namespace and name.
+func readArchive(r io.Reader) ([]*imageapi.ImageStream, []*imageapi.Image, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the archive: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	streams := []*imageapi.ImageStream{}
+	images := []*imageapi.Image{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read the archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read %s from the archive: %v", header.Name, err)
+		}
+		obj, err := latest.Codec.Decode(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to decode %s from the archive: %v", header.Name, err)
+		}
+		switch t := obj.(type) {
+		case *imageapi.ImageStream:
+			streams = append(streams, t)
+		case *imageapi.Image:
+			images = append(images, t)
+		default:
+			return nil, nil, fmt.Errorf("unexpected object %T in %s", obj, header.Name)
+		}
+	}
+
+	sort.Sort(imageStreamsByName(streams))
+	sort.Sort(imagesByName(images))
+	return streams, images, nil
+}
+
+type imageStreamsByName []*imageapi.ImageStream
+
+func (s imageStreamsByName) Len() int      { return len(s) }
+func (s imageStreamsByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s imageStreamsByName) Less(i, j int) bool {
+	if s[i].Namespace != s[j].Namespace {
+		return s[i].Namespace < s[j].Namespace
+	}
+	return s[i].Name < s[j].Name
+}
+
+type imagesByName []*imageapi.Image
+
+func (s imagesByName) Len() int           { return len(s) }
+func (s imagesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s imagesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// rewriteRegistry replaces the integrated registry of the exporting cluster,
+// found in the status of the image streams, by registry in the references of
+// streams and images.
+func rewriteRegistry(streams []*imageapi.ImageStream, images []*imageapi.Image, registry string) {
+	oldRegistries := sets.NewString()
+	for _, stream := range streams {
+		if ref, err := imageapi.ParseDockerImageReference(stream.Status.DockerImageRepository); err == nil && len(ref.Registry) > 0 {
+			oldRegistries.Insert(ref.Registry)
+		}
+	}
+	oldRegistries.Delete(registry)
+	if oldRegistries.Len() == 0 {
+		return
+	}
+
+	rewrite := func(spec string) string {
+		ref, err := imageapi.ParseDockerImageReference(spec)
+		if err != nil || !oldRegistries.Has(ref.Registry) {
+			return spec
+		}
+		ref.Registry = registry
+		return ref.Exact()
+	}
+
+	for _, image := range images {
+		image.DockerImageReference = rewrite(image.DockerImageReference)
+	}
+	for _, stream := range streams {
+		stream.Spec.DockerImageRepository = rewrite(stream.Spec.DockerImageRepository)
+		for tag, tagRef := range stream.Spec.Tags {
+			if tagRef.From != nil && tagRef.From.Kind == "DockerImage" {
+				from := *tagRef.From
+				from.Name = rewrite(from.Name)
+				tagRef.From = &from
+				stream.Spec.Tags[tag] = tagRef
+			}
+		}
+		for tag, history := range stream.Status.Tags {
+			for i := range history.Items {
+				history.Items[i].DockerImageReference = rewrite(history.Items[i].DockerImageReference)
+			}
+			stream.Status.Tags[tag] = history
+		}
+	}
+}
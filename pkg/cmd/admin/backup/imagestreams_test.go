@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+const (
+	testDigest       = "sha256:3c79d2f1e9a2d1e4fb8ebf4fb7a1e19d4a8f7c5b1a0d1e2f3a4b5c6d7e8f9a0b"
+	testPrunedDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+func exportedImageStream() *imageapi.ImageStream {
+	return &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app", ResourceVersion: "10", UID: "uid"},
+		Spec: imageapi.ImageStreamSpec{
+			Tags: map[string]imageapi.TagReference{
+				"stable": {From: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "app:latest"}},
+				"ext":    {From: &kapi.ObjectReference{Kind: "DockerImage", Name: "172.30.1.1:5000/ns/app:latest"}},
+			},
+		},
+		Status: imageapi.ImageStreamStatus{
+			DockerImageRepository: "172.30.1.1:5000/ns/app",
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{
+					{Image: testDigest, DockerImageReference: "172.30.1.1:5000/ns/app@" + testDigest},
+					{Image: testPrunedDigest, DockerImageReference: "172.30.1.1:5000/ns/app@" + testPrunedDigest},
+				}},
+			},
+		},
+	}
+}
+
+func TestExportImportImageStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagestreams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "imagestreams.tar.gz")
+
+	source := testclient.NewSimpleFake()
+	source.PrependReactor("list", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, &imageapi.ImageStreamList{Items: []imageapi.ImageStream{*exportedImageStream()}}, nil
+	})
+	source.PrependReactor("get", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		name := action.(ktestclient.GetAction).GetName()
+		if name != testDigest {
+			return true, nil, kerrors.NewNotFound("image", name)
+		}
+		return true, &imageapi.Image{
+			ObjectMeta:           kapi.ObjectMeta{Name: testDigest, ResourceVersion: "5"},
+			DockerImageReference: "172.30.1.1:5000/ns/app@" + testDigest,
+			DockerImageManifest:  "{}",
+			Signatures:           []imageapi.ImageSignature{{ObjectMeta: kapi.ObjectMeta{Name: testDigest + "@sig"}, Type: "test"}},
+		}, nil
+	})
+
+	out := &bytes.Buffer{}
+	export := &ExportImageStreamsOptions{Client: source, Out: out, Namespace: "ns", Filename: archive, IncludeSignatures: true}
+	if err := export.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "Exported 1 image streams and 1 images to "+archive+"\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	dest := testclient.NewSimpleFake()
+	var createdImages []*imageapi.Image
+	var restored *imageapi.ImageStream
+	dest.PrependReactor("create", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		image := action.(ktestclient.CreateAction).GetObject().(*imageapi.Image)
+		createdImages = append(createdImages, image)
+		return true, image, nil
+	})
+	dest.PrependReactor("create", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		stream := action.(ktestclient.CreateAction).GetObject().(*imageapi.ImageStream)
+		if len(stream.Spec.Tags) > 0 || len(stream.Status.Tags) > 0 {
+			t.Errorf("unexpected tags in the created image stream: %#v", stream)
+		}
+		return true, stream, nil
+	})
+	dest.PrependReactor("update", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		stream := action.(ktestclient.CreateAction).GetObject().(*imageapi.ImageStream)
+		restored = stream
+		return true, stream, nil
+	})
+	dest.PrependReactor("get", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, restored, nil
+	})
+
+	out.Reset()
+	importOptions := &ImportImageStreamsOptions{Client: dest, Out: out, Filename: archive, Registry: "registry.example.com:5000"}
+	if err := importOptions.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "Imported 1 images, 0 already existed\nImported 1 image streams, 0 already existed\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	if len(createdImages) != 1 {
+		t.Fatalf("unexpected images: %#v", createdImages)
+	}
+	image := createdImages[0]
+	if image.DockerImageReference != "registry.example.com:5000/ns/app@"+testDigest || len(image.ResourceVersion) > 0 {
+		t.Errorf("unexpected image: %#v", image)
+	}
+	if image.DockerImageManifest != "{}" || len(image.Signatures) != 1 {
+		t.Errorf("expected the manifest and the signatures of the image: %#v", image)
+	}
+
+	stream := exportedImageStream()
+	if restored == nil || restored.ResourceVersion != "" {
+		t.Fatalf("unexpected image stream: %#v", restored)
+	}
+	// the tags are read back with the default reference policy
+	expectedTag := stream.Spec.Tags["stable"]
+	expectedTag.ReferencePolicy.Type = imageapi.SourceTagReferencePolicy
+	if !reflect.DeepEqual(restored.Spec.Tags["stable"], expectedTag) {
+		t.Errorf("unexpected tag: %#v", restored.Spec.Tags["stable"])
+	}
+	if from := restored.Spec.Tags["ext"].From; from.Name != "registry.example.com:5000/ns/app:latest" {
+		t.Errorf("unexpected tag: %#v", from)
+	}
+	expected := []string{"registry.example.com:5000/ns/app@" + testDigest, "registry.example.com:5000/ns/app@" + testPrunedDigest}
+	for i, event := range restored.Status.Tags["latest"].Items {
+		if event.DockerImageReference != expected[i] {
+			t.Errorf("unexpected tag event %d: %#v", i, event)
+		}
+	}
+}
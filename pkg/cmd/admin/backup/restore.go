@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	kcmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+const RestoreImageStreamRecommendedName = "imagestream"
+
+const (
+	restoreImageStreamLong = `
+Restore a deleted image stream
+
+When the master is configured with imagePolicyConfig.deletedImageStreamGracePeriodSeconds,
+a deleted image stream is retained until its grace period expires, along with
+the images it references, which can't be pruned until then. This command undoes
+the deletion of such an image stream before it is removed for good. Without a
+name, the image streams of the current project that can be restored are listed.`
+
+	restoreImageStreamExample = `  # List the deleted image streams of the current project that can be restored
+  $ %[1]s
+
+  # Restore the deleted image stream ruby
+  $ %[1]s ruby`
+)
+
+type RestoreImageStreamOptions struct {
+	Client client.ImageStreamsNamespacer
+	Out    io.Writer
+
+	Namespace string
+	Name      string
+}
+
+func NewCmdRestoreImageStream(name, fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Command {
+	options := &RestoreImageStreamOptions{}
+
+	cmd := &cobra.Command{
+		Use:     name + " [NAME]",
+		Short:   "Restore a deleted image stream",
+		Long:    restoreImageStreamLong,
+		Example: fmt.Sprintf(restoreImageStreamExample, fullName),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := options.Complete(f, args, out); err != nil {
+				kcmdutil.CheckErr(kcmdutil.UsageError(cmd, err.Error()))
+			}
+			kcmdutil.CheckErr(options.Run())
+		},
+	}
+
+	return cmd
+}
+
+func (o *RestoreImageStreamOptions) Complete(f *clientcmd.Factory, args []string, out io.Writer) error {
+	switch len(args) {
+	case 0:
+	case 1:
+		o.Name = args[0]
+	default:
+		return errors.New("at most one image stream name may be specified")
+	}
+	o.Out = out
+
+	namespace, _, err := f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+	o.Namespace = namespace
+
+	o.Client, _, err = f.Clients()
+	return err
+}
+
+// Run restores the image stream, or lists the image streams that can be
+// restored if no name was given.
+func (o *RestoreImageStreamOptions) Run() error {
+	if len(o.Name) == 0 {
+		return o.listDeleted()
+	}
+
+	stream, err := o.Client.ImageStreams(o.Namespace).Get(o.Name)
+	if err != nil {
+		return err
+	}
+	if stream.DeletionTimestamp == nil {
+		return fmt.Errorf("image stream %q is not being deleted", o.Name)
+	}
+	if _, err := o.Client.ImageStreams(o.Namespace).Restore(stream); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "imagestream %q restored\n", o.Name)
+	return nil
+}
+
+// listDeleted prints the image streams being deleted and the time they will
+// be removed at.
+func (o *RestoreImageStreamOptions) listDeleted() error {
+	streams, err := o.Client.ImageStreams(o.Namespace).List(labels.Everything(), imageapi.DeletedImageStreams())
+	if err != nil {
+		return err
+	}
+	var deleted []*imageapi.ImageStream
+	for i := range streams.Items {
+		if streams.Items[i].DeletionTimestamp != nil {
+			deleted = append(deleted, &streams.Items[i])
+		}
+	}
+	if len(deleted) == 0 {
+		fmt.Fprintf(o.Out, "No deleted image streams can be restored in project %s\n", o.Namespace)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.Out, 10, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tREMOVED AT")
+	for _, stream := range deleted {
+		fmt.Fprintf(w, "%s\t%s\n", stream.Name, stream.DeletionTimestamp.Format(time.RFC1123Z))
+	}
+	return w.Flush()
+}
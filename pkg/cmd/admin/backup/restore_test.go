@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestRestoreImageStream(t *testing.T) {
+	deletion := unversioned.NewTime(time.Now().Add(time.Hour))
+	deleted := &imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "deleted", DeletionTimestamp: &deletion}}
+	active := &imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "active"}}
+	fake := testclient.NewSimpleFake()
+	fake.PrependReactor("list", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, &imageapi.ImageStreamList{Items: []imageapi.ImageStream{*deleted, *active}}, nil
+	})
+	fake.PrependReactor("get", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		if action.(ktestclient.GetAction).GetName() == deleted.Name {
+			return true, deleted, nil
+		}
+		return true, active, nil
+	})
+	fake.PrependReactor("update", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, action.(ktestclient.UpdateAction).GetObject(), nil
+	})
+
+	out := &bytes.Buffer{}
+	o := &RestoreImageStreamOptions{Client: fake, Out: out, Namespace: "ns"}
+	if err := o.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "deleted") || strings.Contains(out.String(), "active") {
+		t.Errorf("expected only the deleted image stream to be listed, got %q", out.String())
+	}
+
+	fake.ClearActions()
+	o.Name = "deleted"
+	if err := o.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actions := fake.Actions()
+	if len(actions) != 2 || !actions[1].Matches("update", "imagestreams") || actions[1].GetSubresource() != "restore" {
+		t.Errorf("expected the image stream to be restored, got %#v", actions)
+	}
+
+	fake.ClearActions()
+	o.Name = "active"
+	if err := o.Run(); err == nil {
+		t.Errorf("expected an error restoring an image stream that isn't being deleted")
+	}
+	if actions := fake.Actions(); len(actions) != 1 {
+		t.Errorf("expected no restore, got %#v", actions)
+	}
+}
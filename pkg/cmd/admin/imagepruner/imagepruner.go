@@ -0,0 +1,265 @@
+package imagepruner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/runtime"
+	kutil "k8s.io/kubernetes/pkg/util"
+
+	"github.com/openshift/origin/pkg/cmd/server/bootstrappolicy"
+	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
+	"github.com/openshift/origin/pkg/cmd/util/variable"
+	configcmd "github.com/openshift/origin/pkg/config/cmd"
+	dapi "github.com/openshift/origin/pkg/deploy/api"
+	"github.com/openshift/origin/pkg/generate/app"
+)
+
+const (
+	imagePrunerLong = `
+Install a continuous image pruner
+
+This command deploys a pod pruning the images of the cluster and their data in the integrated
+registry once per --interval, using the same policy as 'oadm prune images'. With no arguments, the
+command will check for the existing deployment config called 'image-pruner' and try to create it,
+along with the service account the pruner runs as. If you want to test whether the pruner has been
+created add the --dry-run flag and the command will exit with 1 if the pruner does not exist.
+
+The service account of the pruner must be allowed to prune images before it can delete anything:
+
+  $ oadm policy add-cluster-role-to-user %[1]s system:serviceaccount:<namespace>:<service account>
+
+With --orphaned-blobs the pruner also deletes the blobs of the registry which no image references,
+e.g. the layers of failed pushes. Every blob the images of this cluster don't reference is deleted,
+including the ones of another cluster sharing the storage of the registry, so the flag must be
+acknowledged with --confirm-orphaned-blobs.
+
+The pruner may be paused, in which case it only reports what it would prune in its logs and its
+metrics, by setting IMAGE_PRUNER_PAUSED=true in the environment of its deployment config. The
+metrics are served on /metrics on port 8080 of the pruner pod.`
+
+	imagePrunerExample = `  # Check if the image pruner ("image-pruner") has been created
+  $ %[1]s %[2]s --dry-run
+
+  # See what the image pruner will look like if created
+  $ %[1]s %[2]s -o yaml
+
+  # Create an image pruner running every 6 hours and keeping 5 images per tag
+  $ %[1]s %[2]s --interval=6h --keep-tag-revisions=5
+
+  # Pause the pruner
+  $ oc env dc/image-pruner IMAGE_PRUNER_PAUSED=true`
+)
+
+// ImagePrunerConfig holds the configuration of the continuous image pruner.
+type ImagePrunerConfig struct {
+	ImageTemplate        variable.ImageTemplate
+	Interval             time.Duration
+	KeepYoungerThan      time.Duration
+	KeepTagRevisions     int
+	OrphanedBlobs        bool
+	ConfirmOrphanedBlobs bool
+	Paused               bool
+	DryRun               bool
+	ServiceAccount       string
+}
+
+var errExit = fmt.Errorf("exit")
+
+// NewCmdImagePruner implements the OpenShift cli image-pruner command
+func NewCmdImagePruner(f *clientcmd.Factory, parentName, name string, out io.Writer) *cobra.Command {
+	cfg := &ImagePrunerConfig{
+		ImageTemplate: variable.NewDefaultImageTemplate(),
+
+		Interval:         time.Hour,
+		KeepYoungerThan:  60 * time.Minute,
+		KeepTagRevisions: 3,
+		ServiceAccount:   "image-pruner",
+	}
+
+	cmd := &cobra.Command{
+		Use:     name,
+		Short:   "Install a continuous image pruner",
+		Long:    fmt.Sprintf(imagePrunerLong, bootstrappolicy.ImagePrunerRoleName),
+		Example: fmt.Sprintf(imagePrunerExample, parentName, name),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := RunCmdImagePruner(f, cmd, out, cfg, args)
+			if err != errExit {
+				cmdutil.CheckErr(err)
+			} else {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&cfg.ImageTemplate.Format, "images", cfg.ImageTemplate.Format, "The image to base the pruner on - ${component} will be replaced with 'deployer'")
+	cmd.Flags().BoolVar(&cfg.ImageTemplate.Latest, "latest-images", cfg.ImageTemplate.Latest, "If true, attempt to use the latest image for the pruner instead of the latest release.")
+	cmd.Flags().DurationVar(&cfg.Interval, "interval", cfg.Interval, "The time between the start of two pruning runs.")
+	cmd.Flags().DurationVar(&cfg.KeepYoungerThan, "keep-younger-than", cfg.KeepYoungerThan, "Specify the minimum age of an image for it to be considered a candidate for pruning.")
+	cmd.Flags().IntVar(&cfg.KeepTagRevisions, "keep-tag-revisions", cfg.KeepTagRevisions, "Specify the number of image revisions for a tag in an image stream that will be preserved.")
+	cmd.Flags().BoolVar(&cfg.OrphanedBlobs, "orphaned-blobs", cfg.OrphanedBlobs, "Also delete the blobs stored by the registry which no image references, e.g. the layers of failed pushes. Requires --confirm-orphaned-blobs.")
+	cmd.Flags().BoolVar(&cfg.ConfirmOrphanedBlobs, "confirm-orphaned-blobs", cfg.ConfirmOrphanedBlobs, "Acknowledge that --orphaned-blobs deletes every blob of the registry that the images of this cluster don't reference.")
+	cmd.Flags().BoolVar(&cfg.Paused, "paused", cfg.Paused, "Create the pruner paused; it only reports what it would prune until IMAGE_PRUNER_PAUSED is set to false.")
+	cmd.Flags().BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "Check if the pruner exists instead of creating.")
+	cmd.Flags().StringVar(&cfg.ServiceAccount, "service-account", cfg.ServiceAccount, "Name of the service account to use to run the pruner pod. It is created if it doesn't exist.")
+
+	cmdutil.AddPrinterFlags(cmd)
+
+	return cmd
+}
+
+// RunCmdImagePruner contains all the necessary functionality for the OpenShift cli image-pruner command
+func RunCmdImagePruner(f *clientcmd.Factory, cmd *cobra.Command, out io.Writer, cfg *ImagePrunerConfig, args []string) error {
+	var name string
+	switch len(args) {
+	case 0:
+		name = "image-pruner"
+	default:
+		return cmdutil.UsageError(cmd, "No arguments are allowed to this command")
+	}
+	if cfg.Interval <= 0 {
+		return cmdutil.UsageError(cmd, "--interval must be positive")
+	}
+	if len(cfg.ServiceAccount) == 0 {
+		return cmdutil.UsageError(cmd, "--service-account must be specified")
+	}
+	if cfg.OrphanedBlobs && !cfg.ConfirmOrphanedBlobs {
+		return cmdutil.UsageError(cmd, "--orphaned-blobs deletes every blob of the registry that the images of this cluster don't reference, add --confirm-orphaned-blobs to acknowledge it")
+	}
+
+	label := map[string]string{
+		"image-pruner": "default",
+	}
+
+	// the deployer image contains the openshift binary
+	image := cfg.ImageTemplate.ExpandOrDie("deployer")
+
+	namespace, _, err := f.OpenShiftClientConfig.Namespace()
+	if err != nil {
+		return fmt.Errorf("error getting client: %v", err)
+	}
+	osClient, kClient, err := f.Clients()
+	if err != nil {
+		return fmt.Errorf("error getting client: %v", err)
+	}
+
+	_, output, err := cmdutil.PrinterForCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("unable to configure printer: %v", err)
+	}
+
+	generate := output
+	if !generate {
+		_, err = osClient.DeploymentConfigs(namespace).Get(name)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("can't check for existing image pruner %q: %v", name, err)
+			}
+			generate = true
+		}
+	}
+
+	if !generate {
+		fmt.Fprintf(out, "Image pruner %q deployment config exists\n", name)
+		return nil
+	}
+	if cfg.DryRun && !output {
+		return fmt.Errorf("image pruner %q does not exist (no deployment config).", name)
+	}
+
+	objects := []runtime.Object{}
+	if _, err := kClient.ServiceAccounts(namespace).Get(cfg.ServiceAccount); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("can't check for existing service account %q: %v", cfg.ServiceAccount, err)
+		}
+		objects = append(objects, &kapi.ServiceAccount{ObjectMeta: kapi.ObjectMeta{Name: cfg.ServiceAccount, Labels: label}})
+	}
+
+	command := []string{
+		"/usr/bin/openshift", "infra", "image-pruner",
+		"--interval=" + cfg.Interval.String(),
+		"--keep-younger-than=" + cfg.KeepYoungerThan.String(),
+		"--keep-tag-revisions=" + strconv.Itoa(cfg.KeepTagRevisions),
+		"--listen=:8080",
+	}
+	if cfg.OrphanedBlobs {
+		command = append(command, "--orphaned-blobs")
+	}
+	env := app.Environment{
+		"IMAGE_PRUNER_PAUSED": strconv.FormatBool(cfg.Paused),
+	}
+
+	objects = append(objects, &dapi.DeploymentConfig{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:   name,
+			Labels: label,
+		},
+		Triggers: []dapi.DeploymentTriggerPolicy{
+			{Type: dapi.DeploymentTriggerOnConfigChange},
+		},
+		Template: dapi.DeploymentTemplate{
+			// a single pruner runs at a time
+			Strategy: dapi.DeploymentStrategy{Type: dapi.DeploymentStrategyTypeRecreate},
+			ControllerTemplate: kapi.ReplicationControllerSpec{
+				Replicas: 1,
+				Selector: label,
+				Template: &kapi.PodTemplateSpec{
+					ObjectMeta: kapi.ObjectMeta{Labels: label},
+					Spec: kapi.PodSpec{
+						ServiceAccountName: cfg.ServiceAccount,
+						Containers: []kapi.Container{
+							{
+								Name:    "image-pruner",
+								Image:   image,
+								Command: command,
+								Ports:   []kapi.ContainerPort{{Name: "metrics", ContainerPort: 8080}},
+								Env:     env.List(),
+								LivenessProbe: &kapi.Probe{
+									InitialDelaySeconds: 10,
+									TimeoutSeconds:      5,
+									Handler: kapi.Handler{
+										HTTPGet: &kapi.HTTPGetAction{
+											Path: "/healthz",
+											Port: kutil.NewIntOrStringFromInt(8080),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	// TODO: label all created objects with the same label
+	list := &kapi.List{Items: objects}
+
+	if output {
+		if err := f.PrintObject(cmd, list, out); err != nil {
+			return fmt.Errorf("unable to print object: %v", err)
+		}
+		return nil
+	}
+
+	mapper, typer := f.Factory.Object()
+	bulk := configcmd.Bulk{
+		Mapper:            mapper,
+		Typer:             typer,
+		RESTClientFactory: f.Factory.RESTClient,
+
+		After: configcmd.NewPrintNameOrErrorAfter(mapper, cmdutil.GetFlagString(cmd, "output") == "name", "created", out, cmd.Out()),
+	}
+	if errs := bulk.Create(list, namespace); len(errs) != 0 {
+		return errExit
+	}
+	fmt.Fprintf(cmd.Out(), "Grant the image pruner the right to prune images with:\n  oadm policy add-cluster-role-to-user %s system:serviceaccount:%s:%s\n", bootstrappolicy.ImagePrunerRoleName, namespace, cfg.ServiceAccount)
+	return nil
+}
@@ -0,0 +1,240 @@
+package prune
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/prune"
+)
+
+// imagePruneReport lists what was deleted by a run of prune images, or what
+// would be deleted in a dry run.
+type imagePruneReport struct {
+	// DryRun is true if nothing was deleted.
+	DryRun bool `json:"dryRun"`
+	// Streams lists what was deleted per image stream.
+	Streams []*streamPruneReport `json:"streams"`
+	// Images lists the images deleted from the server.
+	Images []string `json:"images"`
+	// Blobs lists the layer blobs deleted from the registry.
+	Blobs []string `json:"blobs"`
+	// OrphanedBlobs counts the blobs no image referenced, and their links,
+	// deleted from the registry.
+	OrphanedBlobs *prune.OrphanedBlobsResult `json:"orphanedBlobs,omitempty"`
+
+	lock    sync.Mutex
+	streams map[string]*streamPruneReport
+}
+
+// streamPruneReport lists what was deleted for an image stream.
+type streamPruneReport struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Images lists the images whose references were removed from the tags of
+	// the stream.
+	Images []string `json:"images,omitempty"`
+	// Layers lists the layer links deleted from the repository of the stream.
+	Layers []string `json:"layers,omitempty"`
+	// Manifests lists the image manifests deleted from the repository of the
+	// stream.
+	Manifests []string `json:"manifests,omitempty"`
+}
+
+func newImagePruneReport(dryRun bool) *imagePruneReport {
+	return &imagePruneReport{
+		DryRun:  dryRun,
+		Streams: []*streamPruneReport{},
+		Images:  []string{},
+		Blobs:   []string{},
+		streams: make(map[string]*streamPruneReport),
+	}
+}
+
+// stream returns the report of the stream of the repository repo, of the form
+// <namespace>/<name>. The caller must hold the lock of the report.
+func (r *imagePruneReport) stream(repo string) *streamPruneReport {
+	if s, ok := r.streams[repo]; ok {
+		return s
+	}
+	s := &streamPruneReport{Name: repo}
+	if parts := strings.SplitN(repo, "/", 2); len(parts) == 2 {
+		s.Namespace, s.Name = parts[0], parts[1]
+	}
+	r.streams[repo] = s
+	r.Streams = append(r.Streams, s)
+	return s
+}
+
+// print sorts the report and writes it to out in the format, json or yaml.
+func (r *imagePruneReport) print(out io.Writer, format string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sort.Sort(streamPruneReportsByName(r.Streams))
+	for _, s := range r.Streams {
+		sort.Strings(s.Images)
+		sort.Strings(s.Layers)
+		sort.Strings(s.Manifests)
+	}
+	sort.Strings(r.Images)
+	sort.Strings(r.Blobs)
+
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(r, "", "  ")
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.Marshal(r)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// streamPruneReportsByName sorts stream reports by namespace and name.
+type streamPruneReportsByName []*streamPruneReport
+
+func (s streamPruneReportsByName) Len() int      { return len(s) }
+func (s streamPruneReportsByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s streamPruneReportsByName) Less(i, j int) bool {
+	if s[i].Namespace != s[j].Namespace {
+		return s[i].Namespace < s[j].Namespace
+	}
+	return s[i].Name < s[j].Name
+}
+
+// reportingImageStreamPruner adds each image stream update to a report. If a
+// delegate exists, its PruneImageStream function is invoked first and the
+// update is reported only if it succeeds.
+type reportingImageStreamPruner struct {
+	report   *imagePruneReport
+	delegate prune.ImageStreamPruner
+}
+
+var _ prune.ImageStreamPruner = &reportingImageStreamPruner{}
+
+func (p *reportingImageStreamPruner) PruneImageStream(stream *imageapi.ImageStream, image *imageapi.Image, updatedTags []string) (*imageapi.ImageStream, error) {
+	updatedStream := stream
+	if p.delegate != nil {
+		var err error
+		if updatedStream, err = p.delegate.PruneImageStream(stream, image, updatedTags); err != nil {
+			return updatedStream, err
+		}
+	}
+
+	p.report.lock.Lock()
+	defer p.report.lock.Unlock()
+	s := p.report.stream(fmt.Sprintf("%s/%s", stream.Namespace, stream.Name))
+	s.Images = append(s.Images, image.Name)
+	return updatedStream, nil
+}
+
+// reportingImagePruner adds each image being deleted to a report. If a
+// delegate exists, its PruneImage function is invoked first and the image is
+// reported only if it succeeds.
+type reportingImagePruner struct {
+	report   *imagePruneReport
+	delegate prune.ImagePruner
+}
+
+var _ prune.ImagePruner = &reportingImagePruner{}
+
+func (p *reportingImagePruner) PruneImage(image *imageapi.Image) error {
+	if p.delegate != nil {
+		if err := p.delegate.PruneImage(image); err != nil {
+			return err
+		}
+	}
+
+	p.report.lock.Lock()
+	defer p.report.lock.Unlock()
+	p.report.Images = append(p.report.Images, image.Name)
+	return nil
+}
+
+// reportingLayerPruner adds each repository layer link being deleted to a
+// report. If a delegate exists, its PruneLayer function is invoked first and
+// the layer link is reported only if it succeeds.
+type reportingLayerPruner struct {
+	report   *imagePruneReport
+	delegate prune.LayerPruner
+}
+
+var _ prune.LayerPruner = &reportingLayerPruner{}
+
+func (p *reportingLayerPruner) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	if p.delegate != nil {
+		if err := p.delegate.PruneLayer(registryClient, registryURL, repo, layer); err != nil {
+			return err
+		}
+	}
+
+	p.report.lock.Lock()
+	defer p.report.lock.Unlock()
+	s := p.report.stream(repo)
+	s.Layers = append(s.Layers, layer)
+	return nil
+}
+
+// reportingBlobPruner adds each blob being deleted to a report. If a delegate
+// exists, its PruneBlob function is invoked first and the blob is reported
+// only if it succeeds.
+type reportingBlobPruner struct {
+	report   *imagePruneReport
+	delegate prune.BlobPruner
+}
+
+var _ prune.BlobPruner = &reportingBlobPruner{}
+
+func (p *reportingBlobPruner) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
+	if p.delegate != nil {
+		if err := p.delegate.PruneBlob(registryClient, registryURL, blob); err != nil {
+			return err
+		}
+	}
+
+	p.report.lock.Lock()
+	defer p.report.lock.Unlock()
+	p.report.Blobs = append(p.report.Blobs, blob)
+	return nil
+}
+
+// reportingManifestPruner adds each repository manifest being deleted to a
+// report. If a delegate exists, its PruneManifest function is invoked first
+// and the manifest is reported only if it succeeds.
+type reportingManifestPruner struct {
+	report   *imagePruneReport
+	delegate prune.ManifestPruner
+}
+
+var _ prune.ManifestPruner = &reportingManifestPruner{}
+
+func (p *reportingManifestPruner) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	if p.delegate != nil {
+		if err := p.delegate.PruneManifest(registryClient, registryURL, repo, manifest); err != nil {
+			return err
+		}
+	}
+
+	p.report.lock.Lock()
+	defer p.report.lock.Unlock()
+	s := p.report.stream(repo)
+	s.Manifests = append(s.Manifests, manifest)
+	return nil
+}
@@ -9,21 +9,18 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
-	kapi "k8s.io/kubernetes/pkg/api"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
-	"k8s.io/kubernetes/pkg/fields"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
-	"k8s.io/kubernetes/pkg/labels"
 
 	"github.com/openshift/origin/pkg/client"
 	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
 	imageapi "github.com/openshift/origin/pkg/image/api"
 	"github.com/openshift/origin/pkg/image/prune"
-	oserrors "github.com/openshift/origin/pkg/util/errors"
 )
 
 const (
@@ -44,6 +41,16 @@ type PruneImagesOptions struct {
 
 	CABundle            string
 	RegistryUrlOverride string
+
+	Workers        int
+	CheckpointFile string
+	Output         string
+	OrphanedBlobs  bool
+
+	// orphanedBlobPruner is the pruner of the orphaned blobs given to Pruner,
+	// its output is set up by RunPruneImages like the one of the other
+	// pruners.
+	orphanedBlobPruner *outputOrphanedBlobPruner
 }
 
 // NewCmdPruneImages implements the OpenShift cli prune images command
@@ -52,6 +59,7 @@ func NewCmdPruneImages(f *clientcmd.Factory, parentName, name string, out io.Wri
 		Confirm:          false,
 		KeepYoungerThan:  60 * time.Minute,
 		KeepTagRevisions: 3,
+		Workers:          5,
 	}
 
 	cmd := &cobra.Command{
@@ -79,6 +87,10 @@ func NewCmdPruneImages(f *clientcmd.Factory, parentName, name string, out io.Wri
 	cmd.Flags().IntVar(&opts.KeepTagRevisions, "keep-tag-revisions", opts.KeepTagRevisions, "Specify the number of image revisions for a tag in an image stream that will be preserved.")
 	cmd.Flags().StringVar(&opts.CABundle, "certificate-authority", opts.CABundle, "The path to a certificate authority bundle to use when communicating with the managed Docker registries. Defaults to the certificate authority data from the current user's config file.")
 	cmd.Flags().StringVar(&opts.RegistryUrlOverride, "registry-url", opts.RegistryUrlOverride, "The address to use when contacting the registry, instead of using the default value. This is useful if you can't resolve or reach the registry (e.g.; the default is a cluster-internal URL) but you do have an alternative route that works.")
+	cmd.Flags().IntVar(&opts.Workers, "workers", opts.Workers, "Specify the number of deletions of layers, blobs and manifests sent to the registry in parallel.")
+	cmd.Flags().StringVar(&opts.CheckpointFile, "checkpoint-file", opts.CheckpointFile, "The path of a file recording the deletions from the registry. If a run is interrupted, running the command again with the same file skips the deletions already done. The file is removed once a run completes.")
+	cmd.Flags().BoolVar(&opts.OrphanedBlobs, "orphaned-blobs", opts.OrphanedBlobs, "Also delete the blobs stored by the registry which no image references, e.g. the layers of failed pushes. The registry deletes the blobs older than --keep-younger-than, with their repository links, while it holds the pushes.")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", opts.Output, "Output format. One of: json|yaml. If set, a report of what was (or would be) deleted per image stream is printed instead of the tables.")
 
 	return cmd
 }
@@ -97,58 +109,20 @@ func (o *PruneImagesOptions) Complete(f *clientcmd.Factory, args []string, out i
 	}
 	o.Client = osClient
 
-	allImages, err := osClient.Images().List(labels.Everything(), fields.Everything())
-	if err != nil {
-		return err
-	}
-
-	allStreams, err := osClient.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
-	if err != nil {
-		return err
-	}
-
-	allPods, err := kClient.Pods(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
-	if err != nil {
-		return err
-	}
-
-	allRCs, err := kClient.ReplicationControllers(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
-	if err != nil {
-		return err
-	}
-
-	allBCs, err := osClient.BuildConfigs(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
-	// We need to tolerate 'not found' errors for buildConfigs since they may be disabled in Atomic
-	err = oserrors.TolerateNotFoundError(err)
-	if err != nil {
-		return err
-	}
-
-	allBuilds, err := osClient.Builds(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
-	// We need to tolerate 'not found' errors for builds since they may be disabled in Atomic
-	err = oserrors.TolerateNotFoundError(err)
-	if err != nil {
-		return err
-	}
-
-	allDCs, err := osClient.DeploymentConfigs(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
-	if err != nil {
-		return err
-	}
-
 	options := prune.ImageRegistryPrunerOptions{
 		KeepYoungerThan:  o.KeepYoungerThan,
 		KeepTagRevisions: o.KeepTagRevisions,
-		Images:           allImages,
-		Streams:          allStreams,
-		Pods:             allPods,
-		RCs:              allRCs,
-		BCs:              allBCs,
-		Builds:           allBuilds,
-		DCs:              allDCs,
 		DryRun:           o.Confirm == false,
 		RegistryClient:   registryClient,
 		RegistryURL:      o.RegistryUrlOverride,
+		Workers:          o.Workers,
+	}
+	if err := options.ListObjects(osClient, kClient); err != nil {
+		return err
+	}
+	if o.OrphanedBlobs {
+		o.orphanedBlobPruner = &outputOrphanedBlobPruner{delegate: prune.NewRegistryOrphanedBlobPruner()}
+		options.OrphanedBlobPruner = o.orphanedBlobPruner
 	}
 
 	o.Pruner = prune.NewImageRegistryPruner(options)
@@ -167,32 +141,96 @@ func (o *PruneImagesOptions) Validate() error {
 	if o.Out == nil {
 		return errors.New("a writer needs to be specified")
 	}
+	if o.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	switch o.Output {
+	case "", "json", "yaml":
+	default:
+		return fmt.Errorf("--output must be one of json or yaml, not %q", o.Output)
+	}
 	return nil
 }
 
 // RunPruneImages runs the prune images cli command
 func (o *PruneImagesOptions) RunPruneImages() error {
-	// this tabwriter is used by the describing*Pruners below for their output
-	w := tabwriter.NewWriter(o.Out, 10, 4, 3, ' ', 0)
-	defer w.Flush()
-
-	imagePruner := &describingImagePruner{w: w}
-	imageStreamPruner := &describingImageStreamPruner{w: w}
-	layerPruner := &describingLayerPruner{w: w}
-	blobPruner := &describingBlobPruner{w: w}
-	manifestPruner := &describingManifestPruner{w: w}
-
+	var (
+		imageDeleter       prune.ImagePruner
+		imageStreamDeleter prune.ImageStreamPruner
+		layerDeleter       prune.LayerPruner
+		blobDeleter        prune.BlobPruner
+		manifestDeleter    prune.ManifestPruner
+	)
 	if o.Confirm {
-		imagePruner.delegate = prune.NewDeletingImagePruner(o.Client.Images())
-		imageStreamPruner.delegate = prune.NewDeletingImageStreamPruner(o.Client)
-		layerPruner.delegate = prune.NewDeletingLayerPruner()
-		blobPruner.delegate = prune.NewDeletingBlobPruner()
-		manifestPruner.delegate = prune.NewDeletingManifestPruner()
+		imageDeleter = prune.NewDeletingImagePruner(o.Client.Images())
+		imageStreamDeleter = prune.NewDeletingImageStreamPruner(o.Client)
+		layerDeleter = prune.NewDeletingLayerPruner()
+		blobDeleter = prune.NewDeletingBlobPruner()
+		manifestDeleter = prune.NewDeletingManifestPruner()
 	} else {
 		fmt.Fprintln(os.Stderr, "Dry run enabled - no modifications will be made. Add --confirm to remove images")
 	}
 
-	return o.Pruner.Prune(imagePruner, imageStreamPruner, layerPruner, blobPruner, manifestPruner)
+	var (
+		imagePruner       prune.ImagePruner
+		imageStreamPruner prune.ImageStreamPruner
+		layerPruner       prune.LayerPruner
+		blobPruner        prune.BlobPruner
+		manifestPruner    prune.ManifestPruner
+		report            *imagePruneReport
+	)
+	if len(o.Output) > 0 {
+		report = newImagePruneReport(!o.Confirm)
+		imagePruner = &reportingImagePruner{report: report, delegate: imageDeleter}
+		imageStreamPruner = &reportingImageStreamPruner{report: report, delegate: imageStreamDeleter}
+		layerPruner = &reportingLayerPruner{report: report, delegate: layerDeleter}
+		blobPruner = &reportingBlobPruner{report: report, delegate: blobDeleter}
+		manifestPruner = &reportingManifestPruner{report: report, delegate: manifestDeleter}
+		if o.orphanedBlobPruner != nil {
+			o.orphanedBlobPruner.report = report
+		}
+	} else {
+		// this tabwriter is used by the describing*Pruners below for their output
+		w := tabwriter.NewWriter(o.Out, 10, 4, 3, ' ', 0)
+		defer w.Flush()
+
+		imagePruner = &describingImagePruner{w: w, delegate: imageDeleter}
+		imageStreamPruner = &describingImageStreamPruner{w: w, delegate: imageStreamDeleter}
+		layerPruner = &describingLayerPruner{w: w, delegate: layerDeleter}
+		blobPruner = &describingBlobPruner{w: w, delegate: blobDeleter}
+		manifestPruner = &describingManifestPruner{w: w, delegate: manifestDeleter}
+		if o.orphanedBlobPruner != nil {
+			o.orphanedBlobPruner.w = w
+		}
+	}
+
+	var checkpoint *prune.Checkpoint
+	if len(o.CheckpointFile) > 0 {
+		var err error
+		if checkpoint, err = prune.OpenCheckpoint(o.CheckpointFile, !o.Confirm); err != nil {
+			return err
+		}
+		defer checkpoint.Close()
+
+		layerPruner = prune.NewCheckpointingLayerPruner(layerPruner, checkpoint)
+		blobPruner = prune.NewCheckpointingBlobPruner(blobPruner, checkpoint)
+		manifestPruner = prune.NewCheckpointingManifestPruner(manifestPruner, checkpoint)
+	}
+
+	err := o.Pruner.Prune(imagePruner, imageStreamPruner, layerPruner, blobPruner, manifestPruner)
+
+	if report != nil {
+		if printErr := report.print(o.Out, o.Output); printErr != nil && err == nil {
+			err = printErr
+		}
+	}
+	if err == nil && checkpoint != nil && o.Confirm {
+		// the run completed, a later run must not skip deletions of the same blobs
+		if err := os.Remove(o.CheckpointFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return err
 }
 
 // describingImageStreamPruner prints information about each image stream update.
@@ -264,11 +302,14 @@ type describingLayerPruner struct {
 	w             io.Writer
 	delegate      prune.LayerPruner
 	headerPrinted bool
+	// lock serializes the output of parallel deletions
+	lock sync.Mutex
 }
 
 var _ prune.LayerPruner = &describingLayerPruner{}
 
 func (p *describingLayerPruner) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	p.lock.Lock()
 	if !p.headerPrinted {
 		p.headerPrinted = true
 		fmt.Fprintln(p.w, "\nDeleting registry repository layer links ...")
@@ -276,6 +317,7 @@ func (p *describingLayerPruner) PruneLayer(registryClient *http.Client, registry
 	}
 
 	fmt.Fprintf(p.w, "%s\t%s\n", repo, layer)
+	p.lock.Unlock()
 
 	if p.delegate == nil {
 		return nil
@@ -295,11 +337,14 @@ type describingBlobPruner struct {
 	w             io.Writer
 	delegate      prune.BlobPruner
 	headerPrinted bool
+	// lock serializes the output of parallel deletions
+	lock sync.Mutex
 }
 
 var _ prune.BlobPruner = &describingBlobPruner{}
 
 func (p *describingBlobPruner) PruneBlob(registryClient *http.Client, registryURL, layer string) error {
+	p.lock.Lock()
 	if !p.headerPrinted {
 		p.headerPrinted = true
 		fmt.Fprintln(p.w, "\nDeleting registry layer blobs ...")
@@ -307,6 +352,7 @@ func (p *describingBlobPruner) PruneBlob(registryClient *http.Client, registryUR
 	}
 
 	fmt.Fprintf(p.w, "%s\n", layer)
+	p.lock.Unlock()
 
 	if p.delegate == nil {
 		return nil
@@ -327,11 +373,14 @@ type describingManifestPruner struct {
 	w             io.Writer
 	delegate      prune.ManifestPruner
 	headerPrinted bool
+	// lock serializes the output of parallel deletions
+	lock sync.Mutex
 }
 
 var _ prune.ManifestPruner = &describingManifestPruner{}
 
 func (p *describingManifestPruner) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	p.lock.Lock()
 	if !p.headerPrinted {
 		p.headerPrinted = true
 		fmt.Fprintln(p.w, "\nDeleting registry repository manifest data ...")
@@ -339,6 +388,7 @@ func (p *describingManifestPruner) PruneManifest(registryClient *http.Client, re
 	}
 
 	fmt.Fprintf(p.w, "%s\t%s\n", repo, manifest)
+	p.lock.Unlock()
 
 	if p.delegate == nil {
 		return nil
@@ -352,6 +402,36 @@ func (p *describingManifestPruner) PruneManifest(registryClient *http.Client, re
 	return err
 }
 
+// outputOrphanedBlobPruner prints what its delegate deleted, or adds it to a
+// report if one is set. Unlike the other pruners, the delegate is always
+// invoked: it only counts the orphaned blobs in a dry run.
+type outputOrphanedBlobPruner struct {
+	w        io.Writer
+	report   *imagePruneReport
+	delegate prune.OrphanedBlobPruner
+}
+
+var _ prune.OrphanedBlobPruner = &outputOrphanedBlobPruner{}
+
+func (p *outputOrphanedBlobPruner) PruneOrphanedBlobs(registryClient *http.Client, registryURL string, minAge time.Duration, dryRun bool) (*prune.OrphanedBlobsResult, error) {
+	result, err := p.delegate.PruneOrphanedBlobs(registryClient, registryURL, minAge, dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error pruning the orphaned blobs of the registry: %v\n", err)
+		return nil, err
+	}
+
+	if p.report != nil {
+		p.report.lock.Lock()
+		defer p.report.lock.Unlock()
+		p.report.OrphanedBlobs = result
+		return result, nil
+	}
+	fmt.Fprintln(p.w, "\nDeleting orphaned registry blobs and their repository links ...")
+	fmt.Fprintln(p.w, "LINKS\tBLOBS\tBYTES")
+	fmt.Fprintf(p.w, "%d\t%d\t%d\n", result.DeletedLinks, result.DeletedBlobs, result.DeletedBytes)
+	return result, nil
+}
+
 // getClients returns a Kube client, OpenShift client, and registry client.
 func getClients(f *clientcmd.Factory, caBundle string) (*client.Client, *kclient.Client, *http.Client, error) {
 	clientConfig, err := f.OpenShiftClientConfig.ClientConfig()
@@ -359,25 +439,26 @@ func getClients(f *clientcmd.Factory, caBundle string) (*client.Client, *kclient
 		return nil, nil, nil, err
 	}
 
-	var (
-		token          string
-		osClient       *client.Client
-		kClient        *kclient.Client
-		registryClient *http.Client
-	)
+	if len(clientConfig.BearerToken) == 0 {
+		return nil, nil, nil, errors.New("You must use a client config with a token")
+	}
+	osClient, kClient, err := f.Clients()
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	switch {
-	case len(clientConfig.BearerToken) > 0:
-		osClient, kClient, err = f.Clients()
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		token = clientConfig.BearerToken
-	default:
-		err = errors.New("You must use a client config with a token")
+	registryClient, err := NewRegistryClient(clientConfig, caBundle)
+	if err != nil {
 		return nil, nil, nil, err
 	}
 
+	return osClient, kClient, registryClient, nil
+}
+
+// NewRegistryClient returns a client of the registry authenticating with the
+// token of clientConfig. The certificate authority bundle at caBundle, if
+// set, is trusted in addition to the one of clientConfig.
+func NewRegistryClient(clientConfig *kclient.Config, caBundle string) (*http.Client, error) {
 	// copy the config
 	registryClientConfig := *clientConfig
 
@@ -393,11 +474,11 @@ func getClients(f *clientcmd.Factory, caBundle string) (*client.Client, *kclient
 	registryClientConfig.Username = "unused"
 
 	// set the "password" to be the token
-	registryClientConfig.Password = token
+	registryClientConfig.Password = clientConfig.BearerToken
 
 	tlsConfig, err := kclient.TLSConfigFor(&registryClientConfig)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
 	// if the user specified a CA on the command line, add it to the
@@ -405,7 +486,7 @@ func getClients(f *clientcmd.Factory, caBundle string) (*client.Client, *kclient
 	if len(caBundle) > 0 {
 		data, err := ioutil.ReadFile(caBundle)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
 
 		if tlsConfig.RootCAs == nil {
@@ -421,12 +502,10 @@ func getClients(f *clientcmd.Factory, caBundle string) (*client.Client, *kclient
 
 	wrappedTransport, err := kclient.HTTPWrappersForConfig(&registryClientConfig, &transport)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	registryClient = &http.Client{
+	return &http.Client{
 		Transport: wrappedTransport,
-	}
-
-	return osClient, kClient, registryClient, nil
+	}, nil
 }
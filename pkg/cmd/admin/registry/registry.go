@@ -227,6 +227,9 @@ func RunCmdRegistry(f *clientcmd.Factory, cmd *cobra.Command, out io.Writer, cfg
 			"OPENSHIFT_KEY_DATA":  string(config.KeyData),
 			"OPENSHIFT_CERT_DATA": string(config.CertData),
 			"OPENSHIFT_INSECURE":  insecure,
+
+			"REGISTRY_SERVICE_NAMESPACE": namespace,
+			"REGISTRY_SERVICE_NAME":      name,
 		}
 
 		mountHost := len(cfg.HostMount) > 0
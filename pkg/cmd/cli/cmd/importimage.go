@@ -3,16 +3,15 @@ package cmd
 import (
 	"fmt"
 	"io"
-	"time"
+	"sort"
+	"strings"
 
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/errors"
-	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
-	"k8s.io/kubernetes/pkg/labels"
-	"k8s.io/kubernetes/pkg/watch"
 
-	"github.com/openshift/origin/pkg/client"
 	"github.com/openshift/origin/pkg/cmd/cli/describe"
 	imageapi "github.com/openshift/origin/pkg/image/api"
 	"github.com/spf13/cobra"
@@ -25,9 +24,22 @@ const (
 Import tag and image information from an external Docker image repository
 
 Only image streams that have a value set for spec.dockerImageRepository and/or
-spec.Tags may have tag and image information imported.`
+spec.Tags may have tag and image information imported. The images are imported
+by the server at once, the tags whose import failed are reported.
 
-	importImageExample = `  $ %[1]s import-image mystream`
+The --scheduled and --insecure flags set the import policy of the imported tags
+of spec.Tags, which keep it for the following imports. With --insecure, the
+tags of spec.dockerImageRepository are imported insecurely too. With --all,
+every tag of spec.dockerImageRepository is added to spec.Tags, so that each
+tag of the repository gets the import policy.`
+
+	importImageExample = `  $ %[1]s import-image mystream
+
+  # Import the tags of mystream from a registry served over HTTP, and re-import them periodically
+  $ %[1]s import-image mystream --scheduled --insecure
+
+  # Import every tag of a repository into a new image stream, and re-import each of them periodically
+  $ %[1]s import-image mystream --from=registry.example.com/ns/app --confirm --all --scheduled`
 )
 
 // NewCmdImportImage implements the OpenShift cli import-image command.
@@ -45,6 +57,9 @@ func NewCmdImportImage(fullName string, f *clientcmd.Factory, out io.Writer) *co
 	}
 	cmd.Flags().String("from", "", "A Docker image repository to import images from")
 	cmd.Flags().Bool("confirm", false, "If true, allow the image stream import location to be set or changed")
+	cmd.Flags().Bool("scheduled", false, "If true, the server periodically re-imports the imported tags of spec.Tags")
+	cmd.Flags().Bool("insecure", false, "If true, the server may bypass certificate verification or connect directly over HTTP to import the images")
+	cmd.Flags().Bool("all", false, "If true, add every imported tag of the repository to the spec tags of the image stream")
 
 	return cmd
 }
@@ -68,6 +83,11 @@ func RunImportImage(f *clientcmd.Factory, out io.Writer, cmd *cobra.Command, arg
 
 	from := cmdutil.GetFlagString(cmd, "from")
 	confirm := cmdutil.GetFlagBool(cmd, "confirm")
+	all := cmdutil.GetFlagBool(cmd, "all")
+	policy := imageapi.TagImportPolicy{
+		Scheduled: cmdutil.GetFlagBool(cmd, "scheduled"),
+		Insecure:  cmdutil.GetFlagBool(cmd, "insecure"),
+	}
 
 	imageStreamClient := osClient.ImageStreams(namespace)
 	stream, err := imageStreamClient.Get(streamName)
@@ -83,9 +103,6 @@ func RunImportImage(f *clientcmd.Factory, out io.Writer, cmd *cobra.Command, arg
 			Spec:       imageapi.ImageStreamSpec{DockerImageRepository: from},
 		}
 	} else {
-		if len(stream.Spec.DockerImageRepository) == 0 && len(stream.Spec.Tags) == 0 {
-			return fmt.Errorf("image stream has not defined anything to import")
-		}
 		if len(from) != 0 {
 			if from != stream.Spec.DockerImageRepository {
 				if !confirm {
@@ -96,87 +113,197 @@ func RunImportImage(f *clientcmd.Factory, out io.Writer, cmd *cobra.Command, arg
 		}
 	}
 
-	if stream.Annotations != nil {
-		delete(stream.Annotations, imageapi.DockerImageRepositoryCheckAnnotation)
+	isi := newImageStreamImport(stream, policy)
+	if isi.Spec.Repository == nil && len(isi.Spec.Images) == 0 {
+		return fmt.Errorf("image stream has not defined anything to import")
 	}
-
-	if stream.CreationTimestamp.IsZero() {
-		stream, err = imageStreamClient.Create(stream)
-	} else {
-		stream, err = imageStreamClient.Update(stream)
+	if all && isi.Spec.Repository == nil {
+		return fmt.Errorf("--all requires a repository to import, from --from or the spec.dockerImageRepository of the image stream")
 	}
+	result, err := imageStreamClient.Import(isi)
 	if err != nil {
 		return err
 	}
 
-	resourceVersion := stream.ResourceVersion
-
-	fmt.Fprintln(cmd.Out(), "Waiting for the import to complete, CTRL+C to stop waiting.")
-
-	updatedStream, err := waitForImport(imageStreamClient, stream.Name, resourceVersion)
-	if err != nil {
-		if _, ok := err.(importError); ok {
+	if all && result.Status.Import != nil {
+		err := kclient.RetryOnConflict(kclient.DefaultRetry, func() error {
+			imported, err := imageStreamClient.Get(result.Status.Import.Name)
+			if err != nil {
+				return err
+			}
+			if !addRepositoryTags(imported, result, policy) {
+				return nil
+			}
+			_, err = imageStreamClient.Update(imported)
 			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to add the tags of the repository to the image stream: %v", err)
 		}
-		return fmt.Errorf("unable to determine if the import completed successfully - please run 'oc describe -n %s imagestream/%s' to see if the tags were updated as expected: %v", stream.Namespace, stream.Name, err)
 	}
 
-	fmt.Fprint(cmd.Out(), "The import completed successfully.", "\n\n")
+	failures := importFailures(result)
+	if len(failures) == 0 {
+		fmt.Fprint(cmd.Out(), "The import completed successfully.", "\n\n")
+	}
+	printImportSummary(out, stream, result)
 
-	d := describe.ImageStreamDescriber{Interface: osClient}
-	info, err := d.Describe(updatedStream.Namespace, updatedStream.Name)
-	if err != nil {
-		return err
+	if result.Status.Import != nil {
+		d := describe.ImageStreamDescriber{Interface: osClient}
+		info, err := d.Describe(result.Status.Import.Namespace, result.Status.Import.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, info)
 	}
 
-	fmt.Fprintln(out, info)
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to import image: %s", strings.Join(failures, "; "))
+	}
 	return nil
 }
 
-// TODO: move to image/api as a helper
-type importError struct {
-	annotation string
-}
+// newImageStreamImport returns the import of the tags of stream from the
+// repository of its spec.dockerImageRepository, if any, and of its spec tags
+// tracking a DockerImage. The flags set in policy are added to the import
+// policy of the spec tags, an insecure policy applies to the repository too.
+func newImageStreamImport(stream *imageapi.ImageStream, policy imageapi.TagImportPolicy) *imageapi.ImageStreamImport {
+	isi := &imageapi.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:        stream.Name,
+			Annotations: map[string]string{},
+		},
+		Spec: imageapi.ImageStreamImportSpec{Import: true},
+	}
+	if insecure, ok := stream.Annotations[imageapi.InsecureRepositoryAnnotation]; ok {
+		isi.Annotations[imageapi.InsecureRepositoryAnnotation] = insecure
+	}
+	if policy.Insecure {
+		isi.Annotations[imageapi.InsecureRepositoryAnnotation] = "true"
+	}
+	if len(stream.Spec.DockerImageRepository) > 0 {
+		isi.Spec.Repository = &imageapi.RepositoryImportSpec{
+			From: kapi.ObjectReference{Kind: "DockerImage", Name: stream.Spec.DockerImageRepository},
+		}
+	}
 
-func (e importError) Error() string {
-	return fmt.Sprintf("unable to import image: %s", e.annotation)
+	tags := make([]string, 0, len(stream.Spec.Tags))
+	for tag := range stream.Spec.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		tagRef := stream.Spec.Tags[tag]
+		if tagRef.From == nil || tagRef.From.Kind != "DockerImage" || tagRef.Reference {
+			continue
+		}
+		importPolicy := tagRef.ImportPolicy
+		importPolicy.Scheduled = importPolicy.Scheduled || policy.Scheduled
+		importPolicy.Insecure = importPolicy.Insecure || policy.Insecure
+		isi.Spec.Images = append(isi.Spec.Images, imageapi.ImageImportSpec{
+			From:         kapi.ObjectReference{Kind: "DockerImage", Name: tagRef.From.Name},
+			To:           &kapi.LocalObjectReference{Name: tag},
+			ImportPolicy: importPolicy,
+		})
+	}
+	return isi
 }
 
-func waitForImport(imageStreamClient client.ImageStreamInterface, name, resourceVersion string) (*imageapi.ImageStream, error) {
-	streamWatch, err := imageStreamClient.Watch(labels.Everything(), fields.OneTermEqualSelector("metadata.name", name), resourceVersion)
+// addRepositoryTags adds a spec tag with the import policy policy to stream
+// for every tag of the repository successfully imported by isi that stream
+// doesn't define, tracking the tag of the repository. It returns true if
+// stream changed.
+func addRepositoryTags(stream *imageapi.ImageStream, isi *imageapi.ImageStreamImport, policy imageapi.TagImportPolicy) bool {
+	if isi.Spec.Repository == nil || isi.Status.Repository == nil {
+		return false
+	}
+	ref, err := imageapi.ParseDockerImageReference(isi.Spec.Repository.From.Name)
 	if err != nil {
-		return nil, err
+		return false
 	}
-	defer streamWatch.Stop()
-
-	for {
-		select {
-		case event, ok := <-streamWatch.ResultChan():
-			if !ok {
-				return nil, fmt.Errorf("image stream watch ended prematurely")
-			}
+	if stream.Spec.Tags == nil {
+		stream.Spec.Tags = make(map[string]imageapi.TagReference)
+	}
+	changed := false
+	for _, image := range isi.Status.Repository.Images {
+		if image.Image == nil || len(image.Tag) == 0 {
+			continue
+		}
+		if _, ok := stream.Spec.Tags[image.Tag]; ok {
+			continue
+		}
+		tagRef := ref
+		tagRef.Tag = image.Tag
+		stream.Spec.Tags[image.Tag] = imageapi.TagReference{
+			From:         &kapi.ObjectReference{Kind: "DockerImage", Name: tagRef.String()},
+			ImportPolicy: policy,
+		}
+		changed = true
+	}
+	return changed
+}
 
-			switch event.Type {
-			case watch.Modified:
-				s, ok := event.Object.(*imageapi.ImageStream)
-				if !ok {
-					continue
-				}
-				annotation, ok := s.Annotations[imageapi.DockerImageRepositoryCheckAnnotation]
-				if !ok {
-					continue
-				}
+// printImportSummary writes the tags of the image stream stream, as it was
+// before the import isi, that the import created, updated or failed to import.
+func printImportSummary(out io.Writer, stream *imageapi.ImageStream, isi *imageapi.ImageStreamImport) {
+	var imported []imageapi.ImageImportStatus
+	if isi.Status.Repository != nil {
+		imported = append(imported, isi.Status.Repository.Images...)
+	}
+	imported = append(imported, isi.Status.Images...)
 
-				if _, err := time.Parse(time.RFC3339, annotation); err == nil {
-					return s, nil
-				}
-				return nil, importError{annotation}
+	created, updated, failed := []string{}, []string{}, []string{}
+	for _, status := range imported {
+		if len(status.Tag) == 0 {
+			continue
+		}
+		if status.Image == nil {
+			failed = append(failed, status.Tag)
+			continue
+		}
+		if isi.Status.Import != nil {
+			// the image of an immutable tag isn't replaced
+			if current := imageapi.LatestTaggedImage(isi.Status.Import, status.Tag); current == nil || current.Image != status.Image.Name {
+				continue
+			}
+		}
+		previous := imageapi.LatestTaggedImage(stream, status.Tag)
+		switch {
+		case previous == nil:
+			created = append(created, status.Tag)
+		case previous.Image != status.Image.Name:
+			updated = append(updated, status.Tag)
+		}
+	}
+	for _, summary := range []struct {
+		title string
+		tags  []string
+	}{{"Created", created}, {"Updated", updated}, {"Failed", failed}} {
+		if len(summary.tags) == 0 {
+			continue
+		}
+		sort.Strings(summary.tags)
+		fmt.Fprintf(out, "%s tags: %s\n", summary.title, strings.Join(summary.tags, ", "))
+	}
+}
 
-			case watch.Deleted:
-				return nil, fmt.Errorf("the image stream was deleted")
-			case watch.Error:
-				return nil, fmt.Errorf("error watching image stream")
+// importFailures returns the reasons of the imports of isi that failed.
+func importFailures(isi *imageapi.ImageStreamImport) []string {
+	failures := []string{}
+	if repository := isi.Status.Repository; repository != nil {
+		if repository.Status.Status != unversioned.StatusSuccess {
+			failures = append(failures, repository.Status.Message)
+		}
+		for _, image := range repository.Images {
+			if image.Status.Status != unversioned.StatusSuccess {
+				failures = append(failures, fmt.Sprintf("tag %s: %s", image.Tag, image.Status.Message))
 			}
 		}
 	}
+	for _, image := range isi.Status.Images {
+		if image.Status.Status != unversioned.StatusSuccess {
+			failures = append(failures, fmt.Sprintf("tag %s: %s", image.Tag, image.Status.Message))
+		}
+	}
+	return failures
 }
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestNewImageStreamImport(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:        "mysql",
+			Annotations: map[string]string{imageapi.InsecureRepositoryAnnotation: "true"},
+		},
+		Spec: imageapi.ImageStreamSpec{
+			DockerImageRepository: "mysql",
+			Tags: map[string]imageapi.TagReference{
+				"stable": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.6"},
+					ImportPolicy: imageapi.TagImportPolicy{Scheduled: true},
+				},
+				"old":       {From: &kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.5"}},
+				"reference": {From: &kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.1"}, Reference: true},
+				"tracking":  {From: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "mysql:stable"}},
+			},
+		},
+	}
+
+	isi := newImageStreamImport(stream, imageapi.TagImportPolicy{})
+	if !isi.Spec.Import || isi.Name != "mysql" || isi.Annotations[imageapi.InsecureRepositoryAnnotation] != "true" {
+		t.Errorf("unexpected import %#v", isi)
+	}
+	if isi.Spec.Repository == nil || isi.Spec.Repository.From.Name != "mysql" {
+		t.Errorf("expected the repository to be imported, got %#v", isi.Spec.Repository)
+	}
+	expected := []imageapi.ImageImportSpec{
+		{
+			From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.5"},
+			To:   &kapi.LocalObjectReference{Name: "old"},
+		},
+		{
+			From:         kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.6"},
+			To:           &kapi.LocalObjectReference{Name: "stable"},
+			ImportPolicy: imageapi.TagImportPolicy{Scheduled: true},
+		},
+	}
+	if !reflect.DeepEqual(isi.Spec.Images, expected) {
+		t.Errorf("expected images %#v, got %#v", expected, isi.Spec.Images)
+	}
+}
+
+func TestNewImageStreamImportPolicy(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Name: "mysql"},
+		Spec: imageapi.ImageStreamSpec{
+			DockerImageRepository: "mysql",
+			Tags: map[string]imageapi.TagReference{
+				"stable": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.6"},
+					ImportPolicy: imageapi.TagImportPolicy{Scheduled: true},
+				},
+			},
+		},
+	}
+
+	isi := newImageStreamImport(stream, imageapi.TagImportPolicy{Insecure: true})
+	if isi.Annotations[imageapi.InsecureRepositoryAnnotation] != "true" {
+		t.Errorf("expected the repository to be imported insecurely, got %#v", isi.Annotations)
+	}
+	expected := imageapi.TagImportPolicy{Scheduled: true, Insecure: true}
+	if len(isi.Spec.Images) != 1 || isi.Spec.Images[0].ImportPolicy != expected {
+		t.Errorf("expected the import policy %#v, got %#v", expected, isi.Spec.Images)
+	}
+}
+
+func TestImportFailures(t *testing.T) {
+	success := unversioned.Status{Status: unversioned.StatusSuccess}
+	isi := &imageapi.ImageStreamImport{
+		Status: imageapi.ImageStreamImportStatus{
+			Repository: &imageapi.RepositoryImportStatus{
+				Status: success,
+				Images: []imageapi.ImageImportStatus{
+					{Tag: "latest", Status: success},
+					{Tag: "5.1", Status: unversioned.Status{Status: unversioned.StatusFailure, Message: "not found"}},
+				},
+			},
+			Images: []imageapi.ImageImportStatus{
+				{Tag: "stable", Status: unversioned.Status{Status: unversioned.StatusFailure, Message: "unreachable"}},
+			},
+		},
+	}
+	expected := []string{"tag 5.1: not found", "tag stable: unreachable"}
+	if failures := importFailures(isi); !reflect.DeepEqual(failures, expected) {
+		t.Errorf("expected %v, got %v", expected, failures)
+	}
+}
+
+func TestAddRepositoryTags(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Name: "app"},
+		Spec: imageapi.ImageStreamSpec{
+			Tags: map[string]imageapi.TagReference{
+				"stable": {From: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "app:1.0"}},
+			},
+		},
+	}
+	success := unversioned.Status{Status: unversioned.StatusSuccess}
+	isi := &imageapi.ImageStreamImport{
+		Spec: imageapi.ImageStreamImportSpec{
+			Repository: &imageapi.RepositoryImportSpec{From: kapi.ObjectReference{Kind: "DockerImage", Name: "registry.example.com/ns/app"}},
+		},
+		Status: imageapi.ImageStreamImportStatus{
+			Repository: &imageapi.RepositoryImportStatus{
+				Status: success,
+				Images: []imageapi.ImageImportStatus{
+					{Tag: "1.0", Status: success, Image: &imageapi.Image{ObjectMeta: kapi.ObjectMeta{Name: "sha256:1"}}},
+					{Tag: "stable", Status: success, Image: &imageapi.Image{ObjectMeta: kapi.ObjectMeta{Name: "sha256:2"}}},
+					{Tag: "broken", Status: unversioned.Status{Status: unversioned.StatusFailure}},
+				},
+			},
+		},
+	}
+
+	policy := imageapi.TagImportPolicy{Scheduled: true}
+	if !addRepositoryTags(stream, isi, policy) {
+		t.Fatalf("expected the stream to change")
+	}
+	expected := map[string]imageapi.TagReference{
+		"1.0": {
+			From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "registry.example.com/ns/app:1.0"},
+			ImportPolicy: policy,
+		},
+		"stable": {From: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "app:1.0"}},
+	}
+	if !reflect.DeepEqual(stream.Spec.Tags, expected) {
+		t.Errorf("expected tags %#v, got %#v", expected, stream.Spec.Tags)
+	}
+	if addRepositoryTags(stream, isi, policy) {
+		t.Errorf("expected the stream not to change again")
+	}
+}
+
+func TestPrintImportSummary(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: "sha256:old"}}},
+				"stable": {Items: []imageapi.TagEvent{{Image: "sha256:new"}}},
+			},
+		},
+	}
+	success := unversioned.Status{Status: unversioned.StatusSuccess}
+	image := &imageapi.Image{ObjectMeta: kapi.ObjectMeta{Name: "sha256:new"}}
+	isi := &imageapi.ImageStreamImport{
+		Status: imageapi.ImageStreamImportStatus{
+			Repository: &imageapi.RepositoryImportStatus{
+				Status: success,
+				Images: []imageapi.ImageImportStatus{
+					{Tag: "latest", Status: success, Image: image},
+					{Tag: "1.0", Status: success, Image: image},
+					{Tag: "2.0", Status: success, Image: image},
+				},
+			},
+			Images: []imageapi.ImageImportStatus{
+				{Tag: "stable", Status: success, Image: image},
+				{Tag: "dev", Status: unversioned.Status{Status: unversioned.StatusFailure}},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	printImportSummary(out, stream, isi)
+	expected := "Created tags: 1.0, 2.0\nUpdated tags: latest\nFailed tags: dev\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
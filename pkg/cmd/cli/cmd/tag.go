@@ -1,21 +1,26 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
 
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	kapi "k8s.io/kubernetes/pkg/api"
 	kerrors "k8s.io/kubernetes/pkg/api/errors"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	kclientcmd "k8s.io/kubernetes/pkg/client/unversioned/clientcmd"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
 	"k8s.io/kubernetes/pkg/util/sets"
 
 	"github.com/openshift/origin/pkg/client"
 	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
+	"github.com/openshift/origin/pkg/dockerregistry"
 	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
@@ -24,9 +29,23 @@ type TagOptions struct {
 	out      io.Writer
 	osClient client.Interface
 
-	deleteTag bool
-	aliasTag  bool
-	namespace string
+	deleteTag       bool
+	aliasTag        bool
+	immutable       bool
+	referencePolicy string
+	scheduled       bool
+	insecure        bool
+	reference       bool
+	namespace       string
+
+	// fromCluster is the name of the context of the cluster of the source,
+	// whose image is copied between the integrated registries of the clusters.
+	fromCluster     string
+	sourceClient    client.Interface
+	sourceNamespace string
+	sourceServer    string
+	sourceRegistry  dockerregistry.Client
+	destRegistry    dockerregistry.Client
 
 	ref            imageapi.DockerImageReference
 	sourceKind     string
@@ -52,6 +71,22 @@ command, but it operates on image streams instead.`
   # Tag an external Docker image.
   $ %[1]s tag --source=docker openshift/origin:latest yourproject/ruby:tip
 
+  # Tag an external Docker image served over HTTP, and re-import it periodically.
+  $ %[1]s tag --source=docker --scheduled --insecure registry.example.com/ruby:2.0 yourproject/ruby:tip
+
+  # Reference an external Docker image as is, without importing it.
+  $ %[1]s tag --source=docker --reference registry.example.com/ruby:2.0 yourproject/ruby:upstream
+
+  # Tag an external Docker image and pull it through the integrated registry.
+  $ %[1]s tag --source=docker --reference-policy=local openshift/origin:latest yourproject/ruby:tip
+
+  # Tag a release image as 'prod', which can't be changed afterwards.
+  $ %[1]s tag --immutable yourproject/ruby:2.0.1 yourproject/ruby:prod
+
+  # Copy the image of the tag 'myproject/app:prod' of the cluster of the 'production' context
+  # of your client configuration to the tag 'yourproject/app:prod' of the current cluster.
+  $ %[1]s tag --from-cluster=production myproject/app:prod yourproject/app:prod
+
   # Remove the specified spec tag from an image stream.
   $ %[1]s tag openshift/origin:latest -d`
 )
@@ -75,6 +110,12 @@ func NewCmdTag(fullName string, f *clientcmd.Factory, out io.Writer) *cobra.Comm
 	cmd.Flags().StringVar(&opts.sourceKind, "source", opts.sourceKind, "Optional hint for the source type; valid values are 'imagestreamtag', 'istag', 'imagestreamimage', 'isimage', and 'docker'")
 	cmd.Flags().BoolVarP(&opts.deleteTag, "delete", "d", opts.deleteTag, "Delete the provided spec tags")
 	cmd.Flags().BoolVar(&opts.aliasTag, "alias", false, "Should the destination tag be updated whenever the source tag changes. Defaults to false.")
+	cmd.Flags().BoolVar(&opts.immutable, "immutable", opts.immutable, "If true, the image of the destination tag can't be replaced once it is set, until the tag is made mutable again by editing the image stream.")
+	cmd.Flags().StringVar(&opts.referencePolicy, "reference-policy", opts.referencePolicy, "How consumers of the destination tag should pull its images; valid values are 'source' and 'local'. Defaults to 'source'.")
+	cmd.Flags().BoolVar(&opts.scheduled, "scheduled", opts.scheduled, "If true, the server periodically re-imports the Docker image of the destination tag from its source.")
+	cmd.Flags().BoolVar(&opts.insecure, "insecure", opts.insecure, "If true, the server may bypass certificate verification or connect directly over HTTP when importing the Docker image of the destination tag.")
+	cmd.Flags().BoolVar(&opts.reference, "reference", opts.reference, "If true, the destination tag references the Docker image of its source as is, without importing it.")
+	cmd.Flags().StringVar(&opts.fromCluster, "from-cluster", opts.fromCluster, "The name of the context of your client configuration of the cluster of SOURCE. The image is copied from the integrated registry of that cluster to the one of the current cluster.")
 
 	return cmd
 }
@@ -142,6 +183,15 @@ func (o *TagOptions) Complete(f *clientcmd.Factory, cmd *cobra.Command, args []s
 		}
 	}
 
+	// Setup the client of the cluster of the source.
+	o.sourceClient = o.osClient
+	o.sourceNamespace = o.namespace
+	if len(o.fromCluster) > 0 && !o.deleteTag {
+		if err := o.completeFromCluster(f); err != nil {
+			return err
+		}
+	}
+
 	// Populate source.
 	if !o.deleteTag {
 		source := args[0]
@@ -168,7 +218,7 @@ func (o *TagOptions) Complete(f *clientcmd.Factory, cmd *cobra.Command, args []s
 				return fmt.Errorf("server in SOURCE is only allowed when providing a Docker image")
 			}
 			if ref.Namespace == imageapi.DockerDefaultNamespace {
-				ref.Namespace = o.namespace
+				ref.Namespace = o.sourceNamespace
 			}
 			if sourceKind == "ImageStreamTag" {
 				if len(ref.Tag) == 0 {
@@ -199,9 +249,9 @@ func (o *TagOptions) Complete(f *clientcmd.Factory, cmd *cobra.Command, args []s
 		if sourceKind == "ImageStreamTag" && !o.aliasTag {
 			srcNamespace := ref.Namespace
 			if len(srcNamespace) == 0 {
-				srcNamespace = o.namespace
+				srcNamespace = o.sourceNamespace
 			}
-			is, err := o.osClient.ImageStreams(srcNamespace).Get(ref.Name)
+			is, err := o.sourceClient.ImageStreams(srcNamespace).Get(ref.Name)
 			if err != nil {
 				return err
 			}
@@ -243,6 +293,43 @@ func (o *TagOptions) Complete(f *clientcmd.Factory, cmd *cobra.Command, args []s
 	return nil
 }
 
+// completeFromCluster sets up the clients of the cluster of the context named
+// by --from-cluster, and the clients of the integrated registries of both
+// clusters. The registries authenticate the users with their tokens.
+func (o *TagOptions) completeFromCluster(f *clientcmd.Factory) error {
+	rawConfig, err := f.OpenShiftClientConfig.RawConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := rawConfig.Contexts[o.fromCluster]; !ok {
+		return fmt.Errorf("the context %q does not exist in your client configuration", o.fromCluster)
+	}
+	sourceConfig := kclientcmd.NewNonInteractiveClientConfig(rawConfig, o.fromCluster, &kclientcmd.ConfigOverrides{})
+	sourceClientConfig, err := sourceConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if o.sourceNamespace, _, err = sourceConfig.Namespace(); err != nil {
+		return err
+	}
+	if o.sourceClient, err = client.New(sourceClientConfig); err != nil {
+		return err
+	}
+	o.sourceServer = sourceClientConfig.Host
+
+	destClientConfig, err := f.OpenShiftClientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if len(sourceClientConfig.BearerToken) == 0 || len(destClientConfig.BearerToken) == 0 {
+		return errors.New("a token is required to copy images between the integrated registries, log in to both clusters with a token")
+	}
+	// the integrated registries read the token from the password
+	o.sourceRegistry = dockerregistry.NewClientWithCredentials(dockerregistry.NewBasicCredentials("unused", sourceClientConfig.BearerToken))
+	o.destRegistry = dockerregistry.NewClientWithCredentials(dockerregistry.NewBasicCredentials("unused", destClientConfig.BearerToken))
+	return nil
+}
+
 // Validate validates all the required options for the tag command.
 func (o TagOptions) Validate() error {
 	// Validate client and writer
@@ -256,6 +343,49 @@ func (o TagOptions) Validate() error {
 	if o.deleteTag && o.aliasTag {
 		return errors.New("--alias and --delete may not both be specified")
 	}
+	if o.deleteTag && len(o.referencePolicy) > 0 {
+		return errors.New("--reference-policy and --delete may not both be specified")
+	}
+	if o.immutable && o.deleteTag {
+		return errors.New("--immutable and --delete may not both be specified")
+	}
+	if o.immutable && o.aliasTag {
+		return errors.New("--immutable and --alias may not both be specified")
+	}
+	if o.scheduled || o.insecure || o.reference {
+		switch {
+		case o.deleteTag:
+			return errors.New("--scheduled, --insecure and --reference may not be specified with --delete")
+		case o.aliasTag:
+			return errors.New("--scheduled, --insecure and --reference may not be specified with --alias")
+		case len(o.fromCluster) > 0:
+			return errors.New("--scheduled, --insecure and --reference may not be specified with --from-cluster")
+		case o.sourceKind != "DockerImage":
+			return errors.New("--scheduled, --insecure and --reference require a Docker image as the source")
+		}
+	}
+	if o.scheduled && o.reference {
+		return errors.New("--scheduled and --reference may not both be specified, a referenced image isn't imported")
+	}
+	switch strings.ToLower(o.referencePolicy) {
+	case "", "source", "local":
+	default:
+		return fmt.Errorf("invalid reference policy %q; valid values are 'source' and 'local'", o.referencePolicy)
+	}
+	if len(o.fromCluster) > 0 {
+		switch {
+		case o.deleteTag:
+			return errors.New("--from-cluster and --delete may not both be specified")
+		case o.aliasTag:
+			return errors.New("--from-cluster and --alias may not both be specified")
+		case len(o.referencePolicy) > 0:
+			return errors.New("--from-cluster and --reference-policy may not both be specified")
+		case o.sourceKind != "ImageStreamImage":
+			return errors.New("--from-cluster requires an image stream tag or image stream image pushed to the integrated registry as the source")
+		case o.sourceClient == nil || o.sourceRegistry == nil || o.destRegistry == nil:
+			return errors.New("the clients of the cluster of the source and of the registries are required")
+		}
+	}
 
 	// Validate source tag based on --delete usage.
 	if o.deleteTag {
@@ -287,6 +417,10 @@ func (o TagOptions) Validate() error {
 
 // RunTag contains all the necessary functionality for the OpenShift cli tag command.
 func (o TagOptions) RunTag() error {
+	if len(o.fromCluster) > 0 {
+		return o.runPromote()
+	}
+
 	for i, destNameAndTag := range o.destNameAndTag {
 		destName, destTag, ok := imageapi.SplitImageStreamTag(destNameAndTag)
 		if !ok {
@@ -346,6 +480,26 @@ func (o TagOptions) RunTag() error {
 				default:
 					targetRef.From.Name = localRef.NameString()
 					targetRef.From.Namespace = o.ref.Namespace
+					// only the tags of external images have an import policy
+					targetRef.ImportPolicy = imageapi.TagImportPolicy{}
+				}
+				switch strings.ToLower(o.referencePolicy) {
+				case "source":
+					targetRef.ReferencePolicy.Type = imageapi.SourceTagReferencePolicy
+				case "local":
+					targetRef.ReferencePolicy.Type = imageapi.LocalTagReferencePolicy
+				}
+				if o.immutable {
+					targetRef.Immutable = true
+				}
+				if o.scheduled {
+					targetRef.ImportPolicy.Scheduled = true
+				}
+				if o.insecure {
+					targetRef.ImportPolicy.Insecure = true
+				}
+				if o.reference {
+					targetRef.ImportPolicy.ImportMode = imageapi.ReferenceTagImportMode
 				}
 
 				sameNamespace := o.namespace == o.destNamespace[i]
@@ -386,3 +540,156 @@ func (o TagOptions) RunTag() error {
 
 	return nil
 }
+
+// runPromote copies the source image from the integrated registry of the
+// cluster of --from-cluster to the integrated registry of the current cluster
+// as each destination tag, and records where the image came from on the tags.
+func (o TagOptions) runPromote() error {
+	namespace := o.ref.Namespace
+	if len(namespace) == 0 {
+		namespace = o.sourceNamespace
+	}
+	stream, err := o.sourceClient.ImageStreams(namespace).Get(o.ref.Name)
+	if err != nil {
+		return err
+	}
+	if len(stream.Status.DockerImageRepository) == 0 {
+		return fmt.Errorf("the image stream %s/%s has no repository in the integrated registry of the cluster of %q", namespace, o.ref.Name, o.fromCluster)
+	}
+	source, err := imageapi.ParseDockerImageReference(stream.Status.DockerImageRepository)
+	if err != nil {
+		return err
+	}
+	// the source may be given by a prefix of the name of the image
+	isi, err := o.sourceClient.ImageStreamImages(namespace).Get(o.ref.Name, o.ref.ID)
+	if err != nil {
+		return err
+	}
+	source.ID = isi.Image.Name
+
+	sourceConn, err := o.sourceRegistry.Connect(source.Registry, stream.Annotations[imageapi.InsecureRepositoryAnnotation] == "true")
+	if err != nil {
+		return err
+	}
+	_, raw, err := sourceConn.ImageManifest(source.Namespace, source.Name, source.ID)
+	if err != nil {
+		return err
+	}
+	signed := &manifest.SignedManifest{}
+	if err := json.Unmarshal(raw, signed); err != nil {
+		return fmt.Errorf("unable to read the manifest of %s: %v", source.Exact(), err)
+	}
+
+	for i, destNameAndTag := range o.destNameAndTag {
+		destName, destTag, ok := imageapi.SplitImageStreamTag(destNameAndTag)
+		if !ok {
+			return fmt.Errorf("%q must be of the form <namespace>/<stream_name>:<tag>", destNameAndTag)
+		}
+		if err := o.promote(sourceConn, source, signed, o.destNamespace[i], destName, destTag); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.out, "Tag %s/%s set to %s from the cluster of %q.\n", o.destNamespace[i], destNameAndTag, source.Exact(), o.fromCluster)
+	}
+	return nil
+}
+
+// promote pushes the layers and the manifest of the source image to the
+// repository of the image stream name, created if needed, in namespace as tag,
+// and records the source on the tag, which is made immutable if requested.
+func (o TagOptions) promote(sourceConn dockerregistry.Connection, source imageapi.DockerImageReference, signed *manifest.SignedManifest, namespace, name, tag string) error {
+	isc := o.osClient.ImageStreams(namespace)
+	target, err := isc.Get(name)
+	if kerrors.IsNotFound(err) {
+		target, err = isc.Create(&imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: name}})
+	}
+	if err != nil {
+		return err
+	}
+	if len(target.Status.DockerImageRepository) == 0 {
+		return fmt.Errorf("the image stream %s/%s has no repository in the integrated registry", namespace, name)
+	}
+	dest, err := imageapi.ParseDockerImageReference(target.Status.DockerImageRepository)
+	if err != nil {
+		return err
+	}
+	destConn, err := o.destRegistry.Connect(dest.Registry, target.Annotations[imageapi.InsecureRepositoryAnnotation] == "true")
+	if err != nil {
+		return err
+	}
+
+	copied := sets.NewString()
+	for _, layer := range signed.FSLayers {
+		dgst := layer.BlobSum.String()
+		if copied.Has(dgst) {
+			continue
+		}
+		exists, err := destConn.ImageLayerExists(dest.Namespace, dest.Name, dgst)
+		if err != nil {
+			return err
+		}
+		if exists {
+			glog.V(4).Infof("Layer %s already exists in %s", dgst, dest.String())
+			copied.Insert(dgst)
+			continue
+		}
+		glog.V(4).Infof("Copying layer %s from %s to %s", dgst, source.Exact(), dest.String())
+		content, size, err := sourceConn.ImageLayer(source.Namespace, source.Name, dgst)
+		if err != nil {
+			return err
+		}
+		err = destConn.PutImageLayer(dest.Namespace, dest.Name, dgst, content, size)
+		content.Close()
+		if err != nil {
+			return err
+		}
+		copied.Insert(dgst)
+	}
+
+	// like docker does on push, the manifest is signed again for the
+	// repository and the tag it is pushed to
+	m := signed.Manifest
+	m.Name = dest.Namespace + "/" + dest.Name
+	m.Tag = tag
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		return err
+	}
+	resigned, err := manifest.Sign(&m, key)
+	if err != nil {
+		return err
+	}
+	if _, err := destConn.PutImageManifest(dest.Namespace, dest.Name, tag, resigned.Raw); err != nil {
+		return err
+	}
+
+	err = kclient.RetryOnConflict(kclient.DefaultRetry, func() error {
+		istag, err := o.osClient.ImageStreamTags(namespace).Get(name, tag)
+		if err != nil {
+			return err
+		}
+		if istag.Annotations == nil {
+			istag.Annotations = make(map[string]string)
+		}
+		istag.Annotations[imageapi.ImagePromotedFromAnnotation] = source.Exact()
+		istag.Annotations[imageapi.ImagePromotedFromClusterAnnotation] = o.sourceServer
+		_, err = o.osClient.ImageStreamTags(namespace).Update(istag)
+		return err
+	})
+	if err != nil || !o.immutable {
+		return err
+	}
+	return kclient.RetryOnConflict(kclient.DefaultRetry, func() error {
+		stream, err := isc.Get(name)
+		if err != nil {
+			return err
+		}
+		if stream.Spec.Tags == nil {
+			stream.Spec.Tags = make(map[string]imageapi.TagReference)
+		}
+		tagRef := stream.Spec.Tags[tag]
+		tagRef.Immutable = true
+		stream.Spec.Tags[tag] = tagRef
+		_, err = isc.Update(stream)
+		return err
+	})
+}
@@ -1,15 +1,26 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	ktc "k8s.io/kubernetes/pkg/client/unversioned/testclient"
 	"k8s.io/kubernetes/pkg/runtime"
 
 	"github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/dockerregistry"
 	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
@@ -88,6 +99,91 @@ func TestRunTag_Add(t *testing.T) {
 	}
 }
 
+func TestRunTag_Immutable(t *testing.T) {
+	client := testclient.NewSimpleFake(testData()[0])
+	opts := &TagOptions{
+		out:      ioutil.Discard,
+		osClient: client,
+		ref: imageapi.DockerImageReference{
+			Namespace: "openshift",
+			Name:      "ruby",
+			Tag:       "2.0",
+		},
+		sourceKind:     "ImageStreamTag",
+		immutable:      true,
+		destNamespace:  []string{"yourproject"},
+		destNameAndTag: []string{"rails:prod"},
+	}
+	if err := opts.RunTag(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := client.Actions()
+	if len(got) != 2 || !got[1].Matches("update", "imagestreams") {
+		t.Fatalf("expected the image stream to be updated, got %#v", got)
+	}
+	stream := got[1].(ktc.UpdateAction).GetObject().(*imageapi.ImageStream)
+	if !stream.Spec.Tags["prod"].Immutable {
+		t.Errorf("expected the tag to be immutable, got %#v", stream.Spec.Tags["prod"])
+	}
+}
+
+func TestRunTag_ImportPolicy(t *testing.T) {
+	client := testclient.NewSimpleFake(testData()[0])
+	opts := &TagOptions{
+		out:      ioutil.Discard,
+		osClient: client,
+		ref: imageapi.DockerImageReference{
+			Registry:  "registry.example.com",
+			Namespace: "openshift",
+			Name:      "ruby",
+			Tag:       "2.0",
+		},
+		sourceKind:     "DockerImage",
+		scheduled:      true,
+		insecure:       true,
+		destNamespace:  []string{"yourproject"},
+		destNameAndTag: []string{"rails:tip"},
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := opts.RunTag(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := client.Actions()
+	if len(got) != 2 || !got[1].Matches("update", "imagestreams") {
+		t.Fatalf("expected the image stream to be updated, got %#v", got)
+	}
+	stream := got[1].(ktc.UpdateAction).GetObject().(*imageapi.ImageStream)
+	if policy := stream.Spec.Tags["tip"].ImportPolicy; !policy.Scheduled || !policy.Insecure {
+		t.Errorf("expected the tag to be scheduled and insecure, got %#v", policy)
+	}
+
+	opts.reference = true
+	if err := opts.Validate(); err == nil {
+		t.Errorf("expected an error for a scheduled reference")
+	}
+	opts.scheduled = false
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := opts.RunTag(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got = client.Actions()
+	stream = got[len(got)-1].(ktc.UpdateAction).GetObject().(*imageapi.ImageStream)
+	if policy := stream.Spec.Tags["tip"].ImportPolicy; policy.ImportMode != imageapi.ReferenceTagImportMode {
+		t.Errorf("expected the tag to reference its source, got %#v", policy)
+	}
+
+	opts.sourceKind = "ImageStreamTag"
+	if err := opts.Validate(); err == nil {
+		t.Errorf("expected an error for an import policy without a Docker image source")
+	}
+}
+
 func TestRunTag_Delete(t *testing.T) {
 	streams := testData()
 	client := testclient.NewSimpleFake(streams[1])
@@ -132,3 +228,212 @@ func TestRunTag_Delete(t *testing.T) {
 		}
 	}
 }
+
+// fakeRegistry is a registry holding layers and manifests by repository.
+type fakeRegistry struct {
+	host      string
+	layers    map[string]string
+	manifests map[string][]byte
+	// fetched are the layers whose content was read
+	fetched []string
+}
+
+func (r *fakeRegistry) Connect(registry string, allowInsecure bool) (dockerregistry.Connection, error) {
+	if registry != r.host {
+		return nil, fmt.Errorf("unexpected registry %s", registry)
+	}
+	return r, nil
+}
+
+func (r *fakeRegistry) ImageTags(namespace, name string) (map[string]string, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (r *fakeRegistry) ImageByID(namespace, name, id string) (*dockerregistry.Image, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (r *fakeRegistry) ImageByTag(namespace, name, tag string) (*dockerregistry.Image, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (r *fakeRegistry) ImageManifest(namespace, name, reference string) (string, []byte, error) {
+	raw, ok := r.manifests[namespace+"/"+name+":"+reference]
+	if !ok {
+		return "", nil, dockerregistry.NewImageNotFoundError(namespace+"/"+name, reference, "")
+	}
+	return reference, raw, nil
+}
+
+func (r *fakeRegistry) ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error) {
+	content, ok := r.layers[namespace+"/"+name+"@"+dgst]
+	if !ok {
+		return nil, 0, dockerregistry.NewImageNotFoundError(namespace+"/"+name, dgst, "")
+	}
+	r.fetched = append(r.fetched, namespace+"/"+name+"@"+dgst)
+	return ioutil.NopCloser(strings.NewReader(content)), int64(len(content)), nil
+}
+
+func (r *fakeRegistry) ImageLayerExists(namespace, name, dgst string) (bool, error) {
+	_, ok := r.layers[namespace+"/"+name+"@"+dgst]
+	return ok, nil
+}
+
+func (r *fakeRegistry) PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("expected %d bytes for layer %s, got %d", size, dgst, len(data))
+	}
+	r.layers[namespace+"/"+name+"@"+dgst] = string(data)
+	return nil
+}
+
+func (r *fakeRegistry) PutImageManifest(namespace, name, reference string, raw []byte) (string, error) {
+	r.manifests[namespace+"/"+name+":"+reference] = raw
+	return "sha256:promoted", nil
+}
+
+func TestRunTag_FromCluster(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      "myproject/app",
+		Tag:       "prod",
+		FSLayers:  []manifest.FSLayer{{BlobSum: digest.Digest("sha256:layer1")}, {BlobSum: digest.Digest("sha256:layer2")}, {BlobSum: digest.Digest("sha256:layer1")}},
+		History:   []manifest.History{{}, {}, {}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceRegistry := &fakeRegistry{
+		host: "source:5000",
+		layers: map[string]string{
+			"myproject/app@sha256:layer1": "layer1",
+			"myproject/app@sha256:layer2": "layer2",
+		},
+		manifests: map[string][]byte{"myproject/app:sha256:4d8f5bfe4a9bfe8bd4e4e2bfb7b5bf1d4a2f5f79a2e8f3f2a2c1b9d2a5ef1c40": signed.Raw},
+	}
+	// the destination has one of the layers already
+	destRegistry := &fakeRegistry{host: "dest:5000", layers: map[string]string{"yourproject/app@sha256:layer2": "layer2"}, manifests: map[string][]byte{}}
+
+	sourceClient := testclient.NewSimpleFake(&imageapi.ImageStream{
+		ObjectMeta: api.ObjectMeta{Name: "app", Namespace: "myproject"},
+		Status:     imageapi.ImageStreamStatus{DockerImageRepository: "source:5000/myproject/app"},
+	})
+	sourceClient.PrependReactor("get", "imagestreamimages", func(action ktc.Action) (handled bool, ret runtime.Object, err error) {
+		return true, &imageapi.ImageStreamImage{Image: imageapi.Image{ObjectMeta: api.ObjectMeta{Name: "sha256:4d8f5bfe4a9bfe8bd4e4e2bfb7b5bf1d4a2f5f79a2e8f3f2a2c1b9d2a5ef1c40"}}}, nil
+	})
+	client := testclient.NewSimpleFake(&imageapi.ImageStream{
+		ObjectMeta: api.ObjectMeta{Name: "app", Namespace: "yourproject"},
+		Status:     imageapi.ImageStreamStatus{DockerImageRepository: "dest:5000/yourproject/app"},
+	})
+	client.PrependReactor("get", "imagestreamtags", func(action ktc.Action) (handled bool, ret runtime.Object, err error) {
+		return true, &imageapi.ImageStreamTag{ObjectMeta: api.ObjectMeta{Name: "app:prod", Namespace: "yourproject"}}, nil
+	})
+	var provenance map[string]string
+	client.PrependReactor("update", "imagestreamtags", func(action ktc.Action) (handled bool, ret runtime.Object, err error) {
+		istag := action.(ktc.UpdateAction).GetObject().(*imageapi.ImageStreamTag)
+		provenance = istag.Annotations
+		return true, istag, nil
+	})
+
+	opts := &TagOptions{
+		out:            ioutil.Discard,
+		osClient:       client,
+		fromCluster:    "production",
+		sourceClient:   sourceClient,
+		sourceServer:   "https://production.example.com:8443",
+		sourceRegistry: sourceRegistry,
+		destRegistry:   destRegistry,
+		ref:            imageapi.DockerImageReference{Namespace: "myproject", Name: "app", ID: "sha256:4d8f5bfe4a9bfe8bd4e4e2bfb7b5bf1d4a2f5f79a2e8f3f2a2c1b9d2a5ef1c40"},
+		sourceKind:     "ImageStreamImage",
+		destNamespace:  []string{"yourproject"},
+		destNameAndTag: []string{"app:prod"},
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.RunTag(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLayers := map[string]string{
+		"yourproject/app@sha256:layer1": "layer1",
+		"yourproject/app@sha256:layer2": "layer2",
+	}
+	if len(destRegistry.layers) != len(expectedLayers) {
+		t.Errorf("expected layers %v, got %v", expectedLayers, destRegistry.layers)
+	}
+	for k, v := range expectedLayers {
+		if destRegistry.layers[k] != v {
+			t.Errorf("expected layers %v, got %v", expectedLayers, destRegistry.layers)
+		}
+	}
+
+	if expected := []string{"myproject/app@sha256:layer1"}; !reflect.DeepEqual(expected, sourceRegistry.fetched) {
+		t.Errorf("expected only the missing layers %v to be fetched, got %v", expected, sourceRegistry.fetched)
+	}
+
+	raw, ok := destRegistry.manifests["yourproject/app:prod"]
+	if !ok {
+		t.Fatalf("expected the manifest to be pushed as yourproject/app:prod, got %v", destRegistry.manifests)
+	}
+	pushed := &manifest.SignedManifest{}
+	if err := json.Unmarshal(raw, pushed); err != nil {
+		t.Fatal(err)
+	}
+	if pushed.Name != "yourproject/app" || pushed.Tag != "prod" || len(pushed.FSLayers) != 3 {
+		t.Errorf("unexpected manifest %s", raw)
+	}
+	if _, err := manifest.Verify(pushed); err != nil {
+		t.Errorf("expected the manifest to be signed again: %v", err)
+	}
+	if bytes.Equal(raw, signed.Raw) {
+		t.Errorf("expected a new manifest for the destination")
+	}
+
+	expectedProvenance := map[string]string{
+		imageapi.ImagePromotedFromAnnotation:        "source:5000/myproject/app@sha256:4d8f5bfe4a9bfe8bd4e4e2bfb7b5bf1d4a2f5f79a2e8f3f2a2c1b9d2a5ef1c40",
+		imageapi.ImagePromotedFromClusterAnnotation: "https://production.example.com:8443",
+	}
+	for k, v := range expectedProvenance {
+		if provenance[k] != v {
+			t.Errorf("expected the annotations of the tag to contain %v, got %v", expectedProvenance, provenance)
+		}
+	}
+}
+
+func TestValidate_FromCluster(t *testing.T) {
+	base := func() TagOptions {
+		return TagOptions{
+			out:            ioutil.Discard,
+			osClient:       testclient.NewSimpleFake(),
+			fromCluster:    "production",
+			sourceClient:   testclient.NewSimpleFake(),
+			sourceRegistry: &fakeRegistry{},
+			destRegistry:   &fakeRegistry{},
+			ref:            imageapi.DockerImageReference{Name: "app", ID: "sha256:4d8f5bfe4a9bfe8bd4e4e2bfb7b5bf1d4a2f5f79a2e8f3f2a2c1b9d2a5ef1c40"},
+			sourceKind:     "ImageStreamImage",
+			destNamespace:  []string{"yourproject"},
+			destNameAndTag: []string{"app:prod"},
+		}
+	}
+	tests := map[string]func(o *TagOptions){
+		"alias":            func(o *TagOptions) { o.aliasTag = true },
+		"reference policy": func(o *TagOptions) { o.referencePolicy = "local" },
+		"docker image":     func(o *TagOptions) { o.sourceKind = "DockerImage" },
+	}
+	for name, change := range tests {
+		opts := base()
+		change(&opts)
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -38,7 +38,10 @@ Possible resources include builds, buildConfigs, services, pods, etc.`
   $ %[1]s get -o json pod 1234-56-7890-234234-456456
 
   # Return only the status value of the specified pod.
-  $ %[1]s get -o template pod 1234-56-7890-234234-456456 --template={{.currentState.status}}`
+  $ %[1]s get -o template pod 1234-56-7890-234234-456456 --template={{.currentState.status}}
+
+  # List the images whose docker image has the label io.k8s.display-name=nodejs.
+  $ %[1]s get images -l io.k8s.display-name=nodejs`
 )
 
 // NewCmdGet is a wrapper for the Kubernetes cli get command
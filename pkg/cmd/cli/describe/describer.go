@@ -43,6 +43,7 @@ func describerMap(c *client.Client, kclient kclient.Interface, host string) map[
 		"ImageStream":          &ImageStreamDescriber{c},
 		"ImageStreamTag":       &ImageStreamTagDescriber{c},
 		"ImageStreamImage":     &ImageStreamImageDescriber{c},
+		"ImageLayer":           &ImageLayerDescriber{c},
 		"Route":                &RouteDescriber{c},
 		"Project":              &ProjectDescriber{c, kclient},
 		"Template":             &TemplateDescriber{c, meta.NewAccessor(), kapi.Scheme, nil},
@@ -434,10 +435,22 @@ func describeImage(image *imageapi.Image, imageName string) (string, error) {
 		formatString(out, "Author", image.DockerImageMetadata.Author)
 		formatString(out, "Arch", image.DockerImageMetadata.Architecture)
 		describeDockerImage(out, image.DockerImageMetadata.Config)
+		describeImageSignatures(out, image.Signatures)
 		return nil
 	})
 }
 
+func describeImageSignatures(out *tabwriter.Writer, signatures []imageapi.ImageSignature) {
+	if len(signatures) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "Signatures:\n")
+	for _, signature := range signatures {
+		_, name, _ := imageapi.SplitImageSignatureName(signature.Name)
+		fmt.Fprintf(out, "  %s\t%s\t%s ago\n", name, signature.Type, formatRelativeTime(signature.CreationTimestamp.Time))
+	}
+}
+
 func describeDockerImage(out *tabwriter.Writer, image *imageapi.DockerConfig) {
 	if image == nil {
 		return
@@ -519,6 +532,36 @@ func (d *ImageStreamImageDescriber) Describe(namespace, name string) (string, er
 	return describeImage(&imageStreamImage.Image, imageStreamImage.Image.Name)
 }
 
+// ImageLayerDescriber generates information about an ImageLayer
+type ImageLayerDescriber struct {
+	client.Interface
+}
+
+// Describe returns the description of an imageLayer
+func (d *ImageLayerDescriber) Describe(namespace, name string) (string, error) {
+	layer, err := d.ImageLayers(namespace).Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	return tabbedString(func(out *tabwriter.Writer) error {
+		formatString(out, "Name", layer.Name)
+		formatString(out, "Namespace", layer.Namespace)
+		if layer.Size > 0 {
+			formatString(out, "Size", units.HumanSize(float64(layer.Size)))
+		} else {
+			formatString(out, "Size", "<unknown>")
+		}
+		// the sharing of the layers is only known to cluster administrators
+		if layer.Shared {
+			formatString(out, "Storage", "shared with other projects")
+		}
+		formatString(out, "Image Streams", strings.Join(layer.ImageStreams, ", "))
+		formatString(out, "Images", strings.Join(layer.Images, ", "))
+		return nil
+	})
+}
+
 // ImageStreamDescriber generates information about a ImageStream
 type ImageStreamDescriber struct {
 	client.Interface
@@ -534,6 +577,9 @@ func (d *ImageStreamDescriber) Describe(namespace, name string) (string, error)
 
 	return tabbedString(func(out *tabwriter.Writer) error {
 		formatMeta(out, imageStream.ObjectMeta)
+		if imageStream.DeletionTimestamp != nil {
+			formatString(out, "Deleted, Removed At", imageStream.DeletionTimestamp.Format(time.RFC1123Z))
+		}
 		formatString(out, "Docker Pull Spec", imageStream.Status.DockerImageRepository)
 		formatImageStreamTags(out, imageStream)
 		return nil
@@ -57,6 +57,8 @@ var DescriberCoverageExceptions = []reflect.Type{
 	reflect.TypeOf(&authorizationapi.ResourceAccessReview{}),
 	reflect.TypeOf(&authorizationapi.LocalSubjectAccessReview{}),
 	reflect.TypeOf(&authorizationapi.LocalResourceAccessReview{}),
+	reflect.TypeOf(&imageapi.ImageStreamImport{}),
+	reflect.TypeOf(&imageapi.ImageSignature{}),
 }
 
 // MissingDescriberCoverageExceptions is the list of types that were missing describer methods when I started
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -190,10 +191,38 @@ func formatImageStreamTags(out *tabwriter.Writer, stream *imageapi.ImageStream)
 					specTag = fmt.Sprintf("<unknown %s> %s", tagRef.From.Kind, namePair)
 				}
 			}
+			if len(tagRef.Track) > 0 {
+				specTag = fmt.Sprintf("tracks %s", tagRef.Track)
+			}
+			if tagRef.Immutable {
+				specTag = strings.TrimSpace(specTag + " (immutable)")
+			}
+			if tagRef.ImportPolicy.Scheduled {
+				specTag = strings.TrimSpace(specTag + " (scheduled)")
+			}
+			if tagRef.ImportPolicy.Insecure {
+				specTag = strings.TrimSpace(specTag + " (insecure)")
+			}
+			if tagRef.ImportPolicy.ImportMode == imageapi.ReferenceTagImportMode {
+				specTag = strings.TrimSpace(specTag + " (reference)")
+			}
 		} else {
 			specTag = "<pushed>"
 		}
-		if taglist, ok := stream.Status.Tags[tag]; ok {
+		taglist := stream.Status.Tags[tag]
+		failed := false
+		if condition := imageapi.TagImportFailure(stream, tag); condition != nil {
+			fmt.Fprintf(out, "%s\t%s\t%s ago\t! import failed %d times, %s: %s\t\n",
+				tag,
+				specTag,
+				units.HumanDuration(timeNowFn().Sub(condition.LastAttemptTime.Time)),
+				condition.Failures,
+				condition.Reason,
+				condition.Message)
+			tag, specTag = "", ""
+			failed = true
+		}
+		if len(taglist.Items) > 0 {
 			for _, event := range taglist.Items {
 				d := timeNowFn().Sub(event.Created.Time)
 				image := event.Image
@@ -216,7 +245,7 @@ func formatImageStreamTags(out *tabwriter.Writer, stream *imageapi.ImageStream)
 					specTag = ""
 				}
 			}
-		} else {
+		} else if !failed {
 			fmt.Fprintf(out, "%s\t%s\t\t<not available>\t<not available>\n", tag, specTag)
 		}
 	}
@@ -2,6 +2,7 @@ package describe
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"text/tabwriter"
 	"time"
@@ -65,6 +66,18 @@ func TestFormatImageStreamTags(t *testing.T) {
 						},
 					},
 				},
+				"failing": {
+					Conditions: []imageapi.TagEventCondition{
+						{
+							Type:            imageapi.ImportSuccess,
+							Status:          kapi.ConditionFalse,
+							Reason:          imageapi.ImportUnauthorizedReason,
+							Message:         "permission denied",
+							Failures:        3,
+							LastAttemptTime: unversioned.Date(2015, 3, 24, 9, 38, 0, 0, time.UTC),
+						},
+					},
+				},
 			},
 		},
 	}
@@ -78,4 +91,7 @@ func TestFormatImageStreamTags(t *testing.T) {
 	out.Flush()
 	actual := string(buf.String())
 	t.Logf("\n%s", actual)
+	if !strings.Contains(actual, "! import failed 3 times, Unauthorized: permission denied") {
+		t.Errorf("expected the failed import of the tag to be described")
+	}
 }
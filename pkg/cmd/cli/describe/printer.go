@@ -9,6 +9,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/docker/docker/pkg/units"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	kctl "k8s.io/kubernetes/pkg/kubectl"
 	"k8s.io/kubernetes/pkg/labels"
@@ -33,6 +34,7 @@ var (
 	imageStreamTagColumns   = []string{"NAME", "DOCKER REF", "UPDATED", "IMAGENAME"}
 	imageStreamImageColumns = []string{"NAME", "DOCKER REF", "UPDATED", "IMAGENAME"}
 	imageStreamColumns      = []string{"NAME", "DOCKER REPO", "TAGS", "UPDATED"}
+	imageLayerColumns       = []string{"NAME", "SIZE", "IMAGES", "IMAGE STREAMS", "SHARED"}
 	projectColumns          = []string{"NAME", "DISPLAY NAME", "STATUS"}
 	routeColumns            = []string{"NAME", "HOST/PORT", "PATH", "SERVICE", "LABELS", "INSECURE POLICY", "TLS TERMINATION"}
 	deploymentColumns       = []string{"NAME", "STATUS", "CAUSE"}
@@ -76,6 +78,8 @@ func NewHumanReadablePrinter(noHeaders, withNamespace, wide bool, showAll bool,
 	p.Handler(imageColumns, printImageList)
 	p.Handler(imageStreamColumns, printImageStream)
 	p.Handler(imageStreamColumns, printImageStreamList)
+	p.Handler(imageLayerColumns, printImageLayer)
+	p.Handler(imageLayerColumns, printImageLayerList)
 	p.Handler(projectColumns, printProject)
 	p.Handler(projectColumns, printProjectList)
 	p.Handler(routeColumns, printRoute)
@@ -347,6 +351,29 @@ func printImageList(images *imageapi.ImageList, w io.Writer, withNamespace, wide
 	return nil
 }
 
+func printImageLayer(layer *imageapi.ImageLayer, w io.Writer, withNamespace, wide, showAll bool, columnLabels []string) error {
+	size := "<unknown>"
+	if layer.Size > 0 {
+		size = units.HumanSize(float64(layer.Size))
+	}
+	if withNamespace {
+		if _, err := fmt.Fprintf(w, "%s\t", layer.Namespace); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%t\n", layer.Name, size, len(layer.Images), strings.Join(layer.ImageStreams, ","), layer.Shared)
+	return err
+}
+
+func printImageLayerList(layers *imageapi.ImageLayerList, w io.Writer, withNamespace, wide, showAll bool, columnLabels []string) error {
+	for _, layer := range layers.Items {
+		if err := printImageLayer(&layer, w, withNamespace, wide, showAll, columnLabels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func printImageStream(stream *imageapi.ImageStream, w io.Writer, withNamespace, wide, showAll bool, columnLabels []string) error {
 	tags := ""
 	const numOfTagsShown = 3
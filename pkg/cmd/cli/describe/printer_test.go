@@ -40,6 +40,8 @@ var PrinterCoverageExceptions = []reflect.Type{
 	reflect.TypeOf(&buildapi.BinaryBuildRequestOptions{}),
 	reflect.TypeOf(&buildapi.BuildRequest{}),
 	reflect.TypeOf(&buildapi.BuildLogOptions{}),
+	reflect.TypeOf(&imageapi.ImageStreamImport{}),
+	reflect.TypeOf(&imageapi.ImageSignature{}),
 }
 
 // MissingPrinterCoverageExceptions is the list of types that were missing printer methods when I started
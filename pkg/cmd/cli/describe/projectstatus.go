@@ -107,9 +107,11 @@ func (d *ProjectStatusDescriber) MakeGraph(namespace string) (osgraph.Graph, set
 	kubeedges.AddAllMountedSecretEdges(g)
 	buildedges.AddAllInputOutputEdges(g)
 	buildedges.AddAllBuildEdges(g)
+	buildedges.AddAllSecretEdges(g)
 	deployedges.AddAllTriggerEdges(g)
 	deployedges.AddAllDeploymentEdges(g)
 	imageedges.AddAllImageStreamRefEdges(g)
+	imageedges.AddAllImageStreamTagSourceEdges(g)
 	routeedges.AddAllRouteEdges(g)
 
 	return g, forbiddenResources, nil
@@ -299,6 +301,7 @@ func getMarkerScanners() []osgraph.MarkerScanner {
 		kubeanalysis.FindMissingSecrets,
 		buildanalysis.FindUnpushableBuildConfigs,
 		buildanalysis.FindCircularBuilds,
+		buildanalysis.FindBuildConfigSecretsMissingKeys,
 		deployanalysis.FindDeploymentConfigTriggerErrors,
 		routeanalysis.FindMissingPortMapping,
 		routeanalysis.FindMissingTLSTerminationType,
@@ -405,7 +408,7 @@ func describeStandaloneBuildGroup(pipeline graphview.ImagePipeline, namespace st
 		}
 		return lines
 	case pipeline.Image != nil:
-		return []string{describeImageTagInPipeline(pipeline.Image, namespace)}
+		return []string{describeImageInPipeline(pipeline, namespace)}
 	default:
 		return []string{"<unknown>"}
 	}
@@ -415,6 +418,8 @@ func describeImageInPipeline(pipeline graphview.ImagePipeline, namespace string)
 	switch {
 	case pipeline.Image != nil && pipeline.Build != nil:
 		return fmt.Sprintf("%s <- %s", describeImageTagInPipeline(pipeline.Image, namespace), describeBuildInPipeline(pipeline.Build.BuildConfig, pipeline.BaseImage))
+	case pipeline.Image != nil && pipeline.ImportedFrom != nil:
+		return fmt.Sprintf("%s <- %s", describeImageTagInPipeline(pipeline.Image, namespace), pipeline.ImportedFrom.ImageSpec())
 	case pipeline.Image != nil:
 		return describeImageTagInPipeline(pipeline.Image, namespace)
 	case pipeline.Build != nil:
@@ -159,6 +159,18 @@ func TestProjectStatus(t *testing.T) {
 				"To see more, use",
 			},
 		},
+		"deployment of an external image tag": {
+			Path: "../../../../pkg/api/graph/test/external-image-tag.yaml",
+			Extra: []runtime.Object{
+				&projectapi.Project{
+					ObjectMeta: kapi.ObjectMeta{Name: "example", Namespace: ""},
+				},
+			},
+			ErrFn: func(err error) bool { return err == nil },
+			Contains: []string{
+				"dc/ruby deploys imagestreamtag/ruby:2.2 <- docker.io/centos/ruby-22-centos7:latest",
+			},
+		},
 		"unpushable build": {
 			Path: "../../../../pkg/api/graph/test/unpushable-build.yaml",
 			Extra: []runtime.Object{
@@ -0,0 +1,181 @@
+package dockerregistry
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/configuration"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	kerrors "k8s.io/kubernetes/pkg/util/errors"
+
+	"github.com/openshift/origin/pkg/dockerregistry/server"
+)
+
+const dockerRegistryLong = `
+Run the OpenShift Docker registry
+
+The registry serves the images of the image streams of OpenShift. It reads the
+configuration file of the Docker registry given as its argument, which must
+configure the openshift repository middleware, and the REGISTRY_* environment
+variables. Both are validated when the registry starts.`
+
+// redactedKeys are the keys of the configuration whose values aren't printed.
+var redactedKeys = []string{"secret", "password", "accesskey", "apikey", "licensekey", "headers"}
+
+// NewCommandDockerRegistry returns the command running the registry.
+func NewCommandDockerRegistry(name string) *cobra.Command {
+	var validateOnly, printConfig bool
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s CONFIG", name),
+		Short: "Run the OpenShift Docker registry",
+		Long:  dockerRegistryLong,
+		Run: func(c *cobra.Command, args []string) {
+			config, err := LoadConfiguration(openConfiguration(args))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if printConfig {
+				if err := PrintConfiguration(os.Stdout, config); err != nil {
+					log.Fatalf("Error printing the configuration: %v", err)
+				}
+			}
+			if validateOnly {
+				return
+			}
+			ExecuteConfiguration(config)
+		},
+	}
+	cmd.Flags().BoolVar(&validateOnly, "validate", false, "Validate the configuration and exit")
+	cmd.Flags().BoolVar(&printConfig, "print-config", false, "Print the effective configuration, without its secrets")
+	cmd.Flags().AddGoFlagSet(flag.CommandLine)
+	return cmd
+}
+
+// NewCommandGC returns the command deleting the blobs that aren't referenced
+// by any image.
+func NewCommandGC(name string) *cobra.Command {
+	var dryRun bool
+	var minAge time.Duration
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s CONFIG", name),
+		Short: "Delete the blobs that aren't referenced by any image",
+		Run: func(c *cobra.Command, args []string) {
+			ExecuteGC(openConfiguration(args), dryRun, minAge)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the blobs that would be deleted without deleting them")
+	cmd.Flags().DurationVar(&minAge, "min-age", time.Hour, "Keep blobs modified more recently than this")
+	return cmd
+}
+
+// NewCommandMigrateStorage returns the command linking the layers of the
+// images to their repositories.
+func NewCommandMigrateStorage(name string) *cobra.Command {
+	var dryRun, removeLegacy bool
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s CONFIG", name),
+		Short: "Link the layers of the images to their repositories",
+		Run: func(c *cobra.Command, args []string) {
+			ExecuteMigrateStorage(openConfiguration(args), dryRun, removeLegacy)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the layers that would be linked without linking them")
+	cmd.Flags().BoolVar(&removeLegacy, "remove-legacy", false, "Remove the tarsum layer links, needed by Docker clients older than 1.6")
+	return cmd
+}
+
+// LoadConfiguration parses the configuration of the registry from configFile,
+// sets the defaults of the options of the openshift repository middleware and
+// validates it with the environment. The error lists every problem found.
+func LoadConfiguration(configFile io.Reader) (*configuration.Configuration, error) {
+	config, err := configuration.Parse(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing configuration file: %s", err)
+	}
+	server.SetRepositoryOptionDefaults(config)
+
+	if err := server.ValidateConfiguration(config); err != nil {
+		messages := []string{}
+		if agg, ok := err.(kerrors.Aggregate); ok {
+			for _, err := range agg.Errors() {
+				messages = append(messages, "  * "+err.Error())
+			}
+		} else {
+			messages = append(messages, "  * "+err.Error())
+		}
+		return nil, fmt.Errorf("Invalid configuration:\n%s", strings.Join(messages, "\n"))
+	}
+	return config, nil
+}
+
+// PrintConfiguration writes config to w as YAML. The values of the keys named
+// by redactedKeys, e.g. the storage credentials, are replaced.
+func PrintConfiguration(w io.Writer, config *configuration.Configuration) error {
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	var values interface{}
+	if err := yaml.Unmarshal(out, &values); err != nil {
+		return err
+	}
+	if out, err = yaml.Marshal(redact(values)); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// redact returns value with the values of the keys named by redactedKeys
+// replaced, at any depth.
+func redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range value {
+			if isRedactedKey(fmt.Sprint(k)) {
+				value[k] = "<redacted>"
+				continue
+			}
+			value[k] = redact(v)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redact(v)
+		}
+	}
+	return value
+}
+
+func isRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, redacted := range redactedKeys {
+		if strings.Contains(key, redacted) {
+			return true
+		}
+	}
+	return false
+}
+
+// openConfiguration opens the configuration file named by the first argument.
+func openConfiguration(args []string) *os.File {
+	if len(args) == 0 || len(args[0]) == 0 {
+		fmt.Println("configuration path unspecified")
+		os.Exit(1)
+	}
+
+	configFile, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("Unable to open configuration file: %s", err)
+	}
+	return configFile
+}
@@ -6,8 +6,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/configuration"
@@ -16,36 +22,116 @@ import (
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/distribution/registry/storage/driver/factory"
 	_ "github.com/docker/distribution/registry/storage/driver/filesystem"
 	_ "github.com/docker/distribution/registry/storage/driver/s3"
 	"github.com/docker/distribution/version"
 	gorillahandlers "github.com/gorilla/handlers"
 	"github.com/openshift/origin/pkg/cmd/server/crypto"
 	"github.com/openshift/origin/pkg/dockerregistry/server"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-// Execute runs the Docker registry.
+// Execute runs the Docker registry with the configuration read from
+// configFile.
 func Execute(configFile io.Reader) {
-	config, err := configuration.Parse(configFile)
+	config, err := LoadConfiguration(configFile)
 	if err != nil {
-		log.Fatalf("Error parsing configuration file: %s", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	ExecuteConfiguration(config)
+}
 
+// ExecuteConfiguration runs the Docker registry with config, validated by
+// LoadConfiguration.
+func ExecuteConfiguration(config *configuration.Configuration) {
 	logLevel, err := log.ParseLevel(string(config.Log.Level))
 	if err != nil {
 		log.Errorf("Error parsing log level %q: %s", config.Log.Level, err)
 		logLevel = log.InfoLevel
 	}
 	log.SetLevel(logLevel)
+	logFormatter, err := server.LogFormatter(config.Log.Formatter)
+	if err != nil {
+		log.Fatalf("Error configuring the log formatter: %v", err)
+	}
+	log.SetFormatter(logFormatter)
+	// kill -USR1 switches to debug logging and back
+	server.ToggleDebugLoggingOnSignal(logLevel, syscall.SIGUSR1)
 
 	log.Infof("version=%s", version.Version)
+
+	masterClientConfig, err := server.MasterClientConfigFrom(config)
+	if err != nil {
+		log.Fatalf("Error configuring the OpenShift client: %v", err)
+	}
+	server.UseMasterClientConfig(masterClientConfig)
+
+	if err := server.ValidateRegistryURL(); err != nil {
+		switch {
+		case server.IsRegistryURLMismatch(err) && os.Getenv("REGISTRY_URL_STRICT") == "true":
+			log.Fatalf("Refusing to start: %v", err)
+		case server.IsRegistryURLMismatch(err):
+			log.Warnf("!!! %v", err)
+		default:
+			log.Warnf("Unable to validate REGISTRY_URL: %v", err)
+		}
+	}
+
 	ctx := context.Background()
+	// the static fields of the log configuration are added to every entry
+	if len(config.Log.Fields) > 0 {
+		var fields []interface{}
+		for field := range config.Log.Fields {
+			fields = append(fields, field)
+		}
+		ctx = context.WithValues(ctx, config.Log.Fields)
+		ctx = context.WithLogger(ctx, context.GetLogger(ctx, fields...))
+	}
+
+	// In token authentication mode, the registry tokens are verified by the
+	// access controller that also gives the repository middleware a client
+	// acting as the user, like the openshift access controller does.
+	tokenAuth := config.Auth.Type() == "token"
+	if tokenAuth {
+		config.Auth = configuration.Auth{server.TokenAccessControllerName: config.Auth.Parameters()}
+	}
 
 	app := handlers.NewApp(ctx, *config)
 
+	// share the layers of the image streams between the replicas when they
+	// already share the upstream layer info cache
+	if cache, ok := config.Storage["cache"]; ok && cache["layerinfo"] == "redis" {
+		server.UseRedisLayerCache(server.NewRedisPool(config))
+		log.Infof("using redis layer cache")
+	}
+
 	// register OpenShift routes
 	// TODO: change this to an anonymous Access record
 	app.RegisterRoute(app.NewRoute().Path("/healthz"), server.HealthzHandler, handlers.NameNotRequired, handlers.NoCustomAccessRecords)
+	app.RegisterRoute(app.NewRoute().Path("/readyz"), server.ReadyzHandler, handlers.NameNotRequired, handlers.NoCustomAccessRecords)
+
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		log.Fatalf("Error creating storage driver: %v", err)
+	}
+	registryClient, err := server.NewRegistryOpenShiftClient()
+	if err != nil {
+		log.Fatalf("Error creating OpenShift client: %v", err)
+	}
+	server.RegisterHealthChecks(driver, registryClient)
+	server.UseLocalRegistry(storage.NewRegistryWithDriver(driver, cache.NewInMemoryLayerInfoCache()))
+
+	repositoryStatsInterval, err := server.RepositoryStatsIntervalFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring the repository stats: %v", err)
+	}
+	if repositoryStatsInterval > 0 {
+		server.UpdateRepositoryStatsEvery(registryClient, repositoryStatsInterval)
+	}
 
 	// TODO add https scheme
 	adminRouter := app.NewRoute().PathPrefix("/admin/").Subrouter()
@@ -72,6 +158,50 @@ func Execute(configFile io.Reader) {
 		pruneAccessRecords,
 	)
 
+	app.RegisterRoute(
+		// GET /admin/blobs?orphaned=true
+		adminRouter.Path("/blobs").Methods("GET"),
+		// handler
+		server.BlobListDispatcher(driver),
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// custom access records
+		pruneAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// POST /admin/prune
+		adminRouter.Path("/prune").Methods("POST"),
+		// handler
+		server.PruneDispatcher(driver),
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// custom access records
+		pruneAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// GET /admin/prune/<id>
+		adminRouter.Path("/prune/{id:[a-f0-9]+}").Methods("GET"),
+		// handler
+		server.PruneJobDispatcher(driver),
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// custom access records
+		pruneAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// GET /admin/<repo>/manifests
+		adminRouter.Path("/{name:"+v2.RepositoryNameRegexp.String()+"}/manifests").Methods("GET"),
+		// handler
+		server.ManifestListDispatcher,
+		// repo name required in url
+		handlers.NameRequired,
+		// custom access records
+		pruneAccessRecords,
+	)
+
 	app.RegisterRoute(
 		// DELETE /admin/<repo>/manifests/<digest>
 		adminRouter.Path("/{name:"+v2.RepositoryNameRegexp.String()+"}/manifests/{digest:"+digest.DigestRegexp.String()+"}").Methods("DELETE"),
@@ -94,13 +224,210 @@ func Execute(configFile io.Reader) {
 		pruneAccessRecords,
 	)
 
-	handler := gorillahandlers.CombinedLoggingHandler(os.Stdout, app)
+	app.RegisterRoute(
+		// POST /admin/cache/rebuild
+		adminRouter.Path("/cache/rebuild").Methods("POST"),
+		// handler
+		server.CacheDispatcher,
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// custom access records
+		pruneAccessRecords,
+	)
 
-	if config.HTTP.TLS.Certificate == "" {
-		context.GetLogger(app).Infof("listening on %v", config.HTTP.Addr)
-		if err := http.ListenAndServe(config.HTTP.Addr, handler); err != nil {
+	app.RegisterRoute(
+		// GET and PUT /admin/loglevel
+		adminRouter.Path("/loglevel").Methods("GET", "PUT"),
+		// handler
+		server.LogLevelDispatcher,
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// custom access records
+		pruneAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// POST /v2/<repo>/blobs/uploads/?mount=<digest>&from=<repo>, rewritten by server.WithBlobMount
+		app.NewRoute().Path(server.BlobMountPathPrefix+"/v2/{name:"+v2.RepositoryNameRegexp.String()+"}/blobs/uploads/").Methods("POST"),
+		// handler
+		server.BlobMountDispatcher,
+		// repo name required in url
+		handlers.NameRequired,
+		// pull access to the source repository
+		server.BlobMountAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// HEAD and conditional GET /v2/<repo>/manifests/<reference>, rewritten by server.WithManifestHead
+		app.NewRoute().Path(server.ManifestHeadPathPrefix+"/v2/{name:"+v2.RepositoryNameRegexp.String()+"}/manifests/{reference:"+v2.TagNameRegexp.String()+"|"+digest.DigestRegexp.String()+"}").Methods("HEAD", "GET"),
+		// handler
+		server.ManifestHeadDispatcher,
+		// repo name required in url
+		handlers.NameRequired,
+		// pull access, derived from the method
+		handlers.NoCustomAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// GET /v2/<repo>/tags/list?n=<n>&last=<tag>, rewritten by server.WithTagsPagination
+		app.NewRoute().Path(server.TagsPathPrefix+"/v2/{name:"+v2.RepositoryNameRegexp.String()+"}/tags/list").Methods("GET"),
+		// handler
+		server.TagsDispatcher,
+		// repo name required in url
+		handlers.NameRequired,
+		// pull access, derived from the method
+		handlers.NoCustomAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// GET /v2/_catalog
+		app.NewRoute().Path(server.CatalogPath).Methods("GET"),
+		// handler
+		server.CatalogDispatcher,
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// any authenticated user, the listing is filtered by project visibility
+		handlers.NoCustomAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// GET /extensions/v2/<repo>/signatures/<digest>
+		app.NewRoute().Path("/extensions/v2/{name:"+v2.RepositoryNameRegexp.String()+"}/signatures/{digest:"+digest.DigestRegexp.String()+"}").Methods("GET"),
+		// handler
+		server.SignatureDispatcher,
+		// repo name required in url
+		handlers.NameRequired,
+		// pull access, derived from the method
+		handlers.NoCustomAccessRecords,
+	)
+
+	app.RegisterRoute(
+		// GET /metrics
+		app.NewRoute().Path("/metrics").Methods("GET"),
+		// handler
+		server.MetricsDispatcher,
+		// repo name not required in url
+		handlers.NameNotRequired,
+		// custom access records
+		server.MetricsAccessRecords,
+	)
+
+	var appHandler http.Handler = server.WithTagsPagination(server.WithManifestHead(server.WithBlobMount(app)))
+
+	// replicas sharing the filesystem storage may each get a part of the
+	// same chunked upload, its requests are serialized with lock files, which
+	// also hold the writes while the storage is pruned. Other storage drivers
+	// only lock the requests of this replica.
+	lockDir := filepath.Join(os.TempDir(), "openshift-registry-locks")
+	if config.Storage.Type() == "filesystem" {
+		rootDirectory := "/tmp/registry/storage"
+		if root, ok := config.Storage.Parameters()["rootdirectory"]; ok {
+			rootDirectory = fmt.Sprint(root)
+		}
+		lockDir = server.UploadLockDir(rootDirectory)
+	}
+	appHandler = server.WithUploadLocks(appHandler, lockDir)
+
+	manifestPolicy, err := server.ManifestPolicyFrom(config)
+	if err != nil {
+		log.Fatalf("Error configuring the manifest policy: %v", err)
+	}
+	// the rest of the policy applies once the push is authorized
+	appHandler = server.WithManifestSizeLimit(appHandler, manifestPolicy.MaxManifestSize)
+
+	sizeLimits, err := server.SizeLimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring size limits: %v", err)
+	}
+	if sizeLimits.MaxLayerSize > 0 {
+		appHandler = server.WithLayerSizeLimit(appHandler, sizeLimits.MaxLayerSize)
+	}
+
+	rateLimits, err := server.RateLimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring rate limits: %v", err)
+	}
+	if rateLimits.Enabled() {
+		appHandler = server.WithRateLimit(appHandler, rateLimits)
+	}
+
+	mirror, err := server.MirrorFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring the mirror: %v", err)
+	}
+	if mirror.Enabled() {
+		log.Infof("The registry mirrors %s, pushes and deletes are rejected", strings.Join(mirror.Upstreams, ", "))
+		appHandler = server.WithMirror(appHandler)
+	}
+
+	if server.ReadOnlyFromEnv() {
+		log.Warnf("The registry is in read-only mode, pushes and deletes are rejected")
+		appHandler = server.WithReadOnly(appHandler)
+	}
+
+	// In token authentication mode, the registry issues its own tokens in
+	// exchange for OpenShift credentials. The endpoint is served outside of the
+	// app, which would otherwise require a token to obtain one.
+	if tokenAuth {
+		tokenHandler, err := server.NewTokenHandler(config.Auth.Parameters())
+		if err != nil {
+			log.Fatalf("Error configuring the token endpoint: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(server.TokenPath, tokenHandler)
+		mux.Handle("/", appHandler)
+		appHandler = mux
+	}
+
+	auditSink, err := server.NewAuditSinkFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring the audit log: %v", err)
+	}
+	if auditSink != nil {
+		appHandler = server.WithAudit(appHandler, auditSink)
+		server.UseDeletionAuditSink(auditSink)
+	}
+
+	// the writes in flight are completed when the registry is stopped
+	drainer := server.NewDrainer()
+	shutdownTimeout, err := server.ShutdownTimeoutFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring the shutdown: %v", err)
+	}
+
+	// the requests made through trusted proxies are logged, limited and
+	// audited by the address of the client
+	trustedProxies, err := server.TrustedProxiesFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring the trusted proxies: %v", err)
+	}
+	proxyProtocol := server.ProxyProtocolFromEnv()
+
+	handler := gorillahandlers.CombinedLoggingHandler(os.Stdout, prometheus.InstrumentHandler("registry", drainer.Handler(appHandler)))
+	handler = server.WithClientIP(handler, trustedProxies)
+
+	var listeners []net.Listener
+
+	// Optionally serve the same handler on a UNIX domain socket, so that the
+	// registry can be fronted by a local proxy without exposing a TCP port.
+	if socketPath := os.Getenv("REGISTRY_HTTP_UNIX_SOCKET"); len(socketPath) != 0 {
+		listener, err := listenUnix(socketPath)
+		if err != nil {
 			context.GetLogger(app).Fatalln(err)
 		}
+		context.GetLogger(app).Infof("listening on unix socket %v", socketPath)
+		listeners = append(listeners, listener)
+	}
+
+	listener, err := net.Listen("tcp", config.HTTP.Addr)
+	if err != nil {
+		context.GetLogger(app).Fatalln(err)
+	}
+	if proxyProtocol {
+		listener = server.NewProxyProtocolListener(listener, trustedProxies)
+	}
+	if config.HTTP.TLS.Certificate == "" {
+		context.GetLogger(app).Infof("listening on %v", config.HTTP.Addr)
 	} else {
 		tlsConf := crypto.SecureTLSConfig(&tls.Config{ClientAuth: tls.NoClientCert})
 
@@ -126,15 +453,71 @@ func Execute(configFile io.Reader) {
 			tlsConf.ClientCAs = pool
 		}
 
-		context.GetLogger(app).Infof("listening on %v, tls", config.HTTP.Addr)
-		server := &http.Server{
-			Addr:      config.HTTP.Addr,
-			Handler:   handler,
-			TLSConfig: tlsConf,
+		cert, err := tls.LoadX509KeyPair(config.HTTP.TLS.Certificate, config.HTTP.TLS.Key)
+		if err != nil {
+			context.GetLogger(app).Fatalln(err)
 		}
+		tlsConf.Certificates = []tls.Certificate{cert}
 
-		if err := server.ListenAndServeTLS(config.HTTP.TLS.Certificate, config.HTTP.TLS.Key); err != nil {
-			context.GetLogger(app).Fatalln(err)
+		context.GetLogger(app).Infof("listening on %v, tls", config.HTTP.Addr)
+		listener = tls.NewListener(listener, tlsConf)
+	}
+	listeners = append(listeners, listener)
+
+	go shutdownOnSignal(app, drainer, shutdownTimeout, listeners)
+
+	for _, listener := range listeners[:len(listeners)-1] {
+		go serve(app, listener, handler, drainer)
+	}
+	serve(app, listeners[len(listeners)-1], handler, drainer)
+	// shutdownOnSignal exits once the writes in flight are done
+	select {}
+}
+
+// serve serves handler on listener until the listener is closed on shutdown.
+func serve(ctx context.Context, listener net.Listener, handler http.Handler, drainer *server.Drainer) {
+	if err := http.Serve(listener, handler); err != nil && !drainer.Draining() {
+		context.GetLogger(ctx).Fatalln(err)
+	}
+}
+
+// shutdownOnSignal stops the registry on SIGTERM or SIGINT, e.g. during a
+// rolling restart: the listeners are closed, the uploads and manifest puts in
+// flight get up to timeout to finish, then the process exits.
+func shutdownOnSignal(ctx context.Context, drainer *server.Drainer, timeout time.Duration, listeners []net.Listener) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+
+	context.GetLogger(ctx).Infof("received %v, waiting up to %v for the writes in flight", sig, timeout)
+	// drain first, so that serve doesn't take the closed listeners for a
+	// failure
+	drainer.Drain()
+	for _, listener := range listeners {
+		listener.Close()
+	}
+	if remaining := drainer.Wait(timeout); remaining > 0 {
+		context.GetLogger(ctx).Warnf("exiting with %d writes in flight, their uploads can be resumed", remaining)
+	} else {
+		context.GetLogger(ctx).Infof("all writes done, exiting")
+	}
+	os.Exit(0)
+}
+
+// listenUnix listens on the UNIX domain socket at path, removing a stale
+// socket left behind by a previous instance.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale socket %s: %v", path, err)
 		}
 	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on unix socket %s: %v", path, err)
+	}
+	return listener, nil
 }
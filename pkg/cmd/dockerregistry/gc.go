@@ -0,0 +1,64 @@
+package dockerregistry
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/dockerregistry/server"
+)
+
+// ExecuteGC deletes the blobs of the storage backend configured in configFile
+// that aren't referenced by any image. The blobs of
+// pushes in progress are only protected by minAge, so it should be run while
+// the registry isn't accepting pushes.
+func ExecuteGC(configFile io.Reader, dryRun bool, minAge time.Duration) {
+	config, err := configuration.Parse(configFile)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %s", err)
+	}
+
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		log.Fatalf("Error creating storage driver: %v", err)
+	}
+
+	masterClientConfig, err := server.MasterClientConfigFrom(config)
+	if err != nil {
+		log.Fatalf("Error configuring the OpenShift client: %v", err)
+	}
+	server.UseMasterClientConfig(masterClientConfig)
+	registryClient, err := server.NewRegistryOpenShiftClient()
+	if err != nil {
+		log.Fatalf("Error creating OpenShift client: %v", err)
+	}
+	images, err := registryClient.Images().List(labels.Everything(), fields.Everything())
+	if err != nil {
+		log.Fatalf("Error listing images: %v", err)
+	}
+
+	result, err := server.GarbageCollect(driver, images.Items, server.GCOptions{
+		MinAge: minAge,
+		DryRun: dryRun,
+		Report: func(dgst digest.Digest, size int64) {
+			fmt.Printf("%s\t%d\n", dgst, size)
+		},
+	})
+	if result != nil {
+		if dryRun {
+			log.Infof("Would delete %d blobs (%d bytes), keeping %d", result.Deleted, result.DeletedBytes, result.Kept)
+		} else {
+			log.Infof("Deleted %d blobs (%d bytes), kept %d", result.Deleted, result.DeletedBytes, result.Kept)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error collecting garbage: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package dockerregistry
+
+import (
+	"fmt"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+
+	"github.com/openshift/origin/pkg/dockerregistry/server"
+)
+
+// ExecuteMigrateStorage links the layers of the storage backend configured in
+// configFile that registries before 2.1 only linked by tarsum, by their
+// canonical digest, so that they aren't orphaned after an upgrade. It prints
+// every layer linked.
+func ExecuteMigrateStorage(configFile io.Reader, dryRun, removeLegacy bool) {
+	config, err := configuration.Parse(configFile)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %s", err)
+	}
+
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		log.Fatalf("Error creating storage driver: %v", err)
+	}
+
+	result, err := server.MigrateStorage(driver, server.MigrateOptions{
+		DryRun:       dryRun,
+		RemoveLegacy: removeLegacy,
+		Report: func(repository string, dgst digest.Digest) {
+			fmt.Printf("%s\t%s\n", repository, dgst)
+		},
+	})
+	if result != nil {
+		if dryRun {
+			log.Infof("Would link %d layers and remove %d tarsum links, %d blobs are missing", result.Linked, result.Removed, result.Missing)
+		} else {
+			log.Infof("Linked %d layers and removed %d tarsum links, %d blobs are missing", result.Linked, result.Removed, result.Missing)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error migrating the storage: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package imagepruner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"k8s.io/kubernetes/pkg/healthz"
+	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	kutil "k8s.io/kubernetes/pkg/util"
+
+	"github.com/openshift/origin/pkg/cmd/admin/prune"
+	"github.com/openshift/origin/pkg/cmd/util"
+	"github.com/openshift/origin/pkg/cmd/util/clientcmd"
+	imageprune "github.com/openshift/origin/pkg/image/prune"
+	"github.com/openshift/origin/pkg/version"
+)
+
+const imagePrunerLong = `
+Start an image pruner
+
+This command prunes the images of the cluster and their data in the integrated registry once per
+--interval, using the same policy as 'oadm prune images': images younger than --keep-younger-than,
+the --keep-tag-revisions most recent images of each tag, and the images referenced by pods,
+replication controllers, builds, build configs or deployment configs are kept. With
+--orphaned-blobs, the blobs of the registry that no image references are deleted as well.
+
+The pruner only reports what it would delete when it is paused with --paused or the
+IMAGE_PRUNER_PAUSED environment variable. Its progress is exposed as Prometheus metrics on
+/metrics at the --listen address.`
+
+// ImagePrunerOptions are the options of the image pruner.
+type ImagePrunerOptions struct {
+	Config *clientcmd.Config
+
+	Interval         time.Duration
+	KeepYoungerThan  time.Duration
+	KeepTagRevisions int
+	Workers          int
+	OrphanedBlobs    bool
+	Paused           bool
+
+	CABundle            string
+	RegistryUrlOverride string
+	ListenAddr          string
+}
+
+// NewCommandImagePruner provides a CLI handler for the image pruner.
+func NewCommandImagePruner(name string) *cobra.Command {
+	options := &ImagePrunerOptions{
+		Config:           clientcmd.NewConfig(),
+		Interval:         time.Hour,
+		KeepYoungerThan:  60 * time.Minute,
+		KeepTagRevisions: 3,
+		Workers:          5,
+		ListenAddr:       ":8080",
+	}
+	options.Config.FromFile = true
+	paused, _ := strconv.ParseBool(util.Env("IMAGE_PRUNER_PAUSED", "false"))
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s%s", name, clientcmd.ConfigSyntax),
+		Short: "Start an image pruner",
+		Long:  imagePrunerLong,
+		Run: func(c *cobra.Command, args []string) {
+			cmdutil.CheckErr(options.Validate())
+			cmdutil.CheckErr(options.Run())
+		},
+	}
+
+	cmd.AddCommand(version.NewVersionCommand(name, false))
+
+	flag := cmd.Flags()
+	options.Config.Bind(flag)
+	flag.DurationVar(&options.Interval, "interval", options.Interval, "The time between the start of two pruning runs.")
+	flag.DurationVar(&options.KeepYoungerThan, "keep-younger-than", options.KeepYoungerThan, "Specify the minimum age of an image for it to be considered a candidate for pruning.")
+	flag.IntVar(&options.KeepTagRevisions, "keep-tag-revisions", options.KeepTagRevisions, "Specify the number of image revisions for a tag in an image stream that will be preserved.")
+	flag.IntVar(&options.Workers, "workers", options.Workers, "Specify the number of deletions of layers, blobs and manifests sent to the registry in parallel.")
+	flag.BoolVar(&options.OrphanedBlobs, "orphaned-blobs", options.OrphanedBlobs, "Also delete the blobs stored by the registry which no image references, e.g. the layers of failed pushes.")
+	flag.BoolVar(&options.Paused, "paused", paused, "Only report what would be pruned, without deleting anything. Defaults to the value of the IMAGE_PRUNER_PAUSED environment variable.")
+	flag.StringVar(&options.CABundle, "registry-certificate-authority", options.CABundle, "The path to a certificate authority bundle to use when communicating with the managed Docker registries, in addition to the one of the master.")
+	flag.StringVar(&options.RegistryUrlOverride, "registry-url", options.RegistryUrlOverride, "The address to use when contacting the registry, instead of the one of the docker-registry service.")
+	flag.StringVar(&options.ListenAddr, "listen", options.ListenAddr, "The address to serve the metrics and the health check on.")
+
+	return cmd
+}
+
+// Validate validates the options of the image pruner.
+func (o *ImagePrunerOptions) Validate() error {
+	if o.Interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if o.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	if len(o.ListenAddr) == 0 {
+		return errors.New("--listen must be specified")
+	}
+	return nil
+}
+
+// Run prunes the images periodically. It never exits.
+func (o *ImagePrunerOptions) Run() error {
+	osClient, kClient, err := o.Config.Clients()
+	if err != nil {
+		return err
+	}
+	clientConfig := o.Config.OpenShiftConfig()
+	if len(clientConfig.BearerToken) == 0 {
+		return errors.New("the image pruner must use a client config with a token")
+	}
+	registryClient, err := prune.NewRegistryClient(clientConfig, o.CABundle)
+	if err != nil {
+		return err
+	}
+
+	pruner := &imageprune.ContinuousPruner{
+		NewPruner: func(paused bool) (imageprune.ImageRegistryPruner, error) {
+			options := imageprune.ImageRegistryPrunerOptions{
+				KeepYoungerThan:  o.KeepYoungerThan,
+				KeepTagRevisions: o.KeepTagRevisions,
+				DryRun:           paused,
+				RegistryClient:   registryClient,
+				RegistryURL:      o.RegistryUrlOverride,
+				Workers:          o.Workers,
+			}
+			if err := options.ListObjects(osClient, kClient); err != nil {
+				return nil, err
+			}
+			if o.OrphanedBlobs {
+				options.OrphanedBlobPruner = imageprune.NewRegistryOrphanedBlobPruner()
+			}
+			return imageprune.NewImageRegistryPruner(options), nil
+		},
+		Interval: o.Interval,
+		Paused:   o.Paused,
+
+		ImagePruner:       imageprune.NewDeletingImagePruner(osClient.Images()),
+		ImageStreamPruner: imageprune.NewDeletingImageStreamPruner(osClient),
+		LayerPruner:       imageprune.NewDeletingLayerPruner(),
+		BlobPruner:        imageprune.NewDeletingBlobPruner(),
+		ManifestPruner:    imageprune.NewDeletingManifestPruner(),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.UninstrumentedHandler())
+	healthz.InstallHandler(mux)
+	go func() {
+		glog.Fatal(http.ListenAndServe(o.ListenAddr, mux))
+	}()
+
+	if o.Paused {
+		glog.Infof("Image pruner started paused, pruning every %s", o.Interval)
+	} else {
+		glog.Infof("Image pruner started, pruning every %s", o.Interval)
+	}
+	pruner.Run(kutil.NeverStop)
+	return nil
+}
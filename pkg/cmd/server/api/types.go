@@ -230,6 +230,9 @@ type MasterConfig struct {
 	// ImageConfig holds options that describe how to build image names for system components
 	ImageConfig ImageConfig
 
+	// ImagePolicyConfig controls how image streams and their images are managed
+	ImagePolicyConfig ImagePolicyConfig
+
 	// PolicyConfig holds information about where to locate critical pieces of bootstrapping policy
 	PolicyConfig PolicyConfig
 
@@ -259,6 +262,31 @@ type ProjectConfig struct {
 	SecurityAllocator *SecurityAllocator
 }
 
+// ImagePolicyConfig holds the options controlling how image streams and their images are managed
+type ImagePolicyConfig struct {
+	// DeletedImageStreamGracePeriodSeconds is the number of seconds a deleted image stream is retained
+	// before it is removed, along with the references it holds to its images, which keep them and their
+	// content in the registry from being pruned. Until then the deletion can be undone with
+	// "oadm restore imagestream". 0, the default, removes deleted image streams immediately.
+	DeletedImageStreamGracePeriodSeconds int64
+	// MaxImageManifestSizeBytes is the maximum size in bytes of the manifest of an image mapped into an
+	// image stream. Larger manifests are rejected. 0, the default, doesn't limit the size.
+	MaxImageManifestSizeBytes int64
+	// ImageStreamStorageUsageIntervalSeconds is the number of seconds between the reports of the storage
+	// the image streams use in the integrated registry, in their openshift.io/image.storageSize annotation.
+	// 0, the default, disables the reports.
+	ImageStreamStorageUsageIntervalSeconds int64
+	// AllowedRegistries lists the registries from which images may be imported into image streams
+	// and run in pods. A registry is a host with an optional port, such as "registry.example.com:5000",
+	// and may start with a "*." wildcard matching any subdomain, such as "*.example.com". A registry
+	// without a port matches any port, "docker.io" matches the Docker Hub. If empty, the default, all
+	// the registries that aren't blocked are allowed.
+	AllowedRegistries []string
+	// BlockedRegistries lists the registries from which images may never be imported or run, even if
+	// they are allowed by AllowedRegistries. Registries are matched as in AllowedRegistries.
+	BlockedRegistries []string
+}
+
 type RoutingConfig struct {
 	// Subdomain is the suffix appended to $service.$namespace. to form the default route hostname
 	Subdomain string
@@ -117,6 +117,12 @@ etcdStorageConfig:
 imageConfig:
   format: ""
   latest: false
+imagePolicyConfig:
+  allowedRegistries: null
+  blockedRegistries: null
+  deletedImageStreamGracePeriodSeconds: 0
+  imageStreamStorageUsageIntervalSeconds: 0
+  maxImageManifestSizeBytes: 0
 kind: MasterConfig
 kubeletClientInfo:
   ca: ""
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -167,6 +168,8 @@ func ValidateMasterConfig(config *api.MasterConfig) ValidationResults {
 
 	validationResults.Append(ValidateProjectConfig(config.ProjectConfig).Prefix("projectConfig"))
 
+	validationResults.AddErrors(ValidateImagePolicyConfig(config.ImagePolicyConfig).Prefix("imagePolicyConfig")...)
+
 	validationResults.AddErrors(ValidateRoutingConfig(config.RoutingConfig).Prefix("routingConfig")...)
 
 	validationResults.Append(ValidateAPILevels(config.APILevels, api.KnownOpenShiftAPILevels, api.DeadOpenShiftAPILevels, "apiLevels"))
@@ -504,6 +507,46 @@ func ValidateProjectConfig(config api.ProjectConfig) ValidationResults {
 	return validationResults
 }
 
+func ValidateImagePolicyConfig(config api.ImagePolicyConfig) fielderrors.ValidationErrorList {
+	allErrs := fielderrors.ValidationErrorList{}
+
+	if config.DeletedImageStreamGracePeriodSeconds < 0 {
+		allErrs = append(allErrs, fielderrors.NewFieldInvalid("deletedImageStreamGracePeriodSeconds", config.DeletedImageStreamGracePeriodSeconds, "must be greater than or equal to 0"))
+	}
+	if config.MaxImageManifestSizeBytes < 0 {
+		allErrs = append(allErrs, fielderrors.NewFieldInvalid("maxImageManifestSizeBytes", config.MaxImageManifestSizeBytes, "must be greater than or equal to 0"))
+	}
+	if config.ImageStreamStorageUsageIntervalSeconds < 0 {
+		allErrs = append(allErrs, fielderrors.NewFieldInvalid("imageStreamStorageUsageIntervalSeconds", config.ImageStreamStorageUsageIntervalSeconds, "must be greater than or equal to 0"))
+	}
+	for i, registry := range config.AllowedRegistries {
+		if !validRegistryPattern(registry) {
+			allErrs = append(allErrs, fielderrors.NewFieldInvalid(fmt.Sprintf("allowedRegistries[%d]", i), registry, "must be a host with an optional port, which may start with \"*.\""))
+		}
+	}
+	for i, registry := range config.BlockedRegistries {
+		if !validRegistryPattern(registry) {
+			allErrs = append(allErrs, fielderrors.NewFieldInvalid(fmt.Sprintf("blockedRegistries[%d]", i), registry, "must be a host with an optional port, which may start with \"*.\""))
+		}
+	}
+
+	return allErrs
+}
+
+// validRegistryPattern returns true if pattern is a host with an optional
+// port, whose host may start with a "*." wildcard.
+func validRegistryPattern(pattern string) bool {
+	host := strings.TrimPrefix(pattern, "*.")
+	if i := strings.LastIndex(host, ":"); i != -1 {
+		port, err := strconv.Atoi(host[i+1:])
+		if err != nil || !kuval.IsValidPortNum(port) {
+			return false
+		}
+		host = host[:i]
+	}
+	return kuval.IsDNS1123Subdomain(host)
+}
+
 func ValidateRoutingConfig(config api.RoutingConfig) fielderrors.ValidationErrorList {
 	allErrs := fielderrors.ValidationErrorList{}
 
@@ -27,6 +27,9 @@ const (
 
 	InfraPersistentVolumeControllerServiceAccountName = "pv-controller"
 	PersistentVolumeControllerRoleName                = "system:pv-controller"
+
+	InfraImageFinalizerControllerServiceAccountName = "image-finalizer-controller"
+	ImageFinalizerControllerRoleName                = "system:image-finalizer-controller"
 )
 
 type InfraServiceAccounts struct {
@@ -345,4 +348,40 @@ func init() {
 		panic(err)
 	}
 
+	err = InfraSAs.addServiceAccount(
+		InfraImageFinalizerControllerServiceAccountName,
+		authorizationapi.ClusterRole{
+			ObjectMeta: kapi.ObjectMeta{
+				Name: ImageFinalizerControllerRoleName,
+			},
+			Rules: []authorizationapi.PolicyRule{
+				// ImageFinalizerController.Run() -> ListWatch
+				// ImageFinalizerController.removeFromRegistry() lists the images sharing layers
+				{
+					Verbs:     sets.NewString("list", "watch"),
+					Resources: sets.NewString("images"),
+				},
+				// ImageFinalizerController.Next() deletes the finalized image,
+				// the registry requires the delete verb on images for the admin API
+				{
+					Verbs:     sets.NewString("delete"),
+					Resources: sets.NewString("images"),
+				},
+				// ImageFinalizerController.Next()
+				{
+					Verbs:     sets.NewString("update"),
+					Resources: sets.NewString("images/finalize"),
+				},
+				// ImageFinalizerController.removeFromRegistry() finds the repositories of the image
+				{
+					Verbs:     sets.NewString("list"),
+					Resources: sets.NewString("imagestreams"),
+				},
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
 }
@@ -83,6 +83,11 @@ func GetBootstrapClusterRoles() []authorizationapi.ClusterRole {
 					Verbs:     sets.NewString("get"),
 					Resources: sets.NewString(authorizationapi.NodeMetricsResource),
 				},
+				// Allow read access to registry metrics
+				{
+					Verbs:     sets.NewString("get"),
+					Resources: sets.NewString(authorizationapi.RegistryMetricsResource),
+				},
 				// Allow read access to stats
 				// Node stats requests are submitted as POSTs.  These creates are non-mutating
 				{
@@ -262,6 +267,21 @@ func GetBootstrapClusterRoles() []authorizationapi.ClusterRole {
 				},
 			},
 		},
+		{
+			ObjectMeta: kapi.ObjectMeta{
+				Name: ImageSignerRoleName,
+			},
+			Rules: []authorizationapi.PolicyRule{
+				{
+					Verbs:     sets.NewString("get"),
+					Resources: sets.NewString("images"),
+				},
+				{
+					Verbs:     sets.NewString("create", "delete"),
+					Resources: sets.NewString("imagesignatures"),
+				},
+			},
+		},
 		{
 			ObjectMeta: kapi.ObjectMeta{
 				Name: DeployerRoleName,
@@ -332,7 +352,7 @@ func GetBootstrapClusterRoles() []authorizationapi.ClusterRole {
 			},
 			Rules: []authorizationapi.PolicyRule{
 				{
-					Verbs:     sets.NewString("get", "delete"),
+					Verbs:     sets.NewString("get", "list", "delete"),
 					Resources: sets.NewString("images"),
 				},
 				{
@@ -343,10 +363,43 @@ func GetBootstrapClusterRoles() []authorizationapi.ClusterRole {
 					Verbs:     sets.NewString("update"),
 					Resources: sets.NewString("imagestreams"),
 				},
+				{
+					// this is used to remove deleted manifests from the tag history in pkg/dockerregistry/server/admin.go
+					Verbs:     sets.NewString("update"),
+					Resources: sets.NewString("imagestreams/status"),
+				},
 				{
 					Verbs:     sets.NewString("create"),
 					Resources: sets.NewString("imagestreammappings"),
 				},
+				{
+					Verbs:     sets.NewString("get"),
+					Resources: sets.NewString("services"),
+				},
+				{
+					Verbs:     sets.NewString("list"),
+					Resources: sets.NewString("routes"),
+				},
+				{
+					// this is used to enforce image quota when pushing in pkg/dockerregistry/server/quota.go
+					Verbs:     sets.NewString("list"),
+					Resources: sets.NewString("imagestreams", "resourcequotas", "limitranges"),
+				},
+				{
+					// this is used to record pushes on image streams in pkg/dockerregistry/server/events.go
+					Verbs:     sets.NewString("create"),
+					Resources: sets.NewString("events"),
+				},
+				{
+					// this is used to allow anonymous pulls from public projects in pkg/dockerregistry/server/auth.go
+					Verbs:     sets.NewString("create"),
+					Resources: sets.NewString("localsubjectaccessreviews"),
+				},
+				{
+					// this is used to authenticate to remote registries when pulling through in pkg/dockerregistry/server/pullthrough.go
+					Verbs:     sets.NewString("get"),
+					Resources: sets.NewString("imagestreams/secrets"),
+				},
 			},
 		},
 		{
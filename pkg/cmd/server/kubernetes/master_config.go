@@ -29,10 +29,12 @@ import (
 	configapi "github.com/openshift/origin/pkg/cmd/server/api"
 	"github.com/openshift/origin/pkg/cmd/server/etcd"
 	cmdflags "github.com/openshift/origin/pkg/cmd/util/flags"
+	imageadmission "github.com/openshift/origin/pkg/image/admission"
+	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
 // AdmissionPlugins is the full list of admission control plugins to enable in the order they must run
-var AdmissionPlugins = []string{"NamespaceLifecycle", "OriginPodNodeEnvironment", "LimitRanger", "ServiceAccount", "SecurityContextConstraint", "ResourceQuota", "SCCExecRestrictions"}
+var AdmissionPlugins = []string{"NamespaceLifecycle", "OriginPodNodeEnvironment", "LimitRanger", "ServiceAccount", "SecurityContextConstraint", imageadmission.RegistryPolicyPluginName, "ResourceQuota", "SCCExecRestrictions"}
 
 // MasterConfig defines the required values to start a Kubernetes master
 type MasterConfig struct {
@@ -123,6 +125,13 @@ func BuildKubernetesMasterConfig(options configapi.MasterConfig, requestContextM
 			saAdmitter.Run()
 			plugins = append(plugins, saAdmitter)
 
+		case imageadmission.RegistryPolicyPluginName:
+			// the registry policy is part of the image policy of the master, so create that one by hand
+			plugins = append(plugins, imageadmission.NewRegistryPolicy(imageapi.RegistryPolicy{
+				Allowed: options.ImagePolicyConfig.AllowedRegistries,
+				Blocked: options.ImagePolicyConfig.BlockedRegistries,
+			}))
+
 		default:
 			plugin := admission.InitPlugin(pluginName, kubeClient, server.AdmissionControlConfigFile)
 			if plugin != nil {
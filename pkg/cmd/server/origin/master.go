@@ -17,6 +17,7 @@ import (
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/rest"
 	"k8s.io/kubernetes/pkg/apiserver"
+	"k8s.io/kubernetes/pkg/client/record"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 	kmaster "k8s.io/kubernetes/pkg/master"
 	"k8s.io/kubernetes/pkg/util"
@@ -42,11 +43,16 @@ import (
 	deployconfigetcd "github.com/openshift/origin/pkg/deploy/registry/deployconfig/etcd"
 	deploylogregistry "github.com/openshift/origin/pkg/deploy/registry/deploylog"
 	deployrollback "github.com/openshift/origin/pkg/deploy/registry/rollback"
+	"github.com/openshift/origin/pkg/dockerregistry"
 	"github.com/openshift/origin/pkg/image/registry/image"
 	imageetcd "github.com/openshift/origin/pkg/image/registry/image/etcd"
+	"github.com/openshift/origin/pkg/image/registry/imagelayer"
+	"github.com/openshift/origin/pkg/image/registry/imagesecret"
+	"github.com/openshift/origin/pkg/image/registry/imagesignature"
 	"github.com/openshift/origin/pkg/image/registry/imagestream"
 	imagestreametcd "github.com/openshift/origin/pkg/image/registry/imagestream/etcd"
 	"github.com/openshift/origin/pkg/image/registry/imagestreamimage"
+	"github.com/openshift/origin/pkg/image/registry/imagestreamimport"
 	"github.com/openshift/origin/pkg/image/registry/imagestreammapping"
 	"github.com/openshift/origin/pkg/image/registry/imagestreamtag"
 	accesstokenetcd "github.com/openshift/origin/pkg/oauth/registry/oauthaccesstoken/etcd"
@@ -314,12 +320,7 @@ func (c *MasterConfig) InstallProtectedAPI(container *restful.Container) []strin
 }
 
 func (c *MasterConfig) GetRestStorage() map[string]rest.Storage {
-	defaultRegistry := env("OPENSHIFT_DEFAULT_REGISTRY", "${DOCKER_REGISTRY_SERVICE_HOST}:${DOCKER_REGISTRY_SERVICE_PORT}")
-	svcCache := service.NewServiceResolverCache(c.KubeClient().Services(kapi.NamespaceDefault).Get)
-	defaultRegistryFunc, err := svcCache.Defer(defaultRegistry)
-	if err != nil {
-		glog.Fatalf("OPENSHIFT_DEFAULT_REGISTRY variable is invalid %q: %v", defaultRegistry, err)
-	}
+	defaultRegistryFunc := c.defaultRegistryFunc()
 
 	kubeletClient, err := kclient.NewKubeletClient(c.KubeletClientConfig)
 	if err != nil {
@@ -370,15 +371,23 @@ func (c *MasterConfig) GetRestStorage() map[string]rest.Storage {
 	resourceAccessReviewRegistry := resourceaccessreview.NewRegistry(resourceAccessReviewStorage)
 	localResourceAccessReviewStorage := localresourceaccessreview.NewREST(resourceAccessReviewRegistry)
 
-	imageStorage := imageetcd.NewREST(c.EtcdHelper)
+	imageStorage, imageFinalizeStorage := imageetcd.NewREST(c.EtcdHelper)
 	imageRegistry := image.NewRegistry(imageStorage)
-	imageStreamStorage, imageStreamStatusStorage, internalImageStreamStorage := imagestreametcd.NewREST(c.EtcdHelper, imagestream.DefaultRegistryFunc(defaultRegistryFunc), subjectAccessReviewRegistry)
+	imageStreamEventBroadcaster := record.NewBroadcaster()
+	imageStreamEventBroadcaster.StartRecordingToSink(c.PrivilegedLoopbackKubernetesClient.Events(""))
+	imageStreamEventRecorder := imageStreamEventBroadcaster.NewRecorder(kapi.EventSource{Component: "imagestream-registry"})
+	imageStreamStorage, imageStreamStatusStorage, internalImageStreamStorage, imageStreamRestoreStorage := imagestreametcd.NewREST(c.EtcdHelper, imagestream.DefaultRegistryFunc(defaultRegistryFunc), subjectAccessReviewRegistry, c.Options.ImagePolicyConfig.DeletedImageStreamGracePeriodSeconds, imageStreamEventRecorder)
 	imageStreamRegistry := imagestream.NewRegistry(imageStreamStorage, imageStreamStatusStorage, internalImageStreamStorage)
-	imageStreamMappingStorage := imagestreammapping.NewREST(imageRegistry, imageStreamRegistry)
+	imageStreamMappingStorage := imagestreammapping.NewREST(imageRegistry, imageStreamRegistry, c.Options.ImagePolicyConfig.MaxImageManifestSizeBytes)
 	imageStreamTagStorage := imagestreamtag.NewREST(imageRegistry, imageStreamRegistry)
 	imageStreamTagRegistry := imagestreamtag.NewRegistry(imageStreamTagStorage)
 	imageStreamImageStorage := imagestreamimage.NewREST(imageRegistry, imageStreamRegistry)
 	imageStreamImageRegistry := imagestreamimage.NewRegistry(imageStreamImageStorage)
+	imageLayerStorage := imagelayer.NewREST(imageRegistry, imageStreamRegistry, subjectAccessReviewRegistry)
+	imageStreamImportStorage := imagestreamimport.NewREST(imageRegistry, imageStreamRegistry, c.ImageImportSecretsClient(), c.KubeClient(), c.KubeClient(), func(credentials dockerregistry.Credentials) dockerregistry.Client {
+		return dockerregistry.NewRestrictedClient(dockerregistry.NewClientWithCredentials(credentials), c.RegistryPolicy())
+	})
+	imageSignatureStorage := imagesignature.NewREST(imageRegistry)
 
 	buildGenerator := &buildgenerator.BuildGenerator{
 		Client: buildgenerator.Client{
@@ -430,12 +439,18 @@ func (c *MasterConfig) GetRestStorage() map[string]rest.Storage {
 	)
 
 	storage := map[string]rest.Storage{
-		"images":              imageStorage,
-		"imageStreams":        imageStreamStorage,
-		"imageStreams/status": imageStreamStatusStorage,
-		"imageStreamImages":   imageStreamImageStorage,
-		"imageStreamMappings": imageStreamMappingStorage,
-		"imageStreamTags":     imageStreamTagStorage,
+		"images":               imageStorage,
+		"images/finalize":      imageFinalizeStorage,
+		"imageStreams":         imageStreamStorage,
+		"imageStreams/status":  imageStreamStatusStorage,
+		"imageStreams/restore": imageStreamRestoreStorage,
+		"imageStreams/secrets": imagesecret.NewREST(c.ImageImportSecretsClient()),
+		"imageSignatures":      imageSignatureStorage,
+		"imageStreamImages":    imageStreamImageStorage,
+		"imageStreamImports":   imageStreamImportStorage,
+		"imageLayers":          imageLayerStorage,
+		"imageStreamMappings":  imageStreamMappingStorage,
+		"imageStreamTags":      imageStreamTagStorage,
 
 		"deploymentConfigs":         deployConfigStorage.DeploymentConfig,
 		"deploymentConfigs/scale":   deployConfigStorage.Scale,
@@ -624,6 +639,18 @@ func (c *MasterConfig) RouteAllocator() *routeallocationcontroller.RouteAllocati
 }
 
 // env returns an environment variable, or the defaultValue if it is not set.
+// defaultRegistryFunc returns the function resolving the address of the
+// integrated registry, from OPENSHIFT_DEFAULT_REGISTRY or the registry service.
+func (c *MasterConfig) defaultRegistryFunc() func() (string, bool) {
+	defaultRegistry := env("OPENSHIFT_DEFAULT_REGISTRY", "${DOCKER_REGISTRY_SERVICE_HOST}:${DOCKER_REGISTRY_SERVICE_PORT}")
+	svcCache := service.NewServiceResolverCache(c.KubeClient().Services(kapi.NamespaceDefault).Get)
+	defaultRegistryFunc, err := svcCache.Defer(defaultRegistry)
+	if err != nil {
+		glog.Fatalf("OPENSHIFT_DEFAULT_REGISTRY variable is invalid %q: %v", defaultRegistry, err)
+	}
+	return defaultRegistryFunc
+}
+
 func env(key string, defaultValue string) string {
 	val := os.Getenv(key)
 	if len(val) == 0 {
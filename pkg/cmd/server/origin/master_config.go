@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
 	"path"
 
 	etcdclient "github.com/coreos/go-etcd/etcd"
@@ -50,6 +51,7 @@ import (
 	"github.com/openshift/origin/pkg/cmd/server/etcd"
 	"github.com/openshift/origin/pkg/cmd/util/plug"
 	"github.com/openshift/origin/pkg/cmd/util/variable"
+	imageapi "github.com/openshift/origin/pkg/image/api"
 	accesstokenregistry "github.com/openshift/origin/pkg/oauth/registry/oauthaccesstoken"
 	accesstokenetcd "github.com/openshift/origin/pkg/oauth/registry/oauthaccesstoken/etcd"
 	projectauth "github.com/openshift/origin/pkg/project/auth"
@@ -162,7 +164,7 @@ func BuildMasterConfig(options configapi.MasterConfig) (*MasterConfig, error) {
 	kubeletClientConfig := configapi.GetKubeletClientConfig(options)
 
 	// in-order list of plug-ins that should intercept admission decisions (origin only intercepts)
-	admissionControlPluginNames := []string{"OriginNamespaceLifecycle", "BuildByStrategy"}
+	admissionControlPluginNames := []string{"OriginNamespaceLifecycle", "BuildByStrategy", "ImageQuota"}
 
 	admissionClient := admissionControlClient(privilegedLoopbackKubeClient, privilegedLoopbackOpenShiftClient)
 	admissionController := admission.NewFromPlugins(admissionClient, admissionControlPluginNames, "")
@@ -424,6 +426,76 @@ func (c *MasterConfig) ImageImportControllerClient() *osclient.Client {
 	return c.PrivilegedLoopbackOpenShiftClient
 }
 
+// ImageImportSecretsClient returns the client used to read the docker registry
+// secrets of a namespace when importing images into its image streams, or
+// when the integrated registry reads them through the secrets subresource of
+// image streams
+func (c *MasterConfig) ImageImportSecretsClient() *kclient.Client {
+	return c.PrivilegedLoopbackKubernetesClient
+}
+
+// RegistryPolicy returns the registries images may be imported from and run
+// in pods
+func (c *MasterConfig) RegistryPolicy() imageapi.RegistryPolicy {
+	return imageapi.RegistryPolicy{
+		Allowed: c.Options.ImagePolicyConfig.AllowedRegistries,
+		Blocked: c.Options.ImagePolicyConfig.BlockedRegistries,
+	}
+}
+
+// ImageStreamDeletionControllerClient returns the image stream deletion
+// controller client object
+func (c *MasterConfig) ImageStreamDeletionControllerClient() *osclient.Client {
+	return c.PrivilegedLoopbackOpenShiftClient
+}
+
+// ImageStreamStorageUsageControllerClient returns the client the image stream
+// storage usage controller lists the images and updates the image streams
+// with.
+func (c *MasterConfig) ImageStreamStorageUsageControllerClient() *osclient.Client {
+	return c.PrivilegedLoopbackOpenShiftClient
+}
+
+// ImageQuotaControllerClients returns the clients the image quota controller
+// lists the image streams and updates the ResourceQuotas with.
+func (c *MasterConfig) ImageQuotaControllerClients() (*osclient.Client, *kclient.Client) {
+	return c.PrivilegedLoopbackOpenShiftClient, c.PrivilegedLoopbackKubernetesClient
+}
+
+// ImageFinalizerControllerClients returns the image finalizer controller client
+// object, and the HTTP client it uses to delete content from the integrated
+// registry. Both carry the token of the controller's service account, which the
+// registry requires to authorize deletions. The controller only sends it over
+// https to the address of the integrated registry, never to the registry
+// recorded in the reference of an image.
+func (c *MasterConfig) ImageFinalizerControllerClients() (*osclient.Client, *http.Client) {
+	name := bootstrappolicy.InfraImageFinalizerControllerServiceAccountName
+	osClient, _, err := c.GetServiceAccountClients(name)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	tokenRetriever := &serviceaccounts.ClientLookupTokenRetriever{Client: c.PrivilegedLoopbackKubernetesClient}
+	token, err := tokenRetriever.GetToken(c.Options.PolicyConfig.OpenShiftInfrastructureNamespace, name)
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	// the registry reads the token from the password of basic auth
+	registryConfig := c.PrivilegedLoopbackClientConfig
+	registryConfig.BearerToken = ""
+	registryConfig.CertFile = ""
+	registryConfig.CertData = []byte{}
+	registryConfig.KeyFile = ""
+	registryConfig.KeyData = []byte{}
+	registryConfig.Username = "unused"
+	registryConfig.Password = token
+	transport, err := kclient.TransportFor(&registryConfig)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	return osClient, &http.Client{Transport: transport}
+}
+
 // DeploymentConfigScaleClient returns the client used by the Scale subresource registry
 func (c *MasterConfig) DeploymentConfigScaleClient() *kclient.Client {
 	return c.PrivilegedLoopbackKubernetesClient
@@ -346,10 +346,74 @@ func (c *MasterConfig) RunSDNController() {
 func (c *MasterConfig) RunImageImportController() {
 	osclient := c.ImageImportControllerClient()
 	factory := imagecontroller.ImportControllerFactory{
-		Client: osclient,
+		Client:     osclient,
+		Secrets:    c.ImageImportSecretsClient(),
+		Registries: c.RegistryPolicy(),
 	}
 	controller := factory.Create()
 	controller.Run()
+
+	scheduledFactory := imagecontroller.ScheduledImportControllerFactory{
+		Client:     osclient,
+		Secrets:    c.ImageImportSecretsClient(),
+		Registries: c.RegistryPolicy(),
+	}
+	scheduledFactory.Create().Run()
+
+	historyFactory := imagecontroller.TagHistoryControllerFactory{
+		Client: osclient,
+	}
+	historyFactory.Create().Run()
+}
+
+// RunImageFinalizerController starts the controller removing the content of
+// the deleted images from the integrated registry.
+func (c *MasterConfig) RunImageFinalizerController() {
+	osclient, registryClient := c.ImageFinalizerControllerClients()
+	factory := imagecontroller.ImageFinalizerControllerFactory{
+		Client:          osclient,
+		RegistryAddress: c.defaultRegistryFunc(),
+		RegistryClient:  registryClient,
+	}
+	factory.Create().Run()
+}
+
+// RunImageStreamDeletionController starts the controller removing the image
+// streams whose deletion grace period expired, if they have one.
+func (c *MasterConfig) RunImageStreamDeletionController() {
+	if c.Options.ImagePolicyConfig.DeletedImageStreamGracePeriodSeconds <= 0 {
+		return
+	}
+	factory := imagecontroller.ImageStreamDeletionControllerFactory{
+		Client: c.ImageStreamDeletionControllerClient(),
+	}
+	factory.Create().Run()
+}
+
+// RunImageStreamStorageUsageController starts the controller reporting the
+// storage the image streams use in the integrated registry, if it's enabled.
+func (c *MasterConfig) RunImageStreamStorageUsageController() {
+	interval := c.Options.ImagePolicyConfig.ImageStreamStorageUsageIntervalSeconds
+	if interval <= 0 {
+		return
+	}
+	factory := imagecontroller.ImageStreamStorageUsageControllerFactory{
+		Client:   c.ImageStreamStorageUsageControllerClient(),
+		Interval: time.Duration(interval) * time.Second,
+	}
+	factory.Create().Run()
+}
+
+// RunImageQuotaController starts the controller recording the usage of the
+// image stream and image quotas.
+func (c *MasterConfig) RunImageQuotaController() {
+	osclient, kubeClient := c.ImageQuotaControllerClients()
+	factory := imagecontroller.ImageQuotaControllerFactory{
+		Client:       osclient,
+		KubeClient:   kubeClient,
+		ResyncPeriod: 30 * time.Second,
+	}
+	factory.Create().Run()
 }
 
 // RunSecurityAllocationController starts the security allocation controller process.
@@ -576,6 +576,10 @@ func startControllers(oc *origin.MasterConfig, kc *kubernetes.MasterConfig) erro
 	oc.RunDeploymentConfigChangeController()
 	oc.RunDeploymentImageChangeTriggerController()
 	oc.RunImageImportController()
+	oc.RunImageFinalizerController()
+	oc.RunImageStreamDeletionController()
+	oc.RunImageQuotaController()
+	oc.RunImageStreamStorageUsageController()
 	oc.RunOriginNamespaceController()
 	oc.RunSDNController()
 
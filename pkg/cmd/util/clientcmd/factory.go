@@ -35,6 +35,7 @@ import (
 	deployreaper "github.com/openshift/origin/pkg/deploy/reaper"
 	deployscaler "github.com/openshift/origin/pkg/deploy/scaler"
 	deployutil "github.com/openshift/origin/pkg/deploy/util"
+	imagereaper "github.com/openshift/origin/pkg/image/reaper"
 	routegen "github.com/openshift/origin/pkg/route/generator"
 )
 
@@ -210,6 +211,8 @@ func NewFactory(clientConfig kclientcmd.ClientConfig) *Factory {
 			return authorizationreaper.NewRoleReaper(oc, oc), nil
 		case "ClusterRole":
 			return authorizationreaper.NewClusterRoleReaper(oc, oc, oc), nil
+		case "ImageStreamTag":
+			return imagereaper.NewImageStreamTagReaper(oc), nil
 
 		}
 		return kReaperFunc(mapping)
@@ -218,6 +218,10 @@ const (
 	// DeploymentReplicasAnnotation is for internal use only and is for
 	// detecting external modifications to deployment replica counts.
 	DeploymentReplicasAnnotation = "openshift.io/deployment.replicas"
+	// DeploymentTagAnnotationsAnnotation is an annotation on the pod template of a DeploymentConfig.
+	// The annotation value is the comma separated list of the annotations copied from the image
+	// stream tags of its image change triggers, so that the ones dropped from the tags are removed.
+	DeploymentTagAnnotationsAnnotation = "openshift.io/image-tag-annotations"
 )
 
 // These constants represent the various reasons for cancelling a deployment
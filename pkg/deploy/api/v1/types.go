@@ -293,7 +293,8 @@ type DeploymentTriggerImageChangeParams struct {
 	// From is a reference to a Docker image repository tag to watch for changes. The
 	// Kind may be left blank, in which case it defaults to "ImageStreamTag". The "Name" is
 	// the only required subfield - if Namespace is blank, the namespace of the current deployment
-	// trigger will be used.
+	// trigger will be used. An ImageStreamTag pinned to an image of its history with
+	// <name>:<tag>@<digest> always resolves to that image.
 	From kapi.ObjectReference `json:"from" description:"a reference to an ImageRepository, ImageStream, or ImageStreamTag to watch for changes"`
 	// LastTriggeredImage is the last image to be triggered.
 	LastTriggeredImage string `json:"lastTriggeredImage,omitempty" description:"the last image to be triggered"`
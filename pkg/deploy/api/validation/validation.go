@@ -14,6 +14,7 @@ import (
 	kvalidation "k8s.io/kubernetes/pkg/util/validation"
 
 	deployapi "github.com/openshift/origin/pkg/deploy/api"
+	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
 // TODO: These tests validate the ReplicationControllerState in a Deployment or DeploymentConfig.
@@ -280,6 +281,10 @@ func validateImageChangeParams(params *deployapi.DeploymentTriggerImageChangePar
 		}
 	}
 
+	if _, _, ok := imageapi.SplitTagDigest(params.Tag); !ok {
+		errs = append(errs, fielderrors.NewFieldInvalid("tag", params.Tag, "a tag pinned to an image must be in the form <tag>@<digest>"))
+	}
+
 	if len(params.ContainerNames) == 0 {
 		errs = append(errs, fielderrors.NewFieldRequired("containerNames"))
 	}
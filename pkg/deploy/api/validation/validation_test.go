@@ -184,6 +184,27 @@ func TestValidateDeploymentConfigMissingFields(t *testing.T) {
 			fielderrors.ValidationErrorTypeInvalid,
 			"triggers[0].imageChangeParams.repositoryName",
 		},
+		"invalid Trigger imageChangeParams.tag digest": {
+			api.DeploymentConfig{
+				ObjectMeta: kapi.ObjectMeta{Name: "foo", Namespace: "bar"},
+				Triggers: []api.DeploymentTriggerPolicy{
+					{
+						Type: api.DeploymentTriggerOnImageChange,
+						ImageChangeParams: &api.DeploymentTriggerImageChangeParams{
+							From: kapi.ObjectReference{
+								Kind: "ImageStream",
+								Name: "name",
+							},
+							Tag:            "latest@0123",
+							ContainerNames: []string{"foo"},
+						},
+					},
+				},
+				Template: test.OkDeploymentTemplate(),
+			},
+			fielderrors.ValidationErrorTypeInvalid,
+			"triggers[0].imageChangeParams.tag",
+		},
 		"missing Trigger imageChangeParams.containerNames": {
 			api.DeploymentConfig{
 				ObjectMeta: kapi.ObjectMeta{Name: "foo", Namespace: "bar"},
@@ -50,16 +50,17 @@ func (c *ImageChangeController) Handle(imageRepo *imageapi.ImageStream) error {
 				continue
 			}
 
-			// Find the latest tag event for the trigger tag
-			latestEvent := imageapi.LatestTaggedImage(imageRepo, params.Tag)
+			// Find the latest tag event for the trigger tag, or the one of the
+			// image the tag is pinned to
+			latestEvent := imageapi.TaggedImage(imageRepo, params.Tag)
 			if latestEvent == nil {
 				glog.V(5).Infof("Couldn't find latest tag event for tag %s in ImageStream %s", params.Tag, labelForRepo(imageRepo))
 				continue
 			}
 
 			// Ensure a change occurred
-			if len(latestEvent.DockerImageReference) > 0 &&
-				latestEvent.DockerImageReference != params.LastTriggeredImage {
+			if ref := imageapi.ResolveReferenceForTagEvent(imageRepo, params.Tag, latestEvent); len(ref) > 0 &&
+				ref != params.LastTriggeredImage {
 				// Mark the config for regeneration
 				configsToUpdate[config.Name] = config
 			}
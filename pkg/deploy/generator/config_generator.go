@@ -2,6 +2,8 @@ package generator
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/errors"
@@ -33,6 +35,7 @@ func (g *DeploymentConfigGenerator) Generate(ctx kapi.Context, name string) (*de
 	configChanged := false
 	errs := fielderrors.ValidationErrorList{}
 	causes := []*deployapi.DeploymentCause{}
+	tagAnnotations := map[string]string{}
 	for i, trigger := range config.Triggers {
 		params := trigger.ImageChangeParams
 
@@ -53,14 +56,19 @@ func (g *DeploymentConfigGenerator) Generate(ctx kapi.Context, name string) (*de
 			errs = append(errs, fielderrors.NewFieldInvalid(f, v, err.Error()))
 			continue
 		}
+		for k, v := range imageapi.TagAnnotations(imageStream, params.Tag) {
+			tagAnnotations[k] = v
+		}
 
-		// Find the latest tag event for the trigger tag
-		latestEvent := imageapi.LatestTaggedImage(imageStream, params.Tag)
+		// Find the latest tag event for the trigger tag, or the one of the image
+		// the tag is pinned to
+		latestEvent := imageapi.TaggedImage(imageStream, params.Tag)
 		if latestEvent == nil {
 			f := fmt.Sprintf("triggers[%d].imageChange.tag", i)
 			errs = append(errs, fielderrors.NewFieldInvalid(f, params.Tag, fmt.Sprintf("no image recorded for %s/%s:%s", imageStream.Namespace, imageStream.Name, params.Tag)))
 			continue
 		}
+		ref := imageapi.ResolveReferenceForTagEvent(imageStream, params.Tag, latestEvent)
 
 		// Update containers
 		template := config.Template.ControllerTemplate.Template
@@ -71,12 +79,11 @@ func (g *DeploymentConfigGenerator) Generate(ctx kapi.Context, name string) (*de
 			if !names.Has(container.Name) {
 				continue
 			}
-			if len(latestEvent.DockerImageReference) > 0 &&
-				container.Image != latestEvent.DockerImageReference {
+			if len(ref) > 0 && container.Image != ref {
 				// Update the image
-				container.Image = latestEvent.DockerImageReference
+				container.Image = ref
 				// Log the last triggered image ID
-				params.LastTriggeredImage = latestEvent.DockerImageReference
+				params.LastTriggeredImage = ref
 				containerChanged = true
 			}
 		}
@@ -88,7 +95,7 @@ func (g *DeploymentConfigGenerator) Generate(ctx kapi.Context, name string) (*de
 				&deployapi.DeploymentCause{
 					Type: deployapi.DeploymentTriggerOnImageChange,
 					ImageTrigger: &deployapi.DeploymentCauseImageTrigger{
-						RepositoryName: latestEvent.DockerImageReference,
+						RepositoryName: ref,
 						Tag:            params.Tag,
 					},
 				})
@@ -99,6 +106,9 @@ func (g *DeploymentConfigGenerator) Generate(ctx kapi.Context, name string) (*de
 		return nil, errors.NewInvalid("DeploymentConfig", config.Name, errs)
 	}
 
+	// Surface the annotations of the tags on the pods created from this config
+	reconcileTagAnnotations(config.Template.ControllerTemplate.Template, tagAnnotations)
+
 	// Bump the version if we updated containers or if this is an initial
 	// deployment
 	if configChanged || config.LatestVersion == 0 {
@@ -111,6 +121,37 @@ func (g *DeploymentConfigGenerator) Generate(ctx kapi.Context, name string) (*de
 	return config, nil
 }
 
+// reconcileTagAnnotations sets the annotations of the image stream tags on the
+// pod template, and removes the ones previously copied from the tags that the
+// tags no longer have.
+func reconcileTagAnnotations(template *kapi.PodTemplateSpec, annotations map[string]string) {
+	if template == nil {
+		return
+	}
+	if previous := template.Annotations[deployapi.DeploymentTagAnnotationsAnnotation]; len(previous) > 0 {
+		for _, k := range strings.Split(previous, ",") {
+			if _, ok := annotations[k]; !ok {
+				delete(template.Annotations, k)
+			}
+		}
+	}
+	if len(annotations) == 0 {
+		delete(template.Annotations, deployapi.DeploymentTagAnnotationsAnnotation)
+		return
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	keys := make([]string, 0, len(annotations))
+	for k, v := range annotations {
+		template.Annotations[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	template.Annotations[deployapi.DeploymentTagAnnotationsAnnotation] = strings.Join(keys, ",")
+}
+
 func (g *DeploymentConfigGenerator) findImageStream(config *deployapi.DeploymentConfig, params *deployapi.DeploymentTriggerImageChangeParams) (*imageapi.ImageStream, error) {
 	// Try to find the repo by ObjectReference
 	if len(params.From.Name) > 0 {
@@ -2,6 +2,7 @@ package generator
 
 import (
 	"flag"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -195,6 +196,133 @@ func TestGenerate_fromConfigWithUpdatedImageRef(t *testing.T) {
 	}
 }
 
+func TestGenerate_fromConfigPinnedToImage(t *testing.T) {
+	pinnedRepoName := "registry:8080/openshift/test-image@sha256:00000000000000000000000000000002"
+	pinnedImageID := "sha256:00000000000000000000000000000002"
+
+	generator := &DeploymentConfigGenerator{
+		Client: Client{
+			DCFn: func(ctx kapi.Context, id string) (*deployapi.DeploymentConfig, error) {
+				config := deploytest.OkDeploymentConfig(1)
+				config.Triggers[0].ImageChangeParams.Tag = imageapi.DefaultImageTag + "@" + pinnedImageID
+				return config, nil
+			},
+			ISFn: func(ctx kapi.Context, name string) (*imageapi.ImageStream, error) {
+				stream := makeStream(
+					"test-image-stream",
+					imageapi.DefaultImageTag,
+					pinnedRepoName,
+					pinnedImageID,
+				)
+				history := stream.Status.Tags[imageapi.DefaultImageTag]
+				newer := imageapi.TagEvent{
+					DockerImageReference: "registry:8080/openshift/test-image@sha256:00000000000000000000000000000003",
+					Image:                "sha256:00000000000000000000000000000003",
+				}
+				history.Items = append([]imageapi.TagEvent{newer}, history.Items...)
+				stream.Status.Tags[imageapi.DefaultImageTag] = history
+				return stream, nil
+			},
+		},
+	}
+
+	config, err := generator.Generate(kapi.NewDefaultContext(), "deploy1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if e, a := pinnedRepoName, config.Template.ControllerTemplate.Template.Spec.Containers[0].Image; e != a {
+		t.Fatalf("Expected container image %s, got %s", e, a)
+	}
+	if e, a := pinnedRepoName, config.Triggers[0].ImageChangeParams.LastTriggeredImage; e != a {
+		t.Fatalf("Expected LastTriggeredImage %s, got %s", e, a)
+	}
+}
+
+func TestGenerate_propagatesTagAnnotations(t *testing.T) {
+	newRepoName := "registry:8080/openshift/test-image@sha256:00000000000000000000000000000002"
+	newImageID := "00000000000000000000000000000002"
+
+	generator := &DeploymentConfigGenerator{
+		Client: Client{
+			DCFn: func(ctx kapi.Context, id string) (*deployapi.DeploymentConfig, error) {
+				return deploytest.OkDeploymentConfig(1), nil
+			},
+			ISFn: func(ctx kapi.Context, name string) (*imageapi.ImageStream, error) {
+				stream := makeStream(
+					"test-image-stream",
+					imageapi.DefaultImageTag,
+					newRepoName,
+					newImageID,
+				)
+				stream.Spec.Tags = map[string]imageapi.TagReference{
+					imageapi.DefaultImageTag: {
+						Annotations: map[string]string{"scan-status": "passed"},
+					},
+				}
+				return stream, nil
+			},
+		},
+	}
+
+	config, err := generator.Generate(kapi.NewDefaultContext(), "deploy1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if e, a := "passed", config.Template.ControllerTemplate.Template.Annotations["scan-status"]; e != a {
+		t.Fatalf("Expected pod template annotation %q, got %q", e, a)
+	}
+}
+
+func TestGenerate_reconcilesTagAnnotationsWithoutTagChange(t *testing.T) {
+	generator := &DeploymentConfigGenerator{
+		Client: Client{
+			DCFn: func(ctx kapi.Context, id string) (*deployapi.DeploymentConfig, error) {
+				config := deploytest.OkDeploymentConfig(1)
+				config.Template.ControllerTemplate.Template.Annotations = map[string]string{
+					"owner":       "alice",
+					"scan-status": "pending",
+					"team":        "web",
+					deployapi.DeploymentTagAnnotationsAnnotation: "owner,scan-status",
+				}
+				return config, nil
+			},
+			ISFn: func(ctx kapi.Context, name string) (*imageapi.ImageStream, error) {
+				stream := makeStream(
+					"test-image-stream",
+					imageapi.DefaultImageTag,
+					"registry:8080/repo1:ref1",
+					"00000000000000000000000000000001",
+				)
+				stream.Spec.Tags = map[string]imageapi.TagReference{
+					imageapi.DefaultImageTag: {
+						Annotations: map[string]string{"scan-status": "passed"},
+					},
+				}
+				return stream, nil
+			},
+		},
+	}
+
+	config, err := generator.Generate(kapi.NewDefaultContext(), "deploy1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if config.LatestVersion != 1 {
+		t.Fatalf("Expected config LatestVersion=1, got %d", config.LatestVersion)
+	}
+	expected := map[string]string{
+		"scan-status": "passed",
+		"team":        "web",
+		deployapi.DeploymentTagAnnotationsAnnotation: "scan-status",
+	}
+	if e, a := expected, config.Template.ControllerTemplate.Template.Annotations; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Expected pod template annotations %v, got %v", e, a)
+	}
+}
+
 func TestGenerate_reportsInvalidErrorWhenMissingRepo(t *testing.T) {
 	generator := &DeploymentConfigGenerator{
 		Client: Client{
@@ -1,9 +1,12 @@
 package dockerregistry
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -31,6 +34,9 @@ type Image struct {
 
 	// Does this registry support pull by ID
 	PullByID bool
+	// Layers are the distinct layers of the image with their sizes, as its
+	// manifest lists them, nil if the registry doesn't serve manifests.
+	Layers []imageapi.ImageLayerSize
 }
 
 // Client includes methods for accessing a Docker registry by name.
@@ -50,21 +56,78 @@ type Connection interface {
 	// ImageByTag will return the requested image by namespace (if not specified,
 	// will be "library"), name, and tag (if not specified, "latest").
 	ImageByTag(namespace, name, tag string) (*Image, error)
+	// ImageManifest will return the digest and the raw manifest of the image
+	// identified by namespace (if not specified, will be "library"), name, and
+	// tag or digest. Only supported by Docker V2 registries.
+	ImageManifest(namespace, name, reference string) (string, []byte, error)
+	// ImageLayer will return the content and the size of the layer with the
+	// given digest within the repository identified by namespace (if not
+	// specified, will be "library") and name. The caller must close the
+	// returned reader. Only supported by Docker V2 registries.
+	ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error)
+	// ImageLayerExists returns true if the repository identified by namespace
+	// (if not specified, will be "library") and name has the layer with the
+	// given digest, without fetching its content. Only supported by Docker V2
+	// registries.
+	ImageLayerExists(namespace, name, dgst string) (bool, error)
+	// PutImageLayer uploads size bytes of content as the layer with the given
+	// digest into the repository identified by namespace (if not specified,
+	// will be "library") and name. Callers check with ImageLayerExists first
+	// rather than reading the content of a layer the repository already has.
+	// Only supported by Docker V2 registries.
+	PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error
+	// PutImageManifest uploads the raw manifest as the given tag or digest of
+	// the repository identified by namespace (if not specified, will be
+	// "library") and name, and returns the digest of the manifest assigned by
+	// the registry. Only supported by Docker V2 registries.
+	PutImageManifest(namespace, name, reference string, manifest []byte) (string, error)
 }
 
 // client implements the Client interface
 type client struct {
 	connections map[string]*connection
+	credentials Credentials
 }
 
 // NewClient returns a client object which allows public access to
 // a Docker registry. enableV2 allows a client to prefer V1 registry
 // API connections.
-// TODO: accept a docker auth config
 func NewClient() Client {
+	return NewClientWithCredentials(NoCredentials)
+}
+
+// NewClientWithCredentials returns a client object which answers the
+// authentication challenges of Docker V2 registries with credentials.
+func NewClientWithCredentials(credentials Credentials) Client {
 	return &client{
 		connections: make(map[string]*connection),
+		credentials: credentials,
+	}
+}
+
+// NewRestrictedClient returns a client which refuses to connect to the
+// registries that policy doesn't allow, and connects to the others with
+// client.
+func NewRestrictedClient(client Client, policy imageapi.RegistryPolicy) Client {
+	if policy.IsEmpty() {
+		return client
 	}
+	return &restrictedClient{client: client, policy: policy}
+}
+
+// restrictedClient connects only to the registries allowed by a policy.
+type restrictedClient struct {
+	client Client
+	policy imageapi.RegistryPolicy
+}
+
+// Connect returns an error satisfying IsRegistryForbidden if the policy of c
+// doesn't allow the registry name.
+func (c *restrictedClient) Connect(name string, allowInsecure bool) (Connection, error) {
+	if !c.policy.Allows(name) {
+		return nil, errRegistryForbidden{registry: name}
+	}
+	return c.client.Connect(name, allowInsecure)
 }
 
 // Connect accepts the name of a registry in the common form Docker provides and will
@@ -80,6 +143,7 @@ func (c *client) Connect(name string, allowInsecure bool) (Connection, error) {
 		return conn, nil
 	}
 	conn := newConnection(*target, allowInsecure, true)
+	conn.credentials = c.credentials
 	c.connections[prefix] = conn
 	return conn, nil
 }
@@ -138,6 +202,8 @@ func convertConnectionError(registry string, err error) error {
 	switch {
 	case strings.Contains(err.Error(), "connection refused"):
 		return errRegistryNotFound{registry}
+	case strings.Contains(strings.ToLower(err.Error()), "timeout"):
+		return errRegistryTimeout{registry, err}
 	default:
 		return err
 	}
@@ -150,9 +216,9 @@ type connection struct {
 	url    url.URL
 	cached map[string]repository
 	isV2   *bool
-	token  string
 
 	allowInsecure bool
+	credentials   Credentials
 }
 
 // newConnection creates a new connection
@@ -192,6 +258,7 @@ func newConnection(url url.URL, allowInsecure, enableV2 bool) *connection {
 		isV2:   isV2,
 
 		allowInsecure: allowInsecure,
+		credentials:   NoCredentials,
 	}
 }
 
@@ -250,6 +317,177 @@ func (c *connection) ImageByTag(namespace, name, tag string) (*Image, error) {
 	return repo.getTaggedImage(c, searchTag, tag)
 }
 
+// ImageManifest returns the digest and the raw manifest of the specified image
+// within the named Docker V2 image repository.
+func (c *connection) ImageManifest(namespace, name, reference string) (string, []byte, error) {
+	repo, err := c.getV2Repository(namespace, name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := repo.get(c, fmt.Sprintf("manifests/%s", reference))
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting manifest for %s:%s: %v", repo.name, reference, err)
+	}
+	defer resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusNotFound, code == http.StatusUnauthorized:
+		return "", nil, NewImageNotFoundError(repo.name, reference, "")
+	case code >= 300 || code < 200:
+		delete(c.cached, repo.name)
+		return "", nil, fmt.Errorf("error retrieving manifest: server returned %d", code)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("can't read manifest body from %s: %v", resp.Request.URL, err)
+	}
+	return resp.Header.Get("Docker-Content-Digest"), body, nil
+}
+
+// ImageLayer returns the content of the specified layer within the named
+// Docker V2 image repository.
+func (c *connection) ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error) {
+	repo, err := c.getV2Repository(namespace, name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := repo.get(c, fmt.Sprintf("blobs/%s", dgst))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting layer %s from %s: %v", dgst, repo.name, err)
+	}
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusNotFound, code == http.StatusUnauthorized:
+		resp.Body.Close()
+		return nil, 0, NewImageNotFoundError(repo.name, dgst, "")
+	case code >= 300 || code < 200:
+		resp.Body.Close()
+		delete(c.cached, repo.name)
+		return nil, 0, fmt.Errorf("error retrieving layer: server returned %d", code)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// ImageLayerExists checks with a HEAD request whether the named Docker V2
+// image repository has the specified layer.
+func (c *connection) ImageLayerExists(namespace, name, dgst string) (bool, error) {
+	repo, err := c.getV2Repository(namespace, name)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := repo.do(c, "HEAD", repo.url(fmt.Sprintf("blobs/%s", dgst)), nil)
+	if err != nil {
+		return false, fmt.Errorf("error checking layer %s in %s: %v", dgst, repo.name, err)
+	}
+	resp.Body.Close()
+	switch code := resp.StatusCode; {
+	case code == http.StatusOK:
+		return true, nil
+	case code == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error checking layer %s: server returned %d", dgst, code)
+	}
+}
+
+// PutImageLayer uploads the content of the specified layer into the named
+// Docker V2 image repository with a monolithic upload.
+func (c *connection) PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error {
+	repo, err := c.getV2Repository(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := repo.do(c, "POST", repo.url("blobs/uploads/"), nil)
+	if err != nil {
+		return fmt.Errorf("error starting the upload of layer %s to %s: %v", dgst, repo.name, err)
+	}
+	resp.Body.Close()
+	if code := resp.StatusCode; code != http.StatusAccepted {
+		delete(c.cached, repo.name)
+		return fmt.Errorf("error starting the upload of layer %s: server returned %d", dgst, code)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location %q for layer %s: %v", resp.Header.Get("Location"), dgst, err)
+	}
+	// the location may be relative to the registry
+	upload := *resp.Request.URL.ResolveReference(location)
+	query := upload.Query()
+	query.Set("digest", dgst)
+	upload.RawQuery = query.Encode()
+
+	// the upload was authorized with the POST above, the content is only
+	// sent once
+	resp, err = repo.do(c, "PUT", upload, func(req *http.Request) {
+		req.Body = ioutil.NopCloser(content)
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading layer %s to %s: %v", dgst, repo.name, err)
+	}
+	resp.Body.Close()
+	if code := resp.StatusCode; code != http.StatusCreated {
+		return fmt.Errorf("error uploading layer %s: server returned %d", dgst, code)
+	}
+	return nil
+}
+
+// PutImageManifest uploads the manifest of an image into the named Docker V2
+// image repository. The layers of the manifest must have been uploaded first.
+func (c *connection) PutImageManifest(namespace, name, reference string, manifest []byte) (string, error) {
+	repo, err := c.getV2Repository(namespace, name)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := repo.do(c, "PUT", repo.url(fmt.Sprintf("manifests/%s", reference)), func(req *http.Request) {
+		req.Body = ioutil.NopCloser(bytes.NewReader(manifest))
+		req.ContentLength = int64(len(manifest))
+		req.Header.Set("Content-Type", "application/json")
+	})
+	if err != nil {
+		return "", fmt.Errorf("error uploading manifest %s:%s: %v", repo.name, reference, err)
+	}
+	defer resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusUnauthorized:
+		return "", errUnauthorized{fmt.Sprintf("permission denied to push to %s", repo.name)}
+	case code >= 300 || code < 200:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("error uploading manifest: server returned %d: %s", code, strings.TrimSpace(string(body)))
+	}
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// getV2Repository returns the named repository, or an error if the registry
+// doesn't support the Docker V2 API.
+func (c *connection) getV2Repository(namespace, name string) (*v2repository, error) {
+	if len(namespace) == 0 {
+		namespace = imageapi.DockerDefaultNamespace
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("image name must be specified")
+	}
+
+	repo, err := c.getCachedRepository(fmt.Sprintf("%s/%s", namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	v2repo, ok := repo.(*v2repository)
+	if !ok {
+		return nil, fmt.Errorf("registry %s does not support the Docker V2 API", c.url.Host)
+	}
+	return v2repo, nil
+}
+
 // getCachedRepository returns a repository interface matching the provided name and
 // may cache information about the server on the connection object.
 func (c *connection) getCachedRepository(name string) (repository, error) {
@@ -270,7 +508,6 @@ func (c *connection) getCachedRepository(name string) (repository, error) {
 		repo := &v2repository{
 			name:     name,
 			endpoint: base,
-			token:    c.token,
 		}
 		c.cached[name] = repo
 		return repo, nil
@@ -341,13 +578,23 @@ func parseAuthChallenge(header string) (string, map[string]string) {
 	return sections[0], keys
 }
 
-// authenticateV2 attempts to respond to a given WWW-Authenticate challenge header
-// by asking for a token from the realm. Currently only supports "Bearer" challenges
-// with no credentials provided.
-// TODO: support credentials or replace with the Docker distribution v2 registry client
+// authenticateV2 attempts to respond to a given WWW-Authenticate challenge header,
+// returning the value of the Authorization header to send. "Basic" challenges are
+// answered with the credentials of the registry, "Bearer" challenges by asking for a
+// token from the realm, with the credentials of the registry if there are some. The
+// credentials of the registry are only sent to a realm on the same host, a realm on
+// another host only gets the credentials of the docker registry secrets for its host.
 func (c *connection) authenticateV2(header string) (string, error) {
 	mode, keys := parseAuthChallenge(header)
-	if strings.ToLower(mode) != "bearer" {
+	username, password := c.credentials.Basic(&c.url)
+	switch strings.ToLower(mode) {
+	case "basic":
+		if len(username) == 0 && len(password) == 0 {
+			return "", errUnauthorized{fmt.Sprintf("no credentials for the registry %s, cannot authenticate: %s", c.url.Host, header)}
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
 		return "", fmt.Errorf("unsupported authentication challenge from registry: %s", header)
 	}
 
@@ -370,6 +617,15 @@ func (c *connection) authenticateV2(header string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("error creating v2 auth request: %v", err)
 	}
+	if realmURL.Host != c.url.Host {
+		username, password = "", ""
+		if keyring, ok := c.credentials.(*keyringCredentials); ok {
+			username, password = keyring.Basic(realmURL)
+		}
+	}
+	if len(username) > 0 || len(password) > 0 {
+		req.SetBasicAuth(username, password)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -379,7 +635,7 @@ func (c *connection) authenticateV2(header string) (string, error) {
 
 	switch code := resp.StatusCode; {
 	case code == http.StatusUnauthorized:
-		return "", fmt.Errorf("permission denied to access realm %q", realmURL.String())
+		return "", errUnauthorized{fmt.Sprintf("permission denied to access realm %q", realmURL.String())}
 	case code == http.StatusNotFound:
 		return "", fmt.Errorf("defined realm %q cannot be found", realm)
 	case code >= 300 || resp.StatusCode < 200:
@@ -396,7 +652,7 @@ func (c *connection) authenticateV2(header string) (string, error) {
 	if err := json.Unmarshal(body, &token); err != nil {
 		return "", fmt.Errorf("can't decode the server authorization from %s: %v", realmURL.String(), err)
 	}
-	return token.Token, nil
+	return "Bearer " + token.Token, nil
 }
 
 // getRepositoryV1 returns a repository implementation for a v1 registry by asking for
@@ -454,7 +710,9 @@ type repository interface {
 type v2repository struct {
 	name     string
 	endpoint url.URL
-	token    string
+	// authorization is the value of the Authorization header, set once the
+	// registry challenged the client.
+	authorization string
 }
 
 // v2tags describes the tags/list returned by the Docker V2 registry.
@@ -463,6 +721,55 @@ type v2tags struct {
 	Tags []string `json:"tags"`
 }
 
+// url returns the URL of the given path below the repository.
+func (repo *v2repository) url(subpath string) url.URL {
+	endpoint := repo.endpoint
+	endpoint.Path = path.Join(endpoint.Path, fmt.Sprintf("/v2/%s/%s", repo.name, subpath))
+	// path.Join drops the trailing slash the upload endpoint requires
+	if strings.HasSuffix(subpath, "/") {
+		endpoint.Path += "/"
+	}
+	return endpoint
+}
+
+// get requests the given path below the repository, authenticating once if the
+// server asks for it. The caller must close the body of the returned response.
+func (repo *v2repository) get(c *connection, subpath string) (*http.Response, error) {
+	return repo.do(c, "GET", repo.url(subpath), nil)
+}
+
+// do sends a request to endpoint, prepared by prepare if it isn't nil,
+// authenticating once if the server asks for it. prepare is called again for
+// the authenticated request, it must provide a fresh body each time. The
+// caller must close the body of the returned response.
+func (repo *v2repository) do(c *connection, method string, endpoint url.URL, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequest(method, endpoint.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	if len(repo.authorization) > 0 {
+		req.Header.Set("Authorization", repo.authorization)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, convertConnectionError(c.url.String(), err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && len(repo.authorization) == 0 {
+		resp.Body.Close()
+		authorization, err := c.authenticateV2(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		repo.authorization = authorization
+		return repo.do(c, method, endpoint, prepare)
+	}
+	return resp, nil
+}
+
 func (repo *v2repository) getTags(c *connection) (map[string]string, error) {
 	endpoint := repo.endpoint
 	endpoint.Path = path.Join(endpoint.Path, fmt.Sprintf("/v2/%s/tags/list", repo.name))
@@ -470,8 +777,8 @@ func (repo *v2repository) getTags(c *connection) (map[string]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
-	if len(repo.token) > 0 {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", repo.token))
+	if len(repo.authorization) > 0 {
+		req.Header.Set("Authorization", repo.authorization)
 	}
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -481,17 +788,20 @@ func (repo *v2repository) getTags(c *connection) (map[string]string, error) {
 
 	switch code := resp.StatusCode; {
 	case code == http.StatusUnauthorized:
-		if len(repo.token) != 0 {
+		if len(repo.authorization) != 0 {
 			delete(c.cached, repo.name)
 			// docker will not return a NotFound on any repository URL - for backwards compatibilty, return NotFound on the
 			// repo
 			return nil, errRepositoryNotFound{repo.name}
 		}
-		token, err := c.authenticateV2(resp.Header.Get("WWW-Authenticate"))
+		authorization, err := c.authenticateV2(resp.Header.Get("WWW-Authenticate"))
 		if err != nil {
+			if IsUnauthorized(err) {
+				return nil, err
+			}
 			return nil, fmt.Errorf("error getting image tags for %s: %v", repo.name, err)
 		}
-		repo.token = token
+		repo.authorization = authorization
 		return repo.getTags(c)
 
 	case code == http.StatusNotFound:
@@ -520,8 +830,8 @@ func (repo *v2repository) getTaggedImage(c *connection, tag, userTag string) (*I
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
-	if len(repo.token) > 0 {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", repo.token))
+	if len(repo.authorization) > 0 {
+		req.Header.Set("Authorization", repo.authorization)
 	}
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -531,17 +841,20 @@ func (repo *v2repository) getTaggedImage(c *connection, tag, userTag string) (*I
 
 	switch code := resp.StatusCode; {
 	case code == http.StatusUnauthorized:
-		if len(repo.token) != 0 {
+		if len(repo.authorization) != 0 {
 			delete(c.cached, repo.name)
 			// docker will not return a NotFound on any repository URL - for backwards compatibilty, return NotFound on the
 			// repo
 			return nil, errTagNotFound{len(userTag) == 0, tag, repo.name}
 		}
-		token, err := c.authenticateV2(resp.Header.Get("WWW-Authenticate"))
+		authorization, err := c.authenticateV2(resp.Header.Get("WWW-Authenticate"))
 		if err != nil {
+			if IsUnauthorized(err) {
+				return nil, err
+			}
 			return nil, fmt.Errorf("error getting image for %s:%s: %v", repo.name, tag, err)
 		}
-		repo.token = token
+		repo.authorization = authorization
 		return repo.getTaggedImage(c, tag, userTag)
 	case code == http.StatusNotFound:
 		return nil, errTagNotFound{len(userTag) == 0, tag, repo.name}
@@ -558,12 +871,13 @@ func (repo *v2repository) getTaggedImage(c *connection, tag, userTag string) (*I
 	if err != nil {
 		return nil, fmt.Errorf("can't read image body from %s: %v", req.URL, err)
 	}
-	dockerImage, err := unmarshalV2DockerImage(body)
+	dockerImage, layers, err := unmarshalV2DockerImage(body)
 	if err != nil {
 		return nil, err
 	}
 	image := &Image{
-		Image: *dockerImage,
+		Image:  *dockerImage,
+		Layers: layers,
 	}
 	if len(digest) > 0 {
 		image.Image.ID = digest
@@ -741,6 +1055,40 @@ func (e errRegistryNotFound) Error() string {
 	return fmt.Sprintf("the registry %q could not be reached", e.registry)
 }
 
+// errRegistryForbidden indicates the registry isn't allowed by the registry
+// policy of the cluster.
+type errRegistryForbidden struct {
+	registry string
+}
+
+func (e errRegistryForbidden) Error() string {
+	registry := e.registry
+	if len(registry) == 0 {
+		registry = imageapi.DockerDefaultRegistry
+	}
+	return fmt.Sprintf("images may not be imported from the registry %q", registry)
+}
+
+// errRegistryTimeout indicates the registry didn't answer in time.
+type errRegistryTimeout struct {
+	registry string
+	err      error
+}
+
+func (e errRegistryTimeout) Error() string {
+	return e.err.Error()
+}
+
+// errUnauthorized indicates the registry asked for credentials and refused
+// those it was given, or there were none.
+type errUnauthorized struct {
+	message string
+}
+
+func (e errUnauthorized) Error() string {
+	return e.message
+}
+
 func IsRegistryNotFound(err error) bool {
 	_, ok := err.(errRegistryNotFound)
 	return ok
@@ -765,6 +1113,40 @@ func IsNotFound(err error) bool {
 	return IsRegistryNotFound(err) || IsRepositoryNotFound(err) || IsImageNotFound(err) || IsTagNotFound(err)
 }
 
+func IsRegistryTimeout(err error) bool {
+	_, ok := err.(errRegistryTimeout)
+	return ok
+}
+
+// IsRegistryForbidden returns true if err was returned because the registry
+// isn't allowed by the registry policy of the cluster.
+func IsRegistryForbidden(err error) bool {
+	_, ok := err.(errRegistryForbidden)
+	return ok
+}
+
+func IsUnauthorized(err error) bool {
+	_, ok := err.(errUnauthorized)
+	return ok
+}
+
+// ImportFailureReason returns the reason of the ImportSuccess condition of a
+// tag whose import failed with err.
+func ImportFailureReason(err error) string {
+	switch {
+	case IsUnauthorized(err):
+		return imageapi.ImportUnauthorizedReason
+	case IsNotFound(err):
+		return imageapi.ImportNotFoundReason
+	case IsRegistryTimeout(err):
+		return imageapi.ImportTimeoutReason
+	case IsRegistryForbidden(err):
+		return imageapi.ImportForbiddenReason
+	default:
+		return imageapi.ImportFailedReason
+	}
+}
+
 func unmarshalDockerImage(body []byte) (*docker.Image, error) {
 	var imagePre012 docker.ImagePre012
 	if err := json.Unmarshal(body, &imagePre012); err != nil {
@@ -786,13 +1168,39 @@ func unmarshalDockerImage(body []byte) (*docker.Image, error) {
 	}, nil
 }
 
-func unmarshalV2DockerImage(body []byte) (*docker.Image, error) {
+func unmarshalV2DockerImage(body []byte) (*docker.Image, []imageapi.ImageLayerSize, error) {
 	manifest := imageapi.DockerImageManifest{}
 	if err := json.Unmarshal(body, &manifest); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(manifest.History) == 0 {
-		return nil, fmt.Errorf("image has no v1Compatibility history and cannot be used")
+		return nil, nil, fmt.Errorf("image has no v1Compatibility history and cannot be used")
+	}
+	image, err := unmarshalDockerImage([]byte(manifest.History[0].DockerV1Compatibility))
+	if err != nil {
+		return nil, nil, err
+	}
+	return image, v2ImageLayers(manifest), nil
+}
+
+// v2ImageLayers returns the distinct layers of manifest with the sizes of its
+// history, or nil if the history doesn't match the layers.
+func v2ImageLayers(manifest imageapi.DockerImageManifest) []imageapi.ImageLayerSize {
+	if len(manifest.History) != len(manifest.FSLayers) {
+		return nil
+	}
+	layers := []imageapi.ImageLayerSize{}
+	seen := make(map[string]bool)
+	for i, layer := range manifest.FSLayers {
+		if seen[layer.DockerBlobSum] {
+			continue
+		}
+		seen[layer.DockerBlobSum] = true
+		v1Metadata := imageapi.DockerV1CompatibilityImage{}
+		if err := json.Unmarshal([]byte(manifest.History[i].DockerV1Compatibility), &v1Metadata); err != nil {
+			return nil
+		}
+		layers = append(layers, imageapi.ImageLayerSize{Name: layer.DockerBlobSum, Size: v1Metadata.Size})
 	}
-	return unmarshalDockerImage([]byte(manifest.History[0].DockerV1Compatibility))
+	return layers
 }
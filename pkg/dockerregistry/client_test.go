@@ -2,11 +2,16 @@ package dockerregistry
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
 )
 
 // tests of running registries are done in the integration client test
@@ -281,3 +286,250 @@ func TestGetTagFallback(t *testing.T) {
 		t.Errorf("expected error")
 	}
 }
+
+func TestV2ManifestAndLayer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+			w.WriteHeader(http.StatusOK)
+		case "/v2/foo/bar/manifests/latest":
+			w.Header().Set("Docker-Content-Digest", "sha256:manifest")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"name":"foo/bar"}`)
+		case "/v2/foo/bar/blobs/sha256:layer":
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, "layer")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	uri, _ := url.Parse(server.URL)
+	conn, err := NewClient().Connect(uri.Host, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dgst, manifest, err := conn.ImageManifest("foo", "bar", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dgst != "sha256:manifest" || string(manifest) != `{"name":"foo/bar"}` {
+		t.Errorf("unexpected manifest %s: %s", dgst, manifest)
+	}
+
+	layer, size, err := conn.ImageLayer("foo", "bar", "sha256:layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer layer.Close()
+	content, err := ioutil.ReadAll(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "layer" || size != int64(len("layer")) {
+		t.Errorf("unexpected layer of size %d: %s", size, content)
+	}
+
+	if _, _, err := conn.ImageManifest("foo", "bar", "missing"); !IsImageNotFound(err) {
+		t.Errorf("expected image not found error, got %v", err)
+	}
+}
+
+func TestV2Credentials(t *testing.T) {
+	var uri *url.URL
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/token":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"granted"}`)
+		case "/v2/basic/app/tags/list":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"name":"basic/app","tags":["latest"]}`)
+		case "/v2/bearer/app/tags/list":
+			if r.Header.Get("Authorization") != "Bearer granted" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="registry"`, uri.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"name":"bearer/app","tags":["latest"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	uri, _ = url.Parse(server.URL)
+
+	secrets := []kapi.Secret{
+		{
+			Type: kapi.SecretTypeDockercfg,
+			Data: map[string][]byte{
+				kapi.DockerConfigKey: []byte(fmt.Sprintf(`{"https://%s":{"auth":"dXNlcjpwYXNz","email":"user@example.com"}}`, uri.Host)),
+			},
+		},
+	}
+	credentials, err := NewCredentialsForSecrets(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, namespace := range []string{"basic", "bearer"} {
+		conn, err := NewClientWithCredentials(credentials).Connect(uri.Host, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags, err := conn.ImageTags(namespace, "app")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", namespace, err)
+			continue
+		}
+		if _, ok := tags["latest"]; !ok {
+			t.Errorf("%s: unexpected tags %v", namespace, tags)
+		}
+
+		conn, err = NewClient().Connect(uri.Host, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.ImageTags(namespace, "app"); !IsUnauthorized(err) {
+			t.Errorf("%s: expected an unauthorized error without credentials, got %v", namespace, err)
+		} else if reason := ImportFailureReason(err); reason != imageapi.ImportUnauthorizedReason {
+			t.Errorf("%s: unexpected import failure reason %s", namespace, reason)
+		}
+	}
+}
+
+func TestV2CredentialsNotSentToOtherRealms(t *testing.T) {
+	realmCalled := false
+	realm := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realmCalled = true
+		if _, _, ok := r.BasicAuth(); ok {
+			t.Errorf("unexpected credentials sent to the realm of another host")
+		}
+		fmt.Fprint(w, `{"token":"anonymous"}`)
+	}))
+	defer realm.Close()
+	realmURI, _ := url.Parse(realm.URL)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/bearer/app/tags/list":
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="registry"`, realmURI.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"name":"bearer/app","tags":["latest"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	uri, _ := url.Parse(server.URL)
+
+	secrets := []kapi.Secret{
+		{
+			Type: kapi.SecretTypeDockercfg,
+			Data: map[string][]byte{
+				kapi.DockerConfigKey: []byte(fmt.Sprintf(`{"https://%s":{"auth":"dXNlcjpwYXNz","email":"user@example.com"}}`, uri.Host)),
+			},
+		},
+	}
+	keyring, err := NewCredentialsForSecrets(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, credentials := range []Credentials{keyring, NewBasicCredentials("user", "pass")} {
+		realmCalled = false
+		conn, err := NewClientWithCredentials(credentials).Connect(uri.Host, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.ImageTags("bearer", "app"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !realmCalled {
+			t.Errorf("expected a token to be requested from the realm")
+		}
+	}
+}
+
+func TestV2PutLayerAndManifest(t *testing.T) {
+	uploaded := map[string]string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		if r.URL.Path != "/v2/" {
+			if _, password, ok := r.BasicAuth(); !ok || password != "token" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == "HEAD" && r.URL.Path == "/v2/foo/bar/blobs/sha256:existing":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == "HEAD":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "POST" && r.URL.Path == "/v2/foo/bar/blobs/uploads/":
+			w.Header().Set("Location", "/v2/foo/bar/blobs/uploads/1?_state=abc")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == "PUT" && r.URL.Path == "/v2/foo/bar/blobs/uploads/1":
+			if r.URL.Query().Get("_state") != "abc" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			uploaded[r.URL.Query().Get("digest")] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "PUT" && r.URL.Path == "/v2/foo/bar/manifests/latest":
+			body, _ := ioutil.ReadAll(r.Body)
+			uploaded["latest"] = string(body)
+			w.Header().Set("Docker-Content-Digest", "sha256:manifest")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	uri, _ := url.Parse(server.URL)
+
+	conn, err := NewClientWithCredentials(NewBasicCredentials("user", "token")).Connect(uri.Host, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := conn.ImageLayerExists("foo", "bar", "sha256:existing"); err != nil || !exists {
+		t.Fatalf("expected the layer to exist, got %t: %v", exists, err)
+	}
+	if exists, err := conn.ImageLayerExists("foo", "bar", "sha256:layer"); err != nil || exists {
+		t.Fatalf("expected the layer not to exist, got %t: %v", exists, err)
+	}
+	if err := conn.PutImageLayer("foo", "bar", "sha256:layer", strings.NewReader("layer"), 5); err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := conn.PutImageManifest("foo", "bar", "latest", []byte(`{"name":"foo/bar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dgst != "sha256:manifest" {
+		t.Errorf("unexpected digest %s", dgst)
+	}
+	expected := map[string]string{"sha256:layer": "layer", "latest": `{"name":"foo/bar"}`}
+	if len(uploaded) != len(expected) || uploaded["sha256:layer"] != expected["sha256:layer"] || uploaded["latest"] != expected["latest"] {
+		t.Errorf("expected uploads %v, got %v", expected, uploaded)
+	}
+}
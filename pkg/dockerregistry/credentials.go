@@ -0,0 +1,88 @@
+package dockerregistry
+
+import (
+	"net/url"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/credentialprovider"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+// Credentials provides the credentials sent to a registry asking for them.
+type Credentials interface {
+	// Basic returns the username and password to use for the registry at url,
+	// or empty strings if there are none.
+	Basic(url *url.URL) (string, string)
+}
+
+// NoCredentials provides no credentials for any registry.
+var NoCredentials Credentials = noCredentials{}
+
+type noCredentials struct{}
+
+func (noCredentials) Basic(url *url.URL) (string, string) {
+	return "", ""
+}
+
+// basicCredentials provides the same username and password for any registry.
+type basicCredentials struct {
+	username, password string
+}
+
+// NewBasicCredentials returns Credentials providing username and password
+// to any registry, e.g. a token of a user for an integrated registry.
+func NewBasicCredentials(username, password string) Credentials {
+	return &basicCredentials{username: username, password: password}
+}
+
+func (c *basicCredentials) Basic(url *url.URL) (string, string) {
+	return c.username, c.password
+}
+
+// keyringCredentials looks up the credentials of a registry in a keyring.
+type keyringCredentials struct {
+	keyring credentialprovider.DockerKeyring
+}
+
+// NewKeyringCredentials returns Credentials looking up the registries in
+// keyring.
+func NewKeyringCredentials(keyring credentialprovider.DockerKeyring) Credentials {
+	return &keyringCredentials{keyring: keyring}
+}
+
+func (c *keyringCredentials) Basic(url *url.URL) (string, string) {
+	// the keyring looks up images, a trailing slash keeps a host from being
+	// mistaken for a repository of the Docker Hub
+	configs, ok := c.keyring.Lookup(url.Host + "/")
+	if !ok || len(configs) == 0 {
+		return "", ""
+	}
+	return configs[0].Username, configs[0].Password
+}
+
+// NewCredentialsForSecrets returns Credentials for the registries of the
+// secrets of type dockercfg or dockerconfigjson. The other secrets are
+// ignored.
+func NewCredentialsForSecrets(secrets []kapi.Secret) (Credentials, error) {
+	keyring, err := credentialprovider.MakeDockerKeyring(secrets, &credentialprovider.BasicDockerKeyring{})
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyringCredentials(keyring), nil
+}
+
+// CredentialsForNamespace returns the Credentials of the docker registry
+// secrets of namespace, used to import images into the image streams of the
+// namespace. NoCredentials is returned if secrets is nil.
+func CredentialsForNamespace(secrets kclient.SecretsNamespacer, namespace string) (Credentials, error) {
+	if secrets == nil {
+		return NoCredentials, nil
+	}
+	list, err := secrets.Secrets(namespace).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return NewCredentialsForSecrets(list.Items)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// defaultAccessCacheTTL is how long the result of an access check is
+	// remembered.
+	defaultAccessCacheTTL = 30 * time.Second
+	// defaultAccessCacheSize is the number of access checks remembered.
+	defaultAccessCacheSize = 4096
+)
+
+// accessCache remembers the results of the subject access reviews made for
+// the tokens of recent requests, so that pulling an image, which checks the
+// access to the repository for every layer, doesn't cost a call to the master
+// per layer. A nil accessCache remembers nothing.
+type accessCache struct {
+	cache *lru.Cache
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+type accessCacheRecord struct {
+	created time.Time
+	err     error
+}
+
+// newAccessCache returns the cache configured by the "accesscachettl" and
+// "accesscachesize" options, or nil if either is zero.
+func newAccessCache(options map[string]interface{}) (*accessCache, error) {
+	ttl := defaultAccessCacheTTL
+	if value, ok := options["accesscachettl"]; ok {
+		var err error
+		if ttl, err = time.ParseDuration(fmt.Sprintf("%v", value)); err != nil {
+			return nil, fmt.Errorf("invalid accesscachettl %q: %v", value, err)
+		}
+	}
+	size := defaultAccessCacheSize
+	if value, ok := options["accesscachesize"]; ok {
+		var err error
+		if size, err = strconv.Atoi(fmt.Sprintf("%v", value)); err != nil {
+			return nil, fmt.Errorf("invalid accesscachesize %q: %v", value, err)
+		}
+	}
+	if ttl <= 0 || size <= 0 {
+		return nil, nil
+	}
+
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &accessCache{cache: cache, ttl: ttl, now: time.Now}, nil
+}
+
+// accessCacheKey identifies the access check of the given attributes for the
+// owner of bearerToken. The token itself isn't kept in memory.
+func accessCacheKey(bearerToken string, attributes ...string) string {
+	sum := sha256.Sum256([]byte(bearerToken))
+	return hex.EncodeToString(sum[:]) + "/" + strings.Join(attributes, "/")
+}
+
+// verify returns the remembered result of the access check identified by key,
+// or runs check. Grants and denials are remembered, errors reaching the
+// master aren't.
+func (c *accessCache) verify(key string, check func() error) error {
+	if c == nil {
+		return check()
+	}
+
+	if value, ok := c.cache.Get(key); ok {
+		record := value.(*accessCacheRecord)
+		if record.created.Add(c.ttl).After(c.now()) {
+			return record.err
+		}
+		log.Debugf("Access cache record expired for %s", key)
+		c.cache.Remove(key)
+	}
+
+	err := check()
+	if err == nil || err == ErrOpenShiftAccessDenied {
+		c.cache.Add(key, &accessCacheRecord{created: c.now(), err: err})
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccessCache(t *testing.T) {
+	cache, err := newAccessCache(map[string]interface{}{"accesscachettl": "1m", "accesscachesize": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	checks := 0
+	check := func(result error) func() error {
+		return func() error {
+			checks++
+			return result
+		}
+	}
+
+	allowed := accessCacheKey("token", "get", "ns", "app")
+	if err := cache.verify(allowed, check(nil)); err != nil || checks != 1 {
+		t.Fatalf("unexpected result %v after %d checks", err, checks)
+	}
+	if err := cache.verify(allowed, check(ErrOpenShiftAccessDenied)); err != nil || checks != 1 {
+		t.Errorf("expected the grant to be remembered, got %v after %d checks", err, checks)
+	}
+	if err := cache.verify(accessCacheKey("other token", "get", "ns", "app"), check(ErrOpenShiftAccessDenied)); err != ErrOpenShiftAccessDenied || checks != 2 {
+		t.Errorf("expected other tokens to be checked, got %v after %d checks", err, checks)
+	}
+
+	denied := accessCacheKey("token", "update", "ns", "app")
+	cache.verify(denied, check(ErrOpenShiftAccessDenied))
+	if err := cache.verify(denied, check(nil)); err != ErrOpenShiftAccessDenied {
+		t.Errorf("expected the denial to be remembered, got %v", err)
+	}
+
+	failed := accessCacheKey("token", "get", "ns", "failed")
+	cache.verify(failed, check(errors.New("master unavailable")))
+	checks = 0
+	if err := cache.verify(failed, check(nil)); err != nil || checks != 1 {
+		t.Errorf("expected errors not to be remembered, got %v after %d checks", err, checks)
+	}
+
+	now = now.Add(2 * time.Minute)
+	checks = 0
+	if err := cache.verify(denied, check(nil)); err != nil || checks != 1 {
+		t.Errorf("expected expired records to be checked again, got %v after %d checks", err, checks)
+	}
+}
+
+func TestAccessCacheDisabled(t *testing.T) {
+	cache, err := newAccessCache(map[string]interface{}{"accesscachettl": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache != nil {
+		t.Fatalf("expected a zero ttl to disable the cache")
+	}
+	checks := 0
+	for i := 0; i < 2; i++ {
+		cache.verify(accessCacheKey("token", "get", "ns", "app"), func() error {
+			checks++
+			return nil
+		})
+	}
+	if checks != 2 {
+		t.Errorf("expected every access to be checked, got %d checks", checks)
+	}
+
+	if _, err := newAccessCache(map[string]interface{}{"accesscachettl": "soon"}); err == nil {
+		t.Errorf("expected an invalid ttl to be rejected")
+	}
+}
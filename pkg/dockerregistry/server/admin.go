@@ -1,15 +1,31 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/docker/distribution"
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/handlers"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	gorillahandlers "github.com/gorilla/handlers"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	"golang.org/x/net/context"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/sets"
 )
 
 // BlobDispatcher takes the request context and builds the appropriate handler
@@ -39,12 +55,19 @@ type blobHandler struct {
 func (bh *blobHandler) Delete(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
+	w, audit := auditedDeletion(w, req, "blob", "", bh.Digest.String())
+	defer audit()
+	if !authorizeDeletion(bh.Context, w, req) {
+		return
+	}
+
 	if len(bh.Digest) == 0 {
 		bh.Errors.Push(v2.ErrorCodeBlobUnknown)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
+	layerCache.forgetAll()
 	err := bh.Registry().Blobs().Delete(bh.Digest)
 	if err != nil {
 		// Ignore PathNotFoundError
@@ -58,6 +81,41 @@ func (bh *blobHandler) Delete(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// deletionAccessCache remembers the access checks of the deletion handlers.
+var deletionAccessCache, _ = newAccessCache(nil)
+
+// authorizeDeletion verifies with a SubjectAccessReview that the user of req
+// may delete images, as the system:image-pruner cluster role allows, whatever
+// the access records of the route granted, e.g. if the registry isn't
+// configured with the openshift access controller. Otherwise it answers 403
+// and returns false. The user must have been verified by the access
+// controller, with an OpenShift or a registry token alike.
+func authorizeDeletion(ctx *handlers.Context, w http.ResponseWriter, req *http.Request) bool {
+	err := ErrOpenShiftAccessDenied
+	client, ok := UserClientFrom(ctx)
+	userName, verified := authenticatedUsers.requestUser(req)
+	if ok && verified {
+		err = deletionAccessCache.verify(accessCacheKey(userName, "prune"), func() error {
+			return withDeadline(ctx, defaultAPITimeout, "create SubjectAccessReview", func(ctx context.Context) error {
+				return verifyPruneAccess(ctx, client)
+			})
+		})
+	}
+
+	switch err {
+	case nil:
+		return true
+	case ErrOpenShiftAccessDenied:
+		ctxu.GetLogger(ctx).Errorf("Denying %s %s: %v", req.Method, req.URL.Path, err)
+		ctx.Errors.Push(v2.ErrorCodeUnauthorized, err.Error())
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		ctx.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return false
+}
+
 // LayerDispatcher takes the request context and builds the appropriate handler
 // for handling layer requests.
 func LayerDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
@@ -85,6 +143,12 @@ type layerHandler struct {
 func (lh *layerHandler) Delete(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
+	w, audit := auditedDeletion(w, req, "blob", lh.Repository.Name(), lh.Digest.String())
+	defer audit()
+	if !authorizeDeletion(lh.Context, w, req) {
+		return
+	}
+
 	if len(lh.Digest) == 0 {
 		lh.Errors.Push(v2.ErrorCodeBlobUnknown)
 		w.WriteHeader(http.StatusNotFound)
@@ -120,6 +184,86 @@ func ManifestDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
 	}
 }
 
+// ManifestListDispatcher takes the request context and builds the appropriate
+// handler for listing the manifests of a repository.
+func ManifestListDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	manifestListHandler := &manifestListHandler{
+		Context: ctx,
+	}
+
+	return gorillahandlers.MethodHandler{
+		"GET": http.HandlerFunc(manifestListHandler.List),
+	}
+}
+
+// manifestListHandler handles the listing of the manifests of a repository.
+type manifestListHandler struct {
+	*handlers.Context
+}
+
+type manifestListAPIResponse struct {
+	Name      string          `json:"name"`
+	Manifests []digest.Digest `json:"manifests"`
+}
+
+// List returns the digests of the manifests of the repository, in lexical
+// order. The number of entries is limited by the "n" parameter, listing starts
+// after the "last" parameter. A Link header points to the next page, if any.
+func (mh *manifestListHandler) List(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+	limit := -1
+	if n := query.Get("n"); len(n) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			mh.Errors.PushErr(fmt.Errorf("invalid number of entries %q", n))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	var last digest.Digest
+	if value := query.Get("last"); len(value) > 0 {
+		var err error
+		if last, err = digest.ParseDigest(value); err != nil {
+			mh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	r, ok := mh.Repository.(*repository)
+	if !ok {
+		mh.Errors.PushErr(fmt.Errorf("unexpected repository %T", mh.Repository))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	page, more, err := r.EnumeratePage(mh, limit, last)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			mh.Errors.Push(v2.ErrorCodeNameUnknown, err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mh.Errors.PushErr(fmt.Errorf("error listing the manifests of repo %q: %v", r.Name(), err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if more {
+		next := url.Values{}
+		next.Set("last", page[len(page)-1].String())
+		next.Set("n", strconv.Itoa(limit))
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", req.URL.Path, next.Encode()))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(manifestListAPIResponse{Name: r.Name(), Manifests: page}); err != nil {
+		mh.Errors.PushErr(err)
+		return
+	}
+}
+
 // manifestHandler handles http operations on mainfests.
 type manifestHandler struct {
 	*handlers.Context
@@ -128,10 +272,20 @@ type manifestHandler struct {
 }
 
 // Delete deletes the manifest information from the repository from the storage
-// backend.
+// backend. This removes the manifest revision together with its signature
+// links. The signature blobs may still be linked from the revisions of other
+// repositories, so they are left to the garbage collection. With the query
+// parameter pruneTagHistory=true, the tag events of the image stream pointing
+// at the manifest are removed as well.
 func (mh *manifestHandler) Delete(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
+	w, audit := auditedDeletion(w, req, "manifest", mh.Repository.Name(), mh.Digest.String())
+	defer audit()
+	if !authorizeDeletion(mh.Context, w, req) {
+		return
+	}
+
 	if len(mh.Digest) == 0 {
 		mh.Errors.Push(v2.ErrorCodeManifestUnknown)
 		w.WriteHeader(http.StatusNotFound)
@@ -148,5 +302,506 @@ func (mh *manifestHandler) Delete(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	if req.URL.Query().Get("pruneTagHistory") == "true" {
+		r, ok := mh.Repository.(*repository)
+		if !ok {
+			mh.Errors.PushErr(fmt.Errorf("unexpected repository %T", mh.Repository))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		updatedTags, err := r.removeTagEvents(mh, mh.Digest)
+		if err != nil {
+			mh.Errors.PushErr(fmt.Errorf("error removing manifest %q from the tag history of repo %q: %v", mh.Digest, mh.Repository.Name(), err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(updatedTags) > 0 {
+			ctxu.GetLogger(mh).Infof("removed manifest %s from tags %v of %s", mh.Digest, updatedTags, mh.Repository.Name())
+		}
+		for _, tag := range updatedTags {
+			auditDeletion(req, "tag", mh.Repository.Name(), tag, http.StatusNoContent)
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// CacheDispatcher takes the request context and builds the appropriate
+// handler for handling layer info cache requests.
+func CacheDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	cacheHandler := &cacheHandler{
+		Context: ctx,
+	}
+
+	return gorillahandlers.MethodHandler{
+		"POST": http.HandlerFunc(cacheHandler.Rebuild),
+	}
+}
+
+// cacheHandler handles http operations on the layer info cache.
+type cacheHandler struct {
+	*handlers.Context
+}
+
+// layerInfoCacheRebuild guards the rebuild of the layer info cache, which runs
+// in the background, so that only one runs at a time.
+var layerInfoCacheRebuild struct {
+	sync.Mutex
+	running bool
+}
+
+// Rebuild repopulates the layer info cache with the layers of all images
+// stored in this registry. It is meant to be used after the cache backend has
+// been lost or migrated, so that the cache doesn't have to be warmed up by
+// client traffic hitting the storage driver. The images are listed before
+// responding, the cache is rebuilt in the background and the result logged.
+func (ch *cacheHandler) Rebuild(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	registryAddr := os.Getenv("REGISTRY_URL")
+	if len(registryAddr) == 0 {
+		ch.Errors.PushErr(errors.New("REGISTRY_URL is required"))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	registryAliases := registryAliasesFromEnv()
+
+	registryClient, err := NewRegistryOpenShiftClient()
+	if err != nil {
+		ch.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	layerInfoCacheRebuild.Lock()
+	defer layerInfoCacheRebuild.Unlock()
+	if layerInfoCacheRebuild.running {
+		ch.Errors.PushErr(errors.New("the layer info cache is already being rebuilt"))
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	images, err := registryClient.Images().List(labels.Everything(), fields.Everything())
+	if err != nil {
+		ch.Errors.PushErr(fmt.Errorf("error listing images: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// the rebuild outlives the request, it only keeps its logger
+	ctx := ctxu.WithLogger(context.Background(), ctxu.GetLogger(ch))
+	registry := ch.Registry()
+	layerInfoCacheRebuild.running = true
+	go func() {
+		defer func() {
+			layerInfoCacheRebuild.Lock()
+			defer layerInfoCacheRebuild.Unlock()
+			layerInfoCacheRebuild.running = false
+		}()
+		repositories, layers, missing := rebuildLayerInfoCache(ctx, registry, images.Items, registryAddr, registryAliases)
+		ctxu.GetLogger(ctx).Infof("rebuilt layer info cache: %d repository entries, %d layers cached, %d layers missing", repositories, layers, missing)
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// rebuildLayerInfoCache fetches the layers of the images stored in this
+// registry from their repositories of registry, which records both the
+// repository membership and the location of the layers in the layer info
+// cache. A layer shared by several images of a repository is fetched once. It
+// returns the number of repositories, of layers cached and of layers missing.
+func rebuildLayerInfoCache(ctx context.Context, registry distribution.Namespace, images []imageapi.Image, registryAddr string, registryAliases sets.String) (int, int, int) {
+	repositories := sets.NewString()
+	fetched := sets.NewString()
+	var layers, missing int
+	for i := range images {
+		image := &images[i]
+
+		ref, err := imageapi.ParseDockerImageReference(image.DockerImageReference)
+		if err != nil || !isLocalImage(image, registryAddr, registryAliases) || len(ref.Namespace) == 0 {
+			continue
+		}
+
+		if len(image.DockerImageManifest) == 0 {
+			continue
+		}
+		var m manifest.SignedManifest
+		if err := json.Unmarshal([]byte(image.DockerImageManifest), &m); err != nil {
+			ctxu.GetLogger(ctx).Errorf("error unmarshaling manifest of image %q: %v", image.Name, err)
+			continue
+		}
+
+		repoName := fmt.Sprintf("%s/%s", ref.Namespace, ref.Name)
+		repo, err := registry.Repository(ctx, repoName)
+		if err != nil {
+			ctxu.GetLogger(ctx).Errorf("error getting repository %q: %v", repoName, err)
+			continue
+		}
+		repositories.Insert(repoName)
+
+		for _, fsLayer := range m.FSLayers {
+			key := repoName + "@" + fsLayer.BlobSum.String()
+			if fetched.Has(key) {
+				continue
+			}
+			fetched.Insert(key)
+
+			layer, err := repo.Layers().Fetch(fsLayer.BlobSum)
+			if err != nil {
+				ctxu.GetLogger(ctx).Warnf("unable to cache layer %q of repository %q: %v", fsLayer.BlobSum, repoName, err)
+				missing++
+				continue
+			}
+			layer.Close()
+			layers++
+		}
+	}
+	return repositories.Len(), layers, missing
+}
+
+// defaultOrphanedBlobMinAge protects recently written blobs from being
+// reported as orphaned, e.g. the layers of a push in progress.
+const defaultOrphanedBlobMinAge = time.Hour
+
+// OrphanedBlob is a blob that no image references. The
+// orphaned blobs are listed as one JSON object per line.
+type OrphanedBlob struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// BlobListDispatcher returns the dispatcher for listing the blobs stored by
+// driver. The upstream app doesn't expose its storage driver, so it has to be
+// passed in.
+func BlobListDispatcher(driver storagedriver.StorageDriver) func(*handlers.Context, *http.Request) http.Handler {
+	return func(ctx *handlers.Context, r *http.Request) http.Handler {
+		blobListHandler := &blobListHandler{
+			Context: ctx,
+			driver:  driver,
+		}
+
+		return gorillahandlers.MethodHandler{
+			"GET": http.HandlerFunc(blobListHandler.List),
+		}
+	}
+}
+
+// blobListHandler handles the listing of blobs.
+type blobListHandler struct {
+	*handlers.Context
+
+	driver storagedriver.StorageDriver
+}
+
+// List streams the blobs of the storage backend that aren't referenced by any
+// image, i.e. those GarbageCollect would delete. Only
+// the listing of orphaned blobs is supported, requested with orphaned=true.
+// Blobs modified less than minAge ago, an hour by default, are left out.
+func (bh *blobListHandler) List(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+	if query.Get("orphaned") != "true" {
+		bh.Errors.Push(v2.ErrorCodeUnsupported, "only orphaned=true is supported")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	minAge := defaultOrphanedBlobMinAge
+	if value := query.Get("minAge"); len(value) > 0 {
+		var err error
+		if minAge, err = time.ParseDuration(value); err != nil {
+			bh.Errors.PushErr(fmt.Errorf("invalid minAge %q: %v", value, err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	registryClient, err := NewRegistryOpenShiftClient()
+	if err != nil {
+		bh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	images, err := registryClient.Images().List(labels.Everything(), fields.Everything())
+	if err != nil {
+		bh.Errors.PushErr(fmt.Errorf("error listing images: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	stream := newJSONStream(w)
+	result, err := GarbageCollect(bh.driver, images.Items, GCOptions{
+		MinAge: minAge,
+		DryRun: true,
+		Report: func(dgst digest.Digest, size int64) {
+			stream.encode(&OrphanedBlob{Digest: dgst.String(), Size: size})
+		},
+	})
+	if err != nil {
+		// the status was sent already, the error ends the stream
+		ctxu.GetLogger(bh).Errorf("error listing orphaned blobs: %v", err)
+		stream.encode(map[string]string{"error": err.Error()})
+		return
+	}
+	ctxu.GetLogger(bh).Infof("listed %d orphaned blobs (%d bytes), %d blobs referenced", result.Deleted, result.DeletedBytes, result.Kept)
+}
+
+// pruneJobsRoot is where the status of the prune jobs is kept, so that any
+// replica of the registry can report it.
+const pruneJobsRoot = "/docker/registry/v2/openshift/prunejobs"
+
+// The states of a prune job.
+const (
+	PruneJobRunning   = "Running"
+	PruneJobSucceeded = "Succeeded"
+	PruneJobFailed    = "Failed"
+)
+
+// PruneJob is the status of a pruning of the storage, which runs in the
+// background.
+type PruneJob struct {
+	ID string `json:"id"`
+	// State is PruneJobRunning until the job ends, and then PruneJobSucceeded
+	// or PruneJobFailed.
+	State    string     `json:"state"`
+	DryRun   bool       `json:"dryRun"`
+	MinAge   string     `json:"minAge"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// DeletedLinks, DeletedBlobs and DeletedBytes count what was deleted, or
+	// would be in a dry run, once the job succeeded.
+	DeletedLinks int   `json:"deletedLinks"`
+	DeletedBlobs int   `json:"deletedBlobs"`
+	DeletedBytes int64 `json:"deletedBytes"`
+	// Error is why the job failed.
+	Error string `json:"error,omitempty"`
+}
+
+// pruneJobPath returns the path of the status of the prune job id.
+func pruneJobPath(id string) string {
+	return path.Join(pruneJobsRoot, id)
+}
+
+// PruneDispatcher returns the dispatcher for pruning the storage of driver.
+func PruneDispatcher(driver storagedriver.StorageDriver) func(*handlers.Context, *http.Request) http.Handler {
+	return func(ctx *handlers.Context, r *http.Request) http.Handler {
+		pruneHandler := &pruneHandler{
+			Context: ctx,
+			driver:  driver,
+		}
+
+		return gorillahandlers.MethodHandler{
+			"POST": http.HandlerFunc(pruneHandler.Prune),
+		}
+	}
+}
+
+// PruneJobDispatcher returns the dispatcher for the status of the prune jobs
+// of the storage of driver.
+func PruneJobDispatcher(driver storagedriver.StorageDriver) func(*handlers.Context, *http.Request) http.Handler {
+	return func(ctx *handlers.Context, r *http.Request) http.Handler {
+		pruneHandler := &pruneHandler{
+			Context: ctx,
+			driver:  driver,
+			ID:      ctxu.GetStringValue(ctx, "vars.id"),
+		}
+
+		return gorillahandlers.MethodHandler{
+			"GET": http.HandlerFunc(pruneHandler.Get),
+		}
+	}
+}
+
+// pruneHandler handles the pruning of the storage.
+type pruneHandler struct {
+	*handlers.Context
+
+	driver storagedriver.StorageDriver
+	ID     string
+}
+
+// Prune starts a job deleting the manifest revisions and the layer links of
+// the repositories, and then the blobs, that aren't referenced by any image,
+// instead of a DELETE request for each of them. It answers 202 Accepted with
+// the PruneJob, whose status is at the location of the response, or 409
+// Conflict if the storage is being pruned already. Objects modified less than
+// minAge ago, an hour by default, are kept. With dryRun=true nothing is
+// deleted.
+//
+// The job holds the lock of the storage, so the writes to the repositories
+// wait for it to end and the objects they write can't be deleted. The caller
+// must be allowed to prune images, and the request is audited.
+func (ph *pruneHandler) Prune(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	w, audit := auditedAction(w, req, "prune", "storage", "", req.URL.RawQuery)
+	defer audit()
+	if !authorizeDeletion(ph.Context, w, req) {
+		return
+	}
+
+	query := req.URL.Query()
+	minAge := defaultOrphanedBlobMinAge
+	if value := query.Get("minAge"); len(value) > 0 {
+		var err error
+		if minAge, err = time.ParseDuration(value); err != nil {
+			ph.Errors.PushErr(fmt.Errorf("invalid minAge %q: %v", value, err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	dryRun := query.Get("dryRun") == "true"
+
+	// a dry run deletes nothing, the writes don't have to wait for it
+	unlock := func() {}
+	if storageLocks != nil && !dryRun {
+		var err error
+		if unlock, err = storageLocks.lockGC(); err != nil {
+			if err == errPruneRunning {
+				ph.Errors.PushErr(err)
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			ph.Errors.PushErr(fmt.Errorf("error locking the storage: %v", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	job := &PruneJob{
+		ID:      randomLockSuffix(),
+		State:   PruneJobRunning,
+		DryRun:  dryRun,
+		MinAge:  minAge.String(),
+		Started: time.Now().UTC(),
+	}
+	if err := putPruneJob(ph.driver, job); err != nil {
+		unlock()
+		ph.Errors.PushErr(fmt.Errorf("error recording the prune job: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/prune/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+
+	go func() {
+		defer unlock()
+		runPruneJob(ph.driver, job, minAge, ctxu.GetLogger(ph))
+	}()
+}
+
+// Get answers the status of the prune job of the request, to the users
+// allowed to prune images.
+func (ph *pruneHandler) Get(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	if !authorizeDeletion(ph.Context, w, req) {
+		return
+	}
+
+	content, err := ph.driver.GetContent(pruneJobPath(ph.ID))
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			ph.Errors.Push(v2.ErrorCodeUnknown, fmt.Sprintf("unknown prune job %q", ph.ID))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ph.Errors.PushErr(fmt.Errorf("error reading the prune job %q: %v", ph.ID, err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// runPruneJob prunes the storage of driver and records the end of job.
+func runPruneJob(driver storagedriver.StorageDriver, job *PruneJob, minAge time.Duration, logger ctxu.Logger) {
+	err := pruneStorage(driver, job, minAge)
+	finished := time.Now().UTC()
+	job.Finished = &finished
+	if err != nil {
+		logger.Errorf("error pruning the storage: %v", err)
+		job.State = PruneJobFailed
+		job.Error = err.Error()
+	} else {
+		logger.Infof("pruned %d repository links and %d blobs (%d bytes), dry run: %t", job.DeletedLinks, job.DeletedBlobs, job.DeletedBytes, job.DryRun)
+		job.State = PruneJobSucceeded
+	}
+	if err := putPruneJob(driver, job); err != nil {
+		logger.Errorf("error recording the end of prune job %s: %v", job.ID, err)
+	}
+}
+
+// pruneStorage waits for the writes in progress, unless job is a dry run, and
+// deletes the repository links and then the blobs no image references. It
+// counts them in job.
+func pruneStorage(driver storagedriver.StorageDriver, job *PruneJob, minAge time.Duration) error {
+	if storageLocks != nil && !job.DryRun {
+		if err := storageLocks.waitForWrites(); err != nil {
+			return fmt.Errorf("error waiting for the writes in progress: %v", err)
+		}
+	}
+
+	registryClient, err := NewRegistryOpenShiftClient()
+	if err != nil {
+		return err
+	}
+	images, err := registryClient.Images().List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing images: %v", err)
+	}
+
+	options := GCOptions{MinAge: minAge, DryRun: job.DryRun}
+	links, err := PruneRepositoryLinks(driver, images.Items, options)
+	if !job.DryRun {
+		// the cached layers may point at deleted links
+		defer layerCache.forgetAll()
+	}
+	if err != nil {
+		return fmt.Errorf("error pruning repositories: %v", err)
+	}
+	result, err := GarbageCollect(driver, images.Items, options)
+	if err != nil {
+		return fmt.Errorf("error pruning blobs: %v", err)
+	}
+	job.DeletedLinks = links
+	job.DeletedBlobs = result.Deleted
+	job.DeletedBytes = result.DeletedBytes
+	return nil
+}
+
+// putPruneJob records the status of job in the storage of driver.
+func putPruneJob(driver storagedriver.StorageDriver, job *PruneJob) error {
+	content, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return driver.PutContent(pruneJobPath(job.ID), content)
+}
+
+// jsonStream writes a JSON object per line and flushes it to the client.
+type jsonStream struct {
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+func newJSONStream(w http.ResponseWriter) *jsonStream {
+	flusher, _ := w.(http.Flusher)
+	return &jsonStream{encoder: json.NewEncoder(w), flusher: flusher}
+}
+
+func (s *jsonStream) encode(v interface{}) {
+	if err := s.encoder.Encode(v); err != nil {
+		return
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
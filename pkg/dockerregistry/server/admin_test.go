@@ -0,0 +1,413 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/handlers"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/api/latest"
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestListOrphanedBlobs(t *testing.T) {
+	driver := inmemory.New()
+	for _, dgst := range []string{testDigest1, testDigest2} {
+		p := blobsRoot + "/sha256/00/" + strings.TrimPrefix(dgst, "sha256:") + "/data"
+		if err := driver.PutContent(p, []byte("content")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	images := &imageapi.ImageList{Items: []imageapi.Image{{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:        testDigest1,
+			Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"},
+		},
+	}}}
+
+	server, actions := simulateOpenShiftMaster([]response{{200, runtime.EncodeOrDie(latest.Codec, images)}})
+	defer server.Close()
+
+	ctx := &handlers.Context{Context: context.Background()}
+	req, _ := http.NewRequest("GET", "/admin/blobs?orphaned=true&minAge=0s", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	BlobListDispatcher(driver)(ctx, req).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || len(ctx.Errors.Errors) != 0 {
+		t.Fatalf("unexpected response %d: %v", w.Code, ctx.Errors)
+	}
+	if len(*actions) != 1 || (*actions)[0] != "GET /oapi/v1/images" {
+		t.Errorf("unexpected actions %v", *actions)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one orphaned blob, got %q", w.Body.String())
+	}
+	var blob OrphanedBlob
+	if err := json.Unmarshal([]byte(lines[0]), &blob); err != nil {
+		t.Fatal(err)
+	}
+	if blob.Digest != testDigest2 || blob.Size != int64(len("content")) {
+		t.Errorf("unexpected orphaned blob %#v", blob)
+	}
+
+	// the blobs are too recent with the default minimum age
+	server2, _ := simulateOpenShiftMaster([]response{{200, runtime.EncodeOrDie(latest.Codec, images)}})
+	defer server2.Close()
+	req, _ = http.NewRequest("GET", "/admin/blobs?orphaned=true", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	BlobListDispatcher(driver)(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || len(strings.TrimSpace(w.Body.String())) != 0 {
+		t.Errorf("expected no orphaned blob, got %d: %q", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/blobs", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	BlobListDispatcher(driver)(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for listing all blobs, got %d", w.Code)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	driver := inmemory.New()
+	for _, dgst := range []string{testDigest1, testDigest2} {
+		hex := strings.TrimPrefix(dgst, "sha256:")
+		for _, p := range []string{
+			blobsRoot + "/sha256/00/" + hex + "/data",
+			repositoriesRoot + "/ns/app/_manifests/revisions/sha256/" + hex + "/link",
+			repositoriesRoot + "/ns/app/_layers/sha256/" + hex + "/link",
+		} {
+			if err := driver.PutContent(p, []byte(dgst)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	images := &imageapi.ImageList{Items: []imageapi.Image{{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:        testDigest1,
+			Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"},
+		},
+	}}}
+
+	defer func(sink AuditSink, cache *accessCache) {
+		deletionAuditSink, deletionAccessCache = sink, cache
+	}(deletionAuditSink, deletionAccessCache)
+	audit := &bytes.Buffer{}
+	UseDeletionAuditSink(NewWriterAuditSink(audit))
+	deletionAccessCache, _ = newAccessCache(nil)
+
+	server, _ := simulateOpenShiftMaster([]response{
+		{200, runtime.EncodeOrDie(latest.Codec, &authorizationapi.SubjectAccessReviewResponse{Allowed: true})},
+		{200, runtime.EncodeOrDie(latest.Codec, images)},
+	})
+	defer server.Close()
+	userClient, err := NewUserOpenShiftClient("pruner-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticatedUsers.remember("pruner-token", "pruner")
+	pruneContext := func(ctx context.Context) *handlers.Context {
+		return &handlers.Context{Context: WithUserClient(ctx, userClient)}
+	}
+	pruneRequest := func(method, url string) *http.Request {
+		req, _ := http.NewRequest(method, url, strings.NewReader(""))
+		req.Header.Set("Authorization", "Bearer pruner-token")
+		return req
+	}
+
+	// the users who can't prune images can't start a job
+	req, _ := http.NewRequest("POST", "/admin/prune?minAge=0s", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	PruneDispatcher(driver)(pruneContext(context.Background()), req).ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an unverified user, got %d", w.Code)
+	}
+	var record AuditRecord
+	if err := json.NewDecoder(audit).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Kind != "storage" || record.Action != "prune" || record.Reference != "minAge=0s" || record.Status != http.StatusForbidden {
+		t.Errorf("unexpected audit record %#v", record)
+	}
+
+	dir, err := ioutil.TempDir("", "prunelocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(locks *uploadLocks) { storageLocks = locks }(storageLocks)
+	storageLocks = &uploadLocks{dir: dir, ttl: time.Minute, wait: 50 * time.Millisecond}
+
+	// a push in progress
+	unlockWrite, err := storageLocks.lockWrite(writeLockPrefix + "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := pruneContext(context.Background())
+	req = pruneRequest("POST", "/admin/prune?minAge=0s")
+	w = httptest.NewRecorder()
+	PruneDispatcher(driver)(ctx, req).ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted || len(ctx.Errors.Errors) != 0 {
+		t.Fatalf("unexpected response %d: %v", w.Code, ctx.Errors)
+	}
+	record = AuditRecord{}
+	if err := json.NewDecoder(audit).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.User != "pruner" || record.Action != "prune" || record.Status != http.StatusAccepted || record.Outcome != "success" {
+		t.Errorf("unexpected audit record %#v", record)
+	}
+	var job PruneJob
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if location := w.Header().Get("Location"); location != "/admin/prune/"+job.ID || job.State != PruneJobRunning {
+		t.Fatalf("unexpected job %#v at %q", job, location)
+	}
+
+	// a single job runs at a time
+	req = pruneRequest("POST", "/admin/prune?minAge=0s")
+	w = httptest.NewRecorder()
+	PruneDispatcher(driver)(pruneContext(context.Background()), req).ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a second job, got %d", w.Code)
+	}
+
+	// the writes wait for the job, which waits for the push in progress
+	if _, err := storageLocks.lockWrite(writeLockPrefix + "other"); err != errStoragePruned {
+		t.Errorf("expected the writes to wait for the pruning, got %v", err)
+	}
+	if job := getPruneJob(t, driver, userClient, job.ID); job.State != PruneJobRunning {
+		t.Errorf("expected the job to wait for the push, got %#v", job)
+	}
+	unlockWrite()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for job.State == PruneJobRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		job = getPruneJob(t, driver, userClient, job.ID)
+	}
+	if job.State != PruneJobSucceeded || job.DeletedLinks != 2 || job.DeletedBlobs != 1 || job.Finished == nil {
+		t.Errorf("unexpected job %#v", job)
+	}
+	if storageLocks.held(gcLockName) {
+		t.Errorf("expected the job to release the lock of the storage")
+	}
+
+	hex := strings.TrimPrefix(testDigest2, "sha256:")
+	for _, p := range []string{
+		blobsRoot + "/sha256/00/" + hex,
+		repositoriesRoot + "/ns/app/_manifests/revisions/sha256/" + hex,
+		repositoriesRoot + "/ns/app/_layers/sha256/" + hex,
+	} {
+		if _, err := driver.Stat(p); err == nil {
+			t.Errorf("expected %s to be deleted", p)
+		}
+	}
+	hex = strings.TrimPrefix(testDigest1, "sha256:")
+	if _, err := driver.Stat(repositoriesRoot + "/ns/app/_manifests/revisions/sha256/" + hex + "/link"); err != nil {
+		t.Errorf("expected the referenced revision to be kept: %v", err)
+	}
+}
+
+func TestListManifests(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest3}, {Image: testDigest1}}},
+				"prod":   {Items: []imageapi.TagEvent{{Image: testDigest2}}},
+			},
+		},
+	}
+	client, _ := testclient.NewImageTrackerFake(stream)
+	r := &repository{
+		Repository:     &fakeLocalRepository{name: "ns/app"},
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+	}
+	ctx := &handlers.Context{Context: context.Background(), Repository: r}
+
+	req, _ := http.NewRequest("GET", "/admin/ns/app/manifests?n=2", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	ManifestListDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", w.Code, ctx.Errors)
+	}
+	var response manifestListAPIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(response.Manifests, []digest.Digest{testDigest1, testDigest2}) {
+		t.Errorf("unexpected first page %v", response.Manifests)
+	}
+	expectedLink := `</admin/ns/app/manifests?last=` + url.QueryEscape(testDigest2) + `&n=2>; rel="next"`
+	if link := w.Header().Get("Link"); link != expectedLink {
+		t.Errorf("expected link %s, got %s", expectedLink, link)
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/ns/app/manifests?n=2&last="+testDigest2, strings.NewReader(""))
+	w = httptest.NewRecorder()
+	ManifestListDispatcher(ctx, req).ServeHTTP(w, req)
+	response = manifestListAPIResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(response.Manifests, []digest.Digest{testDigest3}) || len(w.Header().Get("Link")) != 0 {
+		t.Errorf("unexpected last page %v, link %q", response.Manifests, w.Header().Get("Link"))
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/ns/app/manifests?last=latest", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	ManifestListDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid last digest, got %d", w.Code)
+	}
+}
+
+func TestAuthorizeDeletion(t *testing.T) {
+	defer func(sink AuditSink, cache *accessCache) {
+		deletionAuditSink, deletionAccessCache = sink, cache
+	}(deletionAuditSink, deletionAccessCache)
+	buf := &bytes.Buffer{}
+	UseDeletionAuditSink(NewWriterAuditSink(buf))
+	deletionAccessCache = nil
+
+	// deletions without an OpenShift user are denied and audited
+	ctx := &handlers.Context{Context: ctxu.WithValue(context.Background(), "vars.digest", testLayerDigest)}
+	req, _ := http.NewRequest("DELETE", "/admin/blobs/"+testLayerDigest, strings.NewReader(""))
+	w := httptest.NewRecorder()
+	BlobDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without an OpenShift user, got %d", w.Code)
+	}
+	var record AuditRecord
+	if err := json.NewDecoder(buf).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Kind != "blob" || record.Action != "delete" || record.Reference != testLayerDigest || record.Status != http.StatusForbidden || record.Outcome != "failure" {
+		t.Errorf("unexpected audit record %#v", record)
+	}
+
+	server, actions := simulateOpenShiftMaster([]response{
+		{200, runtime.EncodeOrDie(latest.Codec, &authorizationapi.SubjectAccessReviewResponse{Allowed: false, Reason: "not a pruner"})},
+		{200, runtime.EncodeOrDie(latest.Codec, &authorizationapi.SubjectAccessReviewResponse{Allowed: true})},
+		{200, runtime.EncodeOrDie(latest.Codec, &authorizationapi.SubjectAccessReviewResponse{Allowed: true})},
+	})
+	defer server.Close()
+	userClient, err := NewUserOpenShiftClient("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = &handlers.Context{Context: WithUserClient(context.Background(), userClient)}
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("user:token")))
+
+	// the token of the request wasn't verified by the access controller
+	w = httptest.NewRecorder()
+	if authorizeDeletion(ctx, w, req) || w.Code != http.StatusForbidden {
+		t.Errorf("expected the deletion by an unverified user to be denied, got %d", w.Code)
+	}
+	authenticatedUsers.remember("token", "user")
+
+	w = httptest.NewRecorder()
+	if authorizeDeletion(ctx, w, req) || w.Code != http.StatusForbidden {
+		t.Errorf("expected the deletion to be denied, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	if !authorizeDeletion(ctx, w, req) {
+		t.Errorf("expected the deletion to be allowed, got %d: %v", w.Code, ctx.Errors)
+	}
+	if len(*actions) != 2 || (*actions)[0] != "POST /oapi/v1/subjectaccessreviews" {
+		t.Errorf("expected a subject access review per deletion, got %v", *actions)
+	}
+
+	// a registry token is as good as an OpenShift one
+	authenticatedUsers.remember("registry-token", "user")
+	req.Header.Set("Authorization", "Bearer registry-token")
+	w = httptest.NewRecorder()
+	if !authorizeDeletion(ctx, w, req) {
+		t.Errorf("expected the deletion with a registry token to be allowed, got %d: %v", w.Code, ctx.Errors)
+	}
+}
+
+func getPruneJob(t *testing.T, driver storagedriver.StorageDriver, userClient *client.Client, id string) PruneJob {
+	ctx := &handlers.Context{Context: WithUserClient(ctxu.WithValue(context.Background(), "vars.id", id), userClient)}
+	req, _ := http.NewRequest("GET", "/admin/prune/"+id, strings.NewReader(""))
+	req.Header.Set("Authorization", "Bearer pruner-token")
+	w := httptest.NewRecorder()
+	PruneJobDispatcher(driver)(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", w.Code, ctx.Errors)
+	}
+	var job PruneJob
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestRebuildLayerInfoCache(t *testing.T) {
+	local := map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"}
+	images := []imageapi.Image{
+		{
+			ObjectMeta:           kapi.ObjectMeta{Name: testDigest1, Annotations: local},
+			DockerImageReference: "registry:5000/ns/app@" + testDigest1,
+			DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"},{"blobSum":"` + testDigest3 + `"},{"blobSum":"` + testLayerDigest + `"}]}`,
+		},
+		{
+			ObjectMeta:           kapi.ObjectMeta{Name: testDigest2, Annotations: local},
+			DockerImageReference: "registry:5000/ns/app@" + testDigest2,
+			DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+		},
+		{
+			ObjectMeta:           kapi.ObjectMeta{Name: testDigest3, Annotations: local},
+			DockerImageReference: "registry:5000/ns/web@" + testDigest3,
+			DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+		},
+		{
+			ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+			DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+			DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+		},
+	}
+	app := &fakeSourceRepository{}
+	registry := &fakeNamespace{repositories: map[string]distribution.Repository{
+		"ns/app": app,
+		"ns/web": &fakeLocalRepository{name: "ns/web"},
+	}}
+
+	repositories, layers, missing := rebuildLayerInfoCache(context.Background(), registry, images, "registry:5000", nil)
+	if repositories != 2 || layers != 2 || missing != 1 {
+		t.Errorf("expected 2 repositories, 2 layers cached and 1 missing, got %d, %d and %d", repositories, layers, missing)
+	}
+	// the layers shared by the images of ns/app are fetched once
+	if app.fetches != 2 {
+		t.Errorf("expected 2 fetches from ns/app, got %d", app.fetches)
+	}
+}
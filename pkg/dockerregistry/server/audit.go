@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// auditWebhookTimeout is how long posting a record to a webhook may take.
+	auditWebhookTimeout = 10 * time.Second
+	// auditWebhookQueueLength is the number of records buffered for delivery
+	// to a webhook before new ones are dropped.
+	auditWebhookQueueLength = 1000
+)
+
+// AuditRecord describes an operation on a manifest or blob.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// User is the OpenShift user the request was made by.
+	User string `json:"user"`
+	// ClientIP is the address of the client the request was made from.
+	ClientIP   string `json:"clientIP,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// Kind is either "manifest" or "blob", "tag" for the tag events removed
+	// by a deletion, or "storage" for a prune of the whole storage.
+	Kind string `json:"kind"`
+	// Action is one of "read", "write", "delete" or "prune".
+	Action string `json:"action"`
+	// Reference is the digest or tag of the manifest or blob, if known, or
+	// the query of a prune.
+	Reference string `json:"reference,omitempty"`
+	Status    int    `json:"status"`
+	// Outcome is either "success" or "failure".
+	Outcome string `json:"outcome"`
+}
+
+// AuditSink receives audit records.
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// NewAuditSinkFromEnv returns the audit sink configured by
+// REGISTRY_AUDIT_LOG, the path of a file records are appended to, or
+// REGISTRY_AUDIT_WEBHOOK, the URL records are posted to. It returns nil if
+// neither is set.
+func NewAuditSinkFromEnv() (AuditSink, error) {
+	if path := os.Getenv("REGISTRY_AUDIT_LOG"); len(path) > 0 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error opening audit log %q: %v", path, err)
+		}
+		return NewWriterAuditSink(f), nil
+	}
+	if url := os.Getenv("REGISTRY_AUDIT_WEBHOOK"); len(url) > 0 {
+		return NewWebhookAuditSink(url, &http.Client{Timeout: auditWebhookTimeout}), nil
+	}
+	return nil, nil
+}
+
+// writerAuditSink writes records as JSON lines.
+type writerAuditSink struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterAuditSink returns a sink writing one JSON object per line to w.
+func NewWriterAuditSink(w io.Writer) AuditSink {
+	return &writerAuditSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *writerAuditSink) Write(record *AuditRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.encoder.Encode(record)
+}
+
+// webhookAuditSink posts records to a URL in the background, so that the
+// requests being audited don't wait for the webhook.
+type webhookAuditSink struct {
+	url    string
+	client *http.Client
+	queue  chan *AuditRecord
+}
+
+// NewWebhookAuditSink returns a sink posting each record as JSON to url.
+func NewWebhookAuditSink(url string, client *http.Client) AuditSink {
+	s := &webhookAuditSink{
+		url:    url,
+		client: client,
+		queue:  make(chan *AuditRecord, auditWebhookQueueLength),
+	}
+	go s.run()
+	return s
+}
+
+func (s *webhookAuditSink) Write(record *AuditRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("audit webhook queue is full, dropping record")
+	}
+}
+
+func (s *webhookAuditSink) run() {
+	for record := range s.queue {
+		if err := s.post(record); err != nil {
+			log.Errorf("Error posting audit record to %s: %v", s.url, err)
+		}
+	}
+}
+
+func (s *webhookAuditSink) post(record *AuditRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+var (
+	auditManifestPath = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+	auditBlobPath     = regexp.MustCompile(`^/v2/(.+)/blobs/([^/]+)$`)
+	auditUploadPath   = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/[^/]*$`)
+)
+
+// auditRecordFor returns the record describing req, or nil if req doesn't
+// operate on a manifest or blob. Upload chunks and status requests aren't
+// audited, only starting a mount and completing an upload are. The deletions
+// made through the admin endpoints are audited by their handlers, see
+// auditDeletion.
+func auditRecordFor(req *http.Request) *AuditRecord {
+	action := ""
+	switch req.Method {
+	case "GET", "HEAD":
+		action = "read"
+	case "PUT", "POST":
+		action = "write"
+	case "DELETE":
+		action = "delete"
+	default:
+		return nil
+	}
+
+	path := req.URL.Path
+	record := &AuditRecord{Action: action}
+	var name string
+	switch {
+	case auditUploadPath.MatchString(path):
+		query := req.URL.Query()
+		switch {
+		case req.Method == "PUT":
+			record.Reference = query.Get("digest")
+		case req.Method == "POST" && len(query.Get("mount")) > 0:
+			record.Reference = query.Get("mount")
+		default:
+			return nil
+		}
+		record.Kind = "blob"
+		name = auditUploadPath.FindStringSubmatch(path)[1]
+	case auditManifestPath.MatchString(path):
+		m := auditManifestPath.FindStringSubmatch(path)
+		record.Kind, name, record.Reference = "manifest", m[1], m[2]
+	case auditBlobPath.MatchString(path):
+		m := auditBlobPath.FindStringSubmatch(path)
+		record.Kind, name, record.Reference = "blob", m[1], m[2]
+	default:
+		return nil
+	}
+
+	record.setRepository(name)
+	return record
+}
+
+// setRepository sets the namespace and the repository of r from the name of
+// the repository, if any.
+func (r *AuditRecord) setRepository(name string) {
+	if len(name) == 0 {
+		return
+	}
+	if namespace, repository, err := getNamespaceName(name); err == nil {
+		r.Namespace, r.Repository = namespace, repository
+	} else {
+		r.Repository = name
+	}
+}
+
+var (
+	// deletionAuditSink receives the records of the deletions made through
+	// the admin endpoints, which are audited even if no audit sink is
+	// configured: to the registry log by default.
+	deletionAuditSink AuditSink = logAuditSink{}
+)
+
+// UseDeletionAuditSink makes the admin endpoints write the records of their
+// deletions to sink rather than to the registry log.
+func UseDeletionAuditSink(sink AuditSink) {
+	deletionAuditSink = sink
+}
+
+// logAuditSink writes records to the registry log.
+type logAuditSink struct{}
+
+func (logAuditSink) Write(record *AuditRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	log.Infof("audit: %s", body)
+	return nil
+}
+
+// auditDeletion writes the record of the deletion of the object of the given
+// kind and reference, made by req, to deletionAuditSink. name is the
+// repository the object was deleted from, empty for blobs.
+func auditDeletion(req *http.Request, kind, name, reference string, status int) {
+	auditAction(req, "delete", kind, name, reference, status)
+}
+
+// auditAction writes the record of action on the object of the given kind and
+// reference, made by req through an admin endpoint, to deletionAuditSink.
+func auditAction(req *http.Request, action, kind, name, reference string, status int) {
+	record := &AuditRecord{
+		Time:      time.Now().UTC(),
+		User:      auditUser(req),
+		ClientIP:  requestClientIP(req),
+		Kind:      kind,
+		Action:    action,
+		Reference: reference,
+		Status:    status,
+		Outcome:   "success",
+	}
+	if status >= 400 {
+		record.Outcome = "failure"
+	}
+	record.setRepository(name)
+	if err := deletionAuditSink.Write(record); err != nil {
+		log.Errorf("Error writing audit record: %v", err)
+	}
+}
+
+// auditedDeletion returns a writer for the response to a deletion made
+// through an admin endpoint, and a function auditing the deletion with the
+// status of the response, to be called once it's sent.
+func auditedDeletion(w http.ResponseWriter, req *http.Request, kind, name, reference string) (http.ResponseWriter, func()) {
+	return auditedAction(w, req, "delete", kind, name, reference)
+}
+
+// auditedAction is auditedDeletion for any action.
+func auditedAction(w http.ResponseWriter, req *http.Request, action, kind, name, reference string) (http.ResponseWriter, func()) {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	return recorder, func() {
+		auditAction(req, action, kind, name, reference, recorder.status)
+	}
+}
+
+// WithAudit writes a record to sink for every manifest and blob operation
+// served by handler.
+func WithAudit(handler http.Handler, sink AuditSink) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		record := auditRecordFor(req)
+		if record == nil {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, req)
+
+		record.Time = time.Now().UTC()
+		record.User = auditUser(req)
+		record.ClientIP = requestClientIP(req)
+		record.Status = recorder.status
+		record.Outcome = "success"
+		if recorder.status >= 400 {
+			record.Outcome = "failure"
+		}
+		if err := sink.Write(record); err != nil {
+			log.Errorf("Error writing audit record: %v", err)
+		}
+	})
+}
+
+// auditUser returns the name of the user the access controller authenticated
+// for req, "anonymous" if req has no credentials, or "unknown".
+func auditUser(req *http.Request) string {
+	name, _ := authenticatedUsers.requestUser(req)
+	return name
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
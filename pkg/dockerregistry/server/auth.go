@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/util/sets"
 
 	log "github.com/Sirupsen/logrus"
 	ctxu "github.com/docker/distribution/context"
 	registryauth "github.com/docker/distribution/registry/auth"
 	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
 	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/cmd/server/bootstrappolicy"
 	"golang.org/x/net/context"
 )
 
@@ -35,7 +38,15 @@ func UserClientFrom(ctx context.Context) (*client.Client, bool) {
 }
 
 type AccessController struct {
-	realm string
+	realm      string
+	apiTimeout time.Duration
+	// accessCache remembers the results of access checks, nil disables it.
+	accessCache *accessCache
+	// users remembers the users owning the tokens of recent requests.
+	users *userCache
+	// mirror is true if the registry mirrors upstream registries, whose
+	// repositories aren't image streams of projects.
+	mirror bool
 }
 
 var _ registryauth.AccessController = &AccessController{}
@@ -68,7 +79,19 @@ func newAccessController(options map[string]interface{}) (registryauth.AccessCon
 		// Default to openshift if not present
 		realm = "origin"
 	}
-	return &AccessController{realm: realm}, nil
+	apiTimeout, err := apiTimeoutFrom(options)
+	if err != nil {
+		return nil, err
+	}
+	accessCache, err := newAccessCache(options)
+	if err != nil {
+		return nil, err
+	}
+	mirror, err := MirrorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &AccessController{realm: realm, apiTimeout: apiTimeout, accessCache: accessCache, users: authenticatedUsers, mirror: mirror.Enabled()}, nil
 }
 
 // Error returns the internal error string for this authChallenge.
@@ -104,6 +127,12 @@ func (ac *AccessController) wrapErr(err error) error {
 	}
 }
 
+// isHealthCheck returns true for the requests of the health and readiness
+// probes, which are served without credentials.
+func isHealthCheck(req *http.Request) bool {
+	return req.URL.Path == "/healthz" || req.URL.Path == "/readyz"
+}
+
 // Authorized handles checking whether the given request is authorized
 // for actions on resources allowed by openshift.
 // Sources of access records:
@@ -116,12 +145,27 @@ func (ac *AccessController) Authorized(ctx context.Context, accessRecords ...reg
 	}
 
 	// TODO: change this to an anonymous Access record, don't require a token for it, and fold into the access record check look below
-	if req.URL.Path == "/healthz" {
+	if isHealthCheck(req) {
 		return ctx, nil
 	}
 
 	bearerToken, err := getToken(req)
+	if err == ErrTokenRequired && ac.mirror && isPullOnly(accessRecords) {
+		// a mirror serves the public repositories of its upstream
+		// registries, which are pulled from anonymously
+		return ctx, nil
+	}
+	if err == ErrTokenRequired && isPullOnly(accessRecords) {
+		// public projects can be pulled from without credentials, others
+		// challenge the client for them
+		if anonymousErr := ac.verifyAnonymousPull(ctx, accessRecords); anonymousErr == nil {
+			return ctx, nil
+		}
+	}
 	if err != nil {
+		if err == ErrTokenInvalid {
+			ctxu.GetLogger(ctx).Errorf("Error reading the token of the request: %v", err)
+		}
 		return nil, ac.wrapErr(err)
 	}
 
@@ -130,22 +174,23 @@ func (ac *AccessController) Authorized(ctx context.Context, accessRecords ...reg
 		return nil, ac.wrapErr(err)
 	}
 
-	// In case of docker login, hits endpoint /v2
-	if len(accessRecords) == 0 {
-		if err := verifyOpenShiftUser(client); err != nil {
-			return nil, ac.wrapErr(err)
-		}
-	}
-
 	verifiedPrune := false
 
 	// Validate all requested accessRecords
 	// Only return failure errors from this loop. Success should continue to validate all records
 	for _, access := range accessRecords {
-		log.Debugf("Origin auth: checking for access to %s:%s:%s", access.Resource.Type, access.Resource.Name, access.Action)
+		ctxu.GetLogger(ctx).Debugf("Origin auth: checking for access to %s:%s:%s", access.Resource.Type, access.Resource.Name, access.Action)
 
 		switch access.Resource.Type {
 		case "repository":
+			// the repositories of a mirror, e.g. busybox or
+			// quay.io/coreos/etcd, don't belong to projects
+			if ac.mirror {
+				if access.Action != "pull" {
+					return nil, ac.wrapErr(ErrUnsupportedAction)
+				}
+				continue
+			}
 			imageStreamNS, imageStreamName, err := getNamespaceName(access.Resource.Name)
 			if err != nil {
 				return nil, ac.wrapErr(err)
@@ -168,12 +213,22 @@ func (ac *AccessController) Authorized(ctx context.Context, accessRecords ...reg
 				if verifiedPrune {
 					continue
 				}
-				if err := verifyPruneAccess(client); err != nil {
+				err := ac.accessCache.verify(accessCacheKey(bearerToken, "prune"), func() error {
+					return withDeadline(ctx, ac.apiTimeout, "create SubjectAccessReview", func(ctx context.Context) error {
+						return verifyPruneAccess(ctx, client)
+					})
+				})
+				if err != nil {
 					return nil, ac.wrapErr(err)
 				}
 				verifiedPrune = true
 			default:
-				if err := verifyImageStreamAccess(imageStreamNS, imageStreamName, verb, client); err != nil {
+				err := ac.accessCache.verify(accessCacheKey(bearerToken, verb, imageStreamNS, imageStreamName), func() error {
+					return withDeadline(ctx, ac.apiTimeout, "create LocalSubjectAccessReview", func(ctx context.Context) error {
+						return verifyImageStreamAccess(ctx, imageStreamNS, imageStreamName, verb, client)
+					})
+				})
+				if err != nil {
 					return nil, ac.wrapErr(err)
 				}
 			}
@@ -184,10 +239,24 @@ func (ac *AccessController) Authorized(ctx context.Context, accessRecords ...reg
 				if verifiedPrune {
 					continue
 				}
-				if err := verifyPruneAccess(client); err != nil {
+				err := ac.accessCache.verify(accessCacheKey(bearerToken, "prune"), func() error {
+					return withDeadline(ctx, ac.apiTimeout, "create SubjectAccessReview", func(ctx context.Context) error {
+						return verifyPruneAccess(ctx, client)
+					})
+				})
+				if err != nil {
 					return nil, ac.wrapErr(err)
 				}
 				verifiedPrune = true
+			case "metrics":
+				err := ac.accessCache.verify(accessCacheKey(bearerToken, "metrics"), func() error {
+					return withDeadline(ctx, ac.apiTimeout, "create SubjectAccessReview", func(ctx context.Context) error {
+						return verifyMetricsAccess(ctx, client)
+					})
+				})
+				if err != nil {
+					return nil, ac.wrapErr(err)
+				}
 			default:
 				return nil, ac.wrapErr(ErrUnsupportedAction)
 			}
@@ -196,9 +265,71 @@ func (ac *AccessController) Authorized(ctx context.Context, accessRecords ...reg
 		}
 	}
 
+	// In case of docker login, hits endpoint /v2, the user is the only thing
+	// verified. It's remembered for the handlers auditing and rate limiting
+	// the requests, and set as auth.user in the context, which the registry
+	// logs the request with.
+	userName, err := ac.users.verify(bearerToken, func() (string, error) {
+		name, err := fetchWithDeadline(ctx, ac.apiTimeout, "get user", func(ctx context.Context) (interface{}, error) {
+			return verifyOpenShiftUser(ctx, client)
+		})
+		if err != nil {
+			return "", err
+		}
+		return name.(string), nil
+	})
+	if err != nil {
+		return nil, ac.wrapErr(err)
+	}
+
+	ctx = registryauth.WithUser(ctx, registryauth.UserInfo{Name: userName})
 	return WithUserClient(ctx, client), nil
 }
 
+// isPullOnly returns true if accessRecords only ask to pull from
+// repositories.
+func isPullOnly(accessRecords []registryauth.Access) bool {
+	if len(accessRecords) == 0 {
+		return false
+	}
+	for _, access := range accessRecords {
+		if access.Resource.Type != "repository" || access.Action != "pull" {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyAnonymousPull checks that unauthenticated users may pull from the
+// repositories of accessRecords, i.e. that their projects made them public by
+// granting system:unauthenticated the access to image stream layers, e.g.
+// with the system:image-puller role. The check is made with the registry's
+// credentials.
+func (ac *AccessController) verifyAnonymousPull(ctx context.Context, accessRecords []registryauth.Access) error {
+	var registryClient *client.Client
+	for _, access := range accessRecords {
+		imageStreamNS, imageStreamName, err := getNamespaceName(access.Resource.Name)
+		if err != nil {
+			return err
+		}
+		err = ac.accessCache.verify(accessCacheKey("", "anonymous", imageStreamNS, imageStreamName), func() error {
+			if registryClient == nil {
+				var err error
+				if registryClient, err = NewRegistryOpenShiftClient(); err != nil {
+					return err
+				}
+			}
+			return withDeadline(ctx, ac.apiTimeout, "create LocalSubjectAccessReview", func(ctx context.Context) error {
+				return verifyAnonymousImageStreamAccess(ctx, imageStreamNS, imageStreamName, registryClient)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func getNamespaceName(resourceName string) (string, string, error) {
 	repoParts := strings.SplitN(resourceName, "/", 2)
 	if len(repoParts) != 2 {
@@ -224,7 +355,6 @@ func getToken(req *http.Request) (string, error) {
 
 	payload, err := base64.StdEncoding.DecodeString(basicToken)
 	if err != nil {
-		log.Errorf("Basic token decode failed: %s", err)
 		return "", ErrTokenInvalid
 	}
 
@@ -237,19 +367,51 @@ func getToken(req *http.Request) (string, error) {
 	return bearerToken, nil
 }
 
-func verifyOpenShiftUser(client *client.Client) error {
-	if _, err := client.Users().Get("~"); err != nil {
-		log.Errorf("Get user failed with error: %s", err)
+// requestUserClient returns a client acting as the user whose OpenShift token
+// authenticates req, or is carried by the registry token of req in token
+// authentication mode. It returns nil if req has no credentials. The master
+// verifies the token when the client is used.
+func requestUserClient(req *http.Request) (*client.Client, error) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) == 0 {
+		return nil, nil
+	}
+	if parts := strings.SplitN(authorization, " ", 2); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+		if tokenCredentials == nil {
+			return nil, ErrTokenRequired
+		}
+		claims, err := parseTokenClaims(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		bearerToken, err := tokenCredentials.open(claims.Credential)
+		if err != nil {
+			return nil, err
+		}
+		return NewUserOpenShiftClient(bearerToken)
+	}
+	bearerToken, err := getToken(req)
+	if err != nil {
+		return nil, err
+	}
+	return NewUserOpenShiftClient(bearerToken)
+}
+
+// verifyOpenShiftUser returns the name of the user owning the token of client.
+func verifyOpenShiftUser(ctx context.Context, client *client.Client) (string, error) {
+	user, err := client.Users().Get("~")
+	if err != nil {
+		ctxu.GetLogger(ctx).Errorf("Get user failed with error: %s", err)
 		if kerrors.IsUnauthorized(err) || kerrors.IsForbidden(err) {
-			return ErrOpenShiftAccessDenied
+			return "", ErrOpenShiftAccessDenied
 		}
-		return err
+		return "", err
 	}
 
-	return nil
+	return user.Name, nil
 }
 
-func verifyImageStreamAccess(namespace, imageRepo, verb string, client *client.Client) error {
+func verifyImageStreamAccess(ctx context.Context, namespace, imageRepo, verb string, client *client.Client) error {
 	sar := authorizationapi.LocalSubjectAccessReview{
 		Action: authorizationapi.AuthorizationAttributes{
 			Verb:         verb,
@@ -260,7 +422,7 @@ func verifyImageStreamAccess(namespace, imageRepo, verb string, client *client.C
 	response, err := client.LocalSubjectAccessReviews(namespace).Create(&sar)
 
 	if err != nil {
-		log.Errorf("OpenShift client error: %s", err)
+		ctxu.GetLogger(ctx).Errorf("OpenShift client error: %s", err)
 		if kerrors.IsUnauthorized(err) || kerrors.IsForbidden(err) {
 			return ErrOpenShiftAccessDenied
 		}
@@ -268,30 +430,63 @@ func verifyImageStreamAccess(namespace, imageRepo, verb string, client *client.C
 	}
 
 	if !response.Allowed {
-		log.Errorf("OpenShift access denied: %s", response.Reason)
+		ctxu.GetLogger(ctx).Errorf("OpenShift access denied: %s", response.Reason)
 		return ErrOpenShiftAccessDenied
 	}
 
 	return nil
 }
 
-func verifyPruneAccess(client *client.Client) error {
+func verifyAnonymousImageStreamAccess(ctx context.Context, namespace, imageRepo string, client client.Interface) error {
+	sar := authorizationapi.LocalSubjectAccessReview{
+		Action: authorizationapi.AuthorizationAttributes{
+			Verb:         "get",
+			Resource:     "imagestreams/layers",
+			ResourceName: imageRepo,
+		},
+		User:   bootstrappolicy.UnauthenticatedUsername,
+		Groups: sets.NewString(bootstrappolicy.UnauthenticatedGroup),
+	}
+	response, err := client.LocalSubjectAccessReviews(namespace).Create(&sar)
+	if err != nil {
+		ctxu.GetLogger(ctx).Errorf("OpenShift client error: %s", err)
+		if kerrors.IsUnauthorized(err) || kerrors.IsForbidden(err) {
+			return ErrOpenShiftAccessDenied
+		}
+		return err
+	}
+	if !response.Allowed {
+		ctxu.GetLogger(ctx).Debugf("Anonymous access to %s/%s denied: %s", namespace, imageRepo, response.Reason)
+		return ErrOpenShiftAccessDenied
+	}
+	return nil
+}
+
+func verifyPruneAccess(ctx context.Context, client *client.Client) error {
+	return verifyClusterAccess(ctx, client, "delete", "images")
+}
+
+func verifyMetricsAccess(ctx context.Context, client *client.Client) error {
+	return verifyClusterAccess(ctx, client, "get", authorizationapi.RegistryMetricsResource)
+}
+
+func verifyClusterAccess(ctx context.Context, client *client.Client, verb, resource string) error {
 	sar := authorizationapi.SubjectAccessReview{
 		Action: authorizationapi.AuthorizationAttributes{
-			Verb:     "delete",
-			Resource: "images",
+			Verb:     verb,
+			Resource: resource,
 		},
 	}
 	response, err := client.SubjectAccessReviews().Create(&sar)
 	if err != nil {
-		log.Errorf("OpenShift client error: %s", err)
+		ctxu.GetLogger(ctx).Errorf("OpenShift client error: %s", err)
 		if kerrors.IsUnauthorized(err) || kerrors.IsForbidden(err) {
 			return ErrOpenShiftAccessDenied
 		}
 		return err
 	}
 	if !response.Allowed {
-		log.Errorf("OpenShift access denied: %s", response.Reason)
+		ctxu.GetLogger(ctx).Errorf("OpenShift access denied: %s", response.Reason)
 		return ErrOpenShiftAccessDenied
 	}
 	return nil
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"testing"
 
+	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/auth"
 	"golang.org/x/net/context"
 
@@ -63,7 +64,7 @@ func TestVerifyImageStreamAccess(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = verifyImageStreamAccess("foo", "bar", "create", client)
+		err = verifyImageStreamAccess(context.Background(), "foo", "bar", "create", client)
 		if err == nil || test.expectedError == nil {
 			if err != test.expectedError {
 				t.Fatalf("verifyImageStreamAccess did not get expected error - got %s - expected %s", err, test.expectedError)
@@ -80,6 +81,8 @@ func TestAccessController(t *testing.T) {
 	options := map[string]interface{}{
 		"addr":       "https://openshift-example.com/osapi",
 		"apiVersion": latest.Version,
+		// the test cases share a token, but expect different responses
+		"accesscachettl": "0",
 	}
 	accessController, err := newAccessController(options)
 	if err != nil {
@@ -93,6 +96,8 @@ func TestAccessController(t *testing.T) {
 		expectedError      error
 		expectedChallenge  bool
 		expectedActions    []string
+		// expectedUser is the user remembered for the token, if any
+		expectedUser string
 	}{
 		"no token": {
 			access:            []auth.Access{},
@@ -100,6 +105,44 @@ func TestAccessController(t *testing.T) {
 			expectedError:     ErrTokenRequired,
 			expectedChallenge: true,
 		},
+		"anonymous pull from a public project": {
+			access: []auth.Access{{
+				Resource: auth.Resource{
+					Type: "repository",
+					Name: "foo/bar",
+				},
+				Action: "pull",
+			}},
+			openshiftResponses: []response{
+				{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Namespace: "foo", Allowed: true, Reason: "authorized!"})},
+			},
+			expectedError:     nil,
+			expectedChallenge: false,
+			expectedActions:   []string{"POST /oapi/v1/namespaces/foo/localsubjectaccessreviews"},
+		},
+		"anonymous pull from a private project": {
+			access: []auth.Access{{
+				Resource: auth.Resource{
+					Type: "repository",
+					Name: "foo/bar",
+				},
+				Action: "pull",
+			}},
+			openshiftResponses: []response{
+				{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Namespace: "foo", Allowed: false, Reason: "not authorized!"})},
+			},
+			expectedError:     ErrTokenRequired,
+			expectedChallenge: true,
+			expectedActions:   []string{"POST /oapi/v1/namespaces/foo/localsubjectaccessreviews"},
+		},
+		"anonymous push": {
+			access: []auth.Access{
+				{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "pull"},
+				{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "push"},
+			},
+			expectedError:     ErrTokenRequired,
+			expectedChallenge: true,
+		},
 		"invalid registry token": {
 			access: []auth.Access{{
 				Resource: auth.Resource{Type: "repository"},
@@ -161,6 +204,7 @@ func TestAccessController(t *testing.T) {
 			expectedError:     nil,
 			expectedChallenge: false,
 			expectedActions:   []string{"GET /oapi/v1/users/~"},
+			expectedUser:      "usr1",
 		},
 		"error running subject access review": {
 			access: []auth.Access{{
@@ -229,10 +273,15 @@ func TestAccessController(t *testing.T) {
 			basicToken: "b3BlbnNoaWZ0OmF3ZXNvbWU=",
 			openshiftResponses: []response{
 				{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Namespace: "foo", Allowed: true, Reason: "authorized!"})},
+				{200, runtime.EncodeOrDie(latest.Codec, &userapi.User{ObjectMeta: kapi.ObjectMeta{Name: "usr1"}})},
 			},
 			expectedError:     nil,
 			expectedChallenge: false,
-			expectedActions:   []string{"POST /oapi/v1/namespaces/foo/localsubjectaccessreviews"},
+			expectedActions: []string{
+				"POST /oapi/v1/namespaces/foo/localsubjectaccessreviews",
+				"GET /oapi/v1/users/~",
+			},
+			expectedUser: "usr1",
 		},
 		"pruning": {
 			access: []auth.Access{
@@ -253,9 +302,28 @@ func TestAccessController(t *testing.T) {
 			basicToken: "b3BlbnNoaWZ0OmF3ZXNvbWU=",
 			openshiftResponses: []response{
 				{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Allowed: true, Reason: "authorized!"})},
+				{200, runtime.EncodeOrDie(latest.Codec, &userapi.User{ObjectMeta: kapi.ObjectMeta{Name: "usr1"}})},
 			},
 			expectedError:     nil,
 			expectedChallenge: false,
+			expectedActions: []string{
+				"POST /oapi/v1/subjectaccessreviews",
+				"GET /oapi/v1/users/~",
+			},
+		},
+		"metrics": {
+			access: []auth.Access{{
+				Resource: auth.Resource{
+					Type: "admin",
+				},
+				Action: "metrics",
+			}},
+			basicToken: "b3BlbnNoaWZ0OmF3ZXNvbWU=",
+			openshiftResponses: []response{
+				{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Allowed: false, Reason: "not authorized!"})},
+			},
+			expectedError:     ErrOpenShiftAccessDenied,
+			expectedChallenge: true,
 			expectedActions: []string{
 				"POST /oapi/v1/subjectaccessreviews",
 			},
@@ -271,7 +339,10 @@ func TestAccessController(t *testing.T) {
 		if len(test.basicToken) > 0 {
 			req.Header.Set("Authorization", fmt.Sprintf("Basic %s", test.basicToken))
 		}
-		ctx := context.WithValue(nil, "http.request", req)
+		ctx := context.WithValue(context.Background(), "http.request", req)
+
+		users := newUserCache(0, 10)
+		accessController.(*AccessController).users = users
 
 		server, actions := simulateOpenShiftMaster(test.openshiftResponses)
 		authCtx, err := accessController.Authorized(ctx, test.access...)
@@ -295,6 +366,12 @@ func TestAccessController(t *testing.T) {
 				t.Errorf("%s: expected auth context but got nil", k)
 				continue
 			}
+			if user, _ := users.requestUser(req); len(test.expectedUser) > 0 && user != test.expectedUser {
+				t.Errorf("%s: expected the token to be remembered as owned by %s, got %s", k, test.expectedUser, user)
+			}
+			if name := ctxu.GetStringValue(authCtx, "auth.user.name"); len(test.expectedUser) > 0 && name != test.expectedUser {
+				t.Errorf("%s: expected the context to hold the user %s, got %q", k, test.expectedUser, name)
+			}
 		} else {
 			_, isChallenge := err.(auth.Challenge)
 			if test.expectedChallenge != isChallenge {
@@ -314,6 +391,65 @@ func TestAccessController(t *testing.T) {
 	}
 }
 
+func TestAccessControllerMirror(t *testing.T) {
+	accessController := &AccessController{realm: "origin", users: newUserCache(0, 10), mirror: true}
+
+	tests := map[string]struct {
+		access             []auth.Access
+		basicToken         string
+		openshiftResponses []response
+		expectedError      error
+		expectedActions    []string
+	}{
+		"anonymous pull": {
+			access: []auth.Access{{Resource: auth.Resource{Type: "repository", Name: "busybox"}, Action: "pull"}},
+		},
+		"pull": {
+			access:     []auth.Access{{Resource: auth.Resource{Type: "repository", Name: "quay.io/coreos/etcd"}, Action: "pull"}},
+			basicToken: "dXNyMTphd2Vzb21l",
+			openshiftResponses: []response{
+				{200, runtime.EncodeOrDie(latest.Codec, &userapi.User{ObjectMeta: kapi.ObjectMeta{Name: "usr1"}})},
+			},
+			expectedActions: []string{"GET /oapi/v1/users/~"},
+		},
+		"anonymous push": {
+			access:        []auth.Access{{Resource: auth.Resource{Type: "repository", Name: "busybox"}, Action: "push"}},
+			expectedError: ErrTokenRequired,
+		},
+		"push": {
+			access:        []auth.Access{{Resource: auth.Resource{Type: "repository", Name: "busybox"}, Action: "push"}},
+			basicToken:    "dXNyMTphd2Vzb21l",
+			expectedError: ErrUnsupportedAction,
+		},
+	}
+	for k, test := range tests {
+		req, err := http.NewRequest("GET", "https://openshift-example.com/osapi", nil)
+		if err != nil {
+			t.Errorf("%s: %v", k, err)
+			continue
+		}
+		if len(test.basicToken) > 0 {
+			req.Header.Set("Authorization", fmt.Sprintf("Basic %s", test.basicToken))
+		}
+		ctx := context.WithValue(context.Background(), "http.request", req)
+
+		server, actions := simulateOpenShiftMaster(test.openshiftResponses)
+		_, err = accessController.Authorized(ctx, test.access...)
+		server.Close()
+
+		expectedActions := test.expectedActions
+		if expectedActions == nil {
+			expectedActions = []string{}
+		}
+		if !reflect.DeepEqual(actions, &expectedActions) {
+			t.Errorf("%s: expected\n\t%#v\ngot\n\t%#v", k, &expectedActions, actions)
+		}
+		if test.expectedError == nil && err != nil || test.expectedError != nil && (err == nil || err.Error() != test.expectedError.Error()) {
+			t.Errorf("%s: expected error %v, got %v", k, test.expectedError, err)
+		}
+	}
+}
+
 type response struct {
 	code int
 	body string
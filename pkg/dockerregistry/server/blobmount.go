@@ -0,0 +1,178 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/handlers"
+	gorillahandlers "github.com/gorilla/handlers"
+)
+
+// BlobMountPathPrefix is prepended to the path of cross repository blob mount
+// requests, so that they are dispatched to BlobMountDispatcher instead of the
+// upstream upload handler.
+const BlobMountPathPrefix = "/openshift/mount"
+
+// WithBlobMount routes cross repository blob mount requests, i.e.
+// POST /v2/<name>/blobs/uploads/?mount=<digest>&from=<repository>, below
+// BlobMountPathPrefix and passes all other requests through unchanged.
+func WithBlobMount(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isBlobMountRequest(r) {
+			mountURL := *r.URL
+			mountURL.Path = BlobMountPathPrefix + r.URL.Path
+			mountRequest := *r
+			mountRequest.URL = &mountURL
+			r = &mountRequest
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isBlobMountRequest(r *http.Request) bool {
+	if r.Method != "POST" || !strings.HasPrefix(r.URL.Path, "/v2/") || !strings.HasSuffix(r.URL.Path, "/blobs/uploads/") {
+		return false
+	}
+	query := r.URL.Query()
+	return len(query.Get("mount")) > 0 && len(query.Get("from")) > 0
+}
+
+// BlobMountAccessRecords requires pull access to the repository the blob is
+// mounted from, in addition to the push access to the target repository that
+// is required for every upload.
+func BlobMountAccessRecords(r *http.Request) []auth.Access {
+	from := r.URL.Query().Get("from")
+	if len(from) == 0 {
+		return nil
+	}
+	return []auth.Access{
+		{
+			Resource: auth.Resource{
+				Type: "repository",
+				Name: from,
+			},
+			Action: "pull",
+		},
+	}
+}
+
+// BlobMountDispatcher takes the request context and builds the appropriate
+// handler for handling cross repository blob mount requests.
+func BlobMountDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	query := r.URL.Query()
+	dgst, _ := digest.ParseDigest(query.Get("mount"))
+
+	blobMountHandler := &blobMountHandler{
+		Context: ctx,
+		Digest:  dgst,
+		From:    query.Get("from"),
+	}
+
+	return gorillahandlers.MethodHandler{
+		"POST": http.HandlerFunc(blobMountHandler.Mount),
+	}
+}
+
+// blobMountHandler handles cross repository blob mounts.
+type blobMountHandler struct {
+	*handlers.Context
+
+	Digest digest.Digest
+	From   string
+}
+
+// Mount links the layer with the requested digest from the source repository
+// into the repository of the request, so that the client doesn't have to
+// upload it again.
+func (bh *blobMountHandler) Mount(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	if len(bh.Digest) == 0 {
+		bh.Errors.Push(v2.ErrorCodeDigestInvalid)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	layers := bh.Repository.Layers()
+	exists, err := layers.Exists(bh.Digest)
+	if err != nil {
+		bh.Errors.PushErr(fmt.Errorf("error checking for layer %q in repo %q: %v", bh.Digest, bh.Repository.Name(), err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !exists {
+		source, err := bh.Registry().Repository(bh, bh.From)
+		if err == nil {
+			err = bh.copyLayer(source, layers)
+		}
+		if err != nil {
+			switch err := err.(type) {
+			case distribution.ErrUnknownLayer:
+				bh.Errors.Push(v2.ErrorCodeBlobUnknown, err.FSLayer)
+				w.WriteHeader(http.StatusNotFound)
+			case distribution.ErrRepositoryNameInvalid:
+				bh.Errors.Push(v2.ErrorCodeNameInvalid, err)
+				w.WriteHeader(http.StatusBadRequest)
+			default:
+				bh.Errors.PushErr(err)
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	blobURL, err := v2.NewURLBuilderFromRequest(req).BuildBlobURL(bh.Repository.Name(), bh.Digest)
+	if err != nil {
+		bh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", blobURL)
+	w.Header().Set("Content-Length", "0")
+	w.Header().Set("Docker-Content-Digest", bh.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// copyLayer uploads the layer from the source repository into layers. The
+// blob store is content addressable, so only the layer link is new. The
+// source is asked for the layer first, so that no upload is started for a
+// layer it doesn't have.
+func (bh *blobMountHandler) copyLayer(source distribution.Repository, layers distribution.LayerService) error {
+	exists, err := source.Layers().Exists(bh.Digest)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return distribution.ErrUnknownLayer{FSLayer: manifest.FSLayer{BlobSum: bh.Digest}}
+	}
+	layer, err := source.Layers().Fetch(bh.Digest)
+	if err != nil {
+		return err
+	}
+	defer layer.Close()
+
+	upload, err := layers.Upload()
+	if err != nil {
+		return err
+	}
+	if _, err := upload.ReadFrom(layer); err != nil {
+		upload.Cancel()
+		return err
+	}
+	if _, err := upload.Finish(bh.Digest); err != nil {
+		upload.Cancel()
+		return err
+	}
+
+	ctxu.GetLogger(bh).Infof("mounted layer %s from %s into %s", bh.Digest, bh.From, bh.Repository.Name())
+	return nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/handlers"
+	"golang.org/x/net/context"
+)
+
+func TestWithBlobMount(t *testing.T) {
+	tests := map[string]struct {
+		method       string
+		url          string
+		expectedPath string
+	}{
+		"mount": {
+			method:       "POST",
+			url:          "/v2/ns/is/blobs/uploads/?mount=sha256:abc&from=other/is",
+			expectedPath: BlobMountPathPrefix + "/v2/ns/is/blobs/uploads/",
+		},
+		"upload": {
+			method:       "POST",
+			url:          "/v2/ns/is/blobs/uploads/",
+			expectedPath: "/v2/ns/is/blobs/uploads/",
+		},
+		"mount without source": {
+			method:       "POST",
+			url:          "/v2/ns/is/blobs/uploads/?mount=sha256:abc",
+			expectedPath: "/v2/ns/is/blobs/uploads/",
+		},
+		"get": {
+			method:       "GET",
+			url:          "/v2/ns/is/blobs/uploads/?mount=sha256:abc&from=other/is",
+			expectedPath: "/v2/ns/is/blobs/uploads/",
+		},
+	}
+
+	for name, test := range tests {
+		var path string
+		handler := WithBlobMount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+		}))
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if path != test.expectedPath {
+			t.Errorf("%s: expected path %q, got %q", name, test.expectedPath, path)
+		}
+		if req.URL.Path == BlobMountPathPrefix+"/v2/ns/is/blobs/uploads/" {
+			t.Errorf("%s: original request was modified", name)
+		}
+	}
+}
+
+func TestBlobMountAccessRecords(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/v2/ns/is/blobs/uploads/?mount=sha256:abc&from=other/is", nil)
+	records := BlobMountAccessRecords(req)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 access record, got %#v", records)
+	}
+	if records[0].Type != "repository" || records[0].Name != "other/is" || records[0].Action != "pull" {
+		t.Errorf("unexpected access record %#v", records[0])
+	}
+
+	req, _ = http.NewRequest("POST", "/v2/ns/is/blobs/uploads/", nil)
+	if records := BlobMountAccessRecords(req); len(records) != 0 {
+		t.Errorf("expected no access records, got %#v", records)
+	}
+}
+
+// fakeSourceRepository stores the layer it serves, if any, and counts the
+// fetches of its layers.
+type fakeSourceRepository struct {
+	distribution.Repository
+	distribution.LayerService
+
+	stored  digest.Digest
+	fetches int
+}
+
+func (r *fakeSourceRepository) Layers() distribution.LayerService {
+	return r
+}
+
+func (r *fakeSourceRepository) Exists(dgst digest.Digest) (bool, error) {
+	return dgst == r.stored, nil
+}
+
+func (r *fakeSourceRepository) Fetch(dgst digest.Digest) (distribution.Layer, error) {
+	r.fetches++
+	return &remoteLayer{ReadCloser: ioutil.NopCloser(strings.NewReader("layer")), digest: dgst, length: 5}, nil
+}
+
+func TestBlobMountCopyLayer(t *testing.T) {
+	bh := &blobMountHandler{
+		Context: &handlers.Context{Context: context.Background(), Repository: &fakeLocalRepository{name: "ns/is"}},
+		Digest:  testLayerDigest,
+		From:    "other/is",
+	}
+
+	source, layers := &fakeSourceRepository{}, &fakeLocalLayers{}
+	if _, unknown := bh.copyLayer(source, layers).(distribution.ErrUnknownLayer); !unknown {
+		t.Errorf("expected a layer missing from the source to be unknown")
+	}
+	if source.fetches != 0 || len(layers.uploads) != 0 {
+		t.Errorf("expected the missing layer not to be streamed, got %d fetches and %d uploads", source.fetches, len(layers.uploads))
+	}
+
+	source.stored = testLayerDigest
+	if err := bh.copyLayer(source, layers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(layers.uploads) != 1 || layers.uploads[0].content.String() != "layer" || layers.uploads[0].finished != testLayerDigest {
+		t.Errorf("expected the layer to be copied, got %d uploads", len(layers.uploads))
+	}
+}
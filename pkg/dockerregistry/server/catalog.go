@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/handlers"
+	gorillahandlers "github.com/gorilla/handlers"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/client"
+)
+
+// CatalogPath is the path of the catalog endpoint.
+const CatalogPath = "/v2/_catalog"
+
+// CatalogDispatcher takes the request context and builds the appropriate
+// handler for handling catalog requests.
+func CatalogDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	catalogHandler := &catalogHandler{
+		Context: ctx,
+	}
+
+	return gorillahandlers.MethodHandler{
+		"GET": http.HandlerFunc(catalogHandler.GetCatalog),
+	}
+}
+
+// catalogHandler handles http operations on the catalog.
+type catalogHandler struct {
+	*handlers.Context
+}
+
+type catalogAPIResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+// GetCatalog lists the repositories of the image streams in all projects
+// visible to the user, in lexical order. The number of entries is limited by
+// the "n" parameter, listing starts after the "last" parameter. A Link header
+// points to the next page, if any.
+func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+	last := query.Get("last")
+	limit := -1
+	if n := query.Get("n"); len(n) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			ch.Errors.PushErr(fmt.Errorf("invalid number of entries %q", n))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	userClient, ok := UserClientFrom(ch)
+	if !ok {
+		ch.Errors.Push(v2.ErrorCodeUnauthorized)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	repositories, err := visibleRepositories(ch, userClient)
+	if err != nil {
+		ctxu.GetLogger(ch).Errorf("error listing repositories: %v", err)
+		ch.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	page, more := paginate(repositories, last, limit)
+	if more {
+		next := url.Values{}
+		next.Set("last", page[len(page)-1])
+		next.Set("n", strconv.Itoa(limit))
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", CatalogPath, next.Encode()))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(catalogAPIResponse{Repositories: page}); err != nil {
+		ch.Errors.PushErr(err)
+		return
+	}
+}
+
+// visibleRepositories returns the sorted names of the repositories of the
+// image streams in the projects the user can see. The projects whose image
+// streams can't be listed, e.g. because they are being deleted, are skipped
+// with a warning in the log of ctx.
+func visibleRepositories(ctx context.Context, userClient client.Interface) ([]string, error) {
+	projects, err := userClient.Projects().List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("error listing projects: %v", err)
+	}
+
+	repositories := []string{}
+	for _, project := range projects.Items {
+		streams, err := userClient.ImageStreams(project.Name).List(labels.Everything(), fields.Everything())
+		if err != nil {
+			ctxu.GetLogger(ctx).Warnf("Skipping project %s in the catalog: error listing its image streams: %v", project.Name, err)
+			continue
+		}
+		for _, stream := range streams.Items {
+			repositories = append(repositories, fmt.Sprintf("%s/%s", project.Name, stream.Name))
+		}
+	}
+	sort.Strings(repositories)
+	return repositories, nil
+}
+
+// paginate returns up to limit of the sorted names, of repositories or tags,
+// following last, and whether there are more. A negative limit returns all of
+// them.
+func paginate(names []string, last string, limit int) ([]string, bool) {
+	start := 0
+	if len(last) > 0 {
+		start = sort.Search(len(names), func(i int) bool { return names[i] > last })
+	}
+	page := names[start:]
+	if limit < 0 || len(page) <= limit {
+		return page, false
+	}
+	return page[:limit], limit > 0
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	projectapi "github.com/openshift/origin/pkg/project/api"
+)
+
+func TestVisibleRepositories(t *testing.T) {
+	client, _ := testclient.NewImageTrackerFake(
+		&imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "b", Name: "app"}},
+		&imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "a", Name: "web"}},
+		&imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "a", Name: "db"}},
+		&imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "hidden", Name: "secret"}},
+	)
+	client.AddReactor("list", "projects", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, &projectapi.ProjectList{
+			Items: []projectapi.Project{
+				{ObjectMeta: kapi.ObjectMeta{Name: "b"}},
+				{ObjectMeta: kapi.ObjectMeta{Name: "a"}},
+				{ObjectMeta: kapi.ObjectMeta{Name: "terminating"}},
+			},
+		}, nil
+	})
+	client.PrependReactor("list", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "terminating" {
+			return true, nil, errors.New("forbidden")
+		}
+		return false, nil, nil
+	})
+
+	repositories, err := visibleRepositories(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"a/db", "a/web", "b/app"}
+	if !reflect.DeepEqual(expected, repositories) {
+		t.Errorf("expected %v, got %v", expected, repositories)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	repositories := []string{"a/db", "a/web", "b/app", "c/api"}
+	tests := map[string]struct {
+		last         string
+		limit        int
+		expected     []string
+		expectedMore bool
+	}{
+		"all": {
+			limit:    -1,
+			expected: repositories,
+		},
+		"first page": {
+			limit:        2,
+			expected:     []string{"a/db", "a/web"},
+			expectedMore: true,
+		},
+		"last page": {
+			last:     "a/web",
+			limit:    2,
+			expected: []string{"b/app", "c/api"},
+		},
+		"after a removed repository": {
+			last:     "a/zzz",
+			limit:    -1,
+			expected: []string{"b/app", "c/api"},
+		},
+		"past the end": {
+			last:     "c/api",
+			limit:    2,
+			expected: []string{},
+		},
+	}
+	for name, test := range tests {
+		page, more := paginate(repositories, test.last, test.limit)
+		if !reflect.DeepEqual(test.expected, page) || more != test.expectedMore {
+			t.Errorf("%s: expected %v (more=%t), got %v (more=%t)", name, test.expected, test.expectedMore, page, more)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolHeaderTimeout bounds the time a trusted proxy may take to send
+// the PROXY protocol header of a connection.
+const proxyProtocolHeaderTimeout = 5 * time.Second
+
+// TrustedProxies are the networks of the proxies, e.g. the router or an
+// external load balancer, whose X-Forwarded-For headers and PROXY protocol
+// headers tell the address of the client.
+type TrustedProxies []*net.IPNet
+
+// Has returns true if ip belongs to a trusted proxy.
+func (p TrustedProxies) Has(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxiesFromEnv returns the proxies listed in
+// REGISTRY_TRUSTED_PROXIES, separated by commas, as addresses or networks,
+// e.g. 10.1.0.0/16,192.168.0.4.
+func TrustedProxiesFromEnv() (TrustedProxies, error) {
+	proxies := TrustedProxies{}
+	for _, value := range strings.Split(os.Getenv("REGISTRY_TRUSTED_PROXIES"), ",") {
+		if value = strings.TrimSpace(value); len(value) == 0 {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid REGISTRY_TRUSTED_PROXIES %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REGISTRY_TRUSTED_PROXIES %q", value)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ProxyProtocolFromEnv returns true if REGISTRY_PROXY_PROTOCOL makes the
+// registry expect the PROXY protocol header, version 1, on the connections of
+// the trusted proxies, e.g. of a load balancer passing TLS through.
+func ProxyProtocolFromEnv() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("REGISTRY_PROXY_PROTOCOL"))
+	return enabled
+}
+
+// WithClientIP sets the remote address of the requests to the address of the
+// client: the hops of X-Forwarded-For added by trusted proxies are skipped,
+// the others are ignored, so that clients can't pass for someone else. The
+// address is also set as X-Real-Ip, in place of X-Forwarded-For, for the
+// logs of the upstream handlers. The rate limits, the audit records and the
+// events then refer to the client rather than to the proxies.
+func WithClientIP(handler http.Handler, trusted TrustedProxies) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := clientIP(r, trusted); len(ip) > 0 {
+			if _, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				r.RemoteAddr = net.JoinHostPort(ip, port)
+			} else {
+				r.RemoteAddr = ip
+			}
+			r.Header.Set("X-Real-Ip", ip)
+		} else {
+			r.Header.Del("X-Real-Ip")
+		}
+		r.Header.Del("X-Forwarded-For")
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the client of r, or an empty string if the
+// request didn't come over IP, e.g. over a UNIX socket.
+func clientIP(r *http.Request, trusted TrustedProxies) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0 && trusted.Has(ip); i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+	}
+	return ip.String()
+}
+
+// requestClientIP returns the address of the client of r, as set by
+// WithClientIP.
+func requestClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// NewProxyProtocolListener returns a listener reading the PROXY protocol
+// header, version 1, sent by the trusted proxies at the start of their
+// connections: their remote address is the address of the client. The
+// connections of other peers are served as they are.
+func NewProxyProtocolListener(listener net.Listener, trusted TrustedProxies) net.Listener {
+	return &proxyProtocolListener{Listener: listener, trusted: trusted}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+
+	trusted TrustedProxies
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{Conn: conn, trusted: l.trusted, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtocolConn reads the header when it's first read from or asked for
+// its remote address.
+type proxyProtocolConn struct {
+	net.Conn
+
+	trusted    TrustedProxies
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	return c.remoteAddr
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.remoteAddr = c.Conn.RemoteAddr()
+	addr, ok := c.remoteAddr.(*net.TCPAddr)
+	if !ok || !c.trusted.Has(addr.IP) {
+		return
+	}
+
+	c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+	line, err := c.reader.ReadString('\n')
+	c.Conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		c.err = fmt.Errorf("error reading the PROXY protocol header from %s: %v", addr, err)
+		return
+	}
+	source, err := parseProxyProtocolHeader(line)
+	if err != nil {
+		c.err = fmt.Errorf("invalid PROXY protocol header from %s: %v", addr, err)
+		return
+	}
+	if source != nil {
+		c.remoteAddr = source
+	}
+}
+
+// parseProxyProtocolHeader returns the source address of a PROXY protocol
+// header, e.g. "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n", or nil
+// for the UNKNOWN protocol.
+func parseProxyProtocolHeader(line string) (*net.TCPAddr, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("%q isn't a PROXY protocol header", line)
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", fields[1])
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%q has %d fields", line, len(fields))
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source address %q", fields[2])
+	}
+	port, err := strconv.Atoi(fields[4])
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid source port %q", fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
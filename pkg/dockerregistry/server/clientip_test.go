@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTrustedProxiesFromEnv(t *testing.T) {
+	defer os.Setenv("REGISTRY_TRUSTED_PROXIES", os.Getenv("REGISTRY_TRUSTED_PROXIES"))
+
+	os.Setenv("REGISTRY_TRUSTED_PROXIES", "10.1.0.0/16, 192.168.0.4,fd00::1")
+	proxies, err := TrustedProxiesFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, trusted := range map[string]bool{
+		"10.1.2.3":    true,
+		"10.2.0.1":    false,
+		"192.168.0.4": true,
+		"192.168.0.5": false,
+		"fd00::1":     true,
+		"fd00::2":     false,
+	} {
+		if proxies.Has(net.ParseIP(ip)) != trusted {
+			t.Errorf("%s: expected trusted=%v", ip, trusted)
+		}
+	}
+
+	for _, value := range []string{"10.1.0.0/33", "router"} {
+		os.Setenv("REGISTRY_TRUSTED_PROXIES", value)
+		if _, err := TrustedProxiesFromEnv(); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestWithClientIP(t *testing.T) {
+	_, router, _ := net.ParseCIDR("10.1.0.0/16")
+	trusted := TrustedProxies{router}
+
+	for _, test := range []struct {
+		remoteAddr     string
+		forwardedFor   string
+		expectedAddr   string
+		expectedRealIP string
+	}{
+		{"192.168.0.1:1234", "", "192.168.0.1:1234", "192.168.0.1"},
+		// untrusted clients can't pass for someone else
+		{"192.168.0.1:1234", "172.17.0.1", "192.168.0.1:1234", "192.168.0.1"},
+		{"10.1.0.1:1234", "172.17.0.1", "172.17.0.1:1234", "172.17.0.1"},
+		// only the hops added by the trusted proxies are skipped
+		{"10.1.0.1:1234", "172.17.0.1, 192.168.0.1, 10.1.0.2", "192.168.0.1:1234", "192.168.0.1"},
+		{"10.1.0.1:1234", "garbage", "10.1.0.1:1234", "10.1.0.1"},
+		{"@", "172.17.0.1", "@", ""},
+	} {
+		var served *http.Request
+		handler := WithClientIP(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			served = req
+		}), trusted)
+		req, err := http.NewRequest("GET", "/v2/", strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = test.remoteAddr
+		if len(test.forwardedFor) > 0 {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		req.Header.Set("X-Real-Ip", "1.2.3.4")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if served.RemoteAddr != test.expectedAddr {
+			t.Errorf("%s %q: expected remote address %s, got %s", test.remoteAddr, test.forwardedFor, test.expectedAddr, served.RemoteAddr)
+		}
+		if realIP := served.Header.Get("X-Real-Ip"); realIP != test.expectedRealIP {
+			t.Errorf("%s %q: expected X-Real-Ip %q, got %q", test.remoteAddr, test.forwardedFor, test.expectedRealIP, realIP)
+		}
+		if forwardedFor := served.Header.Get("X-Forwarded-For"); len(forwardedFor) > 0 {
+			t.Errorf("%s %q: unexpected X-Forwarded-For %q", test.remoteAddr, test.forwardedFor, forwardedFor)
+		}
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+
+	for _, test := range []struct {
+		trusted      TrustedProxies
+		header       string
+		expectedAddr string
+		expectedData string
+	}{
+		{TrustedProxies{loopback}, "PROXY TCP4 192.168.0.1 127.0.0.1 56324 443\r\n", "192.168.0.1:56324", "GET"},
+		{TrustedProxies{loopback}, "PROXY TCP6 fd00::1 fd00::2 56324 443\r\n", "[fd00::1]:56324", "GET"},
+		{TrustedProxies{loopback}, "PROXY UNKNOWN\r\n", "127.0.0.1", "GET"},
+		// the connections of untrusted peers are served as they are
+		{TrustedProxies{}, "", "127.0.0.1", "GET"},
+		{TrustedProxies{loopback}, "GET / HTTP/1.1\r\n", "", ""},
+	} {
+		proxyListener := NewProxyProtocolListener(listener, test.trusted)
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Write([]byte(test.header + "GET")); err != nil {
+			t.Fatal(err)
+		}
+		conn, err := proxyListener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := make([]byte, 3)
+		n, err := conn.Read(data)
+		if len(test.expectedData) == 0 {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.header)
+			}
+		} else if err != nil || string(data[:n]) != test.expectedData {
+			t.Errorf("%q: expected %q, got %q, %v", test.header, test.expectedData, data[:n], err)
+		}
+		if addr := conn.RemoteAddr().String(); len(test.expectedAddr) > 0 && !strings.HasPrefix(addr, test.expectedAddr) {
+			t.Errorf("%q: expected remote address %s, got %s", test.header, test.expectedAddr, addr)
+		}
+		conn.Close()
+		client.Close()
+	}
+	listener.Close()
+}
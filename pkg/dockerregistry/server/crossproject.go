@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"golang.org/x/net/context"
+
+	"github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// errNoLocalRegistry is returned for the layers of other repositories when
+// UseLocalRegistry wasn't called.
+var errNoLocalRegistry = errors.New("the repositories of other image streams can't be opened")
+
+// localRegistry opens the repositories of this registry, so that the layers of
+// the images tagged from the image streams of other projects can be served
+// from the repositories they were pushed to. nil disables it.
+var localRegistry distribution.Namespace
+
+// UseLocalRegistry lets repositories serve the layers of images tagged from
+// other repositories of registry, which has to be backed by the same storage
+// as the app.
+func UseLocalRegistry(registry distribution.Namespace) {
+	localRegistry = registry
+}
+
+// sourceRepository returns the repository of this registry that the image of
+// event was tagged from, if it isn't the repository of r, e.g. because the
+// image was tagged from the image stream of another project. The reference of
+// the event is used rather than the one of the image, which points at the
+// repository the image was pushed to first.
+func (r *repository) sourceRepository(event imageapi.TagEvent, image *imageapi.Image) (imageapi.DockerImageReference, bool) {
+	if r.isRemoteImage(image) {
+		return imageapi.DockerImageReference{}, false
+	}
+	ref, err := imageapi.ParseDockerImageReference(event.DockerImageReference)
+	if err != nil || len(ref.Namespace) == 0 {
+		return imageapi.DockerImageReference{}, false
+	}
+	if ref.Namespace == r.namespace && ref.Name == r.name {
+		return imageapi.DockerImageReference{}, false
+	}
+	return ref, true
+}
+
+// verifySourceAccess verifies that the user of ctx can pull from the image
+// stream image was tagged from, when it isn't the image stream of r. Requests
+// without a user, i.e. anonymous pulls, may only pull from the image streams
+// of public projects.
+func (r *repository) verifySourceAccess(ctx context.Context, image *imageapi.Image) error {
+	imageStream, err := r.getImageStream(ctx)
+	if err != nil {
+		return err
+	}
+	event, ok := latestTagEvent(imageStream, image.Name)
+	if !ok {
+		return nil
+	}
+	ref, ok := r.sourceRepository(event, image)
+	if !ok {
+		return nil
+	}
+	userClient, _ := UserClientFrom(ctx)
+	return r.verifyPullAccess(ctx, ref, userClient)
+}
+
+// verifyPullAccess verifies that the user of userClient, or an anonymous user
+// if it's nil, can pull from the image stream of ref.
+func (r *repository) verifyPullAccess(ctx context.Context, ref imageapi.DockerImageReference, userClient *client.Client) error {
+	if userClient == nil {
+		return withDeadline(ctx, r.apiTimeout, "create LocalSubjectAccessReview", func(ctx context.Context) error {
+			return verifyAnonymousImageStreamAccess(ctx, ref.Namespace, ref.Name, r.registryClient)
+		})
+	}
+	return withDeadline(ctx, r.apiTimeout, "create LocalSubjectAccessReview", func(ctx context.Context) error {
+		return verifyImageStreamAccess(ctx, ref.Namespace, ref.Name, "get", userClient)
+	})
+}
+
+// latestTagEvent returns the most recent tag event of stream for the image
+// named imageName.
+func latestTagEvent(stream *imageapi.ImageStream, imageName string) (imageapi.TagEvent, bool) {
+	var latest imageapi.TagEvent
+	found := false
+	for _, history := range stream.Status.Tags {
+		for _, event := range history.Items {
+			if event.Image != imageName {
+				continue
+			}
+			if !found || event.Created.After(latest.Created.Time) {
+				latest = event
+				found = true
+			}
+			break
+		}
+	}
+	return latest, found
+}
+
+// findSourceLayer returns the repository of this registry that an image of
+// the image stream of r, tagged from another image stream, was tagged from and
+// whose manifest contains the given layer.
+func (r *repository) findSourceLayer(ctx context.Context, dgst digest.Digest) (imageapi.DockerImageReference, error) {
+	if localRegistry == nil {
+		return imageapi.DockerImageReference{}, errNoLocalRegistry
+	}
+	var ref imageapi.DockerImageReference
+	_, err := r.findLayerImage(ctx, dgst, func(event imageapi.TagEvent, image *imageapi.Image) bool {
+		sourceRef, ok := r.sourceRepository(event, image)
+		ref = sourceRef
+		return ok
+	})
+	if err != nil {
+		return imageapi.DockerImageReference{}, err
+	}
+	return ref, nil
+}
+
+// fetchSourceLayer returns the layer from the repository found by
+// findSourceLayer. The access of the user to that repository is verified when
+// the layer is served.
+func (r *repository) fetchSourceLayer(ctx context.Context, dgst digest.Digest) (distribution.Layer, *imageapi.DockerImageReference, error) {
+	ref, err := r.findSourceLayer(ctx, dgst)
+	if err != nil {
+		return nil, nil, err
+	}
+	source, err := localRegistry.Repository(ctx, fmt.Sprintf("%s/%s", ref.Namespace, ref.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	layer, err := source.Layers().Fetch(dgst)
+	if err != nil {
+		return nil, nil, err
+	}
+	return layer, &ref, nil
+}
+
+// verifyRequestSourceAccess verifies that the user of req can pull from the
+// image stream of source. The layer services aren't given the context of the
+// request, the user is told by the credentials of req instead.
+func (r *repository) verifyRequestSourceAccess(req *http.Request, source imageapi.DockerImageReference) error {
+	userClient, err := requestUserClient(req)
+	if err != nil {
+		return err
+	}
+	return r.verifyPullAccess(context.Background(), source, userClient)
+}
+
+// writeBlobUnknown tells the client that the layer dgst doesn't exist, the
+// layers the user isn't allowed to pull are hidden this way.
+func writeBlobUnknown(w http.ResponseWriter, dgst digest.Digest) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{
+			{
+				"code":    "BLOB_UNKNOWN",
+				"message": "blob unknown to registry",
+				"detail":  dgst.String(),
+			},
+		},
+	})
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/api/latest"
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	"github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/cmd/server/bootstrappolicy"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// fakeNamespace opens the given repositories.
+type fakeNamespace struct {
+	distribution.Namespace
+
+	repositories map[string]distribution.Repository
+}
+
+func (n *fakeNamespace) Repository(ctx context.Context, name string) (distribution.Repository, error) {
+	repo, ok := n.repositories[name]
+	if !ok {
+		return nil, fmt.Errorf("unexpected repository %s", name)
+	}
+	return repo, nil
+}
+
+func TestCrossProjectImage(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"prod": {Items: []imageapi.TagEvent{{
+					Image:                testDigest1,
+					DockerImageReference: "registry:5000/other/app@" + testDigest1,
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:        testDigest1,
+			Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"},
+		},
+		DockerImageReference: "registry:5000/other/app@" + testDigest1,
+		DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	r := &repository{
+		Repository:     &fakeLocalRepository{},
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+	}
+
+	saved := localRegistry
+	defer func() { localRegistry = saved }()
+	localRegistry = nil
+	if exists, _ := r.Layers().Exists(testLayerDigest); exists {
+		t.Errorf("expected the layer not to exist without the local registry")
+	}
+
+	UseLocalRegistry(&fakeNamespace{repositories: map[string]distribution.Repository{
+		"other/app": &fakeSizedRepository{sizes: map[digest.Digest]int64{testLayerDigest: 5}},
+	}})
+	// a layer exists if the user can pull it from the source repository, as
+	// when the manifest referencing it is pushed
+	for _, allowed := range []bool{true, false} {
+		client.PrependReactor("create", "localsubjectaccessreviews", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			return true, &authorizationapi.SubjectAccessReviewResponse{Namespace: "other", Allowed: allowed}, nil
+		})
+		if exists, err := r.Layers().Exists(testLayerDigest); err != nil || exists != allowed {
+			t.Errorf("allowed %t: unexpected existence of the layer of the source repository %t, %v", allowed, exists, err)
+		}
+	}
+	layer, err := r.Layers().Fetch(testLayerDigest)
+	if err != nil || layer.Length() != 5 {
+		t.Fatalf("expected the layer of the source repository, got %v, %v", layer, err)
+	}
+	if _, err := r.Layers().Fetch(testDigest2); err == nil {
+		t.Errorf("expected an error for a layer no image references")
+	}
+
+	for _, allowed := range []bool{true, false} {
+		server, actions := simulateOpenShiftMaster([]response{
+			{200, runtime.EncodeOrDie(latest.Codec, &authorizationapi.SubjectAccessReviewResponse{Namespace: "other", Allowed: allowed})},
+		})
+		userClient, err := NewUserOpenShiftClient("token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.verifySourceAccess(WithUserClient(context.Background(), userClient), image)
+		server.Close()
+		if allowed != (err == nil) {
+			t.Errorf("allowed %t: unexpected error %v", allowed, err)
+		}
+		if len(*actions) != 1 || (*actions)[0] != "POST /oapi/v1/namespaces/other/localsubjectaccessreviews" {
+			t.Errorf("allowed %t: unexpected actions %v", allowed, *actions)
+		}
+	}
+
+	// anonymous users may only pull the images and layers of public projects
+	for _, allowed := range []bool{true, false} {
+		var review *authorizationapi.LocalSubjectAccessReview
+		client.PrependReactor("create", "localsubjectaccessreviews", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			review = action.(ktestclient.CreateAction).GetObject().(*authorizationapi.LocalSubjectAccessReview)
+			return true, &authorizationapi.SubjectAccessReviewResponse{Namespace: "other", Allowed: allowed}, nil
+		})
+
+		err := r.verifySourceAccess(context.Background(), image)
+		if allowed != (err == nil) {
+			t.Errorf("anonymous, allowed %t: unexpected error %v", allowed, err)
+		}
+		if review == nil || review.User != bootstrappolicy.UnauthenticatedUsername || review.Action.ResourceName != "app" {
+			t.Errorf("anonymous, allowed %t: unexpected review %#v", allowed, review)
+		}
+
+		if allowed {
+			continue
+		}
+		req, _ := http.NewRequest("GET", "/v2/ns/app/blobs/"+testLayerDigest, nil)
+		handler, err := layer.Handler(req)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected the layer to be hidden from anonymous users, got %d", w.Code)
+		}
+	}
+}
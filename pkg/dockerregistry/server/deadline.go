@@ -0,0 +1,104 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+)
+
+// defaultAPITimeout is the time an operation against the master API may take
+// before the registry gives up on it.
+const defaultAPITimeout = 30 * time.Second
+
+// apiTimeouts counts the master API operations that didn't complete in time,
+// keyed by operation. It's published via expvar under registry.openshift.
+var apiTimeouts = new(expvar.Map).Init()
+
+func init() {
+	registry := expvar.Get("registry")
+	if registry == nil {
+		registry = expvar.NewMap("registry")
+	}
+
+	openshift := registry.(*expvar.Map).Get("openshift")
+	if openshift == nil {
+		openshift = &expvar.Map{}
+		openshift.(*expvar.Map).Init()
+		registry.(*expvar.Map).Set("openshift", openshift)
+	}
+
+	openshift.(*expvar.Map).Set("apitimeouts", apiTimeouts)
+}
+
+// apiTimeoutFrom returns the duration configured by the "apitimeout" option,
+// or defaultAPITimeout if it isn't set.
+func apiTimeoutFrom(options map[string]interface{}) (time.Duration, error) {
+	value, ok := options["apitimeout"]
+	if !ok {
+		return defaultAPITimeout, nil
+	}
+	timeout, err := time.ParseDuration(fmt.Sprintf("%v", value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid apitimeout %q: %v", value, err)
+	}
+	return timeout, nil
+}
+
+// withDeadline runs fn, which is expected to call the master API, and gives up
+// waiting for it once timeout elapses or ctx is done. In that case a timeout
+// status error is returned and the timeout is recorded for operation. fn is
+// passed a context which is canceled once withDeadline returns, so that it can
+// stop early. A timeout of zero disables the deadline.
+func withDeadline(ctx context.Context, timeout time.Duration, operation string, fn func(context.Context) error) error {
+	_, err := fetchWithDeadline(ctx, timeout, operation, func(ctx context.Context) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// fetchWithDeadline is withDeadline for an fn returning a result, which is
+// returned only if fn completes in time. fn runs in a goroutine of its own, it
+// mustn't write to the variables of its caller, which may have stopped waiting
+// for it.
+func fetchWithDeadline(ctx context.Context, timeout time.Duration, operation string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	value, _, err := runWithDeadline(ctx, timeout, operation, fn)
+	return value, err
+}
+
+// runWithDeadline is fetchWithDeadline, which also returns true if it gave up
+// waiting for fn.
+func runWithDeadline(ctx context.Context, timeout time.Duration, operation string, fn func(context.Context) (interface{}, error)) (interface{}, bool, error) {
+	defer observeAPICall(operation, time.Now())
+
+	if timeout <= 0 {
+		value, err := fn(ctx)
+		return value, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	// buffered, so that fn can finish after we stopped waiting for it
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn(ctx)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, false, result.err
+	case <-ctx.Done():
+		apiTimeouts.Add(operation, 1)
+		ctxu.GetLogger(ctx).Errorf("Timed out waiting for %s after %v: %v", operation, timeout, ctx.Err())
+		return nil, true, kerrors.NewTimeoutError(fmt.Sprintf("%s did not complete in %v", operation, timeout), 0)
+	}
+}
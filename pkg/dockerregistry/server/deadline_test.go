@@ -0,0 +1,71 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+)
+
+func TestWithDeadline(t *testing.T) {
+	expected := errors.New("failed")
+	if err := withDeadline(context.Background(), time.Second, "test", func(ctx context.Context) error { return expected }); err != expected {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	err := withDeadline(context.Background(), 10*time.Millisecond, "test", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	if !isGatewayTimeout(err) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = withDeadline(ctx, time.Minute, "test", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	if !isGatewayTimeout(err) {
+		t.Errorf("expected timeout error for a canceled context, got %v", err)
+	}
+}
+
+func TestFetchWithDeadline(t *testing.T) {
+	value, err := fetchWithDeadline(context.Background(), time.Second, "test", func(ctx context.Context) (interface{}, error) {
+		return "value", nil
+	})
+	if err != nil || value != "value" {
+		t.Errorf("expected the value to be returned, got %v, %v", value, err)
+	}
+
+	// the abandoned call is told to stop, its result is dropped
+	canceled := make(chan error)
+	value, err = fetchWithDeadline(context.Background(), 10*time.Millisecond, "test", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return "late", nil
+	})
+	if !isGatewayTimeout(err) || value != nil {
+		t.Errorf("expected timeout error, got %v, %v", value, err)
+	}
+	select {
+	case err := <-canceled:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the context of the call to be past its deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the context of the call to be canceled")
+	}
+}
+
+func isGatewayTimeout(err error) bool {
+	statusErr, ok := err.(*kerrors.StatusError)
+	return ok && statusErr.ErrStatus.Code == http.StatusGatewayTimeout
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultShutdownTimeout bounds how long a registry that is stopped waits for
+// the uploads and manifest puts in flight.
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownRetryAfter is how long clients are asked to wait before retrying a
+// request rejected while the registry is shutting down, by then another
+// replica should serve it.
+const shutdownRetryAfter = 5 * time.Second
+
+// ShutdownTimeoutFromEnv returns how long the writes in flight are waited for
+// on shutdown, from REGISTRY_SHUTDOWN_TIMEOUT, e.g. "1m".
+func ShutdownTimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("REGISTRY_SHUTDOWN_TIMEOUT")
+	if len(value) == 0 {
+		return defaultShutdownTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid REGISTRY_SHUTDOWN_TIMEOUT %q", value)
+	}
+	return timeout, nil
+}
+
+// Drainer tracks the writes in flight, i.e. the blob uploads, which may be
+// committing, and the manifest puts, so that the registry can wait for them
+// before it exits. The state of the upload sessions is kept in the storage,
+// the uploads not finished by then can be resumed on another replica.
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	writes   int
+	// idle is closed once draining and the last write is done
+	idle chan struct{}
+}
+
+// NewDrainer returns a Drainer which isn't draining.
+func NewDrainer() *Drainer {
+	return &Drainer{idle: make(chan struct{})}
+}
+
+// Handler serves the requests with handler until Drain is called. Then new
+// requests are rejected with 503 Service Unavailable, so that clients retry
+// them elsewhere, while the writes already in flight are completed.
+func (d *Drainer) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write := isWriteRequest(r)
+		if !d.start(write) {
+			w.Header().Set("Connection", "close")
+			writeUnavailable(w, shutdownRetryAfter, "the registry is shutting down")
+			return
+		}
+		if write {
+			defer d.done()
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// start returns false if the registry is draining, otherwise it counts the
+// write about to be served.
+func (d *Drainer) start(write bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	if write {
+		d.writes++
+	}
+	return true
+}
+
+func (d *Drainer) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writes--
+	if d.draining && d.writes == 0 {
+		close(d.idle)
+	}
+}
+
+// Draining returns true once Drain was called.
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Drain stops accepting requests, the writes in flight are completed.
+func (d *Drainer) Drain() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return
+	}
+	d.draining = true
+	if d.writes == 0 {
+		close(d.idle)
+	}
+}
+
+// Wait waits up to timeout for the writes in flight to finish once Drain was
+// called. It returns the number of writes still running after the timeout.
+func (d *Drainer) Wait(timeout time.Duration) int {
+	select {
+	case <-d.idle:
+		return 0
+	case <-time.After(timeout):
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.writes
+	}
+}
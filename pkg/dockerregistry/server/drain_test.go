@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainer(t *testing.T) {
+	drainer := NewDrainer()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := drainer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	upload := httptest.NewRecorder()
+	uploadDone := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("PUT", "/v2/ns/is/blobs/uploads/uuid?digest="+testLayerDigest, nil)
+		handler.ServeHTTP(upload, req)
+		close(uploadDone)
+	}()
+	<-started
+
+	drainer.Drain()
+	req, _ := http.NewRequest("GET", "/v2/ns/is/manifests/latest", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || len(w.Header().Get("Retry-After")) == 0 {
+		t.Errorf("expected new requests to be rejected while draining, got %d", w.Code)
+	}
+
+	if remaining := drainer.Wait(10 * time.Millisecond); remaining != 1 {
+		t.Errorf("expected the upload to be in flight, got %d writes", remaining)
+	}
+	close(release)
+	if remaining := drainer.Wait(time.Second); remaining != 0 {
+		t.Errorf("expected no write in flight, got %d", remaining)
+	}
+	<-uploadDone
+	if upload.Code != http.StatusCreated {
+		t.Errorf("expected the upload in flight to complete, got %d", upload.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/record"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const (
+	// ImagePushedReason is the reason of the event recorded on an image stream
+	// when an image is pushed to it.
+	ImagePushedReason = "Pushed"
+	// ImageDeletedReason is the reason of the event recorded on an image stream
+	// when the manifest of one of its images is deleted from the registry.
+	ImageDeletedReason = "Deleted"
+)
+
+var (
+	eventRecorderOnce sync.Once
+	eventRecorder     record.EventRecorder
+)
+
+// registryEventRecorder returns the event recorder shared by all
+// repositories, which are created per request.
+func registryEventRecorder(kubeClient kclient.Interface) record.EventRecorder {
+	eventRecorderOnce.Do(func() {
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(createEventSink{kubeClient.Events("")})
+		eventRecorder = eventBroadcaster.NewRecorder(kapi.EventSource{Component: "docker-registry"})
+	})
+	return eventRecorder
+}
+
+// createEventSink records every event as a new one, as the registry is only
+// allowed to create events, while the recorder patches the count of the
+// events it has recorded before.
+type createEventSink struct {
+	kclient.EventInterface
+}
+
+func (s createEventSink) Patch(event *kapi.Event, data []byte) (*kapi.Event, error) {
+	created := *event
+	created.Name = fmt.Sprintf("%v.%x", event.InvolvedObject.Name, time.Now().UnixNano())
+	created.ResourceVersion = ""
+	created.Count = 1
+	created.FirstTimestamp = created.LastTimestamp
+	return s.EventInterface.Create(&created)
+}
+
+// recordPush records the push of the image with digest dgst to tag on the
+// image stream of r, and notifies the configured endpoints.
+func (r *repository) recordPush(ctx context.Context, dgst digest.Digest, tag string) {
+	if r.eventRecorder == nil && r.notifier == nil {
+		return
+	}
+	user := r.userName(ctx)
+	r.notifier.notify(r.registryEvent(ctx, "push", dgst, tag, user))
+	if r.eventRecorder == nil {
+		return
+	}
+	if len(tag) == 0 {
+		r.eventRecorder.Eventf(r.imageStreamReference(ctx), ImagePushedReason, "Image %s pushed by %s", dgst, user)
+		return
+	}
+	r.eventRecorder.Eventf(r.imageStreamReference(ctx), ImagePushedReason, "Image %s pushed to tag %s by %s", dgst, tag, user)
+}
+
+// recordDelete records the deletion of the manifest with digest dgst from the
+// repository of r, and notifies the configured endpoints.
+func (r *repository) recordDelete(ctx context.Context, dgst digest.Digest) {
+	if r.eventRecorder == nil && r.notifier == nil {
+		return
+	}
+	user := r.userName(ctx)
+	r.notifier.notify(r.registryEvent(ctx, "delete", dgst, "", user))
+	if r.eventRecorder == nil {
+		return
+	}
+	r.eventRecorder.Eventf(r.imageStreamReference(ctx), ImageDeletedReason, "Manifest of image %s deleted by %s", dgst, user)
+}
+
+func (r *repository) registryEvent(ctx context.Context, action string, dgst digest.Digest, tag, user string) *RegistryEvent {
+	event := &RegistryEvent{
+		Time:       time.Now().UTC(),
+		Action:     action,
+		Repository: r.namespace + "/" + r.name,
+		Tag:        tag,
+		Digest:     dgst.String(),
+		Actor:      user,
+	}
+	if req, err := ctxu.GetRequest(ctx); err == nil {
+		event.ClientIP = requestClientIP(req)
+	}
+	return event
+}
+
+// imageStreamReference returns a reference to the image stream of r. The UID
+// is filled in if the stream exists, so that the events show up when
+// describing it. The stream is usually in the metadata cache already, the
+// events are recorded before the cache forgets it.
+func (r *repository) imageStreamReference(ctx context.Context) *kapi.ObjectReference {
+	ref := &kapi.ObjectReference{
+		Kind:      "ImageStream",
+		Namespace: r.namespace,
+		Name:      r.name,
+	}
+	if stream, err := r.getImageStream(ctx); err == nil {
+		ref.UID = stream.UID
+		ref.ResourceVersion = stream.ResourceVersion
+	}
+	return ref
+}
+
+// userName returns the name of the user the request was authorized for, as
+// set in ctx by the access controller, so that recording an event doesn't ask
+// the master for it.
+func (r *repository) userName(ctx context.Context) string {
+	if user := ctxu.GetStringValue(ctx, "auth.user.name"); len(user) > 0 {
+		return user
+	}
+	return "unknown user"
+}
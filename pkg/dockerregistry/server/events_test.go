@@ -0,0 +1,76 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	registryauth "github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/record"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestRecordImageEvents(t *testing.T) {
+	client, _ := testclient.NewImageTrackerFake(&imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app", UID: "123"},
+	})
+	recorder := &record.FakeRecorder{}
+	r := &repository{
+		registryClient: client,
+		namespace:      "ns",
+		name:           "app",
+		eventRecorder:  recorder,
+	}
+
+	ctx := registryauth.WithUser(context.Background(), registryauth.UserInfo{Name: "alice"})
+	r.recordPush(ctx, testDigest1, "latest")
+	r.recordPush(ctx, testDigest2, "")
+	r.recordDelete(context.Background(), testDigest1)
+
+	expected := []string{
+		"Pushed Image " + testDigest1 + " pushed to tag latest by alice",
+		"Pushed Image " + testDigest2 + " pushed by alice",
+		"Deleted Manifest of image " + testDigest1 + " deleted by unknown user",
+	}
+	if !reflect.DeepEqual(expected, recorder.Events) {
+		t.Errorf("expected events\n\t%v\ngot\n\t%v", expected, recorder.Events)
+	}
+	// the user is read from the context, only the image stream is fetched
+	for _, action := range client.Actions() {
+		if action.GetResource() != "imagestreams" {
+			t.Errorf("unexpected action %#v", action)
+		}
+	}
+
+	ref := r.imageStreamReference(ctx)
+	if ref.Kind != "ImageStream" || ref.Namespace != "ns" || ref.Name != "app" || ref.UID != "123" {
+		t.Errorf("unexpected image stream reference %#v", ref)
+	}
+}
+
+func TestCreateEventSink(t *testing.T) {
+	client := &ktestclient.Fake{}
+	sink := createEventSink{client.Events("ns")}
+
+	event := &kapi.Event{
+		ObjectMeta:     kapi.ObjectMeta{Namespace: "ns", Name: "app.1", ResourceVersion: "2"},
+		InvolvedObject: kapi.ObjectReference{Kind: "ImageStream", Namespace: "ns", Name: "app"},
+		Count:          2,
+	}
+	if _, err := sink.Patch(event, []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actions := client.Actions()
+	if len(actions) != 1 || actions[0].GetVerb() != "create" {
+		t.Fatalf("expected the event to be created, got %#v", actions)
+	}
+	created := actions[0].(ktestclient.CreateAction).GetObject().(*kapi.Event)
+	if created.Name == event.Name || len(created.ResourceVersion) > 0 || created.Count != 1 {
+		t.Errorf("expected a new event, got %#v", created)
+	}
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	kerrors "k8s.io/kubernetes/pkg/util/errors"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+const (
+	// blobsRoot is where the storage backend keeps the blob data, as
+	// <algorithm>/<first two hex bytes of digest>/<hex digest>/data.
+	blobsRoot = "/docker/registry/v2/blobs"
+	// repositoriesRoot is where the storage backend keeps the repositories.
+	repositoriesRoot = "/docker/registry/v2/repositories"
+)
+
+// GCOptions controls a garbage collection run.
+type GCOptions struct {
+	// MinAge protects blobs modified more recently than this, e.g. the layers
+	// of a push in progress, whose image doesn't exist yet.
+	MinAge time.Duration
+	// DryRun only reports the blobs that would be deleted.
+	DryRun bool
+	// Report is called for every blob deleted, or that would be in a dry run.
+	Report func(dgst digest.Digest, size int64)
+	// ReportLink is called by PruneRepositoryLinks for every manifest
+	// revision or layer link deleted, or that would be in a dry run.
+	ReportLink func(kind, repository string, dgst digest.Digest)
+}
+
+// GCResult summarizes a garbage collection run.
+type GCResult struct {
+	Deleted      int
+	DeletedBytes int64
+	Kept         int
+}
+
+// ReferencedBlobs returns the digests of the manifests and layers of the
+// images. Every image is counted, not only the ones managed by OpenShift: the
+// layers of the images pulled through may be mirrored locally, and the images
+// imported from other registries may reference layers pushed here.
+func ReferencedBlobs(images []imageapi.Image) sets.String {
+	referenced := sets.NewString()
+	for _, image := range images {
+		referenced.Insert(image.Name)
+		if len(image.DockerImageManifest) == 0 {
+			continue
+		}
+		var m manifest.Manifest
+		if err := json.Unmarshal([]byte(image.DockerImageManifest), &m); err != nil {
+			continue
+		}
+		for _, layer := range m.FSLayers {
+			referenced.Insert(layer.BlobSum.String())
+		}
+	}
+	return referenced
+}
+
+// referencedSignatures returns the digests of the signatures of the referenced
+// manifests, which are stored as blobs and linked from the repositories.
+func referencedSignatures(driver storagedriver.StorageDriver, referenced sets.String) (sets.String, error) {
+	signatures := sets.NewString()
+	err := storage.Walk(driver, repositoriesRoot, func(fileInfo storagedriver.FileInfo) error {
+		// .../_manifests/revisions/<algorithm>/<hex digest>/signatures/<algorithm>/<hex digest>/link
+		parts := strings.Split(fileInfo.Path(), "/")
+		n := len(parts)
+		if fileInfo.IsDir() || n < 8 || parts[n-1] != "link" || parts[n-4] != "signatures" || parts[n-7] != "revisions" {
+			return nil
+		}
+		revision := parts[n-6] + ":" + parts[n-5]
+		if referenced.Has(revision) {
+			signatures.Insert(parts[n-3] + ":" + parts[n-2])
+		}
+		return nil
+	})
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return signatures, nil
+	}
+	return signatures, err
+}
+
+// GarbageCollect deletes the blobs of the storage backend that aren't
+// referenced by any of the images. The manifest revisions and the layer links
+// of the repositories to a blob are deleted before the blob, so that no
+// repository serves a blob whose content is gone. A blob some repository
+// linked to less than MinAge ago is kept, as is a blob whose links couldn't
+// all be deleted.
+func GarbageCollect(driver storagedriver.StorageDriver, images []imageapi.Image, options GCOptions) (*GCResult, error) {
+	referenced := ReferencedBlobs(images)
+	signatures, err := referencedSignatures(driver, referenced)
+	if err != nil {
+		return nil, fmt.Errorf("error listing manifest signatures: %v", err)
+	}
+	referenced = referenced.Union(signatures)
+
+	result := &GCResult{}
+	// the blobs are deleted once the walks are done, so that they don't list
+	// directories being deleted
+	unreferenced := map[string]storagedriver.FileInfo{}
+	cutoff := time.Now().Add(-options.MinAge)
+	err = storage.Walk(driver, blobsRoot, func(fileInfo storagedriver.FileInfo) error {
+		if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "data" {
+			return nil
+		}
+		dgst, err := blobDigest(fileInfo.Path())
+		if err != nil {
+			return nil
+		}
+		if referenced.Has(dgst.String()) || fileInfo.ModTime().After(cutoff) {
+			result.Kept++
+			return nil
+		}
+		unreferenced[dgst.String()] = fileInfo
+		return nil
+	})
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		err = nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	kept, errs := deleteBlobLinks(driver, unreferenced, cutoff, options)
+	for dgst := range kept {
+		delete(unreferenced, dgst)
+		result.Kept++
+	}
+
+	for _, fileInfo := range unreferenced {
+		dgst, _ := blobDigest(fileInfo.Path())
+		if !options.DryRun {
+			if err := driver.Delete(path.Dir(fileInfo.Path())); err != nil {
+				errs = append(errs, fmt.Errorf("error deleting blob %s: %v", dgst, err))
+				continue
+			}
+		}
+		result.Deleted++
+		result.DeletedBytes += fileInfo.Size()
+		if options.Report != nil {
+			options.Report(dgst, fileInfo.Size())
+		}
+	}
+	return result, kerrors.NewAggregate(errs)
+}
+
+// deleteBlobLinks deletes the manifest revisions and the layer links of the
+// repositories to the given blobs. It returns the blobs to keep: those linked
+// to more recently than cutoff, e.g. by a push in progress, and those whose
+// links couldn't be deleted.
+func deleteBlobLinks(driver storagedriver.StorageDriver, blobs map[string]storagedriver.FileInfo, cutoff time.Time, options GCOptions) (sets.String, []error) {
+	kept := sets.NewString()
+	if len(blobs) == 0 {
+		return kept, nil
+	}
+
+	links := []repositoryLink{}
+	err := storage.Walk(driver, repositoriesRoot, func(fileInfo storagedriver.FileInfo) error {
+		if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "link" {
+			return nil
+		}
+		link, ok := parseRepositoryLink(fileInfo.Path())
+		if !ok {
+			return nil
+		}
+		if _, ok := blobs[link.dgst.String()]; !ok {
+			return nil
+		}
+		if fileInfo.ModTime().After(cutoff) {
+			kept.Insert(link.dgst.String())
+			return nil
+		}
+		links = append(links, link)
+		return nil
+	})
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		err = nil
+	}
+	if err != nil {
+		// without knowing the links, none of the blobs can be deleted
+		for dgst := range blobs {
+			kept.Insert(dgst)
+		}
+		return kept, []error{fmt.Errorf("error listing repository links: %v", err)}
+	}
+
+	errs := []error{}
+	for _, link := range links {
+		if kept.Has(link.dgst.String()) {
+			continue
+		}
+		if !options.DryRun {
+			if err := driver.Delete(link.dir); err != nil {
+				if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+					errs = append(errs, fmt.Errorf("error deleting %s %s of repository %s: %v", link.kind, link.dgst, link.repository, err))
+					kept.Insert(link.dgst.String())
+					continue
+				}
+			}
+		}
+		if options.ReportLink != nil {
+			options.ReportLink(link.kind, link.repository, link.dgst)
+		}
+	}
+	return kept, errs
+}
+
+const (
+	// LinkKindManifest is the kind of the manifest revisions of a repository.
+	LinkKindManifest = "manifest"
+	// LinkKindLayer is the kind of the layer links of a repository.
+	LinkKindLayer = "layer"
+)
+
+// repositoryLink is a manifest revision or a layer link of a repository.
+type repositoryLink struct {
+	kind       string
+	repository string
+	dgst       digest.Digest
+	// dir is deleted to remove the link, for a revision it holds the links
+	// to its signatures as well.
+	dir string
+}
+
+// PruneRepositoryLinks deletes the manifest revisions and the layer links of
+// the repositories whose digest isn't referenced by any of the images. The blobs are left to GarbageCollect, which has to run
+// afterwards to delete the signatures of the deleted revisions. It returns
+// the number of links deleted.
+func PruneRepositoryLinks(driver storagedriver.StorageDriver, images []imageapi.Image, options GCOptions) (int, error) {
+	referenced := ReferencedBlobs(images)
+
+	// the links are deleted once the walk is done, so that it doesn't list
+	// directories being deleted
+	links := []repositoryLink{}
+	cutoff := time.Now().Add(-options.MinAge)
+	err := storage.Walk(driver, repositoriesRoot, func(fileInfo storagedriver.FileInfo) error {
+		if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "link" {
+			return nil
+		}
+		link, ok := parseRepositoryLink(fileInfo.Path())
+		if !ok || referenced.Has(link.dgst.String()) || fileInfo.ModTime().After(cutoff) {
+			return nil
+		}
+		links = append(links, link)
+		return nil
+	})
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		err = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	errs := []error{}
+	for _, link := range links {
+		if !options.DryRun {
+			if err := driver.Delete(link.dir); err != nil {
+				if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+					errs = append(errs, fmt.Errorf("error deleting %s %s of repository %s: %v", link.kind, link.dgst, link.repository, err))
+					continue
+				}
+			}
+		}
+		deleted++
+		if options.ReportLink != nil {
+			options.ReportLink(link.kind, link.repository, link.dgst)
+		}
+	}
+	return deleted, kerrors.NewAggregate(errs)
+}
+
+// parseRepositoryLink returns the manifest revision or the layer link stored
+// at p, of the form <repositoriesRoot>/<name>/_manifests/revisions/<algorithm>/<hex digest>/link
+// or <repositoriesRoot>/<name>/_layers/<algorithm>/<hex digest>/link. Other
+// links, e.g. of signatures or tags, are ignored.
+func parseRepositoryLink(p string) (repositoryLink, bool) {
+	parts := strings.Split(strings.TrimPrefix(p, repositoriesRoot+"/"), "/")
+	n := len(parts)
+	if n < 5 {
+		return repositoryLink{}, false
+	}
+
+	link := repositoryLink{dir: path.Dir(p)}
+	var nameParts []string
+	switch {
+	case parts[n-4] == "revisions" && parts[n-5] == "_manifests":
+		link.kind = LinkKindManifest
+		nameParts = parts[:n-5]
+	case parts[n-4] == "_layers":
+		link.kind = LinkKindLayer
+		nameParts = parts[:n-4]
+	default:
+		return repositoryLink{}, false
+	}
+	if len(nameParts) == 0 {
+		return repositoryLink{}, false
+	}
+	dgst, err := digest.ParseDigest(parts[n-3] + ":" + parts[n-2])
+	if err != nil {
+		return repositoryLink{}, false
+	}
+	link.repository = strings.Join(nameParts, "/")
+	link.dgst = dgst
+	return link, true
+}
+
+// blobDigest returns the digest of the blob stored at p, of the form
+// <blobsRoot>/<algorithm>/<xx>/<hex digest>/data.
+func blobDigest(p string) (digest.Digest, error) {
+	parts := strings.Split(strings.TrimPrefix(p, blobsRoot+"/"), "/")
+	if len(parts) != 4 {
+		return "", fmt.Errorf("unexpected blob path %s", p)
+	}
+	return digest.ParseDigest(parts[0] + ":" + parts[2])
+}
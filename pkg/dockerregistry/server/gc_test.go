@@ -0,0 +1,107 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestGarbageCollect(t *testing.T) {
+	driver := inmemory.New()
+	blobs := map[string]string{
+		testDigest1:     blobsRoot + "/sha256/00/" + strings.TrimPrefix(testDigest1, "sha256:") + "/data",
+		testDigest2:     blobsRoot + "/sha256/00/" + strings.TrimPrefix(testDigest2, "sha256:") + "/data",
+		testLayerDigest: blobsRoot + "/sha256/00/" + strings.TrimPrefix(testLayerDigest, "sha256:") + "/data",
+	}
+	for _, p := range blobs {
+		if err := driver.PutContent(p, []byte("content")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unreferencedLink := repositoriesRoot + "/ns/app/_layers/sha256/" + strings.TrimPrefix(testDigest2, "sha256:") + "/link"
+	referencedLink := repositoriesRoot + "/ns/app/_layers/sha256/" + strings.TrimPrefix(testLayerDigest, "sha256:") + "/link"
+	for _, p := range []string{unreferencedLink, referencedLink} {
+		if err := driver.PutContent(p, []byte("link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	images := []imageapi.Image{
+		{
+			ObjectMeta: kapi.ObjectMeta{
+				Name:        testDigest1,
+				Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"},
+			},
+			DockerImageManifest: `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+		},
+	}
+
+	reported := []digest.Digest{}
+	report := func(dgst digest.Digest, size int64) {
+		reported = append(reported, dgst)
+	}
+	reportedLinks := []string{}
+	reportLink := func(kind, repository string, dgst digest.Digest) {
+		reportedLinks = append(reportedLinks, kind+" "+repository+" "+dgst.String())
+	}
+	result, err := GarbageCollect(driver, images, GCOptions{DryRun: true, Report: report, ReportLink: reportLink})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Deleted != 1 || result.Kept != 2 {
+		t.Errorf("unexpected dry run result: %#v", result)
+	}
+	if !reflect.DeepEqual(reported, []digest.Digest{testDigest2}) {
+		t.Errorf("unexpected blobs reported: %v", reported)
+	}
+	if !reflect.DeepEqual(reportedLinks, []string{"layer ns/app " + testDigest2}) {
+		t.Errorf("unexpected links reported: %v", reportedLinks)
+	}
+	for _, p := range []string{blobs[testDigest2], unreferencedLink} {
+		if _, err := driver.Stat(p); err != nil {
+			t.Errorf("expected dry run to keep %s: %v", p, err)
+		}
+	}
+
+	if _, err := GarbageCollect(driver, images, GCOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range []string{blobs[testDigest2], unreferencedLink} {
+		if _, err := driver.Stat(p); err == nil {
+			t.Errorf("expected %s to be deleted", p)
+		}
+	}
+	for _, p := range []string{blobs[testDigest1], blobs[testLayerDigest], referencedLink} {
+		if _, err := driver.Stat(p); err != nil {
+			t.Errorf("expected %s to be kept: %v", p, err)
+		}
+	}
+}
+
+func TestReferencedBlobs(t *testing.T) {
+	images := []imageapi.Image{
+		{
+			ObjectMeta: kapi.ObjectMeta{
+				Name:        testDigest1,
+				Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"},
+			},
+		},
+		{
+			// pulled through from another registry, its layers may be
+			// mirrored locally
+			ObjectMeta:          kapi.ObjectMeta{Name: testDigest2},
+			DockerImageManifest: `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+		},
+	}
+	expected := sets.NewString(testDigest1, testDigest2, testLayerDigest)
+	if referenced := ReferencedBlobs(images); !referenced.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected.List(), referenced.List())
+	}
+}
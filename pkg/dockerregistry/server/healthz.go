@@ -1,12 +1,106 @@
 package server
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/docker/distribution/health"
 	"github.com/docker/distribution/registry/handlers"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"golang.org/x/net/context"
+
+	"github.com/openshift/origin/pkg/client"
+)
+
+const (
+	// healthCheckPath is the path stat'ed to check the storage driver. It
+	// doesn't need to exist, the driver only needs to answer.
+	healthCheckPath = "/docker/registry/v2"
+	// masterHealthCheckTimeout is the time the master has to answer the
+	// readiness check.
+	masterHealthCheckTimeout = 5 * time.Second
+)
+
+var (
+	readinessChecksLock sync.RWMutex
+	// readinessChecks are run by /readyz in addition to the health checks.
+	readinessChecks = map[string]health.Checker{}
 )
 
+// HealthzHandler reports the health checks. It serves /healthz, meant to be
+// the liveness probe of the registry. Restarting the registry doesn't repair
+// its storage or its connection to the master, so they're only checked by
+// /readyz.
 func HealthzHandler(ctx *handlers.Context, r *http.Request) http.Handler {
 	return http.HandlerFunc(health.StatusHandler)
 }
+
+// ReadyzHandler reports the health checks and the readiness checks. It serves
+// /readyz, meant to be the readiness probe of the registry, so that a replica
+// that can't reach its storage or the master is taken out of rotation but
+// isn't restarted.
+func ReadyzHandler(ctx *handlers.Context, r *http.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		status := health.CheckStatus()
+		readinessChecksLock.RLock()
+		for name, check := range readinessChecks {
+			if err := check.Check(); err != nil {
+				status[name] = err.Error()
+			}
+		}
+		readinessChecksLock.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if len(status) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// RegisterHealthChecks checks the storage driver and the connection of
+// registryClient to the master for /readyz.
+func RegisterHealthChecks(driver storagedriver.StorageDriver, registryClient client.Interface) {
+	readinessChecksLock.Lock()
+	defer readinessChecksLock.Unlock()
+	readinessChecks["storage"] = StorageHealthCheck(driver)
+	readinessChecks["master"] = MasterHealthCheck(registryClient, masterHealthCheckTimeout)
+}
+
+// StorageHealthCheck returns a check that driver answers a stat of the
+// registry's root.
+func StorageHealthCheck(driver storagedriver.StorageDriver) health.Checker {
+	return health.CheckFunc(func() error {
+		_, err := driver.Stat(healthCheckPath)
+		if _, notFound := err.(storagedriver.PathNotFoundError); notFound {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("storage driver %s: %v", driver.Name(), err)
+		}
+		return nil
+	})
+}
+
+// MasterHealthCheck returns a check that the master answers registryClient
+// within timeout and accepts its credentials.
+func MasterHealthCheck(registryClient client.Interface, timeout time.Duration) health.Checker {
+	return health.CheckFunc(func() error {
+		err := withDeadline(context.Background(), timeout, "get registry user", func(ctx context.Context) error {
+			_, err := registryClient.Users().Get("~")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("master: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/health"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	userapi "github.com/openshift/origin/pkg/user/api"
+)
+
+// failingDriver fails every stat.
+type failingDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *failingDriver) Stat(path string) (storagedriver.FileInfo, error) {
+	return nil, errors.New("disk unavailable")
+}
+
+func TestStorageHealthCheck(t *testing.T) {
+	driver := inmemory.New()
+	if err := StorageHealthCheck(driver).Check(); err != nil {
+		t.Errorf("unexpected error for empty storage: %v", err)
+	}
+	if err := driver.PutContent(healthCheckPath+"/blobs/data", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := StorageHealthCheck(driver).Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := StorageHealthCheck(&failingDriver{driver}).Check(); err == nil || !strings.Contains(err.Error(), "disk unavailable") {
+		t.Errorf("expected the error of the driver, got %v", err)
+	}
+}
+
+func TestMasterHealthCheck(t *testing.T) {
+	client := testclient.NewSimpleFake(&userapi.User{})
+	if err := MasterHealthCheck(client, time.Second).Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	client.PrependReactor("get", "users", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unauthorized")
+	})
+	if err := MasterHealthCheck(client, time.Second).Check(); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	readinessChecksLock.Lock()
+	saved := readinessChecks
+	readinessChecks = map[string]health.Checker{}
+	readinessChecksLock.Unlock()
+	defer func() {
+		readinessChecksLock.Lock()
+		readinessChecks = saved
+		readinessChecksLock.Unlock()
+	}()
+
+	RegisterHealthChecks(&failingDriver{inmemory.New()}, testclient.NewSimpleFake(&userapi.User{}))
+	if _, ok := health.CheckStatus()["storage"]; ok {
+		t.Errorf("expected the storage not to be checked by /healthz")
+	}
+	if _, ok := readinessChecks["storage"]; !ok {
+		t.Errorf("expected the storage to be checked by /readyz")
+	}
+
+	var masterErr error
+	readinessChecks = map[string]health.Checker{}
+	readinessChecks["master"] = health.CheckFunc(func() error { return masterErr })
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	ReadyzHandler(nil, req).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	masterErr = errors.New("master: connection refused")
+	w = httptest.NewRecorder()
+	ReadyzHandler(nil, req).ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "connection refused") {
+		t.Errorf("expected 503 reporting the master, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/digest"
+	"golang.org/x/net/context"
+
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// ImmutableTagError is returned when pushing an image would replace the image
+// of an immutable tag.
+type ImmutableTagError struct {
+	Message string
+}
+
+func (e *ImmutableTagError) Error() string {
+	return "denied: " + e.Message
+}
+
+// IsImmutableTag returns true if err is an ImmutableTagError.
+func IsImmutableTag(err error) bool {
+	_, ok := err.(*ImmutableTagError)
+	return ok
+}
+
+// admitImageTag verifies that tagging the image with digest dgst as tag of the
+// image stream of r doesn't replace the image of an immutable tag. The API
+// server denies such a tag anyway, this denies the push before the manifest is
+// stored.
+func (r *repository) admitImageTag(ctx context.Context, tag string, dgst digest.Digest) error {
+	stream, err := r.getImageStream(ctx)
+	switch {
+	case kerrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	if !imageapi.IsTagImmutable(stream, tag) {
+		return nil
+	}
+	if latest := imageapi.LatestTaggedImage(stream, tag); latest.Image != dgst.String() {
+		r.logger(ctx).Infof("Denying push of image %s as immutable tag %s of %s/%s, which references image %s", dgst, tag, r.namespace, r.name, latest.Image)
+		return &ImmutableTagError{
+			Message: fmt.Sprintf("tag %q of image stream %s/%s is immutable, its image can't be replaced", tag, r.namespace, r.name),
+		}
+	}
+	return nil
+}
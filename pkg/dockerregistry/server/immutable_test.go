@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestAdmitImageTag(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec: imageapi.ImageStreamSpec{
+			Tags: map[string]imageapi.TagReference{
+				"prod":    {Immutable: true},
+				"staging": {Immutable: true},
+			},
+		},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"prod":   {Items: []imageapi.TagEvent{{Image: testDigest1}}},
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest1}}},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		tag          string
+		expectDenied bool
+	}{
+		"mutable tag":                    {tag: "latest"},
+		"new tag":                        {tag: "next"},
+		"immutable tag without an image": {tag: "staging"},
+		"immutable tag":                  {tag: "prod", expectDenied: true},
+	}
+	for name, test := range tests {
+		client, _ := testclient.NewImageTrackerFake(stream)
+		r := &repository{registryClient: client, namespace: "ns", name: "app"}
+
+		err := r.admitImageTag(context.Background(), test.tag, testDigest2)
+		if test.expectDenied {
+			if !IsImmutableTag(err) {
+				t.Errorf("%s: expected an immutable tag error, got %v", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	// pushing the image an immutable tag already references is admitted
+	client, _ := testclient.NewImageTrackerFake(stream)
+	r := &repository{registryClient: client, namespace: "ns", name: "app"}
+	if err := r.admitImageTag(context.Background(), "prod", testDigest1); err != nil {
+		t.Errorf("unexpected error for the image of the immutable tag: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/hashicorp/golang-lru"
+	"golang.org/x/net/context"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+const (
+	// defaultLayerCacheTTL is how long the layers of an image stream are
+	// remembered.
+	defaultLayerCacheTTL = time.Minute
+	// layerCacheSize is the number of image streams whose layers are kept in
+	// memory, the least recently used ones are dropped first.
+	layerCacheSize = 1024
+)
+
+// imageStreamLayerCache remembers the layers referenced by the images of image
+// streams, as recorded in the image metadata stored by the master. It allows
+// answering whether a repository contains a layer without a round trip to the
+// storage backend. Only positive answers are taken from it: a layer missing
+// from the cache may still have been uploaded by a push in progress.
+type imageStreamLayerCache interface {
+	// get returns the layers of the image stream identified by key, unless
+	// they aren't known or expired at now.
+	get(key string, now time.Time) (sets.String, bool)
+	set(key string, layers sets.String, expires time.Time)
+	// forget drops the layers of the image stream identified by key.
+	forget(key string)
+	// forgetAll drops the layers of all image streams, e.g. because a blob
+	// was deleted from the storage backend.
+	forgetAll()
+}
+
+// layerCache is shared by all repositories, which are created per request. It
+// is kept in memory unless UseRedisLayerCache is called.
+var layerCache imageStreamLayerCache = newInMemoryLayerCache(layerCacheSize)
+
+// inMemoryLayerCache is an imageStreamLayerCache local to the registry
+// process. It holds the layers of at most size image streams.
+type inMemoryLayerCache struct {
+	cache *lru.Cache
+}
+
+type layerCacheEntry struct {
+	layers  sets.String
+	expires time.Time
+}
+
+func newInMemoryLayerCache(size int) *inMemoryLayerCache {
+	// only fails for a size that isn't positive
+	cache, _ := lru.New(size)
+	return &inMemoryLayerCache{cache: cache}
+}
+
+func (c *inMemoryLayerCache) get(key string, now time.Time) (sets.String, bool) {
+	value, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := value.(*layerCacheEntry)
+	if now.After(entry.expires) {
+		c.cache.Remove(key)
+		return nil, false
+	}
+	return entry.layers, true
+}
+
+func (c *inMemoryLayerCache) set(key string, layers sets.String, expires time.Time) {
+	c.cache.Add(key, &layerCacheEntry{layers: layers, expires: expires})
+}
+
+func (c *inMemoryLayerCache) forget(key string) {
+	c.cache.Remove(key)
+}
+
+func (c *inMemoryLayerCache) forgetAll() {
+	c.cache.Purge()
+}
+
+// layerCacheTTLFrom returns the duration configured by the "layercachettl"
+// option, or defaultLayerCacheTTL if it isn't set. A zero duration disables
+// the cache.
+func layerCacheTTLFrom(options map[string]interface{}) (time.Duration, error) {
+	value, ok := options["layercachettl"]
+	if !ok {
+		return defaultLayerCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(fmt.Sprintf("%v", value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid layercachettl %q: %v", value, err)
+	}
+	return ttl, nil
+}
+
+// cacheKey identifies the image stream of r in the layer cache.
+func (r *repository) cacheKey() string {
+	return r.namespace + "/" + r.name
+}
+
+// imageStreamHasLayer returns true if one of the images pushed to the image
+// stream of r references the layer.
+func (r *repository) imageStreamHasLayer(ctx context.Context, dgst digest.Digest) bool {
+	if r.layerCacheTTL <= 0 {
+		return false
+	}
+
+	now := r.clock()
+	layers, ok := layerCache.get(r.cacheKey(), now)
+	if !ok {
+		var err error
+		layers, err = r.imageStreamLayers(ctx)
+		if err != nil {
+			r.logger(ctx).Debugf("Error determining the layers of %s: %v", r.cacheKey(), err)
+			return false
+		}
+		layerCache.set(r.cacheKey(), layers, now.Add(r.layerCacheTTL))
+	}
+	return layers.Has(dgst.String())
+}
+
+// imageStreamLayers returns the layers of the images pushed to the image stream
+// of r. Images stored in other registries are left out, since their layers
+// aren't in the storage backend.
+func (r *repository) imageStreamLayers(ctx context.Context) (sets.String, error) {
+	layers := sets.NewString()
+
+	stream, err := r.getImageStream(ctx)
+	if kerrors.IsNotFound(err) {
+		return layers, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := sets.NewString()
+	for _, history := range stream.Status.Tags {
+		for _, event := range history.Items {
+			if seen.Has(event.Image) {
+				continue
+			}
+			seen.Insert(event.Image)
+
+			imageDigest, err := digest.ParseDigest(event.Image)
+			if err != nil {
+				continue
+			}
+			image, err := r.getImage(ctx, imageDigest)
+			if err != nil {
+				return nil, err
+			}
+			if r.isRemoteImage(image) || len(image.DockerImageManifest) == 0 {
+				continue
+			}
+
+			var m manifest.Manifest
+			if err := json.Unmarshal([]byte(image.DockerImageManifest), &m); err != nil {
+				continue
+			}
+			for _, layer := range m.FSLayers {
+				layers.Insert(layer.BlobSum.String())
+			}
+		}
+	}
+	return layers, nil
+}
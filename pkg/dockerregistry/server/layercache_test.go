@@ -0,0 +1,92 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestImageStreamLayerCache(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "cached"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{
+					Image:                testDigest1,
+					DockerImageReference: "registry:5000/ns/cached@" + testDigest1,
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+		DockerImageReference: "registry:5000/ns/cached@" + testDigest1,
+		DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	r := &repository{
+		Repository:     &fakeLocalRepository{},
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "cached",
+		layerCacheTTL:  time.Minute,
+	}
+	defer layerCache.forget(r.cacheKey())
+
+	exists, err := r.Layers().Exists(testLayerDigest)
+	if err != nil || !exists {
+		t.Fatalf("expected layer to exist, got %v, %v", exists, err)
+	}
+	if exists, _ := r.Layers().Exists(testDigest2); exists {
+		t.Errorf("expected unreferenced layer not to exist")
+	}
+
+	// subsequent lookups don't reach the master
+	calls := len(client.Actions())
+	if exists, _ := r.Layers().Exists(testLayerDigest); !exists {
+		t.Errorf("expected cached layer to exist")
+	}
+	if len(client.Actions()) != calls {
+		t.Errorf("expected no calls to the master, got %v", client.Actions()[calls:])
+	}
+
+	// expired entries are refreshed
+	layers, _ := layerCache.get(r.cacheKey(), time.Now())
+	layerCache.set(r.cacheKey(), layers, time.Now().Add(-time.Second))
+	r.Layers().Exists(testLayerDigest)
+	if len(client.Actions()) == calls {
+		t.Errorf("expected expired layers to be fetched again")
+	}
+}
+
+func TestInMemoryLayerCacheSize(t *testing.T) {
+	cache := newInMemoryLayerCache(2)
+	now := time.Now()
+	expires := now.Add(time.Minute)
+
+	cache.set("ns/a", sets.NewString("a"), expires)
+	cache.set("ns/b", sets.NewString("b"), expires)
+	// using a keeps it over b
+	if _, ok := cache.get("ns/a", now); !ok {
+		t.Fatalf("expected the layers of ns/a to be cached")
+	}
+	cache.set("ns/c", sets.NewString("c"), expires)
+
+	if _, ok := cache.get("ns/b", now); ok {
+		t.Errorf("expected the least recently used stream to be dropped")
+	}
+	for _, key := range []string{"ns/a", "ns/c"} {
+		if _, ok := cache.get(key, now); !ok {
+			t.Errorf("expected the layers of %s to be cached", key)
+		}
+	}
+	if _, ok := cache.get("ns/a", expires.Add(time.Second)); ok {
+		t.Errorf("expected the layers of ns/a to expire")
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/Sirupsen/logrus/formatters/logstash"
+	"github.com/docker/distribution/registry/handlers"
+	gorillahandlers "github.com/gorilla/handlers"
+)
+
+// LogFormatter returns the formatter named by the formatter option of the log
+// section of the configuration: "text", the default, "json" or "logstash".
+func LogFormatter(name string) (log.Formatter, error) {
+	switch name {
+	case "", "text":
+		return &log.TextFormatter{}, nil
+	case "json":
+		return &log.JSONFormatter{}, nil
+	case "logstash":
+		return &logstash.LogstashFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported log formatter %q", name)
+	}
+}
+
+// ToggleDebugLoggingOnSignal switches the registry between level and debug
+// logging each time one of signals is received, so that the requests of a
+// running registry can be traced without restarting it.
+func ToggleDebugLoggingOnSignal(level log.Level, signals ...os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	go func() {
+		for range c {
+			log.Infof("Switching to log level %s", toggleDebugLogging(level))
+		}
+	}()
+}
+
+// toggleDebugLogging switches to debug logging, or back to level if debug
+// logging is enabled already, and returns the new log level.
+func toggleDebugLogging(level log.Level) log.Level {
+	if log.GetLevel() == log.DebugLevel {
+		log.SetLevel(level)
+	} else {
+		log.SetLevel(log.DebugLevel)
+	}
+	return log.GetLevel()
+}
+
+// LogLevelDispatcher takes the request context and builds the appropriate
+// handler for handling log level requests.
+func LogLevelDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	logLevelHandler := &logLevelHandler{
+		Context: ctx,
+	}
+
+	return gorillahandlers.MethodHandler{
+		"GET": http.HandlerFunc(logLevelHandler.GetLogLevel),
+		"PUT": http.HandlerFunc(logLevelHandler.PutLogLevel),
+	}
+}
+
+// logLevelHandler handles http operations on the log level.
+type logLevelHandler struct {
+	*handlers.Context
+}
+
+type logLevelAPIResponse struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel returns the current log level.
+func (lh *logLevelHandler) GetLogLevel(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	lh.writeLogLevel(w)
+}
+
+// PutLogLevel switches to the log level of the request, e.g.
+// {"level": "debug"}, until the registry is restarted, and returns it.
+func (lh *logLevelHandler) PutLogLevel(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	var request logLevelAPIResponse
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		lh.Errors.PushErr(fmt.Errorf("invalid log level request: %v", err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	level, err := log.ParseLevel(request.Level)
+	if err != nil {
+		lh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.SetLevel(level)
+	log.Infof("Switching to log level %s", level)
+	lh.writeLogLevel(w)
+}
+
+func (lh *logLevelHandler) writeLogLevel(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(logLevelAPIResponse{Level: log.GetLevel().String()}); err != nil {
+		lh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
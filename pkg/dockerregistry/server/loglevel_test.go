@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/registry/handlers"
+	"golang.org/x/net/context"
+)
+
+func TestToggleDebugLogging(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	log.SetLevel(log.WarnLevel)
+	if level := toggleDebugLogging(log.WarnLevel); level != log.DebugLevel {
+		t.Errorf("expected debug logging, got %s", level)
+	}
+	if level := toggleDebugLogging(log.WarnLevel); level != log.WarnLevel {
+		t.Errorf("expected the configured level back, got %s", level)
+	}
+}
+
+func TestLogFormatter(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":         "*logrus.TextFormatter",
+		"text":     "*logrus.TextFormatter",
+		"json":     "*logrus.JSONFormatter",
+		"logstash": "*logstash.LogstashFormatter",
+	} {
+		formatter, err := LogFormatter(name)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", name, err)
+			continue
+		}
+		if actual := fmt.Sprintf("%T", formatter); actual != expected {
+			t.Errorf("%q: expected %s, got %s", name, expected, actual)
+		}
+	}
+	if _, err := LogFormatter("xml"); err == nil {
+		t.Errorf("expected an error for an unknown formatter")
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	ctx := &handlers.Context{Context: context.Background()}
+	req, _ := http.NewRequest("PUT", "/admin/loglevel", strings.NewReader(`{"level": "debug"}`))
+	w := httptest.NewRecorder()
+	LogLevelDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || log.GetLevel() != log.DebugLevel {
+		t.Fatalf("expected debug logging, got %d %s: %v", w.Code, log.GetLevel(), ctx.Errors)
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/loglevel", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	LogLevelDispatcher(ctx, req).ServeHTTP(w, req)
+	var response logLevelAPIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Level != "debug" {
+		t.Errorf("unexpected log level %q", response.Level)
+	}
+
+	req, _ = http.NewRequest("PUT", "/admin/loglevel", strings.NewReader(`{"level": "verbose"}`))
+	w = httptest.NewRecorder()
+	LogLevelDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || log.GetLevel() != log.DebugLevel {
+		t.Errorf("expected an unknown level to be rejected, got %d %s", w.Code, log.GetLevel())
+	}
+}
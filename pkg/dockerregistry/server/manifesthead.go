@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/handlers"
+	gorillahandlers "github.com/gorilla/handlers"
+	"golang.org/x/net/context"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// ManifestHeadPathPrefix is prepended to the path of HEAD requests for
+// manifests, and of GET requests with If-None-Match, so that they are
+// dispatched to ManifestHeadDispatcher, as the upstream manifest handler only
+// serves unconditional GETs.
+const ManifestHeadPathPrefix = "/openshift/head"
+
+// WithManifestHead routes HEAD /v2/<name>/manifests/<reference>, and GET with
+// If-None-Match, below ManifestHeadPathPrefix. The responses to the other GET
+// requests for manifests get an ETag, all other requests are passed through
+// unchanged.
+func WithManifestHead(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isManifestRequest(r) {
+			switch {
+			case r.Method == "HEAD" || (r.Method == "GET" && len(r.Header.Get("If-None-Match")) > 0):
+				headURL := *r.URL
+				headURL.Path = ManifestHeadPathPrefix + r.URL.Path
+				headRequest := *r
+				headRequest.URL = &headURL
+				r = &headRequest
+			case r.Method == "GET":
+				w = &etagResponseWriter{ResponseWriter: w}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isManifestRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/v2/") && strings.Contains(r.URL.Path, "/manifests/")
+}
+
+// etagResponseWriter sets the ETag of a manifest to its digest, once the
+// upstream handler has set the Docker-Content-Digest header.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *etagResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if dgst := w.Header().Get("Docker-Content-Digest"); len(dgst) > 0 {
+			w.Header().Set("ETag", manifestETag(digest.Digest(dgst)))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *etagResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// manifestETag returns the ETag of the manifest with digest dgst.
+func manifestETag(dgst digest.Digest) string {
+	return fmt.Sprintf("%q", dgst.String())
+}
+
+// etagMatches returns true if one of the If-None-Match headers of r is the
+// ETag of the manifest with digest dgst, quoted or not.
+func etagMatches(r *http.Request, dgst digest.Digest) bool {
+	for _, header := range r.Header["If-None-Match"] {
+		for _, etag := range strings.Split(header, ",") {
+			etag = strings.TrimSpace(etag)
+			if etag == "*" || etag == dgst.String() || etag == manifestETag(dgst) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ManifestHeadDispatcher takes the request context and builds the appropriate
+// handler for handling HEAD and conditional GET requests for manifests.
+func ManifestHeadDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	manifestHeadHandler := &manifestHeadHandler{
+		Context: ctx,
+	}
+	reference := ctxu.GetStringValue(ctx, "vars.reference")
+	if dgst, err := digest.ParseDigest(reference); err == nil {
+		manifestHeadHandler.Digest = dgst
+	} else {
+		manifestHeadHandler.Tag = reference
+	}
+
+	return gorillahandlers.MethodHandler{
+		"HEAD": http.HandlerFunc(manifestHeadHandler.HeadImageManifest),
+		"GET":  http.HandlerFunc(manifestHeadHandler.GetImageManifest),
+	}
+}
+
+// manifestHeadHandler handles HEAD and conditional GET requests for manifests.
+// manifestHeadRepository is a repository that tells the digest and the length
+// of its manifests without loading them when it can.
+type manifestHeadRepository interface {
+	distribution.ManifestService
+	manifestHead(ctx context.Context, tag string, dgst digest.Digest) (digest.Digest, int64, error)
+}
+
+type manifestHeadHandler struct {
+	*handlers.Context
+
+	// One of tag or digest gets set, depending on what is present in context.
+	Tag    string
+	Digest digest.Digest
+}
+
+// HeadImageManifest answers with the headers GetImageManifest would send.
+func (mh *manifestHeadHandler) HeadImageManifest(w http.ResponseWriter, req *http.Request) {
+	r, ok := mh.Repository.(manifestHeadRepository)
+	if !ok {
+		mh.Errors.PushErr(fmt.Errorf("unexpected repository %T", mh.Repository))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dgst, length, err := r.manifestHead(mh, mh.Tag, mh.Digest)
+	if err != nil {
+		mh.Errors.Push(v2.ErrorCodeManifestUnknown, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprint(length))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("ETag", manifestETag(dgst))
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetImageManifest answers 304 Not Modified if the client has the manifest
+// already, as told by If-None-Match, without loading the manifest. Otherwise
+// the manifest is sent like by the upstream handler, with its ETag.
+func (mh *manifestHeadHandler) GetImageManifest(w http.ResponseWriter, req *http.Request) {
+	r, ok := mh.Repository.(manifestHeadRepository)
+	if !ok {
+		mh.Errors.PushErr(fmt.Errorf("unexpected repository %T", mh.Repository))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dgst, _, err := r.manifestHead(mh, mh.Tag, mh.Digest)
+	if err != nil {
+		mh.Errors.Push(v2.ErrorCodeManifestUnknown, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("ETag", manifestETag(dgst))
+	if etagMatches(req, dgst) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	m, err := r.Get(mh, dgst)
+	if err != nil {
+		mh.Errors.Push(v2.ErrorCodeManifestUnknown, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprint(len(m.Raw)))
+	w.Write(m.Raw)
+}
+
+// manifestHead returns the digest and the length of the manifest with the
+// given tag, or else with digest dgst. The length is taken from the Image
+// when it holds the signed manifest, the manifest is only loaded when it has
+// to be signed or pulled through.
+func (r *repository) manifestHead(ctx context.Context, tag string, dgst digest.Digest) (_ digest.Digest, _ int64, err error) {
+	defer func() {
+		manifestRequests.WithLabelValues("head", resultLabel(err)).Inc()
+	}()
+
+	if len(tag) > 0 {
+		imageStreamTag, err := r.getImageStreamTag(ctx, tag)
+		if err != nil {
+			return "", 0, err
+		}
+		if dgst, err = digest.ParseDigest(imageStreamTag.Image.Name); err != nil {
+			return "", 0, err
+		}
+	} else if err := r.verifyImageStreamImage(ctx, dgst); err != nil {
+		return "", 0, err
+	}
+
+	image, err := r.getImage(ctx, dgst)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := r.verifySourceAccess(ctx, image); err != nil {
+		return "", 0, err
+	}
+	if !r.isRemoteImage(image) && hasSignedManifest(image) {
+		return dgst, int64(len(image.DockerImageManifest)), nil
+	}
+
+	m, err := r.Get(ctx, dgst)
+	if err != nil {
+		return "", 0, err
+	}
+	return dgst, int64(len(m.Raw)), nil
+}
+
+// hasSignedManifest returns true if image holds its manifest with the
+// signatures, as served to clients.
+func hasSignedManifest(image *imageapi.Image) bool {
+	var signed manifest.SignedManifest
+	if err := json.Unmarshal([]byte(image.DockerImageManifest), &signed); err != nil {
+		return false
+	}
+	signatures, err := signed.Signatures()
+	return err == nil && len(signatures) > 0
+}
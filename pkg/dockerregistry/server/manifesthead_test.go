@@ -0,0 +1,177 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestWithManifestHead(t *testing.T) {
+	tests := map[string]struct {
+		method       string
+		url          string
+		ifNoneMatch  string
+		expectedPath string
+	}{
+		"head by tag": {
+			method:       "HEAD",
+			url:          "/v2/ns/is/manifests/latest",
+			expectedPath: ManifestHeadPathPrefix + "/v2/ns/is/manifests/latest",
+		},
+		"head by digest": {
+			method:       "HEAD",
+			url:          "/v2/ns/is/manifests/" + testDigest1,
+			expectedPath: ManifestHeadPathPrefix + "/v2/ns/is/manifests/" + testDigest1,
+		},
+		"get": {
+			method:       "GET",
+			url:          "/v2/ns/is/manifests/latest",
+			expectedPath: "/v2/ns/is/manifests/latest",
+		},
+		"conditional get": {
+			method:       "GET",
+			url:          "/v2/ns/is/manifests/latest",
+			ifNoneMatch:  `"` + testDigest1 + `"`,
+			expectedPath: ManifestHeadPathPrefix + "/v2/ns/is/manifests/latest",
+		},
+		"head blob": {
+			method:       "HEAD",
+			url:          "/v2/ns/is/blobs/" + testLayerDigest,
+			expectedPath: "/v2/ns/is/blobs/" + testLayerDigest,
+		},
+	}
+
+	for name, test := range tests {
+		var path string
+		handler := WithManifestHead(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+		}))
+		req, _ := http.NewRequest(test.method, test.url, nil)
+		if len(test.ifNoneMatch) > 0 {
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if path != test.expectedPath {
+			t.Errorf("%s: expected path %q, got %q", name, test.expectedPath, path)
+		}
+	}
+}
+
+func TestManifestHead(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      "ns/app",
+		Tag:       "latest",
+		FSLayers:  []manifest.FSLayer{{BlobSum: testLayerDigest}},
+		History:   []manifest.History{{V1Compatibility: "{}"}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := signed.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{
+					Image:                dgst.String(),
+					DockerImageReference: "registry:5000/ns/app@" + dgst.String(),
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: dgst.String()},
+		DockerImageReference: "registry:5000/ns/app@" + dgst.String(),
+		DockerImageManifest:  string(signed.Raw),
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	// the repository has no storage, the manifest must not be loaded
+	r := &repository{
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+	}
+
+	for _, test := range []struct {
+		tag  string
+		dgst digest.Digest
+	}{
+		{tag: "latest"},
+		{dgst: dgst},
+	} {
+		headDigest, length, err := r.manifestHead(context.Background(), test.tag, test.dgst)
+		if err != nil {
+			t.Fatalf("%s%s: unexpected error: %v", test.tag, test.dgst, err)
+		}
+		if headDigest != dgst || length != int64(len(signed.Raw)) {
+			t.Errorf("%s%s: unexpected digest %s and length %d", test.tag, test.dgst, headDigest, length)
+		}
+	}
+
+	if _, _, err := r.manifestHead(context.Background(), "missing", ""); err == nil {
+		t.Errorf("expected an error for an unknown tag")
+	}
+
+	// a conditional GET for the current manifest isn't answered with it
+	handler := &manifestHeadHandler{
+		Context: &handlers.Context{Context: context.Background(), Repository: r},
+		Tag:     "latest",
+	}
+	req, _ := http.NewRequest("GET", "/v2/ns/app/manifests/latest", nil)
+	req.Header.Set("If-None-Match", `"`+dgst.String()+`"`)
+	w := httptest.NewRecorder()
+	handler.GetImageManifest(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != `"`+dgst.String()+`"` {
+		t.Errorf("expected 304 with the ETag, got %d %v: %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestManifestETag(t *testing.T) {
+	handler := WithManifestHead(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", testDigest1)
+		w.Write([]byte("{}"))
+	}))
+	req, _ := http.NewRequest("GET", "/v2/ns/is/manifests/latest", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if etag := w.Header().Get("ETag"); etag != `"`+testDigest1+`"` {
+		t.Errorf("unexpected ETag %q", etag)
+	}
+
+	for header, expected := range map[string]bool{
+		`"` + testDigest1 + `"`: true,
+		testDigest1:             true,
+		`"` + testDigest2 + `", "` + testDigest1 + `"`: true,
+		"*":                     true,
+		`"` + testDigest2 + `"`: false,
+	} {
+		req.Header.Set("If-None-Match", header)
+		if etagMatches(req, testDigest1) != expected {
+			t.Errorf("If-None-Match %s: expected match %t", header, expected)
+		}
+	}
+}
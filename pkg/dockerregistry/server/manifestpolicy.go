@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// foreignLayerMediaType is the media type of the layers that are downloaded
+// from their URLs rather than from the registry, e.g. Windows base layers.
+const foreignLayerMediaType = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+
+// defaultMaxManifestSize is the size in bytes of the largest manifest accepted
+// unless the "maxmanifestsize" option says otherwise.
+const defaultMaxManifestSize = 4 << 20
+
+// ManifestPolicy restricts the manifests that can be pushed to the registry.
+type ManifestPolicy struct {
+	// MediaTypes are the media types of the manifests accepted, all if empty.
+	// Manifests sent without a Content-Type are taken as application/json.
+	MediaTypes sets.String
+	// RejectForeignLayers rejects the manifests with foreign layers, or with
+	// layers to be downloaded from other URLs, which the registry can't
+	// store or mirror.
+	RejectForeignLayers bool
+	// MaxManifestSize is the size in bytes of the largest manifest read from
+	// the requests pushing manifests.
+	MaxManifestSize int64
+}
+
+// ManifestPolicyFrom reads the manifest policy from the options of the
+// openshift repository middleware in config, see ManifestPolicyFromOptions.
+func ManifestPolicyFrom(config *configuration.Configuration) (ManifestPolicy, error) {
+	var options map[string]interface{}
+	for _, middleware := range config.Middleware["repository"] {
+		if middleware.Name == "openshift" {
+			options = middleware.Options
+		}
+	}
+	return ManifestPolicyFromOptions(options)
+}
+
+// ManifestPolicyFromOptions reads the manifest policy from the options of the
+// openshift repository middleware: "acceptmediatypes", a list or a comma
+// separated string, "rejectforeignlayers" and "maxmanifestsize", a quantity
+// such as 1Mi.
+func ManifestPolicyFromOptions(options map[string]interface{}) (ManifestPolicy, error) {
+	policy := ManifestPolicy{MediaTypes: sets.NewString(), MaxManifestSize: defaultMaxManifestSize}
+	var mediaTypes []string
+	switch value := options["acceptmediatypes"].(type) {
+	case nil:
+	case []interface{}:
+		for _, mediaType := range value {
+			mediaTypes = append(mediaTypes, fmt.Sprintf("%v", mediaType))
+		}
+	default:
+		mediaTypes = strings.Split(fmt.Sprintf("%v", value), ",")
+	}
+	for _, mediaType := range mediaTypes {
+		if mediaType = strings.TrimSpace(mediaType); len(mediaType) > 0 {
+			policy.MediaTypes.Insert(mediaType)
+		}
+	}
+
+	reject, err := boolOption(options, "rejectforeignlayers", false)
+	if err != nil {
+		return ManifestPolicy{}, err
+	}
+	policy.RejectForeignLayers = reject
+
+	if value, ok := options["maxmanifestsize"]; ok {
+		quantity, err := resource.ParseQuantity(fmt.Sprintf("%v", value))
+		if err != nil || quantity.Value() <= 0 {
+			return ManifestPolicy{}, fmt.Errorf("invalid maxmanifestsize %q", value)
+		}
+		policy.MaxManifestSize = quantity.Value()
+	}
+	return policy, nil
+}
+
+// admit returns a MANIFEST_INVALID error if p doesn't accept the manifest m
+// pushed with the request of ctx. It is called once the push is authorized,
+// so that the policy isn't disclosed to anonymous clients.
+func (p ManifestPolicy) admit(ctx context.Context, m *manifest.SignedManifest) error {
+	if p.MediaTypes.Len() > 0 {
+		mediaType := "application/json"
+		if req, err := ctxu.GetRequest(ctx); err == nil {
+			if contentType := req.Header.Get("Content-Type"); len(contentType) > 0 {
+				if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+					return manifestRejected(fmt.Sprintf("invalid Content-Type %q", contentType))
+				}
+			}
+		}
+		if !p.MediaTypes.Has(mediaType) {
+			return manifestRejected(fmt.Sprintf("manifests of type %s aren't accepted", mediaType))
+		}
+	}
+	if p.RejectForeignLayers && hasForeignLayers(m.Raw) {
+		return manifestRejected("manifests with foreign layers aren't accepted")
+	}
+	return nil
+}
+
+// WithManifestSizeLimit rejects the manifests pushed that are larger than
+// maxSize with 400 MANIFEST_INVALID, and reads no more than maxSize bytes of
+// the body of those that don't announce their size.
+func WithManifestSizeLimit(handler http.Handler, maxSize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || !isManifestRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > maxSize {
+			writeManifestRejected(w, fmt.Sprintf("the manifest is larger than %d bytes", maxSize))
+			return
+		}
+		r.Body = limitedBody{Reader: io.LimitReader(r.Body, maxSize), Closer: r.Body}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// limitedBody reads a part of a request body and closes the whole body.
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// hasForeignLayers returns true if the manifest has foreign layers or layers
+// with URLs to download them from, invalid manifests are left to the
+// upstream handler.
+func hasForeignLayers(body []byte) bool {
+	var m struct {
+		Layers []struct {
+			MediaType string   `json:"mediaType"`
+			URLs      []string `json:"urls"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return false
+	}
+	for _, layer := range m.Layers {
+		if layer.MediaType == foreignLayerMediaType || len(layer.URLs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestRejected returns the error rejecting a manifest push, which clients
+// get as 400 MANIFEST_INVALID.
+func manifestRejected(message string) error {
+	return v2.Error{Code: v2.ErrorCodeManifestInvalid, Message: message}
+}
+
+// writeManifestRejected answers a manifest push with 400 MANIFEST_INVALID.
+func writeManifestRejected(w http.ResponseWriter, message string) {
+	errs := &v2.Errors{}
+	errs.Push(v2.ErrorCodeManifestInvalid, message)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(errs)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+func TestManifestPolicyFrom(t *testing.T) {
+	config := &configuration.Configuration{
+		Middleware: map[string][]configuration.Middleware{
+			"repository": {{
+				Name: "openshift",
+				Options: configuration.Parameters{
+					"acceptmediatypes":    []interface{}{"application/json", "application/vnd.docker.distribution.manifest.v1+prettyjws"},
+					"rejectforeignlayers": true,
+					"maxmanifestsize":     "1Mi",
+				},
+			}},
+		},
+	}
+	policy, err := ManifestPolicyFrom(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !policy.MediaTypes.HasAll("application/json", "application/vnd.docker.distribution.manifest.v1+prettyjws") || policy.MediaTypes.Len() != 2 || !policy.RejectForeignLayers || policy.MaxManifestSize != 1<<20 {
+		t.Errorf("unexpected policy %#v", policy)
+	}
+
+	config.Middleware["repository"][0].Options = configuration.Parameters{"acceptmediatypes": "application/json, application/vnd.docker.distribution.manifest.v1+json"}
+	policy, err = ManifestPolicyFrom(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !policy.MediaTypes.Equal(sets.NewString("application/json", "application/vnd.docker.distribution.manifest.v1+json")) || policy.RejectForeignLayers {
+		t.Errorf("unexpected policy %#v", policy)
+	}
+
+	policy, err = ManifestPolicyFrom(&configuration.Configuration{})
+	if err != nil || policy.MediaTypes.Len() != 0 || policy.RejectForeignLayers || policy.MaxManifestSize != defaultMaxManifestSize {
+		t.Errorf("expected the default policy, got %#v: %v", policy, err)
+	}
+
+	config.Middleware["repository"][0].Options = configuration.Parameters{"maxmanifestsize": "-1"}
+	if _, err := ManifestPolicyFrom(config); err == nil {
+		t.Errorf("expected an error for an invalid maxmanifestsize")
+	}
+}
+
+func TestManifestPolicyAdmit(t *testing.T) {
+	policy := ManifestPolicy{
+		MediaTypes:          sets.NewString("application/json", "application/vnd.docker.distribution.manifest.v1+prettyjws"),
+		RejectForeignLayers: true,
+	}
+	tests := map[string]struct {
+		contentType string
+		body        string
+		accepted    bool
+	}{
+		"schema1": {
+			contentType: "application/vnd.docker.distribution.manifest.v1+prettyjws",
+			body:        `{"schemaVersion":1}`,
+			accepted:    true,
+		},
+		"no content type": {
+			body:     `{"schemaVersion":1}`,
+			accepted: true,
+		},
+		"json with charset": {
+			contentType: "application/json; charset=utf-8",
+			body:        `{"schemaVersion":1}`,
+			accepted:    true,
+		},
+		"invalid content type": {
+			contentType: "application/json; =",
+			body:        `{"schemaVersion":1}`,
+		},
+		"schema2": {
+			contentType: "application/vnd.docker.distribution.manifest.v2+json",
+			body:        `{"schemaVersion":2}`,
+		},
+		"foreign layer": {
+			contentType: "application/json",
+			body:        `{"schemaVersion":2,"layers":[{"mediaType":"` + foreignLayerMediaType + `"}]}`,
+		},
+		"layer with urls": {
+			contentType: "application/json",
+			body:        `{"schemaVersion":2,"layers":[{"urls":["https://example.com/layer"]}]}`,
+		},
+	}
+
+	for name, test := range tests {
+		req, _ := http.NewRequest("PUT", "/v2/ns/app/manifests/latest", strings.NewReader(test.body))
+		if len(test.contentType) > 0 {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		err := policy.admit(ctxu.WithRequest(context.Background(), req), &manifest.SignedManifest{Raw: []byte(test.body)})
+		if test.accepted {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+			continue
+		}
+		if e, ok := err.(v2.Error); !ok || e.Code != v2.ErrorCodeManifestInvalid {
+			t.Errorf("%s: expected MANIFEST_INVALID, got %v", name, err)
+		}
+	}
+}
+
+func TestWithManifestSizeLimit(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		path          string
+		body          string
+		contentLength int64
+		expectedBody  string
+		rejected      bool
+	}{
+		"small manifest": {
+			body:         `{"schemaVersion":1}`,
+			expectedBody: `{"schemaVersion":1}`,
+		},
+		"large manifest": {
+			body:     `{"schemaVersion":1,"name":"ns/app"}`,
+			rejected: true,
+		},
+		"large manifest of unknown size": {
+			body:          `{"schemaVersion":1,"name":"ns/app"}`,
+			contentLength: -1,
+			expectedBody:  `{"schemaVersion":1,"name":"n`,
+		},
+		"get": {
+			method:       "GET",
+			body:         `{"schemaVersion":1,"name":"ns/app"}`,
+			expectedBody: `{"schemaVersion":1,"name":"ns/app"}`,
+		},
+		"blob upload": {
+			path:         "/v2/ns/app/blobs/uploads/1234",
+			body:         `{"schemaVersion":1,"name":"ns/app"}`,
+			expectedBody: `{"schemaVersion":1,"name":"ns/app"}`,
+		},
+	}
+
+	for name, test := range tests {
+		var body string
+		handler := WithManifestSizeLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			content, _ := ioutil.ReadAll(r.Body)
+			body = string(content)
+		}), 28)
+		method, path := test.method, test.path
+		if len(method) == 0 {
+			method = "PUT"
+		}
+		if len(path) == 0 {
+			path = "/v2/ns/app/manifests/latest"
+		}
+		req, _ := http.NewRequest(method, path, strings.NewReader(test.body))
+		if test.contentLength != 0 {
+			req.ContentLength = test.contentLength
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if test.rejected {
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MANIFEST_INVALID") {
+				t.Errorf("%s: expected MANIFEST_INVALID, got %d %q", name, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || body != test.expectedBody {
+			t.Errorf("%s: expected the request to be passed with the body %q, got %d %q", name, test.expectedBody, w.Code, body)
+		}
+	}
+}
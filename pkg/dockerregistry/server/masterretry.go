@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+)
+
+const (
+	// masterRetries is the number of times a master API call failing with a
+	// transient error is retried.
+	masterRetries = 3
+	// masterBreakerThreshold is the number of consecutive transient errors
+	// after which calls to the master fail fast.
+	masterBreakerThreshold = 5
+	// masterBreakerCooldown is how long calls to the master fail fast once
+	// the breaker is open, before a trial call is let through.
+	masterBreakerCooldown = 10 * time.Second
+)
+
+// masterRetryBackoff is the delay before the first retry of a master API
+// call, doubled for every following retry.
+var masterRetryBackoff = 100 * time.Millisecond
+
+// masterBreaker is shared by all the repositories, which are created per
+// request, so that an unavailable master isn't hammered by every request.
+var masterBreaker = newCircuitBreaker(masterBreakerThreshold, masterBreakerCooldown)
+
+// isTransientAPIError returns true if err may go away when the call to the
+// master is repeated: server errors, throttling and failures to reach it.
+func isTransientAPIError(err error) bool {
+	if err == nil {
+		return false
+	}
+	status, ok := err.(*kerrors.StatusError)
+	if !ok {
+		return true
+	}
+	code := status.ErrStatus.Code
+	return code >= http.StatusInternalServerError || code == 429
+}
+
+// circuitBreaker stops calls to the master once they failed threshold times
+// in a row. Calls fail fast for cooldown, after which a single trial call is
+// let through; it closes the breaker again if it succeeds.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns an error if calls must fail fast.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	now := b.now()
+	if now.Before(b.openUntil) {
+		return kerrors.NewServiceUnavailable("the master API is unavailable")
+	}
+	// half-open: let this call through and fail fast until it returns
+	b.openUntil = now.Add(b.cooldown)
+	return nil
+}
+
+// record updates the breaker with the outcome of a call.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isTransientAPIError(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures == b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// callMaster runs fn, which calls the master API, like withDeadline does, but
+// retries it with an exponential backoff while it fails with a transient
+// error, unless masterBreaker tells the master is unavailable. A call which
+// doesn't complete in time counts as a failure of the master.
+func (r *repository) callMaster(ctx context.Context, operation string, fn func() error) error {
+	_, err := r.fetchFromMaster(ctx, operation, func() (interface{}, error) {
+		return nil, fn()
+	})
+	return err
+}
+
+// fetchFromMaster is callMaster for an fn returning a result, which is only
+// returned if fn completes in time, as fetchWithDeadline does.
+func (r *repository) fetchFromMaster(ctx context.Context, operation string, fn func() (interface{}, error)) (interface{}, error) {
+	value, abandoned, err := runWithDeadline(ctx, r.apiTimeout, operation, func(ctx context.Context) (interface{}, error) {
+		return retryMaster(ctx, fn)
+	})
+	// the master didn't answer in time, unless the request was canceled
+	if abandoned && ctx.Err() == nil {
+		masterBreaker.record(err)
+	}
+	return value, err
+}
+
+// retryMaster runs fn until it succeeds, fails with an error which isn't
+// transient, or ctx is done. Once ctx is done, no attempt is started and the
+// outcome of the attempt running isn't recorded by masterBreaker: the caller
+// gave up on it, and recorded the failure itself if the master was too slow.
+func retryMaster(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
+	backoff := masterRetryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := masterBreaker.allow(); err != nil {
+			return nil, err
+		}
+		value, err := fn()
+		if ctx.Err() != nil {
+			return value, err
+		}
+		masterBreaker.record(err)
+		if !isTransientAPIError(err) || attempt == masterRetries {
+			return value, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestIsTransientAPIError(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{kerrors.NewNotFound("ImageStream", "app"), false},
+		{kerrors.NewForbidden("ImageStream", "app", fmt.Errorf("denied")), false},
+		{kerrors.NewInternalError(fmt.Errorf("etcd")), true},
+		{kerrors.NewServiceUnavailable("down"), true},
+		{fmt.Errorf("connection refused"), true},
+	} {
+		if transient := isTransientAPIError(test.err); transient != test.transient {
+			t.Errorf("%v: expected transient=%v, got %v", test.err, test.transient, transient)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	failure := kerrors.NewInternalError(fmt.Errorf("etcd"))
+
+	b.record(failure)
+	if err := b.allow(); err != nil {
+		t.Fatalf("unexpected open breaker after a single failure: %v", err)
+	}
+	b.record(failure)
+	if err := b.allow(); err == nil {
+		t.Fatalf("expected the breaker to open")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected a trial call after the cooldown, got %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Fatalf("expected calls to fail fast during the trial call")
+	}
+	b.record(nil)
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected the breaker to close after a successful call, got %v", err)
+	}
+}
+
+func TestGetMetadataRetries(t *testing.T) {
+	defer func(backoff time.Duration, breaker *circuitBreaker) {
+		masterRetryBackoff, masterBreaker = backoff, breaker
+	}(masterRetryBackoff, masterBreaker)
+	masterRetryBackoff = 0
+	masterBreaker = newCircuitBreaker(masterBreakerThreshold, masterBreakerCooldown)
+
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+	}
+	client, _ := testclient.NewImageTrackerFake(stream)
+	failures := 2
+	client.PrependReactor("get", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		if failures == 0 {
+			return false, nil, nil
+		}
+		failures--
+		return true, nil, kerrors.NewInternalError(fmt.Errorf("etcd"))
+	})
+	cache, err := newMetadataCache(time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	r := &repository{
+		registryClient: client,
+		namespace:      "ns",
+		name:           "app",
+		metadataCache:  cache,
+	}
+	ctx := context.Background()
+
+	if _, err := r.getImageStream(ctx); err != nil {
+		t.Fatalf("expected transient errors to be retried, got %v", err)
+	}
+	if len(client.Actions()) != 3 {
+		t.Errorf("expected 3 calls, got %v", client.Actions())
+	}
+
+	// the master goes away: the expired stream is served for a while
+	failures = 100
+	now = now.Add(2 * time.Minute)
+	if _, err := r.getImageStream(ctx); err != nil {
+		t.Fatalf("expected the expired stream to be served, got %v", err)
+	}
+	if _, err := r.getImageStream(ctx); err != nil {
+		t.Fatalf("expected the expired stream to be served, got %v", err)
+	}
+	if err := masterBreaker.allow(); err == nil {
+		t.Errorf("expected the breaker to be open")
+	}
+
+	now = now.Add(metadataCacheMaxStale)
+	if _, err := r.getImageStream(ctx); !isTransientAPIError(err) {
+		t.Errorf("expected the master to be reported unavailable, got %v", err)
+	}
+}
+
+func TestCallMasterTimeout(t *testing.T) {
+	defer func(backoff time.Duration, breaker *circuitBreaker) {
+		masterRetryBackoff, masterBreaker = backoff, breaker
+	}(masterRetryBackoff, masterBreaker)
+	masterRetryBackoff = time.Minute
+	masterBreaker = newCircuitBreaker(2, masterBreakerCooldown)
+
+	r := &repository{apiTimeout: 10 * time.Millisecond}
+	ctx := context.Background()
+
+	// the abandoned calls complete successfully, the breaker opens anyway
+	block := make(chan struct{})
+	returned := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		err := r.callMaster(ctx, "test", func() error {
+			defer func() { returned <- struct{}{} }()
+			<-block
+			return nil
+		})
+		if !isGatewayTimeout(err) {
+			t.Fatalf("expected timeout error, got %v", err)
+		}
+	}
+	close(block)
+	<-returned
+	<-returned
+	if err := masterBreaker.allow(); err == nil {
+		t.Errorf("expected the breaker to be open")
+	}
+
+	// the transient failure isn't retried once the call is given up
+	masterBreaker = newCircuitBreaker(masterBreakerThreshold, masterBreakerCooldown)
+	attempts := make(chan struct{}, masterRetries+1)
+	err := r.callMaster(ctx, "test", func() error {
+		attempts <- struct{}{}
+		return kerrors.NewInternalError(fmt.Errorf("etcd"))
+	})
+	if !isGatewayTimeout(err) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(attempts) != 1 {
+		t.Errorf("expected a single attempt, got %d", len(attempts))
+	}
+}
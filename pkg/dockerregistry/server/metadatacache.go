@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// defaultMetadataCacheTTL is how long image streams and images fetched
+	// from the master are remembered.
+	defaultMetadataCacheTTL = 5 * time.Second
+	// metadataCacheSize is the number of objects remembered.
+	metadataCacheSize = 1024
+	// metadataCacheMaxStale is how long after they expired the objects
+	// remembered are still served while the master is unavailable.
+	metadataCacheMaxStale = 5 * time.Minute
+)
+
+// metadataCache remembers the image streams, images, image stream tags and
+// image stream images recently fetched from the master, so that the
+// repeated lookups made while pulling a tag don't each cost a call to the
+// master. A nil metadataCache remembers nothing.
+type metadataCache struct {
+	cache *lru.Cache
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+type metadataCacheRecord struct {
+	created time.Time
+	object  interface{}
+}
+
+var (
+	sharedMetadataCacheOnce sync.Once
+	sharedMetadataCache     *metadataCache
+)
+
+// metadataCacheFrom returns the cache shared by all repositories, which are
+// created per request, with the TTL configured by the "metadatacachettl"
+// option, or nil if it's zero.
+func metadataCacheFrom(options map[string]interface{}) (*metadataCache, error) {
+	ttl := defaultMetadataCacheTTL
+	if value, ok := options["metadatacachettl"]; ok {
+		var err error
+		if ttl, err = time.ParseDuration(fmt.Sprintf("%v", value)); err != nil {
+			return nil, fmt.Errorf("invalid metadatacachettl %q: %v", value, err)
+		}
+	}
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	var err error
+	sharedMetadataCacheOnce.Do(func() {
+		sharedMetadataCache, err = newMetadataCache(ttl, metadataCacheSize)
+	})
+	return sharedMetadataCache, err
+}
+
+func newMetadataCache(ttl time.Duration, size int) (*metadataCache, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &metadataCache{cache: cache, ttl: ttl, now: time.Now}, nil
+}
+
+// get returns the object remembered under key, unless it expired. Expired
+// objects are kept until they are evicted, see getStale.
+func (c *metadataCache) get(key string) (interface{}, bool) {
+	return c.getWithin(key, 0)
+}
+
+// getStale returns the object remembered under key, even if it expired, as
+// long as it didn't more than metadataCacheMaxStale ago.
+func (c *metadataCache) getStale(key string) (interface{}, bool) {
+	return c.getWithin(key, metadataCacheMaxStale)
+}
+
+func (c *metadataCache) getWithin(key string, staleness time.Duration) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	value, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	record := value.(*metadataCacheRecord)
+	if !record.created.Add(c.ttl + staleness).After(c.now()) {
+		return nil, false
+	}
+	return record.object, true
+}
+
+// set remembers object under key. Objects are shared by all the requests and
+// must not be modified.
+func (c *metadataCache) set(key string, object interface{}) {
+	if c == nil {
+		return
+	}
+	c.cache.Add(key, &metadataCacheRecord{created: c.now(), object: object})
+}
+
+// forgetImageStream drops the image stream identified by namespace and name
+// and its tags and images, e.g. because an image was pushed to it.
+func (c *metadataCache) forgetImageStream(namespace, name string) {
+	if c == nil {
+		return
+	}
+	prefix := imageStreamCacheKey(namespace, name)
+	for _, key := range c.cache.Keys() {
+		if s := key.(string); s == prefix || strings.HasPrefix(s, prefix+":") || strings.HasPrefix(s, prefix+"@") {
+			c.cache.Remove(key)
+		}
+	}
+}
+
+func imageStreamCacheKey(namespace, name string) string {
+	return "imagestreams/" + namespace + "/" + name
+}
+
+func imageCacheKey(name string) string {
+	return "images/" + name
+}
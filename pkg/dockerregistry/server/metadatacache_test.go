@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestMetadataCache(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+	}
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+		DockerImageReference: "registry:5000/ns/app@" + testDigest1,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	cache, err := newMetadataCache(time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	r := &repository{
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+		metadataCache:  cache,
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.getImageStream(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.getImage(ctx, testDigest1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(client.Actions()) != 2 {
+		t.Fatalf("expected repeated lookups to be cached, got %v", client.Actions())
+	}
+
+	// misses aren't cached
+	r.getImage(ctx, testDigest2)
+	r.getImage(ctx, testDigest2)
+	if len(client.Actions()) != 4 {
+		t.Errorf("expected missing images to be looked up every time, got %v", client.Actions())
+	}
+
+	// pushes invalidate the stream, but not the images
+	client.ClearActions()
+	r.metadataCache.forgetImageStream("ns", "app")
+	r.getImageStream(ctx)
+	r.getImage(ctx, testDigest1)
+	if len(client.Actions()) != 1 || client.Actions()[0].GetResource() != "imagestreams" {
+		t.Errorf("expected only the stream to be looked up again, got %v", client.Actions())
+	}
+
+	client.ClearActions()
+	now = now.Add(2 * time.Minute)
+	r.getImage(ctx, testDigest1)
+	if len(client.Actions()) != 1 {
+		t.Errorf("expected expired images to be looked up again, got %v", client.Actions())
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	registryauth "github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	manifestRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "manifest_requests_total",
+			Help:      "Counter of manifest requests broken out by operation and result",
+		},
+		[]string{"operation", "result"},
+	)
+
+	blobServes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "blob_serves_total",
+			Help:      "Counter of blobs served broken out by whether they were stored locally or pulled through",
+		},
+		[]string{"source"},
+	)
+
+	pullthroughRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "pullthrough_requests_total",
+			Help:      "Counter of requests to remote registries broken out by type and result",
+		},
+		[]string{"type", "result"},
+	)
+
+	pushes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "pushes_total",
+			Help:      "Counter of images pushed broken out by namespace",
+		},
+		[]string{"namespace"},
+	)
+
+	repositoryPulls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "repository_pulls_total",
+			Help:      "Counter of manifests pulled broken out by namespace and image stream",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	repositoryPushes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "repository_pushes_total",
+			Help:      "Counter of images pushed broken out by namespace and image stream",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	repositoryLastPulled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "openshift_registry",
+			Name:      "repository_last_pull_timestamp_seconds",
+			Help:      "Time of the last manifest pulled broken out by namespace and image stream",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	rateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_registry",
+			Name:      "rate_limited_requests_total",
+			Help:      "Counter of requests rejected for exceeding a rate limit broken out by limit",
+		},
+		[]string{"limit"},
+	)
+
+	apiCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "openshift_registry",
+			Name:      "master_api_duration_seconds",
+			Help:      "Latency of the calls to the master API broken out by operation",
+		},
+		[]string{"operation"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(manifestRequests)
+	prometheus.MustRegister(blobServes)
+	prometheus.MustRegister(pullthroughRequests)
+	prometheus.MustRegister(pushes)
+	prometheus.MustRegister(repositoryPulls)
+	prometheus.MustRegister(repositoryPushes)
+	prometheus.MustRegister(repositoryLastPulled)
+	prometheus.MustRegister(rateLimited)
+	prometheus.MustRegister(apiCallDuration)
+}
+
+// MetricsDispatcher serves the metrics of the registry process in the
+// Prometheus format.
+func MetricsDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	return prometheus.UninstrumentedHandler()
+}
+
+// MetricsAccessRecords requires the "metrics" action on the admin resource.
+func MetricsAccessRecords(*http.Request) []registryauth.Access {
+	return []registryauth.Access{
+		{
+			Resource: registryauth.Resource{
+				Type: "admin",
+			},
+			Action: "metrics",
+		},
+	}
+}
+
+// resultLabel returns the value of the result label for err.
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// observeAPICall records the latency of a master API call started at start.
+func observeAPICall(operation string, start time.Time) {
+	apiCallDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
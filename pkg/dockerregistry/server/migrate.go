@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	kerrors "k8s.io/kubernetes/pkg/util/errors"
+)
+
+// MigrateOptions controls a storage migration.
+type MigrateOptions struct {
+	// DryRun only reports the links that would be created or removed.
+	DryRun bool
+	// RemoveLegacy removes the tarsum layer links once the layer is linked by
+	// its canonical digest. Docker clients older than 1.6 pull the layers by
+	// tarsum and need them.
+	RemoveLegacy bool
+	// Report is called for every layer linked by its canonical digest, or
+	// that would be in a dry run.
+	Report func(repository string, dgst digest.Digest)
+}
+
+// MigrateResult summarizes a storage migration.
+type MigrateResult struct {
+	// Linked is the number of layers linked by their canonical digest.
+	Linked int
+	// Removed is the number of tarsum layer links removed.
+	Removed int
+	// Missing is the number of tarsum layer links whose blob doesn't exist.
+	Missing int
+}
+
+// legacyLayerLink is a layer link of a repository keyed by the tarsum of the
+// layer, of the form <repositoriesRoot>/<name>/_layers/tarsum/<version>/<algorithm>/<hash>/link,
+// as written by registries before 2.1. Its content is the canonical digest of
+// the blob.
+type legacyLayerLink struct {
+	repository string
+	path       string
+}
+
+// parseLegacyLayerLink returns the tarsum layer link stored at p.
+func parseLegacyLayerLink(p string) (legacyLayerLink, bool) {
+	parts := strings.Split(strings.TrimPrefix(p, repositoriesRoot+"/"), "/")
+	n := len(parts)
+	if n < 7 || parts[n-1] != "link" || parts[n-5] != "tarsum" || parts[n-6] != "_layers" {
+		return legacyLayerLink{}, false
+	}
+	return legacyLayerLink{repository: strings.Join(parts[:n-6], "/"), path: p}, true
+}
+
+// MigrateStorage links the layers of the repositories that are only linked by
+// their tarsum, by their canonical digest, as the registries since 2.1 only
+// look up the layers by the digest of their content. The blobs aren't moved,
+// their layout didn't change. Running it again is harmless.
+func MigrateStorage(driver storagedriver.StorageDriver, options MigrateOptions) (*MigrateResult, error) {
+	// the links are written once the walk is done, so that it doesn't list
+	// directories being changed
+	links := []legacyLayerLink{}
+	err := storage.Walk(driver, repositoriesRoot, func(fileInfo storagedriver.FileInfo) error {
+		if fileInfo.IsDir() {
+			return nil
+		}
+		if link, ok := parseLegacyLayerLink(fileInfo.Path()); ok {
+			links = append(links, link)
+		}
+		return nil
+	})
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrateResult{}
+	errs := []error{}
+	for _, link := range links {
+		content, err := driver.GetContent(link.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading %s: %v", link.path, err))
+			continue
+		}
+		dgst, err := digest.ParseDigest(strings.TrimSpace(string(content)))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid layer link %s: %v", link.path, err))
+			continue
+		}
+
+		hex := dgst.Hex()
+		if _, err := driver.Stat(path.Join(blobsRoot, dgst.Algorithm(), hex[:2], hex, "data")); err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+				errs = append(errs, fmt.Errorf("error checking the blob of %s: %v", link.path, err))
+				continue
+			}
+			log.Warnf("The blob %s of %s is missing, leaving %s", dgst, link.repository, link.path)
+			result.Missing++
+			continue
+		}
+
+		canonical := path.Join(repositoriesRoot, link.repository, "_layers", dgst.Algorithm(), hex, "link")
+		if _, err := driver.Stat(canonical); err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+				errs = append(errs, fmt.Errorf("error checking %s: %v", canonical, err))
+				continue
+			}
+			if !options.DryRun {
+				if err := driver.PutContent(canonical, []byte(dgst)); err != nil {
+					errs = append(errs, fmt.Errorf("error linking layer %s of repository %s: %v", dgst, link.repository, err))
+					continue
+				}
+			}
+			result.Linked++
+			if options.Report != nil {
+				options.Report(link.repository, dgst)
+			}
+		}
+
+		if options.RemoveLegacy {
+			if !options.DryRun {
+				if err := driver.Delete(path.Dir(link.path)); err != nil {
+					errs = append(errs, fmt.Errorf("error removing %s: %v", link.path, err))
+					continue
+				}
+			}
+			result.Removed++
+		}
+	}
+	return result, kerrors.NewAggregate(errs)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestMigrateStorage(t *testing.T) {
+	driver := inmemory.New()
+	hex1 := strings.TrimPrefix(testDigest1, "sha256:")
+	hex2 := strings.TrimPrefix(testDigest2, "sha256:")
+	hex3 := strings.TrimPrefix(testDigest3, "sha256:")
+	for p, content := range map[string]string{
+		blobsRoot + "/sha256/" + hex1[:2] + "/" + hex1 + "/data": "content",
+		blobsRoot + "/sha256/" + hex2[:2] + "/" + hex2 + "/data": "content",
+		// only linked by tarsum
+		repositoriesRoot + "/ns/app/_layers/tarsum/v1/sha256/" + hex3 + "/link": testDigest1,
+		// linked by tarsum and by digest
+		repositoriesRoot + "/ns/app/_layers/tarsum/v1/sha256/" + hex1 + "/link": testDigest2,
+		repositoriesRoot + "/ns/app/_layers/sha256/" + hex2 + "/link":           testDigest2,
+		// the blob is missing
+		repositoriesRoot + "/ns/other/_layers/tarsum/v1/sha256/" + hex2 + "/link": testDigest3,
+	} {
+		if err := driver.PutContent(p, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	canonical := repositoriesRoot + "/ns/app/_layers/sha256/" + hex1 + "/link"
+
+	reported := []string{}
+	options := MigrateOptions{
+		DryRun: true,
+		Report: func(repository string, dgst digest.Digest) {
+			reported = append(reported, repository+"@"+dgst.String())
+		},
+	}
+	result, err := MigrateStorage(driver, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *result != (MigrateResult{Linked: 1, Missing: 1}) {
+		t.Errorf("unexpected dry run result %#v", result)
+	}
+	if len(reported) != 1 || reported[0] != "ns/app@"+testDigest1 {
+		t.Errorf("unexpected layers reported %v", reported)
+	}
+	if _, err := driver.Stat(canonical); err == nil {
+		t.Errorf("expected a dry run not to link the layer")
+	}
+
+	options.DryRun = false
+	options.RemoveLegacy = true
+	result, err = MigrateStorage(driver, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *result != (MigrateResult{Linked: 1, Removed: 2, Missing: 1}) {
+		t.Errorf("unexpected result %#v", result)
+	}
+	if content, err := driver.GetContent(canonical); err != nil || string(content) != testDigest1 {
+		t.Errorf("expected the layer to be linked by its digest, got %q: %v", content, err)
+	}
+	for _, p := range []string{
+		repositoriesRoot + "/ns/app/_layers/tarsum/v1/sha256/" + hex3,
+		repositoriesRoot + "/ns/app/_layers/tarsum/v1/sha256/" + hex1,
+	} {
+		if _, err := driver.Stat(p); err == nil {
+			t.Errorf("expected %s to be removed", p)
+		}
+	}
+	if _, err := driver.Stat(repositoriesRoot + "/ns/other/_layers/tarsum/v1/sha256/" + hex2 + "/link"); err != nil {
+		t.Errorf("expected the link to a missing blob to be kept: %v", err)
+	}
+
+	// nothing is left to migrate
+	result, err = MigrateStorage(driver, options)
+	if err != nil || *result != (MigrateResult{Missing: 1}) {
+		t.Errorf("unexpected result of a second run %#v: %v", result, err)
+	}
+}
@@ -0,0 +1,449 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/hashicorp/golang-lru"
+	"golang.org/x/net/context"
+
+	"github.com/openshift/origin/pkg/dockerregistry"
+)
+
+const (
+	// defaultMirrorTTL is how long the tags resolved by the upstream
+	// registries are trusted before they are resolved again.
+	defaultMirrorTTL = 5 * time.Minute
+	// mirrorManifestCacheSize is the number of manifests remembered by the
+	// mirror.
+	mirrorManifestCacheSize = 1024
+)
+
+// Mirror configures the registry as a read-only mirror of upstream
+// registries, e.g. of docker.io for the builds of the cluster. Pulls don't
+// need image streams: the manifests are fetched from the upstream registries
+// and the layers are stored locally as they are pulled, so that they are
+// served locally afterwards.
+type Mirror struct {
+	// Upstreams are the registries mirrored. Repositories whose first
+	// component names one of them are mirrored from it, the others from
+	// the first one, e.g. with docker.io,quay.io, library/busybox comes
+	// from docker.io and quay.io/coreos/etcd from quay.io.
+	Upstreams []string
+	// TTL is how long a tag resolved by an upstream registry is trusted.
+	// Manifests and layers are addressed by digest and never revalidated.
+	TTL time.Duration
+}
+
+// Enabled returns true if the registry is a mirror.
+func (m Mirror) Enabled() bool {
+	return len(m.Upstreams) > 0
+}
+
+// MirrorFromEnv reads the mirror configuration: the upstream registries from
+// REGISTRY_MIRROR_UPSTREAMS, separated by commas, and the TTL of the tags
+// from REGISTRY_MIRROR_TTL, e.g. 10m.
+func MirrorFromEnv() (Mirror, error) {
+	mirror := Mirror{TTL: defaultMirrorTTL}
+	for _, upstream := range strings.Split(os.Getenv("REGISTRY_MIRROR_UPSTREAMS"), ",") {
+		if upstream = strings.TrimSpace(upstream); len(upstream) > 0 {
+			mirror.Upstreams = append(mirror.Upstreams, upstream)
+		}
+	}
+	if value := os.Getenv("REGISTRY_MIRROR_TTL"); len(value) > 0 {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return Mirror{}, fmt.Errorf("invalid REGISTRY_MIRROR_TTL %q", value)
+		}
+		mirror.TTL = ttl
+	}
+	return mirror, nil
+}
+
+// upstream returns the upstream registry the repository name is mirrored from
+// and the name of the repository in it.
+func (m Mirror) upstream(name string) (string, string) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 {
+		for _, upstream := range m.Upstreams[1:] {
+			if parts[0] == upstream {
+				return upstream, parts[1]
+			}
+		}
+	}
+	return m.Upstreams[0], name
+}
+
+// WithMirror rejects the manifest puts, blob uploads and deletes made through
+// the Docker API with 405 UNSUPPORTED: a mirror only serves pulls. The deletes
+// and prune jobs of the admin API are rejected too: no image references the
+// layers mirrored, which would all be pruned as orphans. The storage of a
+// mirror mustn't be shared with a registry whose blobs are pruned.
+func WithMirror(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWriteRequest(r) && !isAdminWriteRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		errs := &v2.Errors{}
+		errs.Push(v2.ErrorCodeUnsupported, "the registry is a read-only mirror")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(errs)
+	})
+}
+
+// isAdminWriteRequest returns true if r deletes or prunes content through the
+// admin API.
+func isAdminWriteRequest(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/admin/") {
+		return false
+	}
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+// errMirrorReadOnly is returned for the writes to a mirrored repository.
+var errMirrorReadOnly = errors.New("the registry is a read-only mirror")
+
+var (
+	sharedMirrorCachesOnce sync.Once
+	// mirrorTags remembers the digests of the tags resolved by the
+	// upstream registries, keyed by registry, repository and tag.
+	mirrorTags *metadataCache
+	// mirrorManifests remembers the manifests fetched from the upstream
+	// registries, keyed by digest.
+	mirrorManifests *lru.Cache
+)
+
+// mirrorRepository serves a repository of an upstream registry. Its layers are
+// stored in the local repository as they are pulled.
+type mirrorRepository struct {
+	distribution.Repository
+
+	// registry and remoteName identify the upstream repository.
+	registry   string
+	remoteName string
+	insecure   bool
+	connector  dockerregistry.Client
+	tags       *metadataCache
+	manifests  *lru.Cache
+}
+
+// newMirrorRepository returns the mirror of the upstream repository of repo.
+// The tags and manifests are remembered by caches shared by all the
+// repositories, which are created per request.
+func newMirrorRepository(repo distribution.Repository, mirror Mirror) (distribution.Repository, error) {
+	var err error
+	sharedMirrorCachesOnce.Do(func() {
+		if mirrorTags, err = newMetadataCache(mirror.TTL, metadataCacheSize); err != nil {
+			return
+		}
+		mirrorManifests, err = lru.New(mirrorManifestCacheSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	registry, remoteName := mirror.upstream(repo.Name())
+	return &mirrorRepository{
+		Repository: repo,
+		registry:   registry,
+		remoteName: remoteName,
+		insecure:   insecureRegistriesFromEnv().Has(registry),
+		connector:  dockerregistry.NewClient(),
+		tags:       mirrorTags,
+		manifests:  mirrorManifests,
+	}, nil
+}
+
+func (r *mirrorRepository) connect() (dockerregistry.Connection, error) {
+	return r.connector.Connect(r.registry, r.insecure)
+}
+
+// remoteRepository returns the namespace and the name of the upstream
+// repository, the namespace defaults to library.
+func (r *mirrorRepository) remoteRepository() (string, string) {
+	parts := strings.SplitN(r.remoteName, "/", 2)
+	if len(parts) == 1 {
+		return "", parts[0]
+	}
+	return parts[0], parts[1]
+}
+
+func (r *mirrorRepository) logger() ctxu.Logger {
+	return ctxu.GetLoggerWithField(context.Background(), "mirror.upstream", r.registry+"/"+r.remoteName)
+}
+
+// Manifests returns r, which implements distribution.ManifestService.
+func (r *mirrorRepository) Manifests() distribution.ManifestService {
+	return r
+}
+
+// Exists returns true if the upstream registry has the manifest.
+func (r *mirrorRepository) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	if _, err := r.Get(ctx, dgst); err != nil {
+		if _, unknown := err.(distribution.ErrUnknownManifestRevision); unknown {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Get returns the manifest with digest dgst, fetched from the upstream
+// registry unless it was already.
+func (r *mirrorRepository) Get(ctx context.Context, dgst digest.Digest) (*manifest.SignedManifest, error) {
+	if cached, ok := r.manifests.Get(dgst.String()); ok {
+		return cached.(*manifest.SignedManifest), nil
+	}
+	_, m, err := r.fetchManifest(dgst.String())
+	if err != nil {
+		if isUpstreamNotFound(err) {
+			return nil, distribution.ErrUnknownManifestRevision{Name: r.Name(), Revision: dgst}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExistsByTag returns true if the upstream registry has the tag.
+func (r *mirrorRepository) ExistsByTag(ctx context.Context, tag string) (bool, error) {
+	if _, err := r.GetByTag(ctx, tag); err != nil {
+		if _, unknown := err.(distribution.ErrManifestUnknown); unknown {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByTag returns the manifest the tag points to. The tag is resolved by the
+// upstream registry again once its TTL elapsed; while the upstream registry
+// can't be reached, the manifest it pointed to is still served for a while.
+func (r *mirrorRepository) GetByTag(ctx context.Context, tag string) (*manifest.SignedManifest, error) {
+	key := r.registry + "/" + r.remoteName + ":" + tag
+	if cached, ok := r.tags.get(key); ok {
+		return r.Get(ctx, cached.(digest.Digest))
+	}
+
+	dgst, m, err := r.fetchManifest(tag)
+	switch {
+	case err == nil:
+		r.tags.set(key, dgst)
+		return m, nil
+	case isUpstreamNotFound(err):
+		return nil, distribution.ErrManifestUnknown{Name: r.Name(), Tag: tag}
+	}
+	if stale, ok := r.tags.getStale(key); ok {
+		r.logger().Warnf("Serving tag %s from the cache, the upstream registry is unavailable: %v", tag, err)
+		return r.Get(ctx, stale.(digest.Digest))
+	}
+	return nil, err
+}
+
+// isUpstreamNotFound returns true if the upstream registry answered it doesn't
+// have the repository, the tag or the image, rather than couldn't be reached.
+func isUpstreamNotFound(err error) bool {
+	return dockerregistry.IsNotFound(err) && !dockerregistry.IsRegistryNotFound(err)
+}
+
+// fetchManifest fetches the manifest with the given tag or digest from the
+// upstream registry, and remembers it by digest.
+func (r *mirrorRepository) fetchManifest(reference string) (digest.Digest, *manifest.SignedManifest, error) {
+	conn, err := r.connect()
+	if err != nil {
+		return "", nil, err
+	}
+	namespace, name := r.remoteRepository()
+	_, raw, err := conn.ImageManifest(namespace, name, reference)
+	pullthroughRequests.WithLabelValues("manifest", resultLabel(err)).Inc()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var m manifest.SignedManifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return "", nil, err
+	}
+	dgst, err := manifestDigest(&m)
+	if err != nil {
+		return "", nil, err
+	}
+	// a manifest fetched by digest must match it
+	if expected, err := digest.ParseDigest(reference); err == nil && expected != dgst {
+		return "", nil, fmt.Errorf("the manifest %s of %s/%s has digest %s", expected, r.registry, r.remoteName, dgst)
+	}
+	r.manifests.Add(dgst.String(), &m)
+	return dgst, &m, nil
+}
+
+// manifestDigest returns the digest of m, computed without its signatures.
+func manifestDigest(m *manifest.SignedManifest) (digest.Digest, error) {
+	payload, err := m.Payload()
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(payload)
+}
+
+// manifestHead returns the digest and the length of the manifest with the
+// given tag, or else with digest dgst.
+func (r *mirrorRepository) manifestHead(ctx context.Context, tag string, dgst digest.Digest) (_ digest.Digest, _ int64, err error) {
+	defer func() {
+		manifestRequests.WithLabelValues("head", resultLabel(err)).Inc()
+	}()
+
+	var m *manifest.SignedManifest
+	if len(tag) > 0 {
+		if m, err = r.GetByTag(ctx, tag); err != nil {
+			return "", 0, err
+		}
+		if dgst, err = manifestDigest(m); err != nil {
+			return "", 0, err
+		}
+	} else if m, err = r.Get(ctx, dgst); err != nil {
+		return "", 0, err
+	}
+	return dgst, int64(len(m.Raw)), nil
+}
+
+// Tags lists the tags of the upstream repository, sorted.
+func (r *mirrorRepository) Tags(ctx context.Context) ([]string, error) {
+	conn, err := r.connect()
+	if err != nil {
+		return nil, err
+	}
+	namespace, name := r.remoteRepository()
+	remoteTags, err := conn.ImageTags(namespace, name)
+	if err != nil {
+		if dockerregistry.IsRepositoryNotFound(err) {
+			return nil, distribution.ErrRepositoryUnknown{Name: r.Name()}
+		}
+		return nil, err
+	}
+	tags := []string{}
+	for tag := range remoteTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// TagsPage returns up to limit of the tags returned by Tags following last,
+// and whether there are more. A negative limit returns all of them.
+func (r *mirrorRepository) TagsPage(ctx context.Context, limit int, last string) ([]string, bool, error) {
+	tags, err := r.Tags(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	page, more := paginate(tags, last, limit)
+	return page, more, nil
+}
+
+// Put is rejected, the mirror is read-only.
+func (r *mirrorRepository) Put(ctx context.Context, manifest *manifest.SignedManifest) error {
+	return errMirrorReadOnly
+}
+
+// Delete is rejected, the mirror is read-only.
+func (r *mirrorRepository) Delete(ctx context.Context, dgst digest.Digest) error {
+	return errMirrorReadOnly
+}
+
+// Layers returns a layer service that serves the layers stored locally, and
+// stores those it pulls from the upstream registry.
+func (r *mirrorRepository) Layers() distribution.LayerService {
+	return &mirrorLayerService{LayerService: r.Repository.Layers(), repo: r}
+}
+
+// mirrorLayerService falls back to the upstream registry for the layers not
+// stored locally yet.
+type mirrorLayerService struct {
+	distribution.LayerService
+
+	repo *mirrorRepository
+}
+
+var _ distribution.LayerService = &mirrorLayerService{}
+
+// Exists returns true if the layer is stored locally or in the upstream
+// registry.
+func (s *mirrorLayerService) Exists(dgst digest.Digest) (bool, error) {
+	exists, err := s.LayerService.Exists(dgst)
+	if err != nil || exists {
+		return exists, err
+	}
+	content, err := s.fetchRemote(dgst)
+	if err != nil {
+		return false, nil
+	}
+	content.Close()
+	return true, nil
+}
+
+// Fetch returns the local layer, or else the layer streamed from the upstream
+// registry, which is stored locally as it is read.
+func (s *mirrorLayerService) Fetch(dgst digest.Digest) (distribution.Layer, error) {
+	layer, err := s.LayerService.Fetch(dgst)
+	if _, unknown := err.(distribution.ErrUnknownLayer); !unknown {
+		if err == nil {
+			blobServes.WithLabelValues("local").Inc()
+		}
+		return layer, err
+	}
+
+	remote, remoteErr := s.fetchRemote(dgst)
+	if remoteErr != nil {
+		s.repo.logger().Debugf("Layer %s can't be pulled from the upstream registry: %v", dgst, remoteErr)
+		return nil, err
+	}
+	blobServes.WithLabelValues("remote").Inc()
+	s.repo.logger().Infof("Mirroring layer %s", dgst)
+	return remote, nil
+}
+
+func (s *mirrorLayerService) fetchRemote(dgst digest.Digest) (*remoteLayer, error) {
+	conn, err := s.repo.connect()
+	if err != nil {
+		return nil, err
+	}
+	namespace, name := s.repo.remoteRepository()
+	content, size, err := conn.ImageLayer(namespace, name, dgst.String())
+	pullthroughRequests.WithLabelValues("layer", resultLabel(err)).Inc()
+	if err != nil {
+		return nil, err
+	}
+	return &remoteLayer{ReadCloser: content, digest: dgst, length: size, mirrorTo: s.LayerService, logger: s.repo.logger()}, nil
+}
+
+// Delete is rejected, the mirror is read-only.
+func (s *mirrorLayerService) Delete(dgst digest.Digest) error {
+	return errMirrorReadOnly
+}
+
+// Upload is rejected, the mirror is read-only. The layers pulled are stored
+// through the local layer service.
+func (s *mirrorLayerService) Upload() (distribution.LayerUpload, error) {
+	return nil, errMirrorReadOnly
+}
+
+// Resume is rejected, the mirror is read-only.
+func (s *mirrorLayerService) Resume(uuid string) (distribution.LayerUpload, error) {
+	return nil, errMirrorReadOnly
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
+	"github.com/hashicorp/golang-lru"
+	"golang.org/x/net/context"
+
+	"github.com/openshift/origin/pkg/dockerregistry"
+)
+
+// fakeUpstreamRegistry serves a single manifest tagged latest and its layer.
+type fakeUpstreamRegistry struct {
+	dockerregistry.Connection
+
+	raw         []byte
+	unavailable bool
+	requests    []string
+}
+
+func (f *fakeUpstreamRegistry) Connect(registry string, insecure bool) (dockerregistry.Connection, error) {
+	f.requests = append(f.requests, registry)
+	if f.unavailable {
+		return nil, errors.New("connection refused")
+	}
+	return f, nil
+}
+
+func (f *fakeUpstreamRegistry) ImageManifest(namespace, name, reference string) (string, []byte, error) {
+	if namespace != "library" || name != "busybox" || reference != "latest" && !strings.HasPrefix(reference, "sha256:") {
+		return "", nil, dockerregistry.NewImageNotFoundError(namespace+"/"+name, reference, "")
+	}
+	return "", f.raw, nil
+}
+
+func (f *fakeUpstreamRegistry) ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error) {
+	if dgst != testLayerDigest {
+		return nil, 0, dockerregistry.NewImageNotFoundError(namespace+"/"+name, dgst, "")
+	}
+	return ioutil.NopCloser(strings.NewReader("layer")), 5, nil
+}
+
+func (f *fakeUpstreamRegistry) PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakeUpstreamRegistry) PutImageManifest(namespace, name, reference string, manifest []byte) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func TestMirrorUpstream(t *testing.T) {
+	mirror := Mirror{Upstreams: []string{"docker.io", "quay.io"}}
+	for name, expected := range map[string][2]string{
+		"library/busybox":     {"docker.io", "library/busybox"},
+		"quay.io/coreos/etcd": {"quay.io", "coreos/etcd"},
+		"docker.io/busybox":   {"docker.io", "docker.io/busybox"},
+	} {
+		if registry, remoteName := mirror.upstream(name); registry != expected[0] || remoteName != expected[1] {
+			t.Errorf("%s: expected %s/%s, got %s/%s", name, expected[0], expected[1], registry, remoteName)
+		}
+	}
+}
+
+func TestMirrorRepository(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      "library/busybox",
+		Tag:       "latest",
+		FSLayers:  []manifest.FSLayer{{BlobSum: testLayerDigest}},
+		History:   []manifest.History{{V1Compatibility: "{}"}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := manifestDigest(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := newMetadataCache(time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tags.now = func() time.Time { return now }
+	manifests, err := lru.New(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &fakeUpstreamRegistry{raw: signed.Raw}
+	local := &fakeLocalRepository{name: "library/busybox"}
+	r := &mirrorRepository{
+		Repository: local,
+		registry:   "docker.io",
+		remoteName: "library/busybox",
+		connector:  upstream,
+		tags:       tags,
+		manifests:  manifests,
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		m, err := r.GetByTag(ctx, "latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(m.Raw) != string(signed.Raw) {
+			t.Fatalf("unexpected manifest %s", m.Raw)
+		}
+	}
+	if _, err := r.Get(ctx, dgst); err != nil {
+		t.Fatal(err)
+	}
+	if len(upstream.requests) != 1 {
+		t.Errorf("expected the tag to be resolved once, got %d requests", len(upstream.requests))
+	}
+
+	// the tag is revalidated once it expired, and served while the upstream
+	// registry is unavailable
+	now = now.Add(2 * time.Minute)
+	upstream.unavailable = true
+	if _, err := r.GetByTag(ctx, "latest"); err != nil {
+		t.Errorf("expected the expired tag to be served, got %v", err)
+	}
+	if len(upstream.requests) != 2 {
+		t.Errorf("expected the expired tag to be resolved again, got %d requests", len(upstream.requests))
+	}
+	upstream.unavailable = false
+
+	if _, err := r.GetByTag(ctx, "missing"); err == nil {
+		t.Errorf("expected an error for a missing tag")
+	} else if _, unknown := err.(distribution.ErrManifestUnknown); !unknown {
+		t.Errorf("expected ErrManifestUnknown for a missing tag, got %v", err)
+	}
+
+	if err := r.Put(ctx, signed); err != errMirrorReadOnly {
+		t.Errorf("expected pushes to be rejected, got %v", err)
+	}
+
+	layer, err := r.Layers().Fetch(testLayerDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "layer" {
+		t.Errorf("unexpected layer content %q", content)
+	}
+	uploads := local.layers.uploads
+	if len(uploads) != 1 || uploads[0].finished != testLayerDigest || uploads[0].content.String() != "layer" {
+		t.Errorf("expected the layer to be stored locally, got %#v", uploads)
+	}
+	if _, err := r.Layers().Upload(); err != errMirrorReadOnly {
+		t.Errorf("expected uploads to be rejected, got %v", err)
+	}
+}
+
+func TestWithMirror(t *testing.T) {
+	handler := WithMirror(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, test := range []struct {
+		method, path string
+		code         int
+	}{
+		{"GET", "/v2/library/busybox/manifests/latest", http.StatusOK},
+		{"PUT", "/v2/library/busybox/manifests/latest", http.StatusMethodNotAllowed},
+		{"GET", "/admin/blobs", http.StatusOK},
+		{"POST", "/admin/prune", http.StatusMethodNotAllowed},
+		{"DELETE", "/admin/library/busybox/layers/" + testLayerDigest, http.StatusMethodNotAllowed},
+	} {
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.code, w.Code)
+		}
+	}
+}
@@ -3,12 +3,97 @@ package server
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/docker/distribution/configuration"
 	osclient "github.com/openshift/origin/pkg/client"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 )
 
+// MasterClientConfig configures the connections of the registry to the
+// master, in addition to the OPENSHIFT_* environment variables.
+type MasterClientConfig struct {
+	// CAFile verifies the master, instead of OPENSHIFT_CA_DATA.
+	CAFile string
+	// CertFile and KeyFile authenticate the registry, instead of
+	// OPENSHIFT_CERT_DATA and OPENSHIFT_KEY_DATA.
+	CertFile string
+	KeyFile  string
+	// QPS and Burst limit the rate of the requests of each client, zero
+	// keeps the client defaults.
+	QPS   float32
+	Burst int
+	// Timeout cancels the requests the master didn't answer in time, zero
+	// disables it.
+	Timeout time.Duration
+}
+
+// masterClientConfig is used by all the clients of the master.
+var masterClientConfig MasterClientConfig
+
+// UseMasterClientConfig makes the clients of the master created from now on
+// use config.
+func UseMasterClientConfig(config MasterClientConfig) {
+	masterClientConfig = config
+}
+
+// MasterClientConfigFrom reads the master client settings from the options of
+// the openshift repository middleware in config: "masterca", "mastercert",
+// "masterkey", "masterqps", "masterburst" and "mastertimeout".
+func MasterClientConfigFrom(config *configuration.Configuration) (MasterClientConfig, error) {
+	var options map[string]interface{}
+	for _, middleware := range config.Middleware["repository"] {
+		if middleware.Name == "openshift" {
+			options = middleware.Options
+		}
+	}
+
+	clientConfig := MasterClientConfig{
+		CAFile:   stringOption(options, "masterca"),
+		CertFile: stringOption(options, "mastercert"),
+		KeyFile:  stringOption(options, "masterkey"),
+	}
+	if (len(clientConfig.CertFile) == 0) != (len(clientConfig.KeyFile) == 0) {
+		return MasterClientConfig{}, errors.New("mastercert and masterkey must be set together")
+	}
+	if value := stringOption(options, "masterqps"); len(value) > 0 {
+		qps, err := strconv.ParseFloat(value, 32)
+		if err != nil || qps < 0 {
+			return MasterClientConfig{}, fmt.Errorf("invalid masterqps %q", value)
+		}
+		clientConfig.QPS = float32(qps)
+	}
+	if value := stringOption(options, "masterburst"); len(value) > 0 {
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst < 0 {
+			return MasterClientConfig{}, fmt.Errorf("invalid masterburst %q", value)
+		}
+		clientConfig.Burst = burst
+	}
+	if value := stringOption(options, "mastertimeout"); len(value) > 0 {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return MasterClientConfig{}, fmt.Errorf("invalid mastertimeout %q", value)
+		}
+		clientConfig.Timeout = timeout
+	}
+	return clientConfig, nil
+}
+
+// stringOption returns the option name of the middleware configuration as a
+// string, or "" if it isn't set.
+func stringOption(options map[string]interface{}, name string) string {
+	value, ok := options[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 func NewUserOpenShiftClient(bearerToken string) (*osclient.Client, error) {
 	config, err := openShiftClientConfig()
 	if err != nil {
@@ -23,11 +108,38 @@ func NewUserOpenShiftClient(bearerToken string) (*osclient.Client, error) {
 }
 
 func NewRegistryOpenShiftClient() (*osclient.Client, error) {
+	config, err := registryClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := osclient.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Origin client: %s", err)
+	}
+	return client, nil
+}
+
+func NewRegistryKubeClient() (*kclient.Client, error) {
+	config, err := registryClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kclient.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Kubernetes client: %s", err)
+	}
+	return client, nil
+}
+
+func registryClientConfig() (*kclient.Config, error) {
 	config, err := openShiftClientConfig()
 	if err != nil {
 		return nil, err
 	}
-	if !config.Insecure {
+	if !config.Insecure && len(masterClientConfig.CertFile) > 0 {
+		config.TLSClientConfig.CertFile = masterClientConfig.CertFile
+		config.TLSClientConfig.KeyFile = masterClientConfig.KeyFile
+	} else if !config.Insecure {
 		certData := os.Getenv("OPENSHIFT_CERT_DATA")
 		if len(certData) == 0 {
 			return nil, errors.New("OPENSHIFT_CERT_DATA is required")
@@ -39,11 +151,7 @@ func NewRegistryOpenShiftClient() (*osclient.Client, error) {
 		config.TLSClientConfig.CertData = []byte(certData)
 		config.TLSClientConfig.KeyData = []byte(certKeyData)
 	}
-	client, err := osclient.New(config)
-	if err != nil {
-		return nil, fmt.Errorf("error creating Origin client: %s", err)
-	}
-	return client, nil
+	return config, nil
 }
 
 func openShiftClientConfig() (*kclient.Config, error) {
@@ -54,7 +162,9 @@ func openShiftClientConfig() (*kclient.Config, error) {
 
 	insecure := os.Getenv("OPENSHIFT_INSECURE") == "true"
 	var tlsClientConfig kclient.TLSClientConfig
-	if !insecure {
+	if !insecure && len(masterClientConfig.CAFile) > 0 {
+		tlsClientConfig.CAFile = masterClientConfig.CAFile
+	} else if !insecure {
 		caData := os.Getenv("OPENSHIFT_CA_DATA")
 		if len(caData) == 0 {
 			return nil, errors.New("OPENSHIFT_CA_DATA is required")
@@ -64,9 +174,66 @@ func openShiftClientConfig() (*kclient.Config, error) {
 		}
 	}
 
-	return &kclient.Config{
+	config := &kclient.Config{
 		Host:            openshiftAddr,
 		TLSClientConfig: tlsClientConfig,
 		Insecure:        insecure,
-	}, nil
+		QPS:             masterClientConfig.QPS,
+		Burst:           masterClientConfig.Burst,
+	}
+	if timeout := masterClientConfig.Timeout; timeout > 0 {
+		config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return &timeoutRoundTripper{rt: rt, timeout: timeout}
+		}
+	}
+	return config, nil
+}
+
+// requestCanceler is implemented by http.Transport.
+type requestCanceler interface {
+	CancelRequest(*http.Request)
+}
+
+// timeoutRoundTripper cancels the requests whose responses weren't read
+// completely after timeout, so that a master that hangs doesn't hold the
+// connections of the registry.
+type timeoutRoundTripper struct {
+	rt      http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	canceler, ok := t.rt.(requestCanceler)
+	if !ok {
+		return t.rt.RoundTrip(req)
+	}
+	timer := time.AfterFunc(t.timeout, func() {
+		canceler.CancelRequest(req)
+	})
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		timer.Stop()
+		return nil, err
+	}
+	resp.Body = &timerBody{ReadCloser: resp.Body, timer: timer}
+	return resp, nil
+}
+
+// timerBody stops the timer of the request once its response is read.
+type timerBody struct {
+	io.ReadCloser
+	timer *time.Timer
+}
+
+func (b *timerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.timer.Stop()
+	}
+	return n, err
+}
+
+func (b *timerBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
 }
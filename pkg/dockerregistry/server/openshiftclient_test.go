@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+)
+
+func TestMasterClientConfigFrom(t *testing.T) {
+	tests := map[string]struct {
+		options       map[string]interface{}
+		expected      MasterClientConfig
+		expectedError bool
+	}{
+		"none": {},
+		"all": {
+			options: map[string]interface{}{
+				"masterca":      "/ca.crt",
+				"mastercert":    "/client.crt",
+				"masterkey":     "/client.key",
+				"masterqps":     20,
+				"masterburst":   40,
+				"mastertimeout": "30s",
+			},
+			expected: MasterClientConfig{
+				CAFile:   "/ca.crt",
+				CertFile: "/client.crt",
+				KeyFile:  "/client.key",
+				QPS:      20,
+				Burst:    40,
+				Timeout:  30 * time.Second,
+			},
+		},
+		"cert without key": {
+			options:       map[string]interface{}{"mastercert": "/client.crt"},
+			expectedError: true,
+		},
+		"invalid timeout": {
+			options:       map[string]interface{}{"mastertimeout": "soon"},
+			expectedError: true,
+		},
+	}
+
+	for name, test := range tests {
+		config := &configuration.Configuration{
+			Middleware: map[string][]configuration.Middleware{
+				"repository": {{Name: "openshift", Options: configuration.Parameters(test.options)}},
+			},
+		}
+		clientConfig, err := MasterClientConfigFrom(config)
+		if (err != nil) != test.expectedError {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(clientConfig, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", name, test.expected, clientConfig)
+		}
+	}
+}
+
+func TestOpenShiftClientConfigWithFiles(t *testing.T) {
+	defer os.Setenv("OPENSHIFT_MASTER", os.Getenv("OPENSHIFT_MASTER"))
+	defer os.Setenv("OPENSHIFT_CA_DATA", os.Getenv("OPENSHIFT_CA_DATA"))
+	defer os.Setenv("OPENSHIFT_INSECURE", os.Getenv("OPENSHIFT_INSECURE"))
+	defer UseMasterClientConfig(masterClientConfig)
+
+	os.Setenv("OPENSHIFT_MASTER", "https://master:8443")
+	os.Setenv("OPENSHIFT_CA_DATA", "")
+	os.Setenv("OPENSHIFT_INSECURE", "")
+	UseMasterClientConfig(MasterClientConfig{CAFile: "/ca.crt", QPS: 20, Burst: 40})
+
+	config, err := openShiftClientConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.CAFile != "/ca.crt" || config.QPS != 20 || config.Burst != 40 {
+		t.Errorf("unexpected config %#v", config)
+	}
+}
+
+func TestTimeoutRoundTripper(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-release
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: &timeoutRoundTripper{rt: &http.Transport{}, timeout: 50 * time.Millisecond}}
+	resp, err := client.Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get(server.URL + "/hang"); err == nil {
+		t.Errorf("expected the request to be canceled")
+	}
+}
@@ -0,0 +1,487 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/dockerregistry"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// isRemoteImage returns true if the image is stored in another registry than
+// this one, e.g. because it was imported from docker.io.
+func (r *repository) isRemoteImage(image *imageapi.Image) bool {
+	if _, err := imageapi.ParseDockerImageReference(image.DockerImageReference); err != nil {
+		return false
+	}
+	return !isLocalImage(image, r.registryAddr, r.registryAliases)
+}
+
+// remoteConnection connects to the registry the given reference points to,
+// insecurely if the image stream or the tag importing from it says so and
+// the registry is allowed to be contacted insecurely.
+func (r *repository) remoteConnection(ctx context.Context, ref imageapi.DockerImageReference) (dockerregistry.Connection, error) {
+	insecure := false
+	if imageStream, err := r.getImageStream(ctx); err == nil && isInsecureRemote(imageStream, ref) {
+		if r.insecureRegistries.Len() == 0 || r.insecureRegistries.Has(ref.DockerClientDefaults().Registry) {
+			insecure = true
+		} else {
+			r.logger(ctx).Warnf("Connecting securely to %s: it isn't listed in REGISTRY_PULLTHROUGH_INSECURE_REGISTRIES", ref.Registry)
+		}
+	}
+	return r.remoteRegistryClient(ctx).Connect(ref.Registry, insecure)
+}
+
+// remoteRegistryClient returns the client of the remote registries, which
+// authenticates with the docker registry secrets of the project. They are read
+// through the secrets subresource of the image stream, the registry can't read
+// the secrets of the projects itself. The remote registries are contacted
+// anonymously if the secrets can't be read.
+func (r *repository) remoteRegistryClient(ctx context.Context) dockerregistry.Client {
+	if r.remoteClient != nil {
+		return r.remoteClient
+	}
+	secrets, err := r.fetchFromMaster(ctx, "get ImageStream secrets", func() (interface{}, error) {
+		return r.registryClient.ImageStreams(r.namespace).Secrets(r.name)
+	})
+	credentials := dockerregistry.NoCredentials
+	if err == nil {
+		credentials, err = dockerregistry.NewCredentialsForSecrets(secrets.(*kapi.SecretList).Items)
+	}
+	if err != nil {
+		r.logger(ctx).Warnf("Unable to read the docker registry secrets of %s, pulling through anonymously: %v", r.namespace, err)
+		credentials = dockerregistry.NoCredentials
+	}
+	r.remoteClient = r.registryConnector(credentials)
+	return r.remoteClient
+}
+
+// isInsecureRemote returns true if the insecure annotation is set on stream,
+// or on one of its tags importing from the registry of ref.
+func isInsecureRemote(stream *imageapi.ImageStream, ref imageapi.DockerImageReference) bool {
+	if stream.Annotations[imageapi.InsecureRepositoryAnnotation] == "true" {
+		return true
+	}
+	registry := ref.DockerClientDefaults().Registry
+	for _, tagRef := range stream.Spec.Tags {
+		if tagRef.From == nil || tagRef.From.Kind != "DockerImage" || tagRef.Annotations[imageapi.InsecureRepositoryAnnotation] != "true" {
+			continue
+		}
+		from, err := imageapi.ParseDockerImageReference(tagRef.From.Name)
+		if err == nil && from.DockerClientDefaults().Registry == registry {
+			return true
+		}
+	}
+	return false
+}
+
+// insecureRegistriesFromEnv returns the registries listed in
+// REGISTRY_PULLTHROUGH_INSECURE_REGISTRIES, separated by commas, that may be
+// contacted insecurely to pull content through, e.g. registry.local:5000. If
+// none are listed, all the registries may be.
+func insecureRegistriesFromEnv() sets.String {
+	registries := sets.NewString()
+	for _, registry := range strings.Split(os.Getenv("REGISTRY_PULLTHROUGH_INSECURE_REGISTRIES"), ",") {
+		if registry = strings.TrimSpace(registry); len(registry) > 0 {
+			registries.Insert(registry)
+		}
+	}
+	return registries
+}
+
+// pullthroughManifest fetches the manifest of a remote image from the
+// registry that stores it, and verifies that it has the digest of the image.
+func (r *repository) pullthroughManifest(ctx context.Context, image *imageapi.Image) (m *manifest.SignedManifest, err error) {
+	defer func() {
+		pullthroughRequests.WithLabelValues("manifest", resultLabel(err)).Inc()
+	}()
+
+	if r.disablePullthrough {
+		return nil, errPullthroughDisabled
+	}
+
+	ref, err := imageapi.ParseDockerImageReference(image.DockerImageReference)
+	if err != nil {
+		return nil, err
+	}
+	// images imported from v1 registries can't be fetched by digest
+	if _, err := digest.ParseDigest(image.Name); err != nil {
+		return nil, fmt.Errorf("image %s from %s can't be pulled through: it is not addressable by digest", image.Name, image.DockerImageReference)
+	}
+
+	conn, err := r.remoteConnection(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	_, raw, err := conn.ImageManifest(ref.Namespace, ref.Name, image.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var sm manifest.SignedManifest
+	if err := json.Unmarshal(raw, &sm); err != nil {
+		return nil, err
+	}
+	dgst, err := manifestDigest(&sm)
+	if err != nil {
+		return nil, err
+	}
+	if dgst.String() != image.Name {
+		return nil, fmt.Errorf("the manifest of image %s from %s has digest %s", image.Name, image.DockerImageReference, dgst)
+	}
+	return &sm, nil
+}
+
+// Layers returns a layer service that serves layers not stored locally from
+// the remote registries of the images tagged into the image stream.
+func (r *repository) Layers() distribution.LayerService {
+	return &pullthroughLayerService{
+		LayerService: r.Repository.Layers(),
+		repo:         r,
+	}
+}
+
+// pullthroughLayerService falls back to remote registries for unknown layers.
+type pullthroughLayerService struct {
+	distribution.LayerService
+
+	repo *repository
+}
+
+var _ distribution.LayerService = &pullthroughLayerService{}
+
+// Exists returns true if the layer is referenced by an image pushed to the
+// image stream, is stored locally, or is served from elsewhere as told by
+// servesLayer, which verifyLayers accepts in the pushed manifests as well.
+func (s *pullthroughLayerService) Exists(dgst digest.Digest) (bool, error) {
+	ctx := s.repo.requestContext()
+	if s.repo.imageStreamHasLayer(ctx, dgst) {
+		return true, nil
+	}
+
+	exists, err := s.LayerService.Exists(dgst)
+	if err != nil || exists {
+		return exists, err
+	}
+	return s.repo.servesLayer(ctx, dgst), nil
+}
+
+// Fetch returns the local layer, the layer of the repository an image tagged
+// from another image stream was pushed to, or the layer proxied from the
+// remote registry of an image that references it. The local layers are served
+// by the registry if the image stream disables redirects.
+func (s *pullthroughLayerService) Fetch(dgst digest.Digest) (distribution.Layer, error) {
+	layer, err := s.LayerService.Fetch(dgst)
+	if _, unknown := err.(distribution.ErrUnknownLayer); !unknown {
+		if err != nil {
+			return nil, err
+		}
+		blobServes.WithLabelValues("local").Inc()
+		return &localLayer{Layer: layer, repo: s.repo}, nil
+	}
+
+	ctx := s.repo.requestContext()
+	if layer, source, sourceErr := s.repo.fetchSourceLayer(ctx, dgst); sourceErr == nil {
+		blobServes.WithLabelValues("local").Inc()
+		return &localLayer{Layer: layer, repo: s.repo, source: source}, nil
+	}
+	ref, findErr := s.repo.findRemoteLayer(ctx, dgst)
+	if findErr != nil {
+		s.repo.logger(ctx).Debugf("Layer %s of %s/%s can't be pulled through: %v", dgst, s.repo.namespace, s.repo.name, findErr)
+		return nil, err
+	}
+
+	conn, err := s.repo.remoteConnection(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	content, size, err := conn.ImageLayer(ref.Namespace, ref.Name, dgst.String())
+	pullthroughRequests.WithLabelValues("layer", resultLabel(err)).Inc()
+	if err != nil {
+		return nil, err
+	}
+	blobServes.WithLabelValues("remote").Inc()
+	s.repo.logger(ctx).Infof("Pulling layer %s of %s/%s through from %s", dgst, s.repo.namespace, s.repo.name, ref.Exact())
+
+	remote := &remoteLayer{ReadCloser: content, digest: dgst, length: size, logger: s.repo.logger(ctx)}
+	if s.repo.shouldMirror(ctx) {
+		remote.mirrorTo = s.LayerService
+	}
+	return remote, nil
+}
+
+// shouldMirror returns true if the layers pulled through for r are to be
+// stored locally, as set by the annotation of the image stream or else by the
+// "mirrorpullthrough" option.
+func (r *repository) shouldMirror(ctx context.Context) bool {
+	if imageStream, err := r.getImageStream(ctx); err == nil {
+		if mirror, err := strconv.ParseBool(imageStream.Annotations[imageapi.MirrorPullthroughAnnotation]); err == nil {
+			return mirror
+		}
+	}
+	return r.mirrorPullthrough
+}
+
+// Delete unlinks the layer from the repository and drops the cached layers of
+// the image stream.
+func (s *pullthroughLayerService) Delete(dgst digest.Digest) error {
+	layerCache.forget(s.repo.cacheKey())
+	return s.LayerService.Delete(dgst)
+}
+
+var (
+	// errRemoteLayerNotFound is returned when no remote image references a
+	// layer.
+	errRemoteLayerNotFound = errors.New("no remote image references the layer")
+	// errPullthroughDisabled is returned for remote images and layers when
+	// the "pullthrough" option is off.
+	errPullthroughDisabled = errors.New("pullthrough is disabled")
+)
+
+// findRemoteLayer returns the reference to a remote image tagged into the
+// image stream whose manifest contains the given layer.
+func (r *repository) findRemoteLayer(ctx context.Context, dgst digest.Digest) (imageapi.DockerImageReference, error) {
+	if r.disablePullthrough {
+		return imageapi.DockerImageReference{}, errPullthroughDisabled
+	}
+
+	var ref imageapi.DockerImageReference
+	_, err := r.findLayerImage(ctx, dgst, func(event imageapi.TagEvent, image *imageapi.Image) bool {
+		if !r.isRemoteImage(image) {
+			return false
+		}
+		eventRef, err := imageapi.ParseDockerImageReference(event.DockerImageReference)
+		if err != nil {
+			return false
+		}
+		ref = eventRef
+		return true
+	})
+	if err != nil {
+		return imageapi.DockerImageReference{}, err
+	}
+	return ref, nil
+}
+
+// findLayerImage returns the first image of the tag history of the image
+// stream accepted by accept whose manifest contains the given layer, or
+// errRemoteLayerNotFound.
+func (r *repository) findLayerImage(ctx context.Context, dgst digest.Digest, accept func(imageapi.TagEvent, *imageapi.Image) bool) (*imageapi.Image, error) {
+	imageStream, err := r.getImageStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := sets.NewString()
+	for _, history := range imageStream.Status.Tags {
+		for _, event := range history.Items {
+			if seen.Has(event.Image) {
+				continue
+			}
+			seen.Insert(event.Image)
+
+			imageDigest, err := digest.ParseDigest(event.Image)
+			if err != nil {
+				continue
+			}
+			image, err := r.getImage(ctx, imageDigest)
+			if err != nil || len(image.DockerImageManifest) == 0 || !accept(event, image) {
+				continue
+			}
+
+			var m manifest.Manifest
+			if err := json.Unmarshal([]byte(image.DockerImageManifest), &m); err != nil {
+				continue
+			}
+			for _, layer := range m.FSLayers {
+				if layer.BlobSum == dgst {
+					return image, nil
+				}
+			}
+		}
+	}
+	return nil, errRemoteLayerNotFound
+}
+
+// layerMirrors is the set of the digests of the layers being mirrored, so that
+// the concurrent pulls of a layer store it once: only the first one mirrors
+// it, the others are merely streamed from the remote registry.
+var layerMirrors = &mirrorsInFlight{digests: sets.NewString()}
+
+type mirrorsInFlight struct {
+	lock    sync.Mutex
+	digests sets.String
+}
+
+// start returns true if dgst isn't being mirrored already, and then records
+// that it is until done is called.
+func (m *mirrorsInFlight) start(dgst digest.Digest) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.digests.Has(dgst.String()) {
+		return false
+	}
+	m.digests.Insert(dgst.String())
+	return true
+}
+
+func (m *mirrorsInFlight) done(dgst digest.Digest) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.digests.Delete(dgst.String())
+}
+
+// remoteLayer is a layer streamed from a remote registry. It can only be read
+// sequentially.
+type remoteLayer struct {
+	io.ReadCloser
+
+	digest digest.Digest
+	length int64
+
+	// mirrorTo stores the content as it is read, once read completely the
+	// layer is served locally. nil disables mirroring.
+	mirrorTo distribution.LayerService
+	// mirror is the upload of the content read so far, started by the first
+	// read so that layers whose content isn't read aren't uploaded.
+	mirror distribution.LayerUpload
+
+	// logger carries the fields of the repository the layer is pulled
+	// through for. nil logs without them.
+	logger ctxu.Logger
+}
+
+func (l *remoteLayer) getLogger() ctxu.Logger {
+	if l.logger == nil {
+		return ctxu.GetLogger(context.Background())
+	}
+	return l.logger
+}
+
+// Read reads from the remote registry, and mirrors what was read unless the
+// layer is being mirrored by another pull.
+func (l *remoteLayer) Read(p []byte) (int, error) {
+	if l.mirrorTo != nil && l.mirror == nil {
+		l.startMirror()
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	if l.mirror == nil {
+		return n, err
+	}
+	if n > 0 {
+		if _, writeErr := l.mirror.Write(p[:n]); writeErr != nil {
+			l.getLogger().Errorf("Error mirroring layer %s: %v", l.digest, writeErr)
+			l.cancelMirror()
+		}
+	}
+	if err == io.EOF && l.mirror != nil {
+		if _, finishErr := l.mirror.Finish(l.digest); finishErr != nil {
+			l.getLogger().Errorf("Error mirroring layer %s: %v", l.digest, finishErr)
+			l.cancelMirror()
+		} else {
+			l.getLogger().Infof("Mirrored layer %s", l.digest)
+			l.endMirror()
+		}
+	}
+	return n, err
+}
+
+// startMirror starts the upload of the layer, unless it's being mirrored by
+// another pull.
+func (l *remoteLayer) startMirror() {
+	if !layerMirrors.start(l.digest) {
+		l.getLogger().Debugf("Layer %s is being mirrored by another pull", l.digest)
+		l.mirrorTo = nil
+		return
+	}
+	upload, err := l.mirrorTo.Upload()
+	if err != nil {
+		l.getLogger().Errorf("Error starting to mirror layer %s: %v", l.digest, err)
+		l.mirrorTo = nil
+		layerMirrors.done(l.digest)
+		return
+	}
+	l.mirror = upload
+}
+
+// endMirror stops mirroring the layer, so that another pull may mirror it if
+// it wasn't stored.
+func (l *remoteLayer) endMirror() {
+	l.mirror, l.mirrorTo = nil, nil
+	layerMirrors.done(l.digest)
+}
+
+// Close cancels mirroring a layer that wasn't read completely.
+func (l *remoteLayer) Close() error {
+	if l.mirror != nil {
+		l.cancelMirror()
+	}
+	return l.ReadCloser.Close()
+}
+
+func (l *remoteLayer) cancelMirror() {
+	if err := l.mirror.Cancel(); err != nil {
+		l.getLogger().Debugf("Error canceling the mirror of layer %s: %v", l.digest, err)
+	}
+	l.endMirror()
+}
+
+var _ distribution.Layer = &remoteLayer{}
+
+// Seek only supports determining the length of the layer.
+func (l *remoteLayer) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == os.SEEK_END && l.length >= 0 {
+		return l.length, nil
+	}
+	return 0, fmt.Errorf("seeking is not supported on remote layer %s", l.digest)
+}
+
+func (l *remoteLayer) Digest() digest.Digest {
+	return l.digest
+}
+
+func (l *remoteLayer) Length() int64 {
+	return l.length
+}
+
+func (l *remoteLayer) CreatedAt() time.Time {
+	return time.Time{}
+}
+
+// Handler streams the layer to the client.
+func (l *remoteLayer) Handler(r *http.Request) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer l.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", l.digest.String())
+		if l.length >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(l.length, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+
+		if req.Method == "HEAD" {
+			return
+		}
+		if _, err := io.Copy(w, l); err != nil {
+			l.getLogger().Errorf("Error streaming remote layer %s: %v", l.digest, err)
+		}
+	}), nil
+}
@@ -0,0 +1,475 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/dockerregistry"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+const testLayerDigest = "sha256:00000000000000000000000000000000000000000000000000000000000000aa"
+
+// fakeLocalRepository has no layers stored locally.
+type fakeLocalRepository struct {
+	distribution.Repository
+
+	name   string
+	layers fakeLocalLayers
+}
+
+func (r *fakeLocalRepository) Name() string {
+	return r.name
+}
+
+func (r *fakeLocalRepository) Layers() distribution.LayerService {
+	return &r.layers
+}
+
+type fakeLocalLayers struct {
+	distribution.LayerService
+
+	// uploads are the layers uploaded
+	uploads []*fakeLayerUpload
+}
+
+func (l *fakeLocalLayers) Upload() (distribution.LayerUpload, error) {
+	upload := &fakeLayerUpload{}
+	l.uploads = append(l.uploads, upload)
+	return upload, nil
+}
+
+type fakeLayerUpload struct {
+	distribution.LayerUpload
+
+	content   bytes.Buffer
+	finished  digest.Digest
+	cancelled bool
+}
+
+func (u *fakeLayerUpload) Write(p []byte) (int, error) {
+	return u.content.Write(p)
+}
+
+func (u *fakeLayerUpload) ReadFrom(r io.Reader) (int64, error) {
+	return u.content.ReadFrom(r)
+}
+
+func (u *fakeLayerUpload) Finish(dgst digest.Digest) (distribution.Layer, error) {
+	u.finished = dgst
+	return nil, nil
+}
+
+func (u *fakeLayerUpload) Cancel() error {
+	u.cancelled = true
+	return nil
+}
+
+func (l *fakeLocalLayers) Exists(dgst digest.Digest) (bool, error) {
+	return false, nil
+}
+
+func (l *fakeLocalLayers) Fetch(dgst digest.Digest) (distribution.Layer, error) {
+	return nil, distribution.ErrUnknownLayer{FSLayer: manifest.FSLayer{BlobSum: dgst}}
+}
+
+// fakeRemoteRegistry serves a single layer and manifest.
+type fakeRemoteRegistry struct {
+	dockerregistry.Connection
+
+	registry, repository string
+	insecure             bool
+	manifest             []byte
+}
+
+func (f *fakeRemoteRegistry) Connect(registry string, insecure bool) (dockerregistry.Connection, error) {
+	f.registry = registry
+	f.insecure = insecure
+	return f, nil
+}
+
+func (f *fakeRemoteRegistry) ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error) {
+	f.repository = namespace + "/" + name
+	if dgst != testLayerDigest {
+		return nil, 0, fmt.Errorf("unexpected layer %s", dgst)
+	}
+	return ioutil.NopCloser(strings.NewReader("layer")), 5, nil
+}
+
+func (f *fakeRemoteRegistry) ImageManifest(namespace, name, reference string) (string, []byte, error) {
+	f.repository = namespace + "/" + name
+	return "", f.manifest, nil
+}
+
+func (f *fakeRemoteRegistry) PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeRemoteRegistry) PutImageManifest(namespace, name, reference string, manifest []byte) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func TestPullthroughLayer(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{
+					Image:                testDigest1,
+					DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+		DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+		DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	remote := &fakeRemoteRegistry{}
+	r := &repository{
+		Repository:        &fakeLocalRepository{},
+		registryClient:    client,
+		registryAddr:      "registry:5000",
+		namespace:         "ns",
+		name:              "app",
+		registryConnector: func(dockerregistry.Credentials) dockerregistry.Client { return remote },
+	}
+
+	exists, err := r.Layers().Exists(testLayerDigest)
+	if err != nil || !exists {
+		t.Fatalf("expected the remote layer to exist, got %v: %v", exists, err)
+	}
+
+	layer, err := r.Layers().Fetch(testLayerDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := ioutil.ReadAll(layer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != "layer" || layer.Length() != 5 {
+		t.Errorf("unexpected layer of length %d: %s", layer.Length(), content)
+	}
+	if remote.registry != "docker.io" || remote.repository != "library/busybox" {
+		t.Errorf("unexpected remote repository %s/%s", remote.registry, remote.repository)
+	}
+
+	if _, err := r.Layers().Fetch(testDigest2); err == nil {
+		t.Errorf("expected an error for a layer no image references")
+	}
+	if _, err := r.findRemoteLayer(context.Background(), testDigest3); err != errRemoteLayerNotFound {
+		t.Errorf("expected %v, got %v", errRemoteLayerNotFound, err)
+	}
+	if uploads := r.Repository.(*fakeLocalRepository).layers.uploads; len(uploads) != 0 {
+		t.Errorf("expected no layer to be mirrored, got %d", len(uploads))
+	}
+
+	r.disablePullthrough = true
+	if exists, _ := r.Layers().Exists(testLayerDigest); exists {
+		t.Errorf("expected the remote layer not to exist with pullthrough disabled")
+	}
+	if _, err := r.Layers().Fetch(testLayerDigest); err == nil {
+		t.Errorf("expected an error for the remote layer with pullthrough disabled")
+	}
+}
+
+func TestPullthroughManifestDigest(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      "library/busybox",
+		Tag:       "latest",
+		FSLayers:  []manifest.FSLayer{{BlobSum: testLayerDigest}},
+		History:   []manifest.History{{V1Compatibility: "{}"}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := manifestDigest(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &fakeRemoteRegistry{manifest: signed.Raw}
+	r := &repository{
+		Repository:        &fakeLocalRepository{},
+		registryClient:    &testclient.Fake{},
+		registryAddr:      "registry:5000",
+		namespace:         "ns",
+		name:              "app",
+		registryConnector: func(dockerregistry.Credentials) dockerregistry.Client { return remote },
+	}
+
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: dgst.String()},
+		DockerImageReference: "docker.io/library/busybox@" + dgst.String(),
+	}
+	if _, err := r.pullthroughManifest(context.Background(), image); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if remote.repository != "library/busybox" {
+		t.Errorf("unexpected remote repository %s", remote.repository)
+	}
+
+	image = &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+		DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+	}
+	if _, err := r.pullthroughManifest(context.Background(), image); err == nil {
+		t.Errorf("expected an error for a manifest that doesn't have the digest of the image")
+	}
+}
+
+func TestPullthroughLayerRequestContext(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{
+					Image:                testDigest1,
+					DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+		DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+		DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &repository{
+		Repository:        &fakeLocalRepository{},
+		ctx:               ctx,
+		registryClient:    client,
+		registryAddr:      "registry:5000",
+		namespace:         "ns",
+		name:              "app",
+		registryConnector: func(dockerregistry.Credentials) dockerregistry.Client { return &fakeRemoteRegistry{} },
+	}
+
+	// the request is gone, so the master isn't asked for the image stream
+	if exists, _ := r.Layers().Exists(testLayerDigest); exists {
+		t.Errorf("expected the remote layer not to be found once the request is done")
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("unexpected actions once the request is done: %#v", client.Actions())
+	}
+}
+
+func TestPullthroughLayerMirror(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		option     bool
+		annotation string
+		mirrored   bool
+	}{
+		{name: "option", option: true, mirrored: true},
+		{name: "annotation", annotation: "true", mirrored: true},
+		{name: "annotation overrides option", option: true, annotation: "false"},
+		{name: "invalid annotation", option: true, annotation: "maybe", mirrored: true},
+	} {
+		stream := &imageapi.ImageStream{
+			ObjectMeta: kapi.ObjectMeta{
+				Namespace:   "ns",
+				Name:        "app",
+				Annotations: map[string]string{},
+			},
+			Status: imageapi.ImageStreamStatus{
+				Tags: map[string]imageapi.TagEventList{
+					"latest": {Items: []imageapi.TagEvent{{
+						Image:                testDigest1,
+						DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+					}}},
+				},
+			},
+		}
+		if len(test.annotation) > 0 {
+			stream.Annotations[imageapi.MirrorPullthroughAnnotation] = test.annotation
+		}
+		image := &imageapi.Image{
+			ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+			DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+			DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+		}
+		client, _ := testclient.NewImageTrackerFake(stream, image)
+		local := &fakeLocalRepository{}
+		r := &repository{
+			Repository:        local,
+			registryClient:    client,
+			registryAddr:      "registry:5000",
+			namespace:         "ns",
+			name:              "app",
+			registryConnector: func(dockerregistry.Credentials) dockerregistry.Client { return &fakeRemoteRegistry{} },
+			mirrorPullthrough: test.option,
+		}
+
+		layer, err := r.Layers().Fetch(testLayerDigest)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(local.layers.uploads) != 0 {
+			t.Errorf("%s: expected the mirror to start with the first read", test.name)
+		}
+		if _, err := ioutil.ReadAll(layer); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		layer.Close()
+
+		if !test.mirrored {
+			if len(local.layers.uploads) != 0 {
+				t.Errorf("%s: expected no layer to be mirrored", test.name)
+			}
+			continue
+		}
+		if len(local.layers.uploads) != 1 {
+			t.Fatalf("%s: expected one upload, got %d", test.name, len(local.layers.uploads))
+		}
+		upload := local.layers.uploads[0]
+		if upload.content.String() != "layer" || upload.finished != testLayerDigest || upload.cancelled {
+			t.Errorf("%s: unexpected upload of %q finished as %q, cancelled %v", test.name, upload.content.String(), upload.finished, upload.cancelled)
+		}
+	}
+}
+
+func TestPullthroughLayerMirrorCancel(t *testing.T) {
+	local := &fakeLocalLayers{}
+	layer := &remoteLayer{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("layer")),
+		digest:     testLayerDigest,
+		length:     5,
+		mirrorTo:   local,
+	}
+	if _, err := layer.Read(make([]byte, 2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	layer.Close()
+
+	if len(local.uploads) != 1 {
+		t.Fatalf("expected one upload, got %d", len(local.uploads))
+	}
+	if upload := local.uploads[0]; !upload.cancelled || len(upload.finished) != 0 {
+		t.Errorf("expected the upload of a partially read layer to be cancelled, got finished %q, cancelled %v", upload.finished, upload.cancelled)
+	}
+}
+
+func TestRemoteConnectionInsecure(t *testing.T) {
+	tests := map[string]struct {
+		streamAnnotations map[string]string
+		tags              map[string]imageapi.TagReference
+		allowed           sets.String
+		expected          bool
+	}{
+		"secure": {},
+		"insecure image stream": {
+			streamAnnotations: map[string]string{imageapi.InsecureRepositoryAnnotation: "true"},
+			expected:          true,
+		},
+		"insecure tag": {
+			tags: map[string]imageapi.TagReference{"latest": {
+				Annotations: map[string]string{imageapi.InsecureRepositoryAnnotation: "true"},
+				From:        &kapi.ObjectReference{Kind: "DockerImage", Name: "registry.local:5000/ns/app:latest"},
+			}},
+			expected: true,
+		},
+		"insecure tag of another registry": {
+			tags: map[string]imageapi.TagReference{"latest": {
+				Annotations: map[string]string{imageapi.InsecureRepositoryAnnotation: "true"},
+				From:        &kapi.ObjectReference{Kind: "DockerImage", Name: "busybox"},
+			}},
+		},
+		"allowed registry": {
+			streamAnnotations: map[string]string{imageapi.InsecureRepositoryAnnotation: "true"},
+			allowed:           sets.NewString("registry.local:5000"),
+			expected:          true,
+		},
+		"registry not allowed": {
+			streamAnnotations: map[string]string{imageapi.InsecureRepositoryAnnotation: "true"},
+			allowed:           sets.NewString("other.local:5000"),
+		},
+	}
+
+	for name, test := range tests {
+		stream := &imageapi.ImageStream{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app", Annotations: test.streamAnnotations},
+			Spec:       imageapi.ImageStreamSpec{Tags: test.tags},
+		}
+		client, _ := testclient.NewImageTrackerFake(stream)
+		remote := &fakeRemoteRegistry{}
+		r := &repository{
+			registryClient:     client,
+			namespace:          "ns",
+			name:               "app",
+			registryConnector:  func(dockerregistry.Credentials) dockerregistry.Client { return remote },
+			insecureRegistries: test.allowed,
+		}
+		ref := imageapi.DockerImageReference{Registry: "registry.local:5000", Namespace: "ns", Name: "app"}
+		if _, err := r.remoteConnection(context.Background(), ref); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if remote.insecure != test.expected {
+			t.Errorf("%s: expected insecure %t, got %t", name, test.expected, remote.insecure)
+		}
+	}
+}
+
+func TestPullthroughLayerMirrorOnce(t *testing.T) {
+	local := &fakeLocalLayers{}
+	newLayer := func() *remoteLayer {
+		return &remoteLayer{
+			ReadCloser: ioutil.NopCloser(strings.NewReader("layer")),
+			digest:     testLayerDigest,
+			length:     5,
+			mirrorTo:   local,
+		}
+	}
+
+	// concurrent pulls of the layer
+	first, second := newLayer(), newLayer()
+	if _, err := first.Read(make([]byte, 2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ioutil.ReadAll(second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second.Close()
+	if len(local.uploads) != 1 {
+		t.Fatalf("expected the layer to be mirrored once, got %d uploads", len(local.uploads))
+	}
+	if _, err := ioutil.ReadAll(first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.Close()
+	if upload := local.uploads[0]; upload.content.String() != "layer" || upload.finished != testLayerDigest {
+		t.Errorf("unexpected upload of %q finished as %q", upload.content.String(), upload.finished)
+	}
+
+	// a layer whose mirror ended may be mirrored again
+	third := newLayer()
+	ioutil.ReadAll(third)
+	third.Close()
+	if len(local.uploads) != 2 {
+		t.Errorf("expected the layer to be mirrored again, got %d uploads", len(local.uploads))
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/digest"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// QuotaExceededError is returned when pushing an image would exceed the image
+// quota or the image stream limits of the project, or the size limits of the
+// registry.
+type QuotaExceededError struct {
+	Message string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return "denied: " + e.Message
+}
+
+// IsQuotaExceeded returns true if err is a QuotaExceededError.
+func IsQuotaExceeded(err error) bool {
+	_, ok := err.(*QuotaExceededError)
+	return ok
+}
+
+// admitImagePush verifies that the image with digest dgst, whose distinct
+// layers add up to size bytes, isn't larger than the "storage" maximum of an
+// "openshift.io/Image" LimitRange of the project, and that it can be tagged
+// into the image stream of r without exceeding the "openshift.io/images"
+// maximum of an "openshift.io/ImageStream" LimitRange. If the image stream
+// doesn't exist yet, it verifies that creating it doesn't exceed the
+// "openshift.io/imagestreams" hard limit of a ResourceQuota of the project.
+// Nothing is verified if the "enforcequota" option is off.
+func (r *repository) admitImagePush(ctx context.Context, dgst digest.Digest, size int64) error {
+	if r.disableQuota {
+		return nil
+	}
+
+	obj, err := fetchWithDeadline(ctx, r.apiTimeout, "list LimitRanges", func(ctx context.Context) (interface{}, error) {
+		return r.kubeClient.LimitRanges(r.namespace).List(labels.Everything(), fields.Everything())
+	})
+	if err != nil {
+		return err
+	}
+	limitRanges := obj.(*kapi.LimitRangeList)
+	if err := r.admitImageSizeLimit(ctx, limitRanges, dgst, size); err != nil {
+		return err
+	}
+
+	stream, err := r.getImageStream(ctx)
+	switch {
+	case kerrors.IsNotFound(err):
+		return r.admitImageStreamCreation(ctx)
+	case err != nil:
+		return err
+	}
+	return r.admitImageIntoStream(ctx, limitRanges, stream, dgst)
+}
+
+// admitImageSizeLimit verifies the image size limits of the project.
+func (r *repository) admitImageSizeLimit(ctx context.Context, limitRanges *kapi.LimitRangeList, dgst digest.Digest, size int64) error {
+	max, limitRange, ok := imageapi.ImageSizeLimit(limitRanges.Items)
+	if !ok || size <= max {
+		return nil
+	}
+	r.logger(ctx).Infof("Denying push of image %s to %s/%s: limit range %s allows images of %d bytes", dgst, r.namespace, r.name, limitRange, max)
+	return &QuotaExceededError{
+		Message: fmt.Sprintf("image %s can't be pushed: the image is %d bytes, larger than the maximum of %d bytes set by limit range %q", dgst, size, max, limitRange),
+	}
+}
+
+// admitImageStreamCreation verifies the image stream quota of the project
+// with the usage recorded in the status of its ResourceQuotas, which the
+// master reserves when it creates the stream. A quota whose usage isn't known
+// yet is left to the master, so that the stream isn't counted here.
+func (r *repository) admitImageStreamCreation(ctx context.Context) error {
+	quotas, err := fetchWithDeadline(ctx, r.apiTimeout, "list ResourceQuotas", func(ctx context.Context) (interface{}, error) {
+		return r.kubeClient.ResourceQuotas(r.namespace).List(labels.Everything(), fields.Everything())
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, quota := range quotas.(*kapi.ResourceQuotaList).Items {
+		hard, ok := quota.Spec.Hard[imageapi.ResourceImageStreams]
+		if !ok {
+			continue
+		}
+		used, ok := quota.Status.Used[imageapi.ResourceImageStreams]
+		if !ok {
+			continue
+		}
+		if used.Value() >= hard.Value() {
+			r.logger(ctx).Infof("Denying creation of image stream %s/%s: quota %s allows %d image streams", r.namespace, r.name, quota.Name, hard.Value())
+			return &QuotaExceededError{
+				Message: fmt.Sprintf("image stream %s/%s can't be created: project %s has reached its quota %q of %d image streams", r.namespace, r.name, r.namespace, quota.Name, hard.Value()),
+			}
+		}
+	}
+	return nil
+}
+
+// admitImageIntoStream verifies the image limits of the image stream.
+func (r *repository) admitImageIntoStream(ctx context.Context, limitRanges *kapi.LimitRangeList, stream *imageapi.ImageStream, dgst digest.Digest) error {
+	images := sets.NewString()
+	for _, history := range stream.Status.Tags {
+		for _, event := range history.Items {
+			images.Insert(event.Image)
+		}
+	}
+	// retagging an image the stream already references adds nothing
+	if images.Has(dgst.String()) {
+		return nil
+	}
+
+	for _, limitRange := range limitRanges.Items {
+		for _, limit := range limitRange.Spec.Limits {
+			if limit.Type != imageapi.LimitTypeImageStream {
+				continue
+			}
+			max, ok := limit.Max[imageapi.ResourceImages]
+			if !ok {
+				continue
+			}
+			if int64(images.Len()) >= max.Value() {
+				r.logger(ctx).Infof("Denying push of image %s to %s/%s: limit range %s allows %d images per image stream", dgst, r.namespace, r.name, limitRange.Name, max.Value())
+				return &QuotaExceededError{
+					Message: fmt.Sprintf("image %s can't be pushed: image stream %s/%s has reached the limit of %d images set by limit range %q", dgst, r.namespace, r.name, max.Value(), limitRange.Name),
+				}
+			}
+		}
+	}
+	return nil
+}
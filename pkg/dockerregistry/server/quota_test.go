@@ -0,0 +1,164 @@
+package server
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/resource"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestAdmitImagePush(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest2}, {Image: testDigest1}}},
+			},
+		},
+	}
+	otherStream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "other"},
+	}
+	imageLimit := func(max int64) *kapi.LimitRangeList {
+		return &kapi.LimitRangeList{
+			Items: []kapi.LimitRange{{
+				ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "limits"},
+				Spec: kapi.LimitRangeSpec{
+					Limits: []kapi.LimitRangeItem{{
+						Type: imageapi.LimitTypeImageStream,
+						Max:  kapi.ResourceList{imageapi.ResourceImages: *resource.NewQuantity(max, resource.DecimalSI)},
+					}},
+				},
+			}},
+		}
+	}
+	sizeLimit := func(max int64) *kapi.LimitRangeList {
+		return &kapi.LimitRangeList{
+			Items: []kapi.LimitRange{{
+				ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "sizes"},
+				Spec: kapi.LimitRangeSpec{
+					Limits: []kapi.LimitRangeItem{{
+						Type: imageapi.LimitTypeImage,
+						Max:  kapi.ResourceList{kapi.ResourceStorage: *resource.NewQuantity(max, resource.BinarySI)},
+					}},
+				},
+			}},
+		}
+	}
+	// streamQuota returns a quota of hard image streams, used unknown if used
+	// is negative
+	streamQuota := func(hard, used int64) *kapi.ResourceQuotaList {
+		quota := kapi.ResourceQuota{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "quota"},
+			Spec: kapi.ResourceQuotaSpec{
+				Hard: kapi.ResourceList{imageapi.ResourceImageStreams: *resource.NewQuantity(hard, resource.DecimalSI)},
+			},
+		}
+		if used >= 0 {
+			quota.Status.Used = kapi.ResourceList{imageapi.ResourceImageStreams: *resource.NewQuantity(used, resource.DecimalSI)}
+		}
+		return &kapi.ResourceQuotaList{Items: []kapi.ResourceQuota{quota}}
+	}
+
+	tests := map[string]struct {
+		streams      []runtime.Object
+		kubeObjects  []runtime.Object
+		name         string
+		size         int64
+		expectDenied bool
+	}{
+		"no limits": {
+			streams:     []runtime.Object{stream},
+			kubeObjects: []runtime.Object{&kapi.LimitRangeList{}},
+			name:        "app",
+		},
+		"below image limit": {
+			streams:     []runtime.Object{stream},
+			kubeObjects: []runtime.Object{imageLimit(3)},
+			name:        "app",
+		},
+		"image limit reached": {
+			streams:      []runtime.Object{stream},
+			kubeObjects:  []runtime.Object{imageLimit(2)},
+			name:         "app",
+			expectDenied: true,
+		},
+		"below image size limit": {
+			streams:     []runtime.Object{stream},
+			kubeObjects: []runtime.Object{sizeLimit(1024)},
+			name:        "app",
+			size:        1024,
+		},
+		"image size limit exceeded": {
+			streams:      []runtime.Object{stream},
+			kubeObjects:  []runtime.Object{sizeLimit(1024)},
+			name:         "new",
+			size:         1025,
+			expectDenied: true,
+		},
+		"new stream below quota": {
+			streams:     []runtime.Object{stream},
+			kubeObjects: []runtime.Object{streamQuota(2, 1)},
+			name:        "new",
+		},
+		"stream quota reached": {
+			streams:      []runtime.Object{stream, otherStream},
+			kubeObjects:  []runtime.Object{streamQuota(2, 2)},
+			name:         "new",
+			expectDenied: true,
+		},
+		"stream quota usage unknown": {
+			streams:     []runtime.Object{stream, otherStream},
+			kubeObjects: []runtime.Object{streamQuota(2, -1)},
+			name:        "new",
+		},
+	}
+
+	for name, test := range tests {
+		client, _ := testclient.NewImageTrackerFake(test.streams...)
+		r := &repository{
+			registryClient: client,
+			kubeClient:     ktestclient.NewSimpleFake(test.kubeObjects...),
+			registryAddr:   "registry:5000",
+			namespace:      "ns",
+			name:           test.name,
+		}
+
+		err := r.admitImagePush(context.Background(), testDigest3, test.size)
+		if test.expectDenied {
+			if !IsQuotaExceeded(err) {
+				t.Errorf("%s: expected quota exceeded error, got %v", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+
+	}
+
+	// retagging an image the stream already references is always admitted
+	client, _ := testclient.NewImageTrackerFake(stream)
+	r := &repository{
+		registryClient: client,
+		kubeClient:     ktestclient.NewSimpleFake(imageLimit(2)),
+		namespace:      "ns",
+		name:           "app",
+	}
+	if err := r.admitImagePush(context.Background(), testDigest1, 0); err != nil {
+		t.Errorf("unexpected error for an image already in the stream: %v", err)
+	}
+
+	r.kubeClient = ktestclient.NewSimpleFake(imageLimit(0))
+	r.disableQuota = true
+	if err := r.admitImagePush(context.Background(), testDigest3, 0); err != nil {
+		t.Errorf("unexpected error with quota enforcement disabled: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/golang-lru"
+	kutil "k8s.io/kubernetes/pkg/util"
+)
+
+const (
+	// rateLimiterCacheSize is the number of users and projects whose rate is
+	// tracked, the least recently seen are forgotten.
+	rateLimiterCacheSize = 4096
+	// rateLimitRetryAfter is the number of seconds clients are asked to wait
+	// before retrying a rejected request.
+	rateLimitRetryAfter = 1
+)
+
+// RateLimits are the rates of requests per second allowed for a user and for
+// a project, and the number of blob uploads a user or project can run at the
+// same time. Zero means unlimited.
+type RateLimits struct {
+	UserQPS    float64
+	ProjectQPS float64
+	// Burst is the number of requests allowed above the rate, it defaults to
+	// the rate.
+	Burst             int
+	ConcurrentUploads int
+}
+
+// Enabled returns true if any limit is set.
+func (l RateLimits) Enabled() bool {
+	return l.UserQPS > 0 || l.ProjectQPS > 0 || l.ConcurrentUploads > 0
+}
+
+// RateLimitsFromEnv returns the limits set by REGISTRY_RATELIMIT_USER_QPS,
+// REGISTRY_RATELIMIT_PROJECT_QPS, REGISTRY_RATELIMIT_BURST and
+// REGISTRY_RATELIMIT_CONCURRENT_UPLOADS.
+func RateLimitsFromEnv() (RateLimits, error) {
+	limits := RateLimits{}
+	for env, limit := range map[string]*float64{
+		"REGISTRY_RATELIMIT_USER_QPS":    &limits.UserQPS,
+		"REGISTRY_RATELIMIT_PROJECT_QPS": &limits.ProjectQPS,
+	} {
+		value := os.Getenv(env)
+		if len(value) == 0 {
+			continue
+		}
+		qps, err := strconv.ParseFloat(value, 64)
+		if err != nil || qps < 0 {
+			return RateLimits{}, fmt.Errorf("invalid %s %q", env, value)
+		}
+		*limit = qps
+	}
+	for env, limit := range map[string]*int{
+		"REGISTRY_RATELIMIT_BURST":              &limits.Burst,
+		"REGISTRY_RATELIMIT_CONCURRENT_UPLOADS": &limits.ConcurrentUploads,
+	} {
+		value := os.Getenv(env)
+		if len(value) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return RateLimits{}, fmt.Errorf("invalid %s %q", env, value)
+		}
+		*limit = n
+	}
+	return limits, nil
+}
+
+// rateLimiter tracks the rates and the uploads in progress of the users and
+// projects.
+type rateLimiter struct {
+	limits RateLimits
+	// buckets holds a kutil.RateLimiter per user and per project
+	buckets *lru.Cache
+
+	lock    sync.Mutex
+	uploads map[string]int
+}
+
+// WithRateLimit rejects the requests of users and projects exceeding limits
+// with 429 Too Many Requests. Users are told apart by their name once their
+// token was verified, so that all the tokens of a user share its limits, and
+// by their address otherwise. Projects are told apart by the repository named
+// by the request.
+func WithRateLimit(handler http.Handler, limits RateLimits) http.Handler {
+	limiter := newRateLimiter(limits)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := requestClient(r)
+		project := ""
+		if namespace := requestNamespace(r); len(namespace) > 0 {
+			project = "project/" + namespace
+		}
+
+		if !limiter.accept(user, limits.UserQPS) {
+			writeTooManyRequests(w, "user", "too many requests")
+			return
+		}
+		if len(project) > 0 && !limiter.accept(project, limits.ProjectQPS) {
+			writeTooManyRequests(w, "project", "too many requests to the project")
+			return
+		}
+
+		if limits.ConcurrentUploads > 0 && isBlobUpload(r) {
+			keys := []string{user}
+			if len(project) > 0 {
+				keys = append(keys, project)
+			}
+			if !limiter.startUpload(keys...) {
+				writeTooManyRequests(w, "uploads", "too many uploads in progress")
+				return
+			}
+			defer limiter.finishUpload(keys...)
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	// only fails for a size that isn't positive
+	buckets, _ := lru.New(rateLimiterCacheSize)
+	return &rateLimiter{limits: limits, buckets: buckets, uploads: map[string]int{}}
+}
+
+// accept returns true if the request of key doesn't exceed qps.
+func (l *rateLimiter) accept(key string, qps float64) bool {
+	if qps <= 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	value, ok := l.buckets.Get(key)
+	if !ok {
+		burst := l.limits.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(qps))
+		}
+		value = kutil.NewTokenBucketRateLimiter(float32(qps), burst)
+		l.buckets.Add(key, value)
+	}
+	l.lock.Unlock()
+
+	return value.(kutil.RateLimiter).CanAccept()
+}
+
+// startUpload counts an upload for every key, unless one of them has reached
+// the limit of concurrent uploads.
+func (l *rateLimiter) startUpload(keys ...string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, key := range keys {
+		if l.uploads[key] >= l.limits.ConcurrentUploads {
+			return false
+		}
+	}
+	for _, key := range keys {
+		l.uploads[key]++
+	}
+	return true
+}
+
+func (l *rateLimiter) finishUpload(keys ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, key := range keys {
+		if l.uploads[key]--; l.uploads[key] <= 0 {
+			delete(l.uploads, key)
+		}
+	}
+}
+
+// requestClient identifies the client of r by the name of its user, if the
+// access controller verified its token, or else by its address.
+func requestClient(r *http.Request) string {
+	if name, ok := authenticatedUsers.requestUser(r); ok {
+		return "user/" + name
+	}
+	return "address/" + requestClientIP(r)
+}
+
+// requestNamespace returns the namespace of the repository named by a request
+// to the Docker API, or an empty string.
+func requestNamespace(r *http.Request) string {
+	if !strings.HasPrefix(r.URL.Path, "/v2/") {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v2/"), "/", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return ""
+	}
+	return parts[0]
+}
+
+// isBlobUpload returns true for the requests transferring the content of a
+// blob upload.
+func isBlobUpload(r *http.Request) bool {
+	return (r.Method == "PATCH" || r.Method == "PUT") && blobUploadPath.MatchString(r.URL.Path)
+}
+
+func writeTooManyRequests(w http.ResponseWriter, limit, message string) {
+	rateLimited.WithLabelValues(limit).Inc()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(rateLimitRetryAfter))
+	w.WriteHeader(429)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{
+			{
+				"code":    "TOOMANYREQUESTS",
+				"message": message,
+			},
+		},
+	})
+}
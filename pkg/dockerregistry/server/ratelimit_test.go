@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func rateLimitRequest(method, path, token string) *http.Request {
+	req, _ := http.NewRequest(method, path, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("user:"+token)))
+	}
+	return req
+}
+
+// rememberRateLimitUsers has the tokens of the tests owned by the users of the
+// same name, as if the access controller had verified them, and returns the
+// function restoring the users remembered before.
+func rememberRateLimitUsers() func() {
+	old := authenticatedUsers
+	authenticatedUsers = newUserCache(userCacheTTL, 10)
+	for _, token := range []string{"alice", "bob"} {
+		authenticatedUsers.remember(token, token)
+	}
+	// another token of alice
+	authenticatedUsers.remember("alice2", "alice")
+	return func() { authenticatedUsers = old }
+}
+
+func TestWithRateLimit(t *testing.T) {
+	defer rememberRateLimitUsers()()
+	handler := WithRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), RateLimits{UserQPS: 0.001, ProjectQPS: 0.001, Burst: 2})
+
+	tests := []struct {
+		token, path string
+		code        int
+	}{
+		{token: "alice", path: "/v2/ns/app/manifests/latest", code: http.StatusOK},
+		{token: "alice", path: "/v2/ns/app/manifests/latest", code: http.StatusOK},
+		// the user exceeded its burst, whichever token it uses
+		{token: "alice", path: "/v2/other/app/manifests/latest", code: 429},
+		{token: "alice2", path: "/v2/other/app/manifests/latest", code: 429},
+		// the project exceeded its burst
+		{token: "bob", path: "/v2/ns/app/manifests/latest", code: 429},
+		{token: "bob", path: "/v2/other/app/manifests/latest", code: http.StatusOK},
+		// anonymous clients and unverified tokens are told apart by address
+		{path: "/v2/", code: http.StatusOK},
+		{token: "unverified", path: "/v2/", code: http.StatusOK},
+		{path: "/v2/", code: 429},
+	}
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, rateLimitRequest("GET", test.path, test.token))
+		if w.Code != test.code {
+			t.Errorf("%d: expected %d, got %d", i, test.code, w.Code)
+		}
+		if w.Code == 429 && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("%d: unexpected Retry-After %q", i, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestWithRateLimitConcurrentUploads(t *testing.T) {
+	defer rememberRateLimitUsers()()
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := WithRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			started <- struct{}{}
+			<-release
+		}
+	}), RateLimits{ConcurrentUploads: 1})
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, rateLimitRequest("PATCH", "/v2/ns/app/blobs/uploads/1234", "alice"))
+		done <- w.Code
+	}()
+	<-started
+
+	tests := []struct {
+		method, path, token string
+		code                int
+	}{
+		{method: "PATCH", path: "/v2/ns/other/blobs/uploads/5678", token: "alice", code: 429},
+		{method: "PUT", path: "/v2/ns/other/blobs/uploads/5678", token: "bob", code: 429},
+		{method: "GET", path: "/v2/ns/app/blobs/" + testLayerDigest, token: "alice", code: http.StatusOK},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, rateLimitRequest(test.method, test.path, test.token))
+		if w.Code != test.code {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.code, w.Code)
+		}
+	}
+
+	release <- struct{}{}
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("unexpected response to the first upload: %d", code)
+	}
+
+	go func() { <-started; release <- struct{}{} }()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, rateLimitRequest("PATCH", "/v2/ns/other/blobs/uploads/5678", "bob"))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected an upload once the first finished, got %d", w.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readOnlyRetryAfter is how long clients are asked to wait before retrying a
+// write rejected in read-only mode.
+const readOnlyRetryAfter = 5 * time.Minute
+
+// ReadOnlyFromEnv returns true if REGISTRY_READONLY puts the registry in
+// read-only mode.
+func ReadOnlyFromEnv() bool {
+	readOnly, _ := strconv.ParseBool(os.Getenv("REGISTRY_READONLY"))
+	return readOnly
+}
+
+// WithReadOnly rejects manifest puts, blob uploads and deletes made through
+// the Docker API with 503 Service Unavailable, while pulls are still served.
+// The admin endpoints stay available, so that images can be pruned while
+// clients can't push.
+func WithReadOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWriteRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		writeUnavailable(w, readOnlyRetryAfter, "the registry is in read-only mode for maintenance")
+	})
+}
+
+// writeUnavailable answers with 503 Service Unavailable and the given message,
+// asking the client to retry after retryAfter.
+func writeUnavailable(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{
+			{
+				"code":    "UNAVAILABLE",
+				"message": message,
+			},
+		},
+	})
+}
+
+func isWriteRequest(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/v2/") {
+		return false
+	}
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithReadOnly(t *testing.T) {
+	served := false
+	handler := WithReadOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+
+	tests := []struct {
+		method   string
+		path     string
+		rejected bool
+	}{
+		{method: "GET", path: "/v2/ns/app/manifests/latest"},
+		{method: "HEAD", path: "/v2/ns/app/blobs/" + testLayerDigest},
+		{method: "GET", path: "/healthz"},
+		{method: "PUT", path: "/v2/ns/app/manifests/latest", rejected: true},
+		{method: "POST", path: "/v2/ns/app/blobs/uploads/", rejected: true},
+		{method: "PATCH", path: "/v2/ns/app/blobs/uploads/1234", rejected: true},
+		{method: "DELETE", path: "/v2/ns/app/manifests/" + testDigest1, rejected: true},
+		// pruning is allowed in read-only mode
+		{method: "DELETE", path: "/admin/blobs/" + testLayerDigest},
+	}
+	for _, test := range tests {
+		served = false
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if served == test.rejected {
+			t.Errorf("%s %s: expected rejected=%v, got served=%v", test.method, test.path, test.rejected, served)
+		}
+		if !test.rejected {
+			continue
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: expected 503, got %d", test.method, test.path, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "300" {
+			t.Errorf("%s %s: unexpected Retry-After %q", test.method, test.path, w.Header().Get("Retry-After"))
+		}
+	}
+}
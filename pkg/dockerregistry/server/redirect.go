@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"golang.org/x/net/context"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func init() {
+	storagemiddleware.Register("openshift", storagemiddleware.InitFunc(newRedirectDriver))
+}
+
+// redirectDriver controls how the clients are redirected to the storage
+// backend to download the layers, when the backend supports it, e.g. s3:
+// redirects can be disabled, so that the registry serves the layers itself,
+// and the expiry of the signed URLs can be set.
+type redirectDriver struct {
+	storagedriver.StorageDriver
+
+	// disable serves all the layers from the registry, as set by the
+	// "redirect" option.
+	disable bool
+	// expiry is how long the URLs the clients are redirected to are valid,
+	// zero leaves the default of the storage driver.
+	expiry time.Duration
+}
+
+// newRedirectDriver returns a new storage middleware.
+func newRedirectDriver(driver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	redirect, err := boolOption(options, "redirect", true)
+	if err != nil {
+		return nil, err
+	}
+	var expiry time.Duration
+	if value, ok := options["redirectexpiry"]; ok {
+		if expiry, err = time.ParseDuration(fmt.Sprintf("%v", value)); err != nil || expiry <= 0 {
+			return nil, fmt.Errorf("invalid redirectexpiry %q", value)
+		}
+	}
+	return &redirectDriver{StorageDriver: driver, disable: !redirect, expiry: expiry}, nil
+}
+
+// URLFor returns storagedriver.ErrUnsupportedMethod when redirects are
+// disabled, so that the layers are served by the registry.
+func (d *redirectDriver) URLFor(path string, options map[string]interface{}) (string, error) {
+	if d.disable {
+		return "", storagedriver.ErrUnsupportedMethod
+	}
+	if d.expiry > 0 {
+		withExpiry := map[string]interface{}{"expiry": time.Now().Add(d.expiry)}
+		for k, v := range options {
+			withExpiry[k] = v
+		}
+		options = withExpiry
+	}
+	return d.StorageDriver.URLFor(path, options)
+}
+
+// shouldRedirect returns false if the annotation of the image stream of r
+// disables the redirects to the storage backend, for clients that can't reach
+// it.
+func (r *repository) shouldRedirect(ctx context.Context) bool {
+	if imageStream, err := r.getImageStream(ctx); err == nil {
+		if redirect, err := strconv.ParseBool(imageStream.Annotations[imageapi.BlobRedirectAnnotation]); err == nil {
+			return redirect
+		}
+	}
+	return true
+}
+
+// localLayer is a layer stored by the registry, that the clients are only
+// redirected to the storage backend for if the image stream of repo allows it.
+type localLayer struct {
+	distribution.Layer
+
+	repo *repository
+	// source is the repository of another image stream the layer is served
+	// from, the user must be allowed to pull from it.
+	source *imageapi.DockerImageReference
+}
+
+// Handler serves the content of the layer, or redirects to the storage backend
+// like the upstream layer does.
+func (l *localLayer) Handler(r *http.Request) (http.Handler, error) {
+	if l.source != nil {
+		if err := l.repo.verifyRequestSourceAccess(r, *l.source); err != nil {
+			log.Errorf("Error verifying the access to layer %s of %s/%s: %v", l.Digest(), l.source.Namespace, l.source.Name, err)
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeBlobUnknown(w, l.Digest())
+			}), nil
+		}
+	}
+	if l.repo.shouldRedirect(context.Background()) {
+		return l.Layer.Handler(r)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", l.Digest().String())
+		http.ServeContent(w, r, l.Digest().String(), l.CreatedAt(), l)
+	}), nil
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// urlForDriver records the options of URLFor.
+type urlForDriver struct {
+	storagedriver.StorageDriver
+	options map[string]interface{}
+}
+
+func (d *urlForDriver) URLFor(path string, options map[string]interface{}) (string, error) {
+	d.options = options
+	return "https://storage.example.com" + path, nil
+}
+
+func TestRedirectDriver(t *testing.T) {
+	backend := &urlForDriver{StorageDriver: inmemory.New()}
+
+	driver, err := newRedirectDriver(backend, map[string]interface{}{"redirectexpiry": "5m"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := driver.URLFor("/blob", map[string]interface{}{"method": "GET"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expiry, ok := backend.options["expiry"].(time.Time)
+	if !ok || expiry.Sub(time.Now()) > 5*time.Minute || expiry.Sub(time.Now()) < 4*time.Minute {
+		t.Errorf("expected the URL to expire in 5 minutes, got %v", backend.options["expiry"])
+	}
+	if backend.options["method"] != "GET" {
+		t.Errorf("expected the method to be passed, got %v", backend.options)
+	}
+
+	driver, err = newRedirectDriver(backend, map[string]interface{}{"redirect": false})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := driver.URLFor("/blob", nil); err != storagedriver.ErrUnsupportedMethod {
+		t.Errorf("expected the redirects to be disabled, got %v", err)
+	}
+
+	for _, options := range []map[string]interface{}{
+		{"redirect": "sometimes"},
+		{"redirectexpiry": "soon"},
+		{"redirectexpiry": "-1m"},
+	} {
+		if _, err := newRedirectDriver(backend, options); err == nil {
+			t.Errorf("%v: expected an error", options)
+		}
+	}
+}
+
+func TestShouldRedirect(t *testing.T) {
+	for annotation, expected := range map[string]bool{
+		"":      true,
+		"true":  true,
+		"false": false,
+	} {
+		stream := &imageapi.ImageStream{
+			ObjectMeta: kapi.ObjectMeta{
+				Namespace:   "ns",
+				Name:        "app",
+				Annotations: map[string]string{imageapi.BlobRedirectAnnotation: annotation},
+			},
+		}
+		client, _ := testclient.NewImageTrackerFake(stream)
+		r := &repository{registryClient: client, namespace: "ns", name: "app"}
+		if redirect := r.shouldRedirect(context.Background()); redirect != expected {
+			t.Errorf("%q: expected redirect %t, got %t", annotation, expected, redirect)
+		}
+	}
+}
+
+// fakeLayer is a layer stored locally.
+type fakeLayer struct {
+	distribution.Layer
+	*bytes.Reader
+}
+
+func (l *fakeLayer) Digest() digest.Digest      { return testLayerDigest }
+func (l *fakeLayer) CreatedAt() time.Time       { return time.Now() }
+func (l *fakeLayer) Read(p []byte) (int, error) { return l.Reader.Read(p) }
+func (l *fakeLayer) Seek(offset int64, whence int) (int64, error) {
+	return l.Reader.Seek(offset, whence)
+}
+
+func TestLocalLayer(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "app",
+			Annotations: map[string]string{imageapi.BlobRedirectAnnotation: "false"},
+		},
+	}
+	client, _ := testclient.NewImageTrackerFake(stream)
+	r := &repository{registryClient: client, namespace: "ns", name: "app"}
+
+	layer := &localLayer{Layer: &fakeLayer{Reader: bytes.NewReader([]byte("content"))}, repo: r}
+	req, _ := http.NewRequest("GET", "/v2/ns/app/blobs/"+testLayerDigest, nil)
+	handler, err := layer.Handler(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "content" || w.Header().Get("Docker-Content-Digest") != testLayerDigest {
+		t.Errorf("expected the layer to be served, got %d %v: %q", w.Code, w.Header(), w.Body.String())
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/configuration"
+	"github.com/garyburd/redigo/redis"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+const (
+	// redisLayerCachePrefix prefixes the keys of the layer cache in redis.
+	redisLayerCachePrefix = "openshift.io/registry/layers/"
+	// redisLayerCacheGeneration is the key of the generation of the layer
+	// cache. forgetAll increments it, the keys of the previous generations
+	// are left to expire.
+	redisLayerCacheGeneration = redisLayerCachePrefix + "generation"
+)
+
+// redisLayerCache is an imageStreamLayerCache kept in redis, so that the
+// replicas of the registry share it. Errors talking to redis are logged and
+// treated as cache misses, the layers are then read from the master.
+type redisLayerCache struct {
+	pool *redis.Pool
+}
+
+// UseRedisLayerCache keeps the layers of the image streams in the redis
+// instance of pool instead of in memory.
+func UseRedisLayerCache(pool *redis.Pool) {
+	layerCache = &redisLayerCache{pool: pool}
+}
+
+// key returns the redis key of the image stream identified by key in the
+// current generation of the cache.
+func (c *redisLayerCache) key(conn redis.Conn, key string) (string, error) {
+	generation, err := redis.Int64(conn.Do("GET", redisLayerCacheGeneration))
+	if err != nil && err != redis.ErrNil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d/%s", redisLayerCachePrefix, generation, key), nil
+}
+
+// get doesn't need now, redis expires the keys itself.
+func (c *redisLayerCache) get(key string, now time.Time) (sets.String, bool) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	redisKey, err := c.key(conn, key)
+	if err != nil {
+		log.Warnf("Error reading the layer cache from redis: %v", err)
+		return nil, false
+	}
+	value, err := redis.Bytes(conn.Do("GET", redisKey))
+	if err == redis.ErrNil {
+		return nil, false
+	}
+	if err != nil {
+		log.Warnf("Error reading the layers of %s from redis: %v", key, err)
+		return nil, false
+	}
+
+	var layers []string
+	if err := json.Unmarshal(value, &layers); err != nil {
+		log.Warnf("Error decoding the layers of %s from redis: %v", key, err)
+		return nil, false
+	}
+	return sets.NewString(layers...), true
+}
+
+func (c *redisLayerCache) set(key string, layers sets.String, expires time.Time) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	redisKey, err := c.key(conn, key)
+	if err != nil {
+		log.Warnf("Error reading the layer cache from redis: %v", err)
+		return
+	}
+	ttl := expires.Sub(time.Now()) / time.Millisecond
+	if ttl <= 0 {
+		if _, err := conn.Do("DEL", redisKey); err != nil {
+			log.Warnf("Error deleting the layers of %s from redis: %v", key, err)
+		}
+		return
+	}
+	value, err := json.Marshal(layers.List())
+	if err != nil {
+		return
+	}
+	if _, err := conn.Do("SET", redisKey, value, "PX", int64(ttl)); err != nil {
+		log.Warnf("Error writing the layers of %s to redis: %v", key, err)
+	}
+}
+
+func (c *redisLayerCache) forget(key string) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	redisKey, err := c.key(conn, key)
+	if err != nil {
+		log.Warnf("Error reading the layer cache from redis: %v", err)
+		return
+	}
+	if _, err := conn.Do("DEL", redisKey); err != nil {
+		log.Warnf("Error deleting the layers of %s from redis: %v", key, err)
+	}
+}
+
+func (c *redisLayerCache) forgetAll() {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("INCR", redisLayerCacheGeneration); err != nil {
+		log.Warnf("Error invalidating the layer cache in redis: %v", err)
+	}
+}
+
+// NewRedisPool returns a pool of connections to the redis instance of the
+// redis section of config, as the upstream app creates for its layer info
+// cache.
+func NewRedisPool(config *configuration.Configuration) *redis.Pool {
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			conn, err := redis.DialTimeout("tcp", config.Redis.Addr, config.Redis.DialTimeout, config.Redis.ReadTimeout, config.Redis.WriteTimeout)
+			if err != nil {
+				log.Errorf("Error connecting to redis instance %s: %v", config.Redis.Addr, err)
+				return nil, err
+			}
+			if len(config.Redis.Password) > 0 {
+				if _, err := conn.Do("AUTH", config.Redis.Password); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			if config.Redis.DB != 0 {
+				if _, err := conn.Do("SELECT", config.Redis.DB); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			return conn, nil
+		},
+		MaxIdle:     config.Redis.Pool.MaxIdle,
+		MaxActive:   config.Redis.Pool.MaxActive,
+		IdleTimeout: config.Redis.Pool.IdleTimeout,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+		// if no connection is available, proceed without the cache
+		Wait: false,
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// fakeRedisConn serves GET, SET, DEL and INCR from a map, ignoring expiry.
+type fakeRedisConn struct {
+	values map[string][]byte
+}
+
+func (c *fakeRedisConn) Close() error { return nil }
+func (c *fakeRedisConn) Err() error   { return nil }
+
+func (c *fakeRedisConn) Do(command string, args ...interface{}) (interface{}, error) {
+	switch command {
+	case "":
+		return nil, nil
+	case "GET":
+		value, ok := c.values[args[0].(string)]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "SET":
+		c.values[args[0].(string)] = args[1].([]byte)
+		return "OK", nil
+	case "DEL":
+		delete(c.values, args[0].(string))
+		return int64(1), nil
+	case "INCR":
+		n, _ := strconv.ParseInt(string(c.values[args[0].(string)]), 10, 64)
+		n++
+		c.values[args[0].(string)] = []byte(strconv.FormatInt(n, 10))
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported command %s", command)
+}
+
+func (c *fakeRedisConn) Send(string, ...interface{}) error { return nil }
+func (c *fakeRedisConn) Flush() error                      { return nil }
+func (c *fakeRedisConn) Receive() (interface{}, error)     { return nil, nil }
+
+func TestRedisLayerCache(t *testing.T) {
+	conn := &fakeRedisConn{values: map[string][]byte{}}
+	cache := &redisLayerCache{pool: &redis.Pool{Dial: func() (redis.Conn, error) { return conn, nil }}}
+	now := time.Now()
+
+	if _, ok := cache.get("ns/is", now); ok {
+		t.Fatalf("expected a miss for an unknown image stream")
+	}
+	cache.set("ns/is", sets.NewString(testLayerDigest), now.Add(time.Minute))
+	if layers, ok := cache.get("ns/is", now); !ok || !layers.Has(testLayerDigest) {
+		t.Errorf("expected the cached layers, got %v, %t", layers, ok)
+	}
+	// an image stream without layers is cached as well
+	cache.set("ns/empty", sets.NewString(), now.Add(time.Minute))
+	if layers, ok := cache.get("ns/empty", now); !ok || layers.Len() != 0 {
+		t.Errorf("expected no cached layers, got %v, %t", layers, ok)
+	}
+
+	cache.forget("ns/is")
+	if _, ok := cache.get("ns/is", now); ok {
+		t.Errorf("expected a miss for a forgotten image stream")
+	}
+
+	cache.set("ns/is", sets.NewString(testLayerDigest), now.Add(time.Minute))
+	cache.forgetAll()
+	if _, ok := cache.get("ns/is", now); ok {
+		t.Errorf("expected a miss after forgetting all image streams")
+	}
+
+	// setting an expired entry drops it
+	cache.set("ns/is", sets.NewString(testLayerDigest), now.Add(time.Minute))
+	cache.set("ns/is", sets.NewString(testLayerDigest), now.Add(-time.Second))
+	if _, ok := cache.get("ns/is", now); ok {
+		t.Errorf("expected a miss for an expired entry")
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	routeapi "github.com/openshift/origin/pkg/route/api"
+)
+
+const defaultRegistryServiceName = "docker-registry"
+
+// RegistryURLMismatchError is returned by ValidateRegistryURL when REGISTRY_URL
+// doesn't point at the registry service or any of its routes.
+type RegistryURLMismatchError struct {
+	RegistryURL string
+	Candidates  []string
+}
+
+func (e *RegistryURLMismatchError) Error() string {
+	return fmt.Sprintf("REGISTRY_URL %q does not match the registry service or any of its routes (expected one of: %s); images pushed to this registry will reference a location they can't be pulled from",
+		e.RegistryURL, strings.Join(e.Candidates, ", "))
+}
+
+// IsRegistryURLMismatch returns true if err is a RegistryURLMismatchError.
+func IsRegistryURLMismatch(err error) bool {
+	_, ok := err.(*RegistryURLMismatchError)
+	return ok
+}
+
+// ValidateRegistryURL verifies that REGISTRY_URL addresses the registry
+// through its service (cluster IP or service DNS name) or one of the routes
+// exposing the service. The service is looked up by REGISTRY_SERVICE_NAME
+// (defaults to docker-registry) in REGISTRY_SERVICE_NAMESPACE (defaults to
+// default). The registry records REGISTRY_URL in the DockerImageReference of
+// every pushed image, so a wrong value results in images that can't be pulled
+// back.
+func ValidateRegistryURL() error {
+	registryAddr := os.Getenv("REGISTRY_URL")
+	if len(registryAddr) == 0 {
+		return errors.New("REGISTRY_URL is required")
+	}
+	namespace := os.Getenv("REGISTRY_SERVICE_NAMESPACE")
+	if len(namespace) == 0 {
+		namespace = kapi.NamespaceDefault
+	}
+	name := os.Getenv("REGISTRY_SERVICE_NAME")
+	if len(name) == 0 {
+		name = defaultRegistryServiceName
+	}
+
+	kubeClient, err := NewRegistryKubeClient()
+	if err != nil {
+		return err
+	}
+	osClient, err := NewRegistryOpenShiftClient()
+	if err != nil {
+		return err
+	}
+
+	service, err := kubeClient.Services(namespace).Get(name)
+	if err != nil {
+		return fmt.Errorf("error getting service %s/%s: %v", namespace, name, err)
+	}
+	routes, err := osClient.Routes(namespace).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing routes in namespace %s: %v", namespace, err)
+	}
+
+	return validateRegistryAddr(registryAddr, service, routes.Items)
+}
+
+// validateRegistryAddr checks registryAddr against the addresses of service
+// and of the routes pointing to it.
+func validateRegistryAddr(registryAddr string, service *kapi.Service, routes []routeapi.Route) error {
+	host, port, err := net.SplitHostPort(registryAddr)
+	if err != nil {
+		host, port = registryAddr, ""
+	}
+
+	candidates := []string{}
+
+	serviceHosts := []string{fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)}
+	if len(service.Spec.ClusterIP) > 0 && service.Spec.ClusterIP != kapi.ClusterIPNone {
+		serviceHosts = append([]string{service.Spec.ClusterIP}, serviceHosts...)
+	}
+	for _, servicePort := range service.Spec.Ports {
+		for _, serviceHost := range serviceHosts {
+			candidates = append(candidates, net.JoinHostPort(serviceHost, strconv.Itoa(servicePort.Port)))
+			// the service DNS name may carry the cluster domain suffix
+			hostMatches := host == serviceHost || strings.HasPrefix(host, serviceHost+".")
+			if hostMatches && portMatches(port, servicePort.Port) {
+				return nil
+			}
+		}
+	}
+
+	for _, route := range routes {
+		if route.Spec.To.Name != service.Name || len(route.Spec.Host) == 0 {
+			continue
+		}
+		candidates = append(candidates, route.Spec.Host)
+		if host == route.Spec.Host && (portMatches(port, 80) || portMatches(port, 443)) {
+			return nil
+		}
+	}
+
+	return &RegistryURLMismatchError{RegistryURL: registryAddr, Candidates: candidates}
+}
+
+// portMatches returns true if port is expected, or is missing and expected is
+// one of the ports implied by the scheme.
+func portMatches(port string, expected int) bool {
+	if len(port) == 0 {
+		return expected == 80 || expected == 443
+	}
+	return port == strconv.Itoa(expected)
+}
+
+// requestRegistryAddr returns the address the client reached the registry at
+// with the request in ctx, so that pushed images reference the registry the
+// way the client does when it's reachable under several names. The first
+// X-Forwarded-Host is preferred over the Host header. The headers are set by
+// the client, so the address is only used if it's registryAddr, usually
+// REGISTRY_URL, or one of its aliases: registryAddr is returned otherwise.
+func requestRegistryAddr(ctx context.Context, registryAddr string, aliases sets.String) string {
+	req, err := ctxu.GetRequest(ctx)
+	if err != nil {
+		return registryAddr
+	}
+	addr := strings.TrimSpace(strings.Split(req.Header.Get("X-Forwarded-Host"), ",")[0])
+	if len(addr) == 0 {
+		addr = req.Host
+	}
+	if aliases.Has(normalizeRegistryAddr(addr)) {
+		return addr
+	}
+	return registryAddr
+}
+
+// registryAliasesFromEnv returns the addresses listed in REGISTRY_URL_ALIASES,
+// separated by commas, under which the registry is reachable besides
+// REGISTRY_URL, e.g. its service IP, DNS name and routes. The addresses are
+// normalized with normalizeRegistryAddr.
+func registryAliasesFromEnv() sets.String {
+	aliases := sets.NewString()
+	for _, alias := range strings.Split(os.Getenv("REGISTRY_URL_ALIASES"), ",") {
+		if alias = normalizeRegistryAddr(alias); len(alias) > 0 {
+			aliases.Insert(alias)
+		}
+	}
+	return aliases
+}
+
+// isLocalImage returns true if the content of image is stored in this
+// registry. Images pushed to it are recognized by their annotation, whatever
+// address they were pushed to, others by referencing registryAddr or one of
+// its aliases.
+func isLocalImage(image *imageapi.Image, registryAddr string, aliases sets.String) bool {
+	if image.Annotations[imageapi.ManagedByOpenShiftAnnotation] == "true" {
+		return true
+	}
+	ref, err := imageapi.ParseDockerImageReference(image.DockerImageReference)
+	if err != nil {
+		return false
+	}
+	registry := normalizeRegistryAddr(ref.Registry)
+	return registry == normalizeRegistryAddr(registryAddr) || aliases.Has(registry)
+}
+
+// normalizeRegistryAddr returns addr in the form registry addresses are
+// compared in: without a scheme or a trailing slash, lower case, and without
+// the port 443 docker implies for a host.
+func normalizeRegistryAddr(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if i := strings.Index(addr, "://"); i >= 0 {
+		addr = addr[i+len("://"):]
+	}
+	addr = strings.ToLower(strings.TrimRight(addr, "/"))
+	if host, port, err := net.SplitHostPort(addr); err == nil && port == "443" {
+		addr = host
+	}
+	return addr
+}
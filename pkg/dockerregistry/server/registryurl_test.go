@@ -0,0 +1,158 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	routeapi "github.com/openshift/origin/pkg/route/api"
+)
+
+func TestValidateRegistryAddr(t *testing.T) {
+	service := &kapi.Service{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "docker-registry"},
+		Spec: kapi.ServiceSpec{
+			ClusterIP: "172.30.0.10",
+			Ports:     []kapi.ServicePort{{Port: 5000}},
+		},
+	}
+	routes := []routeapi.Route{
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "registry"},
+			Spec: routeapi.RouteSpec{
+				Host: "registry.example.com",
+				To:   kapi.ObjectReference{Kind: "Service", Name: "docker-registry"},
+			},
+		},
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "other"},
+			Spec: routeapi.RouteSpec{
+				Host: "other.example.com",
+				To:   kapi.ObjectReference{Kind: "Service", Name: "other"},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		registryAddr string
+		mismatch     bool
+	}{
+		"cluster ip":               {registryAddr: "172.30.0.10:5000"},
+		"service dns name":         {registryAddr: "docker-registry.default.svc:5000"},
+		"service dns name domain":  {registryAddr: "docker-registry.default.svc.cluster.local:5000"},
+		"route host":               {registryAddr: "registry.example.com"},
+		"route host with tls port": {registryAddr: "registry.example.com:443"},
+		"wrong port":               {registryAddr: "172.30.0.10:5001", mismatch: true},
+		"missing port":             {registryAddr: "172.30.0.10", mismatch: true},
+		"unknown host":             {registryAddr: "10.0.0.1:5000", mismatch: true},
+		"route of another service": {registryAddr: "other.example.com", mismatch: true},
+	}
+
+	for name, test := range tests {
+		err := validateRegistryAddr(test.registryAddr, service, routes)
+		if test.mismatch {
+			if !IsRegistryURLMismatch(err) {
+				t.Errorf("%s: expected mismatch error, got %v", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestRequestRegistryAddr(t *testing.T) {
+	aliases := sets.NewString("registry.example.com", "proxy.example.com")
+	if addr := requestRegistryAddr(context.Background(), "fallback:5000", aliases); addr != "fallback:5000" {
+		t.Errorf("expected fallback without a request, got %q", addr)
+	}
+
+	req, _ := http.NewRequest("PUT", "http://registry.example.com/v2/ns/app/manifests/latest", nil)
+	if addr := requestRegistryAddr(ctxu.WithRequest(context.Background(), req), "fallback:5000", aliases); addr != "registry.example.com" {
+		t.Errorf("expected the Host header, got %q", addr)
+	}
+
+	req.Header.Set("X-Forwarded-Host", "proxy.example.com:443, registry.example.com")
+	if addr := requestRegistryAddr(ctxu.WithRequest(context.Background(), req), "fallback:5000", aliases); addr != "proxy.example.com:443" {
+		t.Errorf("expected the first forwarded host, got %q", addr)
+	}
+
+	// an address the registry isn't configured with is ignored
+	req.Header.Set("X-Forwarded-Host", "attacker.example.com")
+	if addr := requestRegistryAddr(ctxu.WithRequest(context.Background(), req), "fallback:5000", aliases); addr != "fallback:5000" {
+		t.Errorf("expected the fallback for an unknown forwarded host, got %q", addr)
+	}
+	req.Header.Del("X-Forwarded-Host")
+	req.Host = "attacker.example.com"
+	if addr := requestRegistryAddr(ctxu.WithRequest(context.Background(), req), "fallback:5000", aliases); addr != "fallback:5000" {
+		t.Errorf("expected the fallback for an unknown host, got %q", addr)
+	}
+}
+
+func TestIsLocalImage(t *testing.T) {
+	managed := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"}},
+		DockerImageReference: "registry.example.com/ns/app@" + testDigest1,
+	}
+	if !isLocalImage(managed, "172.30.0.10:5000", nil) {
+		t.Errorf("expected an image pushed under another address to be local")
+	}
+	imported := &imageapi.Image{DockerImageReference: "docker.io/library/busybox@" + testDigest1}
+	if isLocalImage(imported, "172.30.0.10:5000", nil) {
+		t.Errorf("expected an imported image not to be local")
+	}
+	if !isLocalImage(&imageapi.Image{DockerImageReference: "172.30.0.10:5000/ns/app@" + testDigest1}, "172.30.0.10:5000", nil) {
+		t.Errorf("expected an image referencing the registry to be local")
+	}
+	aliased := &imageapi.Image{DockerImageReference: "registry.example.com/ns/app@" + testDigest1}
+	if isLocalImage(aliased, "172.30.0.10:5000", nil) {
+		t.Errorf("expected an image referencing an unknown address not to be local")
+	}
+	if !isLocalImage(aliased, "172.30.0.10:5000", sets.NewString("docker-registry.default.svc:5000", "registry.example.com")) {
+		t.Errorf("expected an image referencing an alias to be local")
+	}
+	// the addresses are compared normalized
+	os.Setenv("REGISTRY_URL_ALIASES", "https://Registry.Example.com:443/")
+	defer os.Setenv("REGISTRY_URL_ALIASES", "")
+	if !isLocalImage(aliased, "172.30.0.10:5000", registryAliasesFromEnv()) {
+		t.Errorf("expected an image referencing a normalized alias to be local")
+	}
+	if !isLocalImage(&imageapi.Image{DockerImageReference: "registry.example.com:443/ns/app@" + testDigest1}, "Registry.Example.com", nil) {
+		t.Errorf("expected an image referencing the registry with the implied port to be local")
+	}
+}
+
+func TestNormalizeRegistryAddr(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"172.30.0.10:5000":                  "172.30.0.10:5000",
+		" Registry.Example.com ":            "registry.example.com",
+		"registry.example.com:443":          "registry.example.com",
+		"https://registry.example.com/":     "registry.example.com",
+		"http://registry.example.com:80":    "registry.example.com:80",
+		"docker-registry.default.svc:5000/": "docker-registry.default.svc:5000",
+	} {
+		if normalized := normalizeRegistryAddr(addr); normalized != expected {
+			t.Errorf("%q: expected %q, got %q", addr, expected, normalized)
+		}
+	}
+}
+
+func TestRegistryAliasesFromEnv(t *testing.T) {
+	defer os.Setenv("REGISTRY_URL_ALIASES", os.Getenv("REGISTRY_URL_ALIASES"))
+
+	os.Setenv("REGISTRY_URL_ALIASES", " docker-registry.default.svc:5000, registry.example.com,,")
+	if aliases := registryAliasesFromEnv(); !aliases.Equal(sets.NewString("docker-registry.default.svc:5000", "registry.example.com")) {
+		t.Errorf("unexpected aliases: %v", aliases.List())
+	}
+	os.Setenv("REGISTRY_URL_ALIASES", "")
+	if aliases := registryAliasesFromEnv(); aliases.Len() != 0 {
+		t.Errorf("expected no aliases, got %v", aliases.List())
+	}
+}
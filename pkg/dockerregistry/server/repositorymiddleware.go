@@ -6,42 +6,198 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
 	repomw "github.com/docker/distribution/registry/middleware/repository"
 	"github.com/docker/libtrust"
 	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/dockerregistry"
 	imageapi "github.com/openshift/origin/pkg/image/api"
 	"golang.org/x/net/context"
 	kapi "k8s.io/kubernetes/pkg/api"
 	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/client/record"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/util/sets"
 )
 
 func init() {
-	repomw.Register("openshift", repomw.InitFunc(newRepository))
+	repomw.Register("openshift", NewRepositoryMiddleware(DefaultRepositoryDependencies()))
+}
+
+// RepositoryDependencies are the clients, the registry address and the clock
+// the repositories of the openshift middleware use. The clients are created
+// per repository, i.e. per request.
+type RepositoryDependencies struct {
+	// RegistryAddr returns the address images pushed to the registry are
+	// referenced by.
+	RegistryAddr func() (string, error)
+	// OpenShiftClient returns the client of the master used by the
+	// registry itself.
+	OpenShiftClient func() (client.Interface, error)
+	// KubeClient returns the client reading the quota and the limit ranges
+	// of the projects, and recording events.
+	KubeClient func() (kclient.Interface, error)
+	// RegistryConnector returns the client of the remote registries images
+	// are pulled through from, authenticating with credentials.
+	RegistryConnector func(credentials dockerregistry.Credentials) dockerregistry.Client
+	// Now is the clock of the caches and of the pull stats.
+	Now func() time.Time
+}
+
+// DefaultRepositoryDependencies returns the dependencies of the registry:
+// REGISTRY_URL and the clients of the master configured by the environment.
+func DefaultRepositoryDependencies() RepositoryDependencies {
+	return RepositoryDependencies{
+		RegistryAddr: func() (string, error) {
+			registryAddr := os.Getenv("REGISTRY_URL")
+			if len(registryAddr) == 0 {
+				return "", errors.New("REGISTRY_URL is required")
+			}
+			return registryAddr, nil
+		},
+		OpenShiftClient: func() (client.Interface, error) {
+			return NewRegistryOpenShiftClient()
+		},
+		KubeClient: func() (kclient.Interface, error) {
+			return NewRegistryKubeClient()
+		},
+		RegistryConnector: dockerregistry.NewClientWithCredentials,
+		Now:               time.Now,
+	}
+}
+
+// NewRepositoryMiddleware returns the openshift repository middleware with the
+// given dependencies, e.g. for registering it under another name in a registry
+// embedding it, or for wiring fakes in tests.
+func NewRepositoryMiddleware(deps RepositoryDependencies) repomw.InitFunc {
+	return func(ctx context.Context, repo distribution.Repository, options map[string]interface{}) (distribution.Repository, error) {
+		return newRepository(ctx, repo, options, deps)
+	}
 }
 
 type repository struct {
 	distribution.Repository
 
-	registryClient *client.Client
-	registryAddr   string
-	namespace      string
-	name           string
+	// ctx is the context of the request the repository serves, for the
+	// services of distribution whose methods aren't given one.
+	ctx context.Context
+
+	registryClient client.Interface
+	// kubeClient reads the quota and limit ranges of the project.
+	kubeClient   kclient.Interface
+	registryAddr string
+	namespace    string
+	name         string
+	// registryAliases are the other addresses images stored in this registry
+	// may reference.
+	registryAliases sets.String
+	// insecureRegistries are the remote registries that may be contacted
+	// insecurely to pull content through, all if empty.
+	insecureRegistries sets.String
+	// apiTimeout bounds every call to the master API.
+	apiTimeout time.Duration
+	// layerCacheTTL is how long the layers of the image stream are
+	// remembered in layerCache, zero disables it.
+	layerCacheTTL time.Duration
+	// sizeLimits bounds the size of the layers and images pushed.
+	sizeLimits SizeLimits
+	// metadataCache remembers the objects fetched from the master, nil
+	// disables it.
+	metadataCache *metadataCache
+	// eventRecorder records pushes and deletions on the image stream.
+	eventRecorder record.EventRecorder
+	// notifier posts pushes and deletions to the notification endpoints.
+	notifier *eventNotifier
+	// registryConnector returns the client connecting to the remote
+	// registries of images tagged into image streams, for pulling their
+	// content through.
+	registryConnector func(dockerregistry.Credentials) dockerregistry.Client
+	// remoteClient is the client returned by registryConnector, given the
+	// docker registry secrets of the project, once content is pulled through.
+	remoteClient dockerregistry.Client
+	// mirrorPullthrough stores the layers pulled through locally, unless the
+	// image stream says otherwise.
+	mirrorPullthrough bool
+	// disablePullthrough serves only the manifests and layers stored
+	// locally, as set by the "pullthrough" option.
+	disablePullthrough bool
+	// disableQuota skips the quota and limit range checks of pushes, as set
+	// by the "enforcequota" option. The size limits still apply.
+	disableQuota bool
+	// manifestPolicy restricts the manifests pushed.
+	manifestPolicy ManifestPolicy
+	// now is the clock of the layer cache and of the pull stats, nil means
+	// time.Now.
+	now func() time.Time
 }
 
-// newRepository returns a new repository middleware.
-func newRepository(repo distribution.Repository, options map[string]interface{}) (distribution.Repository, error) {
-	registryAddr := os.Getenv("REGISTRY_URL")
-	if len(registryAddr) == 0 {
-		return nil, errors.New("REGISTRY_URL is required")
+// newRepository returns a new repository middleware serving the request of ctx
+// using deps.
+func newRepository(ctx context.Context, repo distribution.Repository, options map[string]interface{}, deps RepositoryDependencies) (distribution.Repository, error) {
+	mirror, err := MirrorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if mirror.Enabled() {
+		return newMirrorRepository(repo, mirror)
+	}
+
+	registryAddr, err := deps.RegistryAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	registryClient, err := deps.OpenShiftClient()
+	if err != nil {
+		return nil, err
+	}
+
+	kubeClient, err := deps.KubeClient()
+	if err != nil {
+		return nil, err
+	}
+
+	apiTimeout, err := apiTimeoutFrom(options)
+	if err != nil {
+		return nil, err
+	}
+
+	layerCacheTTL, err := layerCacheTTLFrom(options)
+	if err != nil {
+		return nil, err
 	}
 
-	registryClient, err := NewRegistryOpenShiftClient()
+	metadataCache, err := metadataCacheFrom(options)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeLimits, err := SizeLimitsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	mirrorPullthrough, err := boolOption(options, "mirrorpullthrough", false)
+	if err != nil {
+		return nil, err
+	}
+	pullthrough, err := boolOption(options, "pullthrough", true)
+	if err != nil {
+		return nil, err
+	}
+	enforceQuota, err := boolOption(options, "enforcequota", true)
+	if err != nil {
+		return nil, err
+	}
+	manifestPolicy, err := ManifestPolicyFromOptions(options)
 	if err != nil {
 		return nil, err
 	}
@@ -53,39 +209,104 @@ func newRepository(repo distribution.Repository, options map[string]interface{})
 
 	return &repository{
 		Repository:     repo,
+		ctx:            ctx,
 		registryClient: registryClient,
+		kubeClient:     kubeClient,
 		registryAddr:   registryAddr,
 		namespace:      nameParts[0],
 		name:           nameParts[1],
+		apiTimeout:     apiTimeout,
+		layerCacheTTL:  layerCacheTTL,
+		sizeLimits:     sizeLimits,
+
+		registryAliases:    registryAliasesFromEnv(),
+		insecureRegistries: insecureRegistriesFromEnv(),
+		metadataCache:      metadataCache,
+		eventRecorder:      registryEventRecorder(kubeClient),
+		notifier:           registryEventNotifier(),
+		registryConnector:  deps.RegistryConnector,
+		mirrorPullthrough:  mirrorPullthrough,
+
+		disablePullthrough: !pullthrough,
+		disableQuota:       !enforceQuota,
+		manifestPolicy:     manifestPolicy,
+		now:                deps.Now,
 	}, nil
 }
 
+// dockerImageMetadata returns the metadata of the image of m, read from the
+// v1Compatibility of its top layer: its config with the entrypoint, the
+// environment, the exposed ports and the labels, its author, etc.
+func dockerImageMetadata(m *manifest.SignedManifest) (imageapi.DockerImage, error) {
+	image, err := imageapi.ImageWithMetadata(imageapi.Image{DockerImageManifest: string(m.Raw)})
+	if err != nil {
+		return imageapi.DockerImage{}, err
+	}
+	return image.DockerImageMetadata, nil
+}
+
+// boolOption returns the value of the boolean option name of the middleware
+// configuration, or defaultValue if it isn't set.
+func boolOption(options map[string]interface{}, name string, defaultValue bool) (bool, error) {
+	value, ok := options[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+	return b, nil
+}
+
 // Manifests returns r, which implements distribution.ManifestService.
 func (r *repository) Manifests() distribution.ManifestService {
 	return r
 }
 
-// Tags lists the tags under the named repository.
+// Tags lists the tags under the named repository, sorted. If the image stream
+// doesn't exist, distribution.ErrRepositoryUnknown is returned, which clients
+// get as NAME_UNKNOWN.
 func (r *repository) Tags(ctx context.Context) ([]string, error) {
 	imageStream, err := r.getImageStream(ctx)
 	if err != nil {
-		return []string{}, nil
+		if kerrors.IsNotFound(err) {
+			return nil, distribution.ErrRepositoryUnknown{Name: r.namespace + "/" + r.name}
+		}
+		return nil, err
 	}
 	tags := []string{}
-	for tag := range imageStream.Status.Tags {
-		tags = append(tags, tag)
+	for tag, history := range imageStream.Status.Tags {
+		if len(history.Items) > 0 {
+			tags = append(tags, tag)
+		}
 	}
+	sort.Strings(tags)
 
 	return tags, nil
 }
 
-// Exists returns true if the manifest specified by dgst exists.
-func (r *repository) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
-	image, err := r.getImage(dgst)
+// TagsPage returns up to limit of the tags returned by Tags following last,
+// and whether there are more. A negative limit returns all of them.
+func (r *repository) TagsPage(ctx context.Context, limit int, last string) ([]string, bool, error) {
+	tags, err := r.Tags(ctx)
 	if err != nil {
+		return nil, false, err
+	}
+	page, more := paginate(tags, last, limit)
+	return page, more, nil
+}
+
+// Exists returns true if the manifest specified by dgst exists and belongs to
+// the image stream.
+func (r *repository) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	if err := r.verifyImageStreamImage(ctx, dgst); err != nil {
+		if _, unknown := err.(distribution.ErrUnknownManifestRevision); unknown {
+			return false, nil
+		}
 		return false, err
 	}
-	return image != nil, nil
+	return true, nil
 }
 
 // ExistsByTag returns true if the manifest with tag `tag` exists.
@@ -99,47 +320,83 @@ func (r *repository) ExistsByTag(ctx context.Context, tag string) (bool, error)
 }
 
 // Get retrieves the manifest with digest `dgst`.
-func (r *repository) Get(ctx context.Context, dgst digest.Digest) (*manifest.SignedManifest, error) {
-	if _, err := r.getImageStreamImage(ctx, dgst); err != nil {
-		log.Errorf("Error retrieving ImageStreamImage %s/%s@%s: %v", r.namespace, r.name, dgst.String(), err)
+func (r *repository) Get(ctx context.Context, dgst digest.Digest) (m *manifest.SignedManifest, err error) {
+	defer func() {
+		manifestRequests.WithLabelValues("get", resultLabel(err)).Inc()
+		if err == nil {
+			r.countPull(ctx)
+		}
+	}()
+
+	if err := r.verifyImageStreamImage(ctx, dgst); err != nil {
+		r.logger(ctx).Errorf("Error retrieving ImageStreamImage %s/%s@%s: %v", r.namespace, r.name, dgst.String(), err)
 		return nil, err
 	}
 
-	image, err := r.getImage(dgst)
+	image, err := r.getImage(ctx, dgst)
 	if err != nil {
-		log.Errorf("Error retrieving image %s: %v", dgst.String(), err)
+		r.logger(ctx).Errorf("Error retrieving image %s: %v", dgst.String(), err)
+		return nil, err
+	}
+	if err := r.verifySourceAccess(ctx, image); err != nil {
+		r.logger(ctx).Errorf("Error verifying the access to the source of image %s: %v", dgst.String(), err)
 		return nil, err
 	}
 
+	if r.isRemoteImage(image) {
+		return r.pullthroughManifest(ctx, image)
+	}
 	return r.manifestFromImage(image)
 }
 
 // GetByTag retrieves the named manifest with the provided tag
-func (r *repository) GetByTag(ctx context.Context, tag string) (*manifest.SignedManifest, error) {
+func (r *repository) GetByTag(ctx context.Context, tag string) (m *manifest.SignedManifest, err error) {
+	defer func() {
+		manifestRequests.WithLabelValues("get", resultLabel(err)).Inc()
+		if err == nil {
+			r.countPull(ctx)
+		}
+	}()
+
 	imageStreamTag, err := r.getImageStreamTag(ctx, tag)
 	if err != nil {
-		log.Errorf("Error getting ImageStreamTag %q: %v", tag, err)
+		r.logger(ctx).Errorf("Error getting ImageStreamTag %q: %v", tag, err)
 		return nil, err
 	}
 	image := &imageStreamTag.Image
 
 	dgst, err := digest.ParseDigest(imageStreamTag.Image.Name)
 	if err != nil {
-		log.Errorf("Error parsing digest %q: %v", imageStreamTag.Image.Name, err)
+		r.logger(ctx).Errorf("Error parsing digest %q: %v", imageStreamTag.Image.Name, err)
 		return nil, err
 	}
 
-	image, err = r.getImage(dgst)
+	image, err = r.getImage(ctx, dgst)
 	if err != nil {
-		log.Errorf("Error getting image %q: %v", dgst.String(), err)
+		r.logger(ctx).Errorf("Error getting image %q: %v", dgst.String(), err)
+		return nil, err
+	}
+	if err := r.verifySourceAccess(ctx, image); err != nil {
+		r.logger(ctx).Errorf("Error verifying the access to the source of image %q: %v", dgst.String(), err)
 		return nil, err
 	}
 
+	if r.isRemoteImage(image) {
+		return r.pullthroughManifest(ctx, image)
+	}
 	return r.manifestFromImage(image)
 }
 
 // Put creates or updates the named manifest.
-func (r *repository) Put(ctx context.Context, manifest *manifest.SignedManifest) error {
+func (r *repository) Put(ctx context.Context, manifest *manifest.SignedManifest) (err error) {
+	defer func() {
+		manifestRequests.WithLabelValues("put", resultLabel(err)).Inc()
+		if err == nil {
+			pushes.WithLabelValues(r.namespace).Inc()
+			r.countPush()
+		}
+	}()
+
 	// Resolve the payload in the manifest.
 	payload, err := manifest.Payload()
 	if err != nil {
@@ -152,36 +409,92 @@ func (r *repository) Put(ctx context.Context, manifest *manifest.SignedManifest)
 		return err
 	}
 
+	if err := r.manifestPolicy.admit(ctx, manifest); err != nil {
+		r.logger(ctx).Infof("Denying push of image %s to %s/%s: %v", dgst, r.namespace, r.name, err)
+		return err
+	}
+	if err := r.verifyLayers(ctx, manifest); err != nil {
+		r.logger(ctx).Errorf("Error verifying the layers of image %s: %v", dgst, err)
+		return err
+	}
+	layers, err := r.imageLayers(ctx, manifest)
+	if err != nil {
+		r.logger(ctx).Errorf("Error determining the layer sizes of image %s: %v", dgst, err)
+		return err
+	}
+	if err := r.admitImageSize(layers); err != nil {
+		r.logger(ctx).Errorf("Error admitting image %s into %s/%s: %v", dgst, r.namespace, r.name, err)
+		return err
+	}
+	layersJSON, err := json.Marshal(layers)
+	if err != nil {
+		return err
+	}
+	// the metadata only serves clients, an image without it can be pushed
+	metadata, err := dockerImageMetadata(manifest)
+	if err != nil {
+		r.logger(ctx).Warnf("Error reading the metadata of image %s: %v", dgst, err)
+	}
+
+	// admission counts the images of the stream as they are now
+	r.metadataCache.forgetImageStream(r.namespace, r.name)
+	if err := r.admitImagePush(ctx, dgst, imageSize(layers)); err != nil {
+		r.logger(ctx).Errorf("Error admitting image %s into %s/%s: %v", dgst, r.namespace, r.name, err)
+		return err
+	}
+
+	// a manifest pushed by digest isn't tagged, whatever its own tag says, so
+	// that it can be tagged later through the API
+	tag := manifest.Tag
+	if pushedByDigest(ctx) {
+		tag = ""
+	}
+	if len(tag) > 0 {
+		if err := r.admitImageTag(ctx, tag, dgst); err != nil {
+			return err
+		}
+	}
+
 	// Upload to openshift
 	ism := imageapi.ImageStreamMapping{
 		ObjectMeta: kapi.ObjectMeta{
 			Namespace: r.namespace,
 			Name:      r.name,
 		},
-		Tag: manifest.Tag,
+		Tag: tag,
 		Image: imageapi.Image{
 			ObjectMeta: kapi.ObjectMeta{
 				Name: dgst.String(),
 				Annotations: map[string]string{
 					imageapi.ManagedByOpenShiftAnnotation: "true",
+					// the sizes are recorded, so that they are known
+					// without a round trip to the storage backend
+					imageapi.ImageSizeAnnotation:   strconv.FormatInt(imageSize(layers), 10),
+					imageapi.ImageLayersAnnotation: string(layersJSON),
 				},
 			},
-			DockerImageReference: fmt.Sprintf("%s/%s/%s@%s", r.registryAddr, r.namespace, r.name, dgst.String()),
-			DockerImageManifest:  string(payload),
+			DockerImageReference: fmt.Sprintf("%s/%s/%s@%s", requestRegistryAddr(ctx, r.registryAddr, r.registryAliases), r.namespace, r.name, dgst.String()),
+			DockerImageMetadata:  metadata,
+			// the version of the metadata above
+			DockerImageMetadataVersion: "1.0",
+			// the signatures are kept with the manifest, so that they are
+			// shared by all the replicas of the registry and survive the
+			// replacement of its storage
+			DockerImageManifest: string(manifest.Raw),
 		},
 	}
 
-	if err := r.registryClient.ImageStreamMappings(r.namespace).Create(&ism); err != nil {
+	if err := r.createImageStreamMapping(ctx, &ism); err != nil {
 		// if the error was that the image stream wasn't found, try to auto provision it
 		statusErr, ok := err.(*kerrors.StatusError)
 		if !ok {
-			log.Errorf("Error creating ImageStreamMapping: %s", err)
+			r.logger(ctx).Errorf("Error creating ImageStreamMapping: %s", err)
 			return err
 		}
 
 		status := statusErr.ErrStatus
 		if status.Code != http.StatusNotFound || status.Details.Kind != "imageStream" || status.Details.Name != r.name {
-			log.Errorf("Error creating ImageStreamMapping: %s", err)
+			r.logger(ctx).Errorf("Error creating ImageStreamMapping: %s", err)
 			return err
 		}
 
@@ -193,65 +506,283 @@ func (r *repository) Put(ctx context.Context, manifest *manifest.SignedManifest)
 
 		client, ok := UserClientFrom(ctx)
 		if !ok {
-			log.Errorf("Error creating user client to auto provision image stream: Origin user client unavailable")
+			r.logger(ctx).Errorf("Error creating user client to auto provision image stream: Origin user client unavailable")
 			return statusErr
 		}
 
-		if _, err := client.ImageStreams(r.namespace).Create(&stream); err != nil {
-			log.Errorf("Error auto provisioning image stream: %s", err)
+		err := withDeadline(ctx, r.apiTimeout, "create ImageStream", func(ctx context.Context) error {
+			_, err := client.ImageStreams(r.namespace).Create(&stream)
+			return err
+		})
+		if err != nil {
+			r.logger(ctx).Errorf("Error auto provisioning image stream: %s", err)
 			return statusErr
 		}
 
 		// try to create the ISM again
-		if err := r.registryClient.ImageStreamMappings(r.namespace).Create(&ism); err != nil {
-			log.Errorf("Error creating image stream mapping: %s", err)
+		if err := r.createImageStreamMapping(ctx, &ism); err != nil {
+			r.logger(ctx).Errorf("Error creating image stream mapping: %s", err)
 			return err
 		}
 	}
 
-	// Grab each json signature and store them.
-	signatures, err := manifest.Signatures()
-	if err != nil {
-		return err
+	r.recordPush(ctx, dgst, tag)
+
+	// the image stream references new layers now
+	layerCache.forget(r.cacheKey())
+	r.metadataCache.forgetImageStream(r.namespace, r.name)
+	return nil
+}
+
+// clock returns the current time as told by the clock of r.
+func (r *repository) clock() time.Time {
+	if r.now == nil {
+		return time.Now()
 	}
+	return r.now()
+}
 
-	for _, signature := range signatures {
-		if err := r.Signatures().Put(dgst, signature); err != nil {
-			log.Errorf("Error storing signature: %s", err)
-			return err
-		}
+// logger returns the logger of the request of ctx, which carries the request
+// id and the authenticated user, with the namespace and the name of the image
+// stream of r as fields.
+func (r *repository) logger(ctx context.Context) ctxu.Logger {
+	return ctxu.GetLoggerWithFields(ctx, map[string]interface{}{
+		"openshift.namespace":  r.namespace,
+		"openshift.repository": r.name,
+	}, "http.request.id", "auth.user.name")
+}
+
+// requestContext returns the context of the request r serves, or the
+// background context for a repository that doesn't serve a request.
+func (r *repository) requestContext() context.Context {
+	if r.ctx == nil {
+		return context.Background()
 	}
+	return r.ctx
+}
 
-	return nil
+// pushedByDigest returns true if the manifest of the request of ctx is pushed
+// to PUT /v2/<name>/manifests/<digest> rather than to a tag.
+func pushedByDigest(ctx context.Context) bool {
+	_, err := digest.ParseDigest(ctxu.GetStringValue(ctx, "vars.reference"))
+	return err == nil
 }
 
 // Delete deletes the manifest with digest `dgst`. Note: Image resources
 // in OpenShift are deleted via 'oadm prune images'. This function deletes
 // the content related to the manifest in the registry's storage (signatures).
 func (r *repository) Delete(ctx context.Context, dgst digest.Digest) error {
-	return r.Repository.Manifests().Delete(ctx, dgst)
+	if err := r.Repository.Manifests().Delete(ctx, dgst); err != nil {
+		return err
+	}
+	r.recordDelete(ctx, dgst)
+
+	// the layers of the deleted manifest may not be referenced anymore
+	layerCache.forget(r.cacheKey())
+	r.metadataCache.forgetImageStream(r.namespace, r.name)
+	return nil
+}
+
+// removeTagEvents removes the events pointing at the image with digest dgst
+// from the tag history of the image stream, so that its tags don't advertise
+// an image whose manifest was deleted. It returns the tags that were changed.
+func (r *repository) removeTagEvents(ctx context.Context, dgst digest.Digest) ([]string, error) {
+	var updatedTags []string
+	err := kclient.RetryOnConflict(kclient.DefaultRetry, func() error {
+		updatedTags = nil
+
+		obj, err := fetchWithDeadline(ctx, r.apiTimeout, "get ImageStream", func(ctx context.Context) (interface{}, error) {
+			return r.registryClient.ImageStreams(r.namespace).Get(r.name)
+		})
+		if err != nil {
+			return err
+		}
+		imageStream := obj.(*imageapi.ImageStream)
+
+		for tag, history := range imageStream.Status.Tags {
+			newHistory := imageapi.TagEventList{Conditions: history.Conditions}
+			for _, event := range history.Items {
+				if event.Image == dgst.String() {
+					continue
+				}
+				newHistory.Items = append(newHistory.Items, event)
+			}
+			if len(newHistory.Items) != len(history.Items) {
+				imageStream.Status.Tags[tag] = newHistory
+				updatedTags = append(updatedTags, tag)
+			}
+		}
+		if len(updatedTags) == 0 {
+			return nil
+		}
+
+		return withDeadline(ctx, r.apiTimeout, "update ImageStream status", func(ctx context.Context) error {
+			_, err := r.registryClient.ImageStreams(r.namespace).UpdateStatus(imageStream)
+			return err
+		})
+	})
+	layerCache.forget(r.cacheKey())
+	r.metadataCache.forgetImageStream(r.namespace, r.name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(updatedTags)
+	return updatedTags, nil
+}
+
+// Enumerate returns the digests of all manifests that belong to the
+// repository. The digests are derived from the tag history of the
+// ImageStream, which also covers images that were retagged from other streams
+// or pushed by digest, so that the cost doesn't depend on the number of images
+// in the cluster.
+func (r *repository) Enumerate(ctx context.Context) ([]digest.Digest, error) {
+	imageStream, err := r.getImageStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := sets.NewString()
+	for _, history := range imageStream.Status.Tags {
+		for _, event := range history.Items {
+			// images imported from v1 registries are not addressable by digest
+			if _, err := digest.ParseDigest(event.Image); err != nil {
+				continue
+			}
+			seen.Insert(event.Image)
+		}
+	}
+
+	digests := make([]digest.Digest, 0, seen.Len())
+	for _, dgst := range seen.List() {
+		digests = append(digests, digest.Digest(dgst))
+	}
+	return digests, nil
+}
+
+// EnumeratePage returns up to limit of the digests returned by Enumerate,
+// which are sorted, following last, and whether there are more. A negative
+// limit returns all of them. It allows repositories with many images to be
+// processed a page at a time.
+func (r *repository) EnumeratePage(ctx context.Context, limit int, last digest.Digest) ([]digest.Digest, bool, error) {
+	digests, err := r.Enumerate(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	start := 0
+	if len(last) > 0 {
+		start = sort.Search(len(digests), func(i int) bool { return digests[i] > last })
+	}
+	page := digests[start:]
+	if limit < 0 || len(page) <= limit {
+		return page, false, nil
+	}
+	return page[:limit], limit > 0, nil
 }
 
 // getImageStream retrieves the ImageStream for r.
 func (r *repository) getImageStream(ctx context.Context) (*imageapi.ImageStream, error) {
-	return r.registryClient.ImageStreams(r.namespace).Get(r.name)
+	stream, err := r.getMetadata(ctx, imageStreamCacheKey(r.namespace, r.name), "get ImageStream", func() (interface{}, error) {
+		return r.registryClient.ImageStreams(r.namespace).Get(r.name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream.(*imageapi.ImageStream), nil
 }
 
 // getImage retrieves the Image with digest `dgst`.
-func (r *repository) getImage(dgst digest.Digest) (*imageapi.Image, error) {
-	return r.registryClient.Images().Get(dgst.String())
+func (r *repository) getImage(ctx context.Context, dgst digest.Digest) (*imageapi.Image, error) {
+	image, err := r.getMetadata(ctx, imageCacheKey(dgst.String()), "get Image", func() (interface{}, error) {
+		return r.registryClient.Images().Get(dgst.String())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return image.(*imageapi.Image), nil
 }
 
 // getImageStreamTag retrieves the Image with tag `tag` for the ImageStream
 // associated with r.
 func (r *repository) getImageStreamTag(ctx context.Context, tag string) (*imageapi.ImageStreamTag, error) {
-	return r.registryClient.ImageStreamTags(r.namespace).Get(r.name, tag)
+	key := imageStreamCacheKey(r.namespace, r.name) + ":" + tag
+	istag, err := r.getMetadata(ctx, key, "get ImageStreamTag", func() (interface{}, error) {
+		return r.registryClient.ImageStreamTags(r.namespace).Get(r.name, tag)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return istag.(*imageapi.ImageStreamTag), nil
 }
 
 // getImageStreamImage retrieves the Image with digest `dgst` for the ImageStream
 // associated with r. This ensures the image belongs to the image stream.
 func (r *repository) getImageStreamImage(ctx context.Context, dgst digest.Digest) (*imageapi.ImageStreamImage, error) {
-	return r.registryClient.ImageStreamImages(r.namespace).Get(r.name, dgst.String())
+	key := imageStreamCacheKey(r.namespace, r.name) + "@" + dgst.String()
+	isimage, err := r.getMetadata(ctx, key, "get ImageStreamImage", func() (interface{}, error) {
+		return r.registryClient.ImageStreamImages(r.namespace).Get(r.name, dgst.String())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return isimage.(*imageapi.ImageStreamImage), nil
+}
+
+// getMetadata returns the object remembered under key by the metadata cache,
+// or else fetches it from the master with fetch. If the master stays
+// unavailable, the expired copy remembered is returned instead, if any.
+func (r *repository) getMetadata(ctx context.Context, key, operation string, fetch func() (interface{}, error)) (interface{}, error) {
+	if cached, ok := r.metadataCache.get(key); ok {
+		return cached, nil
+	}
+	object, err := r.fetchFromMaster(ctx, operation, fetch)
+	if err == nil {
+		r.metadataCache.set(key, object)
+		return object, nil
+	}
+	if isTransientAPIError(err) {
+		if stale, ok := r.metadataCache.getStale(key); ok {
+			ctxu.GetLogger(ctx).Warnf("Serving %s from the cache, the master is unavailable: %v", key, err)
+			return stale, nil
+		}
+	}
+	return nil, err
+}
+
+// verifyImageStreamImage verifies that the image with digest dgst belongs to
+// the image stream, i.e. that it is in the history of one of its tags, even if
+// no tag points to it anymore. Pulling by digest is authorized by the access
+// to the image stream, so this keeps the images of other image streams, that
+// the user may not see, out of reach. If the image doesn't belong to the image
+// stream, distribution.ErrUnknownManifestRevision is returned, which clients
+// get as MANIFEST_UNKNOWN. Images pushed by digest to the repository of r
+// belong to it before they are tagged.
+func (r *repository) verifyImageStreamImage(ctx context.Context, dgst digest.Digest) error {
+	_, err := r.getImageStreamImage(ctx, dgst)
+	if !kerrors.IsNotFound(err) {
+		return err
+	}
+	if image, err := r.getImage(ctx, dgst); err == nil && r.isUntaggedImage(image) {
+		return nil
+	}
+	return distribution.ErrUnknownManifestRevision{Name: r.namespace + "/" + r.name, Revision: dgst}
+}
+
+// isUntaggedImage returns true if image was pushed by digest to the
+// repository of r and isn't tagged in its image stream.
+func (r *repository) isUntaggedImage(image *imageapi.Image) bool {
+	if r.isRemoteImage(image) {
+		return false
+	}
+	ref, err := imageapi.ParseDockerImageReference(image.DockerImageReference)
+	return err == nil && ref.Namespace == r.namespace && ref.Name == r.name
+}
+
+// createImageStreamMapping creates the ImageStreamMapping `ism`.
+func (r *repository) createImageStreamMapping(ctx context.Context, ism *imageapi.ImageStreamMapping) error {
+	return r.callMaster(ctx, "create ImageStreamMapping", func() error {
+		return r.registryClient.ImageStreamMappings(r.namespace).Create(ism)
+	})
 }
 
 // manifestFromImage converts an Image to a SignedManifest.
@@ -261,7 +792,16 @@ func (r *repository) manifestFromImage(image *imageapi.Image) (*manifest.SignedM
 		return nil, err
 	}
 
-	// Fetch the signatures for the manifest
+	var signed manifest.SignedManifest
+	if err := json.Unmarshal([]byte(image.DockerImageManifest), &signed); err != nil {
+		return nil, err
+	}
+	if signatures, err := signed.Signatures(); err == nil && len(signatures) > 0 {
+		return &signed, nil
+	}
+
+	// Images pushed before the signatures were kept with the manifest have
+	// them in the storage of the registry.
 	signatures, err := r.Signatures().Get(dgst)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/dockerregistry"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+const (
+	testDigest1 = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+	testDigest2 = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+	testDigest3 = "sha256:0000000000000000000000000000000000000000000000000000000000000003"
+	testDigest4 = "sha256:0000000000000000000000000000000000000000000000000000000000000004"
+)
+
+func TestEnumerateImages(t *testing.T) {
+	tests := map[string]struct {
+		stream   *imageapi.ImageStream
+		images   []imageapi.Image
+		expected []digest.Digest
+	}{
+		"tag history": {
+			stream: &imageapi.ImageStream{
+				ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+				Status: imageapi.ImageStreamStatus{
+					Tags: map[string]imageapi.TagEventList{
+						"latest": {Items: []imageapi.TagEvent{{Image: testDigest2}, {Image: testDigest1}}},
+						"prod":   {Items: []imageapi.TagEvent{{Image: testDigest1}}},
+						"v1":     {Items: []imageapi.TagEvent{{Image: "abcdef"}}},
+					},
+				},
+			},
+			expected: []digest.Digest{testDigest1, testDigest2},
+		},
+		"no history": {
+			stream: &imageapi.ImageStream{
+				ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+			},
+			images: []imageapi.Image{
+				{
+					ObjectMeta:           kapi.ObjectMeta{Name: testDigest3},
+					DockerImageReference: "registry:5000/ns/app@" + testDigest3,
+				},
+			},
+			expected: []digest.Digest{},
+		},
+	}
+
+	for name, test := range tests {
+		client, _ := testclient.NewImageTrackerFake(test.stream, &imageapi.ImageList{Items: test.images})
+		r := &repository{
+			registryClient: client,
+			registryAddr:   "registry:5000",
+			namespace:      "ns",
+			name:           "app",
+		}
+
+		digests, err := r.Enumerate(context.Background())
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(digests, test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, digests)
+		}
+		for _, action := range client.Actions() {
+			if action.GetResource() == "images" && action.GetVerb() == "list" {
+				t.Errorf("%s: expected images not to be listed", name)
+			}
+		}
+	}
+}
+
+func TestManifestFromImageWithSignatures(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      "ns/app",
+		Tag:       "latest",
+		FSLayers:  []manifest.FSLayer{{BlobSum: testLayerDigest}},
+		History:   []manifest.History{{V1Compatibility: "{}"}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := signed.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the repository has no storage, the signatures must come from the image
+	r := &repository{namespace: "ns", name: "app"}
+	m, err := r.manifestFromImage(&imageapi.Image{
+		ObjectMeta:          kapi.ObjectMeta{Name: dgst.String()},
+		DockerImageManifest: string(signed.Raw),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(m.Raw) != string(signed.Raw) {
+		t.Errorf("expected the signed manifest to be served as pushed")
+	}
+	if _, err := manifest.Verify(m); err != nil {
+		t.Errorf("expected the signatures to verify: %v", err)
+	}
+}
+
+func TestRemoveTagEvents(t *testing.T) {
+	client, _ := testclient.NewImageTrackerFake(&imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest2}, {Image: testDigest1}}},
+				"prod":   {Items: []imageapi.TagEvent{{Image: testDigest1}}},
+				"v1":     {Items: []imageapi.TagEvent{{Image: testDigest3}}},
+			},
+		},
+	})
+	r := &repository{
+		registryClient: client,
+		namespace:      "ns",
+		name:           "app",
+	}
+
+	updatedTags, err := r.removeTagEvents(context.Background(), testDigest1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(updatedTags, []string{"latest", "prod"}) {
+		t.Errorf("unexpected updated tags %v", updatedTags)
+	}
+
+	stream, err := client.ImageStreams("ns").Get("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]imageapi.TagEventList{
+		"latest": {Items: []imageapi.TagEvent{{Image: testDigest2}}},
+		"prod":   {},
+		"v1":     {Items: []imageapi.TagEvent{{Image: testDigest3}}},
+	}
+	if !reflect.DeepEqual(stream.Status.Tags, expected) {
+		t.Errorf("expected tags %#v, got %#v", expected, stream.Status.Tags)
+	}
+
+	// nothing left to remove, the stream isn't updated
+	client.ClearActions()
+	if updatedTags, err := r.removeTagEvents(context.Background(), testDigest1); err != nil || len(updatedTags) != 0 {
+		t.Errorf("expected no tag to be updated, got %v: %v", updatedTags, err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("unexpected update of the image stream")
+		}
+	}
+}
+
+func TestDockerImageMetadata(t *testing.T) {
+	m := &manifest.SignedManifest{
+		Manifest: manifest.Manifest{
+			Versioned: manifest.Versioned{SchemaVersion: 1},
+			FSLayers:  []manifest.FSLayer{{BlobSum: testLayerDigest}, {BlobSum: testLayerDigest}},
+			History: []manifest.History{
+				{V1Compatibility: `{"id":"top","parent":"base","author":"me","config":{"Entrypoint":["/bin/app"],"Env":["A=1"],"ExposedPorts":{"8080/tcp":{}},"Labels":{"app":"test"}}}`},
+				{V1Compatibility: `{"id":"base"}`},
+			},
+		},
+	}
+	raw, err := json.Marshal(&m.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Raw = raw
+
+	metadata, err := dockerImageMetadata(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata.ID != "top" || metadata.Parent != "base" || metadata.Author != "me" || metadata.Config == nil {
+		t.Fatalf("unexpected metadata %#v", metadata)
+	}
+	config := metadata.Config
+	if !reflect.DeepEqual(config.Entrypoint, []string{"/bin/app"}) || !reflect.DeepEqual(config.Env, []string{"A=1"}) {
+		t.Errorf("unexpected config %#v", config)
+	}
+	if _, ok := config.ExposedPorts["8080/tcp"]; !ok || config.Labels["app"] != "test" {
+		t.Errorf("unexpected config %#v", config)
+	}
+
+	m.Raw = []byte(`{"history":[{"v1Compatibility":"not json"}]}`)
+	if _, err := dockerImageMetadata(m); err == nil {
+		t.Errorf("expected an error for an invalid v1Compatibility")
+	}
+}
+
+func TestManifestsByDigest(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest2}, {Image: testDigest1}}},
+			},
+		},
+	}
+	otherStream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "other"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest3}}},
+			},
+		},
+	}
+	images := &imageapi.ImageList{Items: []imageapi.Image{
+		{ObjectMeta: kapi.ObjectMeta{Name: testDigest1}},
+		{ObjectMeta: kapi.ObjectMeta{Name: testDigest2}},
+		{ObjectMeta: kapi.ObjectMeta{Name: testDigest3}, DockerImageReference: "registry:5000/ns/other@" + testDigest3},
+		{ObjectMeta: kapi.ObjectMeta{Name: testDigest4}, DockerImageReference: "registry:5000/ns/app@" + testDigest4},
+	}}
+	client, _ := testclient.NewImageTrackerFake(stream, otherStream, images)
+	r := &repository{
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+	}
+
+	// an image no tag points to anymore is still in the history
+	if exists, err := r.Exists(context.Background(), testDigest1); err != nil || !exists {
+		t.Errorf("expected the image of the tag history to exist, got %t, %v", exists, err)
+	}
+	if exists, err := r.Exists(context.Background(), testDigest3); err != nil || exists {
+		t.Errorf("expected the image of another image stream not to exist, got %t, %v", exists, err)
+	}
+	_, err := r.Get(context.Background(), testDigest3)
+	if _, unknown := err.(distribution.ErrUnknownManifestRevision); !unknown {
+		t.Errorf("expected an unknown manifest revision for the image of another image stream, got %v", err)
+	}
+	// an image pushed by digest belongs to the image stream before it's tagged
+	if exists, err := r.Exists(context.Background(), testDigest4); err != nil || !exists {
+		t.Errorf("expected the untagged image pushed to the repository to exist, got %t, %v", exists, err)
+	}
+}
+
+func TestPushedByDigest(t *testing.T) {
+	for reference, expected := range map[string]bool{
+		testDigest1: true,
+		"latest":    false,
+		"":          false,
+	} {
+		ctx := context.WithValue(context.Background(), "vars.reference", reference)
+		if pushedByDigest(ctx) != expected {
+			t.Errorf("%q: expected pushed by digest %t", reference, expected)
+		}
+	}
+}
+
+func TestRepositoryLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buf
+	logger.Formatter = &logrus.JSONFormatter{}
+
+	ctx := ctxu.WithLogger(ctxu.Background(), logrus.NewEntry(logger))
+	ctx = ctxu.WithValue(ctx, "http.request.id", "request-1")
+	ctx = ctxu.WithValue(ctx, "auth.user.name", "alice")
+	r := &repository{namespace: "ns", name: "app"}
+	r.logger(ctx).Errorf("pushing failed")
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("unexpected log entry %q: %v", buf.String(), err)
+	}
+	for key, expected := range map[string]string{
+		"http.request.id":      "request-1",
+		"auth.user.name":       "alice",
+		"openshift.namespace":  "ns",
+		"openshift.repository": "app",
+		"msg":                  "pushing failed",
+	} {
+		if fields[key] != expected {
+			t.Errorf("expected %s %q, got %v", key, expected, fields[key])
+		}
+	}
+}
+
+func TestNewRepositoryMiddleware(t *testing.T) {
+	registryClient, _ := testclient.NewImageTrackerFake()
+	kubeClient := ktestclient.NewSimpleFake()
+	remote := &fakeRemoteRegistry{}
+	now := time.Unix(1000, 0)
+	deps := RepositoryDependencies{
+		RegistryAddr:      func() (string, error) { return "registry:5000", nil },
+		OpenShiftClient:   func() (client.Interface, error) { return registryClient, nil },
+		KubeClient:        func() (kclient.Interface, error) { return kubeClient, nil },
+		RegistryConnector: func(dockerregistry.Credentials) dockerregistry.Client { return remote },
+		Now:               func() time.Time { return now },
+	}
+
+	repo, err := NewRepositoryMiddleware(deps)(context.Background(), &fakeLocalRepository{name: "ns/app"}, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := repo.(*repository)
+	if r.registryClient != registryClient || r.kubeClient != kubeClient || r.registryConnector(dockerregistry.NoCredentials) != remote {
+		t.Errorf("expected the clients of the dependencies to be used, got %#v", r)
+	}
+	if r.registryAddr != "registry:5000" || r.namespace != "ns" || r.name != "app" {
+		t.Errorf("unexpected repository %s/%s/%s", r.registryAddr, r.namespace, r.name)
+	}
+	if !r.clock().Equal(now) {
+		t.Errorf("expected the clock of the dependencies to be used, got %v", r.clock())
+	}
+
+	deps.RegistryAddr = func() (string, error) { return "", errors.New("REGISTRY_URL is required") }
+	if _, err := NewRepositoryMiddleware(deps)(context.Background(), &fakeLocalRepository{name: "ns/app"}, map[string]interface{}{}); err == nil {
+		t.Errorf("expected an error without the registry address")
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	utilerrors "k8s.io/kubernetes/pkg/util/errors"
+
+	"github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// repositoryStatsUpdateRetries is how many times the annotations of an image
+// stream are updated again after a conflict.
+const repositoryStatsUpdateRetries = 3
+
+// RepositoryStatsIntervalFromEnv returns how often the pulls and pushes of the
+// repositories are added to the annotations of their image streams, from
+// REGISTRY_REPOSITORY_STATS_INTERVAL, e.g. "10m". Zero, the default, only
+// exposes them as metrics.
+func RepositoryStatsIntervalFromEnv() (time.Duration, error) {
+	value := os.Getenv("REGISTRY_REPOSITORY_STATS_INTERVAL")
+	if len(value) == 0 {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid REGISTRY_REPOSITORY_STATS_INTERVAL %q", value)
+	}
+	return interval, nil
+}
+
+// repositoryStats are the pulls and pushes of a repository not yet added to
+// the annotations of its image stream.
+type repositoryStats struct {
+	pulls      int64
+	pushes     int64
+	lastPulled time.Time
+}
+
+// repositoryStatsRecorder collects the pulls and pushes of the repositories
+// until they are added to the annotations of their image streams. Each replica
+// of the registry adds its own.
+type repositoryStatsRecorder struct {
+	mu    sync.Mutex
+	stats map[imageStreamName]*repositoryStats
+}
+
+// imageStreamName identifies an image stream.
+type imageStreamName struct {
+	namespace, name string
+}
+
+// repositoryStatsAnnotations collects the pulls and pushes for the
+// annotations of the image streams, nil only exposes them as metrics.
+var repositoryStatsAnnotations *repositoryStatsRecorder
+
+// UpdateRepositoryStatsEvery adds the pulls and pushes of the repositories to
+// the annotations of their image streams with registryClient every interval,
+// in the background.
+func UpdateRepositoryStatsEvery(registryClient client.Interface, interval time.Duration) {
+	recorder := &repositoryStatsRecorder{stats: map[imageStreamName]*repositoryStats{}}
+	repositoryStatsAnnotations = recorder
+	go func() {
+		for range time.Tick(interval) {
+			if err := recorder.flush(registryClient); err != nil {
+				log.Errorf("Error updating the pulls and pushes of the image streams: %v", err)
+			}
+		}
+	}()
+}
+
+// countPull records a pull of the repository of r if ctx is the context of a
+// GET request, HEAD requests and the manifests read by the registry itself
+// aren't pulls.
+func (r *repository) countPull(ctx context.Context) {
+	req, err := ctxu.GetRequest(ctx)
+	if err != nil || req.Method != "GET" {
+		return
+	}
+	now := r.clock()
+	repositoryPulls.WithLabelValues(r.namespace, r.name).Inc()
+	repositoryLastPulled.WithLabelValues(r.namespace, r.name).Set(float64(now.Unix()))
+	if recorder := repositoryStatsAnnotations; recorder != nil {
+		recorder.add(r.namespace, r.name, repositoryStats{pulls: 1, lastPulled: now})
+	}
+}
+
+// countPush records a push to the repository of r.
+func (r *repository) countPush() {
+	repositoryPushes.WithLabelValues(r.namespace, r.name).Inc()
+	if recorder := repositoryStatsAnnotations; recorder != nil {
+		recorder.add(r.namespace, r.name, repositoryStats{pushes: 1})
+	}
+}
+
+func (s *repositoryStatsRecorder) add(namespace, name string, stats repositoryStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := imageStreamName{namespace: namespace, name: name}
+	current, ok := s.stats[key]
+	if !ok {
+		current = &repositoryStats{}
+		s.stats[key] = current
+	}
+	current.pulls += stats.pulls
+	current.pushes += stats.pushes
+	if stats.lastPulled.After(current.lastPulled) {
+		current.lastPulled = stats.lastPulled
+	}
+}
+
+// flush adds the stats collected to the annotations of the image streams. The
+// stats of the image streams that couldn't be updated are kept for the next
+// flush, those of the image streams that don't exist anymore are dropped.
+func (s *repositoryStatsRecorder) flush(registryClient client.Interface) error {
+	s.mu.Lock()
+	pending := s.stats
+	s.stats = map[imageStreamName]*repositoryStats{}
+	s.mu.Unlock()
+
+	errs := []error{}
+	for key, stats := range pending {
+		err := updateRepositoryStats(registryClient, key.namespace, key.name, stats)
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error updating image stream %s/%s: %v", key.namespace, key.name, err))
+			s.add(key.namespace, key.name, *stats)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// updateRepositoryStats adds stats to the annotations of the image stream,
+// retrying on conflicts with the other replicas.
+func updateRepositoryStats(registryClient client.Interface, namespace, name string, stats *repositoryStats) error {
+	var err error
+	for i := 0; i <= repositoryStatsUpdateRetries; i++ {
+		var stream *imageapi.ImageStream
+		stream, err = registryClient.ImageStreams(namespace).Get(name)
+		if err != nil {
+			return err
+		}
+		if stream.Annotations == nil {
+			stream.Annotations = map[string]string{}
+		}
+		addCountAnnotation(stream.Annotations, imageapi.ImageStreamPullsAnnotation, stats.pulls)
+		addCountAnnotation(stream.Annotations, imageapi.ImageStreamPushesAnnotation, stats.pushes)
+		if !stats.lastPulled.IsZero() {
+			lastPulled, parseErr := time.Parse(time.RFC3339, stream.Annotations[imageapi.ImageStreamLastPulledAnnotation])
+			if parseErr != nil || stats.lastPulled.After(lastPulled) {
+				stream.Annotations[imageapi.ImageStreamLastPulledAnnotation] = stats.lastPulled.UTC().Format(time.RFC3339)
+			}
+		}
+
+		_, err = registryClient.ImageStreams(namespace).Update(stream)
+		if !kerrors.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// addCountAnnotation adds delta to the count of the annotation key, an
+// invalid count is reset.
+func addCountAnnotation(annotations map[string]string, key string, delta int64) {
+	if delta == 0 {
+		return
+	}
+	count, err := strconv.ParseInt(annotations[key], 10, 64)
+	if err != nil {
+		count = 0
+	}
+	annotations[key] = strconv.FormatInt(count+delta, 10)
+}
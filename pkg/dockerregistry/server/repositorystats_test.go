@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestRepositoryStats(t *testing.T) {
+	defer func(recorder *repositoryStatsRecorder) { repositoryStatsAnnotations = recorder }(repositoryStatsAnnotations)
+	recorder := &repositoryStatsRecorder{stats: map[imageStreamName]*repositoryStats{}}
+	repositoryStatsAnnotations = recorder
+
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace: "ns",
+			Name:      "app",
+			Annotations: map[string]string{
+				imageapi.ImageStreamPullsAnnotation:      "10",
+				imageapi.ImageStreamLastPulledAnnotation: "2015-01-01T00:00:00Z",
+			},
+		},
+	}
+	client, tracker := testclient.NewImageTrackerFake(stream)
+	r := &repository{registryClient: client, namespace: "ns", name: "app"}
+	gone := &repository{registryClient: client, namespace: "ns", name: "gone"}
+
+	get, _ := http.NewRequest("GET", "/v2/ns/app/manifests/latest", nil)
+	head, _ := http.NewRequest("HEAD", "/v2/ns/app/manifests/latest", nil)
+	r.countPull(ctxu.WithRequest(context.Background(), get))
+	r.countPull(ctxu.WithRequest(context.Background(), get))
+	r.countPull(ctxu.WithRequest(context.Background(), head))
+	r.countPull(context.Background())
+	r.countPush()
+	gone.countPush()
+
+	if err := recorder.flush(client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated, ok := tracker.ImageStream("ns", "app")
+	if !ok {
+		t.Fatalf("image stream ns/app not found")
+	}
+	if pulls := updated.Annotations[imageapi.ImageStreamPullsAnnotation]; pulls != "12" {
+		t.Errorf("expected 12 pulls, got %q", pulls)
+	}
+	if pushes := updated.Annotations[imageapi.ImageStreamPushesAnnotation]; pushes != "1" {
+		t.Errorf("expected 1 push, got %q", pushes)
+	}
+	lastPulled, err := time.Parse(time.RFC3339, updated.Annotations[imageapi.ImageStreamLastPulledAnnotation])
+	if err != nil || time.Since(lastPulled) > time.Minute {
+		t.Errorf("expected the last pull to be recent, got %q", updated.Annotations[imageapi.ImageStreamLastPulledAnnotation])
+	}
+	if len(recorder.stats) != 0 {
+		t.Errorf("expected no stats to be left, got %v", recorder.stats)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/handlers"
+	gorillahandlers "github.com/gorilla/handlers"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// signatureSchemaVersion is the version of the signature listings.
+const signatureSchemaVersion = 2
+
+// SignatureDispatcher takes the request context and builds the appropriate
+// handler for listing the signatures of an image.
+func SignatureDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	reference := ctxu.GetStringValue(ctx, "vars.digest")
+	dgst, _ := digest.ParseDigest(reference)
+
+	signatureHandler := &signatureHandler{
+		Context: ctx,
+		Digest:  dgst,
+	}
+
+	return gorillahandlers.MethodHandler{
+		"GET": http.HandlerFunc(signatureHandler.Get),
+	}
+}
+
+// signatureHandler handles the listing of the signatures of an image.
+type signatureHandler struct {
+	*handlers.Context
+
+	Digest digest.Digest
+}
+
+type signature struct {
+	// Version is the version of the schema of the signature.
+	Version int `json:"schemaVersion"`
+	// Name of the signature, unique for the image.
+	Name string `json:"name"`
+	// Type of the signature.
+	Type string `json:"type"`
+	// Content is the opaque signature, base64 encoded.
+	Content []byte `json:"content"`
+}
+
+type signatureList struct {
+	Signatures []signature `json:"signatures"`
+}
+
+// Get lists the signatures stored by the master with the image of the
+// repository with the digest of the request.
+func (sh *signatureHandler) Get(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	if len(sh.Digest) == 0 {
+		sh.Errors.Push(v2.ErrorCodeManifestUnknown)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	r, ok := sh.Repository.(*repository)
+	if !ok {
+		sh.Errors.PushErr(fmt.Errorf("unexpected repository %T", sh.Repository))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// the signatures aren't cached, so that new signatures are seen at once
+	obj, err := r.fetchFromMaster(sh, "get ImageStreamImage", func() (interface{}, error) {
+		return r.registryClient.ImageStreamImages(r.namespace).Get(r.name, sh.Digest.String())
+	})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			sh.Errors.Push(v2.ErrorCodeManifestUnknown, map[string]string{"digest": sh.Digest.String()})
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sh.Errors.PushErr(fmt.Errorf("error getting the image %s of repo %q: %v", sh.Digest, r.Name(), err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	isimage := obj.(*imageapi.ImageStreamImage)
+
+	list := signatureList{Signatures: []signature{}}
+	for _, s := range isimage.Image.Signatures {
+		_, name, ok := imageapi.SplitImageSignatureName(s.Name)
+		if !ok {
+			continue
+		}
+		list.Signatures = append(list.Signatures, signature{
+			Version: signatureSchemaVersion,
+			Name:    name,
+			Type:    s.Type,
+			Content: s.Content,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		sh.Errors.PushErr(err)
+		return
+	}
+}
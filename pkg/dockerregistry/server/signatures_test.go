@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/handlers"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestGetSignatures(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest1}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta: kapi.ObjectMeta{Name: testDigest1},
+		Signatures: []imageapi.ImageSignature{
+			{
+				ObjectMeta: kapi.ObjectMeta{Name: testDigest1 + "@sig"},
+				Type:       imageapi.ImageSignatureTypeAtomicImageV1,
+				Content:    []byte("signature"),
+			},
+		},
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	r := &repository{
+		Repository:     &fakeLocalRepository{name: "ns/app"},
+		registryClient: client,
+		namespace:      "ns",
+		name:           "app",
+	}
+
+	ctx := &handlers.Context{Context: context.Background(), Repository: r}
+	req, _ := http.NewRequest("GET", "/extensions/v2/ns/app/signatures/"+testDigest1, strings.NewReader(""))
+	w := httptest.NewRecorder()
+	(&signatureHandler{Context: ctx, Digest: digest.Digest(testDigest1)}).Get(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", w.Code, ctx.Errors)
+	}
+	list := signatureList{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Signatures) != 1 {
+		t.Fatalf("expected a signature, got %#v", list.Signatures)
+	}
+	s := list.Signatures[0]
+	if s.Version != signatureSchemaVersion || s.Name != "sig" || s.Type != imageapi.ImageSignatureTypeAtomicImageV1 || string(s.Content) != "signature" {
+		t.Errorf("unexpected signature %#v", s)
+	}
+
+	ctx = &handlers.Context{Context: context.Background(), Repository: r}
+	req, _ = http.NewRequest("GET", "/extensions/v2/ns/app/signatures/"+testDigest2, strings.NewReader(""))
+	w = httptest.NewRecorder()
+	(&signatureHandler{Context: ctx, Digest: digest.Digest(testDigest2)}).Get(w, req)
+	if w.Code != http.StatusNotFound || len(ctx.Errors.Errors) != 1 {
+		t.Errorf("expected 404 with MANIFEST_UNKNOWN, got %d: %v", w.Code, ctx.Errors)
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// SizeLimits are the maximum sizes in bytes of the layers and images pushed
+// to the registry. Zero means unlimited.
+type SizeLimits struct {
+	MaxLayerSize int64
+	MaxImageSize int64
+}
+
+// SizeLimitsFromEnv returns the limits set by REGISTRY_MAX_LAYER_SIZE and
+// REGISTRY_MAX_IMAGE_SIZE, given as quantities such as 2Gi.
+func SizeLimitsFromEnv() (SizeLimits, error) {
+	limits := SizeLimits{}
+	for env, limit := range map[string]*int64{
+		"REGISTRY_MAX_LAYER_SIZE": &limits.MaxLayerSize,
+		"REGISTRY_MAX_IMAGE_SIZE": &limits.MaxImageSize,
+	} {
+		value := os.Getenv(env)
+		if len(value) == 0 {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil || quantity.Value() < 0 {
+			return SizeLimits{}, fmt.Errorf("invalid %s %q", env, value)
+		}
+		*limit = quantity.Value()
+	}
+	return limits, nil
+}
+
+var (
+	blobUploadPath = regexp.MustCompile(`^/v2/.+/blobs/uploads/[^/]+$`)
+	contentRange   = regexp.MustCompile(`^(?:bytes )?(\d+)-(\d+)`)
+)
+
+// WithLayerSizeLimit rejects blob upload requests that would make the layer
+// larger than maxLayerSize with 413 Request Entity Too Large, and cuts off the
+// body of those that don't announce their size once it exceeds the limit. A
+// layer uploaded in chunks is checked once more when the manifest referencing
+// it is pushed.
+func WithLayerSizeLimit(handler http.Handler, maxLayerSize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != "PATCH" && r.Method != "PUT") || !blobUploadPath.MatchString(r.URL.Path) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		var offset int64
+		if m := contentRange.FindStringSubmatch(r.Header.Get("Content-Range")); m != nil {
+			offset, _ = strconv.ParseInt(m[1], 10, 64)
+			if end, _ := strconv.ParseInt(m[2], 10, 64); end >= maxLayerSize {
+				writeSizeInvalid(w, maxLayerSize)
+				return
+			}
+		}
+		if offset+r.ContentLength > maxLayerSize {
+			writeSizeInvalid(w, maxLayerSize)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxLayerSize-offset)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func writeSizeInvalid(w http.ResponseWriter, maxLayerSize int64) {
+	errs := &v2.Errors{}
+	errs.Push(v2.ErrorCodeSizeInvalid, map[string]int64{"maxLayerSize": maxLayerSize})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(errs)
+}
+
+// imageLayers returns the distinct layers of m with their sizes, in the order
+// of the manifest. The sizes are those of the layers stored in the repository,
+// or of the layers it serves from elsewhere as told by servedLayerSize: a
+// layer that could be pulled through isn't fetched, and the layers uploaded in
+// chunks are checked whole.
+func (r *repository) imageLayers(ctx context.Context, m *manifest.SignedManifest) ([]imageapi.ImageLayerSize, error) {
+	layers := []imageapi.ImageLayerSize{}
+	seen := sets.NewString()
+	for _, fsLayer := range m.FSLayers {
+		if seen.Has(fsLayer.BlobSum.String()) {
+			continue
+		}
+		seen.Insert(fsLayer.BlobSum.String())
+
+		layer, err := r.Repository.Layers().Fetch(fsLayer.BlobSum)
+		if _, unknown := err.(distribution.ErrUnknownLayer); unknown {
+			size, sizeErr := r.servedLayerSize(ctx, fsLayer.BlobSum)
+			if sizeErr != nil {
+				r.logger(ctx).Debugf("Layer %s of %s/%s isn't served from elsewhere: %v", fsLayer.BlobSum, r.namespace, r.name, sizeErr)
+				return nil, err
+			}
+			layers = append(layers, imageapi.ImageLayerSize{Name: fsLayer.BlobSum.String(), Size: size})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, imageapi.ImageLayerSize{Name: fsLayer.BlobSum.String(), Size: layer.Length()})
+		layer.Close()
+	}
+	return layers, nil
+}
+
+// servedLayerSize returns the size of a layer that isn't stored in the
+// repository of r but is served from it: the size of the layer in the
+// repository an image of the image stream was tagged from, or else the size
+// recorded on a remote image tagged into the image stream. Nothing is pulled
+// through, the size of a remote layer isn't known if no image recorded it.
+func (r *repository) servedLayerSize(ctx context.Context, dgst digest.Digest) (int64, error) {
+	if layer, _, err := r.fetchSourceLayer(ctx, dgst); err == nil {
+		defer layer.Close()
+		return layer.Length(), nil
+	}
+	var size int64
+	_, err := r.findLayerImage(ctx, dgst, func(_ imageapi.TagEvent, image *imageapi.Image) bool {
+		if !r.isRemoteImage(image) {
+			return false
+		}
+		layers, _ := imageapi.ImageLayerSizes(image)
+		for _, layer := range layers {
+			if layer.Name == dgst.String() {
+				size = layer.Size
+				return true
+			}
+		}
+		return false
+	})
+	return size, err
+}
+
+// imageSize returns the total size of layers.
+func imageSize(layers []imageapi.ImageLayerSize) int64 {
+	var total int64
+	for _, layer := range layers {
+		total += layer.Size
+	}
+	return total
+}
+
+// admitImageSize verifies that none of the layers of an image is larger than
+// the maximum layer size and that they don't add up to more than the maximum
+// image size.
+func (r *repository) admitImageSize(layers []imageapi.ImageLayerSize) error {
+	for _, layer := range layers {
+		if r.sizeLimits.MaxLayerSize > 0 && layer.Size > r.sizeLimits.MaxLayerSize {
+			return &QuotaExceededError{Message: fmt.Sprintf("layer %s is %d bytes, larger than the maximum of %d bytes", layer.Name, layer.Size, r.sizeLimits.MaxLayerSize)}
+		}
+	}
+	if total := imageSize(layers); r.sizeLimits.MaxImageSize > 0 && total > r.sizeLimits.MaxImageSize {
+		return &QuotaExceededError{Message: fmt.Sprintf("the image is %d bytes, larger than the maximum of %d bytes", total, r.sizeLimits.MaxImageSize)}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/dockerregistry"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestSizeLimitsFromEnv(t *testing.T) {
+	defer os.Setenv("REGISTRY_MAX_LAYER_SIZE", os.Getenv("REGISTRY_MAX_LAYER_SIZE"))
+	defer os.Setenv("REGISTRY_MAX_IMAGE_SIZE", os.Getenv("REGISTRY_MAX_IMAGE_SIZE"))
+
+	os.Setenv("REGISTRY_MAX_LAYER_SIZE", "1Ki")
+	os.Setenv("REGISTRY_MAX_IMAGE_SIZE", "")
+	limits, err := SizeLimitsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.MaxLayerSize != 1024 || limits.MaxImageSize != 0 {
+		t.Errorf("unexpected limits: %#v", limits)
+	}
+
+	os.Setenv("REGISTRY_MAX_IMAGE_SIZE", "lots")
+	if _, err := SizeLimitsFromEnv(); err == nil {
+		t.Errorf("expected an invalid size to be rejected")
+	}
+}
+
+func TestWithLayerSizeLimit(t *testing.T) {
+	handler := WithLayerSizeLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}), 10)
+
+	tests := []struct {
+		method, path, contentRange string
+		body                       []byte
+		expected                   int
+	}{
+		{method: "PATCH", path: "/v2/ns/app/blobs/uploads/1234", body: make([]byte, 10), expected: http.StatusOK},
+		{method: "PATCH", path: "/v2/ns/app/blobs/uploads/1234", body: make([]byte, 11), expected: http.StatusRequestEntityTooLarge},
+		{method: "PATCH", path: "/v2/ns/app/blobs/uploads/1234", contentRange: "8-11", body: make([]byte, 4), expected: http.StatusRequestEntityTooLarge},
+		{method: "PUT", path: "/v2/ns/app/blobs/uploads/1234", contentRange: "5-9", body: make([]byte, 5), expected: http.StatusOK},
+		{method: "PUT", path: "/v2/ns/app/manifests/latest", body: make([]byte, 100), expected: http.StatusOK},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.path, bytes.NewReader(test.body))
+		if len(test.contentRange) > 0 {
+			req.Header.Set("Content-Range", test.contentRange)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.expected {
+			t.Errorf("%s %s (%s): expected %d, got %d", test.method, test.path, test.contentRange, test.expected, w.Code)
+		}
+	}
+
+	// bodies of unknown length are cut off at the limit
+	req, _ := http.NewRequest("PATCH", "/v2/ns/app/blobs/uploads/1234", ioutil.NopCloser(bytes.NewReader(make([]byte, 11))))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an oversized streamed body to fail, got %d", w.Code)
+	}
+}
+
+// fakeSizedRepository stores layers of the given sizes.
+type fakeSizedRepository struct {
+	distribution.Repository
+
+	sizes map[digest.Digest]int64
+}
+
+func (r *fakeSizedRepository) Layers() distribution.LayerService {
+	return &fakeSizedLayers{sizes: r.sizes}
+}
+
+type fakeSizedLayers struct {
+	distribution.LayerService
+
+	sizes map[digest.Digest]int64
+}
+
+func (l *fakeSizedLayers) Fetch(dgst digest.Digest) (distribution.Layer, error) {
+	return &fakeSizedLayer{digest: dgst, length: l.sizes[dgst]}, nil
+}
+
+type fakeSizedLayer struct {
+	distribution.Layer
+
+	digest digest.Digest
+	length int64
+}
+
+func (l *fakeSizedLayer) Digest() digest.Digest { return l.digest }
+func (l *fakeSizedLayer) Length() int64         { return l.length }
+func (l *fakeSizedLayer) Close() error          { return nil }
+
+func TestAdmitImageSize(t *testing.T) {
+	m := &manifest.SignedManifest{Manifest: manifest.Manifest{
+		FSLayers: []manifest.FSLayer{{BlobSum: testDigest1}, {BlobSum: testDigest2}, {BlobSum: testDigest1}},
+	}}
+	repo := &fakeSizedRepository{sizes: map[digest.Digest]int64{testDigest1: 100, testDigest2: 50}}
+
+	tests := []struct {
+		limits   SizeLimits
+		admitted bool
+	}{
+		{limits: SizeLimits{}, admitted: true},
+		{limits: SizeLimits{MaxLayerSize: 100, MaxImageSize: 150}, admitted: true},
+		{limits: SizeLimits{MaxLayerSize: 99}},
+		{limits: SizeLimits{MaxImageSize: 149}},
+	}
+	for _, test := range tests {
+		r := &repository{Repository: repo, namespace: "ns", name: "app", sizeLimits: test.limits}
+		layers, err := r.imageLayers(context.Background(), m)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(layers) != 2 || layers[0].Name != testDigest1 || layers[0].Size != 100 || imageSize(layers) != 150 {
+			t.Fatalf("unexpected layers %#v", layers)
+		}
+		err = r.admitImageSize(layers)
+		if test.admitted && err != nil {
+			t.Errorf("%#v: unexpected error: %v", test.limits, err)
+		}
+		if !test.admitted && !IsQuotaExceeded(err) {
+			t.Errorf("%#v: expected the image to be rejected, got %v", test.limits, err)
+		}
+	}
+}
+
+func TestImageLayersAreLocal(t *testing.T) {
+	// the layer is referenced by a remote image tagged into the image stream
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{
+					Image:                testDigest1,
+					DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: testDigest1},
+		DockerImageReference: "docker.io/library/busybox@" + testDigest1,
+		DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	remote := &fakeRemoteRegistry{}
+	r := &repository{
+		Repository:        &fakeLocalRepository{},
+		registryClient:    client,
+		registryAddr:      "registry:5000",
+		namespace:         "ns",
+		name:              "app",
+		registryConnector: func(dockerregistry.Credentials) dockerregistry.Client { return remote },
+	}
+
+	m := &manifest.SignedManifest{Manifest: manifest.Manifest{
+		FSLayers: []manifest.FSLayer{{BlobSum: testLayerDigest}},
+	}}
+	if _, err := r.imageLayers(context.Background(), m); err == nil {
+		t.Errorf("expected a layer of unknown size missing from the repository to fail")
+	}
+
+	// the size recorded by the import of the remote image is used
+	image.Annotations = map[string]string{imageapi.ImageLayersAnnotation: `[{"name":"` + testLayerDigest + `","size":5}]`}
+	client, _ = testclient.NewImageTrackerFake(stream, image)
+	r.registryClient = client
+	layers, err := r.imageLayers(context.Background(), m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(layers) != 1 || layers[0].Name != testLayerDigest || layers[0].Size != 5 {
+		t.Errorf("unexpected layers %#v", layers)
+	}
+	if len(remote.repository) > 0 {
+		t.Errorf("expected the layer not to be pulled through from %s", remote.repository)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/handlers"
+	gorillahandlers "github.com/gorilla/handlers"
+	"golang.org/x/net/context"
+)
+
+// TagsPathPrefix is prepended to the path of tag listings with the "n" or
+// "last" parameters, so that they are dispatched to TagsDispatcher, as the
+// upstream tags handler always lists all tags.
+const TagsPathPrefix = "/openshift/tags"
+
+// WithTagsPagination routes GET /v2/<name>/tags/list with the "n" or "last"
+// parameters below TagsPathPrefix and passes all other requests through
+// unchanged.
+func WithTagsPagination(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPaginatedTagsRequest(r) {
+			tagsURL := *r.URL
+			tagsURL.Path = TagsPathPrefix + r.URL.Path
+			tagsRequest := *r
+			tagsRequest.URL = &tagsURL
+			r = &tagsRequest
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isPaginatedTagsRequest(r *http.Request) bool {
+	if r.Method != "GET" || !strings.HasPrefix(r.URL.Path, "/v2/") || !strings.HasSuffix(r.URL.Path, "/tags/list") {
+		return false
+	}
+	query := r.URL.Query()
+	return len(query.Get("n")) > 0 || len(query.Get("last")) > 0
+}
+
+// TagsDispatcher takes the request context and builds the appropriate handler
+// for handling paginated tag listings.
+func TagsDispatcher(ctx *handlers.Context, r *http.Request) http.Handler {
+	tagsHandler := &tagsHandler{
+		Context: ctx,
+	}
+
+	return gorillahandlers.MethodHandler{
+		"GET": http.HandlerFunc(tagsHandler.GetTags),
+	}
+}
+
+// tagsPager is a repository that lists its tags a page at a time.
+type tagsPager interface {
+	TagsPage(ctx context.Context, limit int, last string) ([]string, bool, error)
+}
+
+// tagsHandler handles paginated tag listings.
+type tagsHandler struct {
+	*handlers.Context
+}
+
+type tagsAPIResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// GetTags lists the tags of the image stream in lexical order, like the
+// upstream handler. The number of entries is limited by the "n" parameter,
+// listing starts after the "last" parameter. A Link header points to the next
+// page, if any.
+func (th *tagsHandler) GetTags(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+	last := query.Get("last")
+	limit := -1
+	if n := query.Get("n"); len(n) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			th.Errors.PushErr(fmt.Errorf("invalid number of entries %q", n))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	r, ok := th.Repository.(tagsPager)
+	if !ok {
+		th.Errors.PushErr(fmt.Errorf("unexpected repository %T", th.Repository))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	page, more, err := r.TagsPage(th, limit, last)
+	if err != nil {
+		if _, unknown := err.(distribution.ErrRepositoryUnknown); unknown {
+			th.Errors.Push(v2.ErrorCodeNameUnknown, map[string]string{"name": th.Repository.Name()})
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		th.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if more {
+		next := url.Values{}
+		next.Set("last", page[len(page)-1])
+		next.Set("n", strconv.Itoa(limit))
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", strings.TrimPrefix(req.URL.Path, TagsPathPrefix), next.Encode()))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(tagsAPIResponse{Name: th.Repository.Name(), Tags: page}); err != nil {
+		th.Errors.PushErr(err)
+		return
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/registry/handlers"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestWithTagsPagination(t *testing.T) {
+	tests := map[string]struct {
+		url          string
+		expectedPath string
+	}{
+		"first page": {
+			url:          "/v2/ns/is/tags/list?n=2",
+			expectedPath: TagsPathPrefix + "/v2/ns/is/tags/list",
+		},
+		"next page": {
+			url:          "/v2/ns/is/tags/list?n=2&last=v1",
+			expectedPath: TagsPathPrefix + "/v2/ns/is/tags/list",
+		},
+		"all tags": {
+			url:          "/v2/ns/is/tags/list",
+			expectedPath: "/v2/ns/is/tags/list",
+		},
+		"manifest": {
+			url:          "/v2/ns/is/manifests/latest?n=2",
+			expectedPath: "/v2/ns/is/manifests/latest",
+		},
+	}
+
+	for name, test := range tests {
+		var path string
+		handler := WithTagsPagination(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+		}))
+		req, _ := http.NewRequest("GET", test.url, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if path != test.expectedPath {
+			t.Errorf("%s: expected path %q, got %q", name, test.expectedPath, path)
+		}
+	}
+}
+
+func TestGetTags(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"v2":     {Items: []imageapi.TagEvent{{Image: testDigest2}}},
+				"latest": {Items: []imageapi.TagEvent{{Image: testDigest2}}},
+				"v1":     {Items: []imageapi.TagEvent{{Image: testDigest1}}},
+			},
+		},
+	}
+	client, _ := testclient.NewImageTrackerFake(stream)
+	r := &repository{
+		Repository:     &fakeLocalRepository{name: "ns/app"},
+		registryClient: client,
+		namespace:      "ns",
+		name:           "app",
+	}
+	ctx := &handlers.Context{Context: context.Background(), Repository: r}
+
+	req, _ := http.NewRequest("GET", TagsPathPrefix+"/v2/ns/app/tags/list?n=2", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	TagsDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", w.Code, ctx.Errors)
+	}
+	var response tagsAPIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Name != "ns/app" || !reflect.DeepEqual(response.Tags, []string{"latest", "v1"}) {
+		t.Errorf("unexpected first page %#v", response)
+	}
+	if link := w.Header().Get("Link"); link != `</v2/ns/app/tags/list?last=v1&n=2>; rel="next"` {
+		t.Errorf("unexpected link %q", link)
+	}
+
+	req, _ = http.NewRequest("GET", TagsPathPrefix+"/v2/ns/app/tags/list?n=2&last=v1", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	TagsDispatcher(ctx, req).ServeHTTP(w, req)
+	response = tagsAPIResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(response.Tags, []string{"v2"}) || len(w.Header().Get("Link")) != 0 {
+		t.Errorf("unexpected last page %v, link %q", response.Tags, w.Header().Get("Link"))
+	}
+
+	req, _ = http.NewRequest("GET", TagsPathPrefix+"/v2/ns/app/tags/list?n=-1", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	TagsDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid number of entries, got %d", w.Code)
+	}
+}
+
+func TestTagsUnknownRepository(t *testing.T) {
+	client, _ := testclient.NewImageTrackerFake()
+	r := &repository{
+		Repository:     &fakeLocalRepository{name: "ns/missing"},
+		registryClient: client,
+		namespace:      "ns",
+		name:           "missing",
+	}
+
+	_, err := r.Tags(context.Background())
+	if _, unknown := err.(distribution.ErrRepositoryUnknown); !unknown {
+		t.Errorf("expected an unknown repository, got %v", err)
+	}
+
+	ctx := &handlers.Context{Context: context.Background(), Repository: r}
+	req, _ := http.NewRequest("GET", TagsPathPrefix+"/v2/ns/missing/tags/list?n=2", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	TagsDispatcher(ctx, req).ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || len(ctx.Errors.Errors) != 1 {
+		t.Errorf("expected 404 with NAME_UNKNOWN, got %d: %v", w.Code, ctx.Errors)
+	}
+}
@@ -0,0 +1,502 @@
+package server
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	ctxu "github.com/docker/distribution/context"
+	registryauth "github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/token"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+
+	"github.com/openshift/origin/pkg/client"
+	userapi "github.com/openshift/origin/pkg/user/api"
+)
+
+// TokenPath is the path of the token endpoint. It's meant to be used as the
+// realm of the registry's token authentication.
+const TokenPath = "/openshift/token"
+
+// TokenAccessControllerName is the name of the access controller verifying
+// the registry tokens issued by TokenHandler. The registry uses it in token
+// authentication mode instead of the upstream access controller.
+const TokenAccessControllerName = "openshift-token"
+
+// defaultTokenExpiration is the lifetime of the issued registry tokens.
+const defaultTokenExpiration = 5 * time.Minute
+
+func init() {
+	registryauth.Register(TokenAccessControllerName, registryauth.InitFunc(newTokenAccessController))
+}
+
+// tokenCredentials opens the OpenShift tokens carried by the registry tokens,
+// nil unless the registry is in token authentication mode.
+var tokenCredentials *credentialSealer
+
+// tokenClaimSet is the claim set of the registry tokens issued by
+// TokenHandler. Besides the standard claims, it carries the OpenShift token
+// of the user, so that the registry can act as the user like it does in basic
+// authentication mode. The OpenShift token is encrypted, see
+// credentialSealer.
+type tokenClaimSet struct {
+	token.ClaimSet
+	Credential string `json:"openshift.io/credential,omitempty"`
+}
+
+// TokenHandler implements the Docker registry token authentication
+// specification. It exchanges OpenShift bearer tokens, passed as the password
+// of basic authentication, for registry tokens granting those of the
+// requested actions the OpenShift user is allowed to perform.
+//
+// The tokens also carry the OpenShift token of the user, encrypted, so that
+// the registry acts as the user like in basic authentication mode.
+//
+// The registry's token authentication is configured with the same issuer and
+// service, and with a root certificate bundle containing the certificate of
+// the signing key:
+//
+//	auth:
+//	  token:
+//	    realm: https://<registry>/openshift/token
+//	    issuer: <issuer>
+//	    service: <service>
+//	    rootcertbundle: <certificate of the signing key>
+//	    signingkey: <signing key>
+type TokenHandler struct {
+	issuer     string
+	service    string
+	signingKey libtrust.PrivateKey
+	// credentials encrypts the OpenShift tokens carried by the registry
+	// tokens.
+	credentials *credentialSealer
+	expiration  time.Duration
+	apiTimeout  time.Duration
+}
+
+// NewTokenHandler returns a TokenHandler configured from the parameters of
+// the registry's token authentication.
+func NewTokenHandler(options map[string]interface{}) (*TokenHandler, error) {
+	values := map[string]string{}
+	for _, key := range []string{"issuer", "service", "signingkey"} {
+		value, ok := options[key].(string)
+		if !ok || len(value) == 0 {
+			return nil, fmt.Errorf("token endpoint requires a valid option string: %q", key)
+		}
+		values[key] = value
+	}
+
+	signingKey, err := libtrust.LoadKeyFile(values["signingkey"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to load token signing key %q: %v", values["signingkey"], err)
+	}
+
+	credentials, err := newCredentialSealer(signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	apiTimeout, err := apiTimeoutFrom(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenHandler{
+		issuer:      values["issuer"],
+		service:     values["service"],
+		signingKey:  signingKey,
+		credentials: credentials,
+		expiration:  defaultTokenExpiration,
+		apiTimeout:  apiTimeout,
+	}, nil
+}
+
+// ServeHTTP issues a registry token for the scopes of the request.
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	if service := query.Get("service"); len(service) > 0 && service != h.service {
+		writeTokenError(w, http.StatusBadRequest, fmt.Errorf("unknown service %q", service))
+		return
+	}
+
+	bearerToken, err := getToken(req)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", TokenPath))
+		writeTokenError(w, http.StatusUnauthorized, err)
+		return
+	}
+	client, err := NewUserOpenShiftClient(bearerToken)
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx := context.Background()
+	user, err := fetchWithDeadline(ctx, h.apiTimeout, "get user", func(ctx context.Context) (interface{}, error) {
+		return client.Users().Get("~")
+	})
+	if err != nil {
+		log.Errorf("Get user failed with error: %s", err)
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", TokenPath))
+		writeTokenError(w, http.StatusUnauthorized, ErrOpenShiftAccessDenied)
+		return
+	}
+	subject := user.(*userapi.User).Name
+
+	var access []*token.ResourceActions
+	for _, scope := range query["scope"] {
+		requested, err := parseScope(scope)
+		if err != nil {
+			writeTokenError(w, http.StatusBadRequest, err)
+			return
+		}
+		if granted := h.grantedActions(ctx, client, requested); len(granted.Actions) > 0 {
+			access = append(access, granted)
+		}
+	}
+
+	rawToken, err := h.issue(subject, bearerToken, access)
+	if err != nil {
+		log.Errorf("Error issuing registry token for %s: %v", subject, err)
+		writeTokenError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": rawToken})
+}
+
+// parseScope parses a scope of the form <type>:<name>:<action>[,<action>...].
+// The name may itself contain colons.
+func parseScope(scope string) (*token.ResourceActions, error) {
+	first := strings.Index(scope, ":")
+	last := strings.LastIndex(scope, ":")
+	if first < 0 || first == last {
+		return nil, fmt.Errorf("invalid scope %q", scope)
+	}
+	return &token.ResourceActions{
+		Type:    scope[:first],
+		Name:    scope[first+1 : last],
+		Actions: strings.Split(scope[last+1:], ","),
+	}, nil
+}
+
+// grantedActions returns the subset of the requested actions the user is
+// allowed to perform. Denied and unknown actions are left out, as mandated by
+// the specification.
+func (h *TokenHandler) grantedActions(ctx context.Context, client *client.Client, requested *token.ResourceActions) *token.ResourceActions {
+	granted := &token.ResourceActions{Type: requested.Type, Name: requested.Name, Actions: []string{}}
+
+	for _, action := range requested.Actions {
+		var verify func(context.Context) error
+		switch requested.Type {
+		case "repository":
+			namespace, name, err := getNamespaceName(requested.Name)
+			if err != nil {
+				return granted
+			}
+			switch action {
+			case "pull":
+				verify = func(ctx context.Context) error { return verifyImageStreamAccess(ctx, namespace, name, "get", client) }
+			case "push":
+				verify = func(ctx context.Context) error { return verifyImageStreamAccess(ctx, namespace, name, "update", client) }
+			case "*":
+				verify = func(ctx context.Context) error { return verifyPruneAccess(ctx, client) }
+			}
+		case "admin":
+			switch action {
+			case "prune":
+				verify = func(ctx context.Context) error { return verifyPruneAccess(ctx, client) }
+			case "metrics":
+				verify = func(ctx context.Context) error { return verifyMetricsAccess(ctx, client) }
+			}
+		}
+		if verify == nil {
+			continue
+		}
+
+		if err := withDeadline(ctx, h.apiTimeout, "create SubjectAccessReview", verify); err != nil {
+			log.Debugf("Not granting %s:%s:%s: %v", requested.Type, requested.Name, action, err)
+			continue
+		}
+		granted.Actions = append(granted.Actions, action)
+	}
+	return granted
+}
+
+// issue returns a signed registry token for subject, the owner of
+// bearerToken, granting access.
+func (h *TokenHandler) issue(subject, bearerToken string, access []*token.ResourceActions) (string, error) {
+	alg, err := signingAlgorithm(h.signingKey)
+	if err != nil {
+		return "", err
+	}
+
+	credential, err := h.credentials.seal(bearerToken)
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 15)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header := token.Header{
+		Type:       "JWT",
+		SigningAlg: alg,
+		KeyID:      h.signingKey.KeyID(),
+	}
+	claims := tokenClaimSet{
+		ClaimSet: token.ClaimSet{
+			Issuer:     h.issuer,
+			Subject:    subject,
+			Audience:   h.service,
+			Expiration: now.Add(h.expiration).Unix(),
+			NotBefore:  now.Unix(),
+			IssuedAt:   now.Unix(),
+			JWTID:      base64.URLEncoding.EncodeToString(jti),
+			Access:     access,
+		},
+		Credential: credential,
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	payload := joseBase64URLEncode(headerJSON) + token.TokenSeparator + joseBase64URLEncode(claimsJSON)
+	signature, _, err := h.signingKey.Sign(strings.NewReader(payload), crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return payload + token.TokenSeparator + joseBase64URLEncode(signature), nil
+}
+
+// signingAlgorithm returns the JWS algorithm key signs with.
+func signingAlgorithm(key libtrust.PrivateKey) (string, error) {
+	switch k := key.CryptoPrivateKey().(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	}
+	return "", errors.New("unsupported token signing key")
+}
+
+// joseBase64URLEncode encodes b without padding, as required by JOSE.
+func joseBase64URLEncode(b []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
+
+// joseBase64URLDecode decodes s, whose padding is stripped as required by
+// JOSE.
+func joseBase64URLDecode(s string) ([]byte, error) {
+	if n := len(s) % 4; n > 0 {
+		s += strings.Repeat("=", 4-n)
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
+
+// parseTokenClaims returns the claims of rawToken. The signature of the token
+// isn't verified.
+func parseTokenClaims(rawToken string) (*tokenClaimSet, error) {
+	parts := strings.Split(rawToken, token.TokenSeparator)
+	if len(parts) != 3 {
+		return nil, errors.New("malformed registry token")
+	}
+	claimsJSON, err := joseBase64URLDecode(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed registry token claims: %v", err)
+	}
+	claims := &tokenClaimSet{}
+	if err := json.Unmarshal(claimsJSON, claims); err != nil {
+		return nil, fmt.Errorf("malformed registry token claims: %v", err)
+	}
+	return claims, nil
+}
+
+// credentialSealer encrypts the OpenShift tokens carried by the registry
+// tokens with a key derived from the signing key, which every replica of the
+// registry shares. The registry tokens are only signed, the OpenShift tokens
+// they carry would otherwise be readable by anyone getting hold of them, and
+// the encryption authenticates the OpenShift tokens as ones the registry
+// verified.
+type credentialSealer struct {
+	aead cipher.AEAD
+}
+
+func newCredentialSealer(signingKey libtrust.PrivateKey) (*credentialSealer, error) {
+	block, err := signingKey.PEMBlock()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(append([]byte("openshift registry token credential\x00"), block.Bytes...))
+	c, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+	return &credentialSealer{aead: aead}, nil
+}
+
+// seal returns credential encrypted.
+func (s *credentialSealer) seal(credential string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return joseBase64URLEncode(s.aead.Seal(nonce, nonce, []byte(credential), nil)), nil
+}
+
+// open returns the credential sealed by seal.
+func (s *credentialSealer) open(sealed string) (string, error) {
+	b, err := joseBase64URLDecode(sealed)
+	if err != nil || len(b) < s.aead.NonceSize() {
+		return "", errors.New("malformed registry token credential")
+	}
+	credential, err := s.aead.Open(nil, b[:s.aead.NonceSize()], b[s.aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("invalid registry token credential")
+	}
+	return string(credential), nil
+}
+
+// tokenAccessController verifies the registry tokens issued by TokenHandler
+// with the upstream token access controller, and provides the repository
+// middleware with a client acting as the user the token was issued to, like
+// AccessController does in basic authentication mode.
+type tokenAccessController struct {
+	registryauth.AccessController
+
+	realm       string
+	service     string
+	credentials *credentialSealer
+	users       *userCache
+}
+
+// newTokenAccessController returns the access controller configured by the
+// options of the upstream token access controller, which must include the
+// signing key of TokenHandler.
+func newTokenAccessController(options map[string]interface{}) (registryauth.AccessController, error) {
+	verifier, err := registryauth.GetAccessController("token", options)
+	if err != nil {
+		return nil, err
+	}
+	signingKeyFile, ok := options["signingkey"].(string)
+	if !ok || len(signingKeyFile) == 0 {
+		return nil, fmt.Errorf("token auth requires a valid option string: %q", "signingkey")
+	}
+	signingKey, err := libtrust.LoadKeyFile(signingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load token signing key %q: %v", signingKeyFile, err)
+	}
+	credentials, err := newCredentialSealer(signingKey)
+	if err != nil {
+		return nil, err
+	}
+	tokenCredentials = credentials
+
+	realm, _ := options["realm"].(string)
+	service, _ := options["service"].(string)
+	return &tokenAccessController{
+		AccessController: verifier,
+		realm:            realm,
+		service:          service,
+		credentials:      credentials,
+		users:            authenticatedUsers,
+	}, nil
+}
+
+// Authorized verifies the registry token of the request, then returns a
+// context holding the user the token was issued to and their client. The
+// probes of isHealthCheck don't need a token.
+func (ac *tokenAccessController) Authorized(ctx context.Context, accessRecords ...registryauth.Access) (context.Context, error) {
+	req, err := ctxu.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if isHealthCheck(req) {
+		return ctx, nil
+	}
+	ctx, err = ac.AccessController.Authorized(ctx, accessRecords...)
+	if err != nil {
+		return nil, err
+	}
+	rawToken := strings.SplitN(req.Header.Get("Authorization"), " ", 2)[1]
+
+	claims, err := parseTokenClaims(rawToken)
+	if err != nil {
+		return nil, &tokenChallenge{realm: ac.realm, service: ac.service, err: err}
+	}
+	bearerToken, err := ac.credentials.open(claims.Credential)
+	if err != nil {
+		ctxu.GetLogger(ctx).Errorf("Error reading the credential of the registry token of %s: %v", claims.Subject, err)
+		return nil, &tokenChallenge{realm: ac.realm, service: ac.service, err: err}
+	}
+	client, err := NewUserOpenShiftClient(bearerToken)
+	if err != nil {
+		return nil, err
+	}
+	ac.users.remember(rawToken, claims.Subject)
+	ctx = registryauth.WithUser(ctx, registryauth.UserInfo{Name: claims.Subject})
+	return WithUserClient(ctx, client), nil
+}
+
+// tokenChallenge asks the client to get a new registry token.
+type tokenChallenge struct {
+	realm   string
+	service string
+	err     error
+}
+
+var _ registryauth.Challenge = &tokenChallenge{}
+
+func (c *tokenChallenge) Error() string {
+	return c.err.Error()
+}
+
+func (c *tokenChallenge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q,service=%q,error=%q", c.realm, c.service, "invalid_token"))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func writeTokenError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"details": err.Error()})
+}
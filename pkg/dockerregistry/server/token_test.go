@@ -0,0 +1,262 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/token"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/api/latest"
+	"github.com/openshift/origin/pkg/authorization/api"
+	userapi "github.com/openshift/origin/pkg/user/api"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := map[string]*token.ResourceActions{
+		"repository:foo/bar:pull,push":  {Type: "repository", Name: "foo/bar", Actions: []string{"pull", "push"}},
+		"repository:host:5000/foo:pull": {Type: "repository", Name: "host:5000/foo", Actions: []string{"pull"}},
+		"admin::prune":                  {Type: "admin", Name: "", Actions: []string{"prune"}},
+		"repository":                    nil,
+		"repository:foo/bar":            nil,
+	}
+	for scope, expected := range tests {
+		actual, err := parseScope(scope)
+		if expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected error, got %#v", scope, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", scope, err)
+			continue
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %#v, got %#v", scope, expected, actual)
+		}
+	}
+}
+
+func TestTokenHandler(t *testing.T) {
+	signingKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := newCredentialSealer(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &TokenHandler{
+		issuer:      "openshift",
+		service:     "registry",
+		signingKey:  signingKey,
+		credentials: credentials,
+		expiration:  defaultTokenExpiration,
+	}
+
+	// pull is allowed, push is denied
+	server, actions := simulateOpenShiftMaster([]response{
+		{200, runtime.EncodeOrDie(latest.Codec, &userapi.User{ObjectMeta: kapi.ObjectMeta{Name: "usr1"}})},
+		{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Namespace: "foo", Allowed: true, Reason: "authorized!"})},
+		{200, runtime.EncodeOrDie(latest.Codec, &api.SubjectAccessReviewResponse{Namespace: "foo", Allowed: false, Reason: "not authorized!"})},
+	})
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", TokenPath+"?service=registry&scope=repository:foo/bar:pull,push", nil)
+	req.SetBasicAuth("usr1", "awesome")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	expectedActions := []string{
+		"GET /oapi/v1/users/~",
+		"POST /oapi/v1/namespaces/foo/localsubjectaccessreviews",
+		"POST /oapi/v1/namespaces/foo/localsubjectaccessreviews",
+	}
+	if !reflect.DeepEqual(expectedActions, *actions) {
+		t.Errorf("expected actions %v, got %v", expectedActions, *actions)
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	issued, err := token.NewToken(body.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = issued.Verify(token.VerifyOptions{
+		TrustedIssuers:    []string{"openshift"},
+		AcceptedAudiences: []string{"registry"},
+		TrustedKeys:       map[string]libtrust.PublicKey{signingKey.KeyID(): signingKey.PublicKey()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error verifying token: %v", err)
+	}
+	if issued.Claims.Subject != "usr1" {
+		t.Errorf("expected subject usr1, got %q", issued.Claims.Subject)
+	}
+	expectedAccess := []*token.ResourceActions{{Type: "repository", Name: "foo/bar", Actions: []string{"pull"}}}
+	if !reflect.DeepEqual(expectedAccess, issued.Claims.Access) {
+		t.Errorf("expected access %#v, got %#v", expectedAccess[0], issued.Claims.Access)
+	}
+
+	// the token carries the OpenShift token of the user, encrypted
+	claims, err := parseTokenClaims(body.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Credential == "awesome" {
+		t.Errorf("expected the OpenShift token to be encrypted")
+	}
+	if credential, err := credentials.open(claims.Credential); err != nil || credential != "awesome" {
+		t.Errorf("expected the OpenShift token of the user, got %q, %v", credential, err)
+	}
+}
+
+func TestTokenAccessController(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	signingKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := libtrust.SaveKey(keyFile, signingKey); err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "registry"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signingKey.CryptoPublicKey(), signingKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	options := map[string]interface{}{
+		"realm":          TokenPath,
+		"issuer":         "openshift",
+		"service":        "registry",
+		"rootcertbundle": certFile,
+		"signingkey":     keyFile,
+	}
+	defer func(saved *credentialSealer) { tokenCredentials = saved }(tokenCredentials)
+	accessController, err := newTokenAccessController(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := newUserCache(time.Minute, 10)
+	accessController.(*tokenAccessController).users = users
+	handler, err := NewTokenHandler(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, _ := simulateOpenShiftMaster(nil)
+	defer server.Close()
+
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "foo/bar"}, Action: "pull"}
+	rawToken, err := handler.issue("usr1", "awesome", []*token.ResourceActions{{Type: "repository", Name: "foo/bar", Actions: []string{"pull"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "/v2/foo/bar/manifests/latest", nil)
+	req.Header.Set("Authorization", "Bearer "+rawToken)
+	authCtx, err := accessController.Authorized(context.WithValue(context.Background(), "http.request", req), access)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := UserClientFrom(authCtx); !ok {
+		t.Errorf("expected the client of the user in the context")
+	}
+	if user, _ := users.requestUser(req); user != "usr1" {
+		t.Errorf("expected the token to be remembered as owned by usr1, got %q", user)
+	}
+	if name := ctxu.GetStringValue(authCtx, "auth.user.name"); name != "usr1" {
+		t.Errorf("expected the context to hold the user usr1, got %q", name)
+	}
+	if userClient, err := requestUserClient(req); err != nil || userClient == nil {
+		t.Errorf("expected the client of the user for the request, got %v", err)
+	}
+
+	// the probes don't present a token
+	for _, path := range []string{"/healthz", "/readyz"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		if _, err := accessController.Authorized(context.WithValue(context.Background(), "http.request", req)); err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+		}
+	}
+
+	// tokens whose OpenShift token the registry didn't encrypt are refused
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handler.credentials, err = newCredentialSealer(otherKey); err != nil {
+		t.Fatal(err)
+	}
+	rawToken, err = handler.issue("usr1", "awesome", []*token.ResourceActions{{Type: "repository", Name: "foo/bar", Actions: []string{"pull"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+rawToken)
+	_, err = accessController.Authorized(context.WithValue(context.Background(), "http.request", req), access)
+	if _, challenge := err.(auth.Challenge); !challenge {
+		t.Errorf("expected a challenge, got %v", err)
+	}
+}
+
+func TestTokenHandlerRequiresCredentials(t *testing.T) {
+	handler := &TokenHandler{issuer: "openshift", service: "registry"}
+
+	req, _ := http.NewRequest("GET", TokenPath+"?service=registry&scope=repository:foo/bar:pull", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if len(w.Header().Get("WWW-Authenticate")) == 0 {
+		t.Errorf("expected a basic authentication challenge")
+	}
+
+	req, _ = http.NewRequest("GET", TokenPath+"?service=other", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an unknown service, got %d", http.StatusBadRequest, w.Code)
+	}
+}
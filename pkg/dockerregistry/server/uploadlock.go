@@ -0,0 +1,307 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// defaultUploadLockTTL is how long a lock whose holder stopped refreshing
+	// it, e.g. because its replica was killed, is honored.
+	defaultUploadLockTTL = time.Minute
+	// uploadLockWait is how long a request waits for the upload to be
+	// released by the request of another replica, e.g. a PATCH retried by
+	// the client while the first one is still being written.
+	uploadLockWait = 30 * time.Second
+	// uploadLockPoll is the interval at which a held lock is retried.
+	uploadLockPoll = 100 * time.Millisecond
+	// uploadLockRetryAfter is how long clients are asked to wait before
+	// retrying a request whose upload stayed locked.
+	uploadLockRetryAfter = 5 * time.Second
+
+	// gcLockName is the lock held while the storage is pruned. Upload uuids
+	// can't start with a dot, so it can't be the lock of an upload.
+	gcLockName = ".gc"
+	// writeLockPrefix prefixes the locks of the writes which don't belong to
+	// an upload session, e.g. the mount of a blob or the put of a manifest.
+	writeLockPrefix = ".write-"
+)
+
+var (
+	// errUploadLocked is returned when the upload stayed locked by another
+	// request.
+	errUploadLocked = errors.New("the upload is in use by another request")
+	// errStoragePruned is returned when a write waited for the pruning of
+	// the storage for longer than the lock wait.
+	errStoragePruned = errors.New("the storage is being pruned")
+	// errPruneRunning is returned when the storage is being pruned already.
+	errPruneRunning = errors.New("the storage is being pruned already")
+)
+
+// storageLocks are the locks of the registry, set by WithUploadLocks. The
+// pruning of the storage holds its lock so that it doesn't delete the blobs
+// and the links written meanwhile.
+var storageLocks *uploadLocks
+
+// uploadLocks serializes the requests writing to the same upload session,
+// across the replicas of the registry sharing a filesystem, with lock files
+// created exclusively in dir. The session state itself is in the storage, so
+// that any replica can resume it, the lock keeps two replicas from writing it
+// at the same time. The other writes to the storage hold a lock of their own,
+// and all of them wait while the storage is pruned, see lockGC.
+type uploadLocks struct {
+	dir  string
+	ttl  time.Duration
+	wait time.Duration
+}
+
+// UploadLockDir returns the directory of the upload locks of the filesystem
+// storage rooted at rootDirectory.
+func UploadLockDir(rootDirectory string) string {
+	return filepath.Join(rootDirectory, "docker", "registry", "v2", "openshift", "uploadlocks")
+}
+
+// WithUploadLocks serializes the PATCH, PUT and DELETE requests for the same
+// upload session, with lock files in dir, and holds the writes to the
+// repositories while the storage is pruned. Requests waiting longer than the
+// lock wait are rejected with 503 Service Unavailable, so that the client
+// retries them.
+func WithUploadLocks(handler http.Handler, dir string) http.Handler {
+	locks := &uploadLocks{dir: dir, ttl: defaultUploadLockTTL, wait: uploadLockWait}
+	storageLocks = locks
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := uploadSessionOf(r)
+		if !ok {
+			if !isRepositoryWrite(r) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			name = writeLockPrefix + randomLockSuffix()
+		}
+		unlock, err := locks.lockWrite(name)
+		if err == errUploadLocked || err == errStoragePruned {
+			writeUnavailable(w, uploadLockRetryAfter, err.Error())
+			return
+		}
+		if err != nil {
+			log.Errorf("Error locking %s: %v", r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer unlock()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// isRepositoryWrite returns true if r may write a blob or a link to a
+// repository, i.e. for POST, PUT, PATCH and DELETE /v2/<name>/...
+func isRepositoryWrite(r *http.Request) bool {
+	switch r.Method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return strings.HasPrefix(r.URL.Path, "/v2/")
+	}
+	return false
+}
+
+func randomLockSuffix() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// uploadSessionOf returns the uuid of the upload session r writes to, i.e. of
+// PATCH, PUT and DELETE /v2/<name>/blobs/uploads/<uuid>.
+func uploadSessionOf(r *http.Request) (string, bool) {
+	switch r.Method {
+	case "PATCH", "PUT", "DELETE":
+	default:
+		return "", false
+	}
+	if !strings.HasPrefix(r.URL.Path, "/v2/") {
+		return "", false
+	}
+	i := strings.LastIndex(r.URL.Path, "/blobs/uploads/")
+	if i < 0 {
+		return "", false
+	}
+	uuid := r.URL.Path[i+len("/blobs/uploads/"):]
+	if len(uuid) == 0 || strings.Contains(uuid, "/") || strings.HasPrefix(uuid, ".") {
+		return "", false
+	}
+	return uuid, true
+}
+
+// lock waits for the lock of the upload uuid and returns the function
+// releasing it. The lock is refreshed while it's held. A lock not refreshed
+// within the TTL is taken over.
+func (l *uploadLocks) lock(uuid string) (func(), error) {
+	return l.lockUntil(uuid, time.Now().Add(l.wait))
+}
+
+// lockWrite waits for the lock name, and for the storage not to be pruned,
+// and returns the function releasing the lock. The lock is taken before the
+// pruning is checked for, so that lockGC waits for it if the pruning starts
+// meanwhile.
+func (l *uploadLocks) lockWrite(name string) (func(), error) {
+	deadline := time.Now().Add(l.wait)
+	for {
+		unlock, err := l.lockUntil(name, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if !l.held(gcLockName) {
+			return unlock, nil
+		}
+		unlock()
+		if time.Now().After(deadline) {
+			return nil, errStoragePruned
+		}
+		time.Sleep(uploadLockPoll)
+	}
+}
+
+// lockGC takes the lock of the pruning of the storage and returns the function
+// releasing it, or errPruneRunning if it's held already. The writes starting
+// once it's taken wait for it to be released, the caller must wait for the
+// ones in progress with waitForWrites.
+func (l *uploadLocks) lockGC() (func(), error) {
+	unlock, err := l.lockUntil(gcLockName, time.Now())
+	if err == errUploadLocked {
+		return nil, errPruneRunning
+	}
+	return unlock, err
+}
+
+// waitForWrites waits until no lock but the one of the pruning is held.
+func (l *uploadLocks) waitForWrites() error {
+	for {
+		infos, err := ioutil.ReadDir(l.dir)
+		if err != nil {
+			return err
+		}
+		writing := false
+		for _, info := range infos {
+			if info.Name() != gcLockName && time.Since(info.ModTime()) <= l.ttl {
+				writing = true
+				break
+			}
+		}
+		if !writing {
+			return nil
+		}
+		time.Sleep(uploadLockPoll)
+	}
+}
+
+// held returns true if the lock name is held and refreshed.
+func (l *uploadLocks) held(name string) bool {
+	info, err := os.Stat(filepath.Join(l.dir, name))
+	return err == nil && time.Since(info.ModTime()) <= l.ttl
+}
+
+// lockUntil takes the lock name, waiting for it until deadline. The lock file
+// holds a token of its holder, so that a holder whose lock was taken over as
+// stale doesn't refresh or release the lock of the replica that took it over.
+func (l *uploadLocks) lockUntil(name string, deadline time.Time) (func(), error) {
+	path := filepath.Join(l.dir, name)
+	hostname, _ := os.Hostname()
+	token := fmt.Sprintf("%s %d %s\n", hostname, os.Getpid(), randomLockSuffix())
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.WriteString(token)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return l.hold(path, token), nil
+		}
+		switch {
+		case os.IsNotExist(err):
+			if err := os.MkdirAll(l.dir, 0700); err != nil {
+				return nil, err
+			}
+			continue
+		case !os.IsExist(err):
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > l.ttl {
+			if stale, err := ioutil.ReadFile(path); err == nil {
+				log.Warnf("Taking over the stale lock %s", name)
+				removeLock(path, string(stale))
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errUploadLocked
+		}
+		time.Sleep(uploadLockPoll)
+	}
+}
+
+// ownsLock returns true if the lock at path holds token.
+func ownsLock(path, token string) bool {
+	content, err := ioutil.ReadFile(path)
+	return err == nil && string(content) == token
+}
+
+// removeLock removes the lock at path if it still holds token, and returns
+// false if it holds another one, i.e. if it was taken by someone else.
+func removeLock(path, token string) (bool, error) {
+	if !ownsLock(path, token) {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return true, err
+	}
+	return true, nil
+}
+
+// hold refreshes the lock at path, as long as it holds token, until the
+// returned function releases it.
+func (l *uploadLocks) hold(path, token string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !ownsLock(path, token) {
+					log.Warnf("The upload lock %s was taken over, not refreshing it anymore", path)
+					return
+				}
+				now := time.Now()
+				if err := os.Chtimes(path, now, now); err != nil {
+					log.Warnf("Error refreshing the upload lock %s: %v", path, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		owned, err := removeLock(path, token)
+		switch {
+		case err != nil:
+			log.Warnf("Error releasing the upload lock %s: %v", path, err)
+		case !owned:
+			log.Warnf("The upload lock %s was taken over before it was released", path)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUploadSessionOf(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{method: "PATCH", path: "/v2/ns/is/blobs/uploads/1234", expected: "1234"},
+		{method: "PUT", path: "/v2/ns/is/blobs/uploads/1234", expected: "1234"},
+		{method: "DELETE", path: "/v2/ns/is/blobs/uploads/1234", expected: "1234"},
+		{method: "GET", path: "/v2/ns/is/blobs/uploads/1234"},
+		{method: "POST", path: "/v2/ns/is/blobs/uploads/"},
+		{method: "PUT", path: "/v2/ns/is/manifests/latest"},
+		{method: "PUT", path: "/v2/ns/is/blobs/uploads/.."},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		uuid, ok := uploadSessionOf(req)
+		if uuid != test.expected || ok != (len(test.expected) > 0) {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.path, test.expected, uuid)
+		}
+	}
+}
+
+func TestUploadLocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploadlocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// two replicas sharing dir
+	first := &uploadLocks{dir: filepath.Join(dir, "locks"), ttl: time.Minute, wait: 50 * time.Millisecond}
+	second := &uploadLocks{dir: filepath.Join(dir, "locks"), ttl: time.Minute, wait: 50 * time.Millisecond}
+
+	unlock, err := first.lock("1234")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := second.lock("1234"); err != errUploadLocked {
+		t.Errorf("expected the upload to be locked, got %v", err)
+	}
+	otherUnlock, err := second.lock("5678")
+	if err != nil {
+		t.Errorf("expected another upload not to be locked, got %v", err)
+	} else {
+		otherUnlock()
+	}
+
+	unlock()
+	unlock, err = second.lock("1234")
+	if err != nil {
+		t.Fatalf("expected the released lock to be taken, got %v", err)
+	}
+
+	// a replica that died doesn't refresh its lock
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "locks", "1234"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	takenOver, err := first.lock("1234")
+	if err != nil {
+		t.Fatalf("expected the stale lock to be taken over, got %v", err)
+	}
+
+	// the replica that stopped refreshing doesn't release the lock taken over
+	unlock()
+	if _, err := second.lock("1234"); err != errUploadLocked {
+		t.Errorf("expected the lock taken over to be kept, got %v", err)
+	}
+	takenOver()
+	if _, err := os.Stat(filepath.Join(dir, "locks", "1234")); !os.IsNotExist(err) {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+}
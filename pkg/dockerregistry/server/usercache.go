@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// userCacheTTL is how long the user owning a token is trusted before the
+	// token is verified again.
+	userCacheTTL = time.Minute
+	// userCacheSize is the number of tokens whose user is remembered.
+	userCacheSize = 4096
+)
+
+// authenticatedUsers remembers the users the access controller authenticated.
+// The handlers wrapping the app, which can't verify credentials themselves,
+// tell users apart with it.
+var authenticatedUsers = newUserCache(userCacheTTL, userCacheSize)
+
+// userCache remembers the users owning the tokens of recent requests, so that
+// authenticating a user doesn't cost a call to the master per request. The
+// tokens themselves aren't kept in memory.
+type userCache struct {
+	cache *lru.Cache
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+type userCacheRecord struct {
+	name     string
+	verified time.Time
+}
+
+func newUserCache(ttl time.Duration, size int) *userCache {
+	// only fails for a size that isn't positive
+	cache, _ := lru.New(size)
+	return &userCache{cache: cache, ttl: ttl, now: time.Now}
+}
+
+func userCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify returns the user owning token, remembered if the token was verified
+// less than the TTL ago, or else returned by get, which verifies the token.
+func (c *userCache) verify(token string, get func() (string, error)) (string, error) {
+	key := userCacheKey(token)
+	if value, ok := c.cache.Get(key); ok {
+		record := value.(*userCacheRecord)
+		if record.verified.Add(c.ttl).After(c.now()) {
+			return record.name, nil
+		}
+	}
+
+	name, err := get()
+	if err != nil {
+		c.cache.Remove(key)
+		return "", err
+	}
+	c.remember(token, name)
+	return name, nil
+}
+
+// remember records that token was verified to be owned by the user name.
+func (c *userCache) remember(token, name string) {
+	c.cache.Add(userCacheKey(token), &userCacheRecord{name: name, verified: c.now()})
+}
+
+// lookup returns the user owning token if the token was verified, however
+// long ago: a token is owned by the same user for its whole life.
+func (c *userCache) lookup(token string) (string, bool) {
+	value, ok := c.cache.Get(userCacheKey(token))
+	if !ok {
+		return "", false
+	}
+	return value.(*userCacheRecord).name, true
+}
+
+// requestUser returns the name of the user the token of req was verified to be
+// owned by, "anonymous" if req has no token, or "unknown" if its token wasn't
+// verified. Registry tokens are looked up as a whole, OpenShift tokens by the
+// password of basic authentication.
+func (c *userCache) requestUser(req *http.Request) (string, bool) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) == 0 {
+		return "anonymous", false
+	}
+	token := ""
+	if parts := strings.SplitN(authorization, " ", 2); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+		token = parts[1]
+	} else if bearerToken, err := getToken(req); err == nil {
+		token = bearerToken
+	}
+	if len(token) > 0 {
+		if name, ok := c.lookup(token); ok {
+			return name, true
+		}
+	}
+	return "unknown", false
+}
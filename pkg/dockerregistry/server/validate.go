@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/docker/distribution/configuration"
+	kerrors "k8s.io/kubernetes/pkg/util/errors"
+)
+
+// ValidateConfiguration returns the errors of the OpenShift specific parts of
+// config, the options of the openshift repository middleware, and of the
+// environment, so that the registry fails when it starts rather than at the
+// first request.
+func ValidateConfiguration(config *configuration.Configuration) error {
+	errs := []error{}
+
+	if len(os.Getenv("REGISTRY_URL")) == 0 {
+		errs = append(errs, errors.New("REGISTRY_URL is required: set it to the address clients pull the images pushed to this registry from, e.g. the address of the registry service"))
+	}
+
+	options, found := repositoryMiddlewareOptions(config)
+	if !found {
+		errs = append(errs, errors.New(`the openshift repository middleware is required: add {name: openshift} to middleware.repository`))
+	}
+	errs = append(errs, validateRepositoryOptions(options)...)
+
+	if _, err := MasterClientConfigFrom(config); err != nil {
+		errs = append(errs, fmt.Errorf("middleware.repository: %v", err))
+	}
+	if _, err := ManifestPolicyFrom(config); err != nil {
+		errs = append(errs, fmt.Errorf("middleware.repository: %v", err))
+	}
+
+	if _, err := SizeLimitsFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := RateLimitsFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := MirrorFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	trustedProxies, err := TrustedProxiesFromEnv()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if ProxyProtocolFromEnv() && len(trustedProxies) == 0 {
+		errs = append(errs, errors.New("REGISTRY_PROXY_PROTOCOL requires REGISTRY_TRUSTED_PROXIES"))
+	}
+	if _, err := ShutdownTimeoutFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := RepositoryStatsIntervalFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// repositoryMiddlewareOptions returns the options of the openshift repository
+// middleware of config, and whether it's configured.
+func repositoryMiddlewareOptions(config *configuration.Configuration) (map[string]interface{}, bool) {
+	for _, middleware := range config.Middleware["repository"] {
+		if middleware.Name == "openshift" && !middleware.Disabled {
+			return middleware.Options, true
+		}
+	}
+	return nil, false
+}
+
+// validateRepositoryOptions returns the errors of the options newRepository
+// reads.
+func validateRepositoryOptions(options map[string]interface{}) []error {
+	errs := []error{}
+	if _, err := apiTimeoutFrom(options); err != nil {
+		errs = append(errs, fmt.Errorf("middleware.repository: %v", err))
+	}
+	if _, err := layerCacheTTLFrom(options); err != nil {
+		errs = append(errs, fmt.Errorf("middleware.repository: %v", err))
+	}
+	if _, err := metadataCacheFrom(options); err != nil {
+		errs = append(errs, fmt.Errorf("middleware.repository: %v", err))
+	}
+	for _, name := range []string{"mirrorpullthrough", "pullthrough", "enforcequota"} {
+		if _, err := boolOption(options, name, false); err != nil {
+			errs = append(errs, fmt.Errorf("middleware.repository: %v", err))
+		}
+	}
+	return errs
+}
+
+// repositoryOptionDefaults are the values of the options of the openshift
+// repository middleware that aren't set.
+var repositoryOptionDefaults = map[string]interface{}{
+	"apitimeout":          defaultAPITimeout.String(),
+	"layercachettl":       defaultLayerCacheTTL.String(),
+	"metadatacachettl":    defaultMetadataCacheTTL.String(),
+	"pullthrough":         true,
+	"mirrorpullthrough":   false,
+	"enforcequota":        true,
+	"rejectforeignlayers": false,
+}
+
+// SetRepositoryOptionDefaults sets the options of the openshift repository
+// middleware of config that aren't set to their default values, so that the
+// effective configuration can be printed.
+func SetRepositoryOptionDefaults(config *configuration.Configuration) {
+	for i, middleware := range config.Middleware["repository"] {
+		if middleware.Name != "openshift" {
+			continue
+		}
+		if middleware.Options == nil {
+			middleware.Options = configuration.Parameters{}
+		}
+		for name, value := range repositoryOptionDefaults {
+			if _, ok := middleware.Options[name]; !ok {
+				middleware.Options[name] = value
+			}
+		}
+		config.Middleware["repository"][i] = middleware
+	}
+}
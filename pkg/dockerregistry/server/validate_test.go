@@ -0,0 +1,65 @@
+package server
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+)
+
+func TestValidateConfiguration(t *testing.T) {
+	defer os.Setenv("REGISTRY_URL", os.Getenv("REGISTRY_URL"))
+	defer os.Setenv("REGISTRY_MIRROR_TTL", os.Getenv("REGISTRY_MIRROR_TTL"))
+
+	os.Setenv("REGISTRY_URL", "172.30.1.1:5000")
+	os.Setenv("REGISTRY_MIRROR_TTL", "")
+	config := &configuration.Configuration{
+		Middleware: map[string][]configuration.Middleware{
+			"repository": {{Name: "openshift", Options: configuration.Parameters{"apitimeout": "10s"}}},
+		},
+	}
+	if err := ValidateConfiguration(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// every problem is reported at once
+	os.Setenv("REGISTRY_URL", "")
+	os.Setenv("REGISTRY_MIRROR_TTL", "forever")
+	config.Middleware["repository"][0].Options["apitimeout"] = "bogus"
+	config.Middleware["repository"][0].Options["pullthrough"] = "maybe"
+	err := ValidateConfiguration(config)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, expected := range []string{"REGISTRY_URL is required", "apitimeout", "pullthrough", "REGISTRY_MIRROR_TTL"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q to be reported, got %v", expected, err)
+		}
+	}
+
+	os.Setenv("REGISTRY_URL", "172.30.1.1:5000")
+	os.Setenv("REGISTRY_MIRROR_TTL", "")
+	if err := ValidateConfiguration(&configuration.Configuration{}); err == nil || !strings.Contains(err.Error(), "openshift repository middleware is required") {
+		t.Errorf("expected the missing middleware to be reported, got %v", err)
+	}
+}
+
+func TestSetRepositoryOptionDefaults(t *testing.T) {
+	config := &configuration.Configuration{
+		Middleware: map[string][]configuration.Middleware{
+			"repository": {{Name: "openshift", Options: configuration.Parameters{"pullthrough": false}}},
+		},
+	}
+	SetRepositoryOptionDefaults(config)
+	options := config.Middleware["repository"][0].Options
+	if options["pullthrough"] != false {
+		t.Errorf("expected pullthrough to be kept, got %v", options["pullthrough"])
+	}
+	if options["apitimeout"] != defaultAPITimeout.String() {
+		t.Errorf("expected the default apitimeout, got %v", options["apitimeout"])
+	}
+	if timeout, err := apiTimeoutFrom(options); err != nil || timeout != defaultAPITimeout {
+		t.Errorf("expected the default apitimeout to be read back, got %v, %v", timeout, err)
+	}
+}
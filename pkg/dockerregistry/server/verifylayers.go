@@ -0,0 +1,96 @@
+package server
+
+import (
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// maxConcurrentLayerChecks bounds the number of layers of a manifest checked
+// at the same time.
+const maxConcurrentLayerChecks = 8
+
+type layerCheck struct {
+	layer manifest.FSLayer
+	err   error
+}
+
+// verifyLayers checks that every layer referenced by m is stored in the
+// repository, or is served from it as told by servesLayer, before the manifest
+// is accepted. The client skips the upload of a layer a HEAD request finds, so
+// the layers of the images tagged from other projects or pulled through have
+// to be accepted as well. The layers are checked in parallel, checks that
+// haven't started are abandoned once one fails or ctx is done. A missing layer is reported as a distribution.ErrUnknownLayer
+// within a distribution.ErrManifestVerification, which the registry app
+// answers with BLOB_UNKNOWN.
+func (r *repository) verifyLayers(ctx context.Context, m *manifest.SignedManifest) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	seen := sets.NewString()
+	layers := []manifest.FSLayer{}
+	for _, layer := range m.FSLayers {
+		if seen.Has(layer.BlobSum.String()) {
+			continue
+		}
+		seen.Insert(layer.BlobSum.String())
+		layers = append(layers, layer)
+	}
+
+	// buffered, so that abandoned checks don't block
+	results := make(chan layerCheck, len(layers))
+	slots := make(chan struct{}, maxConcurrentLayerChecks)
+	for _, layer := range layers {
+		go func(layer manifest.FSLayer) {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				results <- layerCheck{layer: layer, err: ctx.Err()}
+				return
+			}
+			exists, err := r.Repository.Layers().Exists(layer.BlobSum)
+			if err == nil && !exists {
+				exists = r.servesLayer(ctx, layer.BlobSum)
+			}
+			if err == nil && !exists {
+				err = distribution.ErrUnknownLayer{FSLayer: layer}
+			}
+			results <- layerCheck{layer: layer, err: err}
+		}(layer)
+	}
+
+	for range layers {
+		select {
+		case result := <-results:
+			if result.err == nil {
+				continue
+			}
+			if _, unknown := result.err.(distribution.ErrUnknownLayer); unknown {
+				return distribution.ErrManifestVerification{result.err}
+			}
+			return result.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// servesLayer returns true if the layer, although not stored in the repository
+// of r, is served from it: it belongs to an image tagged from the image stream
+// of another project the user of ctx can pull from, or to a remote image
+// tagged into the image stream that can be pulled through.
+func (r *repository) servesLayer(ctx context.Context, dgst digest.Digest) bool {
+	if ref, err := r.findSourceLayer(ctx, dgst); err == nil {
+		userClient, _ := UserClientFrom(ctx)
+		if err := r.verifyPullAccess(ctx, ref, userClient); err == nil {
+			return true
+		}
+		r.logger(ctx).Debugf("Layer %s of %s/%s isn't served from %s/%s: access denied", dgst, r.namespace, r.name, ref.Namespace, ref.Name)
+	}
+	_, err := r.findRemoteLayer(ctx, dgst)
+	return err == nil
+}
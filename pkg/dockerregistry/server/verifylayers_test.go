@@ -0,0 +1,147 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"golang.org/x/net/context"
+	kapi "k8s.io/kubernetes/pkg/api"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// fakeStoredRepository stores the given layers.
+type fakeStoredRepository struct {
+	distribution.Repository
+
+	layers map[digest.Digest]bool
+}
+
+func (r *fakeStoredRepository) Layers() distribution.LayerService {
+	return &fakeStoredLayers{layers: r.layers}
+}
+
+type fakeStoredLayers struct {
+	distribution.LayerService
+
+	layers map[digest.Digest]bool
+}
+
+func (l *fakeStoredLayers) Exists(dgst digest.Digest) (bool, error) {
+	return l.layers[dgst], nil
+}
+
+func TestVerifyLayers(t *testing.T) {
+	client, _ := testclient.NewImageTrackerFake(&imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+	})
+	r := &repository{
+		Repository:     &fakeStoredRepository{layers: map[digest.Digest]bool{testLayerDigest: true, testDigest1: true}},
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+		layerCacheTTL:  time.Minute,
+	}
+	// a layer referenced by the image stream isn't necessarily stored
+	layerCache.set(r.cacheKey(), sets.NewString(testDigest2), time.Now().Add(time.Minute))
+	defer layerCache.forget(r.cacheKey())
+
+	stored := &manifest.SignedManifest{Manifest: manifest.Manifest{
+		FSLayers: []manifest.FSLayer{{BlobSum: testLayerDigest}, {BlobSum: testDigest1}, {BlobSum: testLayerDigest}},
+	}}
+	if err := r.verifyLayers(context.Background(), stored); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	missing := &manifest.SignedManifest{Manifest: manifest.Manifest{
+		FSLayers: []manifest.FSLayer{{BlobSum: testLayerDigest}, {BlobSum: testDigest2}},
+	}}
+	err := r.verifyLayers(context.Background(), missing)
+	verificationErr, ok := err.(distribution.ErrManifestVerification)
+	if !ok || len(verificationErr) != 1 {
+		t.Fatalf("expected a verification error, got %#v", err)
+	}
+	if unknown, ok := verificationErr[0].(distribution.ErrUnknownLayer); !ok || unknown.FSLayer.BlobSum != testDigest2 {
+		t.Errorf("expected the missing layer to be reported, got %#v", verificationErr[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.verifyLayers(ctx, missing); err == nil {
+		t.Errorf("expected an error once the context is done")
+	}
+}
+
+func TestVerifyLayersOfOtherProject(t *testing.T) {
+	// the image stream has an image tagged from another project, whose layer
+	// the client doesn't upload again
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"prod": {Items: []imageapi.TagEvent{{
+					Image:                testDigest1,
+					DockerImageReference: "registry:5000/other/app@" + testDigest1,
+				}}},
+			},
+		},
+	}
+	image := &imageapi.Image{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:        testDigest1,
+			Annotations: map[string]string{imageapi.ManagedByOpenShiftAnnotation: "true"},
+		},
+		DockerImageReference: "registry:5000/other/app@" + testDigest1,
+		DockerImageManifest:  `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + testLayerDigest + `"}]}`,
+	}
+	client, _ := testclient.NewImageTrackerFake(stream, image)
+	r := &repository{
+		Repository:     &fakeLocalRepository{},
+		registryClient: client,
+		registryAddr:   "registry:5000",
+		namespace:      "ns",
+		name:           "app",
+	}
+
+	saved := localRegistry
+	defer func() { localRegistry = saved }()
+	UseLocalRegistry(&fakeNamespace{repositories: map[string]distribution.Repository{
+		"other/app": &fakeSizedRepository{sizes: map[digest.Digest]int64{testLayerDigest: 5}},
+	}})
+
+	pushed := &manifest.SignedManifest{Manifest: manifest.Manifest{
+		FSLayers: []manifest.FSLayer{{BlobSum: testLayerDigest}},
+	}}
+	for _, allowed := range []bool{true, false} {
+		client.PrependReactor("create", "localsubjectaccessreviews", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			return true, &authorizationapi.SubjectAccessReviewResponse{Namespace: "other", Allowed: allowed}, nil
+		})
+
+		err := r.verifyLayers(context.Background(), pushed)
+		if !allowed {
+			if _, ok := err.(distribution.ErrManifestVerification); !ok {
+				t.Errorf("expected the layer of a project the user can't pull from to be unknown, got %#v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		layers, err := r.imageLayers(context.Background(), pushed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(layers) != 1 || layers[0].Name != testLayerDigest || layers[0].Size != 5 {
+			t.Errorf("unexpected layers %#v", layers)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body of a notification,
+	// keyed with REGISTRY_NOTIFY_SECRET, as "sha256=<hex>".
+	SignatureHeader = "X-Registry-Signature"
+
+	// notificationQueueLength is the number of events buffered for delivery
+	// to an endpoint before new ones are dropped.
+	notificationQueueLength = 1000
+	// notificationAttempts is the number of times the delivery of an event
+	// is attempted.
+	notificationAttempts = 5
+	// notificationBackoff is the delay before the first retry, it doubles
+	// with every retry.
+	notificationBackoff = time.Second
+	// notificationTimeout bounds a delivery attempt, including reading the
+	// response, so that an endpoint that hangs doesn't hold its queue.
+	notificationTimeout = 10 * time.Second
+)
+
+// RegistryEvent is posted to the notification endpoints when an image is
+// pushed to or deleted from a repository.
+type RegistryEvent struct {
+	Time time.Time `json:"timestamp"`
+	// Action is either "push" or "delete".
+	Action     string `json:"action"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest"`
+	// Actor is the name of the user who pushed or deleted the image.
+	Actor string `json:"actor"`
+	// ClientIP is the address of the client the image was pushed or deleted
+	// by.
+	ClientIP string `json:"clientIP,omitempty"`
+}
+
+// eventNotifier posts events to HTTP endpoints in the background, so that
+// pushes don't wait for the endpoints. Every endpoint has its own queue, so
+// that an endpoint that is down doesn't delay the others. A nil eventNotifier
+// posts nothing.
+type eventNotifier struct {
+	secret    []byte
+	client    *http.Client
+	endpoints []*notificationEndpoint
+}
+
+type notificationEndpoint struct {
+	url     string
+	queue   chan []byte
+	backoff time.Duration
+}
+
+var (
+	eventNotifierOnce   sync.Once
+	sharedEventNotifier *eventNotifier
+)
+
+// registryEventNotifier returns the notifier shared by all repositories,
+// posting to the comma separated URLs of REGISTRY_NOTIFY_URLS, or nil if
+// there are none.
+func registryEventNotifier() *eventNotifier {
+	eventNotifierOnce.Do(func() {
+		urls := []string{}
+		for _, url := range strings.Split(os.Getenv("REGISTRY_NOTIFY_URLS"), ",") {
+			if url = strings.TrimSpace(url); len(url) > 0 {
+				urls = append(urls, url)
+			}
+		}
+		if len(urls) == 0 {
+			return
+		}
+		sharedEventNotifier = newEventNotifier(urls, []byte(os.Getenv("REGISTRY_NOTIFY_SECRET")), &http.Client{Timeout: notificationTimeout}, notificationBackoff)
+	})
+	return sharedEventNotifier
+}
+
+func newEventNotifier(urls []string, secret []byte, client *http.Client, backoff time.Duration) *eventNotifier {
+	n := &eventNotifier{secret: secret, client: client}
+	for _, url := range urls {
+		endpoint := &notificationEndpoint{
+			url:     url,
+			queue:   make(chan []byte, notificationQueueLength),
+			backoff: backoff,
+		}
+		n.endpoints = append(n.endpoints, endpoint)
+		go n.run(endpoint)
+	}
+	return n
+}
+
+// notify queues event for delivery to every endpoint.
+func (n *eventNotifier) notify(event *RegistryEvent) {
+	if n == nil {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("Error encoding notification: %v", err)
+		return
+	}
+	for _, endpoint := range n.endpoints {
+		select {
+		case endpoint.queue <- body:
+		default:
+			log.Errorf("Notification queue of %s is full, dropping %s of %s@%s", endpoint.url, event.Action, event.Repository, event.Digest)
+		}
+	}
+}
+
+func (n *eventNotifier) run(endpoint *notificationEndpoint) {
+	for body := range endpoint.queue {
+		backoff := endpoint.backoff
+		for attempt := 1; ; attempt++ {
+			err := n.post(endpoint.url, body)
+			if err == nil {
+				break
+			}
+			if attempt == notificationAttempts {
+				log.Errorf("Giving up posting notification to %s: %v", endpoint.url, err)
+				break
+			}
+			log.Debugf("Error posting notification to %s, retrying in %s: %v", endpoint.url, backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func (n *eventNotifier) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+signNotification(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+// signNotification returns the hex encoded HMAC-SHA256 of body keyed with
+// secret.
+func signNotification(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
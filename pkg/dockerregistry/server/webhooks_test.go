@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestEventNotifier(t *testing.T) {
+	lock := sync.Mutex{}
+	attempts := 0
+	delivered := make(chan *RegistryEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		lock.Lock()
+		attempts++
+		failing := attempts == 1
+		lock.Unlock()
+		// the first attempt fails and is retried
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if e, a := "sha256="+signNotification([]byte("secret"), body), r.Header.Get(SignatureHeader); e != a {
+			t.Errorf("expected signature %q, got %q", e, a)
+		}
+		event := &RegistryEvent{}
+		if err := json.Unmarshal(body, event); err != nil {
+			t.Errorf("unexpected error decoding the event: %v", err)
+		}
+		delivered <- event
+	}))
+	defer server.Close()
+
+	r := &repository{
+		namespace: "ns",
+		name:      "app",
+		notifier:  newEventNotifier([]string{server.URL}, []byte("secret"), http.DefaultClient, time.Millisecond),
+	}
+	r.recordPush(context.Background(), testDigest1, "latest")
+
+	select {
+	case event := <-delivered:
+		if event.Action != "push" || event.Repository != "ns/app" || event.Tag != "latest" || event.Digest != testDigest1 || event.Actor != "unknown user" {
+			t.Errorf("unexpected event %#v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the event")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if attempts != 2 {
+		t.Errorf("expected the delivery to be retried once, got %d attempts", attempts)
+	}
+}
@@ -110,6 +110,12 @@ func (g *imageRefGenerator) FromStream(stream *imageapi.ImageStream, tag string)
 			imageRef.ResolvedReference = &ref
 			imageRef.Reference = ref
 		}
+	} else if specTag, ok := stream.Spec.Tags[tag]; ok && specTag.From != nil && specTag.From.Kind == "DockerImage" {
+		// the image of an external source may not be imported yet
+		if ref, err := imageapi.ParseDockerImageReference(specTag.From.Name); err == nil {
+			imageRef.ResolvedReference = &ref
+			imageRef.Reference = ref
+		}
 	}
 
 	if pullSpec := stream.Status.DockerImageRepository; len(pullSpec) != 0 {
@@ -281,6 +281,30 @@ func TestFromStream(t *testing.T) {
 	}
 }
 
+func TestFromStreamExternalTag(t *testing.T) {
+	g := NewImageRefGenerator()
+	repo := imageapi.ImageStream{
+		Spec: imageapi.ImageStreamSpec{
+			Tags: map[string]imageapi.TagReference{
+				"upstream": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "docker.io/library/ruby:2.2"},
+					ImportPolicy: imageapi.TagImportPolicy{ImportMode: imageapi.ReferenceTagImportMode},
+				},
+			},
+		},
+	}
+	imageRef, err := g.FromStream(&repo, "upstream")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if imageRef.ResolvedReference == nil || imageRef.ResolvedReference.String() != "docker.io/library/ruby:2.2" {
+		t.Fatalf("Unexpected resolved reference: %#v", imageRef.ResolvedReference)
+	}
+	if ref := imageRef.Reference; ref.Name != "ruby" || ref.Tag != "2.2" {
+		t.Fatalf("Unexpected reference: %#v", ref)
+	}
+}
+
 func TestFromNameAndPorts(t *testing.T) {
 	g := NewImageRefGenerator()
 	ports := []string{"8080"}
@@ -0,0 +1,133 @@
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/admission"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/quota"
+)
+
+func init() {
+	admission.RegisterPlugin("ImageQuota", func(c kclient.Interface, config io.Reader) (admission.Interface, error) {
+		osClient, ok := c.(client.Interface)
+		if !ok {
+			return nil, errors.New("client is not an Origin client")
+		}
+		return NewImageQuota(c, osClient), nil
+	})
+}
+
+type imageQuota struct {
+	*admission.Handler
+	kClient  kclient.Interface
+	osClient client.Interface
+}
+
+// NewImageQuota returns an admission control enforcing the
+// "openshift.io/imagestreams" and "openshift.io/images" hard limits of the
+// ResourceQuotas of a project when image streams are created and images are
+// tagged into them. The usage is reserved in the status of the quotas, which
+// the image quota controller keeps up to date. The images tagged are also limited in
+// size by the "storage" maximum of the "openshift.io/Image" LimitRanges of the
+// project.
+func NewImageQuota(kClient kclient.Interface, osClient client.Interface) admission.Interface {
+	return &imageQuota{
+		Handler:  admission.NewHandler(admission.Create),
+		kClient:  kClient,
+		osClient: osClient,
+	}
+}
+
+const (
+	imageStreamsResource        = "imagestreams"
+	imageStreamMappingsResource = "imagestreammappings"
+)
+
+func (a *imageQuota) Admit(attr admission.Attributes) error {
+	if len(attr.GetSubresource()) > 0 {
+		return nil
+	}
+	switch attr.GetResource() {
+	case imageStreamsResource:
+		return a.admitImageStream(attr)
+	case imageStreamMappingsResource:
+		mapping, ok := attr.GetObject().(*imageapi.ImageStreamMapping)
+		if !ok {
+			return admission.NewForbidden(attr, fmt.Errorf("Unrecognized request object %#v", attr.GetObject()))
+		}
+		if err := a.admitImageSize(attr, mapping); err != nil {
+			return err
+		}
+		return a.admitImageStreamMapping(attr, mapping)
+	}
+	return nil
+}
+
+// admitImageStream reserves the creation of an image stream in the
+// "openshift.io/imagestreams" quotas of the project.
+func (a *imageQuota) admitImageStream(attr admission.Attributes) error {
+	if err := quota.Reserve(a.kClient, attr.GetNamespace(), imageapi.ResourceImageStreams, 1); err != nil {
+		glog.V(4).Infof("Denying creation of image stream %s/%s: %v", attr.GetNamespace(), attr.GetName(), err)
+		return admission.NewForbidden(attr, err)
+	}
+	return nil
+}
+
+// admitImageStreamMapping reserves the image of mapping in the
+// "openshift.io/images" quotas of the project, which limit the number of
+// unique images referenced by the image streams of the project. Tagging an
+// image the stream already references adds nothing. An image referenced by
+// another stream is reserved again, which the image quota controller releases
+// when it recounts the usage.
+func (a *imageQuota) admitImageStreamMapping(attr admission.Attributes, mapping *imageapi.ImageStreamMapping) error {
+	stream, err := a.osClient.ImageStreams(attr.GetNamespace()).Get(mapping.Name)
+	switch {
+	case err == nil:
+		if quota.ReferencedImages([]imageapi.ImageStream{*stream}).Has(mapping.Image.Name) {
+			return nil
+		}
+	case !kapierrors.IsNotFound(err):
+		return admission.NewForbidden(attr, err)
+	}
+	if err := quota.Reserve(a.kClient, attr.GetNamespace(), imageapi.ResourceImages, 1); err != nil {
+		glog.V(4).Infof("Denying image %s in %s/%s: %v", mapping.Image.Name, attr.GetNamespace(), mapping.Name, err)
+		return admission.NewForbidden(attr, err)
+	}
+	return nil
+}
+
+// admitImageSize verifies that the image of mapping isn't larger than the
+// "storage" maximum of an "openshift.io/Image" LimitRange of the project. The
+// images whose size isn't known are denied if the size of the images is
+// limited.
+func (a *imageQuota) admitImageSize(attr admission.Attributes, mapping *imageapi.ImageStreamMapping) error {
+	limitRanges, err := a.kClient.LimitRanges(attr.GetNamespace()).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return admission.NewForbidden(attr, err)
+	}
+	max, limitRange, ok := imageapi.ImageSizeLimit(limitRanges.Items)
+	if !ok {
+		return nil
+	}
+	size, ok := imageapi.ImageSize(&mapping.Image)
+	if !ok {
+		glog.V(4).Infof("Denying image %s of unknown size in %s/%s: limit range %s limits the size of the images", mapping.Image.Name, attr.GetNamespace(), mapping.Name, limitRange)
+		return admission.NewForbidden(attr, fmt.Errorf("the size of the image isn't known, limit range %q limits the images to %d bytes", limitRange, max))
+	}
+	if size > max {
+		glog.V(4).Infof("Denying image %s in %s/%s: limit range %s allows images of %d bytes", mapping.Image.Name, attr.GetNamespace(), mapping.Name, limitRange, max)
+		return admission.NewForbidden(attr, fmt.Errorf("the image is %d bytes, larger than the maximum of %d bytes set by limit range %q", size, max, limitRange))
+	}
+	return nil
+}
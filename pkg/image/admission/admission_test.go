@@ -0,0 +1,220 @@
+package admission
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/admission"
+	kapi "k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func resourceQuota(name string, hard, used kapi.ResourceList) kapi.ResourceQuota {
+	return kapi.ResourceQuota{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: name},
+		Spec:       kapi.ResourceQuotaSpec{Hard: hard},
+		Status:     kapi.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
+
+func stream(name string, images ...string) *imageapi.ImageStream {
+	events := []imageapi.TagEvent{}
+	for _, image := range images {
+		events = append(events, imageapi.TagEvent{Image: image})
+	}
+	return &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: name},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{"latest": {Items: events}},
+		},
+	}
+}
+
+func TestImageQuotaAdmission(t *testing.T) {
+	limit := func(name kapi.ResourceName, value int64) kapi.ResourceList {
+		return kapi.ResourceList{name: *resource.NewQuantity(value, resource.DecimalSI)}
+	}
+	mapping := func(image string) runtime.Object {
+		return &imageapi.ImageStreamMapping{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "is1"},
+			Image:      imageapi.Image{ObjectMeta: kapi.ObjectMeta{Name: image}},
+			Tag:        "latest",
+		}
+	}
+	sizedMapping := func(image string, size int64) runtime.Object {
+		m := mapping(image).(*imageapi.ImageStreamMapping)
+		m.Image.Annotations = map[string]string{imageapi.ImageLayersAnnotation: `[{"name":"sha256:a","size":` + strconv.FormatInt(size, 10) + `}]`}
+		return m
+	}
+	imageLimit := func(max int64) kapi.LimitRange {
+		return kapi.LimitRange{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "limits"},
+			Spec: kapi.LimitRangeSpec{
+				Limits: []kapi.LimitRangeItem{{Type: imageapi.LimitTypeImage, Max: limit(kapi.ResourceStorage, max)}},
+			},
+		}
+	}
+	newStream := &imageapi.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "new"}}
+
+	tests := []struct {
+		name          string
+		resource      string
+		object        runtime.Object
+		quotas        []kapi.ResourceQuota
+		limitRanges   []kapi.LimitRange
+		stream        *imageapi.ImageStream
+		expectedError string
+		// expectedUsage is the usage reserved in each quota updated
+		expectedUsage map[string]int64
+	}{
+		{
+			name:     "no quota",
+			resource: imageStreamsResource,
+			object:   newStream,
+		},
+		{
+			name:          "image streams below quota",
+			resource:      imageStreamsResource,
+			object:        newStream,
+			quotas:        []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImageStreams, 2), limit(imageapi.ResourceImageStreams, 1))},
+			expectedUsage: map[string]int64{"q": 2},
+		},
+		{
+			name:     "image streams at quota",
+			resource: imageStreamsResource,
+			object:   newStream,
+			quotas: []kapi.ResourceQuota{
+				resourceQuota("q1", limit(kapi.ResourcePods, 1), limit(kapi.ResourcePods, 1)),
+				resourceQuota("q2", limit(imageapi.ResourceImageStreams, 2), limit(imageapi.ResourceImageStreams, 2)),
+			},
+			expectedError: `limited to 2 openshift.io/imagestreams by quota "q2"`,
+		},
+		{
+			name:          "image streams usage not known",
+			resource:      imageStreamsResource,
+			object:        newStream,
+			quotas:        []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImageStreams, 2), nil)},
+			expectedError: `the usage of openshift.io/imagestreams in quota "q" isn't known yet`,
+		},
+		{
+			name:          "images below quota",
+			resource:      imageStreamMappingsResource,
+			object:        mapping("image3"),
+			quotas:        []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImages, 3), limit(imageapi.ResourceImages, 2))},
+			stream:        stream("is1", "image1", "image2"),
+			expectedUsage: map[string]int64{"q": 3},
+		},
+		{
+			name:          "images below quota for a new stream",
+			resource:      imageStreamMappingsResource,
+			object:        mapping("image3"),
+			quotas:        []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImages, 3), limit(imageapi.ResourceImages, 0))},
+			expectedUsage: map[string]int64{"q": 1},
+		},
+		{
+			name:          "images at quota",
+			resource:      imageStreamMappingsResource,
+			object:        mapping("image3"),
+			quotas:        []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImages, 2), limit(imageapi.ResourceImages, 2))},
+			stream:        stream("is1", "image1", "image2"),
+			expectedError: `limited to 2 openshift.io/images by quota "q"`,
+		},
+		{
+			name:     "image already referenced at quota",
+			resource: imageStreamMappingsResource,
+			object:   mapping("image2"),
+			quotas:   []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImages, 2), limit(imageapi.ResourceImages, 2))},
+			stream:   stream("is1", "image1", "image2"),
+		},
+		{
+			name:     "image streams quota ignored by mappings",
+			resource: imageStreamMappingsResource,
+			object:   mapping("image2"),
+			quotas:   []kapi.ResourceQuota{resourceQuota("q", limit(imageapi.ResourceImageStreams, 1), limit(imageapi.ResourceImageStreams, 1))},
+			stream:   stream("is1", "image1"),
+		},
+		{
+			name:        "image below size limit",
+			resource:    imageStreamMappingsResource,
+			object:      sizedMapping("image1", 1024),
+			limitRanges: []kapi.LimitRange{imageLimit(1024)},
+		},
+		{
+			name:          "image above size limit",
+			resource:      imageStreamMappingsResource,
+			object:        sizedMapping("image1", 1025),
+			limitRanges:   []kapi.LimitRange{imageLimit(1024)},
+			expectedError: `the image is 1025 bytes, larger than the maximum of 1024 bytes set by limit range "limits"`,
+		},
+		{
+			name:          "image of unknown size",
+			resource:      imageStreamMappingsResource,
+			object:        mapping("image1"),
+			limitRanges:   []kapi.LimitRange{imageLimit(1024)},
+			expectedError: `the size of the image isn't known, limit range "limits" limits the images to 1024 bytes`,
+		},
+		{
+			name:     "image size annotation ignored",
+			resource: imageStreamMappingsResource,
+			object: &imageapi.ImageStreamMapping{
+				ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "is1"},
+				Image: imageapi.Image{ObjectMeta: kapi.ObjectMeta{
+					Name:        "image1",
+					Annotations: map[string]string{imageapi.ImageSizeAnnotation: "1"},
+				}},
+				Tag: "latest",
+			},
+			limitRanges:   []kapi.LimitRange{imageLimit(1024)},
+			expectedError: `the size of the image isn't known`,
+		},
+	}
+
+	for _, test := range tests {
+		kClient := ktestclient.NewSimpleFake(&kapi.ResourceQuotaList{Items: test.quotas}, &kapi.LimitRangeList{Items: test.limitRanges})
+		usage := make(map[string]int64)
+		kClient.PrependReactor("update", "resourcequotas", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			updated := action.(ktestclient.UpdateAction).GetObject().(*kapi.ResourceQuota)
+			for name, used := range updated.Status.Used {
+				if name == imageapi.ResourceImageStreams || name == imageapi.ResourceImages {
+					usage[updated.Name] = used.Value()
+				}
+			}
+			return true, updated, nil
+		})
+		objects := []runtime.Object{}
+		if test.stream != nil {
+			objects = append(objects, test.stream)
+		}
+		osClient := testclient.NewSimpleFake(objects...)
+		plugin := NewImageQuota(kClient, osClient)
+
+		attrs := admission.NewAttributesRecord(test.object, "", "ns", "name", test.resource, "", admission.Create, nil)
+		err := plugin.Admit(attrs)
+		switch {
+		case len(test.expectedError) == 0 && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		case len(test.expectedError) > 0 && err == nil:
+			t.Errorf("%s: expected error %q", test.name, test.expectedError)
+		case len(test.expectedError) > 0:
+			if !apierrors.IsForbidden(err) || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("%s: expected forbidden error %q, got %v", test.name, test.expectedError, err)
+			}
+		}
+		if len(usage) != len(test.expectedUsage) {
+			t.Errorf("%s: expected the usage %v to be reserved, got %v", test.name, test.expectedUsage, usage)
+			continue
+		}
+		for name, expected := range test.expectedUsage {
+			if usage[name] != expected {
+				t.Errorf("%s: expected the usage %v to be reserved, got %v", test.name, test.expectedUsage, usage)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package admission
+
+import (
+	"fmt"
+	"io"
+
+	"k8s.io/kubernetes/pkg/admission"
+	kapi "k8s.io/kubernetes/pkg/api"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// RegistryPolicyPluginName is the name of the admission control rejecting the
+// pods running images from registries the registry policy doesn't allow.
+const RegistryPolicyPluginName = "OriginImageRegistryPolicy"
+
+func init() {
+	// the master creates the plugin with the registry policy of its
+	// configuration, the registered one allows all the registries
+	admission.RegisterPlugin(RegistryPolicyPluginName, func(c kclient.Interface, config io.Reader) (admission.Interface, error) {
+		return NewRegistryPolicy(imageapi.RegistryPolicy{}), nil
+	})
+}
+
+type registryPolicy struct {
+	*admission.Handler
+	policy imageapi.RegistryPolicy
+}
+
+// NewRegistryPolicy returns an admission control rejecting the pods with a
+// container whose image comes from a registry policy doesn't allow.
+func NewRegistryPolicy(policy imageapi.RegistryPolicy) admission.Interface {
+	return &registryPolicy{
+		Handler: admission.NewHandler(admission.Create, admission.Update),
+		policy:  policy,
+	}
+}
+
+func (a *registryPolicy) Admit(attr admission.Attributes) error {
+	if attr.GetResource() != "pods" || len(attr.GetSubresource()) > 0 || a.policy.IsEmpty() {
+		return nil
+	}
+	pod, ok := attr.GetObject().(*kapi.Pod)
+	if !ok {
+		return admission.NewForbidden(attr, fmt.Errorf("Unrecognized request object %#v", attr.GetObject()))
+	}
+	for _, container := range pod.Spec.Containers {
+		ref, err := imageapi.ParseDockerImageReference(container.Image)
+		if err != nil {
+			// invalid images are rejected by validation
+			continue
+		}
+		if !a.policy.Allows(ref.Registry) {
+			return admission.NewForbidden(attr, fmt.Errorf("the image %q of container %q comes from the registry %q, which is not allowed", container.Image, container.Name, ref.DockerClientDefaults().Registry))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package admission
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/admission"
+	kapi "k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestRegistryPolicyAdmission(t *testing.T) {
+	pod := func(images ...string) *kapi.Pod {
+		pod := &kapi.Pod{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "pod"}}
+		for _, image := range images {
+			pod.Spec.Containers = append(pod.Spec.Containers, kapi.Container{Name: "container", Image: image})
+		}
+		return pod
+	}
+	integrated := imageapi.RegistryPolicy{Allowed: []string{"172.30.1.1:5000", "*.example.com"}}
+
+	tests := []struct {
+		name      string
+		policy    imageapi.RegistryPolicy
+		pod       *kapi.Pod
+		forbidden bool
+	}{
+		{
+			name:   "no policy",
+			policy: imageapi.RegistryPolicy{},
+			pod:    pod("centos"),
+		},
+		{
+			name:      "blocked docker hub",
+			policy:    imageapi.RegistryPolicy{Blocked: []string{"docker.io"}},
+			pod:       pod("registry.example.com/ns/app", "centos:7"),
+			forbidden: true,
+		},
+		{
+			name:   "allowed registries",
+			policy: integrated,
+			pod:    pod("172.30.1.1:5000/ns/app@sha256:0123", "registry.example.com/ns/app"),
+		},
+		{
+			name:      "registry not allowed",
+			policy:    integrated,
+			pod:       pod("172.30.1.1:5000/ns/app", "registry.example.org/ns/app"),
+			forbidden: true,
+		},
+	}
+	for _, test := range tests {
+		plugin := NewRegistryPolicy(test.policy)
+		attrs := admission.NewAttributesRecord(test.pod, "Pod", "ns", "pod", "pods", "", admission.Create, nil)
+		err := plugin.Admit(attrs)
+		switch {
+		case test.forbidden && !apierrors.IsForbidden(err):
+			t.Errorf("%s: expected a forbidden error, got %v", test.name, err)
+		case !test.forbidden && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
@@ -1,13 +1,57 @@
 package api
 
-import "k8s.io/kubernetes/pkg/fields"
+import (
+	"strconv"
+
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+)
 
 // ImageToSelectableFields returns a label set that represents the object.
 func ImageToSelectableFields(image *Image) fields.Set {
 	return fields.Set{
-		"metadata.name":      image.Name,
-		"metadata.namespace": image.Namespace,
+		"metadata.name":                    image.Name,
+		"metadata.namespace":               image.Namespace,
+		"dockerImageReference":             image.DockerImageReference,
+		"dockerImageMetadata.Architecture": image.DockerImageMetadata.Architecture,
+		"dockerImageMetadata.Parent":       image.DockerImageMetadata.Parent,
+	}
+}
+
+// ImageToSelectableLabels returns the labels of the image along with the
+// labels of its docker image, so that images can be selected by either. The
+// labels of the image take precedence.
+func ImageToSelectableLabels(image *Image) labels.Set {
+	set := labels.Set{}
+	if config := image.DockerImageMetadata.Config; config != nil {
+		for k, v := range config.Labels {
+			set[k] = v
+		}
 	}
+	for k, v := range image.Labels {
+		set[k] = v
+	}
+	return set
+}
+
+// ImageStreamDeletedField selects the image streams being deleted with "true",
+// and the others with "false". The image streams being deleted are only listed
+// and watched with a field selector on this field.
+const ImageStreamDeletedField = "deleted"
+
+// ImageStreamsIncludingDeleted returns the field selector listing and watching
+// the image streams being deleted along with the others, e.g. to find all the
+// images that can't be pruned yet.
+func ImageStreamsIncludingDeleted() fields.Selector {
+	// the field is either "true" or "false", never empty
+	selector, _ := fields.ParseSelector(ImageStreamDeletedField + "!=")
+	return selector
+}
+
+// DeletedImageStreams returns the field selector listing and watching the
+// image streams being deleted only.
+func DeletedImageStreams() fields.Selector {
+	return fields.OneTermEqualSelector(ImageStreamDeletedField, "true")
 }
 
 // ImageStreamToSelectableFields returns a label set that represents the object.
@@ -17,5 +61,17 @@ func ImageStreamToSelectableFields(ir *ImageStream) fields.Set {
 		"metadata.namespace":           ir.Namespace,
 		"spec.dockerImageRepository":   ir.Spec.DockerImageRepository,
 		"status.dockerImageRepository": ir.Status.DockerImageRepository,
+		ImageStreamDeletedField:        strconv.FormatBool(ir.DeletionTimestamp != nil),
+	}
+}
+
+// ImageLayerToSelectableFields returns a label set that represents the object.
+// The layers may also be selected by "imageStream", see the storage of
+// ImageLayers.
+func ImageLayerToSelectableFields(layer *ImageLayer) fields.Set {
+	return fields.Set{
+		"metadata.name":      layer.Name,
+		"metadata.namespace": layer.Namespace,
+		"shared":             strconv.FormatBool(layer.Shared),
 	}
 }
@@ -3,8 +3,10 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"strings"
 
+	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/util/sets"
@@ -226,6 +228,37 @@ func JoinImageStreamTag(name, tag string) string {
 	return fmt.Sprintf("%s:%s", name, tag)
 }
 
+// SplitTagDigest splits a tag pinned to an image, <tag>@<digest>, into the tag
+// and the digest of the image. The digest is empty if the tag isn't pinned. It
+// returns false if the tag is pinned to an invalid digest.
+func SplitTagDigest(tagAndDigest string) (tag string, dgst string, ok bool) {
+	parts := strings.SplitN(tagAndDigest, "@", 2)
+	if len(parts) == 1 {
+		return tagAndDigest, "", true
+	}
+	if _, err := digest.ParseDigest(parts[1]); err != nil {
+		return parts[0], parts[1], false
+	}
+	return parts[0], parts[1], true
+}
+
+// SplitImageSignatureName turns the name of an ImageSignature into the name of
+// the signed image and the name of the signature. It returns false if either
+// is missing.
+func SplitImageSignatureName(name string) (imageName, signatureName string, ok bool) {
+	parts := strings.SplitN(name, "@", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// JoinImageSignatureName turns the name of an image and of a signature into the
+// name of an ImageSignature.
+func JoinImageSignatureName(imageName, signatureName string) string {
+	return fmt.Sprintf("%s@%s", imageName, signatureName)
+}
+
 // NormalizeImageStreamTag normalizes an image stream tag by defaulting to 'latest'
 // if no tag has been specified.
 func NormalizeImageStreamTag(name string) string {
@@ -277,6 +310,121 @@ func ImageWithMetadata(image Image) (*Image, error) {
 	return &image, nil
 }
 
+// HasFinalizer returns true if image has finalizer, e.g.
+// ImageFinalizerRegistry.
+func HasFinalizer(image *Image, finalizer kapi.FinalizerName) bool {
+	for _, f := range image.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageLayerSize is a layer of the ImageLayersAnnotation of an image.
+type ImageLayerSize struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// ImageSize returns the total size in bytes of the distinct layers of image,
+// and false if it isn't known. The sizes are read from the
+// ImageLayersAnnotation the integrated registry and the imports record on the
+// images, which must list every layer of the manifest of the image, or else
+// from the history of the manifest.
+func ImageSize(image *Image) (int64, bool) {
+	manifest := DockerImageManifest{}
+	if len(image.DockerImageManifest) > 0 {
+		if err := json.Unmarshal([]byte(image.DockerImageManifest), &manifest); err != nil {
+			return 0, false
+		}
+	}
+	if _, ok := image.Annotations[ImageLayersAnnotation]; ok {
+		layers, ok := ImageLayerSizes(image)
+		if !ok {
+			return 0, false
+		}
+		return imageLayersSize(layers, manifest.FSLayers)
+	}
+	if len(image.DockerImageManifest) == 0 || len(manifest.History) != len(manifest.FSLayers) {
+		return 0, false
+	}
+	var size int64
+	seen := sets.NewString()
+	for i, layer := range manifest.FSLayers {
+		if seen.Has(layer.DockerBlobSum) {
+			continue
+		}
+		seen.Insert(layer.DockerBlobSum)
+		v1Metadata := DockerV1CompatibilityImage{}
+		if err := json.Unmarshal([]byte(manifest.History[i].DockerV1Compatibility), &v1Metadata); err != nil || v1Metadata.Size < 0 {
+			return 0, false
+		}
+		size += v1Metadata.Size
+	}
+	return size, true
+}
+
+// ImageLayerSizes returns the layers listed in the ImageLayersAnnotation of
+// image, and false if it has none or the annotation is invalid.
+func ImageLayerSizes(image *Image) ([]ImageLayerSize, bool) {
+	value, ok := image.Annotations[ImageLayersAnnotation]
+	if !ok {
+		return nil, false
+	}
+	layers := []ImageLayerSize{}
+	if err := json.Unmarshal([]byte(value), &layers); err != nil {
+		return nil, false
+	}
+	return layers, true
+}
+
+// imageLayersSize returns the total size of the distinct layers, and false if
+// one has an invalid size or one of fsLayers is missing.
+func imageLayersSize(layers []ImageLayerSize, fsLayers []DockerFSLayer) (int64, bool) {
+	sizes := make(map[string]int64)
+	for _, layer := range layers {
+		if layer.Size < 0 {
+			return 0, false
+		}
+		sizes[layer.Name] = layer.Size
+	}
+	for _, layer := range fsLayers {
+		if _, ok := sizes[layer.DockerBlobSum]; !ok {
+			return 0, false
+		}
+	}
+	var size int64
+	for _, layerSize := range sizes {
+		size += layerSize
+	}
+	return size, true
+}
+
+// ImageSizeLimit returns the smallest "storage" maximum of the LimitTypeImage
+// limits of limitRanges, with the name of the LimitRange setting it, and
+// false if none limits the size of the images.
+func ImageSizeLimit(limitRanges []kapi.LimitRange) (int64, string, bool) {
+	var (
+		max   int64
+		name  string
+		found bool
+	)
+	for _, limitRange := range limitRanges {
+		for _, limit := range limitRange.Spec.Limits {
+			if limit.Type != LimitTypeImage {
+				continue
+			}
+			quantity, ok := limit.Max[kapi.ResourceStorage]
+			if !ok || (found && quantity.Value() >= max) {
+				continue
+			}
+			max, name, found = quantity.Value(), limitRange.Name, true
+		}
+	}
+	return max, name, found
+}
+
 // DockerImageReferenceForStream returns a DockerImageReference that represents
 // the ImageStream or false, if no valid reference exists.
 func DockerImageReferenceForStream(stream *ImageStream) (DockerImageReference, error) {
@@ -299,7 +447,7 @@ func LatestTaggedImage(stream *ImageStream, tag string) *TagEvent {
 	}
 	// find the most recent tag event with an image reference
 	if stream.Status.Tags != nil {
-		if history, ok := stream.Status.Tags[tag]; ok {
+		if history, ok := stream.Status.Tags[tag]; ok && len(history.Items) > 0 {
 			return &history.Items[0]
 		}
 	}
@@ -307,6 +455,96 @@ func LatestTaggedImage(stream *ImageStream, tag string) *TagEvent {
 	return nil
 }
 
+// TaggedImage returns the TagEvent consumers of tag resolve to: the most recent
+// one, or, if tag is pinned to an image with <tag>@<digest>, the one of the
+// history of the tag recording that image. Returns nil if there is none.
+func TaggedImage(stream *ImageStream, tag string) *TagEvent {
+	tag, dgst, _ := SplitTagDigest(tag)
+	if len(dgst) == 0 {
+		return LatestTaggedImage(stream, tag)
+	}
+	if len(tag) == 0 {
+		tag = DefaultImageTag
+	}
+	history := stream.Status.Tags[tag]
+	for i := range history.Items {
+		if history.Items[i].Image == dgst {
+			return &history.Items[i]
+		}
+	}
+	return nil
+}
+
+// IsTagImmutable returns true if tag is an immutable spec tag of stream that
+// already references an image, which can't be replaced until the tag is made
+// mutable again.
+func IsTagImmutable(stream *ImageStream, tag string) bool {
+	if len(tag) == 0 {
+		tag = DefaultImageTag
+	}
+	tagRef, ok := stream.Spec.Tags[tag]
+	return ok && tagRef.Immutable && LatestTaggedImage(stream, tag) != nil
+}
+
+// IsTagReferenceOnly returns true if tagRef references the pull spec of its
+// source as is, without importing its image, which is requested with either
+// reference or the Reference import mode.
+func IsTagReferenceOnly(tagRef TagReference) bool {
+	return tagRef.Reference || tagRef.ImportPolicy.ImportMode == ReferenceTagImportMode
+}
+
+// ResolveReferenceForTagEvent returns the pull spec consumers of tag should use
+// for the image of event, according to the reference policy of the spec tag.
+// Tags whose policy is Local are pulled through the integrated registry, by
+// digest when the image has one, all other tags from their source. tag may be
+// pinned to an image with <tag>@<digest>. Returns an empty string if event is
+// nil.
+func ResolveReferenceForTagEvent(stream *ImageStream, tag string, event *TagEvent) string {
+	if event == nil {
+		return ""
+	}
+	tag, _, _ = SplitTagDigest(tag)
+	if len(tag) == 0 {
+		tag = DefaultImageTag
+	}
+	specTag, ok := stream.Spec.Tags[tag]
+	if !ok || specTag.ReferencePolicy.Type != LocalTagReferencePolicy || len(stream.Status.DockerImageRepository) == 0 {
+		return event.DockerImageReference
+	}
+	ref, err := ParseDockerImageReference(stream.Status.DockerImageRepository)
+	if err != nil || len(event.Image) == 0 {
+		return event.DockerImageReference
+	}
+	ref.Tag, ref.ID = "", ""
+	if strings.Contains(event.Image, ":") {
+		ref.ID = event.Image
+	} else {
+		ref.Tag = tag
+	}
+	return ref.Exact()
+}
+
+// TagAnnotations returns a copy of the annotations declared on the spec tag
+// of the provided stream. Tags that are resolved by consumers (deployments,
+// builds, ImageStreamTags) surface these annotations alongside the resolved
+// image. tag may be pinned to an image with <tag>@<digest>. Returns nil if the
+// tag has no annotations.
+func TagAnnotations(stream *ImageStream, tag string) map[string]string {
+	tag, _, _ = SplitTagDigest(tag)
+	if len(tag) == 0 {
+		tag = DefaultImageTag
+	}
+	tagRef, ok := stream.Spec.Tags[tag]
+	if !ok || len(tagRef.Annotations) == 0 {
+		return nil
+	}
+	annotations := make(map[string]string, len(tagRef.Annotations))
+	for k, v := range tagRef.Annotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
 // AddTagEventToImageStream attempts to update the given image stream with a tag event. It will
 // collapse duplicate entries - returning true if a change was made or false if no change
 // occurred.
@@ -317,7 +555,8 @@ func AddTagEventToImageStream(stream *ImageStream, tag string, next TagEvent) bo
 
 	tags, ok := stream.Status.Tags[tag]
 	if !ok || len(tags.Items) == 0 {
-		stream.Status.Tags[tag] = TagEventList{Items: []TagEvent{next}}
+		tags.Items = []TagEvent{next}
+		stream.Status.Tags[tag] = tags
 		return true
 	}
 
@@ -345,6 +584,131 @@ func AddTagEventToImageStream(stream *ImageStream, tag string, next TagEvent) bo
 	return true
 }
 
+// TrimTagHistory removes the oldest items of the history of every tag in the
+// status of stream exceeding stream.spec.tagHistoryLimit. It returns the names
+// of the removed images which are no longer referenced by any tag of the stream,
+// and whether the stream was changed.
+func TrimTagHistory(stream *ImageStream) (unreferenced []string, changed bool) {
+	limit := stream.Spec.TagHistoryLimit
+	if limit <= 0 {
+		return nil, false
+	}
+
+	removed := sets.NewString()
+	for tag, history := range stream.Status.Tags {
+		if len(history.Items) <= limit {
+			continue
+		}
+		for _, event := range history.Items[limit:] {
+			removed.Insert(event.Image)
+		}
+		history.Items = history.Items[:limit]
+		stream.Status.Tags[tag] = history
+		changed = true
+	}
+	for _, history := range stream.Status.Tags {
+		for _, event := range history.Items {
+			removed.Delete(event.Image)
+		}
+	}
+	removed.Delete("")
+	return removed.List(), changed
+}
+
+// TagImportFailure returns the ImportSuccess condition of tag in the status of
+// stream if the last import of the tag failed, nil otherwise. A tag without
+// history nor failure was never imported.
+func TagImportFailure(stream *ImageStream, tag string) *TagEventCondition {
+	conditions := stream.Status.Tags[tag].Conditions
+	for i := range conditions {
+		if conditions[i].Type == ImportSuccess && conditions[i].Status == kapi.ConditionFalse {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetTagImportFailed records a failed import of tag, attempted at now, in the
+// status of stream: the ImportSuccess condition of the tag is set to False
+// with reason and message, and its number of failures is incremented.
+func SetTagImportFailed(stream *ImageStream, tag, reason, message string, now unversioned.Time) {
+	if len(message) > 300 {
+		message = message[:300]
+	}
+	condition := TagEventCondition{
+		Type:               ImportSuccess,
+		Status:             kapi.ConditionFalse,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+		Failures:           1,
+		LastAttemptTime:    now,
+		Generation:         stream.Generation,
+	}
+	if previous := TagImportFailure(stream, tag); previous != nil {
+		condition.LastTransitionTime = previous.LastTransitionTime
+		condition.Failures = previous.Failures + 1
+	}
+
+	history := stream.Status.Tags[tag]
+	history.Conditions = append(withoutImportCondition(history.Conditions), condition)
+	if stream.Status.Tags == nil {
+		stream.Status.Tags = make(map[string]TagEventList)
+	}
+	stream.Status.Tags[tag] = history
+}
+
+// ClearTagImportFailed removes the ImportSuccess condition of tag from the
+// status of stream once the tag is imported. It returns true if the status
+// changed.
+func ClearTagImportFailed(stream *ImageStream, tag string) bool {
+	history, ok := stream.Status.Tags[tag]
+	if !ok {
+		return false
+	}
+	conditions := withoutImportCondition(history.Conditions)
+	if len(conditions) == len(history.Conditions) {
+		return false
+	}
+	history.Conditions = conditions
+	stream.Status.Tags[tag] = history
+	return true
+}
+
+// withoutImportCondition returns conditions without the ImportSuccess
+// condition.
+func withoutImportCondition(conditions []TagEventCondition) []TagEventCondition {
+	result := []TagEventCondition{}
+	for _, condition := range conditions {
+		if condition.Type != ImportSuccess {
+			result = append(result, condition)
+		}
+	}
+	return result
+}
+
+// MirrorsTag returns true if the tag of a docker repository is mirrored into
+// an image stream by policy. Invalid patterns match no tag.
+func MirrorsTag(policy *RepositoryMirrorPolicy, tag string) bool {
+	if policy == nil {
+		return false
+	}
+	for _, pattern := range policy.ExcludeTags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return false
+		}
+	}
+	if len(policy.IncludeTags) == 0 {
+		return true
+	}
+	for _, pattern := range policy.IncludeTags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // UpdateChangedTrackingTags identifies any tags in the status that have changed and
 // ensures any referenced tracking tags are also updated. It returns the number of
 // updates applied.
@@ -444,6 +808,12 @@ func UpdateTrackingTags(stream *ImageStream, updatedTag string, updatedImage Tag
 			continue
 		}
 
+		// the image of an immutable tag is never replaced
+		if IsTagImmutable(stream, specTag) {
+			glog.V(5).Infof("tag %q is immutable - skipping", specTag)
+			continue
+		}
+
 		if AddTagEventToImageStream(stream, specTag, updatedImage) {
 			glog.V(5).Infof("stream updated")
 			updated++
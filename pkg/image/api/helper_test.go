@@ -8,6 +8,7 @@ import (
 	"time"
 
 	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/resource"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/util"
 )
@@ -585,6 +586,147 @@ func TestLatestTaggedImage(t *testing.T) {
 	}
 }
 
+func TestTaggedImage(t *testing.T) {
+	stream := &ImageStream{
+		Status: ImageStreamStatus{
+			Tags: map[string]TagEventList{
+				"latest": {
+					Items: []TagEvent{
+						{DockerImageReference: "latest-ref", Image: "sha256:aaa"},
+						{DockerImageReference: "older", Image: "sha256:bbb"},
+					},
+				},
+			},
+		},
+	}
+	tests := map[string]struct {
+		tag      string
+		expected string
+	}{
+		"latest":         {tag: "latest", expected: "latest-ref"},
+		"pinned":         {tag: "latest@sha256:bbb", expected: "older"},
+		"pinned default": {tag: "@sha256:aaa", expected: "latest-ref"},
+		"not in history": {tag: "latest@sha256:ccc"},
+		"unknown tag":    {tag: "other@sha256:aaa"},
+	}
+	for name, test := range tests {
+		actual := TaggedImage(stream, test.tag)
+		switch {
+		case actual == nil && len(test.expected) > 0:
+			t.Errorf("%s: unexpected nil result", name)
+		case actual != nil && actual.DockerImageReference != test.expected:
+			t.Errorf("%s: expected %q, got %q", name, test.expected, actual.DockerImageReference)
+		}
+	}
+}
+
+func TestSplitTagDigest(t *testing.T) {
+	tests := []struct {
+		tagAndDigest string
+		tag, digest  string
+		ok           bool
+	}{
+		{tagAndDigest: "latest", tag: "latest", ok: true},
+		{tagAndDigest: "latest@sha256:abc", tag: "latest", digest: "sha256:abc", ok: true},
+		{tagAndDigest: "latest@abc", tag: "latest", digest: "abc"},
+		{tagAndDigest: "latest@", tag: "latest"},
+	}
+	for _, test := range tests {
+		tag, digest, ok := SplitTagDigest(test.tagAndDigest)
+		if tag != test.tag || digest != test.digest || ok != test.ok {
+			t.Errorf("%s: expected %q, %q, %t, got %q, %q, %t", test.tagAndDigest, test.tag, test.digest, test.ok, tag, digest, ok)
+		}
+	}
+}
+
+func TestResolveReferenceForTagEvent(t *testing.T) {
+	stream := &ImageStream{
+		Spec: ImageStreamSpec{
+			Tags: map[string]TagReference{
+				"source": {ReferencePolicy: TagReferencePolicy{Type: SourceTagReferencePolicy}},
+				"local":  {ReferencePolicy: TagReferencePolicy{Type: LocalTagReferencePolicy}},
+			},
+		},
+		Status: ImageStreamStatus{DockerImageRepository: "registry:5000/ns/app"},
+	}
+	tests := map[string]struct {
+		tag      string
+		event    *TagEvent
+		expected string
+	}{
+		"no event": {
+			tag: "local",
+		},
+		"source": {
+			tag:      "source",
+			event:    &TagEvent{DockerImageReference: "example.com/ns/app@sha256:abc", Image: "sha256:abc"},
+			expected: "example.com/ns/app@sha256:abc",
+		},
+		"unknown tag": {
+			tag:      "other",
+			event:    &TagEvent{DockerImageReference: "example.com/ns/app:other", Image: "sha256:abc"},
+			expected: "example.com/ns/app:other",
+		},
+		"local by digest": {
+			tag:      "local",
+			event:    &TagEvent{DockerImageReference: "example.com/ns/app:1.0", Image: "sha256:abc"},
+			expected: "registry:5000/ns/app@sha256:abc",
+		},
+		"local by tag": {
+			tag:      "local",
+			event:    &TagEvent{DockerImageReference: "example.com/ns/app:1.0", Image: "abc123"},
+			expected: "registry:5000/ns/app:local",
+		},
+		"local pinned": {
+			tag:      "local@sha256:abc",
+			event:    &TagEvent{DockerImageReference: "example.com/ns/app:1.0", Image: "sha256:abc"},
+			expected: "registry:5000/ns/app@sha256:abc",
+		},
+		"local without image": {
+			tag:      "local",
+			event:    &TagEvent{DockerImageReference: "example.com/ns/app:1.0"},
+			expected: "example.com/ns/app:1.0",
+		},
+	}
+	for name, test := range tests {
+		if actual := ResolveReferenceForTagEvent(stream, test.tag, test.event); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", name, test.expected, actual)
+		}
+	}
+
+	stream.Status.DockerImageRepository = ""
+	event := &TagEvent{DockerImageReference: "example.com/ns/app:1.0", Image: "sha256:abc"}
+	if actual := ResolveReferenceForTagEvent(stream, "local", event); actual != event.DockerImageReference {
+		t.Errorf("expected the source reference without an integrated registry, got %q", actual)
+	}
+}
+
+func TestTagAnnotations(t *testing.T) {
+	stream := &ImageStream{
+		Spec: ImageStreamSpec{
+			Tags: map[string]TagReference{
+				"latest": {Annotations: map[string]string{"owner": "team-a"}},
+				"bare":   {},
+			},
+		},
+	}
+
+	annotations := TagAnnotations(stream, "")
+	if e, a := "team-a", annotations["owner"]; e != a {
+		t.Errorf("expected owner %q, got %q", e, a)
+	}
+	annotations["owner"] = "mutated"
+	if e, a := "team-a", stream.Spec.Tags["latest"].Annotations["owner"]; e != a {
+		t.Errorf("expected stream annotations to be copied, got %q", a)
+	}
+	if annotations := TagAnnotations(stream, "bare"); annotations != nil {
+		t.Errorf("expected nil annotations for bare tag, got %v", annotations)
+	}
+	if annotations := TagAnnotations(stream, "missing"); annotations != nil {
+		t.Errorf("expected nil annotations for missing tag, got %v", annotations)
+	}
+}
+
 func TestAddTagEventToImageStream(t *testing.T) {
 	tests := map[string]struct {
 		tags           map[string]TagEventList
@@ -899,6 +1041,146 @@ func TestUpdateTrackingTags(t *testing.T) {
 	}
 }
 
+func TestTrimTagHistory(t *testing.T) {
+	tests := map[string]struct {
+		limit                int
+		tags                 map[string]TagEventList
+		expectedTags         map[string]TagEventList
+		expectedUnreferenced []string
+		expectedChanged      bool
+	}{
+		"no limit": {
+			tags: map[string]TagEventList{
+				"latest": {Items: []TagEvent{{Image: "c"}, {Image: "b"}, {Image: "a"}}},
+			},
+			expectedTags: map[string]TagEventList{
+				"latest": {Items: []TagEvent{{Image: "c"}, {Image: "b"}, {Image: "a"}}},
+			},
+		},
+		"history within the limit": {
+			limit: 3,
+			tags: map[string]TagEventList{
+				"latest": {Items: []TagEvent{{Image: "c"}, {Image: "b"}, {Image: "a"}}},
+			},
+			expectedTags: map[string]TagEventList{
+				"latest": {Items: []TagEvent{{Image: "c"}, {Image: "b"}, {Image: "a"}}},
+			},
+		},
+		"history exceeding the limit": {
+			limit: 1,
+			tags: map[string]TagEventList{
+				"latest": {Items: []TagEvent{{Image: "c"}, {Image: "b"}, {Image: "a"}}},
+				"1.0":    {Items: []TagEvent{{Image: "b"}}},
+			},
+			expectedTags: map[string]TagEventList{
+				"latest": {Items: []TagEvent{{Image: "c"}}},
+				"1.0":    {Items: []TagEvent{{Image: "b"}}},
+			},
+			expectedUnreferenced: []string{"a"},
+			expectedChanged:      true,
+		},
+	}
+
+	for name, test := range tests {
+		stream := &ImageStream{
+			Spec:   ImageStreamSpec{TagHistoryLimit: test.limit},
+			Status: ImageStreamStatus{Tags: test.tags},
+		}
+		unreferenced, changed := TrimTagHistory(stream)
+		if changed != test.expectedChanged {
+			t.Errorf("%s: expected changed=%t, got %t", name, test.expectedChanged, changed)
+		}
+		if len(unreferenced) != 0 || len(test.expectedUnreferenced) != 0 {
+			if !reflect.DeepEqual(test.expectedUnreferenced, unreferenced) {
+				t.Errorf("%s: expected unreferenced images %v, got %v", name, test.expectedUnreferenced, unreferenced)
+			}
+		}
+		if !reflect.DeepEqual(test.expectedTags, stream.Status.Tags) {
+			t.Errorf("%s: unexpected tags: %s", name, util.ObjectDiff(test.expectedTags, stream.Status.Tags))
+		}
+	}
+}
+
+func TestTagImportFailure(t *testing.T) {
+	stream := &ImageStream{ObjectMeta: kapi.ObjectMeta{Generation: 2}}
+	if ClearTagImportFailed(stream, "latest") {
+		t.Errorf("unexpected change of a tag never imported")
+	}
+
+	first := unversioned.Date(2015, 3, 24, 9, 38, 0, 0, time.UTC)
+	SetTagImportFailed(stream, "latest", ImportNotFoundReason, "not found", first)
+	stream.Generation = 3
+	next := unversioned.Date(2015, 3, 24, 9, 40, 0, 0, time.UTC)
+	SetTagImportFailed(stream, "latest", ImportUnauthorizedReason, "permission denied", next)
+
+	expected := &TagEventCondition{
+		Type:               ImportSuccess,
+		Status:             kapi.ConditionFalse,
+		LastTransitionTime: first,
+		Reason:             ImportUnauthorizedReason,
+		Message:            "permission denied",
+		Failures:           2,
+		LastAttemptTime:    next,
+		Generation:         3,
+	}
+	if condition := TagImportFailure(stream, "latest"); !reflect.DeepEqual(expected, condition) {
+		t.Errorf("unexpected condition: %s", util.ObjectDiff(expected, condition))
+	}
+	if len(stream.Status.Tags["latest"].Conditions) != 1 {
+		t.Errorf("expected a single condition, got %#v", stream.Status.Tags["latest"].Conditions)
+	}
+
+	if !ClearTagImportFailed(stream, "latest") {
+		t.Errorf("expected the failure to be cleared")
+	}
+	if condition := TagImportFailure(stream, "latest"); condition != nil {
+		t.Errorf("unexpected condition %#v", condition)
+	}
+}
+
+func TestMirrorsTag(t *testing.T) {
+	tests := map[string]struct {
+		policy   *RepositoryMirrorPolicy
+		mirrored []string
+		ignored  []string
+	}{
+		"no policy": {
+			ignored: []string{"latest"},
+		},
+		"every tag": {
+			policy:   &RepositoryMirrorPolicy{},
+			mirrored: []string{"latest", "1.0"},
+		},
+		"included tags": {
+			policy:   &RepositoryMirrorPolicy{IncludeTags: []string{"1.*", "latest"}},
+			mirrored: []string{"latest", "1.0", "1.0-rc1"},
+			ignored:  []string{"2.0", "nightly"},
+		},
+		"excluded tags": {
+			policy:   &RepositoryMirrorPolicy{IncludeTags: []string{"1.*"}, ExcludeTags: []string{"*-rc?"}},
+			mirrored: []string{"1.0"},
+			ignored:  []string{"1.0-rc1", "latest"},
+		},
+		"invalid pattern": {
+			policy:  &RepositoryMirrorPolicy{IncludeTags: []string{"["}},
+			ignored: []string{"["},
+		},
+	}
+
+	for name, test := range tests {
+		for _, tag := range test.mirrored {
+			if !MirrorsTag(test.policy, tag) {
+				t.Errorf("%s: expected tag %s to be mirrored", name, tag)
+			}
+		}
+		for _, tag := range test.ignored {
+			if MirrorsTag(test.policy, tag) {
+				t.Errorf("%s: expected tag %s not to be mirrored", name, tag)
+			}
+		}
+	}
+}
+
 func TestJoinImageStreamTag(t *testing.T) {
 	if e, a := "foo:bar", JoinImageStreamTag("foo", "bar"); e != a {
 		t.Errorf("Unexpected value: %s", a)
@@ -1090,3 +1372,121 @@ func TestDockerImageReferenceEquality(t *testing.T) {
 		}
 	}
 }
+
+func TestImageSize(t *testing.T) {
+	manifest := `{"schemaVersion": 1, "fsLayers": [{"blobSum": "sha256:a"}, {"blobSum": "sha256:b"}, {"blobSum": "sha256:a"}], "history": [{"v1Compatibility": "{\"id\": \"3\", \"size\": 100}"}, {"v1Compatibility": "{\"id\": \"2\", \"size\": 20}"}, {"v1Compatibility": "{\"id\": \"1\", \"size\": 100}"}]}`
+	tests := map[string]struct {
+		image    Image
+		size     int64
+		expectOK bool
+	}{
+		"unknown": {},
+		"layers annotation": {
+			image: Image{
+				ObjectMeta:          kapi.ObjectMeta{Annotations: map[string]string{ImageLayersAnnotation: `[{"name":"sha256:a","size":1000},{"name":"sha256:b","size":24}]`}},
+				DockerImageManifest: manifest,
+			},
+			size:     1024,
+			expectOK: true,
+		},
+		"layers annotation missing a layer": {
+			image: Image{
+				ObjectMeta:          kapi.ObjectMeta{Annotations: map[string]string{ImageLayersAnnotation: `[{"name":"sha256:a","size":1000}]`}},
+				DockerImageManifest: manifest,
+			},
+		},
+		"layers annotation without manifest": {
+			image: Image{
+				ObjectMeta: kapi.ObjectMeta{Annotations: map[string]string{ImageLayersAnnotation: `[{"name":"sha256:a","size":1000}]`}},
+			},
+			size:     1000,
+			expectOK: true,
+		},
+		"negative layer size": {
+			image: Image{
+				ObjectMeta: kapi.ObjectMeta{Annotations: map[string]string{ImageLayersAnnotation: `[{"name":"sha256:a","size":-1000}]`}},
+			},
+		},
+		"size annotation ignored": {
+			image: Image{
+				ObjectMeta: kapi.ObjectMeta{Annotations: map[string]string{ImageSizeAnnotation: "1024"}},
+			},
+		},
+		"manifest": {
+			image:    Image{DockerImageManifest: manifest},
+			size:     120,
+			expectOK: true,
+		},
+		"invalid manifest": {
+			image: Image{DockerImageManifest: "{"},
+		},
+	}
+	for name, test := range tests {
+		size, ok := ImageSize(&test.image)
+		if size != test.size || ok != test.expectOK {
+			t.Errorf("%s: expected %d, %t, got %d, %t", name, test.size, test.expectOK, size, ok)
+		}
+	}
+}
+
+func TestHasFinalizer(t *testing.T) {
+	image := &Image{Finalizers: []kapi.FinalizerName{"other", ImageFinalizerRegistry}}
+	if !HasFinalizer(image, ImageFinalizerRegistry) {
+		t.Errorf("expected the image to have the registry finalizer")
+	}
+	if HasFinalizer(&Image{}, ImageFinalizerRegistry) {
+		t.Errorf("expected an image without finalizers not to have the registry finalizer")
+	}
+}
+
+func TestImageLayerSizes(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		layers      []ImageLayerSize
+		expectOK    bool
+	}{
+		"no annotation": {},
+		"layers": {
+			annotations: map[string]string{ImageLayersAnnotation: `[{"name":"sha256:a","size":1000},{"name":"sha256:b","size":24}]`},
+			layers:      []ImageLayerSize{{Name: "sha256:a", Size: 1000}, {Name: "sha256:b", Size: 24}},
+			expectOK:    true,
+		},
+		"no layers": {
+			annotations: map[string]string{ImageLayersAnnotation: `[]`},
+			layers:      []ImageLayerSize{},
+			expectOK:    true,
+		},
+		"invalid annotation": {
+			annotations: map[string]string{ImageLayersAnnotation: `{`},
+		},
+	}
+	for name, test := range tests {
+		image := &Image{ObjectMeta: kapi.ObjectMeta{Annotations: test.annotations}}
+		layers, ok := ImageLayerSizes(image)
+		if !reflect.DeepEqual(layers, test.layers) || ok != test.expectOK {
+			t.Errorf("%s: expected %#v, %t, got %#v, %t", name, test.layers, test.expectOK, layers, ok)
+		}
+	}
+}
+
+func TestImageSizeLimit(t *testing.T) {
+	limitRange := func(name string, limitType kapi.LimitType, max int64) kapi.LimitRange {
+		return kapi.LimitRange{
+			ObjectMeta: kapi.ObjectMeta{Name: name},
+			Spec: kapi.LimitRangeSpec{
+				Limits: []kapi.LimitRangeItem{{Type: limitType, Max: kapi.ResourceList{kapi.ResourceStorage: *resource.NewQuantity(max, resource.BinarySI)}}},
+			},
+		}
+	}
+	if _, _, ok := ImageSizeLimit([]kapi.LimitRange{limitRange("pods", kapi.LimitTypePod, 10)}); ok {
+		t.Errorf("expected no image size limit")
+	}
+	max, name, ok := ImageSizeLimit([]kapi.LimitRange{
+		limitRange("large", LimitTypeImage, 2048),
+		limitRange("small", LimitTypeImage, 1024),
+		limitRange("pods", kapi.LimitTypePod, 10),
+	})
+	if !ok || max != 1024 || name != "small" {
+		t.Errorf("expected the smallest limit, got %d, %q, %t", max, name, ok)
+	}
+}
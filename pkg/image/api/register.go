@@ -14,6 +14,10 @@ func init() {
 		&ImageStreamTag{},
 		&ImageStreamTagList{},
 		&ImageStreamImage{},
+		&ImageStreamImport{},
+		&ImageLayer{},
+		&ImageLayerList{},
+		&ImageSignature{},
 		&DockerImage{},
 	)
 }
@@ -27,3 +31,7 @@ func (*ImageStreamMapping) IsAnAPIObject() {}
 func (*ImageStreamTag) IsAnAPIObject()     {}
 func (*ImageStreamTagList) IsAnAPIObject() {}
 func (*ImageStreamImage) IsAnAPIObject()   {}
+func (*ImageStreamImport) IsAnAPIObject()  {}
+func (*ImageSignature) IsAnAPIObject()     {}
+func (*ImageLayer) IsAnAPIObject()         {}
+func (*ImageLayerList) IsAnAPIObject()     {}
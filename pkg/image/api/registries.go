@@ -0,0 +1,69 @@
+package api
+
+import (
+	"strings"
+)
+
+// RegistryPolicy restricts the registries from which images may be imported
+// or run.
+type RegistryPolicy struct {
+	// Allowed lists the registries images may come from. If empty, all the
+	// registries that aren't blocked are allowed.
+	Allowed []string
+	// Blocked lists the registries images may never come from.
+	Blocked []string
+}
+
+// IsEmpty returns true if p allows all the registries.
+func (p RegistryPolicy) IsEmpty() bool {
+	return len(p.Allowed) == 0 && len(p.Blocked) == 0
+}
+
+// Allows returns true if images may come from registry, a host with an
+// optional port. An empty registry is the Docker Hub.
+func (p RegistryPolicy) Allows(registry string) bool {
+	for _, pattern := range p.Blocked {
+		if RegistryMatches(pattern, registry) {
+			return false
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return true
+	}
+	for _, pattern := range p.Allowed {
+		if RegistryMatches(pattern, registry) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistryMatches returns true if registry matches pattern, a host with an
+// optional port which may start with a "*." wildcard matching any subdomain of
+// the rest of the host. A pattern without a port matches any port. The
+// different names of the Docker Hub, including the empty registry, are all
+// matched by "docker.io".
+func RegistryMatches(pattern, registry string) bool {
+	patternHost, patternPort := splitRegistry(pattern)
+	host, port := splitRegistry(registry)
+	if len(patternPort) > 0 && patternPort != port {
+		return false
+	}
+	if strings.HasPrefix(patternHost, "*.") {
+		return strings.HasSuffix(host, patternHost[1:])
+	}
+	return patternHost == host
+}
+
+// splitRegistry returns the normalized host and the port of registry.
+func splitRegistry(registry string) (string, string) {
+	host, port := strings.ToLower(registry), ""
+	if i := strings.LastIndex(host, ":"); i != -1 {
+		host, port = host[:i], host[i+1:]
+	}
+	switch host {
+	case "", "index.docker.io", "registry-1.docker.io":
+		host = DockerDefaultRegistry
+	}
+	return host, port
+}
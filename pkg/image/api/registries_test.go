@@ -0,0 +1,66 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestRegistryPolicyAllows(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RegistryPolicy
+		registries map[string]bool
+	}{
+		{
+			name:   "empty",
+			policy: RegistryPolicy{},
+			registries: map[string]bool{
+				"":                     true,
+				"docker.io":            true,
+				"registry.example.com": true,
+			},
+		},
+		{
+			name:   "blocked docker hub",
+			policy: RegistryPolicy{Blocked: []string{"docker.io"}},
+			registries: map[string]bool{
+				"":                     false,
+				"index.docker.io":      false,
+				"Registry-1.Docker.io": false,
+				"registry.example.com": true,
+			},
+		},
+		{
+			name:   "allowed wildcard",
+			policy: RegistryPolicy{Allowed: []string{"*.example.com", "172.30.1.1:5000"}},
+			registries: map[string]bool{
+				"":                          false,
+				"example.com":               false,
+				"registry.example.com":      true,
+				"registry.example.com:5000": true,
+				"registry.example.org":      false,
+				"172.30.1.1":                false,
+				"172.30.1.1:5000":           true,
+				"172.30.1.1:5001":           false,
+			},
+		},
+		{
+			name: "blocked wins",
+			policy: RegistryPolicy{
+				Allowed: []string{"*.example.com"},
+				Blocked: []string{"untrusted.example.com"},
+			},
+			registries: map[string]bool{
+				"trusted.example.com":        true,
+				"untrusted.example.com":      false,
+				"untrusted.example.com:5000": false,
+			},
+		},
+	}
+	for _, test := range tests {
+		for registry, expected := range test.registries {
+			if actual := test.policy.Allows(registry); actual != expected {
+				t.Errorf("%s: expected %q to be allowed=%t, got %t", test.name, registry, expected, actual)
+			}
+		}
+	}
+}
@@ -25,10 +25,80 @@ const (
 	// InsecureRepositoryAnnotation may be set true on an image stream to allow insecure access to pull content.
 	InsecureRepositoryAnnotation = "openshift.io/image.insecureRepository"
 
+	// MirrorPullthroughAnnotation may be set true or false on an image stream to
+	// have the registry store the layers it pulls through from remote
+	// registries, or not, overriding the registry configuration.
+	MirrorPullthroughAnnotation = "openshift.io/image.mirrorPullthrough"
+
+	// BlobRedirectAnnotation may be set false on an image stream to have the
+	// registry serve its layers itself, instead of redirecting the clients to
+	// the storage backend they may not be able to reach.
+	BlobRedirectAnnotation = "openshift.io/image.blobRedirect"
+
+	// ImageSizeAnnotation is set by the registry on the images pushed to it to
+	// the total size in bytes of their distinct layers.
+	ImageSizeAnnotation = "openshift.io/image.size"
+
+	// ImageLayersAnnotation is set by the registry on the images pushed to it
+	// to the JSON list of their distinct layers with their sizes in bytes,
+	// e.g. [{"name":"sha256:...","size":1024}].
+	ImageLayersAnnotation = "openshift.io/image.layers"
+
+	// ImageStreamStorageSizeAnnotation is set by the master on the image
+	// streams to the total size in bytes of the distinct layers of the images
+	// pushed to them, as kept in the storage of the registry. It's missing if
+	// the size isn't known.
+	ImageStreamStorageSizeAnnotation = "openshift.io/image.storageSize"
+
+	// ImageStreamPullsAnnotation is set by the registry on the image streams
+	// to the number of times their images were pulled from it.
+	ImageStreamPullsAnnotation = "openshift.io/image.pulls"
+
+	// ImageStreamPushesAnnotation is set by the registry on the image streams
+	// to the number of images pushed to them.
+	ImageStreamPushesAnnotation = "openshift.io/image.pushes"
+
+	// ImageStreamLastPulledAnnotation is set by the registry on the image
+	// streams to the time one of their images was last pulled from it, in
+	// RFC 3339 format.
+	ImageStreamLastPulledAnnotation = "openshift.io/image.lastPulled"
+
+	// ImagePromotedFromAnnotation is set by "oc tag --from-cluster" on the
+	// tags it copies an image to, to the pull spec by digest of the image in
+	// the integrated registry of the other cluster.
+	ImagePromotedFromAnnotation = "openshift.io/image.promotedFrom"
+
+	// ImagePromotedFromClusterAnnotation is set by "oc tag --from-cluster" on
+	// the tags it copies an image to, to the address of the API server of the
+	// cluster the image was copied from.
+	ImagePromotedFromClusterAnnotation = "openshift.io/image.promotedFromCluster"
+
+	// ImageMarkedForPruningAnnotation is set to "true" by "oc delete istag" on
+	// the images of the deleted tag that no image stream references anymore,
+	// and by the tag history controller on the images it removes from the
+	// history of a stream. These images are pruned regardless of their age,
+	// and aren't kept as untagged images pushed to a stream.
+	ImageMarkedForPruningAnnotation = "openshift.io/image.markedForPruning"
+
 	// DefaultImageTag is used when an image tag is needed and the configuration does not specify a tag to use.
 	DefaultImageTag = "latest"
 )
 
+const (
+	// ResourceImageStreams is the ResourceQuota resource that limits the number of image streams in a project.
+	ResourceImageStreams kapi.ResourceName = "openshift.io/imagestreams"
+	// ResourceImages is the LimitRange resource that limits the number of images in an image stream, and
+	// the ResourceQuota resource that limits the number of unique images referenced by the image streams
+	// of a project.
+	ResourceImages kapi.ResourceName = "openshift.io/images"
+
+	// LimitTypeImageStream is the LimitRange type that constrains image streams.
+	LimitTypeImageStream kapi.LimitType = "openshift.io/ImageStream"
+	// LimitTypeImage is the LimitRange type that constrains images. Its "storage" maximum is the
+	// maximum total size of the distinct layers of an image tagged into an image stream.
+	LimitTypeImage kapi.LimitType = "openshift.io/Image"
+)
+
 // Image is an immutable representation of a Docker image and metadata at a point in time.
 type Image struct {
 	unversioned.TypeMeta
@@ -42,6 +112,43 @@ type Image struct {
 	DockerImageMetadataVersion string
 	// The raw JSON of the manifest
 	DockerImageManifest string
+	// Signatures holds the signatures of the image.
+	Signatures []ImageSignature
+	// Finalizers must be empty before the image is removed from storage. An
+	// image being deleted is kept until each finalizer is removed through the
+	// finalize subresource.
+	Finalizers []kapi.FinalizerName
+}
+
+const (
+	// ImageFinalizerRegistry is the finalizer of the images managed by the
+	// integrated registry, removed once the manifest, signatures and
+	// unreferenced layers of the image were deleted from the registry storage.
+	ImageFinalizerRegistry kapi.FinalizerName = "openshift.io/registry"
+)
+
+const (
+	// ImageSignatureTypeAtomicImageV1 is the type of the signatures produced by
+	// the atomic and skopeo tools.
+	ImageSignatureTypeAtomicImageV1 = "AtomicImageV1"
+)
+
+// ImageSignature holds a signature of an image. Signatures are stored with the
+// image they sign, they are added and removed through the imageSignatures
+// resource, where they are named <image name>@<signature name>.
+type ImageSignature struct {
+	unversioned.TypeMeta
+	kapi.ObjectMeta
+
+	// Type of the signature, which defines the format of its content.
+	Type string
+	// Content is the opaque signature.
+	Content []byte
+	// ImageIdentity is the identity of the image claimed by the signature, e.g.
+	// the pull spec it was signed for.
+	ImageIdentity string
+	// SignedClaims holds the additional claims of the signature.
+	SignedClaims map[string]string
 }
 
 // ImageStreamList is a list of ImageStream objects.
@@ -71,6 +178,26 @@ type ImageStreamSpec struct {
 	DockerImageRepository string
 	// Tags map arbitrary string values to specific image locators
 	Tags map[string]TagReference
+	// TagHistoryLimit is the maximum number of items kept in the history of
+	// each tag in the status, the oldest items are removed first. Zero keeps
+	// the whole history.
+	TagHistoryLimit int
+	// Mirror, if set, imports every tag of DockerImageRepository allowed by
+	// the policy, and keeps the tags up to date, new tags included, by
+	// importing them periodically.
+	Mirror *RepositoryMirrorPolicy
+}
+
+// RepositoryMirrorPolicy describes the tags of a docker repository mirrored
+// into an image stream. The patterns are shell file name patterns, as
+// accepted by path.Match.
+type RepositoryMirrorPolicy struct {
+	// IncludeTags lists the patterns of the tags to mirror, every tag is
+	// mirrored if it is empty.
+	IncludeTags []string
+	// ExcludeTags lists the patterns of the tags not to mirror, it takes
+	// precedence over IncludeTags.
+	ExcludeTags []string
 }
 
 // TagReference specifies optional annotations for images using this tag and an optional reference to an ImageStreamTag, ImageStreamImage, or DockerImage this tag should track.
@@ -81,6 +208,63 @@ type TagReference struct {
 	From *kapi.ObjectReference
 	// Reference states if the tag will be imported. Default value is false, which means the tag will be imported.
 	Reference bool
+	// ImportPolicy is information that controls how images may be imported by the server.
+	ImportPolicy TagImportPolicy
+	// ReferencePolicy defines how other components should consume the image.
+	ReferencePolicy TagReferencePolicy
+	// Immutable prevents the tag from being changed once it references an image. Pushes, imports and
+	// spec changes that would replace its image are rejected until it is made mutable again.
+	Immutable bool
+	// Track is a range of semantic versions, e.g. ">=2.1 <3.0". The tag references the image of the
+	// tag of the stream whose name is the highest version in the range, and is updated when tags with
+	// higher versions are imported or pushed. A tag tracking versions has no From.
+	Track string
+}
+
+// TagImportPolicy describes the tag related policy for importing its images.
+type TagImportPolicy struct {
+	// Scheduled indicates to the server that this tag should be periodically checked to ensure it is up to date, and imported
+	Scheduled bool
+	// Insecure is true if the server may bypass certificate verification or connect directly over HTTP during image import.
+	Insecure bool
+	// ImportMode is Import, the default, if the image of the tag is imported, or Reference if the tag
+	// only references its source.
+	ImportMode TagImportMode
+}
+
+// TagImportMode describes whether the image of a tag is imported from its source.
+type TagImportMode string
+
+const (
+	// ImportTagImportMode indicates the metadata of the image of the tag is imported, and the tag
+	// references the image by its digest. It is the default.
+	ImportTagImportMode TagImportMode = "Import"
+	// ReferenceTagImportMode indicates the tag references the pull spec of its source as is, without
+	// importing its image. It is equivalent to setting reference on the tag.
+	ReferenceTagImportMode TagImportMode = "Reference"
+)
+
+// TagReferencePolicyType describes how pull specs for images in an image stream tag are generated when
+// image change triggers are fired.
+type TagReferencePolicyType string
+
+const (
+	// SourceTagReferencePolicy indicates the image's original location should be used when the image stream tag
+	// is resolved into other resources (builds and deployments).
+	SourceTagReferencePolicy TagReferencePolicyType = "Source"
+	// LocalTagReferencePolicy indicates the image should prefer to pull via the integrated registry, which
+	// serves the image once it has been pulled through or mirrored.
+	LocalTagReferencePolicy TagReferencePolicyType = "Local"
+)
+
+// TagReferencePolicy describes how pull specs for images in this image stream tag are generated when
+// image change triggers in deployment configs or builds are resolved.
+type TagReferencePolicy struct {
+	// Type determines how the image pull spec should be transformed when the image stream tag is used in
+	// deployment config triggers or new builds. The default value is Source, indicating the original
+	// location of the image should be used. Local resolves the tag to the pull spec of the image in the
+	// integrated registry.
+	Type TagReferencePolicyType
 }
 
 // ImageStreamStatus contains information about the state of this image stream.
@@ -96,6 +280,8 @@ type ImageStreamStatus struct {
 // TagEventList contains a historical record of images associated with a tag.
 type TagEventList struct {
 	Items []TagEvent
+	// Conditions is an array of conditions that apply to the tag event list.
+	Conditions []TagEventCondition
 }
 
 // TagEvent is used by ImageRepositoryStatus to keep a historical record of images associated with a tag.
@@ -108,6 +294,55 @@ type TagEvent struct {
 	Image string
 }
 
+// TagEventConditionType is the type of a TagEventCondition.
+type TagEventConditionType string
+
+// These are valid conditions of TagEvents.
+const (
+	// ImportSuccess with status False means the last import of the tag failed.
+	ImportSuccess TagEventConditionType = "ImportSuccess"
+)
+
+// These are the reasons of the ImportSuccess conditions of tags whose last
+// import failed.
+const (
+	// ImportFailedReason is the reason of a failure without a more specific
+	// reason.
+	ImportFailedReason = "ImportFailed"
+	// ImportUnauthorizedReason means the registry asked for credentials and
+	// refused those it was given, or there were none.
+	ImportUnauthorizedReason = "Unauthorized"
+	// ImportNotFoundReason means the registry, the repository or the image
+	// was not found.
+	ImportNotFoundReason = "NotFound"
+	// ImportTimeoutReason means the registry didn't answer in time.
+	ImportTimeoutReason = "Timeout"
+	// ImportForbiddenReason means the registry isn't allowed by the registry
+	// policy of the cluster.
+	ImportForbiddenReason = "Forbidden"
+)
+
+// TagEventCondition contains condition information for a tag event.
+type TagEventCondition struct {
+	// Type of tag event condition, currently only ImportSuccess
+	Type TagEventConditionType
+	// Status of the condition, one of True, False, Unknown.
+	Status kapi.ConditionStatus
+	// LastTransitionTime is the time the condition transitioned from one status to another.
+	LastTransitionTime unversioned.Time
+	// Reason is a brief machine readable explanation for the condition's last transition.
+	Reason string
+	// Message is a human readable description of the details about last transition, complementing reason.
+	Message string
+	// Failures is the number of consecutive failed imports of the tag.
+	Failures int
+	// LastAttemptTime is the time of the last failed import of the tag.
+	LastAttemptTime unversioned.Time
+	// Generation is the generation of the image stream the last failed import
+	// of the tag was attempted for.
+	Generation int64
+}
+
 // ImageStreamMapping represents a mapping from a single tag to a Docker image as
 // well as the reference to the Docker image repository the image came from.
 type ImageStreamMapping struct {
@@ -149,6 +384,104 @@ type ImageStreamImage struct {
 	Image Image
 }
 
+// ImageStreamImport imports images from remote repositories into an image
+// stream, and reports the outcome of the import of every image. The images are
+// looked up, without changing the image stream, unless Spec.Import is true.
+type ImageStreamImport struct {
+	unversioned.TypeMeta
+	kapi.ObjectMeta
+
+	// Spec describes the images to import.
+	Spec ImageStreamImportSpec
+	// Status is the outcome of the import.
+	Status ImageStreamImportStatus
+}
+
+// ImageStreamImportSpec describes the images to import.
+type ImageStreamImportSpec struct {
+	// Import, if true, creates the images and tags them in the image stream,
+	// which is created if needed.
+	Import bool
+	// Repository is a repository whose tags are all imported.
+	Repository *RepositoryImportSpec
+	// Images are the images to import.
+	Images []ImageImportSpec
+}
+
+// RepositoryImportSpec describes a repository whose tags are all imported.
+type RepositoryImportSpec struct {
+	// From is the DockerImage pull spec of the repository.
+	From kapi.ObjectReference
+}
+
+// ImageImportSpec describes an image to import.
+type ImageImportSpec struct {
+	// From is the DockerImage pull spec of the image.
+	From kapi.ObjectReference
+	// To is the tag of the image stream the image is imported to. The image is
+	// only looked up if it's not set.
+	To *kapi.LocalObjectReference
+	// ImportPolicy is the import policy of the tag.
+	ImportPolicy TagImportPolicy
+}
+
+// ImageStreamImportStatus is the outcome of an import.
+type ImageStreamImportStatus struct {
+	// Import is the image stream the images were imported to, if Spec.Import
+	// is true.
+	Import *ImageStream
+	// Repository is the outcome of the import of Spec.Repository.
+	Repository *RepositoryImportStatus
+	// Images are the outcomes of the imports of Spec.Images, in the same order.
+	Images []ImageImportStatus
+}
+
+// RepositoryImportStatus is the outcome of the import of a repository.
+type RepositoryImportStatus struct {
+	// Status is the outcome of the listing of the tags of the repository.
+	Status unversioned.Status
+	// Images are the outcomes of the imports of the tags of the repository.
+	Images []ImageImportStatus
+}
+
+// ImageImportStatus is the outcome of the import of an image.
+type ImageImportStatus struct {
+	// Status is the outcome of the import, with the reason of the failure.
+	Status unversioned.Status
+	// Image is the image imported, if the import succeeded.
+	Image *Image
+	// Tag is the tag the image is imported to, if any.
+	Tag string
+}
+
+// ImageLayer describes a layer of the images referenced by the image streams
+// of a project, and whether its storage is shared with other projects.
+type ImageLayer struct {
+	unversioned.TypeMeta
+	// Name is the digest of the layer.
+	kapi.ObjectMeta
+
+	// Size is the size of the layer in bytes, zero if it isn't known.
+	Size int64
+	// Images are the names of the images of the project with the layer.
+	Images []string
+	// ImageStreams are the names of the image streams of the project
+	// referencing images with the layer.
+	ImageStreams []string
+	// Shared is true if images referenced by image streams of other projects
+	// have the layer too, so that its storage is shared with them. It is only
+	// computed for the users who may list the image streams of all projects.
+	Shared bool
+}
+
+// ImageLayerList is a list of ImageLayer objects.
+type ImageLayerList struct {
+	unversioned.TypeMeta
+	unversioned.ListMeta
+
+	Items []ImageLayer
+}
+
 // DockerImageReference points to a Docker image.
 type DockerImageReference struct {
 	Registry  string
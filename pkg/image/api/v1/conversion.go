@@ -18,6 +18,12 @@ func convert_api_Image_To_v1_Image(in *newer.Image, out *Image, s conversion.Sco
 
 	out.DockerImageReference = in.DockerImageReference
 	out.DockerImageManifest = in.DockerImageManifest
+	if err := s.Convert(&in.Signatures, &out.Signatures, 0); err != nil {
+		return err
+	}
+	if err := s.Convert(&in.Finalizers, &out.Finalizers, 0); err != nil {
+		return err
+	}
 
 	version := in.DockerImageMetadataVersion
 	if len(version) == 0 {
@@ -40,6 +46,12 @@ func convert_v1_Image_To_api_Image(in *Image, out *newer.Image, s conversion.Sco
 
 	out.DockerImageReference = in.DockerImageReference
 	out.DockerImageManifest = in.DockerImageManifest
+	if err := s.Convert(&in.Signatures, &out.Signatures, 0); err != nil {
+		return err
+	}
+	if err := s.Convert(&in.Finalizers, &out.Finalizers, 0); err != nil {
+		return err
+	}
 
 	version := in.DockerImageMetadataVersion
 	if len(version) == 0 {
@@ -65,6 +77,10 @@ func convert_v1_Image_To_api_Image(in *Image, out *newer.Image, s conversion.Sco
 
 func convert_v1_ImageStreamSpec_To_api_ImageStreamSpec(in *ImageStreamSpec, out *newer.ImageStreamSpec, s conversion.Scope) error {
 	out.DockerImageRepository = in.DockerImageRepository
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if err := s.Convert(&in.Mirror, &out.Mirror, 0); err != nil {
+		return err
+	}
 	out.Tags = make(map[string]newer.TagReference)
 	return s.Convert(&in.Tags, &out.Tags, 0)
 }
@@ -80,6 +96,10 @@ func convert_api_ImageStreamSpec_To_v1_ImageStreamSpec(in *newer.ImageStreamSpec
 			}
 		}
 	}
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if err := s.Convert(&in.Mirror, &out.Mirror, 0); err != nil {
+		return err
+	}
 	out.Tags = make([]NamedTagReference, 0, 0)
 	return s.Convert(&in.Tags, &out.Tags, 0)
 }
@@ -113,14 +133,38 @@ func convert_v1_ImageStreamMapping_To_api_ImageStreamMapping(in *ImageStreamMapp
 	return s.DefaultConvert(in, out, conversion.SourceToDest)
 }
 
+// convert_api_TagReferencePolicy_To_v1_TagReferencePolicy defaults the policy
+// to Source, so that tags without one don't serialize an empty type.
+func convert_api_TagReferencePolicy_To_v1_TagReferencePolicy(in *newer.TagReferencePolicy, out *TagReferencePolicy, s conversion.Scope) error {
+	out.Type = TagReferencePolicyType(in.Type)
+	if len(out.Type) == 0 {
+		out.Type = SourceTagReferencePolicy
+	}
+	return nil
+}
+
 func init() {
-	err := kapi.Scheme.AddConversionFuncs(
+	err := kapi.Scheme.AddDefaultingFuncs(
+		func(obj *TagReferencePolicy) {
+			if len(obj.Type) == 0 {
+				obj.Type = SourceTagReferencePolicy
+			}
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = kapi.Scheme.AddConversionFuncs(
 		func(in *[]NamedTagEventList, out *map[string]newer.TagEventList, s conversion.Scope) error {
 			for _, curr := range *in {
 				newTagEventList := newer.TagEventList{}
 				if err := s.Convert(&curr.Items, &newTagEventList.Items, 0); err != nil {
 					return err
 				}
+				if err := s.Convert(&curr.Conditions, &newTagEventList.Conditions, 0); err != nil {
+					return err
+				}
 				(*out)[curr.Tag] = newTagEventList
 			}
 
@@ -139,6 +183,9 @@ func init() {
 				if err := s.Convert(&newTagEventList.Items, &oldTagEventList.Items, 0); err != nil {
 					return err
 				}
+				if err := s.Convert(&newTagEventList.Conditions, &oldTagEventList.Conditions, 0); err != nil {
+					return err
+				}
 
 				*out = append(*out, *oldTagEventList)
 			}
@@ -150,6 +197,14 @@ func init() {
 				r := newer.TagReference{
 					Annotations: curr.Annotations,
 					Reference:   curr.Reference,
+					Immutable:   curr.Immutable,
+					Track:       curr.Track,
+				}
+				if err := s.Convert(&curr.ImportPolicy, &r.ImportPolicy, 0); err != nil {
+					return err
+				}
+				if err := s.Convert(&curr.ReferencePolicy, &r.ReferencePolicy, 0); err != nil {
+					return err
 				}
 				if err := s.Convert(&curr.From, &r.From, 0); err != nil {
 					return err
@@ -171,6 +226,14 @@ func init() {
 					Name:        tag,
 					Annotations: newTagReference.Annotations,
 					Reference:   newTagReference.Reference,
+					Immutable:   newTagReference.Immutable,
+					Track:       newTagReference.Track,
+				}
+				if err := s.Convert(&newTagReference.ImportPolicy, &oldTagReference.ImportPolicy, 0); err != nil {
+					return err
+				}
+				if err := s.Convert(&newTagReference.ReferencePolicy, &oldTagReference.ReferencePolicy, 0); err != nil {
+					return err
 				}
 				if err := s.Convert(&newTagReference.From, &oldTagReference.From, 0); err != nil {
 					return err
@@ -188,6 +251,7 @@ func init() {
 		convert_api_ImageStreamStatus_To_v1_ImageStreamStatus,
 		convert_api_ImageStreamMapping_To_v1_ImageStreamMapping,
 		convert_v1_ImageStreamMapping_To_api_ImageStreamMapping,
+		convert_api_TagReferencePolicy_To_v1_TagReferencePolicy,
 	)
 	if err != nil {
 		// If one of the conversion functions is malformed, detect it immediately.
@@ -205,4 +269,10 @@ func init() {
 	); err != nil {
 		panic(err)
 	}
+
+	if err := kapi.Scheme.AddFieldLabelConversionFunc("v1", "ImageLayer",
+		oapi.GetFieldLabelConversionFunc(newer.ImageLayerToSelectableFields(&newer.ImageLayer{}), map[string]string{"name": "metadata.name", "imageStream": "imageStream"}),
+	); err != nil {
+		panic(err)
+	}
 }
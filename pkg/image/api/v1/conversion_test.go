@@ -2,6 +2,7 @@ package v1_test
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	kapi "k8s.io/kubernetes/pkg/api"
@@ -46,6 +47,46 @@ func TestRoundTripVersionedObject(t *testing.T) {
 	}
 }
 
+func TestRoundTripTagWithoutReferencePolicy(t *testing.T) {
+	stream := &newer.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Name: "foo", Namespace: "bar"},
+		Spec: newer.ImageStreamSpec{
+			Tags: map[string]newer.TagReference{
+				"latest": {From: &kapi.ObjectReference{Kind: "DockerImage", Name: "busybox"}},
+			},
+		},
+	}
+
+	data, err := kapi.Scheme.EncodeToVersion(stream, "v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), `"type":""`) {
+		t.Errorf("expected the reference policy to have a type, got %s", data)
+	}
+
+	obj, err := kapi.Scheme.Decode(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tag := obj.(*newer.ImageStream).Spec.Tags["latest"]
+	if tag.ReferencePolicy.Type != newer.SourceTagReferencePolicy {
+		t.Errorf("expected the reference policy to default to Source, got %#v", tag.ReferencePolicy)
+	}
+	if tag.From == nil || tag.From.Name != "busybox" {
+		t.Errorf("unable to round trip the tag: %#v", tag)
+	}
+
+	// stored tags without a reference policy are read with the default
+	obj, err = kapi.Scheme.Decode([]byte(`{"kind":"ImageStream","apiVersion":"v1","metadata":{"name":"foo"},"spec":{"tags":[{"name":"latest"}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if policy := obj.(*newer.ImageStream).Spec.Tags["latest"].ReferencePolicy; policy.Type != newer.SourceTagReferencePolicy {
+		t.Errorf("expected the reference policy to default to Source, got %#v", policy)
+	}
+}
+
 func TestFieldSelectors(t *testing.T) {
 	testutil.CheckFieldLabelConversions(t, "v1", "Image",
 		// Ensure all currently returned labels are supported
@@ -17,6 +17,10 @@ func init() {
 		&ImageStreamTag{},
 		&ImageStreamTagList{},
 		&ImageStreamImage{},
+		&ImageStreamImport{},
+		&ImageLayer{},
+		&ImageLayerList{},
+		&ImageSignature{},
 	)
 }
 
@@ -28,3 +32,7 @@ func (*ImageStreamMapping) IsAnAPIObject() {}
 func (*ImageStreamTag) IsAnAPIObject()     {}
 func (*ImageStreamTagList) IsAnAPIObject() {}
 func (*ImageStreamImage) IsAnAPIObject()   {}
+func (*ImageStreamImport) IsAnAPIObject()  {}
+func (*ImageSignature) IsAnAPIObject()     {}
+func (*ImageLayer) IsAnAPIObject()         {}
+func (*ImageLayerList) IsAnAPIObject()     {}
@@ -28,6 +28,28 @@ type Image struct {
 	DockerImageMetadataVersion string `json:"dockerImageMetadataVersion,omitempty" description:"conveys version of the object, if empty defaults to '1.0'"`
 	// DockerImageManifest is the raw JSON of the manifest
 	DockerImageManifest string `json:"dockerImageManifest,omitempty" description:"raw JSON of the manifest"`
+	// Signatures holds the signatures of the image.
+	Signatures []ImageSignature `json:"signatures,omitempty" description:"signatures of the image"`
+	// Finalizers must be empty before the image is removed from storage.
+	Finalizers []kapi.FinalizerName `json:"finalizers,omitempty" description:"an opaque list of values that must be empty to permanently remove the image from storage"`
+}
+
+// ImageSignature holds a signature of an image. Signatures are stored with the
+// image they sign, they are added and removed through the imageSignatures
+// resource, where they are named <image name>@<signature name>.
+type ImageSignature struct {
+	unversioned.TypeMeta `json:",inline"`
+	kapi.ObjectMeta      `json:"metadata,omitempty"`
+
+	// Type of the signature, which defines the format of its content.
+	Type string `json:"type" description:"type of the signature, which defines the format of its content"`
+	// Content is the opaque signature.
+	Content []byte `json:"content" description:"opaque signature, base64 encoded"`
+	// ImageIdentity is the identity of the image claimed by the signature, e.g.
+	// the pull spec it was signed for.
+	ImageIdentity string `json:"imageIdentity,omitempty" description:"identity of the image claimed by the signature, e.g. the pull spec it was signed for"`
+	// SignedClaims holds the additional claims of the signature.
+	SignedClaims map[string]string `json:"signedClaims,omitempty" description:"additional claims of the signature"`
 }
 
 // ImageStreamList is a list of ImageStream objects.
@@ -58,6 +80,26 @@ type ImageStreamSpec struct {
 	DockerImageRepository string `json:"dockerImageRepository,omitempty" description:"optional field if specified this stream is backed by a Docker repository on this server"`
 	// Tags map arbitrary string values to specific image locators
 	Tags []NamedTagReference `json:"tags,omitempty" description:"map arbitrary string values to specific image locators"`
+	// TagHistoryLimit is the maximum number of items kept in the history of
+	// each tag in the status, the oldest items are removed first. Zero keeps
+	// the whole history.
+	TagHistoryLimit int `json:"tagHistoryLimit,omitempty" description:"maximum number of items kept in the history of each tag, the oldest are removed first; zero keeps the whole history"`
+	// Mirror, if set, imports every tag of DockerImageRepository allowed by
+	// the policy, and keeps the tags up to date, new tags included, by
+	// importing them periodically.
+	Mirror *RepositoryMirrorPolicy `json:"mirror,omitempty" description:"if set, every tag of dockerImageRepository allowed by the policy is imported and kept up to date by periodic imports"`
+}
+
+// RepositoryMirrorPolicy describes the tags of a docker repository mirrored
+// into an image stream. The patterns are shell file name patterns, as
+// accepted by path.Match.
+type RepositoryMirrorPolicy struct {
+	// IncludeTags lists the patterns of the tags to mirror, every tag is
+	// mirrored if it is empty.
+	IncludeTags []string `json:"includeTags,omitempty" description:"patterns of the tags to mirror, every tag is mirrored if empty"`
+	// ExcludeTags lists the patterns of the tags not to mirror, it takes
+	// precedence over IncludeTags.
+	ExcludeTags []string `json:"excludeTags,omitempty" description:"patterns of the tags not to mirror, takes precedence over includeTags"`
 }
 
 // NamedTagReference specifies optional annotations for images using this tag and an optional reference to an ImageStreamTag, ImageStreamImage, or DockerImage this tag should track.
@@ -70,6 +112,60 @@ type NamedTagReference struct {
 	From *kapi.ObjectReference `json:"from,omitempty" description:"a reference to an image stream tag or image stream this tag should track"`
 	// Reference states if the tag will be imported. Default value is false, which means the tag will be imported.
 	Reference bool `json:"reference,omitempty" description:"if true consider this tag a reference only and do not attempt to import metadata about the image"`
+	// ImportPolicy is information that controls how images may be imported by the server.
+	ImportPolicy TagImportPolicy `json:"importPolicy,omitempty" description:"attributes controlling how the image of this tag is imported"`
+	// ReferencePolicy defines how other components should consume the image.
+	ReferencePolicy TagReferencePolicy `json:"referencePolicy,omitempty" description:"policy that determines how the pull spec of the image of this tag is resolved by other components"`
+	// Immutable prevents the tag from being changed once it references an image.
+	Immutable bool `json:"immutable,omitempty" description:"if true the image of this tag can't be replaced once it is set"`
+	// Track is a range of semantic versions the tag follows the highest tag of.
+	Track string `json:"track,omitempty" description:"range of semantic versions, e.g. '>=2.1 <3.0'; the tag references the image of the tag of this stream with the highest version in the range"`
+}
+
+// TagImportPolicy describes the tag related policy for importing its images.
+type TagImportPolicy struct {
+	// Scheduled indicates to the server that this tag should be periodically checked to ensure it is up to date, and imported
+	Scheduled bool `json:"scheduled,omitempty" description:"if true the server will periodically check to ensure this tag is up to date and import it"`
+	// Insecure is true if the server may bypass certificate verification or connect directly over HTTP during image import.
+	Insecure bool `json:"insecure,omitempty" description:"if true the server may bypass certificate verification or connect directly over HTTP when importing the image of this tag"`
+	// ImportMode is Import, the default, if the image of the tag is imported, or Reference if the tag
+	// only references its source.
+	ImportMode TagImportMode `json:"importMode,omitempty" description:"Import, the default, to import the image of this tag from its source, or Reference to reference the source without importing it"`
+}
+
+// TagImportMode describes whether the image of a tag is imported from its source.
+type TagImportMode string
+
+const (
+	// ImportTagImportMode indicates the metadata of the image of the tag is imported, and the tag
+	// references the image by its digest. It is the default.
+	ImportTagImportMode TagImportMode = "Import"
+	// ReferenceTagImportMode indicates the tag references the pull spec of its source as is, without
+	// importing its image. It is equivalent to setting reference on the tag.
+	ReferenceTagImportMode TagImportMode = "Reference"
+)
+
+// TagReferencePolicyType describes how pull specs for images in an image stream tag are generated when
+// image change triggers are fired.
+type TagReferencePolicyType string
+
+const (
+	// SourceTagReferencePolicy indicates the image's original location should be used when the image stream tag
+	// is resolved into other resources (builds and deployments).
+	SourceTagReferencePolicy TagReferencePolicyType = "Source"
+	// LocalTagReferencePolicy indicates the image should prefer to pull via the integrated registry, which
+	// serves the image once it has been pulled through or mirrored.
+	LocalTagReferencePolicy TagReferencePolicyType = "Local"
+)
+
+// TagReferencePolicy describes how pull specs for images in this image stream tag are generated when
+// image change triggers in deployment configs or builds are resolved.
+type TagReferencePolicy struct {
+	// Type determines how the image pull spec should be transformed when the image stream tag is used in
+	// deployment config triggers or new builds. The default value is Source, indicating the original
+	// location of the image should be used. Local resolves the tag to the pull spec of the image in the
+	// integrated registry.
+	Type TagReferencePolicyType `json:"type" description:"determines how the image pull spec is resolved, Source (the default) for the original location of the image, Local for the integrated registry"`
 }
 
 // ImageStreamStatus contains information about the state of this image stream.
@@ -86,6 +182,8 @@ type ImageStreamStatus struct {
 type NamedTagEventList struct {
 	Tag   string     `json:"tag" description:"the tag"`
 	Items []TagEvent `json:"items" description:"list of tag events related to the tag"`
+	// Conditions is an array of conditions that apply to the tag event list.
+	Conditions []TagEventCondition `json:"conditions,omitempty" description:"the set of conditions that apply to this tag"`
 }
 
 // TagEvent is used by ImageStreamStatus to keep a historical record of images associated with a tag.
@@ -98,6 +196,36 @@ type TagEvent struct {
 	Image string `json:"image" description:"the image"`
 }
 
+// TagEventConditionType is the type of a TagEventCondition.
+type TagEventConditionType string
+
+// These are valid conditions of TagEvents.
+const (
+	// ImportSuccess with status False means the last import of the tag failed.
+	ImportSuccess TagEventConditionType = "ImportSuccess"
+)
+
+// TagEventCondition contains condition information for a tag event.
+type TagEventCondition struct {
+	// Type of tag event condition, currently only ImportSuccess
+	Type TagEventConditionType `json:"type" description:"type of tag event condition, currently only ImportSuccess"`
+	// Status of the condition, one of True, False, Unknown.
+	Status kapi.ConditionStatus `json:"status" description:"status of the condition, one of True, False, Unknown"`
+	// LastTransitionTime is the time the condition transitioned from one status to another.
+	LastTransitionTime unversioned.Time `json:"lastTransitionTime,omitempty" description:"last time the condition transitioned from one status to another"`
+	// Reason is a brief machine readable explanation for the condition's last transition.
+	Reason string `json:"reason,omitempty" description:"one-word CamelCase reason for the condition's last transition"`
+	// Message is a human readable description of the details about last transition, complementing reason.
+	Message string `json:"message,omitempty" description:"human-readable message indicating details about last transition"`
+	// Failures is the number of consecutive failed imports of the tag.
+	Failures int `json:"failures,omitempty" description:"number of consecutive failed imports of the tag"`
+	// LastAttemptTime is the time of the last failed import of the tag.
+	LastAttemptTime unversioned.Time `json:"lastAttemptTime,omitempty" description:"time of the last failed import of the tag"`
+	// Generation is the generation of the image stream the last failed import
+	// of the tag was attempted for.
+	Generation int64 `json:"generation,omitempty" description:"generation of the image stream the last failed import of the tag was attempted for"`
+}
+
 // ImageStreamMapping represents a mapping from a single tag to a Docker image as
 // well as the reference to the Docker image stream the image came from.
 type ImageStreamMapping struct {
@@ -136,6 +264,105 @@ type ImageStreamImage struct {
 	Image Image `json:"image" description:"the image associated with the ImageStream and image name"`
 }
 
+// ImageStreamImport imports images from remote repositories into an image
+// stream, and reports the outcome of the import of every image. The images are
+// looked up, without changing the image stream, unless spec.import is true.
+type ImageStreamImport struct {
+	unversioned.TypeMeta `json:",inline"`
+	kapi.ObjectMeta      `json:"metadata,omitempty"`
+
+	// Spec describes the images to import.
+	Spec ImageStreamImportSpec `json:"spec" description:"the images to import"`
+	// Status is the outcome of the import.
+	Status ImageStreamImportStatus `json:"status" description:"the outcome of the import"`
+}
+
+// ImageStreamImportSpec describes the images to import.
+type ImageStreamImportSpec struct {
+	// Import, if true, creates the images and tags them in the image stream,
+	// which is created if needed.
+	Import bool `json:"import" description:"if true, the images are created and tagged in the image stream, which is created if needed"`
+	// Repository is a repository whose tags are all imported.
+	Repository *RepositoryImportSpec `json:"repository,omitempty" description:"a repository whose tags are all imported"`
+	// Images are the images to import.
+	Images []ImageImportSpec `json:"images,omitempty" description:"the images to import"`
+}
+
+// RepositoryImportSpec describes a repository whose tags are all imported.
+type RepositoryImportSpec struct {
+	// From is the DockerImage pull spec of the repository.
+	From kapi.ObjectReference `json:"from" description:"the DockerImage pull spec of the repository"`
+}
+
+// ImageImportSpec describes an image to import.
+type ImageImportSpec struct {
+	// From is the DockerImage pull spec of the image.
+	From kapi.ObjectReference `json:"from" description:"the DockerImage pull spec of the image"`
+	// To is the tag of the image stream the image is imported to. The image is
+	// only looked up if it's not set.
+	To *kapi.LocalObjectReference `json:"to,omitempty" description:"the tag of the image stream the image is imported to, the image is only looked up if not set"`
+	// ImportPolicy is the import policy of the tag.
+	ImportPolicy TagImportPolicy `json:"importPolicy,omitempty" description:"the import policy of the tag"`
+}
+
+// ImageStreamImportStatus is the outcome of an import.
+type ImageStreamImportStatus struct {
+	// Import is the image stream the images were imported to, if spec.import
+	// is true.
+	Import *ImageStream `json:"import,omitempty" description:"the image stream the images were imported to, if spec.import is true"`
+	// Repository is the outcome of the import of spec.repository.
+	Repository *RepositoryImportStatus `json:"repository,omitempty" description:"the outcome of the import of spec.repository"`
+	// Images are the outcomes of the imports of spec.images, in the same order.
+	Images []ImageImportStatus `json:"images,omitempty" description:"the outcomes of the imports of spec.images, in the same order"`
+}
+
+// RepositoryImportStatus is the outcome of the import of a repository.
+type RepositoryImportStatus struct {
+	// Status is the outcome of the listing of the tags of the repository.
+	Status unversioned.Status `json:"status" description:"the outcome of the listing of the tags of the repository"`
+	// Images are the outcomes of the imports of the tags of the repository.
+	Images []ImageImportStatus `json:"images,omitempty" description:"the outcomes of the imports of the tags of the repository"`
+}
+
+// ImageImportStatus is the outcome of the import of an image.
+type ImageImportStatus struct {
+	// Status is the outcome of the import, with the reason of the failure.
+	Status unversioned.Status `json:"status" description:"the outcome of the import, with the reason of the failure"`
+	// Image is the image imported, if the import succeeded.
+	Image *Image `json:"image,omitempty" description:"the image imported, if the import succeeded"`
+	// Tag is the tag the image is imported to, if any.
+	Tag string `json:"tag,omitempty" description:"the tag the image is imported to, if any"`
+}
+
+// ImageLayer describes a layer of the images referenced by the image streams
+// of a project, and whether its storage is shared with other projects.
+type ImageLayer struct {
+	unversioned.TypeMeta `json:",inline"`
+	// Name is the digest of the layer.
+	kapi.ObjectMeta `json:"metadata,omitempty"`
+
+	// Size is the size of the layer in bytes, zero if it isn't known.
+	Size int64 `json:"size" description:"size of the layer in bytes, zero if unknown"`
+	// Images are the names of the images of the project with the layer.
+	Images []string `json:"images" description:"names of the images of the project with the layer"`
+	// ImageStreams are the names of the image streams of the project
+	// referencing images with the layer.
+	ImageStreams []string `json:"imageStreams" description:"names of the image streams of the project referencing images with the layer"`
+	// Shared is true if images referenced by image streams of other projects
+	// have the layer too, so that its storage is shared with them. It is only
+	// computed for the users who may list the image streams of all projects.
+	Shared bool `json:"shared" description:"true if images referenced by image streams of other projects have the layer too, only computed for the users who may list the image streams of all projects"`
+}
+
+// ImageLayerList is a list of ImageLayer objects.
+type ImageLayerList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of image layers
+	Items []ImageLayer `json:"items" description:"list of image layers"`
+}
+
 // DockerImageReference points to a Docker image.
 type DockerImageReference struct {
 	Registry  string
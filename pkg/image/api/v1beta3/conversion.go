@@ -18,6 +18,12 @@ func convert_api_Image_To_v1beta3_Image(in *newer.Image, out *Image, s conversio
 
 	out.DockerImageReference = in.DockerImageReference
 	out.DockerImageManifest = in.DockerImageManifest
+	if err := s.Convert(&in.Signatures, &out.Signatures, 0); err != nil {
+		return err
+	}
+	if err := s.Convert(&in.Finalizers, &out.Finalizers, 0); err != nil {
+		return err
+	}
 
 	version := in.DockerImageMetadataVersion
 	if len(version) == 0 {
@@ -40,6 +46,12 @@ func convert_v1beta3_Image_To_api_Image(in *Image, out *newer.Image, s conversio
 
 	out.DockerImageReference = in.DockerImageReference
 	out.DockerImageManifest = in.DockerImageManifest
+	if err := s.Convert(&in.Signatures, &out.Signatures, 0); err != nil {
+		return err
+	}
+	if err := s.Convert(&in.Finalizers, &out.Finalizers, 0); err != nil {
+		return err
+	}
 
 	version := in.DockerImageMetadataVersion
 	if len(version) == 0 {
@@ -74,12 +86,20 @@ func convert_v1beta3_ImageStreamSpec_To_api_ImageStreamSpec(in *ImageStreamSpec,
 			}
 		}
 	}
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if err := s.Convert(&in.Mirror, &out.Mirror, 0); err != nil {
+		return err
+	}
 	out.Tags = make(map[string]newer.TagReference)
 	return s.Convert(&in.Tags, &out.Tags, 0)
 }
 
 func convert_api_ImageStreamSpec_To_v1beta3_ImageStreamSpec(in *newer.ImageStreamSpec, out *ImageStreamSpec, s conversion.Scope) error {
 	out.DockerImageRepository = in.DockerImageRepository
+	out.TagHistoryLimit = in.TagHistoryLimit
+	if err := s.Convert(&in.Mirror, &out.Mirror, 0); err != nil {
+		return err
+	}
 	out.Tags = make([]NamedTagReference, 0, 0)
 	return s.Convert(&in.Tags, &out.Tags, 0)
 }
@@ -198,14 +218,39 @@ func convert_v1beta3_ImageStreamTag_To_api_ImageStreamTag(in *ImageStreamTag, ou
 
 	return nil
 }
+
+// convert_api_TagReferencePolicy_To_v1beta3_TagReferencePolicy defaults the policy
+// to Source, so that tags without one don't serialize an empty type.
+func convert_api_TagReferencePolicy_To_v1beta3_TagReferencePolicy(in *newer.TagReferencePolicy, out *TagReferencePolicy, s conversion.Scope) error {
+	out.Type = TagReferencePolicyType(in.Type)
+	if len(out.Type) == 0 {
+		out.Type = SourceTagReferencePolicy
+	}
+	return nil
+}
+
 func init() {
-	err := kapi.Scheme.AddConversionFuncs(
+	err := kapi.Scheme.AddDefaultingFuncs(
+		func(obj *TagReferencePolicy) {
+			if len(obj.Type) == 0 {
+				obj.Type = SourceTagReferencePolicy
+			}
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = kapi.Scheme.AddConversionFuncs(
 		func(in *[]NamedTagEventList, out *map[string]newer.TagEventList, s conversion.Scope) error {
 			for _, curr := range *in {
 				newTagEventList := newer.TagEventList{}
 				if err := s.Convert(&curr.Items, &newTagEventList.Items, 0); err != nil {
 					return err
 				}
+				if err := s.Convert(&curr.Conditions, &newTagEventList.Conditions, 0); err != nil {
+					return err
+				}
 				(*out)[curr.Tag] = newTagEventList
 			}
 
@@ -224,6 +269,9 @@ func init() {
 				if err := s.Convert(&newTagEventList.Items, &oldTagEventList.Items, 0); err != nil {
 					return err
 				}
+				if err := s.Convert(&newTagEventList.Conditions, &oldTagEventList.Conditions, 0); err != nil {
+					return err
+				}
 
 				*out = append(*out, *oldTagEventList)
 			}
@@ -235,6 +283,14 @@ func init() {
 				r := newer.TagReference{
 					Annotations: curr.Annotations,
 					Reference:   curr.Reference,
+					Immutable:   curr.Immutable,
+					Track:       curr.Track,
+				}
+				if err := s.Convert(&curr.ImportPolicy, &r.ImportPolicy, 0); err != nil {
+					return err
+				}
+				if err := s.Convert(&curr.ReferencePolicy, &r.ReferencePolicy, 0); err != nil {
+					return err
 				}
 				if err := s.Convert(&curr.From, &r.From, 0); err != nil {
 					return err
@@ -256,6 +312,14 @@ func init() {
 					Name:        tag,
 					Annotations: newTagReference.Annotations,
 					Reference:   newTagReference.Reference,
+					Immutable:   newTagReference.Immutable,
+					Track:       newTagReference.Track,
+				}
+				if err := s.Convert(&newTagReference.ImportPolicy, &oldTagReference.ImportPolicy, 0); err != nil {
+					return err
+				}
+				if err := s.Convert(&newTagReference.ReferencePolicy, &oldTagReference.ReferencePolicy, 0); err != nil {
+					return err
 				}
 				if err := s.Convert(&newTagReference.From, &oldTagReference.From, 0); err != nil {
 					return err
@@ -279,6 +343,7 @@ func init() {
 		convert_v1beta3_ImageStreamImage_To_api_ImageStreamImage,
 		convert_api_ImageStreamTag_To_v1beta3_ImageStreamTag,
 		convert_v1beta3_ImageStreamTag_To_api_ImageStreamTag,
+		convert_api_TagReferencePolicy_To_v1beta3_TagReferencePolicy,
 	)
 	if err != nil {
 		// If one of the conversion functions is malformed, detect it immediately.
@@ -300,4 +365,20 @@ func init() {
 		// If one of the conversion functions is malformed, detect it immediately.
 		panic(err)
 	}
+
+	err = kapi.Scheme.AddFieldLabelConversionFunc("v1beta3", "ImageLayer",
+		func(label, value string) (string, string, error) {
+			switch label {
+			case "name":
+				return "metadata.name", value, nil
+			case "metadata.name", "shared", "imageStream":
+				return label, value, nil
+			default:
+				return "", "", fmt.Errorf("field label not supported: %s", label)
+			}
+		})
+	if err != nil {
+		// If one of the conversion functions is malformed, detect it immediately.
+		panic(err)
+	}
 }
@@ -17,6 +17,10 @@ func init() {
 		&ImageStreamTag{},
 		&ImageStreamTagList{},
 		&ImageStreamImage{},
+		&ImageStreamImport{},
+		&ImageLayer{},
+		&ImageLayerList{},
+		&ImageSignature{},
 	)
 }
 
@@ -27,3 +31,7 @@ func (*ImageStreamList) IsAnAPIObject()    {}
 func (*ImageStreamMapping) IsAnAPIObject() {}
 func (*ImageStreamTag) IsAnAPIObject()     {}
 func (*ImageStreamTagList) IsAnAPIObject() {}
+func (*ImageStreamImport) IsAnAPIObject()  {}
+func (*ImageSignature) IsAnAPIObject()     {}
+func (*ImageLayer) IsAnAPIObject()         {}
+func (*ImageLayerList) IsAnAPIObject()     {}
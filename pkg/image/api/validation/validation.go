@@ -1,12 +1,18 @@
 package validation
 
 import (
+	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 
+	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/libtrust"
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/validation"
 	"k8s.io/kubernetes/pkg/util/fielderrors"
+	kvalidation "k8s.io/kubernetes/pkg/util/validation"
 
 	oapi "github.com/openshift/origin/pkg/api"
 	"github.com/openshift/origin/pkg/image/api"
@@ -40,6 +46,26 @@ func ValidateImage(image *api.Image) fielderrors.ValidationErrorList {
 		}
 	}
 
+	names := make(map[string]bool)
+	for i := range image.Signatures {
+		signature := &image.Signatures[i]
+		prefix := fmt.Sprintf("signatures[%d]", i)
+		result = append(result, ValidateImageSignature(signature).Prefix(prefix)...)
+		if imageName, _, ok := api.SplitImageSignatureName(signature.Name); ok && imageName != image.Name {
+			result = append(result, fielderrors.NewFieldInvalid(prefix+".metadata.name", signature.Name, "the signature name must start with the name of the image"))
+		}
+		if names[signature.Name] {
+			result = append(result, fielderrors.NewFieldDuplicate(prefix+".metadata.name", signature.Name))
+		}
+		names[signature.Name] = true
+	}
+
+	for i, finalizer := range image.Finalizers {
+		if !kvalidation.IsQualifiedName(string(finalizer)) || !strings.Contains(string(finalizer), "/") {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("finalizers[%d]", i), finalizer, "must be a fully qualified name, e.g. openshift.io/registry"))
+		}
+	}
+
 	return result
 }
 
@@ -53,6 +79,36 @@ func ValidateImageUpdate(newImage, oldImage *api.Image) fielderrors.ValidationEr
 }
 
 // ValidateImageStream tests required fields for an ImageStream.
+// ValidateImageSignature validates a signature of an image, whose name is of
+// the form <image name>@<signature name>.
+func ValidateImageSignature(signature *api.ImageSignature) fielderrors.ValidationErrorList {
+	result := fielderrors.ValidationErrorList{}
+
+	result = append(result, validation.ValidateObjectMeta(&signature.ObjectMeta, false, validateImageSignatureName).Prefix("metadata")...)
+	if len(signature.Type) == 0 {
+		result = append(result, fielderrors.NewFieldRequired("type"))
+	}
+	if len(signature.Content) == 0 {
+		result = append(result, fielderrors.NewFieldRequired("content"))
+	}
+
+	return result
+}
+
+func validateImageSignatureName(name string, prefix bool) (bool, string) {
+	if ok, reason := oapi.MinimalNameRequirements(name, prefix); !ok {
+		return ok, reason
+	}
+	imageName, signatureName, ok := api.SplitImageSignatureName(name)
+	if !ok {
+		return false, "must be of the form <image name>@<signature name>"
+	}
+	if ok, reason := oapi.MinimalNameRequirements(imageName, false); !ok {
+		return ok, reason
+	}
+	return oapi.MinimalNameRequirements(signatureName, false)
+}
+
 func ValidateImageStream(stream *api.ImageStream) fielderrors.ValidationErrorList {
 	result := fielderrors.ValidationErrorList{}
 	result = append(result, validation.ValidateObjectMeta(&stream.ObjectMeta, true, ValidateImageStreamName).Prefix("metadata")...)
@@ -81,6 +137,24 @@ func ValidateImageStream(stream *api.ImageStream) fielderrors.ValidationErrorLis
 			}
 		}
 	}
+	if stream.Spec.TagHistoryLimit < 0 {
+		result = append(result, fielderrors.NewFieldInvalid("spec.tagHistoryLimit", stream.Spec.TagHistoryLimit, "must be greater than or equal to 0"))
+	}
+	if mirror := stream.Spec.Mirror; mirror != nil {
+		if len(stream.Spec.DockerImageRepository) == 0 {
+			result = append(result, fielderrors.NewFieldInvalid("spec.mirror", "", "the tags of spec.dockerImageRepository are mirrored, it must be set"))
+		}
+		for i, pattern := range mirror.IncludeTags {
+			if _, err := path.Match(pattern, ""); err != nil {
+				result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.mirror.includeTags[%d]", i), pattern, err.Error()))
+			}
+		}
+		for i, pattern := range mirror.ExcludeTags {
+			if _, err := path.Match(pattern, ""); err != nil {
+				result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.mirror.excludeTags[%d]", i), pattern, err.Error()))
+			}
+		}
+	}
 	for tag, tagRef := range stream.Spec.Tags {
 		if tagRef.From != nil {
 			switch tagRef.From.Kind {
@@ -89,6 +163,30 @@ func ValidateImageStream(stream *api.ImageStream) fielderrors.ValidationErrorLis
 				result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].from.kind", tag), tagRef.From.Kind, "valid values are 'DockerImage', 'ImageStreamImage', 'ImageStreamTag'"))
 			}
 		}
+		switch tagRef.ReferencePolicy.Type {
+		case "", api.SourceTagReferencePolicy, api.LocalTagReferencePolicy:
+		default:
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].referencePolicy.type", tag), tagRef.ReferencePolicy.Type, "valid values are 'Source', 'Local'"))
+		}
+		switch tagRef.ImportPolicy.ImportMode {
+		case "", api.ImportTagImportMode, api.ReferenceTagImportMode:
+		default:
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].importPolicy.importMode", tag), tagRef.ImportPolicy.ImportMode, "valid values are 'Import', 'Reference'"))
+		}
+		if tagRef.ImportPolicy != (api.TagImportPolicy{}) && (tagRef.From == nil || tagRef.From.Kind != "DockerImage") {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].importPolicy", tag), "", "only a tag whose source is a DockerImage has an import policy"))
+		}
+		if tagRef.ImportPolicy.Scheduled && api.IsTagReferenceOnly(tagRef) {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].importPolicy.scheduled", tag), true, "a tag that only references its source isn't imported, it can't be scheduled"))
+		}
+		if len(tagRef.Track) > 0 {
+			if _, err := api.ParseVersionRange(tagRef.Track); err != nil {
+				result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].track", tag), tagRef.Track, err.Error()))
+			}
+			if tagRef.From != nil {
+				result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].from", tag), tagRef.From, "a tag tracking a version range can't reference an image"))
+			}
+		}
 	}
 	for tag, history := range stream.Status.Tags {
 		for i, tagEvent := range history.Items {
@@ -106,6 +204,7 @@ func ValidateImageStreamUpdate(newStream, oldStream *api.ImageStream) fielderror
 
 	result = append(result, validation.ValidateObjectMetaUpdate(&newStream.ObjectMeta, &oldStream.ObjectMeta).Prefix("metadata")...)
 	result = append(result, ValidateImageStream(newStream)...)
+	result = append(result, validateImmutableTags(newStream, oldStream)...)
 
 	return result
 }
@@ -114,11 +213,47 @@ func ValidateImageStreamUpdate(newStream, oldStream *api.ImageStream) fielderror
 func ValidateImageStreamStatusUpdate(newStream, oldStream *api.ImageStream) fielderrors.ValidationErrorList {
 	result := fielderrors.ValidationErrorList{}
 	result = append(result, validation.ValidateObjectMetaUpdate(&newStream.ObjectMeta, &oldStream.ObjectMeta).Prefix("metadata")...)
-	newStream.Spec.Tags = oldStream.Spec.Tags
-	newStream.Spec.DockerImageRepository = oldStream.Spec.DockerImageRepository
+	newStream.Spec = oldStream.Spec
+	result = append(result, validateImmutableTags(newStream, oldStream)...)
 	return result
 }
 
+// validateImmutableTags verifies that the immutable tags of oldStream that
+// reference an image are neither removed nor changed to reference another one.
+func validateImmutableTags(newStream, oldStream *api.ImageStream) fielderrors.ValidationErrorList {
+	result := fielderrors.ValidationErrorList{}
+	for tag, oldRef := range oldStream.Spec.Tags {
+		if !api.IsTagImmutable(oldStream, tag) {
+			continue
+		}
+		newRef, ok := newStream.Spec.Tags[tag]
+		if !ok {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s]", tag), tag, "the tag is immutable and can't be removed"))
+			continue
+		}
+		if !kapi.Semantic.DeepEqual(oldRef.From, newRef.From) {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].from", tag), newRef.From, "the tag is immutable and can't reference another image"))
+		}
+		if !sameTaggedImage(api.LatestTaggedImage(oldStream, tag), api.LatestTaggedImage(newStream, tag)) {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("status.tags[%s]", tag), tag, "the tag is immutable and can't reference another image"))
+		}
+	}
+	return result
+}
+
+// sameTaggedImage returns true if the tag event next references the image of
+// previous. An image without a name, e.g. of a tag that wasn't imported yet, is
+// only known by its reference, the name is set when the image is imported.
+func sameTaggedImage(previous, next *api.TagEvent) bool {
+	if next == nil {
+		return false
+	}
+	if len(previous.Image) == 0 {
+		return previous.DockerImageReference == next.DockerImageReference
+	}
+	return previous.Image == next.Image
+}
+
 // ValidateImageStreamMapping tests required fields for an ImageStreamMapping.
 func ValidateImageStreamMapping(mapping *api.ImageStreamMapping) fielderrors.ValidationErrorList {
 	result := fielderrors.ValidationErrorList{}
@@ -140,15 +275,106 @@ func ValidateImageStreamMapping(mapping *api.ImageStreamMapping) fielderrors.Val
 	if ok, msg := validation.ValidateNamespaceName(mapping.Namespace, false); !ok {
 		result = append(result, fielderrors.NewFieldInvalid("namespace", mapping.Namespace, msg))
 	}
-	if len(mapping.Tag) == 0 {
-		result = append(result, fielderrors.NewFieldRequired("tag"))
-	}
 	if errs := ValidateImage(&mapping.Image).Prefix("image"); len(errs) != 0 {
 		result = append(result, errs...)
 	}
 	return result
 }
 
+// ValidateImageManifest tests that the manifest of image, if any, is no larger
+// than maxSize bytes, unless maxSize is 0, and is a JSON document whose layers
+// have valid digests. The name of an image with a manifest must be a valid
+// digest too, since it's the digest of the manifest.
+func ValidateImageManifest(image *api.Image, maxSize int64) fielderrors.ValidationErrorList {
+	result := fielderrors.ValidationErrorList{}
+	if len(image.DockerImageManifest) == 0 {
+		return result
+	}
+
+	if size := int64(len(image.DockerImageManifest)); maxSize > 0 && size > maxSize {
+		return append(result, fielderrors.NewFieldInvalid("dockerImageManifest", fmt.Sprintf("%d bytes", size), fmt.Sprintf("must not be larger than %d bytes", maxSize)))
+	}
+	manifest := api.DockerImageManifest{}
+	if err := json.Unmarshal([]byte(image.DockerImageManifest), &manifest); err != nil {
+		return append(result, fielderrors.NewFieldInvalid("dockerImageManifest", "", fmt.Sprintf("must be a valid JSON document: %v", err)))
+	}
+	for i, layer := range manifest.FSLayers {
+		if _, err := digest.ParseDigest(layer.DockerBlobSum); err != nil {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("dockerImageManifest.fsLayers[%d].blobSum", i), layer.DockerBlobSum, err.Error()))
+		}
+	}
+	if dgst, err := digest.ParseDigest(image.Name); err != nil {
+		result = append(result, fielderrors.NewFieldInvalid("metadata.name", image.Name, fmt.Sprintf("must be the digest of the manifest: %v", err)))
+	} else if err := verifyManifestDigest(image.DockerImageManifest, dgst); err != nil {
+		result = append(result, fielderrors.NewFieldInvalid("metadata.name", image.Name, err.Error()))
+	}
+	return result
+}
+
+// verifyManifestDigest tests that dgst is the digest of manifest, which is the
+// digest of the signed payload for a signed manifest, as the registry computes
+// it, and of the whole document otherwise.
+func verifyManifestDigest(manifest string, dgst digest.Digest) error {
+	payload := []byte(manifest)
+	if signature, err := libtrust.ParsePrettySignature(payload, "signatures"); err == nil {
+		if payload, err = signature.Payload(); err != nil {
+			return fmt.Errorf("the signed payload of the manifest is invalid: %v", err)
+		}
+	}
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		return fmt.Errorf("must be the digest of the manifest: %v", err)
+	}
+	verifier.Write(payload)
+	if !verifier.Verified() {
+		return fmt.Errorf("must be the digest of the manifest")
+	}
+	return nil
+}
+
+// ValidateImageStreamImport tests required fields for an ImageStreamImport.
+func ValidateImageStreamImport(isi *api.ImageStreamImport) fielderrors.ValidationErrorList {
+	result := fielderrors.ValidationErrorList{}
+	result = append(result, validation.ValidateObjectMeta(&isi.ObjectMeta, true, ValidateImageStreamName).Prefix("metadata")...)
+
+	spec := isi.Spec
+	if spec.Repository == nil && len(spec.Images) == 0 {
+		result = append(result, fielderrors.NewFieldRequired("spec.images"))
+	}
+	if spec.Repository != nil {
+		from := spec.Repository.From
+		if from.Kind != "DockerImage" {
+			result = append(result, fielderrors.NewFieldInvalid("spec.repository.from.kind", from.Kind, "only 'DockerImage' is supported"))
+		} else if ref, err := api.ParseDockerImageReference(from.Name); err != nil {
+			result = append(result, fielderrors.NewFieldInvalid("spec.repository.from.name", from.Name, err.Error()))
+		} else if len(ref.Tag) > 0 || len(ref.ID) > 0 {
+			result = append(result, fielderrors.NewFieldInvalid("spec.repository.from.name", from.Name, "the repository name may not contain a tag or an ID"))
+		}
+	}
+	tags := make(map[string]bool)
+	for i, image := range spec.Images {
+		if image.From.Kind != "DockerImage" {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.images[%d].from.kind", i), image.From.Kind, "only 'DockerImage' is supported"))
+		} else if _, err := api.ParseDockerImageReference(image.From.Name); err != nil {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.images[%d].from.name", i), image.From.Name, err.Error()))
+		}
+		if image.ImportPolicy.ImportMode == api.ReferenceTagImportMode {
+			result = append(result, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.images[%d].importPolicy.importMode", i), image.ImportPolicy.ImportMode, "the images of an import are always imported"))
+		}
+		if image.To == nil {
+			continue
+		}
+		switch {
+		case len(image.To.Name) == 0:
+			result = append(result, fielderrors.NewFieldRequired(fmt.Sprintf("spec.images[%d].to.name", i)))
+		case tags[image.To.Name]:
+			result = append(result, fielderrors.NewFieldDuplicate(fmt.Sprintf("spec.images[%d].to.name", i), image.To.Name))
+		}
+		tags[image.To.Name] = true
+	}
+	return result
+}
+
 // ValidateImageStreamTag is essentially a no-op.  We don't allow direct creation of istags
 func ValidateImageStreamTag(ist *api.ImageStreamTag) fielderrors.ValidationErrorList {
 	result := fielderrors.ValidationErrorList{}
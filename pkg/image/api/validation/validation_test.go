@@ -5,6 +5,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
+
 	"github.com/openshift/origin/pkg/image/api"
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/util"
@@ -15,6 +19,10 @@ func TestValidateImageOK(t *testing.T) {
 	errs := ValidateImage(&api.Image{
 		ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
 		DockerImageReference: "openshift/ruby-19-centos",
+		Signatures: []api.ImageSignature{
+			{ObjectMeta: kapi.ObjectMeta{Name: "foo@sig"}, Type: api.ImageSignatureTypeAtomicImageV1, Content: []byte("signature")},
+		},
+		Finalizers: []kapi.FinalizerName{api.ImageFinalizerRegistry},
 	})
 	if len(errs) > 0 {
 		t.Errorf("Unexpected non-empty error list: %#v", errs)
@@ -47,6 +55,49 @@ func TestValidateImageMissingFields(t *testing.T) {
 			fielderrors.ValidationErrorTypeRequired,
 			"dockerImageReference",
 		},
+		"signature of another image": {
+			api.Image{
+				ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
+				DockerImageReference: "ref",
+				Signatures: []api.ImageSignature{
+					{ObjectMeta: kapi.ObjectMeta{Name: "bar@sig"}, Type: api.ImageSignatureTypeAtomicImageV1, Content: []byte("signature")},
+				},
+			},
+			fielderrors.ValidationErrorTypeInvalid,
+			"signatures[0].metadata.name",
+		},
+		"signature without a type": {
+			api.Image{
+				ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
+				DockerImageReference: "ref",
+				Signatures: []api.ImageSignature{
+					{ObjectMeta: kapi.ObjectMeta{Name: "foo@sig"}, Content: []byte("signature")},
+				},
+			},
+			fielderrors.ValidationErrorTypeRequired,
+			"signatures[0].type",
+		},
+		"duplicate signature": {
+			api.Image{
+				ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
+				DockerImageReference: "ref",
+				Signatures: []api.ImageSignature{
+					{ObjectMeta: kapi.ObjectMeta{Name: "foo@sig"}, Type: api.ImageSignatureTypeAtomicImageV1, Content: []byte("signature")},
+					{ObjectMeta: kapi.ObjectMeta{Name: "foo@sig"}, Type: api.ImageSignatureTypeAtomicImageV1, Content: []byte("other")},
+				},
+			},
+			fielderrors.ValidationErrorTypeDuplicate,
+			"signatures[1].metadata.name",
+		},
+		"unqualified finalizer": {
+			api.Image{
+				ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
+				DockerImageReference: "ref",
+				Finalizers:           []kapi.FinalizerName{"registry"},
+			},
+			fielderrors.ValidationErrorTypeInvalid,
+			"finalizers[0]",
+		},
 	}
 
 	for k, v := range errorCases {
@@ -68,6 +119,55 @@ func TestValidateImageMissingFields(t *testing.T) {
 	}
 }
 
+func TestValidateImageSignature(t *testing.T) {
+	tests := map[string]struct {
+		signature api.ImageSignature
+		expected  fielderrors.ValidationErrorList
+	}{
+		"valid": {
+			signature: api.ImageSignature{ObjectMeta: kapi.ObjectMeta{Name: "sha256:abc@sig"}, Type: api.ImageSignatureTypeAtomicImageV1, Content: []byte("signature")},
+			expected:  fielderrors.ValidationErrorList{},
+		},
+		"missing signature name": {
+			signature: api.ImageSignature{ObjectMeta: kapi.ObjectMeta{Name: "sha256:abc"}, Type: api.ImageSignatureTypeAtomicImageV1, Content: []byte("signature")},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("metadata.name", "sha256:abc", "must be of the form <image name>@<signature name>"),
+			},
+		},
+		"missing type and content": {
+			signature: api.ImageSignature{ObjectMeta: kapi.ObjectMeta{Name: "sha256:abc@sig"}},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldRequired("type"),
+				fielderrors.NewFieldRequired("content"),
+			},
+		},
+	}
+	for name, test := range tests {
+		errs := ValidateImageSignature(&test.signature)
+		if !reflect.DeepEqual(errs, test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, errs)
+		}
+	}
+}
+
+func TestValidateImageStreamMappingWithoutTag(t *testing.T) {
+	// a mapping without a tag only registers the image pushed by digest
+	errs := ValidateImageStreamMapping(&api.ImageStreamMapping{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace: "default",
+			Name:      "ruby-19-centos",
+		},
+		DockerImageRepository: "openshift/ruby-19-centos",
+		Image: api.Image{
+			ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
+			DockerImageReference: "openshift/ruby-19-centos",
+		},
+	})
+	if len(errs) > 0 {
+		t.Errorf("Unexpected non-empty error list: %#v", errs)
+	}
+}
+
 func TestValidateImageStreamMappingNotOK(t *testing.T) {
 	errorCases := map[string]struct {
 		I api.ImageStreamMapping
@@ -108,23 +208,6 @@ func TestValidateImageStreamMappingNotOK(t *testing.T) {
 			fielderrors.ValidationErrorTypeRequired,
 			"name",
 		},
-		"missing Tag": {
-			api.ImageStreamMapping{
-				ObjectMeta: kapi.ObjectMeta{
-					Namespace: "default",
-				},
-				DockerImageRepository: "openshift/ruby-19-centos",
-				Image: api.Image{
-					ObjectMeta: kapi.ObjectMeta{
-						Name:      "foo",
-						Namespace: "default",
-					},
-					DockerImageReference: "openshift/ruby-19-centos",
-				},
-			},
-			fielderrors.ValidationErrorTypeRequired,
-			"tag",
-		},
 		"missing image name": {
 			api.ImageStreamMapping{
 				ObjectMeta: kapi.ObjectMeta{
@@ -178,6 +261,107 @@ func TestValidateImageStreamMappingNotOK(t *testing.T) {
 	}
 }
 
+func TestValidateImageManifest(t *testing.T) {
+	const validDigest = "sha256:4e07408562bedb8b60ce05c1decfe3ad16b72230967de01f640b7e4729b49fce"
+	unsigned := `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + validDigest + `"}]}`
+	unsignedDigest, _ := digest.FromBytes([]byte(unsigned))
+	invalidLayer := `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + validDigest + `"},{"blobSum":"layer1"}]}`
+	invalidLayerDigest, _ := digest.FromBytes([]byte(invalidLayer))
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 1},
+		Name:      "ns/app",
+		Tag:       "latest",
+		FSLayers:  []manifest.FSLayer{{BlobSum: validDigest}},
+		History:   []manifest.History{{V1Compatibility: "{}"}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := signed.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedDigest, _ := digest.FromBytes(payload)
+	rawDigest, _ := digest.FromBytes(signed.Raw)
+
+	testCases := map[string]struct {
+		name     string
+		manifest string
+		maxSize  int64
+		field    string
+	}{
+		"no manifest": {
+			name: "foo",
+		},
+		"valid manifest": {
+			name:     unsignedDigest.String(),
+			manifest: unsigned,
+			maxSize:  1024,
+		},
+		"valid signed manifest": {
+			name:     signedDigest.String(),
+			manifest: string(signed.Raw),
+		},
+		"name not the digest of the manifest": {
+			name:     validDigest,
+			manifest: unsigned,
+			field:    "metadata.name",
+		},
+		"name the digest of the signatures": {
+			name:     rawDigest.String(),
+			manifest: string(signed.Raw),
+			field:    "metadata.name",
+		},
+		"manifest too large": {
+			name:     validDigest,
+			manifest: `{"schemaVersion":1,"fsLayers":[{"blobSum":"` + validDigest + `"}]}`,
+			maxSize:  32,
+			field:    "dockerImageManifest",
+		},
+		"manifest not JSON": {
+			name:     validDigest,
+			manifest: "{ no {{{ json here!!!",
+			field:    "dockerImageManifest",
+		},
+		"invalid layer digest": {
+			name:     invalidLayerDigest.String(),
+			manifest: invalidLayer,
+			field:    "dockerImageManifest.fsLayers[1].blobSum",
+		},
+		"name not a digest": {
+			name:     "foo",
+			manifest: `{"schemaVersion":1,"fsLayers":[]}`,
+			field:    "metadata.name",
+		},
+	}
+
+	for k, tc := range testCases {
+		image := &api.Image{
+			ObjectMeta:          kapi.ObjectMeta{Name: tc.name},
+			DockerImageManifest: tc.manifest,
+		}
+		errs := ValidateImageManifest(image, tc.maxSize)
+		if len(tc.field) == 0 {
+			if len(errs) != 0 {
+				t.Errorf("%s: unexpected errors: %v", k, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one error, got %v", k, errs)
+			continue
+		}
+		if err := errs[0].(*fielderrors.ValidationError); err.Type != fielderrors.ValidationErrorTypeInvalid || err.Field != tc.field {
+			t.Errorf("%s: expected an invalid value error for field %s, got %v", k, tc.field, err)
+		}
+	}
+}
+
 func TestValidateImageStream(t *testing.T) {
 
 	namespace63Char := strings.Repeat("a", 63)
@@ -192,6 +376,8 @@ func TestValidateImageStream(t *testing.T) {
 		name                  string
 		dockerImageRepository string
 		specTags              map[string]api.TagReference
+		tagHistoryLimit       int
+		mirror                *api.RepositoryMirrorPolicy
 		statusTags            map[string]api.TagEventList
 		expected              fielderrors.ValidationErrorList
 	}{
@@ -293,6 +479,13 @@ func TestValidateImageStream(t *testing.T) {
 						Name: "abc",
 					},
 				},
+				"local": {
+					From: &kapi.ObjectReference{
+						Kind: "DockerImage",
+						Name: "abc",
+					},
+					ReferencePolicy: api.TagReferencePolicy{Type: api.LocalTagReferencePolicy},
+				},
 				"other": {
 					From: &kapi.ObjectReference{
 						Kind: "ImageStreamTag",
@@ -309,6 +502,126 @@ func TestValidateImageStream(t *testing.T) {
 			},
 			expected: fielderrors.ValidationErrorList{},
 		},
+		"invalid reference policy": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"tag": {
+					From: &kapi.ObjectReference{
+						Kind: "DockerImage",
+						Name: "abc",
+					},
+					ReferencePolicy: api.TagReferencePolicy{Type: "Remote"},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tags[tag].referencePolicy.type", api.TagReferencePolicyType("Remote"), "valid values are 'Source', 'Local'"),
+			},
+		},
+		"invalid import mode": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"tag": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "abc"},
+					ImportPolicy: api.TagImportPolicy{ImportMode: "Mirror"},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tags[tag].importPolicy.importMode", api.TagImportMode("Mirror"), "valid values are 'Import', 'Reference'"),
+			},
+		},
+		"import policy without external source": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"tag": {
+					From:         &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "other:latest"},
+					ImportPolicy: api.TagImportPolicy{Insecure: true},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tags[tag].importPolicy", "", "only a tag whose source is a DockerImage has an import policy"),
+			},
+		},
+		"scheduled reference": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"tag": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "abc"},
+					ImportPolicy: api.TagImportPolicy{ImportMode: api.ReferenceTagImportMode, Scheduled: true},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tags[tag].importPolicy.scheduled", true, "a tag that only references its source isn't imported, it can't be scheduled"),
+			},
+		},
+		"external tags with import policies": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"mirrored": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "docker.io/library/ruby:2.2"},
+					ImportPolicy: api.TagImportPolicy{ImportMode: api.ImportTagImportMode, Scheduled: true, Insecure: true},
+				},
+				"referenced": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "registry.local:5000/ns/app:1.0"},
+					ImportPolicy: api.TagImportPolicy{ImportMode: api.ReferenceTagImportMode, Insecure: true},
+				},
+				"built": {},
+			},
+			expected: fielderrors.ValidationErrorList{},
+		},
+		"invalid version range": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"2": {Track: ">=2 <x"},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tags[2].track", ">=2 <x", `"x" is not a version of the form MAJOR[.MINOR[.PATCH]]`),
+			},
+		},
+		"version range with from": {
+			namespace: "namespace",
+			name:      "foo",
+			specTags: map[string]api.TagReference{
+				"2": {
+					Track: ">=2 <3",
+					From:  &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "other:2.0"},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tags[2].from", &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "other:2.0"}, "a tag tracking a version range can't reference an image"),
+			},
+		},
+		"negative tag history limit": {
+			namespace:       "namespace",
+			name:            "foo",
+			tagHistoryLimit: -1,
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.tagHistoryLimit", -1, "must be greater than or equal to 0"),
+			},
+		},
+		"mirror without docker repository": {
+			namespace: "namespace",
+			name:      "foo",
+			mirror:    &api.RepositoryMirrorPolicy{},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.mirror", "", "the tags of spec.dockerImageRepository are mirrored, it must be set"),
+			},
+		},
+		"mirror with invalid patterns": {
+			namespace:             "namespace",
+			name:                  "foo",
+			dockerImageRepository: "openshift/origin",
+			mirror:                &api.RepositoryMirrorPolicy{IncludeTags: []string{"v1.*", "v["}, ExcludeTags: []string{"*-rc\\"}},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.mirror.includeTags[1]", "v[", "syntax error in pattern"),
+				fielderrors.NewFieldInvalid("spec.mirror.excludeTags[0]", "*-rc\\", "syntax error in pattern"),
+			},
+		},
 		"all possible characters used": {
 			namespace: "abcdefghijklmnopqrstuvwxyz-1234567890",
 			name:      "abcdefghijklmnopqrstuvwxyz-1234567890.dot_underscore-dash",
@@ -337,6 +650,8 @@ func TestValidateImageStream(t *testing.T) {
 			Spec: api.ImageStreamSpec{
 				DockerImageRepository: test.dockerImageRepository,
 				Tags: test.specTags,
+				TagHistoryLimit:       test.tagHistoryLimit,
+				Mirror:                test.mirror,
 			},
 			Status: api.ImageStreamStatus{
 				Tags: test.statusTags,
@@ -350,6 +665,70 @@ func TestValidateImageStream(t *testing.T) {
 	}
 }
 
+func TestValidateImageStreamImport(t *testing.T) {
+	tests := map[string]struct {
+		spec     api.ImageStreamImportSpec
+		expected fielderrors.ValidationErrorList
+	}{
+		"repository": {
+			spec: api.ImageStreamImportSpec{
+				Repository: &api.RepositoryImportSpec{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql"}},
+			},
+			expected: fielderrors.ValidationErrorList{},
+		},
+		"images": {
+			spec: api.ImageStreamImportSpec{
+				Images: []api.ImageImportSpec{
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.6"}, To: &kapi.LocalObjectReference{Name: "5.6"}},
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:latest"}},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{},
+		},
+		"nothing to import": {
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldRequired("spec.images"),
+			},
+		},
+		"repository with a tag": {
+			spec: api.ImageStreamImportSpec{
+				Repository: &api.RepositoryImportSpec{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:latest"}},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.repository.from.name", "mysql:latest", "the repository name may not contain a tag or an ID"),
+			},
+		},
+		"invalid images": {
+			spec: api.ImageStreamImportSpec{
+				Images: []api.ImageImportSpec{
+					{From: kapi.ObjectReference{Kind: "ImageStreamTag", Name: "mysql:latest"}},
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.6"}, To: &kapi.LocalObjectReference{Name: "latest"}},
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.5"}, To: &kapi.LocalObjectReference{Name: "latest"}},
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.1"}, To: &kapi.LocalObjectReference{}},
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "mysql:5.0"}, ImportPolicy: api.TagImportPolicy{ImportMode: api.ReferenceTagImportMode}},
+				},
+			},
+			expected: fielderrors.ValidationErrorList{
+				fielderrors.NewFieldInvalid("spec.images[0].from.kind", "ImageStreamTag", "only 'DockerImage' is supported"),
+				fielderrors.NewFieldDuplicate("spec.images[2].to.name", "latest"),
+				fielderrors.NewFieldRequired("spec.images[3].to.name"),
+				fielderrors.NewFieldInvalid("spec.images[4].importPolicy.importMode", api.ReferenceTagImportMode, "the images of an import are always imported"),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		isi := api.ImageStreamImport{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "foo", Name: "mysql"},
+			Spec:       test.spec,
+		}
+		errs := ValidateImageStreamImport(&isi)
+		if e, a := test.expected, errs; !reflect.DeepEqual(e, a) {
+			t.Errorf("%s: unexpected errors: %s", name, util.ObjectDiff(e, a))
+		}
+	}
+}
+
 func TestValidateISTUpdate(t *testing.T) {
 	old := &api.ImageStreamTag{
 		ObjectMeta: kapi.ObjectMeta{Namespace: kapi.NamespaceDefault, Name: "foo:bar", ResourceVersion: "1", Annotations: map[string]string{"one": "two"}},
@@ -394,3 +773,72 @@ func TestValidateISTUpdate(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateImageStreamUpdateImmutableTags(t *testing.T) {
+	newStream := func(from string, immutable bool, image string) *api.ImageStream {
+		stream := &api.ImageStream{
+			ObjectMeta: kapi.ObjectMeta{Namespace: kapi.NamespaceDefault, Name: "foo", ResourceVersion: "1"},
+			Spec: api.ImageStreamSpec{
+				Tags: map[string]api.TagReference{
+					"prod": {From: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: from}, Immutable: immutable},
+				},
+			},
+		}
+		if len(image) > 0 {
+			stream.Status.Tags = map[string]api.TagEventList{
+				"prod": {Items: []api.TagEvent{{Image: image, DockerImageReference: "registry/foo@" + image}}},
+			}
+		}
+		return stream
+	}
+
+	testCases := map[string]struct {
+		old, updated *api.ImageStream
+		fields       []string
+	}{
+		"mutable tag changed": {
+			old:     newStream("foo:latest", false, "a"),
+			updated: newStream("foo:next", false, "b"),
+		},
+		"immutable tag without image set": {
+			old:     newStream("foo:latest", true, ""),
+			updated: newStream("foo:next", true, "b"),
+		},
+		"immutable tag made mutable": {
+			old:     newStream("foo:latest", true, "a"),
+			updated: newStream("foo:latest", false, "a"),
+		},
+		"immutable tag changed": {
+			old:     newStream("foo:latest", true, "a"),
+			updated: newStream("foo:next", true, "b"),
+			fields:  []string{"spec.tags[prod].from", "status.tags[prod]"},
+		},
+		"immutable tag made mutable and changed": {
+			old:     newStream("foo:latest", true, "a"),
+			updated: newStream("foo:next", false, "a"),
+			fields:  []string{"spec.tags[prod].from"},
+		},
+		"immutable tag image replaced": {
+			old:     newStream("foo:latest", true, "a"),
+			updated: newStream("foo:latest", true, "b"),
+			fields:  []string{"status.tags[prod]"},
+		},
+		"immutable tag removed": {
+			old:     newStream("foo:latest", true, "a"),
+			updated: &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: kapi.NamespaceDefault, Name: "foo", ResourceVersion: "1"}},
+			fields:  []string{"spec.tags[prod]"},
+		},
+	}
+	for name, test := range testCases {
+		errs := ValidateImageStreamUpdate(test.updated, test.old)
+		if len(errs) != len(test.fields) {
+			t.Errorf("%s: expected errors for %v, got %v", name, test.fields, errs)
+			continue
+		}
+		for i := range errs {
+			if field := errs[i].(*fielderrors.ValidationError).Field; field != test.fields[i] {
+				t.Errorf("%s: expected an error for %s, got %v", name, test.fields[i], errs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/golang/glog"
+)
+
+// versionComparator compares a version to the version of a VersionRange.
+type versionComparator struct {
+	operator string
+	version  semver.Version
+}
+
+// VersionRange is a set of comparisons a semantic version must satisfy, e.g.
+// ">=2.1 <3.0".
+type VersionRange []versionComparator
+
+// ParseVersionRange parses a range of semantic versions made of comparisons
+// separated by spaces, which must all be satisfied. A comparison is one of the
+// operators >, >=, <, <= and = followed by a version, or a version alone to
+// match it exactly. The missing minor or patch numbers of a version are 0.
+func ParseVersionRange(s string) (VersionRange, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("a version range requires at least one comparison")
+	}
+	r := make(VersionRange, 0, len(fields))
+	for _, field := range fields {
+		operator := "="
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(field, op) {
+				operator = op
+				field = field[len(op):]
+				break
+			}
+		}
+		version, ok := parseVersion(field)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a version of the form MAJOR[.MINOR[.PATCH]]", field)
+		}
+		r = append(r, versionComparator{operator: operator, version: *version})
+	}
+	return r, nil
+}
+
+// Matches returns true if version satisfies all the comparisons of r.
+func (r VersionRange) Matches(version semver.Version) bool {
+	for _, c := range r {
+		less, greater := version.LessThan(c.version), c.version.LessThan(version)
+		var ok bool
+		switch c.operator {
+		case ">":
+			ok = greater
+		case ">=":
+			ok = !less
+		case "<":
+			ok = less
+		case "<=":
+			ok = !greater
+		default:
+			ok = !less && !greater
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseVersion parses a version of the form [v]MAJOR[.MINOR[.PATCH]]. Versions
+// with a pre-release or build suffix aren't accepted, so that a tag such as
+// "2.1.0-rc1" is never tracked.
+func parseVersion(s string) (*semver.Version, bool) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) > 3 {
+		return nil, false
+	}
+	numbers := make([]int64, 3)
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		numbers[i] = n
+	}
+	return &semver.Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, true
+}
+
+// UpdateVersionTrackingTags sets the image of every spec tag of stream with a
+// version range in spec.tags[].track to the image of the tag of the stream
+// with the highest version in the range, and returns the number of tags that
+// changed. The names of the tags are parsed as versions, the tags which aren't
+// versions or which track versions themselves are ignored. Of two tags with
+// the same version, e.g. "2.1" and "2.1.0", the one with the longest name is
+// used.
+func UpdateVersionTrackingTags(stream *ImageStream) int {
+	updated := 0
+	for specTag, tagRef := range stream.Spec.Tags {
+		if len(tagRef.Track) == 0 || IsTagImmutable(stream, specTag) {
+			continue
+		}
+		r, err := ParseVersionRange(tagRef.Track)
+		if err != nil {
+			glog.V(5).Infof("Tag %q tracks the invalid version range %q: %v", specTag, tagRef.Track, err)
+			continue
+		}
+
+		best := ""
+		var bestVersion *semver.Version
+		for tag, history := range stream.Status.Tags {
+			if len(history.Items) == 0 || len(stream.Spec.Tags[tag].Track) > 0 {
+				continue
+			}
+			version, ok := parseVersion(tag)
+			if !ok || !r.Matches(*version) {
+				continue
+			}
+			if bestVersion == nil || bestVersion.LessThan(*version) ||
+				(!version.LessThan(*bestVersion) && (len(tag) > len(best) || (len(tag) == len(best) && tag > best))) {
+				best, bestVersion = tag, version
+			}
+		}
+		if bestVersion == nil {
+			continue
+		}
+		if AddTagEventToImageStream(stream, specTag, stream.Status.Tags[best].Items[0]) {
+			glog.V(5).Infof("Tag %q of stream %s/%s tracks %q", specTag, stream.Namespace, stream.Name, best)
+			updated++
+		}
+	}
+	return updated
+}
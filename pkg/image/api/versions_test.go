@@ -0,0 +1,106 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestVersionRangeMatches(t *testing.T) {
+	tests := []struct {
+		r        string
+		versions map[string]bool
+	}{
+		{
+			r: ">=2.1 <3.0",
+			versions: map[string]bool{
+				"2.0.9": false,
+				"2.1":   true,
+				"2.9.9": true,
+				"3.0.0": false,
+			},
+		},
+		{
+			r: ">1 <=2.0.1",
+			versions: map[string]bool{
+				"1.0.0": false,
+				"1.0.1": true,
+				"2.0.1": true,
+				"2.0.2": false,
+			},
+		},
+		{
+			r: "v1.2",
+			versions: map[string]bool{
+				"1.2.0": true,
+				"1.2.1": false,
+			},
+		},
+	}
+	for _, test := range tests {
+		r, err := ParseVersionRange(test.r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.r, err)
+			continue
+		}
+		for v, expected := range test.versions {
+			version, ok := parseVersion(v)
+			if !ok {
+				t.Fatalf("%s: invalid version", v)
+			}
+			if r.Matches(*version) != expected {
+				t.Errorf("%s: expected %s to match: %t", test.r, v, expected)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"", ">=2.1 <x", "~2", "1.2.3.4", "2.1.0-rc1"} {
+		if _, err := ParseVersionRange(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestUpdateVersionTrackingTags(t *testing.T) {
+	event := func(image string) TagEventList {
+		return TagEventList{Items: []TagEvent{{DockerImageReference: "registry/ns/app@" + image, Image: image}}}
+	}
+	stream := &ImageStream{
+		Spec: ImageStreamSpec{
+			Tags: map[string]TagReference{
+				"2":      {Track: ">=2 <3"},
+				"2.1":    {Track: ">=2.1 <2.2"},
+				"1":      {Track: ">=1 <2"},
+				"stable": {Track: ">=2 <3", Immutable: true},
+			},
+		},
+		Status: ImageStreamStatus{
+			Tags: map[string]TagEventList{
+				"latest":     event("latest"),
+				"1.9.0-rc1":  event("rc"),
+				"2.0.0":      event("200"),
+				"2.1.3":      event("213"),
+				"v2.1.10":    event("2110"),
+				"2.1.10":     event("2110b"),
+				"2.2":        event("22"),
+				"3.0.0":      event("300"),
+				"stable":     event("old"),
+				"2.1-extras": event("extras"),
+			},
+		},
+	}
+
+	if updated := UpdateVersionTrackingTags(stream); updated != 2 {
+		t.Errorf("expected 2 tags to be updated, got %d", updated)
+	}
+	for tag, image := range map[string]string{"2": "22", "2.1": "2110", "stable": "old"} {
+		if latest := LatestTaggedImage(stream, tag); latest == nil || latest.Image != image {
+			t.Errorf("expected tag %s to reference image %s, got %#v", tag, image, latest)
+		}
+	}
+	if latest := LatestTaggedImage(stream, "1"); latest != nil {
+		t.Errorf("expected tag 1 to reference no image, got %#v", latest)
+	}
+
+	if updated := UpdateVersionTrackingTags(stream); updated != 0 {
+		t.Errorf("expected no tag to be updated again, got %d", updated)
+	}
+}
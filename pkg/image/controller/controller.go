@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/unversioned"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 	kerrors "k8s.io/kubernetes/pkg/util/errors"
 	"k8s.io/kubernetes/pkg/util/sets"
 
@@ -20,6 +22,11 @@ import (
 type ImportController struct {
 	streams  client.ImageStreamsNamespacer
 	mappings client.ImageStreamMappingsNamespacer
+	// secrets holds the docker registry secrets used to authenticate to the
+	// registries of the images of a namespace
+	secrets kclient.SecretsNamespacer
+	// registries restricts the registries images are imported from
+	registries api.RegistryPolicy
 	// injected for testing
 	client dockerregistry.Client
 }
@@ -61,9 +68,9 @@ func (c *ImportController) Next(stream *api.ImageStream) error {
 	glog.V(4).Infof("Importing stream %s/%s...", stream.Namespace, stream.Name)
 
 	insecure := stream.Annotations[api.InsecureRepositoryAnnotation] == "true"
-	client := c.client
-	if client == nil {
-		client = dockerregistry.NewClient()
+	client, err := c.registryClient(stream)
+	if err != nil {
+		return err
 	}
 
 	var errlist []error
@@ -79,7 +86,11 @@ func (c *ImportController) Next(stream *api.ImageStream) error {
 		errlist = append(errlist, err)
 	}
 
-	retry, err = c.importTags(stream, toImport, client, insecure)
+	outcomes := make(map[string]error)
+	retry, err = c.importTags(stream, toImport, client, insecure, outcomes)
+	if _, recordErr := c.recordOutcomes(stream, outcomes, unversioned.Now(), retryCount); recordErr != nil {
+		glog.V(2).Infof("Unable to record the imports of %s/%s: %v", stream.Namespace, stream.Name, recordErr)
+	}
 	if err != nil {
 		if retry {
 			return err
@@ -94,6 +105,20 @@ func (c *ImportController) Next(stream *api.ImageStream) error {
 	return c.done(stream, "", retryCount)
 }
 
+// registryClient returns the client importing the images of stream, which
+// authenticates with the docker registry secrets of the namespace of stream
+// and connects only to the registries allowed by the registry policy.
+func (c *ImportController) registryClient(stream *api.ImageStream) (dockerregistry.Client, error) {
+	if c.client != nil {
+		return dockerregistry.NewRestrictedClient(c.client, c.registries), nil
+	}
+	credentials, err := dockerregistry.CredentialsForNamespace(c.secrets, stream.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the docker registry secrets of %s: %v", stream.Namespace, err)
+	}
+	return dockerregistry.NewRestrictedClient(dockerregistry.NewClientWithCredentials(credentials), c.registries), nil
+}
+
 // getTags returns a map of tags to be imported, a flag saying if we should retry
 // imports, meaning not setting the import annotation and an error if one occurs.
 // Tags explicitly defined will overwrite those from default upstream image repository.
@@ -104,9 +129,18 @@ func getTags(stream *api.ImageStream, client dockerregistry.Client, insecure boo
 	// read explicitly defined tags
 	for tagName, specTag := range stream.Spec.Tags {
 		if specTag.From == nil {
+			// the image of a tag tracking versions is set by the server
+			if len(specTag.Track) > 0 {
+				references.Insert(tagName)
+			}
+			continue
+		}
+		// the image of an immutable tag is never replaced
+		if api.IsTagImmutable(stream, tagName) {
+			references.Insert(tagName)
 			continue
 		}
-		if specTag.From.Kind != "DockerImage" || specTag.Reference {
+		if specTag.From.Kind != "DockerImage" || api.IsTagReferenceOnly(specTag) {
 			references.Insert(tagName)
 			continue
 		}
@@ -122,26 +156,47 @@ func getTags(stream *api.ImageStream, client dockerregistry.Client, insecure boo
 		return imports, false, nil
 	}
 
-	// read tags from default upstream image repository
+	repositoryImports, retry, err := repositoryTags(stream, client, insecure)
+	if err != nil {
+		return imports, retry, err
+	}
+	for tag, ref := range repositoryImports {
+		if _, ok := imports[tag]; ok || references.Has(tag) || api.IsTagImmutable(stream, tag) {
+			continue
+		}
+		imports[tag] = ref
+	}
+
+	return imports, false, nil
+}
+
+// repositoryTags returns the tags of spec.DockerImageRepository of stream to
+// import, those allowed by spec.Mirror if it is set, a flag saying if we
+// should retry imports and an error if one occurs.
+func repositoryTags(stream *api.ImageStream, client dockerregistry.Client, insecure bool) (map[string]api.DockerImageReference, bool, error) {
 	streamRef, err := api.ParseDockerImageReference(stream.Spec.DockerImageRepository)
 	if err != nil {
-		return imports, false, err
+		return nil, false, err
 	}
 	conn, err := client.Connect(streamRef.Registry, insecure)
 	if err != nil {
+		if dockerregistry.IsRegistryForbidden(err) {
+			return nil, false, err
+		}
 		// retry-able error no. 1
-		return imports, true, err
+		return nil, true, err
 	}
 	tags, err := conn.ImageTags(streamRef.Namespace, streamRef.Name)
 	switch {
 	case dockerregistry.IsRepositoryNotFound(err), dockerregistry.IsRegistryNotFound(err):
-		return imports, false, err
+		return nil, false, err
 	case err != nil:
 		// retry-able error no. 2
-		return imports, true, err
+		return nil, true, err
 	}
+	imports := make(map[string]api.DockerImageReference)
 	for tag, image := range tags {
-		if _, ok := imports[tag]; ok || references.Has(tag) {
+		if stream.Spec.Mirror != nil && !api.MirrorsTag(stream.Spec.Mirror, tag) {
 			continue
 		}
 		idTagPresent := false
@@ -166,15 +221,17 @@ func getTags(stream *api.ImageStream, client dockerregistry.Client, insecure boo
 	return imports, false, nil
 }
 
-// importTags imports tags specified in a map from given ImageStream. Returns flag
-// saying if we should retry imports, meaning not setting the import annotation
-// and an error if one occurs.
-func (c *ImportController) importTags(stream *api.ImageStream, imports map[string]api.DockerImageReference, client dockerregistry.Client, insecure bool) (bool, error) {
+// importTags imports tags specified in a map from given ImageStream, and adds
+// the outcome of the import of each tag to outcomes. Returns flag saying if we
+// should retry imports, meaning not setting the import annotation and an error
+// if one occurs.
+func (c *ImportController) importTags(stream *api.ImageStream, imports map[string]api.DockerImageReference, client dockerregistry.Client, insecure bool, outcomes map[string]error) (bool, error) {
 	retrieved := make(map[string]*dockerregistry.Image)
 	var errlist []error
 	shouldRetry := false
 	for tag, ref := range imports {
 		image, retry, err := c.importTag(stream, tag, ref, retrieved[ref.ID], client, insecure)
+		outcomes[tag] = err
 		if err != nil {
 			if retry {
 				shouldRetry = retry
@@ -196,8 +253,14 @@ func (c *ImportController) importTag(stream *api.ImageStream, tag string, ref ap
 	glog.V(5).Infof("Importing tag %s from %s/%s...", tag, stream.Namespace, stream.Name)
 	if dockerImage == nil {
 		// TODO insecure applies to the stream's spec.dockerImageRepository, not necessarily to an external one!
+		if specTag, ok := stream.Spec.Tags[tag]; ok && specTag.ImportPolicy.Insecure {
+			insecure = true
+		}
 		conn, err := client.Connect(ref.Registry, insecure)
 		if err != nil {
+			if dockerregistry.IsRegistryForbidden(err) {
+				return nil, false, err
+			}
 			// retry-able error no. 3
 			return nil, true, err
 		}
@@ -240,6 +303,15 @@ func (c *ImportController) importTag(stream *api.ImageStream, tag string, ref ap
 			DockerImageMetadata:  image,
 		},
 	}
+	// the sizes of the layers are recorded, so that the image size limits of
+	// the project can be checked
+	if dockerImage.Layers != nil {
+		layers, err := json.Marshal(dockerImage.Layers)
+		if err != nil {
+			return nil, false, err
+		}
+		mapping.Image.Annotations = map[string]string{api.ImageLayersAnnotation: string(layers)}
+	}
 	if err := c.mappings.ImageStreamMappings(stream.Namespace).Create(mapping); err != nil {
 		// retry-able no. 5
 		return nil, true, err
@@ -247,6 +319,54 @@ func (c *ImportController) importTag(stream *api.ImageStream, tag string, ref ap
 	return dockerImage, false, nil
 }
 
+// recordOutcomes sets the ImportSuccess conditions of the tags of stream that
+// were imported at now. The stream is read again, as the imports update its
+// status, unless every import succeeded and no tag had failed before.
+func (c *ImportController) recordOutcomes(stream *api.ImageStream, outcomes map[string]error, now unversioned.Time, retry int) (*api.ImageStream, error) {
+	failing := false
+	for tag, err := range outcomes {
+		if err != nil || api.TagImportFailure(stream, tag) != nil {
+			failing = true
+			break
+		}
+	}
+	if !failing {
+		return stream, nil
+	}
+	latest, err := c.streams.ImageStreams(stream.Namespace).Get(stream.Name)
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	for tag, err := range outcomes {
+		if setImportCondition(latest, tag, err, now) {
+			changed = true
+		}
+	}
+	if !changed {
+		return latest, nil
+	}
+	updated, err := c.streams.ImageStreams(stream.Namespace).UpdateStatus(latest)
+	if err != nil {
+		if errors.IsConflict(err) && retry > 0 {
+			return c.recordOutcomes(stream, outcomes, now, retry-1)
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+// setImportCondition records the outcome of an import of tag in the status of
+// stream: a failure sets the ImportSuccess condition to False, or increments
+// its failures, a success removes it. It returns true if the status changed.
+func setImportCondition(stream *api.ImageStream, tag string, err error, now unversioned.Time) bool {
+	if err == nil {
+		return api.ClearTagImportFailed(stream, tag)
+	}
+	api.SetTagImportFailed(stream, tag, dockerregistry.ImportFailureReason(err), err.Error(), now)
+	return true
+}
+
 // done marks the stream as being processed due to an error or failure condition.
 func (c *ImportController) done(stream *api.ImageStream, reason string, retry int) error {
 	if len(reason) == 0 {
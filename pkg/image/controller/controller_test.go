@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
@@ -71,6 +72,26 @@ func (f *fakeDockerRegistryClient) ImageByID(namespace, name, id string) (*docke
 	return nil, dockerregistry.NewImageNotFoundError(fmt.Sprintf("%s/%s", namespace, name), id, "")
 }
 
+func (f *fakeDockerRegistryClient) ImageManifest(namespace, name, reference string) (string, []byte, error) {
+	return "", nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeDockerRegistryClient) ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error) {
+	return nil, 0, fmt.Errorf("not implemented")
+}
+
+func (f *fakeDockerRegistryClient) ImageLayerExists(namespace, name, dgst string) (bool, error) {
+	return false, fmt.Errorf("not implemented")
+}
+
+func (f *fakeDockerRegistryClient) PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeDockerRegistryClient) PutImageManifest(namespace, name, reference string, manifest []byte) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
 func TestControllerNoOp(t *testing.T) {
 	cli, fake := &fakeDockerRegistryClient{}, &client.Fake{}
 	c := ImportController{client: cli, streams: fake, mappings: fake}
@@ -199,6 +220,13 @@ func TestControllerExternalReferenceRepo(t *testing.T) {
 					},
 					Reference: true,
 				},
+				"1.2": {
+					From: &kapi.ObjectReference{
+						Kind: "DockerImage",
+						Name: "some/repo:mytag",
+					},
+					ImportPolicy: api.TagImportPolicy{ImportMode: api.ReferenceTagImportMode},
+				},
 			},
 		},
 	}
@@ -251,8 +279,14 @@ func TestControllerExternalRepoFails(t *testing.T) {
 	if len(stream.Annotations[api.DockerImageRepositoryCheckAnnotation]) != 0 {
 		t.Errorf("should not set annotation: %#v", stream)
 	}
-	if len(fake.Actions()) != 0 {
-		t.Error("expected no actions on fake client")
+	actions := fake.Actions()
+	if len(actions) != 2 || !actions[0].Matches("get", "imagestreams") || !actions[1].Matches("update", "imagestreams") || actions[1].GetSubresource() != "status" {
+		t.Fatalf("expected the failure to be recorded in the status, got %#v", actions)
+	}
+	updated := actions[1].(kclient.CreateAction).GetObject().(*api.ImageStream)
+	condition := api.TagImportFailure(updated, "1.1")
+	if condition == nil || condition.Reason != api.ImportFailedReason || condition.Failures != 1 || !strings.Contains(condition.Message, expectedError.Error()) {
+		t.Errorf("unexpected import condition %#v", condition)
 	}
 }
 
@@ -335,6 +369,32 @@ func TestControllerRetrievesInsecure(t *testing.T) {
 	}
 }
 
+func TestControllerRetrievesInsecureTag(t *testing.T) {
+	cli, fake := &fakeDockerRegistryClient{Err: fmt.Errorf("test error")}, &client.Fake{}
+	c := ImportController{client: cli, streams: fake, mappings: fake}
+
+	stream := api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:      "test",
+			Namespace: "other",
+		},
+		Spec: api.ImageStreamSpec{
+			Tags: map[string]api.TagReference{
+				"latest": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/foo/bar:latest"},
+					ImportPolicy: api.TagImportPolicy{Insecure: true},
+				},
+			},
+		},
+	}
+	if err := c.Next(&stream); err != nil && !strings.Contains(err.Error(), cli.Err.Error()) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !cli.Insecure {
+		t.Errorf("expected insecure call: %#v", cli)
+	}
+}
+
 func TestControllerImageNotFoundError(t *testing.T) {
 	cli, fake := &fakeDockerRegistryClient{Tags: map[string]string{api.DefaultImageTag: "not_found"}}, &client.Fake{}
 	c := ImportController{client: cli, streams: fake, mappings: fake}
@@ -351,14 +411,51 @@ func TestControllerImageNotFoundError(t *testing.T) {
 		t.Errorf("did not set annotation: %#v", stream)
 	}
 	actions := fake.Actions()
-	if len(actions) != 1 {
-		t.Fatalf("expected 1 action, got %#v", actions)
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 actions, got %#v", actions)
 	}
-	if !actions[0].Matches("update", "imagestreams") {
+	if !actions[1].Matches("update", "imagestreams") || actions[1].GetSubresource() != "status" {
+		t.Errorf("expected a status update action: %#v", actions)
+	}
+	updated := actions[1].(kclient.CreateAction).GetObject().(*api.ImageStream)
+	if condition := api.TagImportFailure(updated, api.DefaultImageTag); condition == nil || condition.Reason != api.ImportNotFoundReason {
+		t.Errorf("unexpected import condition %#v", condition)
+	}
+	if !actions[2].Matches("update", "imagestreams") {
 		t.Errorf("expected an update action: %#v", actions)
 	}
 }
 
+func TestControllerRegistryForbidden(t *testing.T) {
+	cli, fake := &fakeDockerRegistryClient{}, &client.Fake{}
+	c := ImportController{client: cli, streams: fake, mappings: fake, registries: api.RegistryPolicy{Blocked: []string{"docker.io"}}}
+	stream := api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Name: "test", Namespace: "other"},
+		Spec: api.ImageStreamSpec{
+			Tags: map[string]api.TagReference{
+				api.DefaultImageTag: {From: &kapi.ObjectReference{Kind: "DockerImage", Name: "foo/bar:latest"}},
+			},
+		},
+	}
+	if err := c.Next(&stream); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(cli.Registry) > 0 || len(cli.Name) > 0 {
+		t.Errorf("unexpected connection to a blocked registry: %#v", cli)
+	}
+	if len(stream.Annotations[api.DockerImageRepositoryCheckAnnotation]) == 0 {
+		t.Errorf("did not set annotation: %#v", stream)
+	}
+	actions := fake.Actions()
+	if len(actions) != 3 || !actions[1].Matches("update", "imagestreams") || actions[1].GetSubresource() != "status" {
+		t.Fatalf("expected a status update action: %#v", actions)
+	}
+	updated := actions[1].(kclient.CreateAction).GetObject().(*api.ImageStream)
+	if condition := api.TagImportFailure(updated, api.DefaultImageTag); condition == nil || condition.Reason != api.ImportForbiddenReason {
+		t.Errorf("unexpected import condition %#v", condition)
+	}
+}
+
 func TestControllerImageWithGenericError(t *testing.T) {
 	cli, fake := &fakeDockerRegistryClient{
 		Tags: map[string]string{api.DefaultImageTag: "found"},
@@ -382,8 +479,8 @@ func TestControllerImageWithGenericError(t *testing.T) {
 	if len(stream.Annotations[api.DockerImageRepositoryCheckAnnotation]) != 0 {
 		t.Errorf("should not set annotation: %#v", stream)
 	}
-	if len(fake.Actions()) != 0 {
-		t.Error("expected no actions on fake client")
+	if actions := fake.Actions(); len(actions) != 2 || actions[1].GetSubresource() != "status" {
+		t.Errorf("expected the failure to be recorded in the status, got %#v", actions)
 	}
 }
 
@@ -554,14 +651,17 @@ func TestControllerWithExternalAndDefaultRegistryErrorOnOneTag(t *testing.T) {
 		t.Errorf("should not set annotation: %#v", stream)
 	}
 	actions := fake.Actions()
-	if len(actions) != 2 {
-		t.Fatalf("expected 2 actions, got %#v", actions)
+	if len(actions) != 4 {
+		t.Fatalf("expected 4 actions, got %#v", actions)
 	}
 	for i := 0; i < 1; i++ {
 		if !actions[i].Matches("create", "imagestreammappings") {
 			t.Errorf("expected a create action: %d %#v", i, actions[i])
 		}
 	}
+	if actions[3].GetSubresource() != "status" {
+		t.Errorf("expected the failure to be recorded in the status, got %#v", actions[3])
+	}
 }
 
 func TestControllerWithSpecTags(t *testing.T) {
@@ -662,7 +762,6 @@ func TestControllerWithSpecTags(t *testing.T) {
 
 func TestControllerReturnsErrForRetries(t *testing.T) {
 	expErr := fmt.Errorf("expected error")
-	osClient := &client.Fake{}
 	errISMClient := &client.Fake{}
 	errISMClient.PrependReactor("create", "imagestreammappings", func(action kclient.Action) (handled bool, ret runtime.Object, err error) {
 		return true, ret, expErr
@@ -677,7 +776,7 @@ func TestControllerReturnsErrForRetries(t *testing.T) {
 		"retry-able error no. 1": {
 			singleError: true,
 			expActions:  0,
-			fakeClient:  osClient,
+			fakeClient:  &client.Fake{},
 			fakeDocker: &fakeDockerRegistryClient{
 				ConnErr: expErr,
 			},
@@ -691,7 +790,7 @@ func TestControllerReturnsErrForRetries(t *testing.T) {
 		"retry-able error no. 2": {
 			singleError: true,
 			expActions:  0,
-			fakeClient:  osClient,
+			fakeClient:  &client.Fake{},
 			fakeDocker: &fakeDockerRegistryClient{
 				Err: expErr,
 			},
@@ -704,8 +803,8 @@ func TestControllerReturnsErrForRetries(t *testing.T) {
 		},
 		"retry-able error no. 3": {
 			singleError: false,
-			expActions:  0,
-			fakeClient:  osClient,
+			expActions:  2,
+			fakeClient:  &client.Fake{},
 			fakeDocker: &fakeDockerRegistryClient{
 				ConnErr: expErr,
 			},
@@ -725,8 +824,8 @@ func TestControllerReturnsErrForRetries(t *testing.T) {
 		},
 		"retry-able error no. 4": {
 			singleError: false,
-			expActions:  0,
-			fakeClient:  osClient,
+			expActions:  2,
+			fakeClient:  &client.Fake{},
 			fakeDocker: &fakeDockerRegistryClient{
 				Images: []expectedImage{
 					{
@@ -757,7 +856,7 @@ func TestControllerReturnsErrForRetries(t *testing.T) {
 		},
 		"retry-able error no. 5": {
 			singleError: false,
-			expActions:  1,
+			expActions:  3,
 			fakeClient:  errISMClient,
 			fakeDocker: &fakeDockerRegistryClient{
 				Images: []expectedImage{
@@ -805,7 +904,7 @@ func TestControllerReturnsErrForRetries(t *testing.T) {
 			t.Errorf("%s: unexpected error from importTags: %v", name, err)
 		}
 		if len(test.fakeClient.Actions()) != test.expActions {
-			t.Errorf("%s: expected no actions: %#v", name, test.fakeClient.Actions())
+			t.Errorf("%s: expected %d actions: %#v", name, test.expActions, test.fakeClient.Actions())
 		}
 	}
 }
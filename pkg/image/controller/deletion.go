@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api/errors"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+// ImageStreamDeletionController removes the image streams whose deletion
+// grace period expired. Until then, a deleted image stream keeps referencing
+// its images so that they aren't pruned, and its deletion may be undone with
+// "oadm restore imagestream".
+type ImageStreamDeletionController struct {
+	streams client.ImageStreamsNamespacer
+	now     func() time.Time
+}
+
+// Next deletes stream if it is being deleted and its grace period expired.
+// The server removes an image stream deleted again after its grace period.
+func (c *ImageStreamDeletionController) Next(stream *api.ImageStream) error {
+	if stream.DeletionTimestamp == nil || c.now().Before(stream.DeletionTimestamp.Time) {
+		return nil
+	}
+	if err := c.streams.ImageStreams(stream.Namespace).Delete(stream.Name); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	glog.V(4).Infof("Removed the image stream %s/%s, its images may be pruned", stream.Namespace, stream.Name)
+	return nil
+}
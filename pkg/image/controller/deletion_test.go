@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+
+	client "github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+func TestImageStreamDeletionControllerNext(t *testing.T) {
+	now := time.Now()
+	past := unversioned.NewTime(now.Add(-time.Minute))
+	future := unversioned.NewTime(now.Add(time.Minute))
+
+	tests := map[string]struct {
+		deletion *unversioned.Time
+		deleted  bool
+	}{
+		"not deleted":        {},
+		"grace period":       {deletion: &future},
+		"grace period ended": {deletion: &past, deleted: true},
+	}
+	for name, test := range tests {
+		stream := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app", DeletionTimestamp: test.deletion}}
+		fake := &client.Fake{}
+		c := &ImageStreamDeletionController{streams: fake, now: func() time.Time { return now }}
+		if err := c.Next(stream); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		actions := fake.Actions()
+		switch {
+		case test.deleted && (len(actions) != 1 || !actions[0].Matches("delete", "imagestreams")):
+			t.Errorf("%s: expected the stream to be deleted, got %#v", name, actions)
+		case !test.deleted && len(actions) != 0:
+			t.Errorf("%s: unexpected actions %#v", name, actions)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"net/http"
 	"time"
 
 	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/client/cache"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/runtime"
@@ -15,11 +18,17 @@ import (
 	"github.com/openshift/origin/pkg/client"
 	"github.com/openshift/origin/pkg/controller"
 	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/prune"
 )
 
 // ImportControllerFactory can create an ImportController.
 type ImportControllerFactory struct {
 	Client client.Interface
+	// Secrets holds the docker registry secrets used to import images from
+	// registries requiring authentication.
+	Secrets kclient.SecretsNamespacer
+	// Registries restricts the registries images are imported from.
+	Registries api.RegistryPolicy
 }
 
 // Create creates an ImportController.
@@ -36,8 +45,10 @@ func (f *ImportControllerFactory) Create() controller.RunnableController {
 	cache.NewReflector(lw, &api.ImageStream{}, q, 2*time.Minute).Run()
 
 	c := &ImportController{
-		streams:  f.Client,
-		mappings: f.Client,
+		streams:    f.Client,
+		mappings:   f.Client,
+		secrets:    f.Secrets,
+		registries: f.Registries,
 	}
 
 	return &controller.RetryController{
@@ -57,3 +68,278 @@ func (f *ImportControllerFactory) Create() controller.RunnableController {
 		},
 	}
 }
+
+// TagHistoryControllerFactory can create a TagHistoryController.
+type TagHistoryControllerFactory struct {
+	Client client.Interface
+}
+
+// Create creates a TagHistoryController.
+func (f *TagHistoryControllerFactory) Create() controller.RunnableController {
+	lw := &cache.ListWatch{
+		ListFunc: func() (runtime.Object, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
+		},
+		WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).Watch(labels.Everything(), fields.Everything(), resourceVersion)
+		},
+	}
+	q := cache.NewFIFO(cache.MetaNamespaceKeyFunc)
+	cache.NewReflector(lw, &api.ImageStream{}, q, 2*time.Minute).Run()
+
+	c := &TagHistoryController{
+		streams: f.Client,
+		images:  f.Client,
+	}
+
+	return &controller.RetryController{
+		Queue: q,
+		RetryManager: controller.NewQueueRetryManager(
+			q,
+			cache.MetaNamespaceKeyFunc,
+			func(obj interface{}, err error, retries controller.Retry) bool {
+				util.HandleError(err)
+				return retries.Count < 5
+			},
+			kutil.NewTokenBucketRateLimiter(1, 10),
+		),
+		Handle: func(obj interface{}) error {
+			r := obj.(*api.ImageStream)
+			return c.Next(r)
+		},
+	}
+}
+
+// ImageStreamDeletionControllerFactory can create an
+// ImageStreamDeletionController.
+type ImageStreamDeletionControllerFactory struct {
+	Client client.Interface
+}
+
+// Create creates an ImageStreamDeletionController. The image streams are
+// resynced every minute, so that they are removed soon after their grace period
+// expired.
+func (f *ImageStreamDeletionControllerFactory) Create() controller.RunnableController {
+	lw := &cache.ListWatch{
+		ListFunc: func() (runtime.Object, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), api.DeletedImageStreams())
+		},
+		WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).Watch(labels.Everything(), api.DeletedImageStreams(), resourceVersion)
+		},
+	}
+	q := cache.NewFIFO(cache.MetaNamespaceKeyFunc)
+	cache.NewReflector(lw, &api.ImageStream{}, q, time.Minute).Run()
+
+	c := &ImageStreamDeletionController{
+		streams: f.Client,
+		now:     time.Now,
+	}
+
+	return &controller.RetryController{
+		Queue: q,
+		RetryManager: controller.NewQueueRetryManager(
+			q,
+			cache.MetaNamespaceKeyFunc,
+			func(obj interface{}, err error, retries controller.Retry) bool {
+				util.HandleError(err)
+				return retries.Count < 5
+			},
+			kutil.NewTokenBucketRateLimiter(1, 10),
+		),
+		Handle: func(obj interface{}) error {
+			r := obj.(*api.ImageStream)
+			return c.Next(r)
+		},
+	}
+}
+
+// ScheduledImportControllerFactory can create a ScheduledImportController.
+type ScheduledImportControllerFactory struct {
+	Client client.Interface
+	// Secrets holds the docker registry secrets used to import images from
+	// registries requiring authentication.
+	Secrets kclient.SecretsNamespacer
+	// Registries restricts the registries images are imported from.
+	Registries api.RegistryPolicy
+	// Interval is the time between two imports of a scheduled tag,
+	// DefaultScheduledImportInterval if it's zero.
+	Interval time.Duration
+}
+
+// Create creates a ScheduledImportController.
+func (f *ScheduledImportControllerFactory) Create() controller.RunnableController {
+	lw := &cache.ListWatch{
+		ListFunc: func() (runtime.Object, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
+		},
+		WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).Watch(labels.Everything(), fields.Everything(), resourceVersion)
+		},
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	cache.NewReflector(lw, &api.ImageStream{}, store, 2*time.Minute).Run()
+
+	interval := f.Interval
+	if interval <= 0 {
+		interval = DefaultScheduledImportInterval
+	}
+	return &ScheduledImportController{
+		streams: f.Client,
+		importer: &ImportController{
+			streams:    f.Client,
+			mappings:   f.Client,
+			secrets:    f.Secrets,
+			registries: f.Registries,
+		},
+		store:          store,
+		interval:       interval,
+		now:            time.Now,
+		random:         random,
+		next:           make(map[string]time.Time),
+		mirrorFailures: make(map[string]int),
+	}
+}
+
+// ImageFinalizerControllerFactory can create an ImageFinalizerController.
+type ImageFinalizerControllerFactory struct {
+	Client client.Interface
+	// RegistryAddress returns the address of the integrated registry, the
+	// content of the images is deleted from.
+	RegistryAddress func() (string, bool)
+	// RegistryClient is used to delete the content of the images from the
+	// integrated registry with its admin API. It is only used over https.
+	RegistryClient *http.Client
+}
+
+// Create creates an ImageFinalizerController.
+func (f *ImageFinalizerControllerFactory) Create() controller.RunnableController {
+	lw := &cache.ListWatch{
+		ListFunc: func() (runtime.Object, error) {
+			return f.Client.Images().List(labels.Everything(), fields.Everything())
+		},
+		WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+			return f.Client.Images().Watch(labels.Everything(), fields.Everything(), resourceVersion)
+		},
+	}
+	q := cache.NewFIFO(cache.MetaNamespaceKeyFunc)
+	cache.NewReflector(lw, &api.Image{}, q, 2*time.Minute).Run()
+
+	// the images and image streams referencing the layers of a deleted image
+	// are looked up in indexes, instead of listing the whole cluster for
+	// every image
+	imageIndex := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{layersIndex: indexImageLayers})
+	imageReflector := cache.NewReflector(lw, &api.Image{}, imageIndex, 2*time.Minute)
+	imageReflector.Run()
+	streamLW := &cache.ListWatch{
+		ListFunc: func() (runtime.Object, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), api.ImageStreamsIncludingDeleted())
+		},
+		WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+			return f.Client.ImageStreams(kapi.NamespaceAll).Watch(labels.Everything(), api.ImageStreamsIncludingDeleted(), resourceVersion)
+		},
+	}
+	streamIndex := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{imagesIndex: indexStreamImages})
+	streamReflector := cache.NewReflector(streamLW, &api.ImageStream{}, streamIndex, 2*time.Minute)
+	streamReflector.Run()
+
+	c := &ImageFinalizerController{
+		images:      f.Client,
+		imageIndex:  imageIndex,
+		streamIndex: streamIndex,
+		synced: func() bool {
+			return len(imageReflector.LastSyncResourceVersion()) > 0 && len(streamReflector.LastSyncResourceVersion()) > 0
+		},
+		registryAddress: f.RegistryAddress,
+		registryClient:  f.RegistryClient,
+		manifestPruner:  prune.NewSecureDeletingManifestPruner(),
+		layerPruner:     prune.NewSecureDeletingLayerPruner(),
+		blobPruner:      prune.NewSecureDeletingBlobPruner(),
+	}
+
+	return &controller.RetryController{
+		Queue: q,
+		RetryManager: controller.NewQueueRetryManager(
+			q,
+			cache.MetaNamespaceKeyFunc,
+			func(obj interface{}, err error, retries controller.Retry) bool {
+				util.HandleError(err)
+				return retries.Count < 5
+			},
+			kutil.NewTokenBucketRateLimiter(1, 10),
+		),
+		Handle: func(obj interface{}) error {
+			image := obj.(*api.Image)
+			return c.Next(image)
+		},
+	}
+}
+
+// ImageStreamStorageUsageControllerFactory can create an
+// ImageStreamStorageUsageController.
+type ImageStreamStorageUsageControllerFactory struct {
+	Client client.Interface
+	// Interval is how often the storage usage of the image streams is
+	// reported.
+	Interval time.Duration
+}
+
+// Create creates an ImageStreamStorageUsageController.
+func (f *ImageStreamStorageUsageControllerFactory) Create() controller.RunnableController {
+	return &ImageStreamStorageUsageController{
+		images:   f.Client,
+		streams:  f.Client,
+		interval: f.Interval,
+	}
+}
+
+// ImageQuotaControllerFactory can create an ImageQuotaController.
+type ImageQuotaControllerFactory struct {
+	Client     client.Interface
+	KubeClient kclient.Interface
+	// ResyncPeriod is how often the usage of every quota is recounted.
+	ResyncPeriod time.Duration
+}
+
+// Create creates an ImageQuotaController. A conflict with a concurrent update
+// of a quota is retried with the quota read again.
+func (f *ImageQuotaControllerFactory) Create() controller.RunnableController {
+	lw := &cache.ListWatch{
+		ListFunc: func() (runtime.Object, error) {
+			return f.KubeClient.ResourceQuotas(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
+		},
+		WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+			return f.KubeClient.ResourceQuotas(kapi.NamespaceAll).Watch(labels.Everything(), fields.Everything(), resourceVersion)
+		},
+	}
+	q := cache.NewFIFO(cache.MetaNamespaceKeyFunc)
+	cache.NewReflector(lw, &kapi.ResourceQuota{}, q, f.ResyncPeriod).Run()
+
+	c := &ImageQuotaController{
+		quotas:  f.KubeClient,
+		streams: f.Client,
+	}
+
+	return &controller.RetryController{
+		Queue: q,
+		RetryManager: controller.NewQueueRetryManager(
+			q,
+			cache.MetaNamespaceKeyFunc,
+			func(obj interface{}, err error, retries controller.Retry) bool {
+				util.HandleError(err)
+				return retries.Count < 5
+			},
+			kutil.NewTokenBucketRateLimiter(1, 10),
+		),
+		Handle: func(obj interface{}) error {
+			resourceQuota := obj.(*kapi.ResourceQuota)
+			err := c.Next(resourceQuota)
+			if errors.IsConflict(err) {
+				if latest, getErr := f.KubeClient.ResourceQuotas(resourceQuota.Namespace).Get(resourceQuota.Name); getErr == nil {
+					return c.Next(latest)
+				}
+			}
+			return err
+		},
+	}
+}
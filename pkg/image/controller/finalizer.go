@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/client/cache"
+	kerrors "k8s.io/kubernetes/pkg/util/errors"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/prune"
+)
+
+const (
+	// layersIndex is the name of the index of the images by layer.
+	layersIndex = "layers"
+	// imagesIndex is the name of the index of the image streams by image.
+	imagesIndex = "images"
+)
+
+// ImageFinalizerController removes the content of the images being deleted
+// from the storage of the integrated registry, then removes their registry
+// finalizer and deletes them, so that deleting an image through the API
+// doesn't leave its manifest and layers behind in the registry.
+type ImageFinalizerController struct {
+	images client.ImagesInterfacer
+
+	// imageIndex holds the images of the cluster, indexed by their layers.
+	imageIndex cache.Indexer
+	// streamIndex holds the image streams of the cluster, indexed by the
+	// images of their tag history.
+	streamIndex cache.Indexer
+	// synced returns true once both indexes hold all the images and image
+	// streams of the cluster.
+	synced func() bool
+
+	// registryAddress returns the address of the integrated registry. The
+	// content of the images is only ever removed from this address, never
+	// from the registry recorded in the reference of the image.
+	registryAddress func() (string, bool)
+	// registryClient is used to call the admin API of the registry.
+	registryClient *http.Client
+	manifestPruner prune.ManifestPruner
+	layerPruner    prune.LayerPruner
+	blobPruner     prune.BlobPruner
+}
+
+// Next removes the content of image from the registry if the image is being
+// deleted and has the registry finalizer, and deletes the image once it has
+// no finalizers left.
+func (c *ImageFinalizerController) Next(image *api.Image) error {
+	if image.DeletionTimestamp == nil {
+		return nil
+	}
+
+	if api.HasFinalizer(image, api.ImageFinalizerRegistry) {
+		if image.Annotations[api.ManagedByOpenShiftAnnotation] == "true" {
+			if err := c.removeFromRegistry(image); err != nil {
+				return err
+			}
+		}
+
+		finalizers := []kapi.FinalizerName{}
+		for _, finalizer := range image.Finalizers {
+			if finalizer != api.ImageFinalizerRegistry {
+				finalizers = append(finalizers, finalizer)
+			}
+		}
+		image.Finalizers = finalizers
+		updated, err := c.images.Images().Finalize(image)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		image = updated
+	}
+
+	if len(image.Finalizers) > 0 {
+		return nil
+	}
+	if err := c.images.Images().Delete(image.Name); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	glog.V(4).Infof("Deleted image %s and its content in the registry", image.Name)
+	return nil
+}
+
+// removeFromRegistry deletes the manifest of image, with its signatures, from
+// the repositories of the image streams referencing it, and the layers of the
+// image no other image references, from the repositories and the blob store.
+func (c *ImageFinalizerController) removeFromRegistry(image *api.Image) error {
+	if !c.synced() {
+		return fmt.Errorf("the images and image streams of the cluster are not loaded yet")
+	}
+	registryURL, ok := c.registryAddress()
+	if !ok || len(registryURL) == 0 {
+		return fmt.Errorf("unable to determine the address of the integrated registry")
+	}
+
+	layers := imageLayers(image)
+	sharedLayers := sets.NewString()
+	for _, layer := range layers.List() {
+		others, err := c.imageIndex.ByIndex(layersIndex, layer)
+		if err != nil {
+			return err
+		}
+		for _, obj := range others {
+			if obj.(*api.Image).Name != image.Name {
+				sharedLayers.Insert(layer)
+				break
+			}
+		}
+	}
+
+	// the repository the image was pushed to, and the ones it was tagged into
+	streams, err := c.streamIndex.ByIndex(imagesIndex, image.Name)
+	if err != nil {
+		return err
+	}
+	if ref, err := api.ParseDockerImageReference(image.DockerImageReference); err == nil && len(ref.Namespace) > 0 {
+		obj, exists, err := c.streamIndex.GetByKey(ref.Namespace + "/" + ref.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			obj = &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: ref.Namespace, Name: ref.Name}}
+		}
+		streams = append(streams, obj)
+	}
+	repos := map[string]sets.String{}
+	for _, obj := range streams {
+		stream := obj.(*api.ImageStream)
+		streamLayers := sets.NewString()
+		for _, history := range stream.Status.Tags {
+			for _, event := range history.Items {
+				if event.Image == image.Name {
+					continue
+				}
+				other, exists, err := c.imageIndex.GetByKey(event.Image)
+				if err != nil {
+					return err
+				}
+				if exists {
+					streamLayers.Insert(imageLayers(other.(*api.Image)).List()...)
+				}
+			}
+		}
+		repos[stream.Namespace+"/"+stream.Name] = streamLayers
+	}
+
+	errs := []error{}
+	for repo, streamLayers := range repos {
+		if err := c.manifestPruner.PruneManifest(c.registryClient, registryURL, repo, image.Name); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting manifest %s from repository %s: %v", image.Name, repo, err))
+		}
+		for _, layer := range layers.Difference(streamLayers).List() {
+			if err := c.layerPruner.PruneLayer(c.registryClient, registryURL, repo, layer); err != nil {
+				errs = append(errs, fmt.Errorf("error deleting layer %s from repository %s: %v", layer, repo, err))
+			}
+		}
+	}
+	for _, layer := range layers.Difference(sharedLayers).List() {
+		if err := c.blobPruner.PruneBlob(c.registryClient, registryURL, layer); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting blob %s: %v", layer, err))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// indexImageLayers indexes an image by the layers of its manifest.
+func indexImageLayers(obj interface{}) ([]string, error) {
+	return imageLayers(obj.(*api.Image)).List(), nil
+}
+
+// indexStreamImages indexes an image stream by the images of its tag history.
+func indexStreamImages(obj interface{}) ([]string, error) {
+	images := sets.NewString()
+	for _, history := range obj.(*api.ImageStream).Status.Tags {
+		for _, event := range history.Items {
+			images.Insert(event.Image)
+		}
+	}
+	return images.List(), nil
+}
+
+// imageLayers returns the layers listed in the manifest of image.
+func imageLayers(image *api.Image) sets.String {
+	layers := sets.NewString()
+	if len(image.DockerImageManifest) == 0 {
+		return layers
+	}
+	manifest := api.DockerImageManifest{}
+	if err := json.Unmarshal([]byte(image.DockerImageManifest), &manifest); err != nil {
+		glog.V(4).Infof("Unable to read the manifest of image %s: %v", image.Name, err)
+		return layers
+	}
+	for _, layer := range manifest.FSLayers {
+		layers.Insert(layer.DockerBlobSum)
+	}
+	return layers
+}
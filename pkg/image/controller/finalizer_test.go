@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/client/cache"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	client "github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+type fakeRegistryPruner struct {
+	deletions sets.String
+	err       error
+}
+
+func (p *fakeRegistryPruner) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	p.deletions.Insert(fmt.Sprintf("manifest %s %s %s", registryURL, repo, manifest))
+	return p.err
+}
+
+func (p *fakeRegistryPruner) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	p.deletions.Insert(fmt.Sprintf("layer %s %s %s", registryURL, repo, layer))
+	return p.err
+}
+
+func (p *fakeRegistryPruner) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
+	p.deletions.Insert(fmt.Sprintf("blob %s %s", registryURL, blob))
+	return p.err
+}
+
+func finalizerTestImage(name string, managed bool, layers ...string) api.Image {
+	manifest := `{"schemaVersion":1,"fsLayers":[`
+	for i, layer := range layers {
+		if i > 0 {
+			manifest += ","
+		}
+		manifest += fmt.Sprintf(`{"blobSum":%q}`, layer)
+	}
+	manifest += "]}"
+	image := api.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: name},
+		DockerImageReference: "registry:5000/ns/is@" + name,
+		DockerImageManifest:  manifest,
+	}
+	if managed {
+		image.Annotations = map[string]string{api.ManagedByOpenShiftAnnotation: "true"}
+	}
+	return image
+}
+
+// newTestImageFinalizerController returns a controller indexing images and
+// streams, and removing content from the integrated registry at registry:5000.
+func newTestImageFinalizerController(fake *client.Fake, pruner *fakeRegistryPruner, images *api.ImageList, streams *api.ImageStreamList) *ImageFinalizerController {
+	imageIndex := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{layersIndex: indexImageLayers})
+	for i := range images.Items {
+		imageIndex.Add(&images.Items[i])
+	}
+	streamIndex := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{imagesIndex: indexStreamImages})
+	for i := range streams.Items {
+		streamIndex.Add(&streams.Items[i])
+	}
+	return &ImageFinalizerController{
+		images:          fake,
+		imageIndex:      imageIndex,
+		streamIndex:     streamIndex,
+		synced:          func() bool { return true },
+		registryAddress: func() (string, bool) { return "registry:5000", true },
+		manifestPruner:  pruner,
+		layerPruner:     pruner,
+		blobPruner:      pruner,
+	}
+}
+
+func TestImageFinalizerControllerNext(t *testing.T) {
+	deleted := finalizerTestImage("id1", true, "layer1", "layer2", "layer3")
+	deleted.Finalizers = []kapi.FinalizerName{api.ImageFinalizerRegistry}
+	now := unversioned.Now()
+	deleted.DeletionTimestamp = &now
+
+	images := &api.ImageList{Items: []api.Image{
+		deleted,
+		finalizerTestImage("id2", true, "layer2"),
+		finalizerTestImage("id3", true, "layer3"),
+	}}
+	streams := &api.ImageStreamList{Items: []api.ImageStream{
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "is"},
+			Status: api.ImageStreamStatus{Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{Image: "id2"}, {Image: "id1"}}},
+			}},
+		},
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "other", Name: "tagged"},
+			Status: api.ImageStreamStatus{Tags: map[string]api.TagEventList{
+				"prod": {Items: []api.TagEvent{{Image: "id1"}}},
+			}},
+		},
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "other", Name: "unrelated"},
+			Status: api.ImageStreamStatus{Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{Image: "id3"}}},
+			}},
+		},
+	}}
+
+	fake := &client.Fake{}
+	fake.AddReactor("update", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, action.(ktestclient.UpdateAction).GetObject(), nil
+	})
+	pruner := &fakeRegistryPruner{deletions: sets.NewString()}
+	c := newTestImageFinalizerController(fake, pruner, images, streams)
+
+	// the registry failing keeps the finalizer
+	pruner.err = errors.New("registry unavailable")
+	image := deleted
+	if err := c.Next(&image); err == nil {
+		t.Fatalf("expected the error of the registry")
+	}
+	if actions := fake.Actions(); len(actions) != 0 {
+		t.Fatalf("unexpected actions %#v", actions)
+	}
+
+	fake.ClearActions()
+	pruner.err = nil
+	pruner.deletions = sets.NewString()
+	image = deleted
+	if err := c.Next(&image); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := sets.NewString(
+		"manifest registry:5000 ns/is id1",
+		"manifest registry:5000 other/tagged id1",
+		// layer2 is still used by id2 in ns/is
+		"layer registry:5000 ns/is layer1",
+		"layer registry:5000 ns/is layer3",
+		"layer registry:5000 other/tagged layer1",
+		"layer registry:5000 other/tagged layer2",
+		"layer registry:5000 other/tagged layer3",
+		// layer2 and layer3 are shared with id2 and id3
+		"blob registry:5000 layer1",
+	)
+	if !reflect.DeepEqual(expected, pruner.deletions) {
+		t.Errorf("expected deletions %v, got %v", expected.List(), pruner.deletions.List())
+	}
+
+	actions := fake.Actions()
+	if len(actions) != 2 || !actions[0].Matches("update", "images") || actions[0].GetSubresource() != "finalize" || !actions[1].Matches("delete", "images") {
+		t.Fatalf("expected the image to be finalized and deleted, got %#v", actions)
+	}
+	if finalized := actions[0].(ktestclient.UpdateAction).GetObject().(*api.Image); len(finalized.Finalizers) != 0 {
+		t.Errorf("expected the registry finalizer to be removed, got %v", finalized.Finalizers)
+	}
+}
+
+func TestImageFinalizerControllerSkipsRegistry(t *testing.T) {
+	now := unversioned.Now()
+	tests := map[string]struct {
+		image           api.Image
+		expectedActions []string
+	}{
+		"not deleted": {
+			image: func() api.Image {
+				image := finalizerTestImage("id1", true, "layer1")
+				image.Finalizers = []kapi.FinalizerName{api.ImageFinalizerRegistry}
+				return image
+			}(),
+		},
+		"external image": {
+			image: func() api.Image {
+				image := finalizerTestImage("id1", false, "layer1")
+				image.Finalizers = []kapi.FinalizerName{api.ImageFinalizerRegistry}
+				image.DeletionTimestamp = &now
+				return image
+			}(),
+			expectedActions: []string{"update", "delete"},
+		},
+		"other finalizers": {
+			image: func() api.Image {
+				image := finalizerTestImage("id1", false, "layer1")
+				image.Finalizers = []kapi.FinalizerName{api.ImageFinalizerRegistry, "example.com/other"}
+				image.DeletionTimestamp = &now
+				return image
+			}(),
+			expectedActions: []string{"update"},
+		},
+		"finalized image": {
+			image: func() api.Image {
+				image := finalizerTestImage("id1", true, "layer1")
+				image.DeletionTimestamp = &now
+				return image
+			}(),
+			expectedActions: []string{"delete"},
+		},
+	}
+
+	for name, test := range tests {
+		fake := &client.Fake{}
+		fake.AddReactor("update", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			return true, action.(ktestclient.UpdateAction).GetObject(), nil
+		})
+		pruner := &fakeRegistryPruner{deletions: sets.NewString()}
+		c := newTestImageFinalizerController(fake, pruner, &api.ImageList{}, &api.ImageStreamList{})
+		image := test.image
+		if err := c.Next(&image); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if pruner.deletions.Len() > 0 {
+			t.Errorf("%s: unexpected registry deletions %v", name, pruner.deletions.List())
+		}
+		verbs := []string{}
+		for _, action := range fake.Actions() {
+			verbs = append(verbs, action.GetVerb())
+		}
+		if len(verbs) != len(test.expectedActions) || (len(verbs) > 0 && !reflect.DeepEqual(verbs, test.expectedActions)) {
+			t.Errorf("%s: expected actions %v, got %v", name, test.expectedActions, verbs)
+		}
+	}
+}
+
+func TestImageFinalizerControllerUsesIntegratedRegistry(t *testing.T) {
+	now := unversioned.Now()
+	image := finalizerTestImage("id1", true, "layer1")
+	image.DockerImageReference = "attacker.example.com/ns/is@id1"
+	image.Finalizers = []kapi.FinalizerName{api.ImageFinalizerRegistry}
+	image.DeletionTimestamp = &now
+
+	fake := &client.Fake{}
+	fake.AddReactor("update", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, action.(ktestclient.UpdateAction).GetObject(), nil
+	})
+	pruner := &fakeRegistryPruner{deletions: sets.NewString()}
+	c := newTestImageFinalizerController(fake, pruner, &api.ImageList{Items: []api.Image{image}}, &api.ImageStreamList{})
+
+	// nothing is removed until the address of the registry is known, and the
+	// indexes are loaded
+	c.registryAddress = func() (string, bool) { return "", false }
+	deleted := image
+	if err := c.Next(&deleted); err == nil {
+		t.Fatalf("expected an error without the address of the registry")
+	}
+	c.registryAddress = func() (string, bool) { return "registry:5000", true }
+	c.synced = func() bool { return false }
+	deleted = image
+	if err := c.Next(&deleted); err == nil {
+		t.Fatalf("expected an error until the indexes are loaded")
+	}
+	if pruner.deletions.Len() > 0 || len(fake.Actions()) > 0 {
+		t.Fatalf("unexpected deletions %v and actions %#v", pruner.deletions.List(), fake.Actions())
+	}
+
+	c.synced = func() bool { return true }
+	deleted = image
+	if err := c.Next(&deleted); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := sets.NewString(
+		"manifest registry:5000 ns/is id1",
+		"layer registry:5000 ns/is layer1",
+		"blob registry:5000 layer1",
+	)
+	if !reflect.DeepEqual(expected, pruner.deletions) {
+		t.Errorf("expected deletions %v, got %v", expected.List(), pruner.deletions.List())
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/util"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/image/api"
+	imageutil "github.com/openshift/origin/pkg/image/util"
+)
+
+// TagHistoryController trims the history of the tags of image streams to the
+// limit set in their spec. The images removed from the history of all the tags
+// of a stream are no longer referenced by it and may be pruned: they are marked
+// for pruning, so that the pruner doesn't keep them as untagged images pushed
+// to the stream.
+type TagHistoryController struct {
+	streams client.ImageStreamsNamespacer
+	images  client.ImagesInterfacer
+}
+
+// Next trims the history of the tags of stream if it exceeds
+// stream.spec.tagHistoryLimit, and updates the status of the stream.
+func (c *TagHistoryController) Next(stream *api.ImageStream) error {
+	if stream.Spec.TagHistoryLimit <= 0 {
+		return nil
+	}
+
+	unreferenced, changed := api.TrimTagHistory(stream)
+	if !changed {
+		return nil
+	}
+	if _, err := c.streams.ImageStreams(stream.Namespace).UpdateStatus(stream); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if len(unreferenced) > 0 {
+		glog.V(4).Infof("Trimmed the tag history of image stream %s/%s, images %v may be pruned", stream.Namespace, stream.Name, unreferenced)
+	}
+	for _, name := range unreferenced {
+		if err := imageutil.MarkImageForPruning(c.images, name); err != nil {
+			util.HandleError(fmt.Errorf("unable to mark image %s for pruning: %v", name, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+
+	client "github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+func TestTagHistoryControllerNext(t *testing.T) {
+	stream := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec:       api.ImageStreamSpec{TagHistoryLimit: 2},
+		Status: api.ImageStreamStatus{
+			Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{Image: "c"}, {Image: "b"}, {Image: "a"}}},
+			},
+		},
+	}
+
+	fake := client.NewSimpleFake(stream, &api.Image{ObjectMeta: kapi.ObjectMeta{Name: "a"}})
+	c := &TagHistoryController{streams: fake, images: fake}
+	if err := c.Next(stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actions := fake.Actions()
+	if len(actions) != 3 || !actions[0].Matches("update", "imagestreams") || actions[0].GetSubresource() != "status" {
+		t.Fatalf("expected the status of the stream to be updated, got %#v", actions)
+	}
+	if !actions[2].Matches("update", "images") {
+		t.Fatalf("expected the trimmed image to be updated, got %#v", actions)
+	}
+	if image := actions[2].(ktestclient.UpdateAction).GetObject().(*api.Image); image.Name != "a" || image.Annotations[api.ImageMarkedForPruningAnnotation] != "true" {
+		t.Errorf("expected the trimmed image to be marked for pruning, got %#v", image)
+	}
+	updated := actions[0].(ktestclient.CreateAction).GetObject().(*api.ImageStream)
+	if items := updated.Status.Tags["latest"].Items; len(items) != 2 || items[0].Image != "c" || items[1].Image != "b" {
+		t.Errorf("unexpected tag history %#v", items)
+	}
+
+	fake.ClearActions()
+	if err := c.Next(updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.Actions()) != 0 {
+		t.Errorf("expected no update of a trimmed stream, got %#v", fake.Actions())
+	}
+}
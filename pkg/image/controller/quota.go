@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"github.com/golang/glog"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/quota"
+)
+
+// ImageQuotaController records the usage of the "openshift.io/imagestreams"
+// and "openshift.io/images" resources in the status of the ResourceQuotas
+// limiting them, which the kubernetes quota controller doesn't track. The
+// ImageQuota admission plugin and the imports reserve usage as image streams
+// are created and images tagged, the controller recounts it so that what the
+// deleted image streams and untagged images used is released.
+type ImageQuotaController struct {
+	quotas  kclient.ResourceQuotasNamespacer
+	streams client.ImageStreamsNamespacer
+}
+
+// Next recounts the usage of the image resources limited by resourceQuota and
+// updates its status if it changed.
+func (c *ImageQuotaController) Next(resourceQuota *kapi.ResourceQuota) error {
+	tracked := []kapi.ResourceName{}
+	for _, name := range []kapi.ResourceName{api.ResourceImageStreams, api.ResourceImages} {
+		if _, ok := resourceQuota.Spec.Hard[name]; ok {
+			tracked = append(tracked, name)
+		}
+	}
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	// the image streams being deleted count until they are removed, since
+	// they can be restored
+	streams, err := c.streams.ImageStreams(resourceQuota.Namespace).List(labels.Everything(), api.ImageStreamsIncludingDeleted())
+	if err != nil {
+		return err
+	}
+	usage := quota.Usage(streams.Items)
+
+	dirty := false
+	status := kapi.ResourceQuotaStatus{Hard: kapi.ResourceList{}, Used: kapi.ResourceList{}}
+	for k, v := range resourceQuota.Status.Hard {
+		status.Hard[k] = *v.Copy()
+	}
+	for k, v := range resourceQuota.Status.Used {
+		status.Used[k] = *v.Copy()
+	}
+	for _, name := range tracked {
+		used, ok := status.Used[name]
+		value := usage[name]
+		if !ok || used.Value() != value.Value() {
+			dirty = true
+		}
+		hard := resourceQuota.Spec.Hard[name]
+		status.Used[name] = value
+		status.Hard[name] = *hard.Copy()
+	}
+	if !dirty {
+		return nil
+	}
+
+	_, err = c.quotas.ResourceQuotas(resourceQuota.Namespace).UpdateStatus(&kapi.ResourceQuota{
+		ObjectMeta: kapi.ObjectMeta{
+			Name:            resourceQuota.Name,
+			Namespace:       resourceQuota.Namespace,
+			ResourceVersion: resourceQuota.ResourceVersion,
+			Labels:          resourceQuota.Labels,
+			Annotations:     resourceQuota.Annotations,
+		},
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+	glog.V(4).Infof("Recorded the image usage of quota %s/%s", resourceQuota.Namespace, resourceQuota.Name)
+	return nil
+}
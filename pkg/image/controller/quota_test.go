@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/resource"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+
+	client "github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+func TestImageQuotaControllerNext(t *testing.T) {
+	quantity := func(value int64) resource.Quantity {
+		return *resource.NewQuantity(value, resource.DecimalSI)
+	}
+	streams := &api.ImageStreamList{Items: []api.ImageStream{
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+			Status: api.ImageStreamStatus{Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{Image: "image2"}, {Image: "image1"}}},
+			}},
+		},
+		{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "other"},
+			Status: api.ImageStreamStatus{Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{Image: "image1"}}},
+			}},
+		},
+	}}
+
+	tests := map[string]struct {
+		hard     kapi.ResourceList
+		used     kapi.ResourceList
+		expected kapi.ResourceList
+	}{
+		"not limited": {
+			hard: kapi.ResourceList{kapi.ResourcePods: quantity(1)},
+		},
+		"unknown usage": {
+			hard:     kapi.ResourceList{api.ResourceImageStreams: quantity(5), kapi.ResourcePods: quantity(1)},
+			used:     kapi.ResourceList{kapi.ResourcePods: quantity(1)},
+			expected: kapi.ResourceList{api.ResourceImageStreams: quantity(2), kapi.ResourcePods: quantity(1)},
+		},
+		"reservations released": {
+			hard:     kapi.ResourceList{api.ResourceImageStreams: quantity(5), api.ResourceImages: quantity(5)},
+			used:     kapi.ResourceList{api.ResourceImageStreams: quantity(3), api.ResourceImages: quantity(4)},
+			expected: kapi.ResourceList{api.ResourceImageStreams: quantity(2), api.ResourceImages: quantity(2)},
+		},
+		"usage unchanged": {
+			hard: kapi.ResourceList{api.ResourceImages: quantity(5)},
+			used: kapi.ResourceList{api.ResourceImages: quantity(2)},
+		},
+	}
+	for name, test := range tests {
+		resourceQuota := &kapi.ResourceQuota{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "quota"},
+			Spec:       kapi.ResourceQuotaSpec{Hard: test.hard},
+			Status:     kapi.ResourceQuotaStatus{Hard: test.hard, Used: test.used},
+		}
+		quotas := ktestclient.NewSimpleFake(resourceQuota)
+		c := &ImageQuotaController{quotas: quotas, streams: client.NewSimpleFake(streams)}
+		if err := c.Next(resourceQuota); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		actions := quotas.Actions()
+		if test.expected == nil {
+			if len(actions) != 0 {
+				t.Errorf("%s: unexpected actions %#v", name, actions)
+			}
+			continue
+		}
+		if len(actions) != 1 || !actions[0].Matches("update", "resourcequotas") {
+			t.Errorf("%s: expected the status of the quota to be updated, got %#v", name, actions)
+			continue
+		}
+		used := actions[0].(ktestclient.UpdateAction).GetObject().(*kapi.ResourceQuota).Status.Used
+		if len(used) != len(test.expected) {
+			t.Errorf("%s: expected usage %v, got %v", name, test.expected, used)
+		}
+		for k, expected := range test.expected {
+			if value := used[k]; value.Value() != expected.Value() {
+				t.Errorf("%s: expected usage %v, got %v", name, test.expected, used)
+			}
+		}
+	}
+}
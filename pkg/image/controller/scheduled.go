@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/client/cache"
+	kutil "k8s.io/kubernetes/pkg/util"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/dockerregistry"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+const (
+	// DefaultScheduledImportInterval is the time between two imports of a
+	// scheduled tag.
+	DefaultScheduledImportInterval = 15 * time.Minute
+	// scheduledImportMaxBackoff bounds the time between two imports of a
+	// scheduled tag that fails to import.
+	scheduledImportMaxBackoff = 4 * time.Hour
+	// scheduledImportJitter is the maximum fraction of the delay before the
+	// next import of a tag added to it, so that the imports of the tags don't
+	// all happen at once.
+	scheduledImportJitter = 0.2
+	// scheduledImportScanPeriod is how often the scheduled tags are checked
+	// for imports that are due.
+	scheduledImportScanPeriod = 30 * time.Second
+)
+
+// ScheduledImportController re-imports the tags of image streams whose import
+// policy is scheduled from their source registry, every interval, so that
+// they track their source without being imported manually. A tag that fails
+// to import is retried with an exponential backoff, its failures are recorded
+// by the ImportSuccess condition of the tag in the status of the stream. The
+// tags of the docker repository of image streams with a mirror policy are
+// listed and imported every interval too, new tags included.
+type ScheduledImportController struct {
+	streams  client.ImageStreamsNamespacer
+	importer *ImportController
+	store    cache.Store
+	interval time.Duration
+	// injected for testing
+	client dockerregistry.Client
+	now    func() time.Time
+	// random returns a random duration shorter than its argument.
+	random func(time.Duration) time.Duration
+
+	lock sync.Mutex
+	// next is the time of the next import of every scheduled tag, keyed by
+	// <namespace>/<stream>:<tag>, and of the next mirroring of every mirrored
+	// stream, keyed by <namespace>/<stream>.
+	next map[string]time.Time
+	// mirrorFailures is the number of consecutive failures to list the tags
+	// of the docker repository of every mirrored stream.
+	mirrorFailures map[string]int
+}
+
+// Run checks the scheduled tags for imports that are due every
+// scheduledImportScanPeriod.
+func (c *ScheduledImportController) Run() {
+	go kutil.Forever(c.scan, scheduledImportScanPeriod)
+}
+
+// scheduledTags returns the spec tags of stream to import periodically. The
+// immutable tags that reference an image already are left out.
+func scheduledTags(stream *api.ImageStream) map[string]api.DockerImageReference {
+	tags := make(map[string]api.DockerImageReference)
+	for tag, specTag := range stream.Spec.Tags {
+		if !specTag.ImportPolicy.Scheduled || api.IsTagReferenceOnly(specTag) || specTag.From == nil || specTag.From.Kind != "DockerImage" {
+			continue
+		}
+		if api.IsTagImmutable(stream, tag) {
+			continue
+		}
+		ref, err := api.ParseDockerImageReference(specTag.From.Name)
+		if err != nil {
+			glog.V(2).Infof("error parsing DockerImage %s: %v", specTag.From.Name, err)
+			continue
+		}
+		tags[tag] = ref.DockerClientDefaults()
+	}
+	return tags
+}
+
+// scan imports the scheduled tags that are due of the streams in the store.
+func (c *ScheduledImportController) scan() {
+	seen := make(map[string]bool)
+	for _, obj := range c.store.List() {
+		stream := obj.(*api.ImageStream)
+		for _, key := range c.Next(stream) {
+			seen[key] = true
+		}
+	}
+
+	// forget the tags that aren't scheduled anymore
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key := range c.next {
+		if !seen[key] {
+			delete(c.next, key)
+			delete(c.mirrorFailures, key)
+		}
+	}
+}
+
+// Next imports the scheduled tags of stream whose import is due, and the tags
+// of its docker repository if its mirroring is due, records the outcome in the
+// status of the stream and schedules their next import. It returns the keys of
+// the scheduled tags of stream, and the key of stream if it is mirrored.
+func (c *ScheduledImportController) Next(stream *api.ImageStream) []string {
+	tags := scheduledTags(stream)
+	mirrored := stream.Spec.Mirror != nil && len(stream.Spec.DockerImageRepository) > 0
+	if len(tags) == 0 && !mirrored {
+		return nil
+	}
+
+	insecure := stream.Annotations[api.InsecureRepositoryAnnotation] == "true"
+	client := c.client
+	// the secrets are read once an import is due, a failure counts as a
+	// failed import
+	registryClient := func() (dockerregistry.Client, error) {
+		if client != nil {
+			return client, nil
+		}
+		var err error
+		client, err = c.importer.registryClient(stream)
+		return client, err
+	}
+
+	now := c.now()
+	keys := []string{}
+	outcomes := make(map[string]error)
+	if mirrored {
+		keys = append(keys, c.mirror(stream, registryClient, insecure, now, outcomes))
+	}
+	for tag, ref := range tags {
+		key := fmt.Sprintf("%s/%s:%s", stream.Namespace, stream.Name, tag)
+		keys = append(keys, key)
+
+		c.lock.Lock()
+		next, scheduled := c.next[key]
+		if !scheduled {
+			// spread the first imports over the interval
+			next = now.Add(c.random(c.delay(failures(stream, tag))))
+			c.next[key] = next
+		}
+		c.lock.Unlock()
+		if now.Before(next) {
+			continue
+		}
+
+		glog.V(4).Infof("Re-importing scheduled tag %s from %s", key, ref.Exact())
+		client, err := registryClient()
+		if err == nil {
+			_, _, err = c.importer.importTag(stream, tag, ref, nil, client, insecure)
+		}
+		if err != nil {
+			glog.V(2).Infof("Scheduled import of %s from %s failed: %v", key, ref.Exact(), err)
+		}
+		outcomes[tag] = err
+	}
+	if len(outcomes) == 0 {
+		return keys
+	}
+
+	updated, err := c.importer.recordOutcomes(stream, outcomes, unversioned.NewTime(now), retryCount)
+	if err != nil {
+		glog.V(2).Infof("Unable to record the scheduled imports of %s/%s: %v", stream.Namespace, stream.Name, err)
+		updated = stream
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for tag := range outcomes {
+		if _, ok := tags[tag]; !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s:%s", stream.Namespace, stream.Name, tag)
+		delay := c.delay(failures(updated, tag))
+		c.next[key] = now.Add(delay + c.random(time.Duration(float64(delay)*scheduledImportJitter)))
+	}
+	return keys
+}
+
+// mirror imports the tags of the docker repository of stream allowed by its
+// mirror policy if its mirroring is due, adds their outcome to outcomes and
+// schedules the next mirroring. The tags defined by the spec of stream are
+// left to their own import policy. It returns the key of stream.
+func (c *ScheduledImportController) mirror(stream *api.ImageStream, registryClient func() (dockerregistry.Client, error), insecure bool, now time.Time, outcomes map[string]error) string {
+	key := fmt.Sprintf("%s/%s", stream.Namespace, stream.Name)
+
+	c.lock.Lock()
+	next, scheduled := c.next[key]
+	if !scheduled {
+		// spread the first mirrorings over the interval
+		next = now.Add(c.random(c.delay(c.mirrorFailures[key])))
+		c.next[key] = next
+	}
+	c.lock.Unlock()
+	if now.Before(next) {
+		return key
+	}
+
+	glog.V(4).Infof("Mirroring the tags of %s into %s", stream.Spec.DockerImageRepository, key)
+	client, err := registryClient()
+	var tags map[string]api.DockerImageReference
+	if err == nil {
+		tags, _, err = repositoryTags(stream, client, insecure)
+	}
+	for tag, ref := range tags {
+		if _, ok := stream.Spec.Tags[tag]; ok {
+			continue
+		}
+		_, _, importErr := c.importer.importTag(stream, tag, ref, nil, client, insecure)
+		if importErr != nil {
+			glog.V(2).Infof("Import of mirrored tag %s:%s from %s failed: %v", key, tag, ref.Exact(), importErr)
+		}
+		outcomes[tag] = importErr
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err != nil {
+		glog.V(2).Infof("Unable to list the tags of %s to mirror into %s: %v", stream.Spec.DockerImageRepository, key, err)
+		c.mirrorFailures[key]++
+	} else {
+		delete(c.mirrorFailures, key)
+	}
+	delay := c.delay(c.mirrorFailures[key])
+	c.next[key] = now.Add(delay + c.random(time.Duration(float64(delay)*scheduledImportJitter)))
+	return key
+}
+
+// delay returns the time to wait before the next import of a tag that failed
+// to import failures times in a row.
+func (c *ScheduledImportController) delay(failures int) time.Duration {
+	delay := c.interval
+	for i := 0; i < failures && delay < scheduledImportMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > scheduledImportMaxBackoff {
+		delay = scheduledImportMaxBackoff
+	}
+	return delay
+}
+
+// failures returns the number of consecutive failed imports of tag recorded
+// in the status of stream.
+func failures(stream *api.ImageStream, tag string) int {
+	if condition := api.TagImportFailure(stream, tag); condition != nil {
+		return condition.Failures
+	}
+	return 0
+}
+
+func random(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
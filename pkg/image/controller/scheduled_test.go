@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	client "github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/dockerregistry"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+func TestScheduledTags(t *testing.T) {
+	stream := &api.ImageStream{
+		Spec: api.ImageStreamSpec{
+			Tags: map[string]api.TagReference{
+				"latest": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"},
+					ImportPolicy: api.TagImportPolicy{Scheduled: true},
+				},
+				"pinned": {
+					From: &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:1.0"},
+				},
+				"reference": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:2.0"},
+					Reference:    true,
+					ImportPolicy: api.TagImportPolicy{Scheduled: true},
+				},
+				"referenceMode": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:3.0"},
+					ImportPolicy: api.TagImportPolicy{Scheduled: true, ImportMode: api.ReferenceTagImportMode},
+				},
+				"tracking": {
+					From:         &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "app:latest"},
+					ImportPolicy: api.TagImportPolicy{Scheduled: true},
+				},
+			},
+		},
+	}
+	tags := scheduledTags(stream)
+	if len(tags) != 1 || tags["latest"].Exact() != "example.com/ns/app:latest" {
+		t.Errorf("expected only latest to be scheduled, got %#v", tags)
+	}
+}
+
+func TestScheduledImportController(t *testing.T) {
+	stream := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec: api.ImageStreamSpec{
+			Tags: map[string]api.TagReference{
+				"latest": {
+					From:         &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"},
+					ImportPolicy: api.TagImportPolicy{Scheduled: true},
+				},
+			},
+		},
+	}
+	fake, tracker := client.NewImageTrackerFake(stream)
+	registry := &fakeDockerRegistryClient{
+		ConnErr: errors.New("connection refused"),
+		Images: []expectedImage{{
+			Tag: "latest",
+			Image: &dockerregistry.Image{
+				Image: docker.Image{ID: "abc123", Config: &docker.Config{}},
+			},
+		}},
+	}
+	now := time.Now()
+	c := &ScheduledImportController{
+		streams:  fake,
+		importer: &ImportController{streams: fake, mappings: fake},
+		interval: time.Minute,
+		client:   registry,
+		now:      func() time.Time { return now },
+		random:   func(time.Duration) time.Duration { return 0 },
+		next:     make(map[string]time.Time),
+	}
+
+	// the import fails: it's recorded and retried after a backoff
+	if keys := c.Next(stream); len(keys) != 1 || keys[0] != "ns/app:latest" {
+		t.Fatalf("unexpected scheduled tags %v", keys)
+	}
+	current, _ := tracker.ImageStream("ns", "app")
+	if failures(current, "latest") != 1 {
+		t.Fatalf("expected the failure to be recorded, got %#v", current.Status.Tags)
+	}
+	if next := c.next["ns/app:latest"]; !next.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("expected the next import after 2m, got %v", next.Sub(now))
+	}
+
+	registry.ConnErr = nil
+	registry.Registry = ""
+	now = now.Add(time.Minute)
+	c.Next(current)
+	if len(registry.Registry) != 0 {
+		t.Errorf("unexpected import during the backoff")
+	}
+
+	now = now.Add(time.Minute)
+	c.Next(current)
+	current, _ = tracker.ImageStream("ns", "app")
+	history := current.Status.Tags["latest"]
+	if len(history.Items) != 1 || history.Items[0].Image != "abc123" {
+		t.Errorf("expected the tag to be imported, got %#v", history)
+	}
+	if len(history.Conditions) != 0 {
+		t.Errorf("expected the failure to be cleared, got %#v", history.Conditions)
+	}
+	if next := c.next["ns/app:latest"]; !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the next import after the interval, got %v", next.Sub(now))
+	}
+}
+
+func TestScheduledImportDelay(t *testing.T) {
+	c := &ScheduledImportController{interval: time.Hour}
+	for failures, expected := range map[int]time.Duration{
+		0:  time.Hour,
+		1:  2 * time.Hour,
+		2:  scheduledImportMaxBackoff,
+		10: scheduledImportMaxBackoff,
+	} {
+		if delay := c.delay(failures); delay != expected {
+			t.Errorf("%d failures: expected %v, got %v", failures, expected, delay)
+		}
+	}
+}
+
+func TestScheduledImportMirroring(t *testing.T) {
+	stream := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec: api.ImageStreamSpec{
+			DockerImageRepository: "example.com/ns/app",
+			Mirror: &api.RepositoryMirrorPolicy{
+				IncludeTags: []string{"1.*", "latest"},
+				ExcludeTags: []string{"*-rc*"},
+			},
+			Tags: map[string]api.TagReference{
+				"latest": {
+					From: &kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:1.0"},
+				},
+			},
+		},
+	}
+	fake, tracker := client.NewImageTrackerFake(stream)
+	registry := &fakeDockerRegistryClient{
+		Err: errors.New("connection reset"),
+		Tags: map[string]string{
+			"1.0":     "1.0",
+			"1.0-rc1": "1.0-rc1",
+			"2.0":     "2.0",
+			"latest":  "latest",
+		},
+		Images: []expectedImage{
+			{ID: "1.0", Image: &dockerregistry.Image{Image: docker.Image{ID: "abc123", Config: &docker.Config{}}}},
+			{ID: "1.0-rc1", Image: &dockerregistry.Image{Image: docker.Image{ID: "abc122", Config: &docker.Config{}}}},
+			{ID: "2.0", Image: &dockerregistry.Image{Image: docker.Image{ID: "abc200", Config: &docker.Config{}}}},
+			{ID: "latest", Image: &dockerregistry.Image{Image: docker.Image{ID: "abc124", Config: &docker.Config{}}}},
+		},
+	}
+	now := time.Now()
+	c := &ScheduledImportController{
+		streams:        fake,
+		importer:       &ImportController{streams: fake, mappings: fake},
+		interval:       time.Minute,
+		client:         registry,
+		now:            func() time.Time { return now },
+		random:         func(time.Duration) time.Duration { return 0 },
+		next:           make(map[string]time.Time),
+		mirrorFailures: make(map[string]int),
+	}
+
+	// listing the tags fails: the mirroring is retried after a backoff
+	if keys := c.Next(stream); len(keys) != 1 || keys[0] != "ns/app" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if next := c.next["ns/app"]; !next.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("expected the next mirroring after 2m, got %v", next.Sub(now))
+	}
+
+	registry.Err = nil
+	now = now.Add(2 * time.Minute)
+	c.Next(stream)
+	current, _ := tracker.ImageStream("ns", "app")
+	if history := current.Status.Tags["1.0"]; len(history.Items) != 1 || history.Items[0].Image != "abc123" {
+		t.Errorf("expected the tag 1.0 to be mirrored, got %#v", history)
+	}
+	for _, tag := range []string{"1.0-rc1", "2.0", "latest"} {
+		if history, ok := current.Status.Tags[tag]; ok {
+			t.Errorf("expected the tag %s not to be mirrored, got %#v", tag, history)
+		}
+	}
+	if next := c.next["ns/app"]; !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the next mirroring after the interval, got %v", next.Sub(now))
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util"
+	kerrors "k8s.io/kubernetes/pkg/util/errors"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/client"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+// ImageStreamStorageUsageController sets the ImageStreamStorageSizeAnnotation
+// of the image streams to the storage their images use in the integrated
+// registry. The sizes are read from the ImageLayersAnnotation the registry
+// records on the images pushed to it, so the controller runs with the other
+// controllers of the master rather than in every replica of the registry.
+type ImageStreamStorageUsageController struct {
+	images   client.ImagesInterfacer
+	streams  client.ImageStreamsNamespacer
+	interval time.Duration
+}
+
+// Run reports the storage usage of the image streams every interval, in the
+// background.
+func (c *ImageStreamStorageUsageController) Run() {
+	go util.Until(func() {
+		updated, err := c.Report()
+		if err != nil {
+			util.HandleError(fmt.Errorf("error reporting the storage usage of the image streams: %v", err))
+		}
+		glog.V(4).Infof("Updated the storage usage of %d image streams", updated)
+	}, c.interval, util.NeverStop)
+}
+
+// Report sets the ImageStreamStorageSizeAnnotation of every image stream whose
+// storage usage changed, and returns the number of image streams updated. The
+// annotation is removed from the image streams whose usage isn't known.
+func (c *ImageStreamStorageUsageController) Report() (int, error) {
+	images, err := c.images.Images().List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("error listing images: %v", err)
+	}
+	imagesByName := make(map[string]*api.Image, len(images.Items))
+	for i := range images.Items {
+		imagesByName[images.Items[i].Name] = &images.Items[i]
+	}
+
+	streams, err := c.streams.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), api.ImageStreamsIncludingDeleted())
+	if err != nil {
+		return 0, fmt.Errorf("error listing image streams: %v", err)
+	}
+
+	updated := 0
+	errs := []error{}
+	for i := range streams.Items {
+		stream := &streams.Items[i]
+		current, annotated := stream.Annotations[api.ImageStreamStorageSizeAnnotation]
+		size, known := imageStreamStorageSize(stream, imagesByName)
+		switch {
+		case !known && !annotated:
+			continue
+		case !known:
+			delete(stream.Annotations, api.ImageStreamStorageSizeAnnotation)
+		case current == strconv.FormatInt(size, 10):
+			continue
+		default:
+			if stream.Annotations == nil {
+				stream.Annotations = map[string]string{}
+			}
+			stream.Annotations[api.ImageStreamStorageSizeAnnotation] = strconv.FormatInt(size, 10)
+		}
+		if _, err := c.streams.ImageStreams(stream.Namespace).Update(stream); err != nil {
+			errs = append(errs, fmt.Errorf("error updating image stream %s/%s: %v", stream.Namespace, stream.Name, err))
+			continue
+		}
+		updated++
+	}
+	return updated, kerrors.NewAggregate(errs)
+}
+
+// imageStreamStorageSize returns the total size of the distinct layers of the
+// images in the tag history of stream that were pushed to its repository, as
+// recorded in their ImageLayersAnnotation. The images tagged from other image
+// streams are accounted to those, the remote images aren't stored. The size
+// isn't known if one of the images pushed to stream has no layers recorded,
+// e.g. because it was pushed before the registry recorded them.
+func imageStreamStorageSize(stream *api.ImageStream, images map[string]*api.Image) (int64, bool) {
+	var size int64
+	seenImages := sets.NewString()
+	seenLayers := sets.NewString()
+	for _, history := range stream.Status.Tags {
+		for _, event := range history.Items {
+			if seenImages.Has(event.Image) {
+				continue
+			}
+			seenImages.Insert(event.Image)
+
+			image, ok := images[event.Image]
+			if !ok || image.Annotations[api.ManagedByOpenShiftAnnotation] != "true" {
+				continue
+			}
+			ref, err := api.ParseDockerImageReference(image.DockerImageReference)
+			if err != nil || ref.Namespace != stream.Namespace || ref.Name != stream.Name {
+				continue
+			}
+			layers, ok := api.ImageLayerSizes(image)
+			if !ok {
+				return 0, false
+			}
+			for _, layer := range layers {
+				if seenLayers.Has(layer.Name) {
+					continue
+				}
+				seenLayers.Insert(layer.Name)
+				size += layer.Size
+			}
+		}
+	}
+	return size, true
+}
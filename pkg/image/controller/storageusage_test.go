@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+
+	client "github.com/openshift/origin/pkg/client/testclient"
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+func TestImageStreamStorageUsageControllerReport(t *testing.T) {
+	const (
+		digest1 = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+		digest2 = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+		digest3 = "sha256:0000000000000000000000000000000000000000000000000000000000000003"
+		digest4 = "sha256:0000000000000000000000000000000000000000000000000000000000000004"
+		digest5 = "sha256:0000000000000000000000000000000000000000000000000000000000000005"
+	)
+	pushed := func(name, repository, layers string) *api.Image {
+		image := &api.Image{
+			ObjectMeta: kapi.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{api.ManagedByOpenShiftAnnotation: "true"},
+			},
+			DockerImageReference: "registry:5000/" + repository + "@" + name,
+		}
+		if len(layers) > 0 {
+			image.Annotations[api.ImageLayersAnnotation] = layers
+		}
+		return image
+	}
+	stream := func(namespace, name, size string, images ...string) *api.ImageStream {
+		stream := &api.ImageStream{
+			ObjectMeta: kapi.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     api.ImageStreamStatus{Tags: map[string]api.TagEventList{}},
+		}
+		if len(size) > 0 {
+			stream.Annotations = map[string]string{api.ImageStreamStorageSizeAnnotation: size}
+		}
+		for _, image := range images {
+			stream.Status.Tags[image] = api.TagEventList{Items: []api.TagEvent{{Image: image}}}
+		}
+		return stream
+	}
+
+	app := stream("ns", "app", "", digest1, digest2, digest3, digest4)
+	// shares a layer with the first image
+	app.Status.Tags["latest"] = api.TagEventList{Items: []api.TagEvent{{Image: digest2}, {Image: digest1}}}
+	unchanged := stream("other", "app", "1000", digest3)
+	unknown := stream("ns", "old", "10", digest5)
+
+	fake, tracker := client.NewImageTrackerFake(
+		app, unchanged, unknown,
+		pushed(digest1, "ns/app", `[{"name":"sha256:a","size":100},{"name":"sha256:b","size":10}]`),
+		pushed(digest2, "ns/app", `[{"name":"sha256:a","size":100},{"name":"sha256:c","size":1}]`),
+		// pushed to another repository
+		pushed(digest3, "other/app", `[{"name":"sha256:d","size":1000}]`),
+		// remote
+		&api.Image{
+			ObjectMeta:           kapi.ObjectMeta{Name: digest4},
+			DockerImageReference: "docker.io/library/busybox@" + digest4,
+		},
+		// pushed before the registry recorded the layers
+		pushed(digest5, "ns/old", ""),
+	)
+	c := &ImageStreamStorageUsageController{images: fake, streams: fake}
+	updated, err := c.Report()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated != 2 {
+		t.Errorf("expected two image streams to be updated, got %d", updated)
+	}
+
+	expected := map[string]string{"ns/app": "111", "other/app": "1000", "ns/old": ""}
+	for name, size := range expected {
+		namespace, stream := name[:strings.Index(name, "/")], name[strings.Index(name, "/")+1:]
+		updated, ok := tracker.ImageStream(namespace, stream)
+		if !ok {
+			t.Fatalf("image stream %s not found", name)
+		}
+		if actual, annotated := updated.Annotations[api.ImageStreamStorageSizeAnnotation]; actual != size || annotated != (len(size) > 0) {
+			t.Errorf("%s: expected a storage size of %q, got %q", name, size, actual)
+		}
+	}
+}
@@ -11,6 +11,9 @@ import (
 const (
 	// ReferencedImageStreamGraphEdgeKind is an edge that goes from an ImageStreamTag node back to an ImageStream
 	ReferencedImageStreamGraphEdgeKind = "ReferencedImageStreamGraphEdge"
+	// ImportedImageStreamTagGraphEdgeKind is an edge that goes from the Docker repository tag a spec tag of an ImageStream
+	// imports or references to the ImageStreamTag
+	ImportedImageStreamTagGraphEdgeKind = "ImportedImageStreamTagGraphEdge"
 )
 
 // AddImageStreamRefEdge ensures that a directed edge exists between an IST Node and the IS it references
@@ -32,3 +35,25 @@ func AddAllImageStreamRefEdges(g osgraph.MutableUniqueGraph) {
 		}
 	}
 }
+
+// AddImageStreamTagSourceEdges ensures that a directed edge exists between the Docker repository tag every spec tag of the
+// IS imports or references and the IST of the tag, so that a stream can mix the tags of builds with tags of external images
+func AddImageStreamTagSourceEdges(g osgraph.MutableUniqueGraph, node *imagegraph.ImageStreamNode) {
+	for tag, tagRef := range node.Spec.Tags {
+		if tagRef.From == nil || tagRef.From.Kind != "DockerImage" {
+			continue
+		}
+		in := imagegraph.EnsureDockerRepositoryNode(g, tagRef.From.Name, "")
+		istNode := imagegraph.FindOrCreateSyntheticImageStreamTagNode(g, imagegraph.MakeImageStreamTagObjectMeta(node.Namespace, node.Name, tag))
+		g.AddEdge(in, istNode, ImportedImageStreamTagGraphEdgeKind)
+	}
+}
+
+// AddAllImageStreamTagSourceEdges calls AddImageStreamTagSourceEdges for every ImageStreamNode in the graph
+func AddAllImageStreamTagSourceEdges(g osgraph.MutableUniqueGraph) {
+	for _, node := range g.(graph.Graph).Nodes() {
+		if isNode, ok := node.(*imagegraph.ImageStreamNode); ok && isNode.IsFound {
+			AddImageStreamTagSourceEdges(g, isNode)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gonum/graph"
 
 	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
 
 	osgraph "github.com/openshift/origin/pkg/api/graph"
 	imageapi "github.com/openshift/origin/pkg/image/api"
@@ -20,11 +21,19 @@ func EnsureImageNode(g osgraph.MutableUniqueGraph, img *imageapi.Image) graph.No
 
 // EnsureAllImageStreamTagNodes creates all the ImageStreamTagNodes that are guaranteed to be present based on the ImageStream.
 // This is different than inferring the presence of an object, since the IST is an object derived from a join between the ImageStream
-// and the Image it references.
+// and the Image it references. The tags of the spec are included, even if they don't reference an image yet, e.g. when the image
+// of their external source isn't imported yet.
 func EnsureAllImageStreamTagNodes(g osgraph.MutableUniqueGraph, is *imageapi.ImageStream) []*ImageStreamTagNode {
 	ret := []*ImageStreamTagNode{}
 
+	tags := sets.NewString()
 	for tag := range is.Status.Tags {
+		tags.Insert(tag)
+	}
+	for tag := range is.Spec.Tags {
+		tags.Insert(tag)
+	}
+	for _, tag := range tags.List() {
 		ist := &imageapi.ImageStreamTag{}
 		ist.Namespace = is.Namespace
 		ist.Name = imageapi.JoinImageStreamTag(is.Name, tag)
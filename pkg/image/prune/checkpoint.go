@@ -0,0 +1,157 @@
+package prune
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// Checkpoint records the deletions from the registry completed by a prune run
+// in a file, one per line, so that an interrupted run can be resumed without
+// sending them again.
+type Checkpoint struct {
+	lock sync.Mutex
+	file *os.File
+	done sets.String
+}
+
+// OpenCheckpoint reads the deletions recorded in the file at path, creating it
+// if it doesn't exist. If dryRun is true, the file is neither created nor
+// written to and the deletions are only read.
+func OpenCheckpoint(path string, dryRun bool) (*Checkpoint, error) {
+	c := &Checkpoint{done: sets.NewString()}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+				c.done.Insert(line)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error reading the checkpoint file %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if !dryRun {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		c.file = f
+	}
+	return c, nil
+}
+
+// Done returns true if the deletion identified by key is recorded.
+func (c *Checkpoint) Done(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.done.Has(key)
+}
+
+// Record records the deletion identified by key.
+func (c *Checkpoint) Record(key string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.done.Insert(key)
+	if c.file == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(c.file, key)
+	return err
+}
+
+// Close closes the checkpoint file.
+func (c *Checkpoint) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// checkpointingLayerPruner skips the repository layer links deleted according
+// to its checkpoint, and records the ones its delegate deletes.
+type checkpointingLayerPruner struct {
+	delegate   LayerPruner
+	checkpoint *Checkpoint
+}
+
+var _ LayerPruner = &checkpointingLayerPruner{}
+
+// NewCheckpointingLayerPruner creates a LayerPruner invoking delegate for the
+// repository layer links which aren't recorded in checkpoint.
+func NewCheckpointingLayerPruner(delegate LayerPruner, checkpoint *Checkpoint) LayerPruner {
+	return &checkpointingLayerPruner{delegate: delegate, checkpoint: checkpoint}
+}
+
+func (p *checkpointingLayerPruner) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	key := fmt.Sprintf("layer %s %s %s", registryURL, repo, layer)
+	if p.checkpoint.Done(key) {
+		return nil
+	}
+	if err := p.delegate.PruneLayer(registryClient, registryURL, repo, layer); err != nil {
+		return err
+	}
+	return p.checkpoint.Record(key)
+}
+
+// checkpointingBlobPruner skips the blobs deleted according to its checkpoint,
+// and records the ones its delegate deletes.
+type checkpointingBlobPruner struct {
+	delegate   BlobPruner
+	checkpoint *Checkpoint
+}
+
+var _ BlobPruner = &checkpointingBlobPruner{}
+
+// NewCheckpointingBlobPruner creates a BlobPruner invoking delegate for the
+// blobs which aren't recorded in checkpoint.
+func NewCheckpointingBlobPruner(delegate BlobPruner, checkpoint *Checkpoint) BlobPruner {
+	return &checkpointingBlobPruner{delegate: delegate, checkpoint: checkpoint}
+}
+
+func (p *checkpointingBlobPruner) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
+	key := fmt.Sprintf("blob %s %s", registryURL, blob)
+	if p.checkpoint.Done(key) {
+		return nil
+	}
+	if err := p.delegate.PruneBlob(registryClient, registryURL, blob); err != nil {
+		return err
+	}
+	return p.checkpoint.Record(key)
+}
+
+// checkpointingManifestPruner skips the repository manifests deleted according
+// to its checkpoint, and records the ones its delegate deletes.
+type checkpointingManifestPruner struct {
+	delegate   ManifestPruner
+	checkpoint *Checkpoint
+}
+
+var _ ManifestPruner = &checkpointingManifestPruner{}
+
+// NewCheckpointingManifestPruner creates a ManifestPruner invoking delegate for
+// the repository manifests which aren't recorded in checkpoint.
+func NewCheckpointingManifestPruner(delegate ManifestPruner, checkpoint *Checkpoint) ManifestPruner {
+	return &checkpointingManifestPruner{delegate: delegate, checkpoint: checkpoint}
+}
+
+func (p *checkpointingManifestPruner) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	key := fmt.Sprintf("manifest %s %s %s", registryURL, repo, manifest)
+	if p.checkpoint.Done(key) {
+		return nil
+	}
+	if err := p.delegate.PruneManifest(registryClient, registryURL, repo, manifest); err != nil {
+		return err
+	}
+	return p.checkpoint.Record(key)
+}
@@ -0,0 +1,76 @@
+package prune
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+func TestCheckpointingBlobPruner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prune-checkpoint")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	checkpoint, err := OpenCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delegate := &fakeBlobPruner{invocations: sets.NewString()}
+	pruner := NewCheckpointingBlobPruner(delegate, checkpoint)
+	if err := pruner.PruneBlob(nil, "registry1", "layer1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delegate.err = errors.New("interrupted")
+	if err := pruner.PruneBlob(nil, "registry1", "layer2"); err == nil {
+		t.Fatalf("expected an error")
+	}
+	checkpoint.Close()
+
+	// resuming the run only deletes the blob which wasn't deleted
+	checkpoint, err = OpenCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer checkpoint.Close()
+	delegate = &fakeBlobPruner{invocations: sets.NewString()}
+	pruner = NewCheckpointingBlobPruner(delegate, checkpoint)
+	for _, layer := range []string{"layer1", "layer2"} {
+		if err := pruner.PruneBlob(nil, "registry1", layer); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if e, a := sets.NewString("registry1|layer2"), delegate.invocations; !e.Equal(a) {
+		t.Errorf("expected blob deletions %v, got %v", e.List(), a.List())
+	}
+	if !checkpoint.Done("blob registry1 layer2") {
+		t.Errorf("expected the deletion of layer2 to be recorded")
+	}
+}
+
+func TestCheckpointDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prune-checkpoint")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	checkpoint, err := OpenCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := checkpoint.Record("blob registry1 layer1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkpoint.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no checkpoint file to be written in a dry run, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package prune
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	kutil "k8s.io/kubernetes/pkg/util"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+var (
+	pruneRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_image_pruner",
+			Name:      "runs_total",
+			Help:      "Counter of pruning runs broken out by result",
+		},
+		[]string{"result"},
+	)
+
+	pruneCandidates = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "openshift_image_pruner",
+			Name:      "candidates",
+			Help:      "Number of objects the last pruning run found prunable broken out by kind",
+		},
+		[]string{"kind"},
+	)
+
+	pruneDeletions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "openshift_image_pruner",
+			Name:      "deletions_total",
+			Help:      "Counter of objects pruned broken out by kind and result",
+		},
+		[]string{"kind", "result"},
+	)
+
+	pruneLastRun = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "openshift_image_pruner",
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Time of the end of the last pruning run",
+		},
+	)
+
+	pruneRunDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "openshift_image_pruner",
+			Name:      "last_run_duration_seconds",
+			Help:      "Duration of the last pruning run",
+		},
+	)
+
+	prunePaused = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "openshift_image_pruner",
+			Name:      "paused",
+			Help:      "1 if the pruner only reports what it would prune, 0 otherwise",
+		},
+	)
+)
+
+// registerMetrics registers the metrics once a pruner runs, so that the other
+// processes using the package, e.g. the master, don't expose them.
+var registerMetrics sync.Once
+
+// The kinds of objects counted by the metrics of the pruner.
+const (
+	imageKind       = "image"
+	imageStreamKind = "imagestream"
+	layerKind       = "layer"
+	blobKind        = "blob"
+	manifestKind    = "manifest"
+)
+
+// ContinuousPruner prunes the images of the cluster and their data in the
+// registry periodically, and records its progress in Prometheus metrics.
+type ContinuousPruner struct {
+	// NewPruner returns a pruner of the images of the cluster as they are at
+	// the start of a run. It is passed true if the run is paused.
+	NewPruner func(paused bool) (ImageRegistryPruner, error)
+	// Interval is the time between the start of two runs.
+	Interval time.Duration
+	// Paused makes the runs report what they would prune in the metrics and
+	// the logs without deleting anything.
+	Paused bool
+
+	ImagePruner       ImagePruner
+	ImageStreamPruner ImageStreamPruner
+	LayerPruner       LayerPruner
+	BlobPruner        BlobPruner
+	ManifestPruner    ManifestPruner
+}
+
+// Run prunes once per interval until stopCh is closed.
+func (c *ContinuousPruner) Run(stopCh <-chan struct{}) {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(pruneRuns)
+		prometheus.MustRegister(pruneCandidates)
+		prometheus.MustRegister(pruneDeletions)
+		prometheus.MustRegister(pruneLastRun)
+		prometheus.MustRegister(pruneRunDuration)
+		prometheus.MustRegister(prunePaused)
+	})
+	kutil.Until(func() {
+		if err := c.RunOnce(); err != nil {
+			glog.Errorf("Error pruning images: %v", err)
+		}
+	}, c.Interval, stopCh)
+}
+
+// RunOnce prunes the images of the cluster once. Nothing is deleted if c is
+// paused.
+func (c *ContinuousPruner) RunOnce() (err error) {
+	start := time.Now()
+	counts := &pruneCounts{counts: make(map[string]int)}
+	defer func() {
+		now := time.Now()
+		pruneRuns.WithLabelValues(runResult(err, c.Paused)).Inc()
+		pruneLastRun.Set(float64(now.Unix()))
+		pruneRunDuration.Set(now.Sub(start).Seconds())
+		for _, kind := range []string{imageKind, imageStreamKind, layerKind, blobKind, manifestKind} {
+			pruneCandidates.WithLabelValues(kind).Set(float64(counts.get(kind)))
+		}
+		if c.Paused {
+			prunePaused.Set(1)
+			glog.Infof("Pruning paused, would have pruned %d images, %d layers, %d blobs and %d manifests", counts.get(imageKind), counts.get(layerKind), counts.get(blobKind), counts.get(manifestKind))
+		} else {
+			prunePaused.Set(0)
+		}
+	}()
+
+	pruner, err := c.NewPruner(c.Paused)
+	if err != nil {
+		return err
+	}
+	w := &countingPruners{counts: counts}
+	if !c.Paused {
+		w.images, w.streams, w.layers, w.blobs, w.manifests = c.ImagePruner, c.ImageStreamPruner, c.LayerPruner, c.BlobPruner, c.ManifestPruner
+	}
+	return pruner.Prune(w, w, w, w, w)
+}
+
+// runResult returns the value of the result label of a run.
+func runResult(err error, paused bool) string {
+	switch {
+	case err != nil:
+		return "error"
+	case paused:
+		return "paused"
+	default:
+		return "success"
+	}
+}
+
+// pruneCounts counts the candidates for pruning by kind. The layers, blobs and
+// manifests are pruned in parallel.
+type pruneCounts struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (c *pruneCounts) add(kind string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.counts[kind]++
+}
+
+func (c *pruneCounts) get(kind string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.counts[kind]
+}
+
+// countingPruners counts the candidates for pruning and the deletions. The
+// deletions are delegated to the pruners that aren't nil.
+type countingPruners struct {
+	counts *pruneCounts
+
+	images    ImagePruner
+	streams   ImageStreamPruner
+	layers    LayerPruner
+	blobs     BlobPruner
+	manifests ManifestPruner
+}
+
+// deleted counts a deletion of an object of kind which failed with err.
+func (p *countingPruners) deleted(kind string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	pruneDeletions.WithLabelValues(kind, result).Inc()
+}
+
+func (p *countingPruners) PruneImage(image *imageapi.Image) error {
+	p.counts.add(imageKind)
+	if p.images == nil {
+		return nil
+	}
+	err := p.images.PruneImage(image)
+	p.deleted(imageKind, err)
+	return err
+}
+
+func (p *countingPruners) PruneImageStream(stream *imageapi.ImageStream, image *imageapi.Image, updatedTags []string) (*imageapi.ImageStream, error) {
+	p.counts.add(imageStreamKind)
+	if p.streams == nil {
+		return stream, nil
+	}
+	updated, err := p.streams.PruneImageStream(stream, image, updatedTags)
+	p.deleted(imageStreamKind, err)
+	return updated, err
+}
+
+func (p *countingPruners) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	p.counts.add(layerKind)
+	if p.layers == nil {
+		return nil
+	}
+	err := p.layers.PruneLayer(registryClient, registryURL, repo, layer)
+	p.deleted(layerKind, err)
+	return err
+}
+
+func (p *countingPruners) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
+	p.counts.add(blobKind)
+	if p.blobs == nil {
+		return nil
+	}
+	err := p.blobs.PruneBlob(registryClient, registryURL, blob)
+	p.deleted(blobKind, err)
+	return err
+}
+
+func (p *countingPruners) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	p.counts.add(manifestKind)
+	if p.manifests == nil {
+		return nil
+	}
+	err := p.manifests.PruneManifest(registryClient, registryURL, repo, manifest)
+	p.deleted(manifestKind, err)
+	return err
+}
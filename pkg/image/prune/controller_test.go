@@ -0,0 +1,90 @@
+package prune
+
+import (
+	"net/http"
+	"testing"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// fakeImageRegistryPruner prunes one image with a layer, a blob and a
+// manifest, referenced by one image stream.
+type fakeImageRegistryPruner struct{}
+
+func (fakeImageRegistryPruner) Prune(imagePruner ImagePruner, streamPruner ImageStreamPruner, layerPruner LayerPruner, blobPruner BlobPruner, manifestPruner ManifestPruner) error {
+	image := &imageapi.Image{}
+	image.Name = "sha256:aaa"
+	if _, err := streamPruner.PruneImageStream(&imageapi.ImageStream{}, image, []string{"latest"}); err != nil {
+		return err
+	}
+	if err := layerPruner.PruneLayer(nil, "registry", "ns/app", "sha256:bbb"); err != nil {
+		return err
+	}
+	if err := blobPruner.PruneBlob(nil, "registry", "sha256:bbb"); err != nil {
+		return err
+	}
+	if err := manifestPruner.PruneManifest(nil, "registry", "ns/app", "sha256:aaa"); err != nil {
+		return err
+	}
+	return imagePruner.PruneImage(image)
+}
+
+type recordingPruner struct {
+	pruned []string
+}
+
+func (p *recordingPruner) PruneImage(image *imageapi.Image) error {
+	p.pruned = append(p.pruned, "image")
+	return nil
+}
+
+func (p *recordingPruner) PruneImageStream(stream *imageapi.ImageStream, image *imageapi.Image, updatedTags []string) (*imageapi.ImageStream, error) {
+	p.pruned = append(p.pruned, "imagestream")
+	return stream, nil
+}
+
+func (p *recordingPruner) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	p.pruned = append(p.pruned, "layer")
+	return nil
+}
+
+func (p *recordingPruner) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
+	p.pruned = append(p.pruned, "blob")
+	return nil
+}
+
+func (p *recordingPruner) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	p.pruned = append(p.pruned, "manifest")
+	return nil
+}
+
+func TestContinuousPrunerRunOnce(t *testing.T) {
+	for _, paused := range []bool{false, true} {
+		recorder := &recordingPruner{}
+		var pausedPruner bool
+		c := &ContinuousPruner{
+			NewPruner: func(paused bool) (ImageRegistryPruner, error) {
+				pausedPruner = paused
+				return fakeImageRegistryPruner{}, nil
+			},
+			Paused:            paused,
+			ImagePruner:       recorder,
+			ImageStreamPruner: recorder,
+			LayerPruner:       recorder,
+			BlobPruner:        recorder,
+			ManifestPruner:    recorder,
+		}
+		if err := c.RunOnce(); err != nil {
+			t.Fatalf("paused=%t: unexpected error: %v", paused, err)
+		}
+		if pausedPruner != paused {
+			t.Errorf("paused=%t: expected the pruner to be created with paused=%t", paused, paused)
+		}
+		switch {
+		case paused && len(recorder.pruned) > 0:
+			t.Errorf("paused=%t: expected nothing to be pruned, got %v", paused, recorder.pruned)
+		case !paused && len(recorder.pruned) != 5:
+			t.Errorf("paused=%t: expected everything to be pruned, got %v", paused, recorder.pruned)
+		}
+	}
+}
@@ -3,7 +3,10 @@ package prune
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/docker/distribution/registry/api/v2"
@@ -20,13 +23,22 @@ import (
 	imageapi "github.com/openshift/origin/pkg/image/api"
 	imagegraph "github.com/openshift/origin/pkg/image/graph/nodes"
 	"github.com/openshift/origin/pkg/image/registry/imagestreamimage"
+	oserrors "github.com/openshift/origin/pkg/util/errors"
 	kapi "k8s.io/kubernetes/pkg/api"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/unversioned"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/util"
 	kerrors "k8s.io/kubernetes/pkg/util/errors"
 	"k8s.io/kubernetes/pkg/util/sets"
 )
 
+// registryServiceName is the name of the service of the integrated registry in
+// the default namespace.
+const registryServiceName = "docker-registry"
+
 // TODO these edges should probably have an `Add***Edges` method in images/graph and be moved there
 const (
 	// ReferencedImageEdgeKind defines a "strong" edge where the tail is an
@@ -120,8 +132,73 @@ type ImageRegistryPrunerOptions struct {
 	DryRun bool
 	// RegistryClient is the http.Client to use when contacting the registry.
 	RegistryClient *http.Client
-	// RegistryURL is the URL for the registry.
+	// RegistryURL is the address of the integrated registry. ListObjects sets
+	// it to the address of the registry service if it's empty. The address
+	// isn't taken from the references of the images: the client pushing an
+	// image may influence it.
 	RegistryURL string
+	// Workers is the number of deletions of layers, blobs and manifests sent to
+	// the registry in parallel. Deletions are sent one at a time if it's not
+	// positive.
+	Workers int
+	// OrphanedBlobPruner, if set, has the registry delete the blobs which no
+	// image references once the images are pruned, e.g. the layers of failed
+	// pushes.
+	OrphanedBlobPruner OrphanedBlobPruner
+}
+
+// ListObjects sets the images, image streams, pods, replication controllers,
+// build configs, builds and deployment configs of o to all the ones of the
+// cluster.
+func (o *ImageRegistryPrunerOptions) ListObjects(osClient client.Interface, kClient kclient.Interface) error {
+	var err error
+	if o.Images, err = osClient.Images().List(labels.Everything(), fields.Everything()); err != nil {
+		return err
+	}
+	// the image streams being deleted keep their images until they are removed
+	if o.Streams, err = osClient.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), imageapi.ImageStreamsIncludingDeleted()); err != nil {
+		return err
+	}
+	if o.Pods, err = kClient.Pods(kapi.NamespaceAll).List(labels.Everything(), fields.Everything()); err != nil {
+		return err
+	}
+	if o.RCs, err = kClient.ReplicationControllers(kapi.NamespaceAll).List(labels.Everything(), fields.Everything()); err != nil {
+		return err
+	}
+	// We need to tolerate 'not found' errors for buildConfigs and builds since
+	// they may be disabled in Atomic
+	o.BCs, err = osClient.BuildConfigs(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
+	if err = oserrors.TolerateNotFoundError(err); err != nil {
+		return err
+	}
+	o.Builds, err = osClient.Builds(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
+	if err = oserrors.TolerateNotFoundError(err); err != nil {
+		return err
+	}
+	o.DCs, err = osClient.DeploymentConfigs(kapi.NamespaceAll).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return err
+	}
+	if len(o.RegistryURL) == 0 {
+		o.RegistryURL, err = registryServiceAddr(kClient)
+	}
+	return err
+}
+
+// registryServiceAddr returns the address of the service of the integrated
+// registry, or an empty address if there's none.
+func registryServiceAddr(kClient kclient.Interface) (string, error) {
+	service, err := kClient.Services(kapi.NamespaceDefault).Get(registryServiceName)
+	if err != nil {
+		if kapierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error getting the registry service: %v", err)
+	}
+	if len(service.Spec.ClusterIP) == 0 || service.Spec.ClusterIP == kapi.ClusterIPNone || len(service.Spec.Ports) == 0 {
+		return "", nil
+	}
+	return net.JoinHostPort(service.Spec.ClusterIP, strconv.Itoa(service.Spec.Ports[0].Port)), nil
 }
 
 // ImageRegistryPruner knows how to prune images and layers.
@@ -140,6 +217,9 @@ type imageRegistryPruner struct {
 	registryPinger registryPinger
 	registryClient *http.Client
 	registryURL    string
+	workers        int
+	dryRun         bool
+	orphanPruner   OrphanedBlobPruner
 }
 
 var _ ImageRegistryPruner = &imageRegistryPruner{}
@@ -228,6 +308,9 @@ ImageStreams having a reference to the image in `status.tags`.
 
 Also automatically remove any image layer that is no longer referenced by any
 images.
+
+If an OrphanedBlobPruner is set, finally have it remove the blobs which the
+registry stores but no image references.
 */
 func NewImageRegistryPruner(options ImageRegistryPrunerOptions) ImageRegistryPruner {
 	g := graph.New()
@@ -241,6 +324,7 @@ func NewImageRegistryPruner(options ImageRegistryPrunerOptions) ImageRegistryPru
 
 	addImagesToGraph(g, options.Images, algorithm)
 	addImageStreamsToGraph(g, options.Streams, algorithm)
+	addUntaggedImagesToGraph(g, options.Streams)
 	addPodsToGraph(g, options.Pods, algorithm)
 	addReplicationControllersToGraph(g, options.RCs)
 	addBuildConfigsToGraph(g, options.BCs)
@@ -260,13 +344,16 @@ func NewImageRegistryPruner(options ImageRegistryPrunerOptions) ImageRegistryPru
 		registryPinger: rp,
 		registryClient: options.RegistryClient,
 		registryURL:    options.RegistryURL,
+		workers:        options.Workers,
+		dryRun:         options.DryRun,
+		orphanPruner:   options.OrphanedBlobPruner,
 	}
 }
 
 // addImagesToGraph adds all images to the graph that belong to one of the
 // registries in the algorithm and are at least as old as the minimum age
-// threshold as specified by the algorithm. It also adds all the images' layers
-// to the graph.
+// threshold as specified by the algorithm, or are marked for pruning. It also
+// adds all the images' layers to the graph.
 func addImagesToGraph(g graph.Graph, images *imageapi.ImageList, algorithm pruneAlgorithm) {
 	for i := range images.Items {
 		image := &images.Items[i]
@@ -283,7 +370,7 @@ func addImagesToGraph(g graph.Graph, images *imageapi.ImageList, algorithm prune
 		}
 
 		age := unversioned.Now().Sub(image.CreationTimestamp.Time)
-		if age < algorithm.keepYoungerThan {
+		if age < algorithm.keepYoungerThan && image.Annotations[imageapi.ImageMarkedForPruningAnnotation] != "true" {
 			glog.V(4).Infof("Image %q is younger than minimum pruning age, skipping (age=%v)", image.Name, age)
 			continue
 		}
@@ -374,6 +461,49 @@ func addImageStreamsToGraph(g graph.Graph, streams *imageapi.ImageStreamList, al
 	}
 }
 
+// addUntaggedImagesToGraph adds strong references from the streams to the
+// images pushed by digest to their repositories which aren't in the history of
+// any tag yet, e.g. because they are tagged later through the API. Those
+// images are only referenced by their DockerImageReference, which names the
+// repository of their stream. The images marked for pruning were removed from
+// the history of the tags, they aren't kept.
+func addUntaggedImagesToGraph(g graph.Graph, streams *imageapi.ImageStreamList) {
+	tagged := sets.NewString()
+	streamNodes := make(map[string]*imagegraph.ImageStreamNode)
+	for i := range streams.Items {
+		stream := &streams.Items[i]
+		for _, history := range stream.Status.Tags {
+			for _, event := range history.Items {
+				tagged.Insert(event.Image)
+			}
+		}
+		if n := g.Find(imagegraph.ImageStreamNodeName(stream)); n != nil {
+			streamNodes[stream.Namespace+"/"+stream.Name] = n.(*imagegraph.ImageStreamNode)
+		}
+	}
+
+	for _, imageNode := range getImageNodes(g.Nodes()) {
+		if tagged.Has(imageNode.Image.Name) || imageNode.Image.Annotations[imageapi.ImageMarkedForPruningAnnotation] == "true" {
+			continue
+		}
+		ref, err := imageapi.ParseDockerImageReference(imageNode.Image.DockerImageReference)
+		if err != nil {
+			continue
+		}
+		streamNode, ok := streamNodes[ref.Namespace+"/"+ref.Name]
+		if !ok {
+			continue
+		}
+		glog.V(4).Infof("Adding edge (kind=%s) from stream %s/%s to untagged image %q", ReferencedImageEdgeKind, ref.Namespace, ref.Name, imageNode.Image.Name)
+		g.AddEdge(streamNode, imageNode, ReferencedImageEdgeKind)
+		for _, s := range g.From(imageNode) {
+			if g.Kind(s) == imagegraph.ImageLayerNodeKind {
+				g.AddEdge(streamNode, s, ReferencedImageLayerEdgeKind)
+			}
+		}
+	}
+}
+
 // addPodsToGraph adds pods to the graph.
 //
 // A pod is only *excluded* from being added to the graph if its phase is not
@@ -519,6 +649,14 @@ func addBuildStrategyImageReferencesToGraph(g graph.Graph, strategy buildapi.Bui
 			return
 		}
 		imageID = ref.ID
+	case "ImageStreamTag":
+		// only the tags pinned to an image reference a given image
+		_, tag, _ := imageapi.SplitImageStreamTag(from.Name)
+		_, dgst, ok := imageapi.SplitTagDigest(tag)
+		if !ok || len(dgst) == 0 {
+			return
+		}
+		imageID = dgst
 	default:
 		return
 	}
@@ -655,7 +793,7 @@ func pruneStreams(g graph.Graph, imageNodes []*imagegraph.ImageNode, streamPrune
 			for tag, history := range stream.Status.Tags {
 				glog.V(4).Infof("Checking tag %q", tag)
 
-				newHistory := imageapi.TagEventList{}
+				newHistory := imageapi.TagEventList{Conditions: history.Conditions}
 
 				for i, tagEvent := range history.Items {
 					glog.V(4).Infof("Checking tag event %d with image %q", i, tagEvent.Image)
@@ -697,39 +835,28 @@ func pruneImages(g graph.Graph, imageNodes []*imagegraph.ImageNode, imagePruner
 	return errs
 }
 
-func (p *imageRegistryPruner) determineRegistry(imageNodes []*imagegraph.ImageNode) (string, error) {
-	if len(p.registryURL) > 0 {
-		return p.registryURL, nil
-	}
-
-	// we only support a single internal registry, and all images have the same registry
-	// so we just take the 1st one and use it
-	pullSpec := imageNodes[0].Image.DockerImageReference
-
-	ref, err := imageapi.ParseDockerImageReference(pullSpec)
-	if err != nil {
-		return "", fmt.Errorf("unable to parse %q: %v", pullSpec, err)
-	}
-
-	if len(ref.Registry) == 0 {
-		return "", fmt.Errorf("%s does not include a registry", pullSpec)
+// determineRegistry returns the address of the registry the images are pruned
+// from.
+func (p *imageRegistryPruner) determineRegistry() (string, error) {
+	if len(p.registryURL) == 0 {
+		return "", fmt.Errorf("the address of the registry is unknown, it must be given with --registry-url")
 	}
-
-	return ref.Registry, nil
+	return p.registryURL, nil
 }
 
 // Run identifies images eligible for pruning, invoking imagePruneFunc for each
 // image, and then it identifies layers eligible for pruning, invoking
 // layerPruneFunc for each registry URL that has layers that can be pruned.
+// If an OrphanedBlobPruner is set, the orphaned blobs are pruned last.
 func (p *imageRegistryPruner) Prune(imagePruner ImagePruner, streamPruner ImageStreamPruner, layerPruner LayerPruner, blobPruner BlobPruner, manifestPruner ManifestPruner) error {
 	allNodes := p.g.Nodes()
 
 	imageNodes := getImageNodes(allNodes)
-	if len(imageNodes) == 0 {
+	if len(imageNodes) == 0 && (p.orphanPruner == nil || len(p.registryURL) == 0) {
 		return nil
 	}
 
-	registryURL, err := p.determineRegistry(imageNodes)
+	registryURL, err := p.determineRegistry()
 	if err != nil {
 		return fmt.Errorf("unable to determine registry: %v", err)
 	}
@@ -746,9 +873,9 @@ func (p *imageRegistryPruner) Prune(imagePruner ImagePruner, streamPruner ImageS
 	errs := []error{}
 
 	errs = append(errs, pruneStreams(p.g, prunableImageNodes, streamPruner)...)
-	errs = append(errs, pruneLayers(p.g, p.registryClient, registryURL, prunableLayers, layerPruner)...)
-	errs = append(errs, pruneBlobs(p.g, p.registryClient, registryURL, prunableLayers, blobPruner)...)
-	errs = append(errs, pruneManifests(p.g, p.registryClient, registryURL, prunableImageNodes, manifestPruner)...)
+	errs = append(errs, pruneLayers(p.g, p.registryClient, registryURL, prunableLayers, layerPruner, p.workers)...)
+	errs = append(errs, pruneBlobs(p.g, p.registryClient, registryURL, prunableLayers, blobPruner, p.workers)...)
+	errs = append(errs, pruneManifests(p.g, p.registryClient, registryURL, prunableImageNodes, manifestPruner, p.workers)...)
 
 	if len(errs) > 0 {
 		// If we had any errors removing image references from image streams or deleting
@@ -758,7 +885,18 @@ func (p *imageRegistryPruner) Prune(imagePruner ImagePruner, streamPruner ImageS
 	}
 
 	errs = append(errs, pruneImages(p.g, prunableImageNodes, imagePruner)...)
-	return kerrors.NewAggregate(errs)
+	if len(errs) > 0 || p.orphanPruner == nil {
+		return kerrors.NewAggregate(errs)
+	}
+
+	// the blobs of the images pruned above are deleted already, the orphaned
+	// ones were leaked by failed pushes or by earlier prunes
+	result, err := p.orphanPruner.PruneOrphanedBlobs(p.registryClient, registryURL, p.algorithm.keepYoungerThan, p.dryRun)
+	if err != nil {
+		return fmt.Errorf("error pruning orphaned blobs: %v", err)
+	}
+	glog.V(1).Infof("Pruned %d repository links and %d orphaned blobs (%d bytes) of %s", result.DeletedLinks, result.DeletedBlobs, result.DeletedBytes, registryURL)
+	return nil
 }
 
 // layerIsPrunable returns true if the layer is not referenced by any images.
@@ -790,12 +928,55 @@ func streamLayerReferences(g graph.Graph, layerNode *imagegraph.ImageLayerNode)
 	return ret
 }
 
-// pruneLayers invokes layerPruner.PruneLayer for each repository layer link to
-// be deleted from the registry.
-func pruneLayers(g graph.Graph, registryClient *http.Client, registryURL string, layerNodes []*imagegraph.ImageLayerNode, layerPruner LayerPruner) []error {
+// runInParallel invokes each of tasks, at most workers of them at a time, and
+// returns the errors they returned. The tasks are invoked in order, one at a
+// time, if workers is not greater than 1.
+func runInParallel(workers int, tasks []func() error) []error {
 	errs := []error{}
 
+	if workers <= 1 {
+		for _, task := range tasks {
+			if err := task(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errs
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	queue := make(chan func() error)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				if err := task(); err != nil {
+					lock.Lock()
+					errs = append(errs, err)
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+
+	return errs
+}
+
+// pruneLayers invokes layerPruner.PruneLayer for each repository layer link to
+// be deleted from the registry, using up to workers parallel invocations.
+func pruneLayers(g graph.Graph, registryClient *http.Client, registryURL string, layerNodes []*imagegraph.ImageLayerNode, layerPruner LayerPruner, workers int) []error {
+	tasks := []func() error{}
+
 	for _, layerNode := range layerNodes {
+		layer := layerNode.Layer
 		// get streams that reference layer
 		streamNodes := streamLayerReferences(g, layerNode)
 
@@ -803,37 +984,50 @@ func pruneLayers(g graph.Graph, registryClient *http.Client, registryURL string,
 			stream := streamNode.ImageStream
 			streamName := fmt.Sprintf("%s/%s", stream.Namespace, stream.Name)
 
-			glog.V(4).Infof("Pruning registry=%q, repo=%q, layer=%q", registryURL, streamName, layerNode.Layer)
-			if err := layerPruner.PruneLayer(registryClient, registryURL, streamName, layerNode.Layer); err != nil {
-				errs = append(errs, fmt.Errorf("error pruning repo %q layer link %q: %v", streamName, layerNode.Layer, err))
-			}
+			tasks = append(tasks, func() error {
+				glog.V(4).Infof("Pruning registry=%q, repo=%q, layer=%q", registryURL, streamName, layer)
+				if err := layerPruner.PruneLayer(registryClient, registryURL, streamName, layer); err != nil {
+					return fmt.Errorf("error pruning repo %q layer link %q: %v", streamName, layer, err)
+				}
+				return nil
+			})
 		}
 	}
 
-	return errs
+	return runInParallel(workers, tasks)
 }
 
 // pruneBlobs invokes blobPruner.PruneBlob for each blob to be deleted from the
-// registry.
-func pruneBlobs(g graph.Graph, registryClient *http.Client, registryURL string, layerNodes []*imagegraph.ImageLayerNode, blobPruner BlobPruner) []error {
-	errs := []error{}
+// registry, using up to workers parallel invocations.
+func pruneBlobs(g graph.Graph, registryClient *http.Client, registryURL string, layerNodes []*imagegraph.ImageLayerNode, blobPruner BlobPruner, workers int) []error {
+	tasks := []func() error{}
 
 	for _, layerNode := range layerNodes {
-		glog.V(4).Infof("Pruning registry=%q, blob=%q", registryURL, layerNode.Layer)
-		if err := blobPruner.PruneBlob(registryClient, registryURL, layerNode.Layer); err != nil {
-			errs = append(errs, fmt.Errorf("error pruning blob %q: %v", layerNode.Layer, err))
-		}
+		layer := layerNode.Layer
+		tasks = append(tasks, func() error {
+			glog.V(4).Infof("Pruning registry=%q, blob=%q", registryURL, layer)
+			if err := blobPruner.PruneBlob(registryClient, registryURL, layer); err != nil {
+				return fmt.Errorf("error pruning blob %q: %v", layer, err)
+			}
+			return nil
+		})
 	}
 
-	return errs
+	return runInParallel(workers, tasks)
 }
 
 // pruneManifests invokes manifestPruner.PruneManifest for each repository
-// manifest to be deleted from the registry.
-func pruneManifests(g graph.Graph, registryClient *http.Client, registryURL string, imageNodes []*imagegraph.ImageNode, manifestPruner ManifestPruner) []error {
-	errs := []error{}
+// manifest to be deleted from the registry. Besides the repositories of all
+// streams referencing the image, the manifest is also pruned from the
+// repository the image was pushed to, so that its revision and signatures are
+// removed even if that stream has already been deleted. Up to workers
+// invocations are run in parallel.
+func pruneManifests(g graph.Graph, registryClient *http.Client, registryURL string, imageNodes []*imagegraph.ImageNode, manifestPruner ManifestPruner, workers int) []error {
+	tasks := []func() error{}
 
 	for _, imageNode := range imageNodes {
+		repoNames := sets.NewString()
+
 		for _, n := range g.To(imageNode) {
 			streamNode, ok := n.(*imagegraph.ImageStreamNode)
 			if !ok {
@@ -841,16 +1035,27 @@ func pruneManifests(g graph.Graph, registryClient *http.Client, registryURL stri
 			}
 
 			stream := streamNode.ImageStream
-			repoName := fmt.Sprintf("%s/%s", stream.Namespace, stream.Name)
+			repoNames.Insert(fmt.Sprintf("%s/%s", stream.Namespace, stream.Name))
+		}
 
-			glog.V(4).Infof("Pruning manifest for registry %q, repo %q, image %q", registryURL, repoName, imageNode.Image.Name)
-			if err := manifestPruner.PruneManifest(registryClient, registryURL, repoName, imageNode.Image.Name); err != nil {
-				errs = append(errs, fmt.Errorf("error pruning manifest for registry %q, repo %q, image %q: %v", registryURL, repoName, imageNode.Image.Name, err))
-			}
+		if ref, err := imageapi.ParseDockerImageReference(imageNode.Image.DockerImageReference); err == nil && len(ref.Namespace) > 0 {
+			repoNames.Insert(fmt.Sprintf("%s/%s", ref.Namespace, ref.Name))
+		}
+
+		imageName := imageNode.Image.Name
+		for _, repoName := range repoNames.List() {
+			repoName := repoName
+			tasks = append(tasks, func() error {
+				glog.V(4).Infof("Pruning manifest for registry %q, repo %q, image %q", registryURL, repoName, imageName)
+				if err := manifestPruner.PruneManifest(registryClient, registryURL, repoName, imageName); err != nil {
+					return fmt.Errorf("error pruning manifest for registry %q, repo %q, image %q: %v", registryURL, repoName, imageName, err)
+				}
+				return nil
+			})
 		}
 	}
 
-	return errs
+	return runInParallel(workers, tasks)
 }
 
 // deletingImagePruner deletes an image from OpenShift.
@@ -892,10 +1097,18 @@ func (p *deletingImageStreamPruner) PruneImageStream(stream *imageapi.ImageStrea
 	return p.streams.ImageStreams(stream.Namespace).UpdateStatus(stream)
 }
 
+// insecureProtocols are the protocols the deletions are sent with by default:
+// https first, falling back to http.
+var insecureProtocols = []string{"https", "http"}
+
+// secureProtocols only send the deletions, and the credentials of the
+// registry client with them, over https.
+var secureProtocols = []string{"https"}
+
 // deleteFromRegistry uses registryClient to send a DELETE request to the
-// provided url. It attempts an https request first; if that fails, it fails
-// back to http.
-func deleteFromRegistry(registryClient *http.Client, url string) error {
+// provided url. It attempts the request with each of protocols in turn, until
+// the registry responds.
+func deleteFromRegistry(registryClient *http.Client, protocols []string, url string) error {
 	deleteFunc := func(proto, url string) error {
 		req, err := http.NewRequest("DELETE", url, nil)
 		if err != nil {
@@ -923,7 +1136,7 @@ func deleteFromRegistry(registryClient *http.Client, url string) error {
 	}
 
 	var err error
-	for _, proto := range []string{"https", "http"} {
+	for _, proto := range protocols {
 		glog.V(4).Infof("Trying %s for %s", proto, url)
 		err = deleteFunc(proto, fmt.Sprintf("%s://%s", proto, url))
 		if err == nil {
@@ -943,48 +1156,69 @@ func deleteFromRegistry(registryClient *http.Client, url string) error {
 
 // deletingLayerPruner deletes a repository layer link from the registry.
 type deletingLayerPruner struct {
+	protocols []string
 }
 
 var _ LayerPruner = &deletingLayerPruner{}
 
 // NewDeletingLayerPruner creates a new deletingLayerPruner.
 func NewDeletingLayerPruner() LayerPruner {
-	return &deletingLayerPruner{}
+	return &deletingLayerPruner{protocols: insecureProtocols}
+}
+
+// NewSecureDeletingLayerPruner creates a new deletingLayerPruner that never
+// falls back to http.
+func NewSecureDeletingLayerPruner() LayerPruner {
+	return &deletingLayerPruner{protocols: secureProtocols}
 }
 
 func (p *deletingLayerPruner) PruneLayer(registryClient *http.Client, registryURL, repoName, layer string) error {
 	glog.V(4).Infof("Pruning registry %q, repo %q, layer %q", registryURL, repoName, layer)
-	return deleteFromRegistry(registryClient, fmt.Sprintf("%s/admin/%s/layers/%s", registryURL, repoName, layer))
+	return deleteFromRegistry(registryClient, p.protocols, fmt.Sprintf("%s/admin/%s/layers/%s", registryURL, repoName, layer))
 }
 
 // deletingBlobPruner deletes a blob from the registry.
 type deletingBlobPruner struct {
+	protocols []string
 }
 
 var _ BlobPruner = &deletingBlobPruner{}
 
 // NewDeletingLayerPruner creates a new deletingBlobPruner.
 func NewDeletingBlobPruner() BlobPruner {
-	return &deletingBlobPruner{}
+	return &deletingBlobPruner{protocols: insecureProtocols}
+}
+
+// NewSecureDeletingBlobPruner creates a new deletingBlobPruner that never
+// falls back to http.
+func NewSecureDeletingBlobPruner() BlobPruner {
+	return &deletingBlobPruner{protocols: secureProtocols}
 }
 
 func (p *deletingBlobPruner) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
 	glog.V(4).Infof("Pruning registry %q, blob %q", registryURL, blob)
-	return deleteFromRegistry(registryClient, fmt.Sprintf("%s/admin/blobs/%s", registryURL, blob))
+	return deleteFromRegistry(registryClient, p.protocols, fmt.Sprintf("%s/admin/blobs/%s", registryURL, blob))
 }
 
 // deletingManifestPruner deletes repository manifest data from the registry.
 type deletingManifestPruner struct {
+	protocols []string
 }
 
 var _ ManifestPruner = &deletingManifestPruner{}
 
 // NewDeletingManifestPruner creates a new deletingManifestPruner.
 func NewDeletingManifestPruner() ManifestPruner {
-	return &deletingManifestPruner{}
+	return &deletingManifestPruner{protocols: insecureProtocols}
+}
+
+// NewSecureDeletingManifestPruner creates a new deletingManifestPruner that
+// never falls back to http.
+func NewSecureDeletingManifestPruner() ManifestPruner {
+	return &deletingManifestPruner{protocols: secureProtocols}
 }
 
 func (p *deletingManifestPruner) PruneManifest(registryClient *http.Client, registryURL, repoName, manifest string) error {
 	glog.V(4).Infof("Pruning manifest for registry %q, repo %q, manifest %q", registryURL, repoName, manifest)
-	return deleteFromRegistry(registryClient, fmt.Sprintf("%s/admin/%s/manifests/%s", registryURL, repoName, manifest))
+	return deleteFromRegistry(registryClient, p.protocols, fmt.Sprintf("%s/admin/%s/manifests/%s", registryURL, repoName, manifest))
 }
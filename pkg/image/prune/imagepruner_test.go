@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	kapi "k8s.io/kubernetes/pkg/api"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	ktc "k8s.io/kubernetes/pkg/client/unversioned/testclient"
 	"k8s.io/kubernetes/pkg/runtime"
@@ -54,6 +56,11 @@ func agedImage(id, ref string, ageInMinutes int64) imageapi.Image {
 	return image
 }
 
+func markedImage(image imageapi.Image) imageapi.Image {
+	image.Annotations[imageapi.ImageMarkedForPruningAnnotation] = "true"
+	return image
+}
+
 func image(id, ref string) imageapi.Image {
 	return agedImage(id, ref, -1)
 }
@@ -344,6 +351,7 @@ func (p *fakeImageStreamPruner) PruneImageStream(stream *imageapi.ImageStream, i
 }
 
 type fakeBlobPruner struct {
+	lock        sync.Mutex
 	invocations sets.String
 	err         error
 }
@@ -351,11 +359,14 @@ type fakeBlobPruner struct {
 var _ BlobPruner = &fakeBlobPruner{}
 
 func (p *fakeBlobPruner) PruneBlob(registryClient *http.Client, registryURL, blob string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.invocations.Insert(fmt.Sprintf("%s|%s", registryURL, blob))
 	return p.err
 }
 
 type fakeLayerPruner struct {
+	lock        sync.Mutex
 	invocations sets.String
 	err         error
 }
@@ -363,11 +374,14 @@ type fakeLayerPruner struct {
 var _ LayerPruner = &fakeLayerPruner{}
 
 func (p *fakeLayerPruner) PruneLayer(registryClient *http.Client, registryURL, repo, layer string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.invocations.Insert(fmt.Sprintf("%s|%s|%s", registryURL, repo, layer))
 	return p.err
 }
 
 type fakeManifestPruner struct {
+	lock        sync.Mutex
 	invocations sets.String
 	err         error
 }
@@ -375,6 +389,8 @@ type fakeManifestPruner struct {
 var _ ManifestPruner = &fakeManifestPruner{}
 
 func (p *fakeManifestPruner) PruneManifest(registryClient *http.Client, registryURL, repo, manifest string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.invocations.Insert(fmt.Sprintf("%s|%s|%s", registryURL, repo, manifest))
 	return p.err
 }
@@ -441,6 +457,36 @@ func TestImagePruning(t *testing.T) {
 			pods:              podList(pod("foo", "pod1", kapi.PodSucceeded, registryURL+"/foo/bar@id")),
 			expectedDeletions: []string{},
 		},
+		"image less than min pruning age marked for pruning - prune": {
+			images:            imageList(markedImage(agedImage("id", registryURL+"/foo/bar@id", 5))),
+			expectedDeletions: []string{"id"},
+		},
+		"image less than min pruning age marked for pruning, referenced by a pod - don't prune": {
+			images:            imageList(markedImage(agedImage("id", registryURL+"/foo/bar@id", 5))),
+			pods:              podList(pod("foo", "pod1", kapi.PodRunning, registryURL+"/foo/bar@id")),
+			expectedDeletions: []string{},
+		},
+		"untagged image pushed to a stream - don't prune": {
+			images: imageList(image("id", registryURL+"/foo/bar@id"), image("id2", registryURL+"/foo/bar@id2")),
+			streams: streamList(
+				stream(registryURL, "foo", "bar", tags(
+					tag("latest",
+						tagEvent("id2", registryURL+"/foo/bar@id2"),
+					),
+				)),
+			),
+			expectedDeletions: []string{},
+		},
+		"image removed from the history of a stream - prune": {
+			images:            imageList(markedImage(image("id", registryURL+"/foo/bar@id"))),
+			streams:           streamList(stream(registryURL, "foo", "bar", tags())),
+			expectedDeletions: []string{"id"},
+		},
+		"untagged image of a deleted stream - prune": {
+			images:            imageList(image("id", registryURL+"/foo/bar@id")),
+			streams:           streamList(stream(registryURL, "foo", "other", tags())),
+			expectedDeletions: []string{"id"},
+		},
 		"pod phase failed - prune": {
 			images: imageList(image("id", registryURL+"/foo/bar@id")),
 			pods: podList(
@@ -520,6 +566,16 @@ func TestImagePruning(t *testing.T) {
 			bcs:               bcList(bc("foo", "bc1", "custom", "DockerImage", "foo", registryURL+"/foo/bar@id")),
 			expectedDeletions: []string{},
 		},
+		"referenced by bc - sti - pinned ImageStreamTag - don't prune": {
+			images:            imageList(image("sha256:aaa", registryURL+"/foo/bar@sha256:aaa")),
+			bcs:               bcList(bc("foo", "bc1", "source", "ImageStreamTag", "foo", "bar:latest@sha256:aaa")),
+			expectedDeletions: []string{},
+		},
+		"referenced by bc - sti - ImageStreamTag - prune": {
+			images:            imageList(image("sha256:aaa", registryURL+"/foo/bar@sha256:aaa")),
+			bcs:               bcList(bc("foo", "bc1", "source", "ImageStreamTag", "foo", "bar:latest")),
+			expectedDeletions: []string{"sha256:aaa"},
+		},
 		"referenced by build - sti - ImageStreamImage - don't prune": {
 			images:            imageList(image("id", registryURL+"/foo/bar@id")),
 			builds:            buildList(build("foo", "build1", "source", "ImageStreamImage", "foo", "bar@id")),
@@ -678,6 +734,7 @@ func TestImagePruning(t *testing.T) {
 			BCs:              &test.bcs,
 			Builds:           &test.builds,
 			DCs:              &test.dcs,
+			RegistryURL:      registryURL,
 		}
 		p := NewImageRegistryPruner(options)
 		p.(*imageRegistryPruner).registryPinger = &fakeRegistryPinger{}
@@ -749,6 +806,7 @@ func TestRegistryPruning(t *testing.T) {
 		expectedBlobDeletions     sets.String
 		expectedManifestDeletions sets.String
 		pingErr                   error
+		workers                   int
 	}{
 		"layers unique to id1 pruned": {
 			images: imageList(
@@ -780,6 +838,32 @@ func TestRegistryPruning(t *testing.T) {
 				"registry1|foo/bar|id1",
 			),
 		},
+		"layers unique to id1 pruned in parallel": {
+			images: imageList(
+				imageWithLayers("id1", "registry1/foo/bar@id1", "layer1", "layer2", "layer3", "layer4"),
+				imageWithLayers("id2", "registry1/foo/bar@id2", "layer3", "layer4", "layer5", "layer6"),
+			),
+			streams: streamList(
+				stream("registry1", "foo", "bar", tags(
+					tag("latest",
+						tagEvent("id2", "registry1/foo/bar@id2"),
+						tagEvent("id1", "registry1/foo/bar@id1"),
+					),
+				)),
+			),
+			expectedLayerDeletions: sets.NewString(
+				"registry1|foo/bar|layer1",
+				"registry1|foo/bar|layer2",
+			),
+			expectedBlobDeletions: sets.NewString(
+				"registry1|layer1",
+				"registry1|layer2",
+			),
+			expectedManifestDeletions: sets.NewString(
+				"registry1|foo/bar|id1",
+			),
+			workers: 3,
+		},
 		"no pruning when no images are pruned": {
 			images: imageList(
 				imageWithLayers("id1", "registry1/foo/bar@id1", "layer1", "layer2", "layer3", "layer4"),
@@ -809,7 +893,10 @@ func TestRegistryPruning(t *testing.T) {
 				"registry1|layer5",
 				"registry1|layer6",
 			),
-			expectedManifestDeletions: sets.NewString(),
+			expectedManifestDeletions: sets.NewString(
+				"registry1|foo/bar|id1",
+				"registry1|foo/bar|id2",
+			),
 		},
 		"ping error": {
 			images: imageList(
@@ -854,6 +941,8 @@ func TestRegistryPruning(t *testing.T) {
 			BCs:              &buildapi.BuildConfigList{},
 			Builds:           &buildapi.BuildList{},
 			DCs:              &deployapi.DeploymentConfigList{},
+			Workers:          test.workers,
+			RegistryURL:      "registry1",
 		}
 		p := NewImageRegistryPruner(options)
 		p.(*imageRegistryPruner).registryPinger = &fakeRegistryPinger{err: test.pingErr}
@@ -877,3 +966,28 @@ func TestRegistryPruning(t *testing.T) {
 		}
 	}
 }
+
+func TestRegistryServiceAddr(t *testing.T) {
+	kClient := ktc.NewSimpleFake(&kapi.Service{
+		ObjectMeta: kapi.ObjectMeta{Namespace: kapi.NamespaceDefault, Name: registryServiceName},
+		Spec: kapi.ServiceSpec{
+			ClusterIP: "172.30.1.1",
+			Ports:     []kapi.ServicePort{{Port: 5000}},
+		},
+	})
+	addr, err := registryServiceAddr(kClient)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr != "172.30.1.1:5000" {
+		t.Errorf("expected the address of the registry service, got %q", addr)
+	}
+
+	kClient = ktc.NewSimpleFake()
+	kClient.PrependReactor("get", "services", func(action ktc.Action) (bool, runtime.Object, error) {
+		return true, nil, kapierrors.NewNotFound("service", registryServiceName)
+	})
+	if addr, err := registryServiceAddr(kClient); err != nil || len(addr) > 0 {
+		t.Errorf("expected no address without a registry service, got %q, %v", addr, err)
+	}
+}
@@ -0,0 +1,149 @@
+package prune
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/golang/glog"
+)
+
+// defaultPruneJobPollInterval is the interval at which the status of the prune
+// job of the registry is checked.
+const defaultPruneJobPollInterval = 5 * time.Second
+
+// OrphanedBlobPruner knows how to delete the blobs stored by the registry which
+// aren't referenced by any image, e.g. the layers of failed pushes.
+type OrphanedBlobPruner interface {
+	// PruneOrphanedBlobs uses registryClient to have the registry at
+	// registryURL delete the orphaned blobs written at least minAge ago, with
+	// the links of its repositories to them, and waits for it to be done.
+	// Nothing is deleted if dryRun is true, the result counts what would be.
+	PruneOrphanedBlobs(registryClient *http.Client, registryURL string, minAge time.Duration, dryRun bool) (*OrphanedBlobsResult, error)
+}
+
+// OrphanedBlobsResult counts what the pruning of the orphaned blobs deleted.
+type OrphanedBlobsResult struct {
+	DeletedLinks int   `json:"deletedLinks"`
+	DeletedBlobs int   `json:"deletedBlobs"`
+	DeletedBytes int64 `json:"deletedBytes"`
+}
+
+// registryOrphanedBlobPruner prunes the orphaned blobs with a prune job of the
+// registry. The registry deletes the blobs together with their links while it
+// holds the writes to the repositories, which a DELETE request per blob
+// couldn't do.
+type registryOrphanedBlobPruner struct {
+	protocols    []string
+	pollInterval time.Duration
+}
+
+var _ OrphanedBlobPruner = &registryOrphanedBlobPruner{}
+
+// NewRegistryOrphanedBlobPruner creates a new OrphanedBlobPruner sending
+// POST /admin/prune to the registry and waiting for the job it starts.
+func NewRegistryOrphanedBlobPruner() OrphanedBlobPruner {
+	return &registryOrphanedBlobPruner{protocols: insecureProtocols, pollInterval: defaultPruneJobPollInterval}
+}
+
+// pruneJob is the status of the prune job of the registry.
+type pruneJob struct {
+	OrphanedBlobsResult
+	ID    string `json:"id"`
+	State string `json:"state"`
+	Error string `json:"error"`
+}
+
+// the states of the prune job of the registry
+const (
+	pruneJobRunning   = "Running"
+	pruneJobSucceeded = "Succeeded"
+)
+
+func (p *registryOrphanedBlobPruner) PruneOrphanedBlobs(registryClient *http.Client, registryURL string, minAge time.Duration, dryRun bool) (*OrphanedBlobsResult, error) {
+	query := url.Values{}
+	query.Set("minAge", minAge.String())
+	if dryRun {
+		query.Set("dryRun", "true")
+	}
+
+	var (
+		proto, location string
+		err             error
+	)
+	for _, proto = range p.protocols {
+		glog.V(4).Infof("Trying %s to prune the orphaned blobs of %s", proto, registryURL)
+		location, err = p.startJob(registryClient, fmt.Sprintf("%s://%s/admin/prune?%s", proto, registryURL, query.Encode()))
+		if err == nil {
+			break
+		}
+		if _, ok := err.(*v2.Errors); ok {
+			// we got a response back from the registry, so return it
+			return nil, err
+		}
+		glog.V(4).Infof("Error with %s for %s: %v", proto, registryURL, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jobURL := fmt.Sprintf("%s://%s%s", proto, registryURL, location)
+	for {
+		job, err := p.getJob(registryClient, jobURL)
+		if err != nil {
+			return nil, err
+		}
+		switch job.State {
+		case pruneJobRunning:
+			glog.V(4).Infof("Waiting for prune job %s of %s", job.ID, registryURL)
+			time.Sleep(p.pollInterval)
+		case pruneJobSucceeded:
+			return &job.OrphanedBlobsResult, nil
+		default:
+			return nil, fmt.Errorf("prune job %s of %s failed: %s", job.ID, registryURL, job.Error)
+		}
+	}
+}
+
+// startJob starts the prune job with a POST to jobURL and returns the path of
+// its status.
+func (p *registryOrphanedBlobPruner) startJob(registryClient *http.Client, jobURL string) (string, error) {
+	resp, err := registryClient.Post(jobURL, "application/json", nil)
+	if err != nil {
+		return "", fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		glog.V(1).Infof("Unexpected status code in response: %d", resp.StatusCode)
+		var response v2.Errors
+		json.NewDecoder(resp.Body).Decode(&response)
+		return "", &response
+	}
+	location := resp.Header.Get("Location")
+	if len(location) == 0 {
+		return "", fmt.Errorf("the registry didn't answer the location of the prune job")
+	}
+	return location, nil
+}
+
+// getJob returns the status of the prune job at jobURL.
+func (p *registryOrphanedBlobPruner) getJob(registryClient *http.Client, jobURL string) (*pruneJob, error) {
+	resp, err := registryClient.Get(jobURL)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the prune job: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d for the prune job", resp.StatusCode)
+	}
+	job := &pruneJob{}
+	if err := json.NewDecoder(resp.Body).Decode(job); err != nil {
+		return nil, fmt.Errorf("error reading the prune job: %v", err)
+	}
+	return job, nil
+}
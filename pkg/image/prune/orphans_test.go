@@ -0,0 +1,159 @@
+package prune
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	buildapi "github.com/openshift/origin/pkg/build/api"
+	deployapi "github.com/openshift/origin/pkg/deploy/api"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+type fakeOrphanedBlobPruner struct {
+	minAge time.Duration
+	dryRun bool
+	calls  int
+}
+
+func (p *fakeOrphanedBlobPruner) PruneOrphanedBlobs(registryClient *http.Client, registryURL string, minAge time.Duration, dryRun bool) (*OrphanedBlobsResult, error) {
+	p.minAge, p.dryRun = minAge, dryRun
+	p.calls++
+	return &OrphanedBlobsResult{DeletedLinks: 1, DeletedBlobs: 2}, nil
+}
+
+func TestRegistryOrphanedBlobPruner(t *testing.T) {
+	var query string
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == "POST" && req.URL.Path == "/admin/prune":
+			query = req.URL.RawQuery
+			w.Header().Set("Location", "/admin/prune/1234")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"id":"1234","state":"Running"}`)
+		case req.Method == "GET" && req.URL.Path == "/admin/prune/1234":
+			polls++
+			if polls < 2 {
+				fmt.Fprintln(w, `{"id":"1234","state":"Running"}`)
+				return
+			}
+			fmt.Fprintln(w, `{"id":"1234","state":"Succeeded","deletedLinks":3,"deletedBlobs":2,"deletedBytes":30}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registryURL := strings.TrimPrefix(server.URL, "http://")
+	pruner := &registryOrphanedBlobPruner{protocols: insecureProtocols, pollInterval: time.Millisecond}
+	result, err := pruner.PruneOrphanedBlobs(http.DefaultClient, registryURL, 30*time.Minute, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e := (OrphanedBlobsResult{DeletedLinks: 3, DeletedBlobs: 2, DeletedBytes: 30}); !reflect.DeepEqual(e, *result) {
+		t.Errorf("expected result %#v, got %#v", e, *result)
+	}
+	if e := "minAge=30m0s"; query != e {
+		t.Errorf("expected query %q, got %q", e, query)
+	}
+	if polls != 2 {
+		t.Errorf("expected the job to be polled until it's done, got %d polls", polls)
+	}
+
+	if _, err := pruner.PruneOrphanedBlobs(http.DefaultClient, registryURL, time.Hour, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e := "dryRun=true&minAge=1h0m0s"; query != e {
+		t.Errorf("expected query %q, got %q", e, query)
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			w.Header().Set("Location", "/admin/prune/1234")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		fmt.Fprintln(w, `{"id":"1234","state":"Failed","error":"storage failure"}`)
+	})
+	if _, err := pruner.PruneOrphanedBlobs(http.DefaultClient, registryURL, time.Hour, false); err == nil || !strings.Contains(err.Error(), "storage failure") {
+		t.Errorf("expected the error of the job, got %v", err)
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintln(w, `{"errors":[{"code":"UNKNOWN","message":"the storage is being pruned already"}]}`)
+	})
+	if _, err := pruner.PruneOrphanedBlobs(http.DefaultClient, registryURL, time.Hour, false); err == nil || !strings.Contains(err.Error(), "pruned already") {
+		t.Errorf("expected the error of the registry, got %v", err)
+	}
+}
+
+func TestOrphanedBlobPruning(t *testing.T) {
+	images := imageList(
+		imageWithLayers("id1", "registry1/foo/bar@id1", "layer1", "layer2"),
+		imageWithLayers("id2", "registry1/foo/bar@id2", "layer2", "layer3"),
+	)
+	streams := streamList(
+		stream("registry1", "foo", "bar", tags(
+			tag("latest",
+				tagEvent("id2", "registry1/foo/bar@id2"),
+				tagEvent("id1", "registry1/foo/bar@id1"),
+			),
+		)),
+	)
+	orphanPruner := &fakeOrphanedBlobPruner{}
+
+	options := ImageRegistryPrunerOptions{
+		KeepYoungerThan:    60 * time.Minute,
+		KeepTagRevisions:   1,
+		Images:             &images,
+		Streams:            &streams,
+		Pods:               &kapi.PodList{},
+		RCs:                &kapi.ReplicationControllerList{},
+		BCs:                &buildapi.BuildConfigList{},
+		Builds:             &buildapi.BuildList{},
+		DCs:                &deployapi.DeploymentConfigList{},
+		RegistryURL:        "registry1",
+		OrphanedBlobPruner: orphanPruner,
+	}
+	p := NewImageRegistryPruner(options)
+	p.(*imageRegistryPruner).registryPinger = &fakeRegistryPinger{}
+
+	blobPruner := &fakeBlobPruner{invocations: sets.NewString()}
+	err := p.Prune(&fakeImagePruner{invocations: sets.NewString()}, &fakeImageStreamPruner{invocations: sets.NewString()}, &fakeLayerPruner{invocations: sets.NewString()}, blobPruner, &fakeManifestPruner{invocations: sets.NewString()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := sets.NewString("registry1|layer1")
+	if !reflect.DeepEqual(expected, blobPruner.invocations) {
+		t.Errorf("expected blob deletions %v, got %v", expected.List(), blobPruner.invocations.List())
+	}
+	if orphanPruner.calls != 1 || orphanPruner.minAge != 60*time.Minute || orphanPruner.dryRun {
+		t.Errorf("expected the orphaned blobs to be pruned once with the minimum age of the images, got %#v", orphanPruner)
+	}
+
+	// the orphaned blobs are pruned even if there are no images
+	orphanPruner = &fakeOrphanedBlobPruner{}
+	options.Images = &imageapi.ImageList{}
+	options.Streams = &imageapi.ImageStreamList{}
+	options.DryRun = true
+	options.OrphanedBlobPruner = orphanPruner
+	p = NewImageRegistryPruner(options)
+
+	err = p.Prune(&fakeImagePruner{invocations: sets.NewString()}, &fakeImageStreamPruner{invocations: sets.NewString()}, &fakeLayerPruner{invocations: sets.NewString()}, &fakeBlobPruner{invocations: sets.NewString()}, &fakeManifestPruner{invocations: sets.NewString()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if orphanPruner.calls != 1 || !orphanPruner.dryRun {
+		t.Errorf("expected the orphaned blobs to be counted in a dry run, got %#v", orphanPruner)
+	}
+}
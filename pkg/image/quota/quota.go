@@ -0,0 +1,137 @@
+package quota
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// maxReserveRetries is the number of times the reservation of usage in a
+// ResourceQuota is attempted when concurrent reservations conflict with it.
+const maxReserveRetries = 10
+
+// ExceededError is returned when reserving usage would exceed a quota.
+type ExceededError struct {
+	Quota    string
+	Resource kapi.ResourceName
+	Hard     int64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("limited to %d %s by quota %q", e.Hard, e.Resource, e.Quota)
+}
+
+// IsExceeded returns true if err is an ExceededError.
+func IsExceeded(err error) bool {
+	_, ok := err.(*ExceededError)
+	return ok
+}
+
+// Reserve adds count to the usage of the resource name in the status of every
+// ResourceQuota of namespace limiting it, or returns an ExceededError if that
+// would exceed one of them, as the kubernetes ResourceQuota admission plugin
+// does for the resources it tracks. Concurrent reservations conflict on the
+// version of the quotas, so that they can't exceed them together. What is
+// reserved for objects that aren't created, or that are deleted later, is
+// released when the ImageQuotaController recounts the usage.
+func Reserve(quotas kclient.ResourceQuotasNamespacer, namespace string, name kapi.ResourceName, count int64) error {
+	list, err := quotas.ResourceQuotas(namespace).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if _, ok := list.Items[i].Spec.Hard[name]; !ok {
+			continue
+		}
+		if err := reserve(quotas, &list.Items[i], name, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserve adds count to the usage of the resource name in the status of quota.
+func reserve(quotas kclient.ResourceQuotasNamespacer, quota *kapi.ResourceQuota, name kapi.ResourceName, count int64) error {
+	interval := time.Duration(rand.Int63n(90)+10) * time.Millisecond
+	for retry := 1; ; retry++ {
+		hard := quota.Spec.Hard[name]
+		used, ok := quota.Status.Used[name]
+		if !ok {
+			return fmt.Errorf("the usage of %s in quota %q isn't known yet, try again later", name, quota.Name)
+		}
+		if used.Value()+count > hard.Value() {
+			return &ExceededError{Quota: quota.Name, Resource: name, Hard: hard.Value()}
+		}
+
+		usage := kapi.ResourceQuota{
+			ObjectMeta: kapi.ObjectMeta{
+				Name:            quota.Name,
+				Namespace:       quota.Namespace,
+				ResourceVersion: quota.ResourceVersion,
+				Labels:          quota.Labels,
+				Annotations:     quota.Annotations,
+			},
+			Status: copyStatus(quota.Status),
+		}
+		usage.Status.Used[name] = *resource.NewQuantity(used.Value()+count, resource.DecimalSI)
+		_, err := quotas.ResourceQuotas(quota.Namespace).UpdateStatus(&usage)
+		if err == nil {
+			return nil
+		}
+		if !kerrors.IsConflict(err) || retry == maxReserveRetries {
+			return err
+		}
+		time.Sleep(interval)
+		if quota, err = quotas.ResourceQuotas(quota.Namespace).Get(quota.Name); err != nil {
+			return err
+		}
+	}
+}
+
+// copyStatus returns a copy of status, which can't be modified in place.
+func copyStatus(status kapi.ResourceQuotaStatus) kapi.ResourceQuotaStatus {
+	out := kapi.ResourceQuotaStatus{Hard: kapi.ResourceList{}, Used: kapi.ResourceList{}}
+	for k, v := range status.Hard {
+		out.Hard[k] = *v.Copy()
+	}
+	for k, v := range status.Used {
+		out.Used[k] = *v.Copy()
+	}
+	return out
+}
+
+// Usage returns the usage of the image resources by streams, the image streams
+// of a project: their number, and the number of unique images their tag
+// histories reference.
+func Usage(streams []imageapi.ImageStream) kapi.ResourceList {
+	return kapi.ResourceList{
+		imageapi.ResourceImageStreams: *resource.NewQuantity(int64(len(streams)), resource.DecimalSI),
+		imageapi.ResourceImages:       *resource.NewQuantity(int64(ReferencedImages(streams).Len()), resource.DecimalSI),
+	}
+}
+
+// ReferencedImages returns the names of the images referenced by the tag
+// histories of streams.
+func ReferencedImages(streams []imageapi.ImageStream) sets.String {
+	images := sets.NewString()
+	for _, stream := range streams {
+		for _, history := range stream.Status.Tags {
+			for _, event := range history.Items {
+				if len(event.Image) > 0 {
+					images.Insert(event.Image)
+				}
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,129 @@
+package quota
+
+import (
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func quantity(value int64) resource.Quantity {
+	return *resource.NewQuantity(value, resource.DecimalSI)
+}
+
+func TestReserve(t *testing.T) {
+	tests := map[string]struct {
+		hard      kapi.ResourceList
+		used      kapi.ResourceList
+		conflicts int
+		expected  int64
+		exceeded  bool
+		fails     bool
+	}{
+		"not limited": {
+			hard:     kapi.ResourceList{kapi.ResourcePods: quantity(1)},
+			used:     kapi.ResourceList{kapi.ResourcePods: quantity(1)},
+			expected: -1,
+		},
+		"below quota": {
+			hard:     kapi.ResourceList{imageapi.ResourceImages: quantity(2)},
+			used:     kapi.ResourceList{imageapi.ResourceImages: quantity(1)},
+			expected: 2,
+		},
+		"at quota": {
+			hard:     kapi.ResourceList{imageapi.ResourceImages: quantity(2)},
+			used:     kapi.ResourceList{imageapi.ResourceImages: quantity(2)},
+			expected: -1,
+			exceeded: true,
+		},
+		"usage unknown": {
+			hard:     kapi.ResourceList{imageapi.ResourceImages: quantity(2)},
+			expected: -1,
+			fails:    true,
+		},
+		"conflict": {
+			hard:      kapi.ResourceList{imageapi.ResourceImages: quantity(2)},
+			used:      kapi.ResourceList{imageapi.ResourceImages: quantity(0)},
+			conflicts: 2,
+			expected:  1,
+		},
+		"conflicts exhausted": {
+			hard:      kapi.ResourceList{imageapi.ResourceImages: quantity(2)},
+			used:      kapi.ResourceList{imageapi.ResourceImages: quantity(0)},
+			conflicts: maxReserveRetries,
+			expected:  -1,
+			fails:     true,
+		},
+	}
+	for name, test := range tests {
+		quota := &kapi.ResourceQuota{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "quota", ResourceVersion: "1"},
+			Spec:       kapi.ResourceQuotaSpec{Hard: test.hard},
+			Status:     kapi.ResourceQuotaStatus{Hard: test.hard, Used: test.used},
+		}
+		client := ktestclient.NewSimpleFake(&kapi.ResourceQuotaList{Items: []kapi.ResourceQuota{*quota}})
+		client.PrependReactor("get", "resourcequotas", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			return true, quota, nil
+		})
+		conflicts := test.conflicts
+		reserved := int64(-1)
+		client.PrependReactor("update", "resourcequotas", func(action ktestclient.Action) (bool, runtime.Object, error) {
+			if conflicts > 0 {
+				conflicts--
+				return true, nil, kerrors.NewConflict("resourceQuota", "quota", nil)
+			}
+			updated := action.(ktestclient.UpdateAction).GetObject().(*kapi.ResourceQuota)
+			if updated.ResourceVersion != "1" {
+				t.Errorf("%s: expected the version of the quota to be preconditioned, got %q", name, updated.ResourceVersion)
+			}
+			used := updated.Status.Used[imageapi.ResourceImages]
+			reserved = used.Value()
+			return true, updated, nil
+		})
+
+		err := Reserve(client, "ns", imageapi.ResourceImages, 1)
+		switch {
+		case test.exceeded:
+			if !IsExceeded(err) {
+				t.Errorf("%s: expected the quota to be exceeded, got %v", name, err)
+			}
+		case test.fails:
+			if err == nil || IsExceeded(err) {
+				t.Errorf("%s: expected an error, got %v", name, err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if reserved != test.expected {
+			t.Errorf("%s: expected %d images to be used, got %d", name, test.expected, reserved)
+		}
+		if used, original := quota.Status.Used[imageapi.ResourceImages], test.used[imageapi.ResourceImages]; used.Value() != original.Value() {
+			t.Errorf("%s: the quota was modified in place", name)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	streams := []imageapi.ImageStream{
+		{Status: imageapi.ImageStreamStatus{Tags: map[string]imageapi.TagEventList{
+			"latest": {Items: []imageapi.TagEvent{{Image: "image2"}, {Image: "image1"}}},
+			"old":    {Items: []imageapi.TagEvent{{DockerImageReference: "example.com/app"}}},
+		}}},
+		{Status: imageapi.ImageStreamStatus{Tags: map[string]imageapi.TagEventList{
+			"latest": {Items: []imageapi.TagEvent{{Image: "image1"}, {Image: "image3"}}},
+		}}},
+		{},
+	}
+	usage := Usage(streams)
+	if streams := usage[imageapi.ResourceImageStreams]; streams.Value() != 3 {
+		t.Errorf("expected 3 image streams, got %s", streams.String())
+	}
+	if images := usage[imageapi.ResourceImages]; images.Value() != 3 {
+		t.Errorf("expected 3 images, got %s", images.String())
+	}
+}
@@ -0,0 +1,2 @@
+// Package reaper implements the Reaper interface for image stream tags
+package reaper
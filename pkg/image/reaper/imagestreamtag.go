@@ -0,0 +1,92 @@
+package reaper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/kubectl"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+	imageutil "github.com/openshift/origin/pkg/image/util"
+)
+
+// NewImageStreamTagReaper returns a new reaper for image stream tags.
+func NewImageStreamTagReaper(oc client.Interface) kubectl.Reaper {
+	return &ImageStreamTagReaper{oc: oc}
+}
+
+// ImageStreamTagReaper implements the Reaper interface for image stream tags.
+type ImageStreamTagReaper struct {
+	oc client.Interface
+}
+
+// Stop on a reaper is actually used for deletion. In this case, the tag is
+// deleted along with its history in the status of its image stream, and the
+// images of the history that no image stream references anymore are marked
+// for pruning. Listing the image streams of all the projects and updating the
+// images requires more permissions than deleting the tag, the images aren't
+// marked if they are missing.
+func (r *ImageStreamTagReaper) Stop(namespace, name string, timeout time.Duration, gracePeriod *kapi.DeleteOptions) (string, error) {
+	streamName, tag, ok := imageapi.SplitImageStreamTag(name)
+	if !ok {
+		return "", fmt.Errorf("%q must be of the form <stream_name>:<tag>", name)
+	}
+	if err := r.oc.ImageStreamTags(namespace).Delete(streamName, tag); err != nil {
+		return "", err
+	}
+
+	images := sets.NewString()
+	err := kclient.RetryOnConflict(kclient.DefaultRetry, func() error {
+		stream, err := r.oc.ImageStreams(namespace).Get(streamName)
+		if err != nil {
+			return err
+		}
+		history, ok := stream.Status.Tags[tag]
+		if !ok {
+			return nil
+		}
+		for _, event := range history.Items {
+			if len(event.Image) > 0 {
+				images.Insert(event.Image)
+			}
+		}
+		delete(stream.Status.Tags, tag)
+		_, err = r.oc.ImageStreams(namespace).UpdateStatus(stream)
+		return err
+	})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if images.Len() == 0 {
+		return "", nil
+	}
+
+	streams, err := r.oc.ImageStreams(kapi.NamespaceAll).List(labels.Everything(), imageapi.ImageStreamsIncludingDeleted())
+	if err != nil {
+		glog.Infof("Cannot list the image streams to find the images of %s/%s that are unreferenced: %v", namespace, name, err)
+		return "", nil
+	}
+	for i := range streams.Items {
+		for _, history := range streams.Items[i].Status.Tags {
+			for _, event := range history.Items {
+				images.Delete(event.Image)
+			}
+		}
+	}
+	for _, imageName := range images.List() {
+		if err := imageutil.MarkImageForPruning(r.oc, imageName); err != nil {
+			glog.Infof("Cannot mark image %s for pruning: %v", imageName, err)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,78 @@
+package reaper
+
+import (
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestImageStreamTagReaper(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "one", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"old": {Items: []imageapi.TagEvent{{Image: "sha256:shared"}, {Image: "sha256:unreferenced"}}},
+				"new": {Items: []imageapi.TagEvent{{Image: "sha256:new"}}},
+			},
+		},
+	}
+	other := imageapi.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "two", Name: "app"},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"latest": {Items: []imageapi.TagEvent{{Image: "sha256:shared"}}},
+			},
+		},
+	}
+
+	client := testclient.NewSimpleFake()
+	var updated *imageapi.ImageStream
+	var marked []string
+	client.PrependReactor("delete", "imagestreamtags", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	client.PrependReactor("get", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		copied, err := kapi.Scheme.Copy(stream)
+		return true, copied, err
+	})
+	client.PrependReactor("update", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		updated = action.(ktestclient.UpdateAction).GetObject().(*imageapi.ImageStream)
+		return true, updated, nil
+	})
+	client.PrependReactor("list", "imagestreams", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, &imageapi.ImageStreamList{Items: []imageapi.ImageStream{*updated, other}}, nil
+	})
+	client.PrependReactor("get", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, &imageapi.Image{ObjectMeta: kapi.ObjectMeta{Name: action.(ktestclient.GetAction).GetName()}}, nil
+	})
+	client.PrependReactor("update", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		image := action.(ktestclient.UpdateAction).GetObject().(*imageapi.Image)
+		if image.Annotations[imageapi.ImageMarkedForPruningAnnotation] != "true" {
+			t.Errorf("expected image %s to be marked for pruning, got %#v", image.Name, image.Annotations)
+		}
+		marked = append(marked, image.Name)
+		return true, image, nil
+	})
+
+	reaper := NewImageStreamTagReaper(client)
+	if _, err := reaper.Stop("one", "app:old", 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actions := client.Actions()
+	if len(actions) == 0 || !actions[0].Matches("delete", "imagestreamtags") {
+		t.Fatalf("expected the tag to be deleted first, got %#v", actions)
+	}
+	if updated == nil || updated.Status.Tags["old"].Items != nil || len(updated.Status.Tags["new"].Items) != 1 {
+		t.Errorf("expected only the history of the deleted tag to be removed, got %#v", updated)
+	}
+	if expected := []string{"sha256:unreferenced"}; !reflect.DeepEqual(marked, expected) {
+		t.Errorf("expected images %v to be marked for pruning, got %v", expected, marked)
+	}
+}
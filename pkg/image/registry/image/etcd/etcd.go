@@ -6,6 +6,8 @@ import (
 	"github.com/openshift/origin/pkg/image/api"
 	"github.com/openshift/origin/pkg/image/registry/image"
 	kapi "k8s.io/kubernetes/pkg/api"
+	etcderr "k8s.io/kubernetes/pkg/api/errors/etcd"
+	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
 	etcdgeneric "k8s.io/kubernetes/pkg/registry/generic/etcd"
@@ -19,8 +21,14 @@ type REST struct {
 	store *etcdgeneric.Etcd
 }
 
-// NewREST returns a new REST.
-func NewREST(s storage.Interface) *REST {
+// FinalizeREST implements the finalize subresource of images, which updates
+// their finalizers.
+type FinalizeREST struct {
+	store *etcdgeneric.Etcd
+}
+
+// NewREST returns a new REST and a FinalizeREST.
+func NewREST(s storage.Interface) (*REST, *FinalizeREST) {
 	prefix := "/images"
 	store := &etcdgeneric.Etcd{
 		NewFunc:     func() runtime.Object { return &api.Image{} },
@@ -45,7 +53,11 @@ func NewREST(s storage.Interface) *REST {
 
 		Storage: s,
 	}
-	return &REST{store: store}
+
+	finalizeStore := *store
+	finalizeStore.UpdateStrategy = image.FinalizeStrategy
+
+	return &REST{store: store}, &FinalizeREST{store: &finalizeStore}
 }
 
 // New returns a new object
@@ -86,7 +98,50 @@ func (r *REST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, boo
 	return r.store.Update(ctx, obj)
 }
 
-// Delete deletes an existing image specified by its ID.
+// Delete deletes an existing image specified by its ID. An image with
+// finalizers is only marked for deletion with a deletion timestamp, it is
+// deleted by the next request once its finalizers have been removed. Deleting
+// an image already marked for deletion returns it unchanged.
 func (r *REST) Delete(ctx kapi.Context, name string, options *kapi.DeleteOptions) (runtime.Object, error) {
-	return r.store.Delete(ctx, name, options)
+	obj, err := r.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	img := obj.(*api.Image)
+	if len(img.Finalizers) == 0 {
+		return r.store.Delete(ctx, name, options)
+	}
+	if img.DeletionTimestamp != nil {
+		// the deletion is pending on the finalizers already, deleting the
+		// image again is a no-op
+		return img, nil
+	}
+
+	key, err := r.store.KeyFunc(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	out := &api.Image{}
+	err = r.store.Storage.GuaranteedUpdate(ctx, key, out, false, storage.SimpleUpdate(func(obj runtime.Object) (runtime.Object, error) {
+		img := obj.(*api.Image)
+		if img.DeletionTimestamp == nil {
+			now := unversioned.Now()
+			img.DeletionTimestamp = &now
+		}
+		return img, nil
+	}))
+	if err != nil {
+		return nil, etcderr.InterpretUpdateError(err, "image", name)
+	}
+	return out, nil
+}
+
+// New returns a new image for the finalize subresource.
+func (r *FinalizeREST) New() runtime.Object {
+	return r.store.NewFunc()
+}
+
+// Update alters the finalizers of an image.
+func (r *FinalizeREST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, bool, error) {
+	return r.store.Update(ctx, obj)
 }
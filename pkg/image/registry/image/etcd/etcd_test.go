@@ -71,12 +71,13 @@ func newHelper(t *testing.T) (*tools.FakeEtcdClient, storage.Interface) {
 
 func newStorage(t *testing.T) (*REST, *tools.FakeEtcdClient) {
 	etcdStorage, fakeClient := registrytest.NewEtcdStorage(t, "")
-	return NewREST(etcdStorage), fakeClient
+	storage, _ := NewREST(etcdStorage)
+	return storage, fakeClient
 }
 
 func TestStorage(t *testing.T) {
 	_, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	image.NewRegistry(storage)
 }
 
@@ -105,7 +106,7 @@ func TestCreate(t *testing.T) {
 func TestCreateRegistryError(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("test error")
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	image := validNewImage()
 	_, err := storage.Create(kapi.NewDefaultContext(), image)
@@ -118,7 +119,7 @@ func TestCreateAlreadyExists(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.TestIndex = true
 
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	existingImage := &api.Image{
 		ObjectMeta: kapi.ObjectMeta{
@@ -154,7 +155,7 @@ func TestCreateAlreadyExists(t *testing.T) {
 func TestListError(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("test error")
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	images, err := storage.List(kapi.NewDefaultContext(), labels.Everything(), fields.Everything())
 	if err != fakeEtcdClient.Err {
 		t.Fatalf("Expected %#v, Got %#v", fakeEtcdClient.Err, err)
@@ -171,7 +172,7 @@ func TestListEmptyList(t *testing.T) {
 		R: &etcd.Response{},
 		E: fakeEtcdClient.NewError(tools.EtcdErrorCodeNotFound),
 	}
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	images, err := storage.List(kapi.NewDefaultContext(), labels.Everything(), fields.Everything())
 	if err != nil {
 		t.Errorf("Unexpected non-nil error: %#v", err)
@@ -199,7 +200,7 @@ func TestListPopulatedList(t *testing.T) {
 		},
 	}
 
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	list, err := storage.List(kapi.NewDefaultContext(), labels.Everything(), fields.Everything())
 	if err != nil {
@@ -241,7 +242,7 @@ func TestListFiltered(t *testing.T) {
 		},
 		E: nil,
 	}
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	list, err := storage.List(kapi.NewDefaultContext(), labels.SelectorFromSet(labels.Set{"env": "dev"}), fields.Everything())
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -253,9 +254,85 @@ func TestListFiltered(t *testing.T) {
 	}
 }
 
+func TestListFilteredByMetadata(t *testing.T) {
+	fakeEtcdClient, helper := newHelper(t)
+	fakeEtcdClient.ChangeIndex = 1
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/images")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Nodes: []*etcd.Node{
+					{
+						Value: runtime.EncodeOrDie(latest.Codec, &api.Image{
+							ObjectMeta: kapi.ObjectMeta{Name: "foo"},
+							DockerImageMetadata: api.DockerImage{
+								Architecture: "amd64",
+								Config:       &api.DockerConfig{Labels: map[string]string{"io.k8s.display-name": "nodejs"}},
+							},
+						}),
+					},
+					{
+						Value: runtime.EncodeOrDie(latest.Codec, &api.Image{
+							ObjectMeta: kapi.ObjectMeta{Name: "bar"},
+							DockerImageMetadata: api.DockerImage{
+								Architecture: "arm",
+								Config:       &api.DockerConfig{Labels: map[string]string{"io.k8s.display-name": "ruby"}},
+							},
+						}),
+					},
+					{
+						Value: runtime.EncodeOrDie(latest.Codec, &api.Image{
+							ObjectMeta: kapi.ObjectMeta{
+								Name:   "baz",
+								Labels: map[string]string{"io.k8s.display-name": "nodejs"},
+							},
+							DockerImageMetadata: api.DockerImage{
+								Architecture: "amd64",
+								Config:       &api.DockerConfig{Labels: map[string]string{"io.k8s.display-name": "ruby"}},
+							},
+						}),
+					},
+				},
+			},
+		},
+		E: nil,
+	}
+	storage, _ := NewREST(helper)
+
+	tests := map[string]struct {
+		label    labels.Selector
+		field    fields.Selector
+		expected []string
+	}{
+		"docker label": {
+			label:    labels.SelectorFromSet(labels.Set{"io.k8s.display-name": "nodejs"}),
+			field:    fields.Everything(),
+			expected: []string{"foo", "baz"},
+		},
+		"architecture": {
+			label:    labels.Everything(),
+			field:    fields.SelectorFromSet(fields.Set{"dockerImageMetadata.Architecture": "arm"}),
+			expected: []string{"bar"},
+		},
+	}
+	for name, test := range tests {
+		list, err := storage.List(kapi.NewDefaultContext(), test.label, test.field)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		names := []string{}
+		for _, image := range list.(*api.ImageList).Items {
+			names = append(names, image.Name)
+		}
+		if !reflect.DeepEqual(test.expected, names) {
+			t.Errorf("%s: expected images %v, got %v", name, test.expected, names)
+		}
+	}
+}
+
 func TestCreateMissingID(t *testing.T) {
 	_, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	obj, err := storage.Create(kapi.NewDefaultContext(), &api.Image{})
 	if obj != nil {
@@ -268,7 +345,7 @@ func TestCreateMissingID(t *testing.T) {
 
 func TestCreateOK(t *testing.T) {
 	_, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	obj, err := storage.Create(kapi.NewDefaultContext(), &api.Image{
 		ObjectMeta:           kapi.ObjectMeta{Name: "foo"},
@@ -293,7 +370,7 @@ func TestCreateOK(t *testing.T) {
 func TestGetError(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("bad")
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	image, err := storage.Get(kapi.NewDefaultContext(), "foo")
 	if image != nil {
@@ -306,7 +383,7 @@ func TestGetError(t *testing.T) {
 
 func TestGetNotFound(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	fakeEtcdClient.Data[etcdtest.AddPrefix("/images/foo")] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
@@ -336,7 +413,7 @@ func TestGetOK(t *testing.T) {
 			},
 		},
 	}
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	image, err := storage.Get(kapi.NewDefaultContext(), "foo")
 	if image == nil {
@@ -359,7 +436,7 @@ func TestDelete(t *testing.T) {
 			},
 		},
 	}
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	obj, err := storage.Delete(kapi.NewDefaultContext(), "foo", nil)
 
@@ -384,10 +461,75 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteWithFinalizers(t *testing.T) {
+	fakeEtcdClient, helper := newHelper(t)
+	image := validNewImage()
+	image.Finalizers = []kapi.FinalizerName{api.ImageFinalizerRegistry}
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/images/foo")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, image),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+	storage, finalizeStorage := NewREST(helper)
+	ctx := kapi.NewDefaultContext()
+
+	// the first deletion only marks the image
+	obj, err := storage.Delete(ctx, "foo", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if image, ok := obj.(*api.Image); !ok || image.DeletionTimestamp == nil {
+		t.Fatalf("expected the image to be marked for deletion, got %#v", obj)
+	}
+	if len(fakeEtcdClient.DeletedKeys) != 0 {
+		t.Fatalf("unexpected deletion: %v", fakeEtcdClient.DeletedKeys)
+	}
+	// deleting it again while the finalizers run is a no-op
+	obj, err = storage.Delete(ctx, "foo", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if image, ok := obj.(*api.Image); !ok || image.DeletionTimestamp == nil || len(fakeEtcdClient.DeletedKeys) != 0 {
+		t.Fatalf("expected the image to stay marked for deletion, got %#v", obj)
+	}
+
+	// the finalizers can't be removed by an update
+	obj, err = storage.Get(ctx, "foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image = obj.(*api.Image)
+	image.Finalizers = nil
+	if _, _, err := storage.Update(ctx, image); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj, err = storage.Get(ctx, "foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if image = obj.(*api.Image); len(image.Finalizers) != 1 || image.DeletionTimestamp == nil {
+		t.Fatalf("expected the finalizers and the deletion timestamp to be kept, got %#v", image)
+	}
+
+	image.Finalizers = nil
+	if _, _, err := finalizeStorage.Update(ctx, image); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Delete(ctx, "foo", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fakeEtcdClient.DeletedKeys) != 1 {
+		t.Errorf("expected the image to be deleted, found %#v", fakeEtcdClient.DeletedKeys)
+	}
+}
+
 func TestDeleteNotFound(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = tools.EtcdErrorNotFound
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	_, err := storage.Delete(kapi.NewDefaultContext(), "foo", nil)
 	if err == nil {
 		t.Error("Unexpected non-error")
@@ -400,7 +542,7 @@ func TestDeleteNotFound(t *testing.T) {
 func TestDeleteImageError(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("Some error")
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	_, err := storage.Delete(kapi.NewDefaultContext(), "foo", nil)
 	if err == nil {
 		t.Error("Unexpected non-error")
@@ -409,7 +551,7 @@ func TestDeleteImageError(t *testing.T) {
 
 func TestWatchErrorWithFieldSet(t *testing.T) {
 	_, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	_, err := storage.Watch(kapi.NewDefaultContext(), labels.Everything(), fields.SelectorFromSet(fields.Set{"foo": "bar"}), "1")
 	if err == nil {
@@ -422,7 +564,7 @@ func TestWatchErrorWithFieldSet(t *testing.T) {
 
 func TestWatchOK(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 
 	var tests = []struct {
 		label    labels.Selector
@@ -522,7 +664,7 @@ func (fakeStrategy) PrepareForCreate(obj runtime.Object) {
 
 func TestStrategyPrepareMethods(t *testing.T) {
 	_, helper := newHelper(t)
-	storage := NewREST(helper)
+	storage, _ := NewREST(helper)
 	img := validNewImage()
 	strategy := fakeStrategy{image.Strategy}
 
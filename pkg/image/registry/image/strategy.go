@@ -31,7 +31,13 @@ func (imageStrategy) NamespaceScoped() bool {
 }
 
 // PrepareForCreate clears fields that are not allowed to be set by end users on creation.
+// The images managed by the integrated registry get the registry finalizer, so
+// that their content is removed from the registry storage when they are deleted.
 func (imageStrategy) PrepareForCreate(obj runtime.Object) {
+	image := obj.(*api.Image)
+	if image.Annotations[api.ManagedByOpenShiftAnnotation] == "true" && !api.HasFinalizer(image, api.ImageFinalizerRegistry) {
+		image.Finalizers = append(image.Finalizers, api.ImageFinalizerRegistry)
+	}
 }
 
 // Validate validates a new image.
@@ -57,6 +63,8 @@ func (imageStrategy) PrepareForUpdate(obj, old runtime.Object) {
 	newImage.DockerImageMetadata = oldImage.DockerImageMetadata
 	newImage.DockerImageManifest = oldImage.DockerImageManifest
 	newImage.DockerImageMetadataVersion = oldImage.DockerImageMetadataVersion
+	// finalizers are removed through the finalize subresource
+	newImage.Finalizers = oldImage.Finalizers
 }
 
 // ValidateUpdate is the default update validation for an end user.
@@ -65,6 +73,7 @@ func (imageStrategy) ValidateUpdate(ctx kapi.Context, obj, old runtime.Object) e
 }
 
 // MatchImage returns a generic matcher for a given label and field selector.
+// The label selector matches the labels of the docker image of the image too.
 func MatchImage(label labels.Selector, field fields.Selector) generic.Matcher {
 	return generic.MatcherFunc(func(obj runtime.Object) (bool, error) {
 		image, ok := obj.(*api.Image)
@@ -72,6 +81,25 @@ func MatchImage(label labels.Selector, field fields.Selector) generic.Matcher {
 			return false, fmt.Errorf("not an image")
 		}
 		fields := api.ImageToSelectableFields(image)
-		return label.Matches(labels.Set(image.Labels)) && field.Matches(fields), nil
+		return label.Matches(api.ImageToSelectableLabels(image)) && field.Matches(fields), nil
 	})
 }
+
+// imageFinalizeStrategy implements behavior for the finalize subresource of
+// Images.
+type imageFinalizeStrategy struct {
+	imageStrategy
+}
+
+// FinalizeStrategy is the logic that applies when updating the finalizers of
+// an Image.
+var FinalizeStrategy = imageFinalizeStrategy{Strategy}
+
+// PrepareForUpdate keeps everything but the finalizers of the image.
+func (imageFinalizeStrategy) PrepareForUpdate(obj, old runtime.Object) {
+	newImage := obj.(*api.Image)
+	oldImage := old.(*api.Image)
+	finalizers := newImage.Finalizers
+	*newImage = *oldImage
+	newImage.Finalizers = finalizers
+}
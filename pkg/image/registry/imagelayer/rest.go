@@ -0,0 +1,258 @@
+package imagelayer
+
+import (
+	"encoding/json"
+	"sort"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	"github.com/openshift/origin/pkg/authorization/registry/subjectaccessreview"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/registry/image"
+	"github.com/openshift/origin/pkg/image/registry/imagestream"
+)
+
+// REST implements the RESTStorage interface for ImageLayer. It supports the
+// Get and List methods, and describes the layers of the images referenced by
+// the image streams of a project, computed from the image streams of the
+// project and their images on each request. Selecting the field "imageStream"
+// describes the layers of a single image stream. Whether a layer is shared
+// with the image streams of other projects, or with the other image streams
+// of the project when a single one is selected, is only computed for the
+// users who may list the image streams of all the projects.
+type REST struct {
+	imageRegistry               image.Registry
+	imageStreamRegistry         imagestream.Registry
+	subjectAccessReviewRegistry subjectaccessreview.Registry
+}
+
+// NewREST returns a new REST.
+func NewREST(imageRegistry image.Registry, imageStreamRegistry imagestream.Registry, subjectAccessReviewRegistry subjectaccessreview.Registry) *REST {
+	return &REST{imageRegistry: imageRegistry, imageStreamRegistry: imageStreamRegistry, subjectAccessReviewRegistry: subjectAccessReviewRegistry}
+}
+
+// New returns a new ImageLayer.
+func (r *REST) New() runtime.Object {
+	return &api.ImageLayer{}
+}
+
+// NewList returns a new list object
+func (r *REST) NewList() runtime.Object {
+	return &api.ImageLayerList{}
+}
+
+// List returns the layers of the images referenced by the image streams of the
+// namespace, or by a single image stream if the field "imageStream" is
+// selected.
+func (r *REST) List(ctx kapi.Context, label labels.Selector, field fields.Selector) (runtime.Object, error) {
+	stream, _ := field.RequiresExactMatch("imageStream")
+	layers, err := r.layers(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &api.ImageLayerList{}
+	for i := range layers {
+		set := api.ImageLayerToSelectableFields(&layers[i])
+		set["imageStream"] = stream
+		if label.Matches(labels.Set(layers[i].Labels)) && field.Matches(set) {
+			list.Items = append(list.Items, layers[i])
+		}
+	}
+	return list, nil
+}
+
+// Get returns the layer of the images referenced by the image streams of the
+// namespace with the given digest.
+func (r *REST) Get(ctx kapi.Context, name string) (runtime.Object, error) {
+	layers, err := r.layers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range layers {
+		if layers[i].Name == name {
+			return &layers[i], nil
+		}
+	}
+	return nil, kapierrors.NewNotFound("imageLayer", name)
+}
+
+// layers returns the layers of the images referenced by the image streams of
+// the namespace of ctx, or only by the image stream named stream if it isn't
+// empty, sorted by digest. A layer is shared if an image referenced by an
+// image stream out of this scope has it too, which is only computed if the
+// user of ctx may list the image streams of all the projects: the others only
+// see the image streams and images of the namespace.
+func (r *REST) layers(ctx kapi.Context, stream string) ([]api.ImageLayer, error) {
+	namespace, ok := kapi.NamespaceFrom(ctx)
+	if !ok || len(namespace) == 0 {
+		return nil, kapierrors.NewBadRequest("a namespace is required to describe image layers")
+	}
+	if len(stream) > 0 {
+		if _, err := r.imageStreamRegistry.GetImageStream(ctx, stream); err != nil {
+			return nil, err
+		}
+	}
+
+	computeShared := r.canListAllImageStreams(ctx)
+	streamsCtx := ctx
+	if computeShared {
+		streamsCtx = kapi.WithNamespace(ctx, kapi.NamespaceAll)
+	}
+	streams, err := r.imageStreamRegistry.ListImageStreams(streamsCtx, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	imagesByName, err := r.images(ctx, streams.Items, computeShared)
+	if err != nil {
+		return nil, err
+	}
+
+	inScope := make(map[string]*api.ImageLayer)
+	layerImages := make(map[string]sets.String)
+	layerStreams := make(map[string]sets.String)
+	shared := sets.NewString()
+	for _, is := range streams.Items {
+		scoped := is.Namespace == namespace && (len(stream) == 0 || is.Name == stream)
+		if !scoped && !computeShared {
+			continue
+		}
+		for _, name := range referencedImages(&is).List() {
+			image, ok := imagesByName[name]
+			if !ok {
+				continue
+			}
+			for _, layer := range layersOf(image) {
+				if !scoped {
+					shared.Insert(layer.Name)
+					continue
+				}
+				if _, ok := inScope[layer.Name]; !ok {
+					inScope[layer.Name] = &api.ImageLayer{
+						ObjectMeta: kapi.ObjectMeta{Namespace: namespace, Name: layer.Name},
+					}
+					layerImages[layer.Name] = sets.NewString()
+					layerStreams[layer.Name] = sets.NewString()
+				}
+				if layer.Size > inScope[layer.Name].Size {
+					inScope[layer.Name].Size = layer.Size
+				}
+				layerImages[layer.Name].Insert(image.Name)
+				layerStreams[layer.Name].Insert(is.Name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(inScope))
+	for name := range inScope {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	layers := make([]api.ImageLayer, 0, len(names))
+	for _, name := range names {
+		layer := inScope[name]
+		layer.Images = layerImages[name].List()
+		layer.ImageStreams = layerStreams[name].List()
+		layer.Shared = shared.Has(name)
+		layers = append(layers, *layer)
+	}
+	return layers, nil
+}
+
+// images returns the images referenced by streams by name. All the images are
+// listed if all is true, otherwise the images referenced are retrieved one by
+// one, ignoring those that don't exist anymore.
+func (r *REST) images(ctx kapi.Context, streams []api.ImageStream, all bool) (map[string]*api.Image, error) {
+	imagesByName := make(map[string]*api.Image)
+	if all {
+		images, err := r.imageRegistry.ListImages(ctx, labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for i := range images.Items {
+			imagesByName[images.Items[i].Name] = &images.Items[i]
+		}
+		return imagesByName, nil
+	}
+
+	names := sets.NewString()
+	for i := range streams {
+		names.Insert(referencedImages(&streams[i]).List()...)
+	}
+	for _, name := range names.List() {
+		image, err := r.imageRegistry.GetImage(ctx, name)
+		if kapierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		imagesByName[name] = image
+	}
+	return imagesByName, nil
+}
+
+// canListAllImageStreams returns true if the user of ctx may list the image
+// streams of all the projects.
+func (r *REST) canListAllImageStreams(ctx kapi.Context) bool {
+	user, ok := kapi.UserFrom(ctx)
+	if !ok {
+		return false
+	}
+	subjectAccessReview := &authorizationapi.SubjectAccessReview{
+		Action: authorizationapi.AuthorizationAttributes{
+			Verb:     "list",
+			Resource: "imagestreams",
+		},
+		User:   user.GetName(),
+		Groups: sets.NewString(user.GetGroups()...),
+	}
+	resp, err := r.subjectAccessReviewRegistry.CreateSubjectAccessReview(kapi.WithNamespace(ctx, kapi.NamespaceAll), subjectAccessReview)
+	return err == nil && resp != nil && resp.Allowed
+}
+
+// referencedImages returns the names of the images in the tag history of
+// stream.
+func referencedImages(stream *api.ImageStream) sets.String {
+	images := sets.NewString()
+	for _, history := range stream.Status.Tags {
+		for _, event := range history.Items {
+			if len(event.Image) > 0 {
+				images.Insert(event.Image)
+			}
+		}
+	}
+	return images
+}
+
+// layersOf returns the distinct layers of image with their sizes, if they are
+// known. The sizes are recorded by the integrated registry on the images
+// pushed to it, the layers of the other images are read from their manifests.
+func layersOf(image *api.Image) []api.ImageLayerSize {
+	if layers, ok := api.ImageLayerSizes(image); ok {
+		return layers
+	}
+	var layers []api.ImageLayerSize
+	if len(image.DockerImageManifest) == 0 {
+		return nil
+	}
+	manifest := api.DockerImageManifest{}
+	if err := json.Unmarshal([]byte(image.DockerImageManifest), &manifest); err != nil {
+		return nil
+	}
+	seen := sets.NewString()
+	for _, layer := range manifest.FSLayers {
+		if seen.Has(layer.DockerBlobSum) {
+			continue
+		}
+		seen.Insert(layer.DockerBlobSum)
+		layers = append(layers, api.ImageLayerSize{Name: layer.DockerBlobSum})
+	}
+	return layers
+}
@@ -0,0 +1,199 @@
+package imagelayer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/auth/user"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/registry/image"
+	"github.com/openshift/origin/pkg/image/registry/imagestream"
+)
+
+type fakeImageRegistry struct {
+	image.Registry
+	images []api.Image
+	listed bool
+}
+
+func (r *fakeImageRegistry) ListImages(ctx kapi.Context, selector labels.Selector) (*api.ImageList, error) {
+	r.listed = true
+	return &api.ImageList{Items: r.images}, nil
+}
+
+func (r *fakeImageRegistry) GetImage(ctx kapi.Context, name string) (*api.Image, error) {
+	for i := range r.images {
+		if r.images[i].Name == name {
+			return &r.images[i], nil
+		}
+	}
+	return nil, kapierrors.NewNotFound("image", name)
+}
+
+type fakeImageStreamRegistry struct {
+	imagestream.Registry
+	streams []api.ImageStream
+}
+
+func (r *fakeImageStreamRegistry) ListImageStreams(ctx kapi.Context, selector labels.Selector) (*api.ImageStreamList, error) {
+	namespace, _ := kapi.NamespaceFrom(ctx)
+	list := &api.ImageStreamList{}
+	for _, stream := range r.streams {
+		if len(namespace) == 0 || stream.Namespace == namespace {
+			list.Items = append(list.Items, stream)
+		}
+	}
+	return list, nil
+}
+
+type fakeSubjectAccessReviewRegistry struct {
+	allow bool
+}
+
+func (r *fakeSubjectAccessReviewRegistry) CreateSubjectAccessReview(ctx kapi.Context, sar *authorizationapi.SubjectAccessReview) (*authorizationapi.SubjectAccessReviewResponse, error) {
+	if len(kapi.NamespaceValue(ctx)) != 0 || sar.Action.Verb != "list" || sar.Action.Resource != "imagestreams" || sar.User != "user" {
+		return nil, fmt.Errorf("unexpected subject access review %#v in namespace %q", sar, kapi.NamespaceValue(ctx))
+	}
+	return &authorizationapi.SubjectAccessReviewResponse{Allowed: r.allow}, nil
+}
+
+func (r *fakeImageStreamRegistry) GetImageStream(ctx kapi.Context, name string) (*api.ImageStream, error) {
+	namespace, _ := kapi.NamespaceFrom(ctx)
+	for i := range r.streams {
+		if r.streams[i].Namespace == namespace && r.streams[i].Name == name {
+			return &r.streams[i], nil
+		}
+	}
+	return nil, kapierrors.NewNotFound("imageStream", name)
+}
+
+func stream(namespace, name string, images ...string) api.ImageStream {
+	history := api.TagEventList{}
+	for _, image := range images {
+		history.Items = append(history.Items, api.TagEvent{Image: image})
+	}
+	return api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     api.ImageStreamStatus{Tags: map[string]api.TagEventList{"latest": history}},
+	}
+}
+
+func testREST(clusterAdmin bool) *REST {
+	images := []api.Image{
+		{
+			ObjectMeta: kapi.ObjectMeta{
+				Name:        "image1",
+				Annotations: map[string]string{api.ImageLayersAnnotation: `[{"name":"layer1","size":10},{"name":"layer2","size":20}]`},
+			},
+		},
+		{
+			ObjectMeta:          kapi.ObjectMeta{Name: "image2"},
+			DockerImageManifest: `{"schemaVersion":1,"fsLayers":[{"blobSum":"layer3"},{"blobSum":"layer1"},{"blobSum":"layer3"}]}`,
+		},
+	}
+	streams := []api.ImageStream{
+		stream("ns", "is1", "image1"),
+		stream("ns", "is2", "image1", "image2"),
+		stream("other", "is3", "image2"),
+	}
+	return NewREST(&fakeImageRegistry{images: images}, &fakeImageStreamRegistry{streams: streams}, &fakeSubjectAccessReviewRegistry{allow: clusterAdmin})
+}
+
+func testContext(namespace string) kapi.Context {
+	return kapi.WithUser(kapi.WithNamespace(kapi.NewContext(), namespace), &user.DefaultInfo{Name: "user"})
+}
+
+func TestList(t *testing.T) {
+	storage := testREST(true)
+	ctx := testContext("ns")
+
+	obj, err := storage.List(ctx, labels.Everything(), fields.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []api.ImageLayer{
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer1"}, Size: 10, Images: []string{"image1", "image2"}, ImageStreams: []string{"is1", "is2"}, Shared: true},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer2"}, Size: 20, Images: []string{"image1"}, ImageStreams: []string{"is1", "is2"}},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer3"}, Images: []string{"image2"}, ImageStreams: []string{"is2"}, Shared: true},
+	}
+	if items := obj.(*api.ImageLayerList).Items; !reflect.DeepEqual(items, expected) {
+		t.Errorf("unexpected layers:\n%#v\nexpected:\n%#v", items, expected)
+	}
+
+	obj, err = storage.List(ctx, labels.Everything(), fields.OneTermEqualSelector("imageStream", "is1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []api.ImageLayer{
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer1"}, Size: 10, Images: []string{"image1"}, ImageStreams: []string{"is1"}, Shared: true},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer2"}, Size: 20, Images: []string{"image1"}, ImageStreams: []string{"is1"}, Shared: true},
+	}
+	if items := obj.(*api.ImageLayerList).Items; !reflect.DeepEqual(items, expected) {
+		t.Errorf("unexpected layers:\n%#v\nexpected:\n%#v", items, expected)
+	}
+
+	if _, err := storage.List(ctx, labels.Everything(), fields.OneTermEqualSelector("imageStream", "missing")); !kapierrors.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if _, err := storage.List(kapi.NewContext(), labels.Everything(), fields.Everything()); !kapierrors.IsBadRequest(err) {
+		t.Errorf("expected a bad request error, got %v", err)
+	}
+}
+
+func TestListProjectUser(t *testing.T) {
+	storage := testREST(false)
+	ctx := testContext("ns")
+
+	// the image streams of other projects aren't looked at, nothing is shared
+	obj, err := storage.List(ctx, labels.Everything(), fields.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []api.ImageLayer{
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer1"}, Size: 10, Images: []string{"image1", "image2"}, ImageStreams: []string{"is1", "is2"}},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer2"}, Size: 20, Images: []string{"image1"}, ImageStreams: []string{"is1", "is2"}},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer3"}, Images: []string{"image2"}, ImageStreams: []string{"is2"}},
+	}
+	if items := obj.(*api.ImageLayerList).Items; !reflect.DeepEqual(items, expected) {
+		t.Errorf("unexpected layers:\n%#v\nexpected:\n%#v", items, expected)
+	}
+	if storage.imageRegistry.(*fakeImageRegistry).listed {
+		t.Errorf("expected only the images of the project to be retrieved")
+	}
+
+	obj, err = storage.List(ctx, labels.Everything(), fields.OneTermEqualSelector("imageStream", "is1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []api.ImageLayer{
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer1"}, Size: 10, Images: []string{"image1"}, ImageStreams: []string{"is1"}},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "layer2"}, Size: 20, Images: []string{"image1"}, ImageStreams: []string{"is1"}},
+	}
+	if items := obj.(*api.ImageLayerList).Items; !reflect.DeepEqual(items, expected) {
+		t.Errorf("unexpected layers:\n%#v\nexpected:\n%#v", items, expected)
+	}
+}
+
+func TestGet(t *testing.T) {
+	storage := testREST(true)
+
+	obj, err := storage.Get(testContext("other"), "layer1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	layer := obj.(*api.ImageLayer)
+	if layer.Size != 0 || !layer.Shared || !reflect.DeepEqual(layer.ImageStreams, []string{"is3"}) {
+		t.Errorf("unexpected layer: %#v", layer)
+	}
+
+	if _, err := storage.Get(testContext("other"), "layer2"); !kapierrors.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package imagesecret
+
+import (
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// REST implements the secrets subresource of image streams. It only supports
+// the Get method, which returns the docker registry secrets of the namespace
+// of the image stream, used to pull its images from the registries they were
+// imported from. This lets the integrated registry read these secrets one
+// namespace at a time, with no permission on the other secrets.
+type REST struct {
+	secrets kclient.SecretsNamespacer
+}
+
+// NewREST returns a new REST reading the secrets with secrets.
+func NewREST(secrets kclient.SecretsNamespacer) *REST {
+	return &REST{secrets: secrets}
+}
+
+// New returns a new SecretList.
+func (r *REST) New() runtime.Object {
+	return &kapi.SecretList{}
+}
+
+// Get returns the secrets of type dockercfg or dockerconfigjson of the
+// namespace of the image stream name.
+func (r *REST) Get(ctx kapi.Context, name string) (runtime.Object, error) {
+	namespace, ok := kapi.NamespaceFrom(ctx)
+	if !ok || len(namespace) == 0 {
+		return nil, errors.NewBadRequest("a namespace must be specified to read the secrets of an image stream")
+	}
+	secrets, err := r.secrets.Secrets(namespace).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return nil, err
+	}
+	filtered := []kapi.Secret{}
+	for i := range secrets.Items {
+		switch secrets.Items[i].Type {
+		case kapi.SecretTypeDockercfg, kapi.SecretTypeDockerConfigJson:
+			filtered = append(filtered, secrets.Items[i])
+		}
+	}
+	secrets.Items = filtered
+	return secrets, nil
+}
@@ -0,0 +1,35 @@
+package imagesecret
+
+import (
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+)
+
+func TestGetReturnsDockerSecrets(t *testing.T) {
+	fake := testclient.NewSimpleFake(&kapi.SecretList{Items: []kapi.Secret{
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "dockercfg"}, Type: kapi.SecretTypeDockercfg},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "config"}, Type: kapi.SecretTypeDockerConfigJson},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "token"}, Type: kapi.SecretTypeServiceAccountToken},
+		{ObjectMeta: kapi.ObjectMeta{Namespace: "ns", Name: "opaque"}, Type: kapi.SecretTypeOpaque},
+	}})
+	storage := NewREST(fake)
+
+	obj, err := storage.Get(kapi.WithNamespace(kapi.NewContext(), "ns"), "is")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secrets := obj.(*kapi.SecretList)
+	if len(secrets.Items) != 2 || secrets.Items[0].Name != "dockercfg" || secrets.Items[1].Name != "config" {
+		t.Errorf("expected only the docker registry secrets, got %#v", secrets.Items)
+	}
+	actions := fake.Actions()
+	if len(actions) != 1 || !actions[0].Matches("list", "secrets") || actions[0].GetNamespace() != "ns" {
+		t.Errorf("expected the secrets of the namespace to be listed, got %#v", actions)
+	}
+
+	if _, err := storage.Get(kapi.NewContext(), "is"); err == nil {
+		t.Errorf("expected an error without a namespace")
+	}
+}
@@ -0,0 +1,138 @@
+package imagesignature
+
+import (
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/rest"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/fielderrors"
+
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/api/validation"
+	"github.com/openshift/origin/pkg/image/registry/image"
+)
+
+// maxRetriesOnConflict is the maximum retry count for the updates of the image
+// which result in resource conflicts.
+const maxRetriesOnConflict = 10
+
+// REST implements the RESTStorage interface in terms of an image registry. It
+// supports the Create and Delete methods, which add a signature to the image
+// it signs and remove it.
+type REST struct {
+	imageRegistry image.Registry
+}
+
+// NewREST returns a new REST.
+func NewREST(imageRegistry image.Registry) *REST {
+	return &REST{imageRegistry: imageRegistry}
+}
+
+// imageSignatureStrategy implements behavior for image signatures.
+type imageSignatureStrategy struct {
+	runtime.ObjectTyper
+	kapi.NameGenerator
+}
+
+// Strategy is the default logic that applies when creating ImageSignature
+// objects via the REST API.
+var Strategy = imageSignatureStrategy{kapi.Scheme, kapi.SimpleNameGenerator}
+
+// New returns a new ImageSignature for use with Create.
+func (r *REST) New() runtime.Object {
+	return &api.ImageSignature{}
+}
+
+// NamespaceScoped is false for image signatures.
+func (s imageSignatureStrategy) NamespaceScoped() bool {
+	return false
+}
+
+// PrepareForCreate clears fields that are not allowed to be set by end users on creation.
+func (s imageSignatureStrategy) PrepareForCreate(obj runtime.Object) {
+}
+
+// Validate validates a new ImageSignature.
+func (s imageSignatureStrategy) Validate(ctx kapi.Context, obj runtime.Object) fielderrors.ValidationErrorList {
+	signature := obj.(*api.ImageSignature)
+	return validation.ValidateImageSignature(signature)
+}
+
+// Create adds the signature to the image it signs. It fails if the image has a
+// signature with the same name already.
+func (r *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, error) {
+	if err := rest.BeforeCreate(Strategy, ctx, obj); err != nil {
+		return nil, err
+	}
+	signature := obj.(*api.ImageSignature)
+	imageName, _, _ := api.SplitImageSignatureName(signature.Name)
+
+	err := r.updateImage(ctx, imageName, func(image *api.Image) error {
+		if indexOfSignature(image, signature.Name) >= 0 {
+			return errors.NewAlreadyExists("imageSignature", signature.Name)
+		}
+		stored := *signature
+		stored.TypeMeta = unversioned.TypeMeta{}
+		image.Signatures = append(image.Signatures, stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// Delete removes the signature named id, of the form <image name>@<signature
+// name>, from the image it signs.
+func (r *REST) Delete(ctx kapi.Context, id string) (runtime.Object, error) {
+	imageName, _, ok := api.SplitImageSignatureName(id)
+	if !ok {
+		return nil, errors.NewBadRequest("ImageSignatures must be deleted with <image name>@<signature name>")
+	}
+
+	err := r.updateImage(ctx, imageName, func(image *api.Image) error {
+		i := indexOfSignature(image, id)
+		if i < 0 {
+			return errors.NewNotFound("imageSignature", id)
+		}
+		image.Signatures = append(image.Signatures[:i], image.Signatures[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &unversioned.Status{Status: unversioned.StatusSuccess}, nil
+}
+
+// updateImage applies change to the image named imageName and updates it,
+// reading the image again if the update conflicts.
+func (r *REST) updateImage(ctx kapi.Context, imageName string, change func(*api.Image) error) error {
+	for i := 0; ; i++ {
+		image, err := r.imageRegistry.GetImage(ctx, imageName)
+		if err != nil {
+			return err
+		}
+		if err := change(image); err != nil {
+			return err
+		}
+		_, err = r.imageRegistry.UpdateImage(ctx, image)
+		if err == nil {
+			return nil
+		}
+		if !errors.IsConflict(err) || i >= maxRetriesOnConflict {
+			return err
+		}
+	}
+}
+
+// indexOfSignature returns the index of the signature named name in the
+// signatures of image, or -1.
+func indexOfSignature(image *api.Image, name string) int {
+	for i := range image.Signatures {
+		if image.Signatures[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+package imagesignature
+
+import (
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	etcdstorage "k8s.io/kubernetes/pkg/storage/etcd"
+	"k8s.io/kubernetes/pkg/tools"
+	"k8s.io/kubernetes/pkg/tools/etcdtest"
+
+	"github.com/openshift/origin/pkg/api/latest"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/registry/image"
+	imageetcd "github.com/openshift/origin/pkg/image/registry/image/etcd"
+)
+
+func setup(t *testing.T) (image.Registry, *REST) {
+	fakeEtcdClient := tools.NewFakeEtcdClient(t)
+	fakeEtcdClient.TestIndex = true
+	helper := etcdstorage.NewEtcdStorage(fakeEtcdClient, latest.Codec, etcdtest.PathPrefix())
+	imageStorage, _ := imageetcd.NewREST(helper)
+	imageRegistry := image.NewRegistry(imageStorage)
+	return imageRegistry, NewREST(imageRegistry)
+}
+
+func newSignature(name string) *api.ImageSignature {
+	return &api.ImageSignature{
+		ObjectMeta: kapi.ObjectMeta{Name: name},
+		Type:       api.ImageSignatureTypeAtomicImageV1,
+		Content:    []byte("signature"),
+	}
+}
+
+func TestCreateAndDelete(t *testing.T) {
+	imageRegistry, storage := setup(t)
+	ctx := kapi.NewContext()
+	if err := imageRegistry.CreateImage(ctx, &api.Image{
+		ObjectMeta:           kapi.ObjectMeta{Name: "sha256:abc"},
+		DockerImageReference: "example.com/ns/app@sha256:abc",
+	}); err != nil {
+		t.Fatalf("unexpected error creating the image: %v", err)
+	}
+
+	if _, err := storage.Create(ctx, newSignature("sha256:abc@sig")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image, err := imageRegistry.GetImage(ctx, "sha256:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(image.Signatures) != 1 || image.Signatures[0].Name != "sha256:abc@sig" || string(image.Signatures[0].Content) != "signature" {
+		t.Fatalf("expected the signature to be added to the image, got %#v", image.Signatures)
+	}
+	if _, err := storage.Create(ctx, newSignature("sha256:abc@sig")); !errors.IsAlreadyExists(err) {
+		t.Errorf("expected the signature to exist already, got %v", err)
+	}
+
+	if _, err := storage.Delete(ctx, "sha256:abc@sig"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image, err = imageRegistry.GetImage(ctx, "sha256:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(image.Signatures) != 0 {
+		t.Errorf("expected the signature to be removed, got %#v", image.Signatures)
+	}
+	if _, err := storage.Delete(ctx, "sha256:abc@sig"); !errors.IsNotFound(err) {
+		t.Errorf("expected the signature not to be found, got %v", err)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	_, storage := setup(t)
+	ctx := kapi.NewContext()
+
+	if _, err := storage.Create(ctx, newSignature("sha256:abc")); !errors.IsInvalid(err) {
+		t.Errorf("expected the signature name to be invalid, got %v", err)
+	}
+	if _, err := storage.Create(ctx, newSignature("sha256:missing@sig")); !errors.IsNotFound(err) {
+		t.Errorf("expected the image not to be found, got %v", err)
+	}
+	if _, err := storage.Delete(ctx, "sha256:abc"); !errors.IsBadRequest(err) {
+		t.Errorf("expected a bad request, got %v", err)
+	}
+}
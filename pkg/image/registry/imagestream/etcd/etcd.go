@@ -1,10 +1,16 @@
 package etcd
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/openshift/origin/pkg/authorization/registry/subjectaccessreview"
 	"github.com/openshift/origin/pkg/image/api"
 	"github.com/openshift/origin/pkg/image/registry/imagestream"
 	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	etcderr "k8s.io/kubernetes/pkg/api/errors/etcd"
+	"k8s.io/kubernetes/pkg/client/record"
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
 	etcdgeneric "k8s.io/kubernetes/pkg/registry/generic/etcd"
@@ -17,10 +23,14 @@ import (
 type REST struct {
 	store                       *etcdgeneric.Etcd
 	subjectAccessReviewRegistry subjectaccessreview.Registry
+	recorder                    record.EventRecorder
 }
 
-// NewREST returns a new REST.
-func NewREST(s storage.Interface, defaultRegistry imagestream.DefaultRegistry, subjectAccessReviewRegistry subjectaccessreview.Registry) (*REST, *StatusREST, *InternalREST) {
+// NewREST returns a new REST, and the RESTs of the status, internal and restore
+// subresources. Deleted image streams are retained for
+// deletionGracePeriodSeconds if it isn't 0. The tags that reference new images
+// are recorded as events with recorder, if it isn't nil.
+func NewREST(s storage.Interface, defaultRegistry imagestream.DefaultRegistry, subjectAccessReviewRegistry subjectaccessreview.Registry, deletionGracePeriodSeconds int64, recorder record.EventRecorder) (*REST, *StatusREST, *InternalREST, *RestoreREST) {
 	prefix := "/imagestreams"
 	store := etcdgeneric.Etcd{
 		NewFunc:     func() runtime.Object { return &api.ImageStream{} },
@@ -41,7 +51,7 @@ func NewREST(s storage.Interface, defaultRegistry imagestream.DefaultRegistry, s
 	}
 
 	strategy := imagestream.NewStrategy(defaultRegistry, subjectAccessReviewRegistry)
-	rest := &REST{subjectAccessReviewRegistry: subjectAccessReviewRegistry}
+	rest := &REST{subjectAccessReviewRegistry: subjectAccessReviewRegistry, recorder: recorder}
 	strategy.ImageStreamGetter = rest
 
 	statusStore := store
@@ -52,11 +62,12 @@ func NewREST(s storage.Interface, defaultRegistry imagestream.DefaultRegistry, s
 
 	store.CreateStrategy = strategy
 	store.UpdateStrategy = strategy
+	store.DeleteStrategy = imagestream.NewDeleteStrategy(deletionGracePeriodSeconds)
 	store.Decorator = strategy.Decorate
 
 	rest.store = &store
 
-	return rest, &StatusREST{store: &statusStore}, &InternalREST{store: &internalStore}
+	return rest, &StatusREST{store: &statusStore, recorder: recorder}, &InternalREST{store: &internalStore, recorder: recorder}, &RestoreREST{store: &store}
 }
 
 // New returns a new object
@@ -84,24 +95,66 @@ func (r *REST) Get(ctx kapi.Context, name string) (runtime.Object, error) {
 	return r.store.Get(ctx, name)
 }
 
-// Create creates a image stream based on a specification.
+// Create creates a image stream based on a specification. The name of an image
+// stream being deleted can't be reused until the stream is removed, although
+// it isn't listed anymore: the AlreadyExists error tells how to restore it.
 func (r *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, error) {
-	return r.store.Create(ctx, obj)
+	out, err := r.store.Create(ctx, obj)
+	if kerrors.IsAlreadyExists(err) {
+		return nil, r.alreadyExistsError(ctx, err, obj.(*api.ImageStream).Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	imagestream.RecordTagUpdates(r.recorder, ctx, nil, out.(*api.ImageStream))
+	return out, nil
+}
+
+// alreadyExistsError explains the AlreadyExists error err of the creation of
+// the image stream name when the existing stream is being deleted.
+func (r *REST) alreadyExistsError(ctx kapi.Context, err error, name string) error {
+	statusErr, ok := err.(*kerrors.StatusError)
+	if !ok {
+		return err
+	}
+	existing, getErr := r.store.Get(ctx, name)
+	if getErr != nil {
+		return err
+	}
+	stream := existing.(*api.ImageStream)
+	if stream.DeletionTimestamp == nil {
+		return err
+	}
+	statusErr.ErrStatus.Message = fmt.Sprintf("%s: it is being deleted and will be removed at %s, unless it is restored with \"oadm restore imagestream %s\"", statusErr.ErrStatus.Message, stream.DeletionTimestamp.Format(time.RFC1123Z), name)
+	return statusErr
 }
 
 // Update changes a image stream specification.
 func (r *REST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, bool, error) {
-	return r.store.Update(ctx, obj)
+	return updateAndRecord(ctx, r.store, r.recorder, obj)
 }
 
-// Delete deletes an existing image stream specified by its ID.
+// Delete deletes an existing image stream specified by its ID. An image stream
+// deleted with a grace period is only marked for deletion, it is removed by the
+// next deletion once its grace period expired.
 func (r *REST) Delete(ctx kapi.Context, name string, options *kapi.DeleteOptions) (runtime.Object, error) {
+	if options != nil && options.GracePeriodSeconds == nil {
+		obj, err := r.store.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		stream := obj.(*api.ImageStream)
+		if stream.DeletionTimestamp != nil && !time.Now().Before(stream.DeletionTimestamp.Time) {
+			options = kapi.NewDeleteOptions(0)
+		}
+	}
 	return r.store.Delete(ctx, name, options)
 }
 
 // StatusREST implements the REST endpoint for changing the status of an image stream.
 type StatusREST struct {
-	store *etcdgeneric.Etcd
+	store    *etcdgeneric.Etcd
+	recorder record.EventRecorder
 }
 
 func (r *StatusREST) New() runtime.Object {
@@ -110,12 +163,13 @@ func (r *StatusREST) New() runtime.Object {
 
 // Update alters the status subset of an object.
 func (r *StatusREST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, bool, error) {
-	return r.store.Update(ctx, obj)
+	return updateAndRecord(ctx, r.store, r.recorder, obj)
 }
 
 // InternalREST implements the REST endpoint for changing both the spec and status of an image stream.
 type InternalREST struct {
-	store *etcdgeneric.Etcd
+	store    *etcdgeneric.Etcd
+	recorder record.EventRecorder
 }
 
 func (r *InternalREST) New() runtime.Object {
@@ -124,5 +178,66 @@ func (r *InternalREST) New() runtime.Object {
 
 // Update alters both the spec and status of the object.
 func (r *InternalREST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, bool, error) {
-	return r.store.Update(ctx, obj)
+	return updateAndRecord(ctx, r.store, r.recorder, obj)
+}
+
+// updateAndRecord updates the image stream obj with store, and records the
+// tags of the image stream that reference new images with recorder.
+func updateAndRecord(ctx kapi.Context, store *etcdgeneric.Etcd, recorder record.EventRecorder, obj runtime.Object) (runtime.Object, bool, error) {
+	stream, ok := obj.(*api.ImageStream)
+	if recorder == nil || !ok {
+		return store.Update(ctx, obj)
+	}
+	var old *api.ImageStream
+	if existing, err := store.Get(ctx, stream.Name); err == nil {
+		old = existing.(*api.ImageStream)
+	}
+	out, created, err := store.Update(ctx, obj)
+	if err != nil {
+		return nil, false, err
+	}
+	if old != nil || created {
+		imagestream.RecordTagUpdates(recorder, ctx, old, out.(*api.ImageStream))
+	}
+	return out, created, nil
+}
+
+// RestoreREST implements the restore subresource of image streams, which
+// undoes the deletion of an image stream during its grace period.
+type RestoreREST struct {
+	store *etcdgeneric.Etcd
+}
+
+func (r *RestoreREST) New() runtime.Object {
+	return &api.ImageStream{}
+}
+
+// Update clears the deletion timestamp of an image stream being deleted.
+func (r *RestoreREST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, bool, error) {
+	stream, ok := obj.(*api.ImageStream)
+	if !ok {
+		return nil, false, kerrors.NewBadRequest(fmt.Sprintf("obj is not an ImageStream: %#v", obj))
+	}
+	key, err := r.store.KeyFunc(ctx, stream.Name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	out := &api.ImageStream{}
+	err = r.store.Storage.GuaranteedUpdate(ctx, key, out, false, storage.SimpleUpdate(func(existing runtime.Object) (runtime.Object, error) {
+		existingStream := existing.(*api.ImageStream)
+		if existingStream.DeletionTimestamp == nil {
+			return nil, kerrors.NewBadRequest(fmt.Sprintf("image stream %s is not being deleted", stream.Name))
+		}
+		existingStream.DeletionTimestamp = nil
+		existingStream.DeletionGracePeriodSeconds = nil
+		return existingStream, nil
+	}))
+	if err != nil {
+		return nil, false, etcderr.InterpretUpdateError(err, r.store.EndpointName, stream.Name)
+	}
+	if err := r.store.Decorator(out); err != nil {
+		return nil, false, err
+	}
+	return out, false, nil
 }
@@ -2,6 +2,7 @@ package etcd
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -10,6 +11,7 @@ import (
 	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/auth/user"
+	"k8s.io/kubernetes/pkg/client/record"
 	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/runtime"
@@ -62,7 +64,7 @@ func validNewStream() *api.ImageStream {
 
 func TestCreate(t *testing.T) {
 	_, helper := newHelper(t)
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 	stream := validNewStream()
 	ctx := kapi.WithUser(kapi.NewDefaultContext(), &fakeUser{})
 	_, err := storage.Create(ctx, stream)
@@ -74,7 +76,7 @@ func TestCreate(t *testing.T) {
 func TestGetImageStreamError(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("foo")
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	image, err := storage.Get(kapi.NewDefaultContext(), "image1")
 	if image != nil {
@@ -87,7 +89,7 @@ func TestGetImageStreamError(t *testing.T) {
 
 func TestGetImageStreamOK(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	ctx := kapi.NewDefaultContext()
 	repoName := "foo"
@@ -110,7 +112,7 @@ func TestGetImageStreamOK(t *testing.T) {
 func TestListImageStreamsError(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("foo")
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	imageStreams, err := storage.List(kapi.NewDefaultContext(), nil, nil)
 	if err != fakeEtcdClient.Err {
@@ -129,7 +131,7 @@ func TestListImageStreamsEmptyList(t *testing.T) {
 		R: &etcd.Response{},
 		E: fakeEtcdClient.NewError(tools.EtcdErrorCodeNotFound),
 	}
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	imageStreams, err := storage.List(kapi.NewDefaultContext(), labels.Everything(), fields.Everything())
 	if err != nil {
@@ -145,7 +147,7 @@ func TestListImageStreamsEmptyList(t *testing.T) {
 
 func TestListImageStreamsPopulatedList(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default")] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
@@ -189,7 +191,7 @@ func (u *fakeUser) GetGroups() []string {
 
 func TestCreateImageStreamOK(t *testing.T) {
 	_, helper := newHelper(t)
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	stream := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "foo"}}
 	ctx := kapi.WithUser(kapi.NewDefaultContext(), &fakeUser{})
@@ -246,7 +248,7 @@ func TestCreateImageStreamSpecTagsFromSet(t *testing.T) {
 		sarRegistry := &fakeSubjectAccessReviewRegistry{
 			allow: test.sarAllowed,
 		}
-		storage, _, _ := NewREST(helper, noDefaultRegistry, sarRegistry)
+		storage, _, _, _ := NewREST(helper, noDefaultRegistry, sarRegistry, 0, nil)
 
 		otherNamespace := test.otherNamespace
 		if len(otherNamespace) == 0 {
@@ -321,7 +323,7 @@ func TestCreateImageStreamSpecTagsFromSet(t *testing.T) {
 func TestCreateRegistryErrorSaving(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("foo")
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	ctx := kapi.WithUser(kapi.NewDefaultContext(), &fakeUser{})
 	_, err := storage.Create(ctx, &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "foo"}})
@@ -332,7 +334,7 @@ func TestCreateRegistryErrorSaving(t *testing.T) {
 
 func TestUpdateImageStreamMissingID(t *testing.T) {
 	_, helper := newHelper(t)
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	obj, created, err := storage.Update(kapi.NewDefaultContext(), &api.ImageStream{})
 	if obj != nil || created {
@@ -346,7 +348,7 @@ func TestUpdateImageStreamMissingID(t *testing.T) {
 func TestUpdateRegistryErrorSaving(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Err = fmt.Errorf("foo")
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	_, created, err := storage.Update(kapi.NewDefaultContext(), &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "bar"}})
 	if err != fakeEtcdClient.Err || created {
@@ -366,7 +368,7 @@ func TestUpdateImageStreamOK(t *testing.T) {
 			},
 		},
 	}
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	ctx := kapi.WithUser(kapi.NewDefaultContext(), &fakeUser{})
 	obj, created, err := storage.Update(ctx, &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "bar", ResourceVersion: "1"}})
@@ -416,7 +418,7 @@ func TestUpdateImageStreamSpecTagsFromSet(t *testing.T) {
 		sarRegistry := &fakeSubjectAccessReviewRegistry{
 			allow: test.sarAllowed,
 		}
-		storage, _, _ := NewREST(helper, noDefaultRegistry, sarRegistry)
+		storage, _, _, _ := NewREST(helper, noDefaultRegistry, sarRegistry, 0, nil)
 
 		fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/foo")] = tools.EtcdResponseWithError{
 			R: &etcd.Response{
@@ -541,7 +543,7 @@ func TestUpdateImageStreamTags(t *testing.T) {
 		},
 	}
 
-	_, _, storage := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	_, _, storage, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	stream := &api.ImageStream{
 		ObjectMeta: kapi.ObjectMeta{
@@ -604,6 +606,51 @@ func TestUpdateImageStreamTags(t *testing.T) {
 	}
 }
 
+func TestUpdateImageStreamStatusRecordsTagUpdates(t *testing.T) {
+	fakeEtcdClient, helper := newHelper(t)
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/test")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value: runtime.EncodeOrDie(latest.Codec, &api.ImageStream{
+					ObjectMeta: kapi.ObjectMeta{Name: "test", Namespace: "default"},
+					Status: api.ImageStreamStatus{
+						Tags: map[string]api.TagEventList{
+							"stable": {Items: []api.TagEvent{{DockerImageReference: "test@sha256:old", Image: "sha256:old"}}},
+							"dev":    {Items: []api.TagEvent{{DockerImageReference: "test@sha256:dev", Image: "sha256:dev"}}},
+						},
+					},
+				}),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+	recorder := &record.FakeRecorder{}
+	_, status, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, recorder)
+
+	stream := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Name: "test", Namespace: "default", ResourceVersion: "1"},
+		Status: api.ImageStreamStatus{
+			Tags: map[string]api.TagEventList{
+				"stable": {Items: []api.TagEvent{{DockerImageReference: "test@sha256:new", Image: "sha256:new"}, {DockerImageReference: "test@sha256:old", Image: "sha256:old"}}},
+				"dev":    {Items: []api.TagEvent{{DockerImageReference: "test@sha256:dev", Image: "sha256:dev"}}},
+				"latest": {Items: []api.TagEvent{{DockerImageReference: "test@sha256:new", Image: "sha256:new"}}},
+			},
+		},
+	}
+	ctx := kapi.WithUser(kapi.NewDefaultContext(), &fakeUser{})
+	if _, _, err := status.Update(ctx, stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"TagUpdated Tag latest set to image sha256:new by user",
+		"TagUpdated Tag stable updated from image sha256:old to sha256:new by user",
+	}
+	if !reflect.DeepEqual(recorder.Events, expected) {
+		t.Errorf("expected events %v, got %v", expected, recorder.Events)
+	}
+}
+
 func TestDeleteImageStream(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/foo")] = tools.EtcdResponseWithError{
@@ -616,7 +663,7 @@ func TestDeleteImageStream(t *testing.T) {
 			},
 		},
 	}
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	obj, err := storage.Delete(kapi.NewDefaultContext(), "foo", nil)
 	if err != nil {
@@ -631,6 +678,68 @@ func TestDeleteImageStream(t *testing.T) {
 	}
 }
 
+func TestDeleteImageStreamGracePeriod(t *testing.T) {
+	fakeEtcdClient, helper := newHelper(t)
+	key := etcdtest.AddPrefix("/imagestreams/default/foo")
+	fakeEtcdClient.Data[key] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value: runtime.EncodeOrDie(latest.Codec, &api.ImageStream{
+					ObjectMeta: kapi.ObjectMeta{Name: "foo", Namespace: "default"},
+				}),
+				ModifiedIndex: 2,
+			},
+		},
+	}
+	storage, _, _, restore := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 60, nil)
+	ctx := kapi.NewDefaultContext()
+
+	obj, err := storage.Delete(ctx, "foo", &kapi.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected non-nil error: %#v", err)
+	}
+	stream, ok := obj.(*api.ImageStream)
+	if !ok {
+		t.Fatalf("Expected the image stream to be retained, got %#v", obj)
+	}
+	if stream.DeletionTimestamp == nil || stream.DeletionGracePeriodSeconds == nil || *stream.DeletionGracePeriodSeconds != 60 {
+		t.Fatalf("Expected the image stream to be deleted in 60 seconds, got %#v", stream.ObjectMeta)
+	}
+
+	// the name of the image stream being deleted is taken until it is removed
+	_, err = storage.Create(kapi.WithUser(ctx, &fakeUser{}), &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "foo"}})
+	if !errors.IsAlreadyExists(err) || !strings.Contains(err.Error(), "oadm restore imagestream foo") {
+		t.Errorf("Expected an AlreadyExists error telling how to restore the image stream, got %v", err)
+	}
+
+	// deleting again during the grace period leaves the image stream as is
+	if _, err := storage.Delete(ctx, "foo", &kapi.DeleteOptions{}); err != nil {
+		t.Fatalf("Unexpected non-nil error: %#v", err)
+	}
+	if _, ok := fakeEtcdClient.Data[key]; !ok {
+		t.Fatalf("Expected the image stream to be retained")
+	}
+
+	obj, _, err = restore.Update(ctx, &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "foo", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Unexpected non-nil error: %#v", err)
+	}
+	if stream := obj.(*api.ImageStream); stream.DeletionTimestamp != nil || stream.DeletionGracePeriodSeconds != nil {
+		t.Errorf("Expected the image stream to be restored, got %#v", stream.ObjectMeta)
+	}
+	if _, _, err := restore.Update(ctx, &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "foo", Namespace: "default"}}); !errors.IsBadRequest(err) {
+		t.Errorf("Expected a bad request restoring an image stream not being deleted, got %v", err)
+	}
+
+	obj, err = storage.Delete(ctx, "foo", kapi.NewDeleteOptions(0))
+	if err != nil {
+		t.Fatalf("Unexpected non-nil error: %#v", err)
+	}
+	if status, ok := obj.(*unversioned.Status); !ok || status.Status != unversioned.StatusSuccess {
+		t.Errorf("Expected the image stream to be deleted immediately, got %#v", obj)
+	}
+}
+
 func TestUpdateImageStreamConflictingNamespace(t *testing.T) {
 	fakeEtcdClient, helper := newHelper(t)
 	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/legal-name/bar")] = tools.EtcdResponseWithError{
@@ -643,7 +752,7 @@ func TestUpdateImageStreamConflictingNamespace(t *testing.T) {
 			},
 		},
 	}
-	storage, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, noDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 
 	ctx := kapi.WithUser(kapi.WithNamespace(kapi.NewContext(), "legal-name"), &fakeUser{})
 	obj, created, err := storage.Update(ctx, &api.ImageStream{
@@ -1176,7 +1285,7 @@ func (fakeStrategy) PrepareForUpdate(obj, old runtime.Object) {
 
 func TestStrategyPrepareMethods(t *testing.T) {
 	_, helper := newHelper(t)
-	storage, _, _ := NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	storage, _, _, _ := NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 	stream := validNewStream()
 	strategy := fakeStrategy{imagestream.NewStrategy(testDefaultRegistry, &fakeSubjectAccessReviewRegistry{})}
 
@@ -0,0 +1,65 @@
+package imagestream
+
+import (
+	"sort"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/record"
+
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+// TagUpdatedReason is the reason of the events recorded on an image stream
+// when one of its tags references a new image.
+const TagUpdatedReason = "TagUpdated"
+
+// RecordTagUpdates records an event on stream for every tag whose latest image
+// differs from the one of the same tag in old, which is nil if stream was just
+// created. The events name the user of ctx, and the previous and new images.
+func RecordTagUpdates(recorder record.EventRecorder, ctx kapi.Context, old, stream *api.ImageStream) {
+	if recorder == nil {
+		return
+	}
+	userName := "unknown user"
+	if user, ok := kapi.UserFrom(ctx); ok && len(user.GetName()) > 0 {
+		userName = user.GetName()
+	}
+	ref := &kapi.ObjectReference{
+		Kind:            "ImageStream",
+		Namespace:       stream.Namespace,
+		Name:            stream.Name,
+		UID:             stream.UID,
+		ResourceVersion: stream.ResourceVersion,
+	}
+
+	tags := make([]string, 0, len(stream.Status.Tags))
+	for tag := range stream.Status.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		next := api.LatestTaggedImage(stream, tag)
+		if next == nil {
+			continue
+		}
+		var previous *api.TagEvent
+		if old != nil {
+			previous = api.LatestTaggedImage(old, tag)
+		}
+		switch {
+		case previous == nil:
+			recorder.Eventf(ref, TagUpdatedReason, "Tag %s set to image %s by %s", tag, taggedImage(next), userName)
+		case previous.Image != next.Image || previous.DockerImageReference != next.DockerImageReference:
+			recorder.Eventf(ref, TagUpdatedReason, "Tag %s updated from image %s to %s by %s", tag, taggedImage(previous), taggedImage(next), userName)
+		}
+	}
+}
+
+// taggedImage returns the name of the image of event, which is its digest, or
+// its pull spec if it has no image.
+func taggedImage(event *api.TagEvent) string {
+	if len(event.Image) > 0 {
+		return event.Image
+	}
+	return event.DockerImageReference
+}
@@ -60,6 +60,7 @@ func (s Strategy) PrepareForCreate(obj runtime.Object) {
 		DockerImageRepository: s.dockerImageRepository(stream),
 		Tags: make(map[string]api.TagEventList),
 	}
+	stream.Generation = 1
 }
 
 // Validate validates a new image stream.
@@ -153,7 +154,7 @@ func (s Strategy) tagsChanged(old, stream *api.ImageStream) fielderrors.Validati
 		}
 
 		if tagRef.From.Kind == "DockerImage" && len(tagRef.From.Name) > 0 {
-			if tagRef.Reference {
+			if api.IsTagReferenceOnly(tagRef) {
 				event, err := tagReferenceToTagEvent(stream, tagRef, "")
 				if err != nil {
 					errs = append(errs, fielderrors.NewFieldInvalid(fmt.Sprintf("spec.tags[%s].from", tag), tagRef.From, err.Error()))
@@ -206,6 +207,7 @@ func (s Strategy) tagsChanged(old, stream *api.ImageStream) fielderrors.Validati
 	if old != nil {
 		api.UpdateChangedTrackingTags(stream, old)
 	}
+	api.UpdateVersionTrackingTags(stream)
 
 	// use a consistent timestamp on creation
 	if old == nil && !stream.CreationTimestamp.IsZero() {
@@ -323,6 +325,17 @@ func (s Strategy) PrepareForUpdate(obj, old runtime.Object) {
 
 	stream.Status = oldStream.Status
 	stream.Status.DockerImageRepository = s.dockerImageRepository(stream)
+	updateGeneration(stream, oldStream)
+}
+
+// updateGeneration increments the generation of stream if its spec differs
+// from the spec of oldStream. The conditions of the tags record the generation
+// their imports were attempted for.
+func updateGeneration(stream, oldStream *api.ImageStream) {
+	stream.Generation = oldStream.Generation
+	if !kapi.Semantic.DeepEqual(stream.Spec, oldStream.Spec) {
+		stream.Generation++
+	}
 }
 
 // ValidateUpdate is the default update validation for an end user.
@@ -361,6 +374,7 @@ func NewStatusStrategy(strategy Strategy) StatusStrategy {
 }
 
 func (StatusStrategy) PrepareForUpdate(obj, old runtime.Object) {
+	obj.(*api.ImageStream).Generation = old.(*api.ImageStream).Generation
 }
 
 func (StatusStrategy) AllowUnconditionalUpdate() bool {
@@ -372,18 +386,46 @@ func (StatusStrategy) ValidateUpdate(ctx kapi.Context, obj, old runtime.Object)
 	return validation.ValidateImageStreamStatusUpdate(obj.(*api.ImageStream), old.(*api.ImageStream))
 }
 
-// MatchImageStream returns a generic matcher for a given label and field selector.
+// MatchImageStream returns a generic matcher for a given label and field
+// selector. The image streams being deleted only match a field selector on
+// api.ImageStreamDeletedField, so that they look removed to their users until
+// they are restored.
 func MatchImageStream(label labels.Selector, field fields.Selector) generic.Matcher {
+	hideDeleted := !selectsField(field, api.ImageStreamDeletedField)
 	return generic.MatcherFunc(func(obj runtime.Object) (bool, error) {
 		ir, ok := obj.(*api.ImageStream)
 		if !ok {
 			return false, fmt.Errorf("not an ImageStream")
 		}
+		if hideDeleted && ir.DeletionTimestamp != nil {
+			return false, nil
+		}
 		fields := api.ImageStreamToSelectableFields(ir)
 		return label.Matches(labels.Set(ir.Labels)) && field.Matches(fields), nil
 	})
 }
 
+// selectsField returns true if a term of selector selects on name.
+func selectsField(selector fields.Selector, name string) bool {
+	if selector == nil {
+		return false
+	}
+	found := false
+	selector.Transform(func(field, value string) (string, string, error) {
+		if field == name {
+			found = true
+		}
+		return field, value, nil
+	})
+	return found
+}
+
+// NewDeletedError returns the error of a change to stream, which is being
+// deleted: it only accepts to be restored or removed.
+func NewDeletedError(kind, name string, stream *api.ImageStream) error {
+	return kerrors.NewForbidden(kind, name, fmt.Errorf("image stream %s/%s is being deleted, it must be restored with \"oadm restore imagestream %s\" first", stream.Namespace, stream.Name, stream.Name))
+}
+
 // DefaultRegistry returns the default Docker registry (host or host:port), or false if it is not available.
 type DefaultRegistry interface {
 	DefaultRegistry() (string, bool)
@@ -410,6 +452,7 @@ func NewInternalStrategy(strategy Strategy) InternalStrategy {
 }
 
 func (InternalStrategy) PrepareForUpdate(obj, old runtime.Object) {
+	updateGeneration(obj.(*api.ImageStream), old.(*api.ImageStream))
 }
 
 func (InternalStrategy) AllowUnconditionalUpdate() bool {
@@ -419,3 +462,32 @@ func (InternalStrategy) AllowUnconditionalUpdate() bool {
 func (InternalStrategy) ValidateUpdate(ctx kapi.Context, obj, old runtime.Object) fielderrors.ValidationErrorList {
 	return validation.ValidateImageStreamUpdate(obj.(*api.ImageStream), old.(*api.ImageStream))
 }
+
+// DeleteStrategy implements the graceful deletion of image streams. A deleted
+// image stream is kept for the grace period, marked with the time it will be
+// removed, so that its deletion can be undone through the restore subresource.
+// The images it references are not pruned until it is removed.
+type DeleteStrategy struct {
+	runtime.ObjectTyper
+	gracePeriodSeconds int64
+}
+
+// NewDeleteStrategy creates a deletion strategy retaining the deleted image
+// streams for gracePeriodSeconds, or removing them immediately if it is 0.
+func NewDeleteStrategy(gracePeriodSeconds int64) DeleteStrategy {
+	return DeleteStrategy{ObjectTyper: kapi.Scheme, gracePeriodSeconds: gracePeriodSeconds}
+}
+
+// CheckGracefulDelete returns true if the deletion of an image stream has a
+// grace period, which defaults to the one of the strategy. A grace period is
+// only honored if the strategy has one.
+func (s DeleteStrategy) CheckGracefulDelete(obj runtime.Object, options *kapi.DeleteOptions) bool {
+	if s.gracePeriodSeconds <= 0 {
+		return false
+	}
+	if options.GracePeriodSeconds == nil {
+		period := s.gracePeriodSeconds
+		options.GracePeriodSeconds = &period
+	}
+	return *options.GracePeriodSeconds > 0
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/openshift/origin/pkg/authorization/registry/subjectaccessreview"
 	"github.com/openshift/origin/pkg/image/api"
 	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/auth/user"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
 	"k8s.io/kubernetes/pkg/runtime"
 	"k8s.io/kubernetes/pkg/util"
 	"k8s.io/kubernetes/pkg/util/fielderrors"
@@ -126,6 +129,33 @@ func TestDockerImageRepository(t *testing.T) {
 	}
 }
 
+func TestGeneration(t *testing.T) {
+	strategy := NewStrategy(&fakeDefaultRegistry{}, &fakeSubjectAccessReviewRegistry{})
+	stream := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "somerepo", Generation: 5}}
+	strategy.PrepareForCreate(stream)
+	if stream.Generation != 1 {
+		t.Fatalf("expected generation 1 on creation, got %d", stream.Generation)
+	}
+
+	updated := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "somerepo"}}
+	strategy.PrepareForUpdate(updated, stream)
+	if updated.Generation != 1 {
+		t.Errorf("expected the generation to be kept if the spec is unchanged, got %d", updated.Generation)
+	}
+
+	updated = &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "somerepo"}, Spec: api.ImageStreamSpec{TagHistoryLimit: 1}}
+	strategy.PrepareForUpdate(updated, stream)
+	if updated.Generation != 2 {
+		t.Errorf("expected the generation to be incremented if the spec changes, got %d", updated.Generation)
+	}
+
+	updated.Generation = 10
+	NewStatusStrategy(strategy).PrepareForUpdate(updated, stream)
+	if updated.Generation != 1 {
+		t.Errorf("expected the generation to be kept by a status update, got %d", updated.Generation)
+	}
+}
+
 func TestTagVerifier(t *testing.T) {
 	tests := map[string]struct {
 		oldTags    map[string]api.TagReference
@@ -373,6 +403,27 @@ func TestTagsChanged(t *testing.T) {
 				}},
 			},
 		},
+		"new tag in the reference import mode": {
+			stream:   "registry:5000/ns/stream",
+			previous: map[string]api.TagReference{},
+			tags: map[string]api.TagReference{
+				"t1": {
+					From: &kapi.ObjectReference{
+						Kind: "DockerImage",
+						Name: "docker.io/library/ruby:2.2",
+					},
+					ImportPolicy: api.TagImportPolicy{ImportMode: api.ReferenceTagImportMode},
+				},
+			},
+			existingTagHistory: make(map[string]api.TagEventList),
+			expectedTagHistory: map[string]api.TagEventList{
+				"t1": {Items: []api.TagEvent{
+					{
+						DockerImageReference: "docker.io/library/ruby:2.2",
+					},
+				}},
+			},
+		},
 		"empty tag ignored on create": {
 			stream:             "registry:5000/ns/stream",
 			tags:               map[string]api.TagReference{"t1": {}},
@@ -1050,3 +1101,41 @@ func TestTagRefChanged(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchImageStreamDeleted(t *testing.T) {
+	now := unversioned.Now()
+	stream := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "foo"}}
+	deleted := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: "bar", DeletionTimestamp: &now}}
+
+	tests := map[string]struct {
+		field           fields.Selector
+		expectedStream  bool
+		expectedDeleted bool
+	}{
+		"everything hides deleted streams": {
+			field:          fields.Everything(),
+			expectedStream: true,
+		},
+		"other fields hide deleted streams": {
+			field: fields.OneTermEqualSelector("metadata.name", "bar"),
+		},
+		"including deleted streams": {
+			field:           api.ImageStreamsIncludingDeleted(),
+			expectedStream:  true,
+			expectedDeleted: true,
+		},
+		"deleted streams only": {
+			field:           api.DeletedImageStreams(),
+			expectedDeleted: true,
+		},
+	}
+	for name, test := range tests {
+		matcher := MatchImageStream(labels.Everything(), test.field)
+		if ok, err := matcher.Matches(stream); err != nil || ok != test.expectedStream {
+			t.Errorf("%s: expected the stream to match %t, got %t (%v)", name, test.expectedStream, ok, err)
+		}
+		if ok, err := matcher.Matches(deleted); err != nil || ok != test.expectedDeleted {
+			t.Errorf("%s: expected the deleted stream to match %t, got %t (%v)", name, test.expectedDeleted, ok, err)
+		}
+	}
+}
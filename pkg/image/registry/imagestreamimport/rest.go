@@ -0,0 +1,553 @@
+package imagestreamimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/rest"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/fielderrors"
+	"k8s.io/kubernetes/pkg/util/sets"
+
+	"github.com/openshift/origin/pkg/dockerregistry"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/api/validation"
+	"github.com/openshift/origin/pkg/image/quota"
+	"github.com/openshift/origin/pkg/image/registry/image"
+	"github.com/openshift/origin/pkg/image/registry/imagestream"
+)
+
+const (
+	// maxRetriesOnConflict is the maximum retry count for the updates of the
+	// image stream which result in resource conflicts.
+	maxRetriesOnConflict = 10
+	// maxRepositoryTags is the maximum number of tags of a repository imported
+	// by a single import.
+	maxRepositoryTags = 50
+)
+
+// REST implements the RESTStorage interface in terms of an image registry and
+// image stream registry. It only supports the Create method, which imports the
+// images from their remote registries and returns the outcome of every import.
+type REST struct {
+	imageRegistry       image.Registry
+	imageStreamRegistry imagestream.Registry
+	secrets             kclient.SecretsNamespacer
+	limitRanges         kclient.LimitRangesNamespacer
+	quotas              kclient.ResourceQuotasNamespacer
+	newClient           func(dockerregistry.Credentials) dockerregistry.Client
+}
+
+// NewREST returns a new REST importing the images with the clients returned
+// by newClient, given the credentials of the docker registry secrets of the
+// namespace of the import. The images imported are limited in size by the
+// LimitRanges of the namespace, the streams and images created by the
+// ResourceQuotas of the namespace.
+func NewREST(imageRegistry image.Registry, imageStreamRegistry imagestream.Registry, secrets kclient.SecretsNamespacer, limitRanges kclient.LimitRangesNamespacer, quotas kclient.ResourceQuotasNamespacer, newClient func(dockerregistry.Credentials) dockerregistry.Client) *REST {
+	return &REST{
+		imageRegistry:       imageRegistry,
+		imageStreamRegistry: imageStreamRegistry,
+		secrets:             secrets,
+		limitRanges:         limitRanges,
+		quotas:              quotas,
+		newClient:           newClient,
+	}
+}
+
+// imageStreamImportStrategy implements behavior for image stream imports.
+type imageStreamImportStrategy struct {
+	runtime.ObjectTyper
+	kapi.NameGenerator
+}
+
+// Strategy is the default logic that applies when creating ImageStreamImport
+// objects via the REST API.
+var Strategy = imageStreamImportStrategy{kapi.Scheme, kapi.SimpleNameGenerator}
+
+// New returns a new ImageStreamImport for use with Create.
+func (r *REST) New() runtime.Object {
+	return &api.ImageStreamImport{}
+}
+
+// NamespaceScoped is true for image stream imports.
+func (s imageStreamImportStrategy) NamespaceScoped() bool {
+	return true
+}
+
+// PrepareForCreate clears the status, which is the outcome of the import.
+func (s imageStreamImportStrategy) PrepareForCreate(obj runtime.Object) {
+	isi := obj.(*api.ImageStreamImport)
+	isi.Status = api.ImageStreamImportStatus{}
+}
+
+// Validate validates a new ImageStreamImport.
+func (s imageStreamImportStrategy) Validate(ctx kapi.Context, obj runtime.Object) fielderrors.ValidationErrorList {
+	isi := obj.(*api.ImageStreamImport)
+	return validation.ValidateImageStreamImport(isi)
+}
+
+// Create imports the images of the spec of the ImageStreamImport and records
+// the outcome of every import in its status. The failure of an import doesn't
+// fail the others. If spec.import is true, the images imported are created and
+// tagged in the image stream, which is created if it doesn't exist and can't
+// be being deleted.
+func (r *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, error) {
+	if err := rest.BeforeCreate(Strategy, ctx, obj); err != nil {
+		return nil, err
+	}
+	isi := obj.(*api.ImageStreamImport)
+
+	stream, err := r.imageStreamRegistry.GetImageStream(ctx, isi.Name)
+	switch {
+	case errors.IsNotFound(err):
+		stream = nil
+	case err != nil:
+		return nil, err
+	}
+	if isi.Spec.Import && stream != nil && stream.DeletionTimestamp != nil {
+		return nil, imagestream.NewDeletedError("imageStreamImport", isi.Name, stream)
+	}
+
+	insecure := isi.Annotations[api.InsecureRepositoryAnnotation] == "true"
+	if stream != nil && stream.Annotations[api.InsecureRepositoryAnnotation] == "true" {
+		insecure = true
+	}
+	credentials, err := dockerregistry.CredentialsForNamespace(r.secrets, kapi.NamespaceValue(ctx))
+	if err != nil {
+		return nil, errors.NewInternalError(fmt.Errorf("unable to read the docker registry secrets: %v", err))
+	}
+	importer := newImporter(r.newClient(credentials), insecure)
+
+	if isi.Spec.Repository != nil {
+		isi.Status.Repository = importer.importRepository(isi.Spec.Repository.From.Name)
+	}
+	for _, spec := range isi.Spec.Images {
+		status := importer.importImage(spec.From.Name, spec.ImportPolicy.Insecure)
+		if spec.To != nil {
+			status.Tag = spec.To.Name
+		}
+		isi.Status.Images = append(isi.Status.Images, status)
+	}
+
+	if !isi.Spec.Import {
+		return isi, nil
+	}
+	if err := r.admitImageSizes(ctx, isi); err != nil {
+		return nil, err
+	}
+	if err := r.admitImageQuota(ctx, isi, stream); err != nil {
+		return nil, err
+	}
+	if stream, err = r.importIntoStream(ctx, isi, stream); err != nil {
+		return nil, err
+	}
+	isi.Status.Import = stream
+	return isi, nil
+}
+
+// admitImageSizes fails the imports of the images to be tagged by isi which
+// are larger than the "storage" maximum of an "openshift.io/Image" LimitRange
+// of the namespace, or whose size isn't known if the size of the images is
+// limited, so that they are neither created nor tagged.
+func (r *REST) admitImageSizes(ctx kapi.Context, isi *api.ImageStreamImport) error {
+	limitRanges, err := r.limitRanges.LimitRanges(kapi.NamespaceValue(ctx)).List(labels.Everything(), fields.Everything())
+	if err != nil {
+		return err
+	}
+	max, limitRange, ok := api.ImageSizeLimit(limitRanges.Items)
+	if !ok {
+		return nil
+	}
+	admit := func(status *api.ImageImportStatus) {
+		if status.Image == nil || len(status.Tag) == 0 {
+			return
+		}
+		message := ""
+		if size, ok := api.ImageSize(status.Image); !ok {
+			message = fmt.Sprintf("the size of image %s isn't known, limit range %q limits the images to %d bytes", status.Image.Name, limitRange, max)
+		} else if size > max {
+			message = fmt.Sprintf("image %s is %d bytes, larger than the maximum of %d bytes set by limit range %q", status.Image.Name, size, max, limitRange)
+		} else {
+			return
+		}
+		glog.V(4).Infof("Denying the import of %s into %s/%s: %s", status.Image.DockerImageReference, isi.Namespace, isi.Name, message)
+		status.Image = nil
+		status.Status = unversioned.Status{
+			Status:  unversioned.StatusFailure,
+			Message: message,
+			Reason:  unversioned.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		}
+	}
+	if isi.Status.Repository != nil {
+		for i := range isi.Status.Repository.Images {
+			admit(&isi.Status.Repository.Images[i])
+		}
+	}
+	for i := range isi.Status.Images {
+		admit(&isi.Status.Images[i])
+	}
+	return nil
+}
+
+// admitImageQuota reserves the usage of the image stream created by isi, if
+// stream is nil, and of the images it tags which stream doesn't reference yet
+// in the ResourceQuotas of the namespace. The whole import is forbidden if the
+// stream can't be created, the imports of the images exceeding a quota are
+// failed, so that they are neither created nor tagged.
+func (r *REST) admitImageQuota(ctx kapi.Context, isi *api.ImageStreamImport, stream *api.ImageStream) error {
+	namespace := kapi.NamespaceValue(ctx)
+	referenced := sets.NewString()
+	if stream == nil {
+		if err := quota.Reserve(r.quotas, namespace, api.ResourceImageStreams, 1); err != nil {
+			return errors.NewForbidden("imageStreamImport", isi.Name, err)
+		}
+	} else {
+		referenced = quota.ReferencedImages([]api.ImageStream{*stream})
+	}
+
+	admit := func(status *api.ImageImportStatus) {
+		if status.Image == nil || len(status.Tag) == 0 || referenced.Has(status.Image.Name) {
+			return
+		}
+		err := quota.Reserve(r.quotas, namespace, api.ResourceImages, 1)
+		if err == nil {
+			referenced.Insert(status.Image.Name)
+			return
+		}
+		glog.V(4).Infof("Denying the import of %s into %s/%s: %v", status.Image.DockerImageReference, isi.Namespace, isi.Name, err)
+		status.Image = nil
+		status.Status = unversioned.Status{
+			Status:  unversioned.StatusFailure,
+			Message: err.Error(),
+			Reason:  unversioned.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		}
+	}
+	if isi.Status.Repository != nil {
+		for i := range isi.Status.Repository.Images {
+			admit(&isi.Status.Repository.Images[i])
+		}
+	}
+	for i := range isi.Status.Images {
+		admit(&isi.Status.Images[i])
+	}
+	return nil
+}
+
+// importIntoStream creates the images imported successfully by isi and tags
+// them in stream, which is created if it's nil. The images of the tags of the
+// repository are only tagged in the status of the stream, whose
+// spec.dockerImageRepository is set to the repository, the images of the spec
+// are tagged in the spec too.
+func (r *REST) importIntoStream(ctx kapi.Context, isi *api.ImageStreamImport, stream *api.ImageStream) (*api.ImageStream, error) {
+	var imported []api.ImageImportStatus
+	if isi.Status.Repository != nil {
+		imported = append(imported, isi.Status.Repository.Images...)
+	}
+	imported = append(imported, isi.Status.Images...)
+
+	updateSpec := func(stream *api.ImageStream) {
+		if stream.Annotations == nil {
+			stream.Annotations = make(map[string]string)
+		}
+		// the images are imported, the import controller mustn't import them again
+		stream.Annotations[api.DockerImageRepositoryCheckAnnotation] = unversioned.Now().UTC().Format(time.RFC3339)
+		if isi.Annotations[api.InsecureRepositoryAnnotation] == "true" {
+			stream.Annotations[api.InsecureRepositoryAnnotation] = "true"
+		}
+		if isi.Spec.Repository != nil {
+			stream.Spec.DockerImageRepository = isi.Spec.Repository.From.Name
+		}
+		if stream.Spec.Tags == nil {
+			stream.Spec.Tags = make(map[string]api.TagReference)
+		}
+		for i, spec := range isi.Spec.Images {
+			if spec.To == nil || isi.Status.Images[i].Image == nil {
+				continue
+			}
+			tagRef := stream.Spec.Tags[spec.To.Name]
+			tagRef.From = &kapi.ObjectReference{Kind: "DockerImage", Name: spec.From.Name}
+			tagRef.Reference = false
+			tagRef.ImportPolicy = spec.ImportPolicy
+			stream.Spec.Tags[spec.To.Name] = tagRef
+		}
+	}
+
+	var err error
+	if stream == nil {
+		stream = &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Name: isi.Name, Namespace: isi.Namespace}}
+		updateSpec(stream)
+		stream, err = r.imageStreamRegistry.CreateImageStream(ctx, stream)
+	} else {
+		stream, err = r.updateImageStream(ctx, stream, func(stream *api.ImageStream) bool {
+			updateSpec(stream)
+			return true
+		}, r.imageStreamRegistry.UpdateImageStream)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(map[string]api.TagEvent)
+	for _, status := range imported {
+		if status.Image == nil || len(status.Tag) == 0 {
+			continue
+		}
+		image := *status.Image
+		if err := r.imageRegistry.CreateImage(ctx, &image); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		events[status.Tag] = api.TagEvent{
+			Created:              unversioned.Now(),
+			DockerImageReference: image.DockerImageReference,
+			Image:                image.Name,
+		}
+	}
+	now := unversioned.Now()
+	return r.updateImageStream(ctx, stream, func(stream *api.ImageStream) bool {
+		changed := false
+		for tag, next := range events {
+			// the image of an immutable tag is never replaced by an import,
+			// the one of a tag tracking versions is set below
+			if api.IsTagImmutable(stream, tag) || len(stream.Spec.Tags[tag].Track) > 0 {
+				continue
+			}
+			if api.AddTagEventToImageStream(stream, tag, next) {
+				api.UpdateTrackingTags(stream, tag, next)
+				changed = true
+			}
+		}
+		if api.UpdateVersionTrackingTags(stream) > 0 {
+			changed = true
+		}
+		// record the outcome of the imports of the images into tags
+		for _, status := range isi.Status.Images {
+			switch {
+			case len(status.Tag) == 0:
+			case status.Image != nil:
+				if api.ClearTagImportFailed(stream, status.Tag) {
+					changed = true
+				}
+			default:
+				api.SetTagImportFailed(stream, status.Tag, importFailureReason(status.Status), status.Status.Message, now)
+				changed = true
+			}
+		}
+		return changed
+	}, r.imageStreamRegistry.UpdateImageStreamStatus)
+}
+
+// updateImageStream applies change to stream and saves it with update, if
+// change returns true. On a conflict, the latest stream is read and the change
+// applied again.
+func (r *REST) updateImageStream(ctx kapi.Context, stream *api.ImageStream, change func(*api.ImageStream) bool, update func(kapi.Context, *api.ImageStream) (*api.ImageStream, error)) (*api.ImageStream, error) {
+	for i := 0; ; i++ {
+		if !change(stream) {
+			return stream, nil
+		}
+		updated, err := update(ctx, stream)
+		if err == nil {
+			return updated, nil
+		}
+		if !errors.IsConflict(err) || i >= maxRetriesOnConflict {
+			return nil, err
+		}
+		if stream, err = r.imageStreamRegistry.GetImageStream(ctx, stream.Name); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// importer imports images from their remote registries, reusing the
+// connections to the registries.
+type importer struct {
+	client      dockerregistry.Client
+	insecure    bool
+	connections map[connectionKey]dockerregistry.Connection
+}
+
+// connectionKey identifies a connection to a registry, insecure connections
+// being kept apart from the secure ones to the same registry.
+type connectionKey struct {
+	registry string
+	insecure bool
+}
+
+func newImporter(client dockerregistry.Client, insecure bool) *importer {
+	return &importer{
+		client:      client,
+		insecure:    insecure,
+		connections: make(map[connectionKey]dockerregistry.Connection),
+	}
+}
+
+// connect returns a connection to registry, which is insecure if insecure is
+// true or if all the imports of the importer are insecure.
+func (i *importer) connect(registry string, insecure bool) (dockerregistry.Connection, error) {
+	key := connectionKey{registry: registry, insecure: insecure || i.insecure}
+	if conn, ok := i.connections[key]; ok {
+		return conn, nil
+	}
+	conn, err := i.client.Connect(key.registry, key.insecure)
+	if err != nil {
+		return nil, err
+	}
+	i.connections[key] = conn
+	return conn, nil
+}
+
+// importRepository imports the images of the tags of the repository from,
+// at most maxRepositoryTags of them, in the order of the tags.
+func (i *importer) importRepository(from string) *api.RepositoryImportStatus {
+	status := &api.RepositoryImportStatus{}
+	ref, err := api.ParseDockerImageReference(from)
+	if err != nil {
+		status.Status = importStatus(err)
+		return status
+	}
+	ref = ref.DockerClientDefaults()
+	conn, err := i.connect(ref.Registry, false)
+	if err != nil {
+		status.Status = importStatus(err)
+		return status
+	}
+	tags, err := conn.ImageTags(ref.Namespace, ref.Name)
+	if err != nil {
+		status.Status = importStatus(err)
+		return status
+	}
+	status.Status = importStatus(nil)
+
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	if len(names) > maxRepositoryTags {
+		glog.V(2).Infof("Importing only %d of the %d tags of %s", maxRepositoryTags, len(names), from)
+		names = names[:maxRepositoryTags]
+	}
+	for _, tag := range names {
+		tagRef := ref
+		tagRef.Tag = tag
+		image := i.importReference(tagRef, false)
+		image.Tag = tag
+		status.Images = append(status.Images, image)
+	}
+	return status
+}
+
+// importImage imports the image from, over an insecure connection if insecure
+// is true.
+func (i *importer) importImage(from string, insecure bool) api.ImageImportStatus {
+	ref, err := api.ParseDockerImageReference(from)
+	if err != nil {
+		return api.ImageImportStatus{Status: importStatus(err)}
+	}
+	return i.importReference(ref.DockerClientDefaults(), insecure)
+}
+
+// importReference imports the image ref, by ID if it's set, by tag otherwise.
+func (i *importer) importReference(ref api.DockerImageReference, insecure bool) api.ImageImportStatus {
+	conn, err := i.connect(ref.Registry, insecure)
+	if err != nil {
+		return api.ImageImportStatus{Status: importStatus(err)}
+	}
+	var dockerImage *dockerregistry.Image
+	if len(ref.ID) > 0 {
+		dockerImage, err = conn.ImageByID(ref.Namespace, ref.Name, ref.ID)
+	} else {
+		dockerImage, err = conn.ImageByTag(ref.Namespace, ref.Name, ref.Tag)
+	}
+	if err != nil {
+		return api.ImageImportStatus{Status: importStatus(err)}
+	}
+
+	var metadata api.DockerImage
+	if err := kapi.Scheme.Convert(&dockerImage.Image, &metadata); err != nil {
+		return api.ImageImportStatus{Status: importStatus(fmt.Errorf("could not convert image: %v", err))}
+	}
+	// prefer to pull by ID always
+	if dockerImage.PullByID {
+		ref.Tag = ""
+		ref.ID = dockerImage.ID
+	}
+	image := &api.Image{
+		ObjectMeta: kapi.ObjectMeta{
+			Name: dockerImage.ID,
+		},
+		DockerImageReference: ref.String(),
+		DockerImageMetadata:  metadata,
+	}
+	// the sizes of the layers are recorded, so that the size of the image
+	// is known
+	if dockerImage.Layers != nil {
+		layers, err := json.Marshal(dockerImage.Layers)
+		if err != nil {
+			return api.ImageImportStatus{Status: importStatus(err)}
+		}
+		image.Annotations = map[string]string{api.ImageLayersAnnotation: string(layers)}
+	}
+	return api.ImageImportStatus{
+		Status: importStatus(nil),
+		Image:  image,
+	}
+}
+
+// importStatus returns the status of an import that failed with err, or that
+// succeeded if err is nil.
+func importStatus(err error) unversioned.Status {
+	if err == nil {
+		return unversioned.Status{Status: unversioned.StatusSuccess, Code: http.StatusOK}
+	}
+	status := unversioned.Status{
+		Status:  unversioned.StatusFailure,
+		Message: err.Error(),
+		Reason:  unversioned.StatusReasonInternalError,
+		Code:    http.StatusInternalServerError,
+	}
+	switch {
+	case dockerregistry.IsNotFound(err):
+		status.Reason = unversioned.StatusReasonNotFound
+		status.Code = http.StatusNotFound
+	case dockerregistry.IsUnauthorized(err):
+		status.Reason = unversioned.StatusReasonUnauthorized
+		status.Code = http.StatusUnauthorized
+	case dockerregistry.IsRegistryTimeout(err):
+		status.Reason = unversioned.StatusReasonTimeout
+		status.Code = http.StatusGatewayTimeout
+	case dockerregistry.IsRegistryForbidden(err):
+		status.Reason = unversioned.StatusReasonForbidden
+		status.Code = http.StatusForbidden
+	}
+	return status
+}
+
+// importFailureReason returns the reason of the ImportSuccess condition of a
+// tag whose import failed with status.
+func importFailureReason(status unversioned.Status) string {
+	switch status.Reason {
+	case unversioned.StatusReasonNotFound:
+		return api.ImportNotFoundReason
+	case unversioned.StatusReasonUnauthorized:
+		return api.ImportUnauthorizedReason
+	case unversioned.StatusReasonTimeout:
+		return api.ImportTimeoutReason
+	case unversioned.StatusReasonForbidden:
+		return api.ImportForbiddenReason
+	default:
+		return api.ImportFailedReason
+	}
+}
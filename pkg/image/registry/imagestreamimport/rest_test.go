@@ -0,0 +1,418 @@
+package imagestreamimport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/auth/user"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+	kstorage "k8s.io/kubernetes/pkg/storage"
+	etcdstorage "k8s.io/kubernetes/pkg/storage/etcd"
+	"k8s.io/kubernetes/pkg/tools"
+	"k8s.io/kubernetes/pkg/tools/etcdtest"
+
+	"github.com/openshift/origin/pkg/api/latest"
+	authorizationapi "github.com/openshift/origin/pkg/authorization/api"
+	"github.com/openshift/origin/pkg/authorization/registry/subjectaccessreview"
+	"github.com/openshift/origin/pkg/dockerregistry"
+	"github.com/openshift/origin/pkg/image/api"
+	"github.com/openshift/origin/pkg/image/registry/image"
+	imageetcd "github.com/openshift/origin/pkg/image/registry/image/etcd"
+	"github.com/openshift/origin/pkg/image/registry/imagestream"
+	imagestreametcd "github.com/openshift/origin/pkg/image/registry/imagestream/etcd"
+)
+
+var testDefaultRegistry = imagestream.DefaultRegistryFunc(func() (string, bool) { return "defaultregistry:5000", true })
+
+type fakeSubjectAccessReviewRegistry struct {
+}
+
+var _ subjectaccessreview.Registry = &fakeSubjectAccessReviewRegistry{}
+
+func (f *fakeSubjectAccessReviewRegistry) CreateSubjectAccessReview(ctx kapi.Context, subjectAccessReview *authorizationapi.SubjectAccessReview) (*authorizationapi.SubjectAccessReviewResponse, error) {
+	return nil, nil
+}
+
+// fakeDockerRegistryClient serves the images of a single repository, keyed by
+// tag.
+type fakeDockerRegistryClient struct {
+	Images map[string]*dockerregistry.Image
+	// Connections records whether each connection was insecure, in order.
+	Connections []bool
+}
+
+func (f *fakeDockerRegistryClient) Connect(registry string, insecure bool) (dockerregistry.Connection, error) {
+	f.Connections = append(f.Connections, insecure)
+	return f, nil
+}
+
+func (f *fakeDockerRegistryClient) ImageTags(namespace, name string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for tag := range f.Images {
+		tags[tag] = tag
+	}
+	return tags, nil
+}
+
+func (f *fakeDockerRegistryClient) ImageByID(namespace, name, id string) (*dockerregistry.Image, error) {
+	for _, image := range f.Images {
+		if image.ID == id {
+			return image, nil
+		}
+	}
+	return nil, dockerregistry.NewImageNotFoundError(namespace+"/"+name, id, "")
+}
+
+func (f *fakeDockerRegistryClient) ImageByTag(namespace, name, tag string) (*dockerregistry.Image, error) {
+	if image, ok := f.Images[tag]; ok {
+		return image, nil
+	}
+	return nil, dockerregistry.NewImageNotFoundError(namespace+"/"+name, "", tag)
+}
+
+func (f *fakeDockerRegistryClient) ImageManifest(namespace, name, reference string) (string, []byte, error) {
+	return "", nil, dockerregistry.NewImageNotFoundError(namespace+"/"+name, reference, "")
+}
+
+func (f *fakeDockerRegistryClient) ImageLayer(namespace, name, dgst string) (io.ReadCloser, int64, error) {
+	return nil, 0, dockerregistry.NewImageNotFoundError(namespace+"/"+name, dgst, "")
+}
+
+func (f *fakeDockerRegistryClient) ImageLayerExists(namespace, name, dgst string) (bool, error) {
+	return false, fmt.Errorf("not implemented")
+}
+
+func (f *fakeDockerRegistryClient) PutImageLayer(namespace, name, dgst string, content io.Reader, size int64) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeDockerRegistryClient) PutImageManifest(namespace, name, reference string, manifest []byte) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func setup(t *testing.T) (kstorage.Interface, *REST) {
+	fakeEtcdClient := tools.NewFakeEtcdClient(t)
+	fakeEtcdClient.TestIndex = true
+	helper := etcdstorage.NewEtcdStorage(fakeEtcdClient, latest.Codec, etcdtest.PathPrefix())
+	imageStorage, _ := imageetcd.NewREST(helper)
+	imageRegistry := image.NewRegistry(imageStorage)
+	imageStreamStorage, imageStreamStatus, internalStorage, _ := imagestreametcd.NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
+	imageStreamRegistry := imagestream.NewRegistry(imageStreamStorage, imageStreamStatus, internalStorage)
+	client := &fakeDockerRegistryClient{
+		Images: map[string]*dockerregistry.Image{
+			"latest": {Image: docker.Image{ID: "abc123", Config: &docker.Config{}}},
+			"1.0":    {Image: docker.Image{ID: "sha256:def456", Config: &docker.Config{}}, PullByID: true},
+		},
+	}
+	newClient := func(dockerregistry.Credentials) dockerregistry.Client { return client }
+	return helper, NewREST(imageRegistry, imageStreamRegistry, nil, ktestclient.NewSimpleFake(&kapi.LimitRangeList{}), ktestclient.NewSimpleFake(&kapi.ResourceQuotaList{}), newClient)
+}
+
+func testContext() kapi.Context {
+	return kapi.WithUser(kapi.NewDefaultContext(), &user.DefaultInfo{Name: "user"})
+}
+
+func TestCreateLookupOnly(t *testing.T) {
+	_, storage := setup(t)
+
+	obj, err := storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: api.ImageStreamImportSpec{
+			Images: []api.ImageImportSpec{
+				{From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"}},
+				{From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:missing"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	isi := obj.(*api.ImageStreamImport)
+	if len(isi.Status.Images) != 2 {
+		t.Fatalf("expected the outcome of every image, got %#v", isi.Status.Images)
+	}
+	if status := isi.Status.Images[0]; status.Status.Code != http.StatusOK || status.Image == nil || status.Image.Name != "abc123" || status.Image.DockerImageReference != "example.com/ns/app:latest" {
+		t.Errorf("unexpected status of the image found: %#v", status)
+	}
+	if status := isi.Status.Images[1]; status.Status.Code != http.StatusNotFound || status.Image != nil {
+		t.Errorf("unexpected status of the missing image: %#v", status)
+	}
+	if isi.Status.Import != nil {
+		t.Errorf("unexpected import %#v", isi.Status.Import)
+	}
+	if _, err := storage.imageStreamRegistry.GetImageStream(kapi.NewDefaultContext(), "app"); !errors.IsNotFound(err) {
+		t.Errorf("expected the image stream not to be created, got %v", err)
+	}
+}
+
+func TestCreateImport(t *testing.T) {
+	helper, storage := setup(t)
+
+	obj, err := storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: api.ImageStreamImportSpec{
+			Import:     true,
+			Repository: &api.RepositoryImportSpec{From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app"}},
+			Images: []api.ImageImportSpec{
+				{
+					From:         kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"},
+					To:           &kapi.LocalObjectReference{Name: "stable"},
+					ImportPolicy: api.TagImportPolicy{Scheduled: true},
+				},
+				{
+					From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:missing"},
+					To:   &kapi.LocalObjectReference{Name: "missing"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	isi := obj.(*api.ImageStreamImport)
+	if repository := isi.Status.Repository; repository == nil || repository.Status.Code != http.StatusOK || len(repository.Images) != 2 {
+		t.Fatalf("unexpected status of the repository: %#v", repository)
+	}
+	if isi.Status.Import == nil {
+		t.Fatalf("expected the image stream to be returned")
+	}
+
+	stream := &api.ImageStream{}
+	if err := helper.Get(kapi.NewDefaultContext(), "/imagestreams/default/app", stream, false); err != nil {
+		t.Fatalf("unexpected error retrieving the image stream: %v", err)
+	}
+	if stream.Spec.DockerImageRepository != "example.com/ns/app" {
+		t.Errorf("unexpected repository %q", stream.Spec.DockerImageRepository)
+	}
+	if len(stream.Annotations[api.DockerImageRepositoryCheckAnnotation]) == 0 {
+		t.Errorf("expected the image stream to be marked as imported")
+	}
+	if tagRef, ok := stream.Spec.Tags["stable"]; !ok || tagRef.From.Name != "example.com/ns/app:latest" || !tagRef.ImportPolicy.Scheduled {
+		t.Errorf("unexpected spec tag %#v", tagRef)
+	}
+	if _, ok := stream.Spec.Tags["missing"]; ok {
+		t.Errorf("unexpected spec tag for the image that failed to import")
+	}
+	if condition := api.TagImportFailure(stream, "missing"); condition == nil || condition.Reason != api.ImportNotFoundReason || condition.Failures != 1 {
+		t.Errorf("expected the failed import to be recorded, got %#v", condition)
+	}
+	for tag, expected := range map[string]string{"latest": "abc123", "stable": "abc123", "1.0": "sha256:def456"} {
+		if event := api.LatestTaggedImage(stream, tag); event == nil || event.Image != expected {
+			t.Errorf("%s: expected image %s, got %#v", tag, expected, event)
+		}
+	}
+	if event := api.LatestTaggedImage(stream, "1.0"); event.DockerImageReference != "example.com/ns/app@sha256:def456" {
+		t.Errorf("expected the image pullable by ID to be referenced by ID, got %s", event.DockerImageReference)
+	}
+
+	image := &api.Image{}
+	if err := helper.Get(kapi.NewDefaultContext(), "/images/sha256:def456", image, false); err != nil {
+		t.Errorf("unexpected error retrieving the image: %v", err)
+	}
+}
+
+func TestCreateImportImageStreamBeingDeleted(t *testing.T) {
+	helper, storage := setup(t)
+
+	now := unversioned.Now()
+	stream := &api.ImageStream{ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app", DeletionTimestamp: &now}}
+	if err := helper.Create(kapi.NewDefaultContext(), "/imagestreams/default/app", stream, stream, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: api.ImageStreamImportSpec{
+			Import: true,
+			Images: []api.ImageImportSpec{
+				{
+					From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"},
+					To:   &kapi.LocalObjectReference{Name: "stable"},
+				},
+			},
+		},
+	})
+	if !errors.IsForbidden(err) {
+		t.Fatalf("expected a forbidden error importing into an image stream being deleted, got %v", err)
+	}
+	if err := helper.Get(kapi.NewDefaultContext(), "/images/abc123", &api.Image{}, false); err == nil {
+		t.Errorf("expected the image not to be created")
+	}
+}
+
+func TestCreateInsecureTag(t *testing.T) {
+	_, storage := setup(t)
+	client := &fakeDockerRegistryClient{
+		Images: map[string]*dockerregistry.Image{
+			"latest": {Image: docker.Image{ID: "abc123", Config: &docker.Config{}}},
+		},
+	}
+	storage.newClient = func(dockerregistry.Credentials) dockerregistry.Client { return client }
+
+	obj, err := storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: api.ImageStreamImportSpec{
+			Import: true,
+			Images: []api.ImageImportSpec{
+				{
+					From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"},
+					To:   &kapi.LocalObjectReference{Name: "secure"},
+				},
+				{
+					From:         kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:latest"},
+					To:           &kapi.LocalObjectReference{Name: "insecure"},
+					ImportPolicy: api.TagImportPolicy{Insecure: true},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.Connections) != 2 || client.Connections[0] || !client.Connections[1] {
+		t.Errorf("expected a secure then an insecure connection, got %v", client.Connections)
+	}
+	stream := obj.(*api.ImageStreamImport).Status.Import
+	if tagRef := stream.Spec.Tags["insecure"]; !tagRef.ImportPolicy.Insecure {
+		t.Errorf("expected the import policy of the tag to be insecure, got %#v", tagRef)
+	}
+	if tagRef := stream.Spec.Tags["secure"]; tagRef.ImportPolicy.Insecure {
+		t.Errorf("expected the import policy of the tag to be secure, got %#v", tagRef)
+	}
+}
+
+func TestCreateImportSizeLimit(t *testing.T) {
+	helper, storage := setup(t)
+	storage.limitRanges = ktestclient.NewSimpleFake(&kapi.LimitRangeList{
+		Items: []kapi.LimitRange{{
+			ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "sizes"},
+			Spec: kapi.LimitRangeSpec{
+				Limits: []kapi.LimitRangeItem{{
+					Type: api.LimitTypeImage,
+					Max:  kapi.ResourceList{kapi.ResourceStorage: *resource.NewQuantity(1024, resource.BinarySI)},
+				}},
+			},
+		}},
+	})
+	client := &fakeDockerRegistryClient{
+		Images: map[string]*dockerregistry.Image{
+			"small":   {Image: docker.Image{ID: "sha256:small", Config: &docker.Config{}}, Layers: []api.ImageLayerSize{{Name: "sha256:a", Size: 1000}, {Name: "sha256:b", Size: 24}}},
+			"large":   {Image: docker.Image{ID: "sha256:large", Config: &docker.Config{}}, Layers: []api.ImageLayerSize{{Name: "sha256:a", Size: 1025}}},
+			"unknown": {Image: docker.Image{ID: "sha256:unknown", Config: &docker.Config{}}},
+		},
+	}
+	storage.newClient = func(dockerregistry.Credentials) dockerregistry.Client { return client }
+
+	spec := api.ImageStreamImportSpec{Import: true}
+	for _, tag := range []string{"small", "large", "unknown"} {
+		spec.Images = append(spec.Images, api.ImageImportSpec{
+			From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:" + tag},
+			To:   &kapi.LocalObjectReference{Name: tag},
+		})
+	}
+	obj, err := storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec:       spec,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	isi := obj.(*api.ImageStreamImport)
+	if status := isi.Status.Images[0]; status.Image == nil || status.Image.Annotations[api.ImageLayersAnnotation] != `[{"name":"sha256:a","size":1000},{"name":"sha256:b","size":24}]` {
+		t.Errorf("unexpected status of the image within the limit: %#v", status)
+	}
+	for _, status := range isi.Status.Images[1:] {
+		if status.Image != nil || status.Status.Code != http.StatusForbidden {
+			t.Errorf("expected the import of %s to be forbidden, got %#v", status.Tag, status)
+		}
+	}
+
+	stream := isi.Status.Import
+	if event := api.LatestTaggedImage(stream, "small"); event == nil || event.Image != "sha256:small" {
+		t.Errorf("expected the image within the limit to be tagged, got %#v", event)
+	}
+	for _, tag := range []string{"large", "unknown"} {
+		if event := api.LatestTaggedImage(stream, tag); event != nil {
+			t.Errorf("%s: unexpected tag event %#v", tag, event)
+		}
+		if condition := api.TagImportFailure(stream, tag); condition == nil || condition.Reason != api.ImportForbiddenReason {
+			t.Errorf("%s: expected the forbidden import to be recorded, got %#v", tag, condition)
+		}
+	}
+	image := &api.Image{}
+	if err := helper.Get(kapi.NewDefaultContext(), "/images/sha256:large", image, false); err == nil {
+		t.Errorf("expected the image over the limit not to be created")
+	}
+}
+
+func TestCreateImportQuota(t *testing.T) {
+	_, storage := setup(t)
+	current := &kapi.ResourceQuota{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "images"},
+		Spec: kapi.ResourceQuotaSpec{Hard: kapi.ResourceList{
+			api.ResourceImageStreams: *resource.NewQuantity(1, resource.DecimalSI),
+			api.ResourceImages:       *resource.NewQuantity(2, resource.DecimalSI),
+		}},
+		Status: kapi.ResourceQuotaStatus{Used: kapi.ResourceList{
+			api.ResourceImageStreams: *resource.NewQuantity(0, resource.DecimalSI),
+			api.ResourceImages:       *resource.NewQuantity(1, resource.DecimalSI),
+		}},
+	}
+	quotas := ktestclient.NewSimpleFake()
+	quotas.PrependReactor("list", "resourcequotas", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		return true, &kapi.ResourceQuotaList{Items: []kapi.ResourceQuota{*current}}, nil
+	})
+	quotas.PrependReactor("update", "resourcequotas", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		// only the status is updated
+		current.Status = action.(ktestclient.UpdateAction).GetObject().(*kapi.ResourceQuota).Status
+		return true, current, nil
+	})
+	storage.quotas = quotas
+
+	spec := api.ImageStreamImportSpec{Import: true}
+	for _, tag := range []string{"latest", "1.0"} {
+		spec.Images = append(spec.Images, api.ImageImportSpec{
+			From: kapi.ObjectReference{Kind: "DockerImage", Name: "example.com/ns/app:" + tag},
+			To:   &kapi.LocalObjectReference{Name: tag},
+		})
+	}
+	obj, err := storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec:       spec,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	isi := obj.(*api.ImageStreamImport)
+	if status := isi.Status.Images[0]; status.Image == nil {
+		t.Errorf("expected the image within the quota to be imported, got %#v", status)
+	}
+	if status := isi.Status.Images[1]; status.Image != nil || status.Status.Code != http.StatusForbidden {
+		t.Errorf("expected the import of the image over the quota to be forbidden, got %#v", status)
+	}
+	if event := api.LatestTaggedImage(isi.Status.Import, "1.0"); event != nil {
+		t.Errorf("unexpected tag event %#v", event)
+	}
+	if used := current.Status.Used[api.ResourceImageStreams]; used.Value() != 1 {
+		t.Errorf("expected 1 image stream to be used, got %s", used.String())
+	}
+	if used := current.Status.Used[api.ResourceImages]; used.Value() != 2 {
+		t.Errorf("expected 2 images to be used, got %s", used.String())
+	}
+
+	_, err = storage.Create(testContext(), &api.ImageStreamImport{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "other"},
+		Spec:       spec,
+	})
+	if !errors.IsForbidden(err) {
+		t.Errorf("expected the creation of a stream over the quota to be forbidden, got %v", err)
+	}
+}
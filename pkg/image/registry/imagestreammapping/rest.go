@@ -1,6 +1,9 @@
 package imagestreammapping
 
 import (
+	"fmt"
+	"strings"
+
 	kapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/rest"
@@ -26,13 +29,18 @@ const maxRetriesOnConflict = 10
 type REST struct {
 	imageRegistry       image.Registry
 	imageStreamRegistry imagestream.Registry
+	strategy            imageStreamMappingStrategy
 }
 
-// NewREST returns a new REST.
-func NewREST(imageRegistry image.Registry, imageStreamRegistry imagestream.Registry) *REST {
+// NewREST returns a new REST. The manifests of the images mapped can't be
+// larger than maxManifestSize bytes, unless it is 0.
+func NewREST(imageRegistry image.Registry, imageStreamRegistry imagestream.Registry, maxManifestSize int64) *REST {
+	strategy := Strategy
+	strategy.maxManifestSize = maxManifestSize
 	return &REST{
 		imageRegistry:       imageRegistry,
 		imageStreamRegistry: imageStreamRegistry,
+		strategy:            strategy,
 	}
 }
 
@@ -40,11 +48,13 @@ func NewREST(imageRegistry image.Registry, imageStreamRegistry imagestream.Regis
 type imageStreamMappingStrategy struct {
 	runtime.ObjectTyper
 	kapi.NameGenerator
+
+	maxManifestSize int64
 }
 
 // Strategy is the default logic that applies when creating ImageStreamMapping
 // objects via the REST API.
-var Strategy = imageStreamMappingStrategy{kapi.Scheme, kapi.SimpleNameGenerator}
+var Strategy = imageStreamMappingStrategy{ObjectTyper: kapi.Scheme, NameGenerator: kapi.SimpleNameGenerator}
 
 // New returns a new ImageStreamMapping for use with Create.
 func (r *REST) New() runtime.Object {
@@ -56,23 +66,34 @@ func (s imageStreamMappingStrategy) NamespaceScoped() bool {
 	return true
 }
 
-// PrepareForCreate clears fields that are not allowed to be set by end users on creation.
+// PrepareForCreate clears fields that are not allowed to be set by end users
+// on creation, and trims the whitespace around the references of the mapping,
+// which the registry copies from the requests of clients.
 func (s imageStreamMappingStrategy) PrepareForCreate(obj runtime.Object) {
+	mapping := obj.(*api.ImageStreamMapping)
+	mapping.Tag = strings.TrimSpace(mapping.Tag)
+	mapping.DockerImageRepository = strings.TrimSpace(mapping.DockerImageRepository)
+	mapping.Image.Name = strings.TrimSpace(mapping.Image.Name)
+	mapping.Image.DockerImageReference = strings.TrimSpace(mapping.Image.DockerImageReference)
 }
 
-// Validate validates a new ImageStreamMapping.
+// Validate validates a new ImageStreamMapping, and the manifest of its image.
 func (s imageStreamMappingStrategy) Validate(ctx kapi.Context, obj runtime.Object) fielderrors.ValidationErrorList {
 	mapping := obj.(*api.ImageStreamMapping)
-	return validation.ValidateImageStreamMapping(mapping)
+	result := validation.ValidateImageStreamMapping(mapping)
+	return append(result, validation.ValidateImageManifest(&mapping.Image, s.maxManifestSize).Prefix("image")...)
 }
 
 // Create registers a new image (if it doesn't exist) and updates the
 // specified ImageStream's tags. If attempts to update the ImageStream fail
 // with a resource conflict, the update will be retried if the newer
 // ImageStream has no tag diffs from the previous state. If tag diffs are
-// detected, the conflict error is returned.
+// detected, the conflict error is returned. A mapping without a tag, e.g. for
+// an image pushed by digest, only registers the image, it can be tagged later.
+// A mapping replacing the image of an immutable tag, or into an image stream
+// being deleted, is forbidden.
 func (s *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, error) {
-	if err := rest.BeforeCreate(Strategy, ctx, obj); err != nil {
+	if err := rest.BeforeCreate(s.strategy, ctx, obj); err != nil {
 		return nil, err
 	}
 
@@ -82,16 +103,19 @@ func (s *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, err
 	if err != nil {
 		return nil, err
 	}
+	if stream.DeletionTimestamp != nil {
+		return nil, imagestream.NewDeletedError("imageStreamMapping", mapping.Name, stream)
+	}
 
 	image := mapping.Image
 	tag := mapping.Tag
-	if len(tag) == 0 {
-		tag = api.DefaultImageTag
-	}
 
 	if err := s.imageRegistry.CreateImage(ctx, &image); err != nil && !errors.IsAlreadyExists(err) {
 		return nil, err
 	}
+	if len(tag) == 0 {
+		return &unversioned.Status{Status: unversioned.StatusSuccess}, nil
+	}
 
 	next := api.TagEvent{
 		Created:              unversioned.Now(),
@@ -101,11 +125,15 @@ func (s *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, err
 
 	err = wait.ExponentialBackoff(wait.Backoff{Steps: maxRetriesOnConflict}, func() (bool, error) {
 		lastEvent := api.LatestTaggedImage(stream, tag)
+		if api.IsTagImmutable(stream, tag) && lastEvent.Image != image.Name {
+			return false, errors.NewForbidden("imageStreamMapping", mapping.Name, fmt.Errorf("tag %q of image stream %s/%s is immutable, its image %s can't be replaced", tag, stream.Namespace, stream.Name, lastEvent.Image))
+		}
 		if !api.AddTagEventToImageStream(stream, tag, next) {
 			// nothing actually changed
 			return true, nil
 		}
 		api.UpdateTrackingTags(stream, tag, next)
+		api.UpdateVersionTrackingTags(stream)
 		_, err := s.imageStreamRegistry.UpdateImageStreamStatus(ctx, stream)
 		if err == nil {
 			return true, nil
@@ -119,6 +147,9 @@ func (s *REST) Create(ctx kapi.Context, obj runtime.Object) (runtime.Object, err
 		if findLatestErr != nil {
 			return false, findLatestErr
 		}
+		if latestStream.DeletionTimestamp != nil {
+			return false, imagestream.NewDeletedError("imageStreamMapping", mapping.Name, latestStream)
+		}
 		newerEvent := api.LatestTaggedImage(latestStream, tag)
 		if lastEvent == nil || kapi.Semantic.DeepEqual(lastEvent, newerEvent) {
 			// The tag hasn't changed, so try again with the updated stream.
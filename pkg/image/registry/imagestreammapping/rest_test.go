@@ -45,11 +45,11 @@ func setup(t *testing.T) (*tools.FakeEtcdClient, kstorage.Interface, *REST) {
 	fakeEtcdClient := tools.NewFakeEtcdClient(t)
 	fakeEtcdClient.TestIndex = true
 	helper := etcdstorage.NewEtcdStorage(fakeEtcdClient, latest.Codec, etcdtest.PathPrefix())
-	imageStorage := imageetcd.NewREST(helper)
+	imageStorage, _ := imageetcd.NewREST(helper)
 	imageRegistry := image.NewRegistry(imageStorage)
-	imageStreamStorage, imageStreamStatus, internalStorage := imagestreametcd.NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	imageStreamStorage, imageStreamStatus, internalStorage, _ := imagestreametcd.NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 	imageStreamRegistry := imagestream.NewRegistry(imageStreamStorage, imageStreamStatus, internalStorage)
-	storage := NewREST(imageRegistry, imageStreamRegistry)
+	storage := NewREST(imageRegistry, imageStreamRegistry, 0)
 	return fakeEtcdClient, helper, storage
 }
 
@@ -174,6 +174,137 @@ func TestCreateSuccessWithName(t *testing.T) {
 	}
 }
 
+func TestCreateManifestTooLarge(t *testing.T) {
+	fakeEtcdClient, _, storage := setup(t)
+	storage.strategy.maxManifestSize = 16
+
+	initialRepo := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "somerepo"},
+	}
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/somerepo")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, initialRepo),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+
+	mapping := validNewMappingWithName()
+	mapping.Image.Name = "sha256:4e07408562bedb8b60ce05c1decfe3ad16b72230967de01f640b7e4729b49fce"
+	mapping.Image.DockerImageManifest = `{"schemaVersion":1,"fsLayers":[]}`
+	_, err := storage.Create(kapi.NewDefaultContext(), mapping)
+	if !errors.IsInvalid(err) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+	if _, ok := fakeEtcdClient.Data[etcdtest.AddPrefix("/images/"+mapping.Image.Name)]; ok {
+		t.Errorf("expected the image not to be created")
+	}
+}
+
+func TestCreateImmutableTag(t *testing.T) {
+	fakeEtcdClient, helper, storage := setup(t)
+
+	initialRepo := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "somerepo"},
+		Spec: api.ImageStreamSpec{
+			Tags: map[string]api.TagReference{
+				"latest": {Immutable: true},
+			},
+		},
+		Status: api.ImageStreamStatus{
+			Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{DockerImageReference: "localhost:5000/default/somerepo:imageID0", Image: "imageID0"}}},
+			},
+		},
+	}
+
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/somerepo")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, initialRepo),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+
+	_, err := storage.Create(kapi.NewDefaultContext(), validNewMappingWithName())
+	if !errors.IsForbidden(err) {
+		t.Fatalf("Expected a forbidden error replacing the image of an immutable tag, got %#v", err)
+	}
+
+	repo := &api.ImageStream{}
+	if err := helper.Get(kapi.NewDefaultContext(), "/imagestreams/default/somerepo", repo, false); err != nil {
+		t.Errorf("Unexpected non-nil err: %#v", err)
+	}
+	if e, a := "imageID0", repo.Status.Tags["latest"].Items[0].Image; e != a {
+		t.Errorf("Expected %s, got %s", e, a)
+	}
+}
+
+func TestCreateWithoutTag(t *testing.T) {
+	fakeEtcdClient, helper, storage := setup(t)
+
+	initialRepo := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "somerepo"},
+	}
+
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/somerepo")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, initialRepo),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+
+	mapping := validNewMappingWithName()
+	mapping.Tag = ""
+	_, err := storage.Create(kapi.NewDefaultContext(), mapping)
+	if err != nil {
+		t.Fatalf("Unexpected error creating mapping: %#v", err)
+	}
+
+	image := &api.Image{}
+	if err := helper.Get(kapi.NewDefaultContext(), "/images/imageID1", image, false); err != nil {
+		t.Errorf("Unexpected error retrieving image: %#v", err)
+	}
+
+	repo := &api.ImageStream{}
+	if err := helper.Get(kapi.NewDefaultContext(), "/imagestreams/default/somerepo", repo, false); err != nil {
+		t.Errorf("Unexpected non-nil err: %#v", err)
+	}
+	if len(repo.Status.Tags) != 0 {
+		t.Errorf("Expected no tags, got %#v", repo.Status.Tags)
+	}
+}
+
+func TestCreateImageStreamBeingDeleted(t *testing.T) {
+	fakeEtcdClient, _, storage := setup(t)
+
+	now := unversioned.Now()
+	initialRepo := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "somerepo", DeletionTimestamp: &now},
+	}
+
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/somerepo")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, initialRepo),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+
+	_, err := storage.Create(kapi.NewDefaultContext(), validNewMappingWithName())
+	if !errors.IsForbidden(err) {
+		t.Fatalf("Expected a forbidden error mapping an image into an image stream being deleted, got %#v", err)
+	}
+	if _, ok := fakeEtcdClient.Data[etcdtest.AddPrefix("/images/imageID1")]; ok {
+		t.Errorf("expected the image not to be created")
+	}
+}
+
 func TestAddExistingImageWithNewTag(t *testing.T) {
 	imageID := "8d812da98d6dd61620343f1a5bf6585b34ad6ed16e5c5f7c7216a525d6aeb772"
 	existingRepo := &api.ImageStream{
@@ -452,6 +583,7 @@ func TestTrackingTags(t *testing.T) {
 // using failing registry update calls will return an error.
 func TestCreateRetryUnrecoverable(t *testing.T) {
 	rest := &REST{
+		strategy: Strategy,
 		imageRegistry: &fakeImageRegistry{
 			createImage: func(ctx kapi.Context, image *api.Image) error {
 				return nil
@@ -485,6 +617,7 @@ func TestCreateRetryUnrecoverable(t *testing.T) {
 func TestCreateRetryConflictNoTagDiff(t *testing.T) {
 	firstUpdate := true
 	rest := &REST{
+		strategy: Strategy,
 		imageRegistry: &fakeImageRegistry{
 			createImage: func(ctx kapi.Context, image *api.Image) error {
 				return nil
@@ -527,6 +660,7 @@ func TestCreateRetryConflictTagDiff(t *testing.T) {
 	firstGet := true
 	firstUpdate := true
 	rest := &REST{
+		strategy: Strategy,
 		imageRegistry: &fakeImageRegistry{
 			createImage: func(ctx kapi.Context, image *api.Image) error {
 				return nil
@@ -581,6 +715,7 @@ type fakeImageRegistry struct {
 	listImages  func(ctx kapi.Context, selector labels.Selector) (*api.ImageList, error)
 	getImage    func(ctx kapi.Context, id string) (*api.Image, error)
 	createImage func(ctx kapi.Context, image *api.Image) error
+	updateImage func(ctx kapi.Context, image *api.Image) (*api.Image, error)
 	deleteImage func(ctx kapi.Context, id string) error
 	watchImages func(ctx kapi.Context, label labels.Selector, field fields.Selector, resourceVersion string) (watch.Interface, error)
 }
@@ -594,6 +729,9 @@ func (f *fakeImageRegistry) GetImage(ctx kapi.Context, id string) (*api.Image, e
 func (f *fakeImageRegistry) CreateImage(ctx kapi.Context, image *api.Image) error {
 	return f.createImage(ctx, image)
 }
+func (f *fakeImageRegistry) UpdateImage(ctx kapi.Context, image *api.Image) (*api.Image, error) {
+	return f.updateImage(ctx, image)
+}
 func (f *fakeImageRegistry) DeleteImage(ctx kapi.Context, id string) error {
 	return f.deleteImage(ctx, id)
 }
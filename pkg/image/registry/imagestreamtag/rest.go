@@ -18,7 +18,8 @@ import (
 )
 
 // REST implements the RESTStorage interface for ImageStreamTag
-// It only supports the Get method and is used to simplify retrieving an Image by tag from an ImageStream
+// It is used to simplify retrieving an Image by tag from an ImageStream, and to update the
+// annotations of a tag without editing the whole ImageStream
 type REST struct {
 	imageRegistry       image.Registry
 	imageStreamRegistry imagestream.Registry
@@ -56,6 +57,21 @@ func nameAndTag(id string) (name string, tag string, err error) {
 	return
 }
 
+// nameAndPinnedTag is nameAndTag for an id which may pin the tag to an image of
+// its history, <name>:<tag>@<digest>. The digest is kept in the returned tag.
+func nameAndPinnedTag(id string) (name string, tag string, err error) {
+	parts := strings.SplitN(id, "@", 2)
+	name, tag, err = nameAndTag(parts[0])
+	if err != nil || len(parts) == 1 {
+		return
+	}
+	tag = tag + "@" + parts[1]
+	if _, _, ok := api.SplitTagDigest(tag); !ok {
+		err = kapierrors.NewBadRequest("ImageStreamTags pinned to an image must be retrieved with <name>:<tag>@<digest>")
+	}
+	return
+}
+
 func (r *REST) List(ctx kapi.Context, label labels.Selector, field fields.Selector) (runtime.Object, error) {
 	imageStreams, err := r.imageStreamRegistry.ListImageStreams(ctx, labels.Everything())
 	if err != nil {
@@ -69,6 +85,10 @@ func (r *REST) List(ctx kapi.Context, label labels.Selector, field fields.Select
 		for currTag := range currIS.Status.Tags {
 			istag, err := newISTag(currTag, &currIS, nil)
 			if err != nil {
+				if kapierrors.IsNotFound(err) {
+					// the tag has no image yet, e.g. its import failed
+					continue
+				}
 				return nil, err
 			}
 			matches, err := matcher.Matches(istag)
@@ -85,9 +105,10 @@ func (r *REST) List(ctx kapi.Context, label labels.Selector, field fields.Select
 	return list, nil
 }
 
-// Get retrieves an image that has been tagged by stream and tag. `id` is of the format <stream name>:<tag>.
+// Get retrieves an image that has been tagged by stream and tag. `id` is of the format <stream name>:<tag>,
+// or <stream name>:<tag>@<digest> to retrieve the image with that digest from the history of the tag.
 func (r *REST) Get(ctx kapi.Context, id string) (runtime.Object, error) {
-	name, tag, err := nameAndTag(id)
+	name, tag, err := nameAndPinnedTag(id)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +136,14 @@ func (r *REST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, boo
 	if err != nil {
 		return nil, false, err
 	}
+	// the resource version of a tag is the one of its image stream, reject
+	// updates made from a stale copy before comparing the other fields
+	if len(istag.ResourceVersion) == 0 {
+		return nil, false, kapierrors.NewBadRequest("the resourceVersion of the ImageStreamTag must be specified for an update")
+	}
+	if istag.ResourceVersion != old.(*api.ImageStreamTag).ResourceVersion {
+		return nil, false, kapierrors.NewConflict("imageStreamTag", istag.Name, fmt.Errorf("the image stream has been modified; please apply your changes to the latest version and try again"))
+	}
 
 	if err := rest.BeforeUpdate(Strategy, ctx, obj, old); err != nil {
 		return nil, false, err
@@ -127,6 +156,10 @@ func (r *REST) Update(ctx kapi.Context, obj runtime.Object) (runtime.Object, boo
 	}
 
 	imageStream, err := r.imageStreamRegistry.GetImageStream(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	imageStream.ResourceVersion = istag.ResourceVersion
 	if imageStream.Spec.Tags == nil {
 		imageStream.Spec.Tags = map[string]api.TagReference{}
 	}
@@ -187,9 +220,10 @@ func (r *REST) Delete(ctx kapi.Context, id string) (runtime.Object, error) {
 	return &unversioned.Status{Status: unversioned.StatusSuccess}, nil
 }
 
-// imageFor retrieves the most recent image for a tag in a given imageStreem.
+// imageFor retrieves the most recent image for a tag in a given imageStreem, or
+// the image the tag is pinned to.
 func (r *REST) imageFor(ctx kapi.Context, tag string, imageStream *api.ImageStream) (*api.Image, error) {
-	event := api.LatestTaggedImage(imageStream, tag)
+	event := api.TaggedImage(imageStream, tag)
 	if event == nil || len(event.Image) == 0 {
 		return nil, kapierrors.NewNotFound("imageStreamTag", api.JoinImageStreamTag(imageStream.Name, tag))
 	}
@@ -200,7 +234,7 @@ func (r *REST) imageFor(ctx kapi.Context, tag string, imageStream *api.ImageStre
 func newISTag(tag string, imageStream *api.ImageStream, image *api.Image) (*api.ImageStreamTag, error) {
 	istagName := api.JoinImageStreamTag(imageStream.Name, tag)
 
-	event := api.LatestTaggedImage(imageStream, tag)
+	event := api.TaggedImage(imageStream, tag)
 	if event == nil || len(event.Image) == 0 {
 		return nil, kapierrors.NewNotFound("imageStreamTag", istagName)
 	}
@@ -218,7 +252,8 @@ func newISTag(tag string, imageStream *api.ImageStream, image *api.Image) (*api.
 	// if the imageStream has Spec.Tags[tag].Annotations[k] = v, copy it to the image's annotations
 	// and add them to the istag's annotations
 	if imageStream.Spec.Tags != nil {
-		if tagRef, ok := imageStream.Spec.Tags[tag]; ok {
+		specTag, _, _ := api.SplitTagDigest(tag)
+		if tagRef, ok := imageStream.Spec.Tags[specTag]; ok {
 			if image != nil && image.Annotations == nil {
 				image.Annotations = make(map[string]string)
 			}
@@ -246,8 +281,9 @@ func newISTag(tag string, imageStream *api.ImageStream, image *api.Image) (*api.
 	// real value from status. This should fix the problem for v1 registries,
 	// where mutliple tags point to a single id and only the first image's metadata
 	// is saved. This in turn will always return the pull spec from the first
-	// imported image, which might be different than the requested tag.
-	ist.Image.DockerImageReference = event.DockerImageReference
+	// imported image, which might be different than the requested tag. Tags
+	// whose reference policy is Local are pulled through the integrated registry.
+	ist.Image.DockerImageReference = api.ResolveReferenceForTagEvent(imageStream, tag, event)
 
 	return ist, nil
 }
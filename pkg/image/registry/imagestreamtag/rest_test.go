@@ -58,9 +58,9 @@ func setup(t *testing.T) (*tools.FakeEtcdClient, kstorage.Interface, *REST) {
 	fakeEtcdClient := tools.NewFakeEtcdClient(t)
 	fakeEtcdClient.TestIndex = true
 	helper := etcdstorage.NewEtcdStorage(fakeEtcdClient, latest.Codec, etcdtest.PathPrefix())
-	imageStorage := imageetcd.NewREST(helper)
+	imageStorage, _ := imageetcd.NewREST(helper)
 	imageRegistry := image.NewRegistry(imageStorage)
-	imageStreamStorage, imageStreamStatus, internalStorage := imagestreametcd.NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{})
+	imageStreamStorage, imageStreamStatus, internalStorage, _ := imagestreametcd.NewREST(helper, testDefaultRegistry, &fakeSubjectAccessReviewRegistry{}, 0, nil)
 	imageStreamRegistry := imagestream.NewRegistry(imageStreamStorage, imageStreamStatus, internalStorage)
 	storage := NewREST(imageRegistry, imageStreamRegistry)
 	return fakeEtcdClient, helper, storage
@@ -324,6 +324,62 @@ func TestGetImageStreamTagDIR(t *testing.T) {
 	}
 }
 
+func TestGetPinnedImageStreamTag(t *testing.T) {
+	image := &api.Image{ObjectMeta: kapi.ObjectMeta{Name: "sha256:bbb"}, DockerImageReference: "foo/bar/baz@sha256:bbb"}
+	repo := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{
+			Namespace: "default",
+			Name:      "test",
+		},
+		Status: api.ImageStreamStatus{
+			Tags: map[string]api.TagEventList{
+				"latest": {
+					Items: []api.TagEvent{
+						{DockerImageReference: "foo/bar/baz@sha256:aaa", Image: "sha256:aaa"},
+						{DockerImageReference: "foo/bar/baz@sha256:bbb", Image: "sha256:bbb"},
+					},
+				},
+			},
+		},
+	}
+	fakeEtcdClient, _, storage := setup(t)
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/images/"+image.Name)] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, image),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+	fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/test")] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value:         runtime.EncodeOrDie(latest.Codec, repo),
+				ModifiedIndex: 1,
+			},
+		},
+	}
+
+	obj, err := storage.Get(kapi.NewDefaultContext(), "test:latest@sha256:bbb")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	actual := obj.(*api.ImageStreamTag)
+	if e, a := "test:latest@sha256:bbb", actual.Name; e != a {
+		t.Errorf("name: expected %v, got %v", e, a)
+	}
+	if e, a := "foo/bar/baz@sha256:bbb", actual.Image.DockerImageReference; e != a {
+		t.Errorf("DockerImageReference: expected %v, got %v", e, a)
+	}
+
+	if _, err := storage.Get(kapi.NewDefaultContext(), "test:latest@sha256:ccc"); !errors.IsNotFound(err) {
+		t.Errorf("expected a not found error for an image not in the history of the tag, got %v", err)
+	}
+	if _, err := storage.Get(kapi.NewDefaultContext(), "test:latest@other"); !errors.IsBadRequest(err) {
+		t.Errorf("expected a bad request error for an invalid digest, got %v", err)
+	}
+}
+
 func TestDeleteImageStreamTag(t *testing.T) {
 	tests := map[string]struct {
 		repo        *api.ImageStream
@@ -471,6 +527,7 @@ func TestDeleteImageStreamTag(t *testing.T) {
 					Kind: "ImageStreamTag",
 					Name: "test:foo",
 				},
+				ReferencePolicy: api.TagReferencePolicy{Type: api.SourceTagReferencePolicy},
 			},
 		}
 		expectedStreamStatus := map[string]api.TagEventList{
@@ -509,3 +566,114 @@ func TestDeleteImageStreamTag(t *testing.T) {
 
 	}
 }
+
+func TestUpdateImageStreamTag(t *testing.T) {
+	stream := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: api.ImageStreamSpec{
+			Tags: map[string]api.TagReference{
+				"latest": {
+					From:        &kapi.ObjectReference{Kind: "DockerImage", Name: "foo/bar:latest"},
+					Annotations: map[string]string{"color": "blue"},
+				},
+			},
+		},
+		Status: api.ImageStreamStatus{
+			Tags: map[string]api.TagEventList{
+				"latest": {Items: []api.TagEvent{{DockerImageReference: "foo/bar@10", Image: "10"}}},
+				"pushed": {Items: []api.TagEvent{{DockerImageReference: "foo/bar@10", Image: "10"}}},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		tag                 string
+		update              func(istag *api.ImageStreamTag)
+		expectConflict      bool
+		expectInvalid       bool
+		expectedAnnotations map[string]string
+	}{
+		"annotations": {
+			tag: "latest",
+			update: func(istag *api.ImageStreamTag) {
+				istag.Annotations = map[string]string{"color": "red", "description": "the latest build"}
+			},
+			expectedAnnotations: map[string]string{"color": "red", "description": "the latest build"},
+		},
+		"tag without spec": {
+			tag: "pushed",
+			update: func(istag *api.ImageStreamTag) {
+				istag.Annotations = map[string]string{"description": "pushed"}
+			},
+			expectedAnnotations: map[string]string{"description": "pushed"},
+		},
+		"stale resource version": {
+			tag: "latest",
+			update: func(istag *api.ImageStreamTag) {
+				istag.ResourceVersion = "0"
+				istag.Annotations = map[string]string{"color": "red"}
+			},
+			expectConflict: true,
+		},
+		"image changed": {
+			tag: "latest",
+			update: func(istag *api.ImageStreamTag) {
+				istag.Image.DockerImageReference = "foo/other@10"
+			},
+			expectInvalid: true,
+		},
+	}
+
+	for name, test := range tests {
+		fakeEtcdClient, helper, storage := setup(t)
+		fakeEtcdClient.Data[etcdtest.AddPrefix("/images/10")] = tools.EtcdResponseWithError{
+			R: &etcd.Response{
+				Node: &etcd.Node{
+					Value:         runtime.EncodeOrDie(latest.Codec, &api.Image{ObjectMeta: kapi.ObjectMeta{Name: "10"}, DockerImageReference: "foo/bar@10"}),
+					ModifiedIndex: 1,
+				},
+			},
+		}
+		fakeEtcdClient.Data[etcdtest.AddPrefix("/imagestreams/default/test")] = tools.EtcdResponseWithError{
+			R: &etcd.Response{
+				Node: &etcd.Node{
+					Value:         runtime.EncodeOrDie(latest.Codec, stream),
+					ModifiedIndex: 1,
+				},
+			},
+		}
+
+		ctx := kapi.NewDefaultContext()
+		obj, err := storage.Get(ctx, "test:"+test.tag)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		istag := obj.(*api.ImageStreamTag)
+		test.update(istag)
+
+		_, _, err = storage.Update(ctx, istag)
+		switch {
+		case test.expectConflict:
+			if !errors.IsConflict(err) {
+				t.Errorf("%s: expected a conflict, got %v", name, err)
+			}
+			continue
+		case test.expectInvalid:
+			if !errors.IsInvalid(err) {
+				t.Errorf("%s: expected an invalid error, got %v", name, err)
+			}
+			continue
+		case err != nil:
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		updated := &api.ImageStream{}
+		if err := helper.Get(ctx, "/imagestreams/default/test", updated, false); err != nil {
+			t.Fatalf("%s: error retrieving updated stream: %v", name, err)
+		}
+		if e, a := test.expectedAnnotations, updated.Spec.Tags[test.tag].Annotations; !reflect.DeepEqual(e, a) {
+			t.Errorf("%s: expected annotations %v, got %v", name, e, a)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package imagestreamtag
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/registry/generic"
+	"k8s.io/kubernetes/pkg/util"
+	"k8s.io/kubernetes/pkg/watch"
+
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+// Watch begins watching for changes to the tags of the image streams of the
+// namespace of ctx, or of all the namespaces if ctx has none. Changes to the
+// image streams are translated into an event for each tag that was added,
+// deleted, or whose latest image or annotations changed. The tags of an image
+// stream first seen through a modification, as when resuming a watch from a
+// resource version, are reported as modified.
+func (r *REST) Watch(ctx kapi.Context, label labels.Selector, field fields.Selector, resourceVersion string) (watch.Interface, error) {
+	streams, err := r.imageStreamRegistry.WatchImageStreams(ctx, labels.Everything(), fields.Everything(), resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	w := &tagWatcher{
+		ctx:     ctx,
+		rest:    r,
+		matcher: MatchImageStreamTag(label, field),
+		streams: streams,
+		tags:    make(map[string]map[string]*api.ImageStreamTag),
+		result:  make(chan watch.Event),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// tagWatcher translates the events of a watch on image streams into events on
+// their tags.
+type tagWatcher struct {
+	ctx     kapi.Context
+	rest    *REST
+	matcher generic.Matcher
+	streams watch.Interface
+
+	// tags are the tags last sent for each image stream, by namespace and name.
+	tags map[string]map[string]*api.ImageStreamTag
+
+	result   chan watch.Event
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// ResultChan implements watch.Interface.
+func (w *tagWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// Stop implements watch.Interface.
+func (w *tagWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.streams.Stop()
+	})
+}
+
+func (w *tagWatcher) run() {
+	defer util.HandleCrash()
+	defer close(w.result)
+
+	for event := range w.streams.ResultChan() {
+		stream, ok := event.Object.(*api.ImageStream)
+		if !ok {
+			if !w.send(event) {
+				return
+			}
+			continue
+		}
+		for _, e := range w.translate(event.Type, stream) {
+			if matches, err := w.matcher.Matches(e.Object); err != nil || !matches {
+				continue
+			}
+			if !w.send(e) {
+				return
+			}
+		}
+	}
+}
+
+// send sends event to the consumer of the watch, and returns false if the
+// watch was stopped first.
+func (w *tagWatcher) send(event watch.Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// translate returns the events on the tags of stream caused by an event of
+// type eventType on it, and records the tags sent.
+func (w *tagWatcher) translate(eventType watch.EventType, stream *api.ImageStream) []watch.Event {
+	key := stream.Namespace + "/" + stream.Name
+	old, known := w.tags[key]
+	current := make(map[string]*api.ImageStreamTag)
+	var events []watch.Event
+
+	if eventType == watch.Deleted && !known {
+		// the last state of the stream has the tags that were deleted with it
+		old = make(map[string]*api.ImageStreamTag)
+		for tag := range stream.Status.Tags {
+			if istag, err := newISTag(tag, stream, nil); err == nil {
+				old[tag] = istag
+			}
+		}
+	}
+	if eventType != watch.Deleted {
+		for tag := range stream.Status.Tags {
+			istag, err := newISTag(tag, stream, nil)
+			if err != nil {
+				// the tag has no image yet, e.g. its import failed
+				continue
+			}
+			previous, seen := old[tag]
+			if seen && tagUnchanged(previous, istag) {
+				current[tag] = withResourceVersion(previous, stream.ResourceVersion)
+				continue
+			}
+			if image, err := w.rest.imageFor(w.ctx, tag, stream); err == nil {
+				if withImage, err := newISTag(tag, stream, image); err == nil {
+					istag = withImage
+				}
+			} else {
+				glog.V(4).Infof("Unable to retrieve the image of the image stream tag %s/%s: %v", istag.Namespace, istag.Name, err)
+			}
+			current[tag] = istag
+
+			switch {
+			case seen, !known && eventType == watch.Modified:
+				events = append(events, watch.Event{Type: watch.Modified, Object: istag})
+			default:
+				events = append(events, watch.Event{Type: watch.Added, Object: istag})
+			}
+		}
+	}
+
+	for tag, previous := range old {
+		if _, ok := current[tag]; ok {
+			continue
+		}
+		events = append(events, watch.Event{Type: watch.Deleted, Object: withResourceVersion(previous, stream.ResourceVersion)})
+	}
+
+	if eventType == watch.Deleted {
+		delete(w.tags, key)
+	} else {
+		w.tags[key] = current
+	}
+	return events
+}
+
+// tagUnchanged returns true if the latest image and the annotations of the
+// image stream tag current are the same as the ones of previous.
+func tagUnchanged(previous, current *api.ImageStreamTag) bool {
+	return previous.Image.Name == current.Image.Name &&
+		previous.Image.DockerImageReference == current.Image.DockerImageReference &&
+		previous.CreationTimestamp.Equal(current.CreationTimestamp) &&
+		kapi.Semantic.DeepEqual(previous.Annotations, current.Annotations)
+}
+
+// withResourceVersion returns a copy of the image stream tag istag, which may
+// have been sent already, with the given resource version.
+func withResourceVersion(istag *api.ImageStreamTag, resourceVersion string) *api.ImageStreamTag {
+	copied := *istag
+	copied.ResourceVersion = resourceVersion
+	return &copied
+}
@@ -0,0 +1,86 @@
+package imagestreamtag
+
+import (
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
+
+	"github.com/openshift/origin/pkg/image/api"
+)
+
+func watchedStream(resourceVersion string, tags map[string]string) *api.ImageStream {
+	stream := &api.ImageStream{
+		ObjectMeta: kapi.ObjectMeta{Namespace: "default", Name: "test", ResourceVersion: resourceVersion},
+		Status:     api.ImageStreamStatus{Tags: map[string]api.TagEventList{}},
+	}
+	for tag, image := range tags {
+		stream.Status.Tags[tag] = api.TagEventList{Items: []api.TagEvent{{Image: image, DockerImageReference: "registry/default/test@" + image}}}
+	}
+	return stream
+}
+
+func TestWatchTranslate(t *testing.T) {
+	_, _, storage := setup(t)
+	w := &tagWatcher{
+		ctx:     kapi.NewDefaultContext(),
+		rest:    storage,
+		matcher: MatchImageStreamTag(labels.Everything(), fields.Everything()),
+		tags:    make(map[string]map[string]*api.ImageStreamTag),
+	}
+
+	type tagEvent struct {
+		Type            watch.EventType
+		Name            string
+		Image           string
+		ResourceVersion string
+	}
+	steps := []struct {
+		eventType watch.EventType
+		stream    *api.ImageStream
+		expected  []tagEvent
+	}{
+		{
+			eventType: watch.Added,
+			stream:    watchedStream("1", map[string]string{"latest": "image1"}),
+			expected:  []tagEvent{{watch.Added, "test:latest", "image1", "1"}},
+		},
+		{
+			eventType: watch.Modified,
+			stream:    watchedStream("2", map[string]string{"latest": "image1", "v1": "image1"}),
+			expected:  []tagEvent{{watch.Added, "test:v1", "image1", "2"}},
+		},
+		{
+			eventType: watch.Modified,
+			stream:    watchedStream("3", map[string]string{"latest": "image2"}),
+			expected: []tagEvent{
+				{watch.Modified, "test:latest", "image2", "3"},
+				{watch.Deleted, "test:v1", "image1", "3"},
+			},
+		},
+		{
+			eventType: watch.Deleted,
+			stream:    watchedStream("4", map[string]string{"latest": "image2"}),
+			expected:  []tagEvent{{watch.Deleted, "test:latest", "image2", "4"}},
+		},
+		{
+			eventType: watch.Modified,
+			stream:    watchedStream("5", map[string]string{"latest": "image3"}),
+			expected:  []tagEvent{{watch.Modified, "test:latest", "image3", "5"}},
+		},
+	}
+
+	for i, step := range steps {
+		var actual []tagEvent
+		for _, event := range w.translate(step.eventType, step.stream) {
+			istag := event.Object.(*api.ImageStreamTag)
+			actual = append(actual, tagEvent{event.Type, istag.Name, istag.Image.Name, istag.ResourceVersion})
+		}
+		if !reflect.DeepEqual(actual, step.expected) {
+			t.Errorf("%d: unexpected events:\n%#v\nexpected:\n%#v", i, actual, step.expected)
+		}
+	}
+}
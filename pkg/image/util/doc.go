@@ -0,0 +1,3 @@
+// Package util contains common functions that are used by the image
+// controllers and commands to update images.
+package util
@@ -0,0 +1,33 @@
+package util
+
+import (
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+
+	"github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+// MarkImageForPruning sets the ImageMarkedForPruningAnnotation on the image
+// named imageName, retrying on conflicts. An image that doesn't exist anymore
+// is ignored.
+func MarkImageForPruning(images client.ImagesInterfacer, imageName string) error {
+	return kclient.RetryOnConflict(kclient.DefaultRetry, func() error {
+		image, err := images.Images().Get(imageName)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if image.Annotations[imageapi.ImageMarkedForPruningAnnotation] == "true" {
+			return nil
+		}
+		if image.Annotations == nil {
+			image.Annotations = make(map[string]string)
+		}
+		image.Annotations[imageapi.ImageMarkedForPruningAnnotation] = "true"
+		_, err = images.Images().Update(image)
+		return err
+	})
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"testing"
+
+	kapi "k8s.io/kubernetes/pkg/api"
+	kerrors "k8s.io/kubernetes/pkg/api/errors"
+	ktestclient "k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+
+	"github.com/openshift/origin/pkg/client/testclient"
+	imageapi "github.com/openshift/origin/pkg/image/api"
+)
+
+func TestMarkImageForPruning(t *testing.T) {
+	fake := testclient.NewSimpleFake(&imageapi.Image{ObjectMeta: kapi.ObjectMeta{Name: "a"}})
+	conflicts := 1
+	fake.PrependReactor("update", "images", func(action ktestclient.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			conflicts--
+			return true, nil, kerrors.NewConflict("images", "a", nil)
+		}
+		return false, nil, nil
+	})
+
+	if err := MarkImageForPruning(fake, "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var updates []*imageapi.Image
+	for _, action := range fake.Actions() {
+		if action.Matches("update", "images") {
+			updates = append(updates, action.(ktestclient.UpdateAction).GetObject().(*imageapi.Image))
+		}
+	}
+	if len(updates) != 2 || updates[1].Annotations[imageapi.ImageMarkedForPruningAnnotation] != "true" {
+		t.Errorf("expected the image to be marked for pruning after a conflict, got %#v", updates)
+	}
+
+	// marked images aren't updated again
+	fake = testclient.NewSimpleFake(&imageapi.Image{ObjectMeta: kapi.ObjectMeta{
+		Name:        "a",
+		Annotations: map[string]string{imageapi.ImageMarkedForPruningAnnotation: "true"},
+	}})
+	if err := MarkImageForPruning(fake, "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, action := range fake.Actions() {
+		if action.Matches("update", "images") {
+			t.Errorf("unexpected update %#v", action)
+		}
+	}
+}
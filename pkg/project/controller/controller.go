@@ -8,6 +8,7 @@ import (
 	"k8s.io/kubernetes/pkg/labels"
 
 	osclient "github.com/openshift/origin/pkg/client"
+	imageapi "github.com/openshift/origin/pkg/image/api"
 	projectutil "github.com/openshift/origin/pkg/project/util"
 )
 
@@ -183,7 +184,7 @@ func deletePolicies(client osclient.Interface, ns string) error {
 }
 
 func deleteImageStreams(client osclient.Interface, ns string) error {
-	items, err := client.ImageStreams(ns).List(labels.Everything(), fields.Everything())
+	items, err := client.ImageStreams(ns).List(labels.Everything(), imageapi.ImageStreamsIncludingDeleted())
 	if err != nil {
 		return err
 	}
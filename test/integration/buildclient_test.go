@@ -222,15 +222,17 @@ func NewTestBuildOpenshift(t *testing.T) *testBuildOpenshift {
 	buildConfigStorage := buildconfigetcd.NewStorage(etcdHelper)
 	buildConfigRegistry := buildconfigregistry.NewRegistry(buildConfigStorage)
 
-	imageStorage := imageetcd.NewREST(etcdHelper)
+	imageStorage, _ := imageetcd.NewREST(etcdHelper)
 	imageRegistry := image.NewRegistry(imageStorage)
 
-	imageStreamStorage, imageStreamStatus, internalStorage := imagestreametcd.NewREST(
+	imageStreamStorage, imageStreamStatus, internalStorage, _ := imagestreametcd.NewREST(
 		etcdHelper,
 		imagestream.DefaultRegistryFunc(func() (string, bool) {
 			return "registry:3000", true
 		}),
 		&fakeSubjectAccessReviewRegistry{},
+		0,
+		nil,
 	)
 	imageStreamRegistry := imagestream.NewRegistry(imageStreamStorage, imageStreamStatus, internalStorage)
 
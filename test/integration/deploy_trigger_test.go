@@ -358,19 +358,21 @@ func NewTestDeployOpenshift(t *testing.T) *testDeployOpenshift {
 
 	interfaces, _ := latest.InterfacesFor(latest.Version)
 
-	imageStorage := imageetcd.NewREST(etcdHelper)
+	imageStorage, _ := imageetcd.NewREST(etcdHelper)
 	imageRegistry := image.NewRegistry(imageStorage)
 
-	imageStreamStorage, imageStreamStatus, internalStorage := imagestreametcd.NewREST(
+	imageStreamStorage, imageStreamStatus, internalStorage, _ := imagestreametcd.NewREST(
 		etcdHelper,
 		imagestream.DefaultRegistryFunc(func() (string, bool) {
 			return "registry:3000", true
 		}),
 		&fakeSubjectAccessReviewRegistry{},
+		0,
+		nil,
 	)
 	imageStreamRegistry := imagestream.NewRegistry(imageStreamStorage, imageStreamStatus, internalStorage)
 
-	imageStreamMappingStorage := imagestreammapping.NewREST(imageRegistry, imageStreamRegistry)
+	imageStreamMappingStorage := imagestreammapping.NewREST(imageRegistry, imageStreamRegistry, 0)
 
 	imageStreamImageStorage := imagestreamimage.NewREST(imageRegistry, imageStreamRegistry)
 	//imageStreamImageRegistry := imagestreamimage.NewRegistry(imageStreamImageStorage)